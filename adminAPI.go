@@ -0,0 +1,422 @@
+package motan
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
+	"github.com/weibocom/motan-go/log"
+)
+
+// the prefix of the versioned admin api, all the responses are the json of writeHandlerResponse
+const adminAPIPrefix = "/v2/"
+
+// adminManagePaths are the manage paths change the agent, they require the admin token as the admin api if admin_token is set
+var adminManagePaths = map[string]bool{
+	"/200": true, "/503": true, "/switcher/set": true, "/hotrestart": true, "/config/reload": true,
+	"/registry/register": true, "/registry/unregister": true, "/registry/subscribe": true,
+}
+
+type adminCluster struct {
+	Key                string   `json:"key"`
+	Path               string   `json:"path"`
+	Group              string   `json:"group"`
+	Protocol           string   `json:"protocol"`
+	Version            string   `json:"version"`
+	Available          bool     `json:"available"`
+	Registries         []string `json:"registries"`
+	Filters            []string `json:"filters"`
+	Endpoints          int      `json:"endpoints"`
+	AvailableEndpoints int      `json:"available_endpoints"`
+}
+
+type adminEndpoint struct {
+	Address      string  `json:"address"`
+	Available    bool    `json:"available"`
+	Calls        int64   `json:"calls"`
+	Errors       int64   `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+
+	Pool *endpoint.ChannelPoolStats `json:"pool,omitempty"` // only the motan2 endpoints
+}
+
+type adminRegistry struct {
+	ID       string `json:"id"`
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+}
+
+type adminCommands struct {
+	TrafficControl *cluster.ClientCommand `json:"traffic_control,omitempty"`
+	Degrade        *cluster.ClientCommand `json:"degrade,omitempty"`
+	Switcher       *cluster.ClientCommand `json:"switcher,omitempty"`
+}
+
+// AdminAPIHandler is the versioned json api of the runtime state of the agent. /v2/clusters, /v2/endpoints?cluster={key},
+// /v2/registries, /v2/filters and /v2/config respond the clusters, the endpoints with the health and latency stats,
+// the registries, the filters and the active config. /v2/switchers and /v2/commands respond the switchers and the
+// effective degrade and traffic control commands of the clusters, and POST sets a switcher by name and value or
+// applies the command json of the body as the agent command of all the clusters. /v2/reload reloads the config as
+// /config/reload by POST, and /v2/loglevel responds the log level and the levels of the scopes, POST sets the level of the param
+// scope such as endpoint, registry, cluster or service:{path}, or the global level, by the param level for the optional
+// param duration, or resets the scope by reset=true. /v2/discovery responds the cached discovery results, POST flushes
+// the results of the param key, or all the results if no key. /v2/health
+// responds the health report of the endpoints, includes the ejections, the circuit breaker states and the error rates,
+// and /v2/health/stream pushes the reports and the health events as server-sent events. /v2/stats responds the qps,
+// the error rate and the latency percentiles of the last 1s, 10s and 60s per cluster and per endpoint, of the param
+// cluster or all the clusters. POST /v2/call executes a test call of the json body through the cluster, or the pinned
+// endpoint, and responds the value, the exception and the trace spans. /v2/endpoints/override responds the endpoints
+// pinned or excluded, and POST pins the traffic of the param cluster to the param addresses, or excludes them, by the
+// param mode pin, exclude or clear, for the param ttl.
+// if admin_token of the motan-agent section is set, the requests must have the header Authorization: Bearer {token},
+// so do the requests of the other manage paths which change the agent, see adminManagePaths
+type AdminAPIHandler struct {
+	agent *Agent
+}
+
+func (h *AdminAPIHandler) SetAgent(agent *Agent) {
+	h.agent = agent
+}
+
+func (h *AdminAPIHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json;charset=utf-8")
+	if !h.agent.adminAuthorized(req) {
+		writeHandlerResponse(res, http.StatusUnauthorized, "invalid admin token", nil)
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		writeHandlerResponse(res, http.StatusMethodNotAllowed, "method not allowed: "+req.Method, nil)
+		return
+	}
+	switch strings.TrimPrefix(req.URL.Path, adminAPIPrefix) {
+	case "clusters":
+		writeHandlerResponse(res, http.StatusOK, "ok", h.clusters())
+	case "endpoints":
+		key := req.FormValue("cluster")
+		c := h.agent.clustermap.LoadOrNil(key)
+		if c == nil {
+			writeHandlerResponse(res, http.StatusNotFound, "cluster not found: "+key, nil)
+			return
+		}
+		writeHandlerResponse(res, http.StatusOK, "ok", adminEndpoints(c.(*cluster.MotanCluster)))
+	case "endpoints/override":
+		h.endpointOverride(res, req)
+	case "registries":
+		writeHandlerResponse(res, http.StatusOK, "ok", h.registries())
+	case "filters":
+		writeHandlerResponse(res, http.StatusOK, "ok", h.filters())
+	case "config":
+		writeHandlerResponse(res, http.StatusOK, "ok", jsonValue(h.agent.GetContext().Config.GetOriginMap()))
+	case "switchers":
+		h.switchers(res, req)
+	case "commands":
+		h.commands(res, req)
+	case "reload":
+		if req.Method != http.MethodPost {
+			writeHandlerResponse(res, http.StatusMethodNotAllowed, "config reload needs POST", nil)
+			return
+		}
+		diff, err := h.agent.ReloadConfig()
+		if err != nil {
+			writeHandlerResponse(res, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		writeHandlerResponse(res, http.StatusOK, "ok", diff)
+	case "loglevel":
+		if req.Method == http.MethodPost {
+			if err := setLogLevel(req); err != nil {
+				writeHandlerResponse(res, http.StatusBadRequest, err.Error(), nil)
+				return
+			}
+		}
+		writeHandlerResponse(res, http.StatusOK, "ok", map[string]interface{}{"level": vlog.GetLevel(), "scopes": vlog.GetScopeLevels()})
+	case "health":
+		writeHandlerResponse(res, http.StatusOK, "ok", h.agent.healthReporter.getReport())
+	case "health/stream":
+		h.agent.healthReporter.serveStream(res, req)
+	case "call":
+		h.agent.debugCall(res, req)
+	case "stats":
+		writeHandlerResponse(res, http.StatusOK, "ok", h.agent.liveStats(req.FormValue("cluster")))
+	case "discovery":
+		c := cluster.GetDiscoveryCache()
+		if c == nil {
+			writeHandlerResponse(res, http.StatusNotFound, "discovery cache is not enabled", nil)
+			return
+		}
+		if req.Method == http.MethodPost {
+			writeHandlerResponse(res, http.StatusOK, "ok", map[string]int{"flushed": c.Flush(req.FormValue("key"))})
+			return
+		}
+		writeHandlerResponse(res, http.StatusOK, "ok", c.Entries())
+	default:
+		writeHandlerResponse(res, http.StatusNotFound, "unknown admin api: "+req.URL.Path, nil)
+	}
+}
+
+// adminAuthorized returns true if admin_token is not set or the request has the header Authorization: Bearer {token}
+func (a *Agent) adminAuthorized(req *http.Request) bool {
+	if a.adminToken == "" {
+		return true
+	}
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) == 1
+}
+
+func (h *AdminAPIHandler) clusters() []*adminCluster {
+	clusters := make([]*adminCluster, 0, 16)
+	h.agent.clustermap.Range(func(k, v interface{}) bool {
+		c := v.(*cluster.MotanCluster)
+		url := c.GetURL()
+		ac := &adminCluster{
+			Key:        k.(string),
+			Path:       url.Path,
+			Group:      url.Group,
+			Protocol:   url.Protocol,
+			Version:    url.GetParam(motan.VersionKey, ""),
+			Available:  c.IsAvailable(),
+			Registries: make([]string, 0, len(c.Registries)),
+			Filters:    urlFilters(url),
+		}
+		for _, r := range c.Registries {
+			ac.Registries = append(ac.Registries, r.GetURL().GetIdentity())
+		}
+		for _, ep := range c.GetRefers() {
+			ac.Endpoints++
+			if ep.IsAvailable() {
+				ac.AvailableEndpoints++
+			}
+		}
+		clusters = append(clusters, ac)
+		return true
+	})
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Key < clusters[j].Key })
+	return clusters
+}
+
+func adminEndpoints(c *cluster.MotanCluster) []*adminEndpoint {
+	refers := c.GetRefers()
+	endpoints := make([]*adminEndpoint, 0, len(refers))
+	for _, ep := range refers {
+		ae := &adminEndpoint{Address: ep.GetURL().GetAddressStr(), Available: ep.IsAvailable()}
+		if fep, ok := ep.(*motan.FilterEndPoint); ok {
+			ae.Calls = fep.Stats.Calls()
+			ae.Errors = fep.Stats.Errors()
+			ae.AvgLatencyMs = float64(fep.Stats.AvgLatency().Microseconds()) / 1000
+			if mep, ok := fep.Caller.(*endpoint.MotanEndpoint); ok {
+				stats := mep.PoolStats()
+				ae.Pool = &stats
+			}
+		}
+		endpoints = append(endpoints, ae)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Address < endpoints[j].Address })
+	return endpoints
+}
+
+// endpointOverride responds the pin or exclude of the param cluster, or of all the clusters. POST pins or excludes the
+// comma separated param addresses by the param mode for the param ttl such as 10m, or clears it by mode=clear
+func (h *AdminAPIHandler) endpointOverride(res http.ResponseWriter, req *http.Request) {
+	key := req.FormValue("cluster")
+	if req.Method != http.MethodPost {
+		overrides := make(map[string]*cluster.EndpointOverride)
+		h.agent.clustermap.Range(func(k, v interface{}) bool {
+			if o := v.(*cluster.MotanCluster).GetOverride(); o != nil && (key == "" || key == k.(string)) {
+				overrides[k.(string)] = o
+			}
+			return true
+		})
+		writeHandlerResponse(res, http.StatusOK, "ok", overrides)
+		return
+	}
+	c := h.agent.clustermap.LoadOrNil(key)
+	if c == nil {
+		writeHandlerResponse(res, http.StatusNotFound, "cluster not found: "+key, nil)
+		return
+	}
+	mc := c.(*cluster.MotanCluster)
+	var ttl time.Duration
+	if v := req.FormValue("ttl"); v != "" {
+		var err error
+		if ttl, err = time.ParseDuration(v); err != nil || ttl < 0 {
+			writeHandlerResponse(res, http.StatusBadRequest, "invalid ttl: "+v, nil)
+			return
+		}
+	}
+	addresses := motan.TrimSplit(req.FormValue("addresses"), ",")
+	var err error
+	switch mode := req.FormValue("mode"); mode {
+	case cluster.OverridePin:
+		err = mc.Pin(addresses, ttl)
+	case cluster.OverrideExclude:
+		err = mc.Exclude(addresses, ttl)
+	case "clear":
+		mc.ClearOverride()
+	default:
+		err = errors.New("invalid mode: " + mode)
+	}
+	if err != nil {
+		writeHandlerResponse(res, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	writeHandlerResponse(res, http.StatusOK, "ok", mc.GetOverride())
+}
+
+func (h *AdminAPIHandler) registries() interface{} {
+	registries := make([]*adminRegistry, 0, len(h.agent.GetContext().RegistryURLs))
+	for id, url := range h.agent.GetContext().RegistryURLs {
+		address := url.GetParam(motan.AddressKey, "")
+		if address == "" && url.Host != "" {
+			address = url.GetAddressStr()
+		}
+		registries = append(registries, &adminRegistry{ID: id, Protocol: url.Protocol, Address: address})
+	}
+	sort.Slice(registries, func(i, j int) bool { return registries[i].ID < registries[j].ID })
+	result := map[string]interface{}{"registries": registries}
+	if h.agent.configurer != nil {
+		result["dynamic"] = h.agent.configurer.getRegistryInfo()
+	}
+	return result
+}
+
+func (h *AdminAPIHandler) filters() interface{} {
+	clusters := make(map[string][]string)
+	h.agent.clustermap.Range(func(k, v interface{}) bool {
+		clusters[k.(string)] = urlFilters(v.(*cluster.MotanCluster).GetURL())
+		return true
+	})
+	result := map[string]interface{}{"clusters": clusters}
+	if f, ok := h.agent.extFactory.(interface{ GetFilterNames() []string }); ok {
+		result["registered"] = f.GetFilterNames()
+	}
+	return result
+}
+
+// setLogLevel sets the level of the param scope, or the global level if no scope, for the param duration such as 10m
+// or until changed. the level of the scope is removed if the param reset is true
+func setLogLevel(req *http.Request) error {
+	scope := req.FormValue("scope")
+	if reset, _ := strconv.ParseBool(req.FormValue("reset")); reset {
+		vlog.ResetScopeLevel(scope)
+		return nil
+	}
+	var d time.Duration
+	if v := req.FormValue("duration"); v != "" {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil || d < 0 {
+			return errors.New("invalid duration: " + v)
+		}
+	}
+	if scope != "" {
+		return vlog.SetScopeLevel(scope, req.FormValue("level"), d)
+	}
+	if d > 0 {
+		return vlog.SetLevelFor(req.FormValue("level"), d)
+	}
+	return vlog.SetLevel(req.FormValue("level"))
+}
+
+func (h *AdminAPIHandler) switchers(res http.ResponseWriter, req *http.Request) {
+	manager := motan.GetSwitcherManager()
+	if req.Method == http.MethodPost {
+		name, value := req.FormValue("name"), req.FormValue("value")
+		if s := manager.GetSwitcher(name); s != nil {
+			v, err := strconv.ParseBool(value)
+			if err != nil {
+				writeHandlerResponse(res, http.StatusBadRequest, "invalid switcher value: "+value, nil)
+				return
+			}
+			s.SetValue(v)
+		} else if s := manager.GetIntSwitcher(name); s != nil {
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				writeHandlerResponse(res, http.StatusBadRequest, "invalid int switcher value: "+value, nil)
+				return
+			}
+			s.SetValue(v)
+		} else {
+			writeHandlerResponse(res, http.StatusNotFound, "switcher not found: "+name, nil)
+			return
+		}
+	}
+	// the bool switcher is responded if an int switcher has the same name
+	switchers := make(map[string]interface{})
+	for name, v := range manager.GetAllIntSwitchers() {
+		switchers[name] = v
+	}
+	for name, v := range manager.GetAllSwitchers() {
+		switchers[name] = v
+	}
+	writeHandlerResponse(res, http.StatusOK, "ok", switchers)
+}
+
+func (h *AdminAPIHandler) commands(res http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil || cluster.ParseCommand(string(body)) == nil {
+			writeHandlerResponse(res, http.StatusBadRequest, "invalid command", nil)
+			return
+		}
+		// the command is replaced by the next agent command of the registry
+		h.agent.clustermap.Range(func(k, v interface{}) bool {
+			v.(*cluster.MotanCluster).NotifyAgentCommand(string(body))
+			return true
+		})
+	}
+	commands := make(map[string]*adminCommands)
+	h.agent.clustermap.Range(func(k, v interface{}) bool {
+		for _, r := range v.(*cluster.MotanCluster).Registries {
+			if w, ok := r.(*cluster.CommandRegistryWrapper); ok {
+				ac := &adminCommands{}
+				ac.TrafficControl, ac.Degrade, ac.Switcher = w.GetCommands()
+				commands[k.(string)] = ac
+				break
+			}
+		}
+		return true
+	})
+	writeHandlerResponse(res, http.StatusOK, "ok", commands)
+}
+
+func urlFilters(url *motan.URL) []string {
+	filters := make([]string, 0, 4)
+	for _, f := range motan.TrimSplit(url.GetParam(motan.FilterKey, ""), ",") {
+		if f != "" {
+			filters = append(filters, f)
+		}
+	}
+	return filters
+}
+
+// jsonValue converts the maps of the yaml config to the maps of string keys, so it can be encoded in json
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[motan.InterfaceToString(k)] = jsonValue(v)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = jsonValue(v)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, v := range t {
+			l[i] = jsonValue(v)
+		}
+		return l
+	}
+	return v
+}
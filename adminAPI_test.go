@@ -0,0 +1,70 @@
+package motan
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func newAdminTestAgent() (*Agent, *cluster.MotanCluster) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	AddDefaultExt(ext)
+	ext.RegistExtEndpoint("test", func(url *motan.URL) motan.EndPoint {
+		return &motan.TestEndPoint{URL: url}
+	})
+	a := NewAgent(ext)
+	a.agentURL = &motan.URL{Parameters: map[string]string{}}
+	a.setContext(&motan.Context{RegistryURLs: map[string]*motan.URL{}})
+	c := cluster.NewCluster(a.GetContext(), ext, &motan.URL{Protocol: "test", Path: "adminService", Parameters: map[string]string{motan.Lbkey: "random"}}, true)
+	c.Notify(&motan.URL{Protocol: "direct"}, []*motan.URL{{Host: "127.0.0.1", Port: 8001, Protocol: "test", Path: "adminService"}, {Host: "127.0.0.1", Port: 8002, Protocol: "test", Path: "adminService"}})
+	a.clustermap.Store("admin-cluster", c)
+	return a, c
+}
+
+func TestAdminEndpointOverride(t *testing.T) {
+	a, c := newAdminTestAgent()
+	defer c.Destroy()
+	a.adminToken = "token"
+	mux := http.NewServeMux()
+	a.registerManageHandlers(mux)
+	serve := func(method string, form url.Values, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, adminAPIPrefix+"endpoints/override", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, req)
+		return res
+	}
+
+	if res := serve(http.MethodPost, url.Values{"cluster": {"admin-cluster"}, "mode": {"pin"}, "addresses": {"127.0.0.1:8001"}}, ""); res.Code != http.StatusUnauthorized {
+		t.Errorf("override without token should be unauthorized. code:%d", res.Code)
+	}
+	res := serve(http.MethodPost, url.Values{"cluster": {"admin-cluster"}, "mode": {"pin"}, "addresses": {"127.0.0.1:8001"}, "ttl": {"1m"}}, "token")
+	if res.Code != http.StatusOK {
+		t.Fatalf("pin fail. code:%d, body:%s", res.Code, res.Body.String())
+	}
+	if o := c.GetOverride(); o == nil || o.Mode != cluster.OverridePin || len(o.Addresses) != 1 || o.Addresses[0] != "127.0.0.1:8001" {
+		t.Errorf("endpoints should be pinned. override:%+v", o)
+	}
+	res = serve(http.MethodGet, nil, "token")
+	if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), `"admin-cluster":{"mode":"pin"`) {
+		t.Errorf("overrides not correct. code:%d, body:%s", res.Code, res.Body.String())
+	}
+	if res = serve(http.MethodPost, url.Values{"cluster": {"admin-cluster"}, "mode": {"unknown"}}, "token"); res.Code != http.StatusBadRequest {
+		t.Errorf("unknown mode should fail. code:%d", res.Code)
+	}
+	if res = serve(http.MethodPost, url.Values{"cluster": {"unknown"}, "mode": {"clear"}}, "token"); res.Code != http.StatusNotFound {
+		t.Errorf("unknown cluster should not be found. code:%d", res.Code)
+	}
+	if res = serve(http.MethodPost, url.Values{"cluster": {"admin-cluster"}, "mode": {"clear"}}, "token"); res.Code != http.StatusOK || c.GetOverride() != nil {
+		t.Errorf("override should be cleared. code:%d, override:%+v", res.Code, c.GetOverride())
+	}
+}
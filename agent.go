@@ -4,19 +4,25 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/weibocom/motan-go/cluster"
 	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
 	"github.com/weibocom/motan-go/log"
+	"github.com/weibocom/motan-go/metrics"
 	mpro "github.com/weibocom/motan-go/protocol"
 	"github.com/weibocom/motan-go/registry"
 	mserver "github.com/weibocom/motan-go/server"
+	"github.com/weibocom/motan-go/tracing"
+	"github.com/weibocom/motan-go/transport"
 	"gopkg.in/yaml.v2"
 )
 
@@ -28,15 +34,21 @@ const (
 	defaultAgentGroup = "default_agent_group"
 	defaultRuntimeDir = "./agent_runtime"
 	defaultStatusSnap = "status"
+	defaultSwitchers  = "switchers.json"
+
+	defaultDiscoveryCacheMaxEntries = 1000
 )
 
 type Agent struct {
 	ConfigFile string
 	extFactory motan.ExtensionFactory
-	Context    *motan.Context
+	Context    *motan.Context // the context the agent started with, GetContext returns the current one
 	recover    bool
 
-	agentServer motan.Server
+	agentServer   motan.Server
+	wsServer      motan.Server
+	gatewayServer motan.Server
+	mListener     net.Listener
 
 	clustermap *motan.CopyOnWriteMap
 	status     int
@@ -45,6 +57,7 @@ type Agent struct {
 	port       int
 	mport      int
 	eport      int
+	wsport     int
 	pidfile    string
 	runtimedir string
 
@@ -54,8 +67,20 @@ type Agent struct {
 
 	manageHandlers map[string]http.Handler
 
-	svcLock sync.Mutex
-	clsLock sync.Mutex
+	svcLock    sync.Mutex
+	clsLock    sync.Mutex
+	reloadLock sync.Mutex
+
+	reloadInterval time.Duration
+	adminToken     string
+	pprofEnable    bool
+	pprofToken     string
+	autoSubscriber *autoSubscriber
+	healthReporter *healthReporter
+	startupStage   atomic.Value // string
+	context        atomic.Value // *motan.Context, replaced by ReloadConfig
+	tenants        map[string]*tenant
+	tenantServers  []motan.Server
 
 	configurer *DynamicConfigurer
 }
@@ -76,6 +101,18 @@ func NewAgent(extfactory motan.ExtensionFactory) *Agent {
 	return agent
 }
 
+// GetContext returns the current context of the agent, which is replaced once the config is reloaded
+func (a *Agent) GetContext() *motan.Context {
+	if ctx, ok := a.context.Load().(*motan.Context); ok {
+		return ctx
+	}
+	return a.Context
+}
+
+func (a *Agent) setContext(ctx *motan.Context) {
+	a.context.Store(ctx)
+}
+
 func (a *Agent) initProxyURL(url *motan.URL) {
 	export := url.GetParam(motan.ExportKey, "")
 	url.Protocol, url.Port, _ = motan.ParseExportInfo(export)
@@ -99,16 +136,39 @@ func (a *Agent) StartMotanAgent() {
 		fmt.Println("init agent context fail. ConfigFile:", a.Context.ConfigFile)
 		return
 	}
+	a.setContext(a.Context)
+	dryRunConfig(a.Context, a.extFactory)
+	metrics.AddSinks(a.Context, a.extFactory)
+	tracing.Start(a.Context)
 	fmt.Println("init agent context success.")
 	a.initParam()
 	a.SetSanpshotConf()
 	a.initAgentURL()
 	a.initStatus()
+	a.initStartupGate()
+	a.initTenants()
+	a.initRegistries()
 	a.initClusters()
+	a.registerMetricsGauges()
+	a.initAutoSubscriber()
+	a.initHealthReporter()
+	// the manage port is opened before warming, so the probes get 503 instead of connection refused
+	go a.startMServer()
+	a.warmupClusters()
+	a.setStartupStage(startupStageListening)
 	a.startServerAgent()
+	a.startWebSocketAgent()
+	a.startGatewayAgent()
+	a.startTenantAgents()
 	a.configurer = NewDynamicConfigurer(a)
-	go a.startMServer()
 	go a.registerAgent()
+	if a.reloadInterval > 0 {
+		go a.watchConfig(a.reloadInterval)
+	}
+	if a.GetContext().RemoteConfig != nil {
+		a.GetContext().RemoteConfig.Watch(a.reloadOnChange)
+	}
+	watchHotRestartSignal(a.HotRestart)
 	f, err := os.Create(a.pidfile)
 	if err != nil {
 		vlog.Errorf("create file %s fail.\n", a.pidfile)
@@ -117,6 +177,8 @@ func (a *Agent) StartMotanAgent() {
 		f.WriteString(strconv.Itoa(os.Getpid()))
 	}
 	vlog.Infoln("Motan agent is starting...")
+	// the agent port is listened right after
+	a.setStartupStage(startupStageReady)
 	a.startAgent()
 }
 
@@ -156,7 +218,7 @@ func (a *Agent) recoverStatus() {
 }
 
 func (a *Agent) initParam() {
-	section, err := a.Context.Config.GetSection("motan-agent")
+	section, err := a.GetContext().Config.GetSection("motan-agent")
 	if err != nil {
 		fmt.Println("get config of \"motan-agent\" fail! err " + err.Error())
 	}
@@ -168,7 +230,11 @@ func (a *Agent) initParam() {
 		logdir = "."
 	}
 	initLog(logdir)
-	registerSwitchers(a.Context)
+	initAccessLog(section, logdir)
+	initDeserializeLimits(section)
+	initBaggage(section)
+	initRequestID(section)
+	initObjectPool(section)
 
 	port := *motan.Port
 	if port == 0 && section != nil && section["port"] != nil {
@@ -194,6 +260,11 @@ func (a *Agent) initParam() {
 		eport = defaultEport
 	}
 
+	wsport := *motan.Wsport
+	if wsport == 0 && section != nil && section["wsport"] != nil {
+		wsport = section["wsport"].(int)
+	}
+
 	pidfile := *motan.Pidfile
 	if pidfile == "" && section != nil && section["pidfile"] != nil {
 		pidfile = section["pidfile"].(string)
@@ -202,6 +273,11 @@ func (a *Agent) initParam() {
 		pidfile = defaultPidFile
 	}
 
+	// max outbound connections to all providers
+	if section != nil && section["max_connections"] != nil {
+		endpoint.SetMaxConnections(section["max_connections"].(int))
+	}
+
 	runtimedir := ""
 	if section != nil && section["runtime_dir"] != nil {
 		runtimedir = section["runtime_dir"].(string)
@@ -210,22 +286,65 @@ func (a *Agent) initParam() {
 		runtimedir = defaultRuntimeDir
 	}
 
+	// seconds, the config is not watched if not set
+	if section != nil && section["config_reload_interval"] != nil {
+		a.reloadInterval = time.Duration(section["config_reload_interval"].(int)) * time.Second
+	}
+
+	// the token of the admin api, the api is not protected if not set
+	if section != nil && section["admin_token"] != nil {
+		a.adminToken = motan.InterfaceToString(section["admin_token"])
+	}
+
+	// the pprof and the runtime stats of the manage port are enabled at startup, also switched by /debug/pprof/sw
+	if section != nil && section["pprof_enable"] == true {
+		a.pprofEnable = true
+	}
+	if section != nil && section["pprof_token"] != nil {
+		a.pprofToken = motan.InterfaceToString(section["pprof_token"])
+	}
+
 	err = os.MkdirAll(runtimedir, 0775)
 	if err != nil {
 		panic("Init runtime directory error: " + err.Error())
 	}
 
+	// the switcher values set at runtime are restored after restart if enabled
+	if section != nil && section["switcher_persist"] == true {
+		motan.GetSwitcherManager().EnablePersistence(runtimedir + string(filepath.Separator) + defaultSwitchers)
+	}
+	registerSwitchers(a.GetContext())
+
+	// seconds, the discovery results are cached and used if the registries discover nothing, disabled if not set
+	if section != nil && section["discovery_cache_ttl"] != nil {
+		if ttl := section["discovery_cache_ttl"].(int); ttl > 0 {
+			maxEntries := defaultDiscoveryCacheMaxEntries
+			if section["discovery_cache_max_entries"] != nil {
+				maxEntries = section["discovery_cache_max_entries"].(int)
+			}
+			cachedir := runtimedir
+			if section["discovery_cache_dir"] != nil {
+				cachedir = section["discovery_cache_dir"].(string)
+				if err = os.MkdirAll(cachedir, 0775); err != nil {
+					panic("Init discovery cache directory error: " + err.Error())
+				}
+			}
+			cluster.SetDiscoveryCache(cluster.NewDiscoveryCache(cachedir, time.Duration(ttl)*time.Second, maxEntries))
+		}
+	}
+
 	vlog.Infof("agent port:%d, manage port:%d, pidfile:%s, logdir:%s, runtimedir:%s\n", port, mport, pidfile, logdir, runtimedir)
 	a.logdir = logdir
 	a.port = port
 	a.eport = eport
 	a.mport = mport
+	a.wsport = wsport
 	a.pidfile = pidfile
 	a.runtimedir = runtimedir
 }
 
 func (a *Agent) initClusters() {
-	for _, url := range a.Context.RefersURLs {
+	for _, url := range a.GetContext().RefersURLs {
 		a.initCluster(url)
 	}
 }
@@ -237,13 +356,31 @@ func (a *Agent) initCluster(url *motan.URL) {
 	if url.Parameters[motan.ApplicationKey] == "" {
 		url.Parameters[motan.ApplicationKey] = a.agentURL.Parameters[motan.ApplicationKey]
 	}
-	mapKey := getClusterKey(url.Group, url.GetStringParamsWithDefault(motan.VersionKey, "0.1"), url.Protocol, url.Path)
-	c := cluster.NewCluster(a.Context, a.extFactory, url, true)
-	a.clustermap.Store(mapKey, c)
+	if t := a.tenants[url.GetParam(motan.TenantKey, "")]; t != nil && t.filter != "" && url.GetParam(motan.FilterKey, "") == "" {
+		url.PutParam(motan.FilterKey, t.filter)
+	}
+	c := cluster.NewCluster(a.GetContext(), a.extFactory, url, true)
+	a.clustermap.Store(clusterKey(url), c)
+}
+
+func (a *Agent) initAutoSubscriber() {
+	section, _ := a.GetContext().Config.GetSection("motan-agent")
+	subscriber, err := newAutoSubscriber(a, section)
+	if err != nil {
+		vlog.Errorf("init auto subscriber fail. err:%v\n", err)
+		return
+	}
+	a.autoSubscriber = subscriber
+}
+
+func (a *Agent) initHealthReporter() {
+	section, _ := a.GetContext().Config.GetSection("motan-agent")
+	a.healthReporter = newHealthReporter(a, section)
+	go a.healthReporter.start()
 }
 
 func (a *Agent) SetSanpshotConf() {
-	section, err := a.Context.Config.GetSection("motan-agent")
+	section, err := a.GetContext().Config.GetSection("motan-agent")
 	if err != nil {
 		vlog.Infoln("get config of \"motan-agent\" fail! err " + err.Error())
 	}
@@ -258,7 +395,7 @@ func (a *Agent) SetSanpshotConf() {
 }
 
 func (a *Agent) initAgentURL() {
-	agentURL := a.Context.AgentURL
+	agentURL := a.GetContext().AgentURL
 	if agentURL.Host == "" {
 		agentURL.Host = motan.GetLocalIP()
 	}
@@ -295,18 +432,59 @@ func (a *Agent) startAgent() {
 	server.SetMessageHandler(handler)
 	vlog.Infof("Motan agent is started. port:%d\n", a.port)
 	fmt.Println("Motan agent start.")
+	a.agentServer = server
 	err := server.Open(true, true, handler, a.extFactory)
 	if err != nil {
 		vlog.Fatalf("start agent fail. port :%d, err: %v\n", a.port, err)
 	}
-	a.agentServer = server
+	if atomic.LoadInt32(&restarting) == 1 {
+		// the process exits after the requests being processed are finished
+		select {}
+	}
 	fmt.Println("Motan agent start fail!")
 }
 
+// startWebSocketAgent lets web clients call the services of agent clusters through websocket
+func (a *Agent) startWebSocketAgent() {
+	if a.wsport == 0 {
+		return
+	}
+	handler := &agentMessageHandler{agent: a}
+	url := &motan.URL{Port: a.wsport, Parameters: make(map[string]string)}
+	if section, _ := a.GetContext().Config.GetSection("motan-agent"); section != nil {
+		if origins, ok := section["ws_allowed_origins"].(string); ok {
+			url.PutParam(mserver.WebSocketOriginsKey, origins)
+		}
+	}
+	server := &mserver.WebSocketServer{URL: url}
+	if err := server.Open(false, true, handler, a.extFactory); err != nil {
+		vlog.Errorf("start websocket agent fail. port :%d, err: %v\n", a.wsport, err)
+		return
+	}
+	a.wsServer = server
+	vlog.Infof("Motan websocket agent is started. port:%d\n", a.wsport)
+}
+
+// startGatewayAgent maps the http requests to the motan calls if the motan-gateway section is configured
+func (a *Agent) startGatewayAgent() {
+	conf := &mserver.GatewayConfig{}
+	if err := a.GetContext().Config.GetStruct("motan-gateway", conf); err != nil || conf.Port == 0 {
+		return
+	}
+	handler := &agentMessageHandler{agent: a}
+	server := &mserver.GatewayServer{URL: &motan.URL{Port: conf.Port}, Config: conf}
+	if err := server.Open(false, true, handler, a.extFactory); err != nil {
+		vlog.Errorf("start gateway agent fail. port :%d, err: %v\n", conf.Port, err)
+		return
+	}
+	a.gatewayServer = server
+	vlog.Infof("Motan gateway agent is started. port:%d\n", conf.Port)
+}
+
 func (a *Agent) registerAgent() {
 	vlog.Infoln("start agent registry.")
 	if reg, exit := a.agentURL.Parameters[motan.RegistryKey]; exit {
-		if registryURL, regexit := a.Context.RegistryURLs[reg]; regexit {
+		if registryURL, regexit := a.GetContext().RegistryURLs[reg]; regexit {
 			registry := a.extFactory.GetRegistry(registryURL)
 			if registry != nil {
 				vlog.Infof("agent register in registry:%s, agent url:%s\n", registry.GetURL().GetIdentity(), a.agentURL.GetIdentity())
@@ -327,7 +505,8 @@ func (a *Agent) registerAgent() {
 }
 
 type agentMessageHandler struct {
-	agent *Agent
+	agent  *Agent
+	tenant *tenant // the tenant of the port, nil for the shared ports
 }
 
 func (a *agentMessageHandler) Call(request motan.Request) (res motan.Response) {
@@ -336,7 +515,38 @@ func (a *agentMessageHandler) Call(request motan.Request) (res motan.Response) {
 		version = request.GetAttachment(mpro.MVersion)
 	}
 	ck := getClusterKey(request.GetAttachment(mpro.MGroup), version, request.GetAttachment(mpro.MProxyProtocol), request.GetAttachment(mpro.MPath))
-	if motanCluster := a.agent.clustermap.LoadOrNil(ck); motanCluster != nil {
+	t := a.tenant
+	if t == nil {
+		t = a.agent.tenants[request.GetAttachment(mpro.MSource)]
+	} else if request.GetAttachment(mpro.MSource) == "" {
+		request.SetAttachment(mpro.MSource, t.name)
+	}
+	var motanCluster interface{}
+	if t != nil {
+		t.metric("total_count")
+		if err := t.acquire(); err != nil {
+			t.metric("reject_count")
+			vlog.Warningf("tenant request rejected. tenant:%s, cluster:%s, err:%v\n", t.name, ck, err)
+			return getDefaultResponse(request.GetRequestID(), err.Error()+". tenant:"+t.name)
+		}
+		defer t.release()
+		motanCluster = a.agent.clustermap.LoadOrNil(t.name + tenantKeySplitter + ck)
+	}
+	if motanCluster == nil {
+		motanCluster = a.agent.clustermap.LoadOrNil(ck)
+	}
+	if subscriber := a.agent.autoSubscriber; subscriber != nil {
+		if motanCluster == nil {
+			c, err := subscriber.getCluster(ck, request.GetAttachment(mpro.MGroup), version, request.GetAttachment(mpro.MProxyProtocol), request.GetAttachment(mpro.MPath))
+			if err != nil {
+				vlog.Warningf("subscribe cluster on demand fail. cluster: %s, err:%v\n", ck, err)
+				return getDefaultResponse(request.GetRequestID(), "subscribe cluster fail. cluster:"+ck+", err:"+err.Error())
+			}
+			motanCluster = c
+		}
+		subscriber.touch(ck)
+	}
+	if motanCluster != nil {
 		motanCluster := motanCluster.(*cluster.MotanCluster)
 		if request.GetAttachment(mpro.MSource) == "" {
 			application := motanCluster.GetURL().GetParam(motan.ApplicationKey, "")
@@ -345,6 +555,9 @@ func (a *agentMessageHandler) Call(request motan.Request) (res motan.Response) {
 			}
 			request.SetAttachment(mpro.MSource, application)
 		}
+		if target := motanCluster.GetURL().GetParam(motan.TranscodeKey, ""); target != "" {
+			return a.transcodeCall(motanCluster, request, target)
+		}
 		res = motanCluster.Call(request)
 		if res == nil {
 			vlog.Warningf("motanCluster Call return nil. cluster:%s\n", ck)
@@ -357,6 +570,47 @@ func (a *agentMessageHandler) Call(request motan.Request) (res motan.Response) {
 	return res
 }
 
+// transcodeCall re-encodes the request by the target serialization configured by the cluster param transcode,
+// and the response back to the serialization of the caller. e.g. requests of simple from dynamic-language clients
+// can be sent to java providers in hessian2
+func (a *agentMessageHandler) transcodeCall(motanCluster *cluster.MotanCluster, request motan.Request, target string) motan.Response {
+	msg, ok := request.GetRPCContext(true).OriginalMessage.(*mpro.Message)
+	if !ok {
+		return motanCluster.Call(request)
+	}
+	extFactory := a.agent.extFactory
+	to := extFactory.GetSerialization(target, -1)
+	if to == nil {
+		vlog.Errorf("transcode serialization not found. serialization:%s, service:%s\n", target, request.GetServiceName())
+		return getDefaultResponse(request.GetRequestID(), "transcode serialization not found: "+target)
+	}
+	from := extFactory.GetSerialization("", msg.Header.GetSerialize())
+	if err := mpro.TranscodeMessage(msg, extFactory, to); err != nil {
+		vlog.Warningf("transcode request fail. req:%s, err:%v\n", motan.GetReqInfo(request), err)
+		return getDefaultResponse(request.GetRequestID(), "transcode request fail. "+err.Error())
+	}
+	if args := request.GetArguments(); len(args) == 1 {
+		if dv, ok := args[0].(*motan.DeserializableValue); ok {
+			dv.Serialization, dv.Body = to, msg.Body
+		}
+	}
+	res := motanCluster.Call(request)
+	if res == nil {
+		return getDefaultResponse(request.GetRequestID(), "motanCluster Call return nil. service:"+request.GetServiceName())
+	}
+	if res.GetException() != nil {
+		return res
+	}
+	if resMsg, ok := res.GetRPCContext(true).OriginalMessage.(*mpro.Message); ok {
+		if err := mpro.TranscodeMessage(resMsg, extFactory, from); err != nil {
+			vlog.Warningf("transcode response fail. req:%s, err:%v\n", motan.GetReqInfo(request), err)
+			return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500,
+				ErrMsg: "transcode response fail. " + err.Error(), ErrType: motan.ServiceException})
+		}
+	}
+	return res
+}
+
 func (a *agentMessageHandler) AddProvider(p motan.Provider) error {
 	return nil
 }
@@ -368,10 +622,17 @@ func (a *agentMessageHandler) GetProvider(serviceName string) motan.Provider {
 }
 
 func (a *Agent) startServerAgent() {
-	globalContext := a.Context
+	globalContext := a.GetContext()
 	for _, url := range globalContext.ServiceURLs {
-		a.initProxyURL(url)
-		a.doExportService(url)
+		urls, err := motan.ExportURLs(url)
+		if err != nil {
+			vlog.Errorf("service export fail! url:%v, err:%v\n", url, err)
+			continue
+		}
+		for _, u := range urls {
+			a.initProxyURL(u)
+			a.doExportService(u)
+		}
 	}
 }
 
@@ -379,7 +640,7 @@ func (a *Agent) doExportService(url *motan.URL) {
 	a.svcLock.Lock()
 	defer a.svcLock.Unlock()
 
-	globalContext := a.Context
+	globalContext := a.GetContext()
 	exporter := &mserver.DefaultExporter{}
 	provider := a.extFactory.GetProvider(url)
 	if provider == nil {
@@ -426,10 +687,12 @@ func (a *Agent) doExportService(url *motan.URL) {
 
 type serverAgentMessageHandler struct {
 	providers *motan.CopyOnWriteMap
+	exposures *motan.CopyOnWriteMap // only the services with exposure config
 }
 
 func (sa *serverAgentMessageHandler) Initialize() {
 	sa.providers = motan.NewCopyOnWriteMap()
+	sa.exposures = motan.NewCopyOnWriteMap()
 }
 
 func (sa *serverAgentMessageHandler) Call(request motan.Request) (res motan.Response) {
@@ -439,24 +702,36 @@ func (sa *serverAgentMessageHandler) Call(request motan.Request) (res motan.Resp
 	})
 	if p := sa.providers.LoadOrNil(request.GetServiceName()); p != nil {
 		p := p.(motan.Provider)
+		if e := sa.exposures.LoadOrNil(request.GetServiceName()); e != nil && !e.(*mserver.MethodExposure).IsExposed(request.GetMethod()) {
+			return mserver.MethodNotExported(request)
+		}
 		res = p.Call(request)
-		res.GetRPCContext(true).GzipSize = int(p.GetURL().GetIntValue(motan.GzipSizeKey, 0))
+		mserver.SetResponseCompression(p.GetURL(), request, res)
 		return res
 	}
 	vlog.Errorf("not found provider for %s\n", motan.GetReqInfo(request))
-	return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "not found provider for " + request.GetServiceName(), ErrType: motan.ServiceException})
+	return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewException(motan.ErrCodeNotFound, "not found provider for "+request.GetServiceName()))
 }
 
 func (sa *serverAgentMessageHandler) AddProvider(p motan.Provider) error {
 	sa.providers.Store(p.GetPath(), p)
+	if e := mserver.NewMethodExposure(p.GetURL()); e != nil {
+		sa.exposures.Store(p.GetPath(), e)
+	} else {
+		sa.exposures.Delete(p.GetPath())
+	}
 	return nil
 }
 
 func (sa *serverAgentMessageHandler) RmProvider(p motan.Provider) {
 	sa.providers.Delete(p.GetPath())
+	sa.exposures.Delete(p.GetPath())
 }
 
 func (sa *serverAgentMessageHandler) GetProvider(serviceName string) motan.Provider {
+	if p := sa.providers.LoadOrNil(serviceName); p != nil {
+		return p.(motan.Provider)
+	}
 	return nil
 }
 
@@ -475,7 +750,14 @@ func registerSwitchers(c *motan.Context) {
 	switchers, _ := c.Config.GetSection(motan.SwitcherSection)
 	s := motan.GetSwitcherManager()
 	for n, v := range switchers {
-		s.Register(n.(string), v.(bool))
+		switch v := v.(type) {
+		case bool:
+			s.Register(n.(string), v)
+		case int:
+			s.RegisterInt(n.(string), int64(v))
+		default:
+			vlog.Warningf("switcher value must be bool or int. name:%v, value:%v\n", n, v)
+		}
 	}
 }
 
@@ -510,6 +792,31 @@ func (a *AgentListener) GetIdentity() string {
 	return a.agent.agentURL.GetIdentity()
 }
 
+// HotRestart starts a new agent process inheriting the listeners, and this process exits after the requests being processed
+// are finished. the pid of the new process is returned
+func (a *Agent) HotRestart() (int, error) {
+	return hotRestart(func() []motan.Server {
+		if a.mListener != nil {
+			a.mListener.Close()
+		}
+		servers := make([]motan.Server, 0, len(a.agentPortServer)+2)
+		if a.agentServer != nil {
+			servers = append(servers, a.agentServer)
+		}
+		if a.wsServer != nil {
+			servers = append(servers, a.wsServer)
+		}
+		if a.gatewayServer != nil {
+			servers = append(servers, a.gatewayServer)
+		}
+		for _, s := range a.agentPortServer {
+			servers = append(servers, s)
+		}
+		servers = append(servers, a.tenantServers...)
+		return servers
+	})
+}
+
 func (a *Agent) RegisterManageHandler(path string, handler http.Handler) {
 	if path != "" && handler != nil {
 		a.manageHandlers[path] = handler // override
@@ -517,6 +824,22 @@ func (a *Agent) RegisterManageHandler(path string, handler http.Handler) {
 }
 
 func (a *Agent) startMServer() {
+	a.registerManageHandlers(http.DefaultServeMux)
+
+	vlog.Infof("start listen manage port %d ...\n", a.mport)
+	lis, err := transport.ListenInherited("tcp", ":"+strconv.Itoa(a.mport))
+	if err == nil {
+		a.mListener = lis
+		err = http.Serve(lis, nil)
+	}
+	if err != nil && atomic.LoadInt32(&restarting) == 0 {
+		fmt.Printf("start listen manage port fail! port:%d, err:%s\n", a.mport, err.Error())
+		vlog.Warningf("start listen manage port fail! port:%d, err:%s\n", a.mport, err.Error())
+	}
+}
+
+// registerManageHandlers registers the default manage handlers and the handlers of RegisterManageHandler to the mux
+func (a *Agent) registerManageHandlers(mux *http.ServeMux) {
 	handlers := make(map[string]http.Handler, 16)
 	for k, v := range GetDefaultManageHandlers() {
 		handlers[k] = v
@@ -525,18 +848,11 @@ func (a *Agent) startMServer() {
 		handlers[k] = v
 	}
 	for k, v := range handlers {
-		a.mhandle(k, v)
-	}
-
-	vlog.Infof("start listen manage port %d ...\n", a.mport)
-	err := http.ListenAndServe(":"+strconv.Itoa(a.mport), nil)
-	if err != nil {
-		fmt.Printf("start listen manage port fail! port:%d, err:%s\n", a.mport, err.Error())
-		vlog.Warningf("start listen manage port fail! port:%d, err:%s\n", a.mport, err.Error())
+		a.mhandle(mux, k, v)
 	}
 }
 
-func (a *Agent) mhandle(k string, h http.Handler) {
+func (a *Agent) mhandle(mux *http.ServeMux, k string, h http.Handler) {
 	defer func() {
 		if err := recover(); err != nil {
 			vlog.Warningf("manageHandler register fail. maybe the pattern '%s' already registered\n", k)
@@ -545,11 +861,16 @@ func (a *Agent) mhandle(k string, h http.Handler) {
 	if sa, ok := h.(SetAgent); ok {
 		sa.SetAgent(a)
 	}
-	http.HandleFunc(k, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(k, func(w http.ResponseWriter, r *http.Request) {
 		if !PermissionCheck(r) {
 			w.Write([]byte("need permission!"))
 			return
 		}
+		if adminManagePaths[k] && !a.adminAuthorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("invalid admin token"))
+			return
+		}
 		defer func() {
 			if err := recover(); err != nil {
 				fmt.Fprintf(w, "process request err: %s\n", err)
@@ -561,7 +882,7 @@ func (a *Agent) mhandle(k string, h http.Handler) {
 }
 
 func (a *Agent) getConfigData() []byte {
-	data, err := yaml.Marshal(a.Context.Config.GetOriginMap())
+	data, err := yaml.Marshal(a.GetContext().Config.GetOriginMap())
 	if err != nil {
 		return []byte(err.Error())
 	}
@@ -578,7 +899,7 @@ func urlExist(url *motan.URL, urls map[string]*motan.URL) bool {
 }
 
 func (a *Agent) SubscribeService(url *motan.URL) error {
-	if urlExist(url, a.Context.RefersURLs) {
+	if urlExist(url, a.GetContext().RefersURLs) {
 		return nil
 	}
 	a.initCluster(url)
@@ -586,7 +907,7 @@ func (a *Agent) SubscribeService(url *motan.URL) error {
 }
 
 func (a *Agent) ExportService(url *motan.URL) error {
-	if urlExist(url, a.Context.ServiceURLs) {
+	if urlExist(url, a.GetContext().ServiceURLs) {
 		return nil
 	}
 	a.doExportService(url)
@@ -594,7 +915,7 @@ func (a *Agent) ExportService(url *motan.URL) error {
 }
 
 func (a *Agent) UnexportService(url *motan.URL) error {
-	if urlExist(url, a.Context.ServiceURLs) {
+	if urlExist(url, a.GetContext().ServiceURLs) {
 		return nil
 	}
 
@@ -0,0 +1,166 @@
+package motan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// the keys of motan-agent section for subscribing the services not in the config on demand
+const (
+	autoSubscribeBasicReferKey  = "auto_subscribe_basic_refer"  // the basic refer of the clusters, includes the registry
+	autoSubscribeMaxClustersKey = "auto_subscribe_max_clusters" // max clusters subscribed on demand
+	autoSubscribeIdleTimeoutKey = "auto_subscribe_idle_timeout" // seconds, the clusters not called in the time are destroyed
+
+	defaultAutoSubscribeMaxClusters = 200
+	defaultAutoSubscribeIdleTimeout = 600 * time.Second
+	maxAutoSubscribeNameLength      = 256
+)
+
+var (
+	errTooManyAutoClusters = errors.New("too many clusters subscribed on demand")
+	errInvalidAutoCluster  = errors.New("invalid path or group for subscribing on demand")
+)
+
+// autoSubscriber creates the clusters for the requests of the services not in the config, by the basic refer.
+// the clusters are destroyed if they are not called in the idle timeout
+type autoSubscriber struct {
+	agent       *Agent
+	basicURL    *motan.URL
+	maxClusters int
+	idleTimeout time.Duration
+	lock        sync.Mutex
+	clusters    sync.Map // cluster key -> *autoCluster
+	count       int
+}
+
+type autoCluster struct {
+	cluster  *cluster.MotanCluster // set before ready is closed
+	ready    chan struct{}
+	lastCall int64 // unix nanoseconds
+}
+
+// newAutoSubscriber returns nil if the basic refer is not configured
+func newAutoSubscriber(a *Agent, section map[interface{}]interface{}) (*autoSubscriber, error) {
+	if section == nil || section[autoSubscribeBasicReferKey] == nil {
+		return nil, nil
+	}
+	name := motan.InterfaceToString(section[autoSubscribeBasicReferKey])
+	basicURL, ok := a.GetContext().BasicReferURLs[name]
+	if !ok {
+		return nil, fmt.Errorf("basic refer %s for auto subscribing not found", name)
+	}
+	s := &autoSubscriber{agent: a, basicURL: basicURL, maxClusters: defaultAutoSubscribeMaxClusters, idleTimeout: defaultAutoSubscribeIdleTimeout}
+	if n, ok := section[autoSubscribeMaxClustersKey].(int); ok && n > 0 {
+		s.maxClusters = n
+	}
+	if n, ok := section[autoSubscribeIdleTimeoutKey].(int); ok && n > 0 {
+		s.idleTimeout = time.Duration(n) * time.Second
+	}
+	go s.evictIdle()
+	vlog.Infof("agent subscribes services on demand. basic refer:%s, max clusters:%d, idle timeout:%v\n", name, s.maxClusters, s.idleTimeout)
+	return s, nil
+}
+
+// getCluster returns the cluster of the key, it is created and subscribed if not exist.
+// the clusters are created outside the lock, the concurrent requests of the same key wait for the creation
+func (s *autoSubscriber) getCluster(ck string, group string, version string, protocol string, path string) (*cluster.MotanCluster, error) {
+	if c := s.agent.clustermap.LoadOrNil(ck); c != nil {
+		return c.(*cluster.MotanCluster), nil
+	}
+	if !validAutoSubscribeName(path) || !validAutoSubscribeName(group) {
+		return nil, errInvalidAutoCluster
+	}
+	s.lock.Lock()
+	if v, ok := s.clusters.Load(ck); ok {
+		s.lock.Unlock()
+		ac := v.(*autoCluster)
+		<-ac.ready
+		return ac.cluster, nil
+	}
+	if s.count >= s.maxClusters {
+		s.lock.Unlock()
+		return nil, errTooManyAutoClusters
+	}
+	ac := &autoCluster{ready: make(chan struct{}), lastCall: time.Now().UnixNano()}
+	s.clusters.Store(ck, ac)
+	s.count++
+	s.lock.Unlock()
+
+	url := s.basicURL.Copy()
+	url.Group, url.Path = group, path
+	if protocol != "" {
+		url.Protocol = protocol
+	}
+	url.PutParam(motan.VersionKey, version)
+	if url.GetParam(motan.ApplicationKey, "") == "" {
+		url.PutParam(motan.ApplicationKey, s.agent.agentURL.GetParam(motan.ApplicationKey, ""))
+	}
+	ac.cluster = cluster.NewCluster(s.agent.GetContext(), s.agent.extFactory, url, true)
+	s.agent.clustermap.Store(ck, ac.cluster)
+	close(ac.ready)
+	vlog.Infof("agent subscribes service on demand. cluster:%s\n", ck)
+	return ac.cluster, nil
+}
+
+// validAutoSubscribeName checks the path or the group from the request, which must not be empty or contain
+// characters other than letters, digits and ._-/:$
+func validAutoSubscribeName(name string) bool {
+	if name == "" || len(name) > maxAutoSubscribeNameLength {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("._-/:$", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// touch records the call of the cluster subscribed on demand
+func (s *autoSubscriber) touch(ck string) {
+	if c, ok := s.clusters.Load(ck); ok {
+		atomic.StoreInt64(&c.(*autoCluster).lastCall, time.Now().UnixNano())
+	}
+}
+
+func (s *autoSubscriber) evictIdle() {
+	ticker := time.NewTicker(s.idleTimeout / 10)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.doEvict(time.Now().Add(-s.idleTimeout).UnixNano())
+	}
+}
+
+func (s *autoSubscriber) doEvict(before int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.clusters.Range(func(k, v interface{}) bool {
+		c := v.(*autoCluster)
+		select {
+		case <-c.ready:
+		default:
+			// still being created
+			return true
+		}
+		if atomic.LoadInt64(&c.lastCall) > before {
+			return true
+		}
+		s.clusters.Delete(k)
+		s.count--
+		// the cluster may be replaced by the config
+		if s.agent.clustermap.LoadOrNil(k) == c.cluster {
+			s.agent.clustermap.Delete(k)
+		}
+		c.cluster.Destroy()
+		vlog.Infof("agent destroys the idle cluster subscribed on demand. cluster:%s\n", k)
+		return true
+	})
+}
@@ -1,13 +1,18 @@
 package motan
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/weibocom/motan-go/cluster"
 	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
+	"github.com/weibocom/motan-go/metrics"
 	mpro "github.com/weibocom/motan-go/protocol"
 )
 
@@ -21,6 +26,8 @@ type MCContext struct {
 	context    *motan.Context
 	extFactory motan.ExtensionFactory
 	clients    map[string]*Client
+	// clients created by Invoke for the services without refers
+	genericClients map[string]*Client
 
 	csync  sync.Mutex
 	inited bool
@@ -30,8 +37,52 @@ type Client struct {
 	url        *motan.URL
 	cluster    *cluster.MotanCluster
 	extFactory motan.ExtensionFactory
+
+	// buffered oneway requests
+	onewayCh   chan motan.Request
+	onewayOnce sync.Once
+
+	// async calls not finished
+	pending     *motan.PendingCalls
+	pendingOnce sync.Once
+
+	interceptors     atomic.Value // []Interceptor
+	interceptorsLock sync.Mutex
+
+	hedgingPolicies sync.Map // method -> HedgingPolicy
 }
 
+// Interceptor is the hooks of the calls of a client, for the applications injecting the auth tokens or recording
+// the custom metrics without the filter extensions
+type Interceptor struct {
+	// BeforeCall is called before the request is sent, the call fails without sent if it returns an error
+	BeforeCall func(request motan.Request) error
+	// AfterCall is called after the call finished, including the calls failed by BeforeCall.
+	// the response of an async call has the reply as the value and the error as the exception
+	AfterCall func(request motan.Request, response motan.Response)
+}
+
+const (
+	onewayBufferSizeKey     = "onewayBufferSize"
+	defaultOnewayBufferSize = 1024
+
+	// the async calls are rejected if the pending calls reach the max, and are finished with ErrAsyncLeaked
+	// if they are not finished in the leak timeout
+	asyncMaxPendingKey      = "asyncMaxPending"
+	asyncLeakTimeoutKey     = "asyncLeakTimeout"
+	defaultAsyncMaxPending  = 10000
+	defaultAsyncLeakTimeout = 60 * time.Second
+
+	// method name of the request carrying the sub requests of a batch call
+	batchMethod = "$batch"
+
+	// key of motan-client section, the basic refer used by Invoke to call the services without refers
+	genericBasicReferKey = "generic_basic_refer"
+)
+
+// ErrOnewayBufferFull is returned when a oneway request is dropped
+var ErrOnewayBufferFull = errors.New("oneway buffer is full, request dropped")
+
 func (c *Client) Call(method string, args []interface{}, reply interface{}) error {
 	req := c.BuildRequest(method, args)
 	return c.BaseCall(req, reply)
@@ -41,9 +92,24 @@ func (c *Client) BaseCall(req motan.Request, reply interface{}) error {
 	rc := req.GetRPCContext(true)
 	rc.ExtFactory = c.extFactory
 	rc.Reply = reply
-	res := c.cluster.Call(req)
+	res := c.invoke(req)
+	defer releaseResponse(res)
 	if res.GetException() != nil {
-		return errors.New(res.GetException().ErrMsg)
+		return exceptionError(res.GetException())
+	}
+	return nil
+}
+
+// CallContext calls like Call, the call is aborted when ctx is done, and the deadline of ctx is propagated to the provider
+func (c *Client) CallContext(ctx context.Context, method string, args []interface{}, reply interface{}) error {
+	req := c.BuildRequest(method, args)
+	rc := req.GetRPCContext(true)
+	rc.ExtFactory = c.extFactory
+	rc.Reply = reply
+	res := motan.CallContext(ctx, interceptedCluster{MotanCluster: c.cluster, client: c}, req)
+	defer releaseResponse(res)
+	if res.GetException() != nil {
+		return exceptionError(res.GetException())
 	}
 	return nil
 }
@@ -64,16 +130,288 @@ func (c *Client) BaseGo(req motan.Request, reply interface{}, done chan *motan.A
 	rc.Result = result
 	rc.AsyncCall = true
 	rc.Result.Reply = reply
-	res := c.cluster.Call(req)
+	if err := c.pendingCalls().Add(result); err != nil {
+		result.Finish(err)
+		return result
+	}
+	res := c.invoke(req)
 	if res.GetException() != nil {
-		result.Error = errors.New(res.GetException().ErrMsg)
-		result.Done <- result
+		result.Finish(exceptionError(res.GetException()))
+	}
+	return result
+}
+
+// GoContext calls like Go, the call is canceled with the error of ctx when ctx is done before the call finished,
+// and the deadline of ctx is propagated to the provider
+func (c *Client) GoContext(ctx context.Context, method string, args []interface{}, reply interface{}, done chan *motan.AsyncResult) *motan.AsyncResult {
+	req := c.BuildRequest(method, args)
+	rc := req.GetRPCContext(true)
+	rc.Context = ctx
+	motan.InjectBaggage(ctx, req)
+	if deadline, ok := ctx.Deadline(); ok {
+		rc.Deadline = deadline
+	}
+	result := c.BaseGo(req, reply, done)
+	result.BindContext(ctx)
+	return result
+}
+
+// OnControl adds the listener of the control messages pushed by the providers of the client, the returned func
+// removes the listener
+func (c *Client) OnControl(listener motan.ControlListener) (remove func()) {
+	return endpoint.AddControlListener(func(msg *motan.ControlMessage) {
+		for _, ep := range c.cluster.GetRefers() {
+			if ep.GetURL().GetAddressStr() == msg.Address {
+				listener(msg)
+				return
+			}
+		}
+	})
+}
+
+// PendingAsyncCalls returns the number of the async calls not finished
+func (c *Client) PendingAsyncCalls() int {
+	return c.pendingCalls().Len()
+}
+
+// EndpointReadiness is the result of warming up an endpoint of the client
+type EndpointReadiness struct {
+	Address string        `json:"address"`
+	Ready   bool          `json:"ready"`
+	RTT     time.Duration `json:"rtt"` // the longest heartbeat round trip time of the connections
+	Error   string        `json:"error,omitempty"`
+}
+
+type pinger interface {
+	Ping(timeout time.Duration) (time.Duration, error)
+}
+
+// WarmUp sends the heartbeats on all connections of the endpoints to the providers in parallel, the connections
+// are established when the endpoints are created, so the first calls will not pay for dialing. the endpoints
+// not supporting heartbeats such as http are ready if available
+func (c *Client) WarmUp(timeout time.Duration) []EndpointReadiness {
+	refers := c.cluster.GetRefers()
+	result := make([]EndpointReadiness, len(refers))
+	var wg sync.WaitGroup
+	for i, ep := range refers {
+		result[i].Address = ep.GetURL().GetAddressStr()
+		caller := motan.Caller(ep)
+		if fep, ok := ep.(*motan.FilterEndPoint); ok {
+			caller = fep.Caller
+		}
+		p, ok := caller.(pinger)
+		if !ok {
+			result[i].Ready = ep.IsAvailable()
+			continue
+		}
+		wg.Add(1)
+		go func(r *EndpointReadiness) {
+			defer motan.HandlePanic(nil)
+			defer wg.Done()
+			rtt, err := p.Ping(timeout)
+			if err != nil {
+				r.Error = err.Error()
+				return
+			}
+			r.Ready, r.RTT = true, rtt
+		}(&result[i])
 	}
+	wg.Wait()
 	return result
 }
 
+func (c *Client) pendingCalls() *motan.PendingCalls {
+	c.pendingOnce.Do(func() {
+		leakTimeout := c.url.GetTimeDuration(asyncLeakTimeoutKey, time.Millisecond, defaultAsyncLeakTimeout)
+		if leakTimeout <= 0 {
+			leakTimeout = defaultAsyncLeakTimeout
+		}
+		c.pending = motan.NewPendingCalls(int(c.url.GetPositiveIntValue(asyncMaxPendingKey, defaultAsyncMaxPending)), leakTimeout)
+		go func() {
+			ticker := time.NewTicker(leakTimeout / 2)
+			defer ticker.Stop()
+			for range ticker.C {
+				c.pending.CheckLeaks()
+			}
+		}()
+	})
+	return c.pending
+}
+
+// Stream starts a streaming call. args are sent with the call, then more messages can be sent and received by the returned Stream
+func (c *Client) Stream(method string, args []interface{}) (motan.Stream, error) {
+	req := c.BuildRequest(method, args)
+	rc := req.GetRPCContext(true)
+	rc.ExtFactory = c.extFactory
+	rc.StreamCall = true
+	res := c.invoke(req)
+	if res.GetException() != nil {
+		return nil, exceptionError(res.GetException())
+	}
+	if stream, ok := res.GetValue().(motan.Stream); ok {
+		return stream, nil
+	}
+	return nil, errors.New("streaming call is not supported by endpoint")
+}
+
+// Oneway sends a request without waiting for any response, for telemetry-style methods.
+// the request is buffered and sent asynchronously, it will be dropped if the buffer is full.
+func (c *Client) Oneway(method string, args []interface{}) error {
+	c.onewayOnce.Do(func() {
+		c.onewayCh = make(chan motan.Request, c.url.GetPositiveIntValue(onewayBufferSizeKey, defaultOnewayBufferSize))
+		go c.sendOneway()
+	})
+	req := c.BuildRequest(method, args)
+	rc := req.GetRPCContext(true)
+	rc.ExtFactory = c.extFactory
+	rc.Oneway = true
+	select {
+	case c.onewayCh <- req:
+		return nil
+	default:
+		metrics.AddCounter(c.url.Group, c.url.Path, c.onewayMetricKey(method)+".drop_count", 1)
+		return ErrOnewayBufferFull
+	}
+}
+
+func (c *Client) sendOneway() {
+	for req := range c.onewayCh {
+		res := c.invoke(req)
+		if res.GetException() != nil {
+			metrics.AddCounter(c.url.Group, c.url.Path, c.onewayMetricKey(req.GetMethod())+".fail_count", 1)
+		}
+	}
+}
+
+func (c *Client) onewayMetricKey(method string) string {
+	return "motan-client-oneway:" + c.url.GetParam(motan.ApplicationKey, "") + ":" + method
+}
+
+// BatchItem is a call of BatchCall, Reply and Error are set after BatchCall returns
+type BatchItem struct {
+	Method string
+	Args   []interface{}
+	Reply  interface{}
+	Error  error
+}
+
+// BatchCall sends all items to the same provider in one message and waits for all responses.
+// the returned error is only for the whole batch, the error of each call is set to the Error of its item.
+func (c *Client) BatchCall(items []*BatchItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	requests := make([]motan.Request, 0, len(items))
+	for _, item := range items {
+		req := c.BuildRequest(item.Method, item.Args)
+		rc := req.GetRPCContext(true)
+		rc.ExtFactory = c.extFactory
+		rc.Reply = item.Reply
+		requests = append(requests, req)
+	}
+	req := c.BuildRequest(batchMethod, nil)
+	rc := req.GetRPCContext(true)
+	rc.ExtFactory = c.extFactory
+	rc.BatchRequests = requests
+	res := c.invoke(req)
+	if res.GetException() != nil {
+		return exceptionError(res.GetException())
+	}
+	responses, ok := res.GetValue().([]motan.Response)
+	if !ok || len(responses) != len(items) {
+		return errors.New("batch call is not supported by endpoint")
+	}
+	for i, response := range responses {
+		if response.GetException() != nil {
+			items[i].Error = exceptionError(response.GetException())
+		}
+	}
+	return nil
+}
+
+// AddInterceptor adds the hooks of the calls, BeforeCall is called in the order added and AfterCall in the reverse order
+func (c *Client) AddInterceptor(interceptor Interceptor) {
+	c.interceptorsLock.Lock()
+	defer c.interceptorsLock.Unlock()
+	old, _ := c.interceptors.Load().([]Interceptor)
+	interceptors := make([]Interceptor, 0, len(old)+1)
+	interceptors = append(interceptors, old...)
+	c.interceptors.Store(append(interceptors, interceptor))
+}
+
+// exceptionError returns the error of the exception, the correlation id is appended for finding the logs of the call
+func exceptionError(e *motan.Exception) error {
+	if e.CorrelationID == "" {
+		return errors.New(e.ErrMsg)
+	}
+	return errors.New(e.ErrMsg + " (correlation id: " + e.CorrelationID + ")")
+}
+
+// releaseResponse releases the pooled response of a sync call and its attachments decoded from the response message
+func releaseResponse(res motan.Response) {
+	motan.ReleaseStringMap(res.GetAttachments())
+	motan.ReleaseMotanResponse(res)
+}
+
+// interceptedCluster is the cluster calling with the interceptors of the client
+type interceptedCluster struct {
+	*cluster.MotanCluster
+	client *Client
+}
+
+func (i interceptedCluster) Call(request motan.Request) motan.Response {
+	return i.client.invoke(request)
+}
+
+// invoke calls the cluster with the interceptors
+func (c *Client) invoke(req motan.Request) motan.Response {
+	cid := motan.EnsureCorrelationID(req.GetRPCContext(true).Context, req)
+	res := c.intercept(req)
+	motan.SetExceptionCorrelationID(res, cid)
+	return res
+}
+
+func (c *Client) intercept(req motan.Request) motan.Response {
+	interceptors, _ := c.interceptors.Load().([]Interceptor)
+	if len(interceptors) == 0 {
+		return c.call(req)
+	}
+	var res motan.Response
+	for _, i := range interceptors {
+		if i.BeforeCall == nil {
+			continue
+		}
+		if err := i.BeforeCall(req); err != nil {
+			res = motan.BuildExceptionResponse(req.GetRequestID(), motan.NewException(motan.ErrCodeBadRequest, err.Error()))
+			break
+		}
+	}
+	if res == nil {
+		res = c.call(req)
+	}
+	afterCall := func(res motan.Response) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			if interceptors[i].AfterCall != nil {
+				interceptors[i].AfterCall(req, res)
+			}
+		}
+	}
+	if rc := req.GetRPCContext(false); rc != nil && rc.AsyncCall && rc.Result != nil && res.GetException() == nil {
+		rc.Result.Then(func(result *motan.AsyncResult) {
+			res := &motan.MotanResponse{RequestID: req.GetRequestID(), Value: result.Reply}
+			if result.Error != nil {
+				res.Exception = motan.NewException(motan.ErrCodeBadRequest, result.Error.Error())
+			}
+			afterCall(res)
+		})
+		return res
+	}
+	afterCall(res)
+	return res
+}
+
 func (c *Client) BuildRequest(method string, args []interface{}) motan.Request {
-	req := &motan.MotanRequest{Method: method, ServiceName: c.url.Path, Arguments: args, Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
+	req := &motan.MotanRequest{RequestID: motan.NewRequestID(), Method: method, ServiceName: c.url.Path, Arguments: args, Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
 	version := c.url.GetParam(motan.VersionKey, "")
 	req.SetAttachment(mpro.MVersion, version)
 	module := c.url.GetParam(motan.ModuleKey, "")
@@ -111,7 +449,12 @@ func GetClientContext(confFile string) *MCContext {
 			logdir = "."
 		}
 		initLog(logdir)
+		initAccessLog(section, logdir)
 		registerSwitchers(mc.context)
+		initDeserializeLimits(section)
+		initBaggage(section)
+		initRequestID(section)
+		initObjectPool(section)
 	}
 	return mc
 }
@@ -124,6 +467,7 @@ func (m *MCContext) Initialize() {
 		m.context.Initialize()
 
 		m.clients = make(map[string]*Client, 32)
+		m.genericClients = make(map[string]*Client, 16)
 		m.inited = true
 	}
 }
@@ -135,6 +479,8 @@ func (m *MCContext) Start(extfactory motan.ExtensionFactory) {
 	if m.extFactory == nil {
 		m.extFactory = GetDefaultExtFactory()
 	}
+	dryRunConfig(m.context, m.extFactory)
+	metrics.AddSinks(m.context, m.extFactory)
 
 	for key, url := range m.context.RefersURLs {
 		c := cluster.NewCluster(m.context, m.extFactory, url, false)
@@ -146,6 +492,63 @@ func (m *MCContext) GetClient(clientid string) *Client {
 	return m.clients[clientid]
 }
 
+// Invoke calls the method of any service by name, for gateways and tools without the interfaces of the services.
+// args can be maps of the fields for the struct parameters, and reply can be a pointer of interface{} or map to
+// receive the result without its type. the client of the refer with the service path is used if exists,
+// otherwise a client is created from the basic refer set by generic_basic_refer of the motan-client section
+func (m *MCContext) Invoke(service string, method string, args []interface{}, reply interface{}) error {
+	c, err := m.getGenericClient(service)
+	if err != nil {
+		return err
+	}
+	return c.Call(method, args, reply)
+}
+
+func (m *MCContext) getGenericClient(service string) (*Client, error) {
+	m.csync.Lock()
+	defer m.csync.Unlock()
+	if m.extFactory == nil {
+		return nil, errors.New("motan client context is not started")
+	}
+	for _, c := range m.clients {
+		if c.url.Path == service {
+			return c, nil
+		}
+	}
+	if c, ok := m.genericClients[service]; ok {
+		return c, nil
+	}
+	basicURL, err := m.genericBasicRefer()
+	if err != nil {
+		return nil, err
+	}
+	url := basicURL.Copy()
+	url.Path = service
+	c := &Client{url: url, cluster: cluster.NewCluster(m.context, m.extFactory, url, false), extFactory: m.extFactory}
+	m.genericClients[service] = c
+	return c, nil
+}
+
+// genericBasicRefer returns the basic refer set by generic_basic_refer, or the only basic refer if not set
+func (m *MCContext) genericBasicRefer() (*motan.URL, error) {
+	name := ""
+	if section, _ := m.context.Config.GetSection("motan-client"); section != nil {
+		name, _ = section[genericBasicReferKey].(string)
+	}
+	if name == "" {
+		if len(m.context.BasicReferURLs) != 1 {
+			return nil, errors.New("generic_basic_refer of motan-client is not set for the services without refers")
+		}
+		for _, url := range m.context.BasicReferURLs {
+			return url, nil
+		}
+	}
+	if url, ok := m.context.BasicReferURLs[name]; ok {
+		return url, nil
+	}
+	return nil, fmt.Errorf("basic refer %s for generic call not found", name)
+}
+
 func (m *MCContext) GetRefer(service string) interface{} {
 	// TODO 对client的封装，可以根据idl自动生成代码时支持
 	return nil
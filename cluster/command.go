@@ -3,6 +3,7 @@ package cluster
 import (
 	"bytes"
 	"encoding/json"
+	"hash/fnv"
 	"regexp"
 	"sort"
 	"strconv"
@@ -17,6 +18,7 @@ const (
 	CMDTrafficControl = iota
 	CMDDegrade        //service degrade
 	CMDSwitcher
+	CMDTrafficSwitch // switch the traffic of a percent of the agents to another group
 )
 
 const (
@@ -54,6 +56,10 @@ type ClientCommand struct {
 	MergeGroups []string `json:"mergeGroups"`
 	RouteRules  []string `json:"routeRules"`
 	Remark      string   `json:"remark"`
+	// the traffic switch command, the agents in the percent use the group ToGroup instead of FromGroup
+	FromGroup string `json:"fromGroup,omitempty"` // any group if empty
+	ToGroup   string `json:"toGroup,omitempty"`
+	Percent   int    `json:"percent,omitempty"`
 }
 
 type Command struct {
@@ -122,6 +128,21 @@ func (c *ClientCommand) MatchCmdPattern(url *motan.URL) bool {
 	return false
 }
 
+// isSwitched returns true if the agent is in the percent of the traffic switch command. the agents are hashed into
+// 100 buckets by the local ip and the service, so an agent keeps switched while the percent increases
+func (c *ClientCommand) isSwitched(url *motan.URL) bool {
+	if c.ToGroup == "" || c.ToGroup == url.Group || (c.FromGroup != "" && c.FromGroup != url.Group) {
+		return false
+	}
+	return trafficSwitchBucket(motan.GetLocalIP(), url.Path) < c.Percent
+}
+
+func trafficSwitchBucket(localIP string, path string) int {
+	h := fnv.New32a()
+	h.Write([]byte(localIP + "|" + path))
+	return int(h.Sum32() % 100)
+}
+
 func ParseCommand(commandInfo string) *Command {
 	command := new(Command)
 	if err := json.Unmarshal([]byte(commandInfo), command); err != nil {
@@ -444,16 +465,30 @@ func mergeCommand(commandInfo string, url *motan.URL) (tcCommand *ClientCommand,
 	} else {
 		var cmdList CmdList = cmd.ClientCommandList
 		sort.Sort(cmdList)
+		trafficDecided := false // the traffic switch command takes the place of the traffic control command
 		for _, c := range cmdList {
 			if c.MatchCmdPattern(url) {
 				switch c.CommandType {
 				case CMDTrafficControl:
-					if tcCommand == nil {
+					if !trafficDecided {
+						trafficDecided = true
 						temp := c
 						tcCommand = &temp
 					} else {
 						vlog.Warningf("traffic control command will ignore by priority. command : %v", c)
 					}
+				case CMDTrafficSwitch:
+					if trafficDecided {
+						vlog.Warningf("traffic switch command will ignore by priority. command : %v", c)
+						break
+					}
+					trafficDecided = true
+					if c.isSwitched(url) {
+						vlog.Infof("%s is switched to group %s by traffic switch command, percent:%d\n", url.GetIdentity(), c.ToGroup, c.Percent)
+						temp := c
+						temp.MergeGroups = []string{c.ToGroup}
+						tcCommand = &temp
+					}
 				case CMDDegrade:
 					temp := c
 					degradeCommand = &temp
@@ -495,6 +530,13 @@ func (c *CommandRegistryWrapper) Notify(registryURL *motan.URL, urls []*motan.UR
 	}
 }
 
+// GetCommands returns the effective traffic control, degrade and switcher commands, nil if not exist
+func (c *CommandRegistryWrapper) GetCommands() (tc *ClientCommand, degrade *ClientCommand, switcher *ClientCommand) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.tcCommand, c.degradeCommand, c.switcherCommand
+}
+
 func (c *CommandRegistryWrapper) SetURL(url *motan.URL) {
 	c.registry.SetURL(url)
 }
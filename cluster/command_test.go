@@ -186,6 +186,55 @@ func TestProcessCommand(t *testing.T) {
 	fmt.Printf("notify:%t, crw:%+v\n", notify, crw)
 }
 
+func TestTrafficSwitchCommand(t *testing.T) {
+	buildSwitchCmd := func(from string, percent int) string {
+		return buildCmdList([]string{`{"commandType":` + strconv.Itoa(CMDTrafficSwitch) + `, "index": 1, "pattern": "*", "fromGroup": "` + from + `", "toGroup": "group1", "percent": ` + strconv.Itoa(percent) + `}`})
+	}
+	url := &motan.URL{Path: "com.weibo.test.TestService", Group: "group0"}
+	switched := func(percent int) map[string]bool {
+		result := make(map[string]bool)
+		for i := 0; i < 100; i++ {
+			*motan.LocalIP = "10.73.1." + strconv.Itoa(i)
+			if tc, _, _ := mergeCommand(buildSwitchCmd("group0", percent), url); tc != nil {
+				if len(tc.MergeGroups) != 1 || tc.MergeGroups[0] != "group1" {
+					t.Fatalf("wrong merge groups of traffic switch: %v", tc.MergeGroups)
+				}
+				result[*motan.LocalIP] = true
+			}
+		}
+		return result
+	}
+	if n := len(switched(0)); n != 0 {
+		t.Errorf("no agent should be switched by 0 percent, switched:%d", n)
+	}
+	if n := len(switched(100)); n != 100 {
+		t.Errorf("all agents should be switched by 100 percent, switched:%d", n)
+	}
+	less, more := switched(30), switched(60)
+	if len(less) == 0 || len(less) >= len(more) {
+		t.Errorf("wrong switched agents. 30 percent:%d, 60 percent:%d", len(less), len(more))
+	}
+	for ip := range less {
+		if !more[ip] {
+			t.Errorf("agent %s should keep switched while the percent increases", ip)
+		}
+	}
+	if tc, _, _ := mergeCommand(buildSwitchCmd("group2", 100), url); tc != nil {
+		t.Errorf("the agents of other groups should not be switched")
+	}
+
+	// the group switched to is subscribed
+	crw := getDefalultCommandWarper()
+	crw.cluster.GetURL().Group = "group0"
+	crw.notifyListener = &MockListener{}
+	if !crw.processCommand(ServiceCmd, buildSwitchCmd("", 100)) || crw.tcCommand == nil || crw.otherGroupListener["group1"] == nil {
+		t.Errorf("traffic switch command not processed. crw:%+v", crw)
+	}
+	if !crw.processCommand(ServiceCmd, buildSwitchCmd("", 0)) || crw.tcCommand != nil || len(crw.otherGroupListener) != 0 {
+		t.Errorf("traffic switch command of 0 percent should restore the own group. crw:%+v", crw)
+	}
+}
+
 func processServiceCmd(crw *CommandRegistryWrapper, cl string, t *testing.T) {
 	notify := crw.processCommand(ServiceCmd, cl)
 	if crw.serviceCommandInfo != cl {
@@ -0,0 +1,193 @@
+package cluster
+
+import (
+	"container/list"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+const (
+	discoveryCacheFile         = "discovery_cache.json"
+	discoveryCacheSaveInterval = 10 * time.Second
+)
+
+var discoveryCache atomic.Value // *DiscoveryCache
+
+// SetDiscoveryCache sets the discovery cache of the clusters created later, nil disables it
+func SetDiscoveryCache(c *DiscoveryCache) {
+	discoveryCache.Store(c)
+}
+
+func GetDiscoveryCache() *DiscoveryCache {
+	c, _ := discoveryCache.Load().(*DiscoveryCache)
+	return c
+}
+
+// DiscoveryCache keeps the last discovery results of the clusters in memory and persists them to the dir, so the
+// clusters can be initialized by the cached results if the registries discover nothing, e.g. the agent restarts
+// during a registry outage. the results are used in the ttl, and the least recently used ones exceeding the max
+// entries are evicted
+type DiscoveryCache struct {
+	file       string
+	ttl        time.Duration
+	maxEntries int
+	lock       sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // *DiscoveryCacheEntry, the most recently used first
+	dirty      bool
+}
+
+// DiscoveryCacheEntry is the discovery result of a cluster from a registry
+type DiscoveryCacheEntry struct {
+	Key     string   `json:"key"`
+	URLs    []string `json:"urls"`    // the ext info of the urls
+	Updated int64    `json:"updated"` // unix seconds
+}
+
+// NewDiscoveryCache loads the cache persisted in the dir, and saves it when changed
+func NewDiscoveryCache(dir string, ttl time.Duration, maxEntries int) *DiscoveryCache {
+	c := &DiscoveryCache{
+		file:       filepath.Join(dir, discoveryCacheFile),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+	if data, err := ioutil.ReadFile(c.file); err == nil {
+		var entries []*DiscoveryCacheEntry
+		if err = json.Unmarshal(data, &entries); err != nil {
+			vlog.Warningf("discovery cache file is broken and ignored. file:%s, err:%v\n", c.file, err)
+		}
+		for _, e := range entries {
+			if len(c.entries) < maxEntries {
+				c.entries[e.Key] = c.lru.PushBack(e)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		vlog.Warningf("read discovery cache file fail. file:%s, err:%v\n", c.file, err)
+	}
+	go c.saveLoop()
+	vlog.Infof("discovery cache inited. file:%s, entries:%d, ttl:%v\n", c.file, len(c.entries), ttl)
+	return c
+}
+
+// Put caches the urls discovered, nothing is cached if the urls are empty
+func (c *DiscoveryCache) Put(key string, urls []*motan.URL) {
+	e := &DiscoveryCacheEntry{Key: key, URLs: make([]string, 0, len(urls)), Updated: time.Now().Unix()}
+	for _, u := range urls {
+		if u != nil {
+			e.URLs = append(e.URLs, u.ToExtInfo())
+		}
+	}
+	if len(e.URLs) == 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+	} else {
+		c.entries[key] = c.lru.PushFront(e)
+		for len(c.entries) > c.maxEntries {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.entries, oldest.Value.(*DiscoveryCacheEntry).Key)
+		}
+	}
+	c.dirty = true
+}
+
+// Get returns the cached urls of the key if they are cached in the ttl, the ttl of the cache is used if ttl is 0
+func (c *DiscoveryCache) Get(key string, ttl time.Duration) []*motan.URL {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*DiscoveryCacheEntry)
+	if time.Since(time.Unix(e.Updated, 0)) > ttl {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	urls := make([]*motan.URL, 0, len(e.URLs))
+	for _, info := range e.URLs {
+		if u := motan.FromExtInfo(info); u != nil {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// Entries returns the cached entries, the most recently used first
+func (c *DiscoveryCache) Entries() []*DiscoveryCacheEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entries := make([]*DiscoveryCacheEntry, 0, len(c.entries))
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		entries = append(entries, el.Value.(*DiscoveryCacheEntry))
+	}
+	return entries
+}
+
+// Flush removes the entry of the key, or all the entries if the key is empty, and returns the count removed
+func (c *DiscoveryCache) Flush(key string) int {
+	c.lock.Lock()
+	n := 0
+	if key == "" {
+		n = len(c.entries)
+		c.entries = make(map[string]*list.Element)
+		c.lru.Init()
+	} else if el, ok := c.entries[key]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		n = 1
+	}
+	c.dirty = c.dirty || n > 0
+	c.lock.Unlock()
+	c.Save()
+	return n
+}
+
+// Save persists the cache if it is changed
+func (c *DiscoveryCache) Save() error {
+	c.lock.Lock()
+	if !c.dirty {
+		c.lock.Unlock()
+		return nil
+	}
+	entries := make([]*DiscoveryCacheEntry, 0, len(c.entries))
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		entries = append(entries, el.Value.(*DiscoveryCacheEntry))
+	}
+	c.dirty = false
+	c.lock.Unlock()
+	data, _ := json.Marshal(entries)
+	// replaced by rename, so the file is not broken if the process exits when writing
+	tmp := c.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		vlog.Errorf("save discovery cache fail. file:%s, err:%v\n", c.file, err)
+		return err
+	}
+	return os.Rename(tmp, c.file)
+}
+
+func (c *DiscoveryCache) saveLoop() {
+	ticker := time.NewTicker(discoveryCacheSaveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.Save()
+	}
+}
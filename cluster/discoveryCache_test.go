@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func testURLs(ports ...int) []*motan.URL {
+	urls := make([]*motan.URL, 0, len(ports))
+	for _, port := range ports {
+		urls = append(urls, &motan.URL{Protocol: "test", Host: "127.0.0.1", Port: port, Path: "testService"})
+	}
+	return urls
+}
+
+func TestDiscoveryCache(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "discovery_cache")
+	defer os.RemoveAll(dir)
+	c := NewDiscoveryCache(dir, time.Minute, 2)
+	c.Put("k1", testURLs(8001, 8002))
+	c.Put("empty", nil)
+	if urls := c.Get("k1", 0); len(urls) != 2 || urls[1].Port != 8002 || urls[0].Path != "testService" {
+		t.Fatalf("wrong cached urls: %v", urls)
+	}
+	if urls := c.Get("empty", 0); urls != nil {
+		t.Errorf("empty urls should not be cached: %v", urls)
+	}
+
+	// expired
+	c.entries["k1"].Value.(*DiscoveryCacheEntry).Updated -= 120
+	if urls := c.Get("k1", 0); urls != nil {
+		t.Errorf("expired urls should not be returned: %v", urls)
+	}
+	if urls := c.Get("k1", time.Hour); len(urls) != 2 {
+		t.Errorf("urls in the ttl should be returned: %v", urls)
+	}
+
+	// k1 is used recently, so k2 is evicted
+	c.Put("k2", testURLs(8003))
+	c.Get("k1", time.Hour)
+	c.Put("k3", testURLs(8004))
+	if len(c.Entries()) != 2 || c.Get("k2", 0) != nil || c.Get("k3", 0) == nil {
+		t.Errorf("wrong entries after eviction: %v", c.Entries())
+	}
+
+	// reload from the dir
+	if err := c.Save(); err != nil {
+		t.Fatalf("save fail: %v", err)
+	}
+	reloaded := NewDiscoveryCache(dir, time.Minute, 10)
+	if len(reloaded.Entries()) != 2 || len(reloaded.Get("k3", 0)) != 1 || len(reloaded.Get("k1", time.Hour)) != 2 {
+		t.Errorf("wrong reloaded entries: %v", reloaded.Entries())
+	}
+
+	if n := reloaded.Flush("k3"); n != 1 || reloaded.Get("k3", 0) != nil {
+		t.Errorf("flush key fail. flushed:%d", n)
+	}
+	if n := reloaded.Flush(""); n != 1 || len(reloaded.Entries()) != 0 {
+		t.Errorf("flush all fail. flushed:%d", n)
+	}
+	if len(NewDiscoveryCache(dir, time.Minute, 10).Entries()) != 0 {
+		t.Errorf("flush should be persisted")
+	}
+}
+
+func TestClusterUseDiscoveryCache(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "discovery_cache")
+	defer os.RemoveAll(dir)
+	SetDiscoveryCache(NewDiscoveryCache(dir, time.Minute, 10))
+	defer SetDiscoveryCache(nil)
+
+	url := &motan.URL{Protocol: "test", Path: "testService", Parameters: map[string]string{
+		motan.Hakey:       "failover",
+		motan.Lbkey:       "random",
+		motan.RegistryKey: "test",
+	}}
+	context := &motan.Context{RegistryURLs: map[string]*motan.URL{"test": RegistryURL}}
+	// the test registry discovers nothing
+	cluster := NewCluster(context, getCustomExt(), url, false)
+	if len(cluster.Refers) != 0 {
+		t.Fatalf("no refers expected without cache, refers:%d", len(cluster.Refers))
+	}
+	cluster.Notify(RegistryURL, testURLs(8001, 8002))
+	cluster.Destroy()
+
+	cluster = NewCluster(context, getCustomExt(), url.Copy(), false)
+	defer cluster.Destroy()
+	if len(cluster.Refers) != 2 {
+		t.Errorf("cached urls should be used, refers:%d", len(cluster.Refers))
+	}
+}
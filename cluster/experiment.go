@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"sync/atomic"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+// variantLoadBalance routes the requests of the experiment to the endpoints tagged with the assigned variants. the
+// endpoints of each variant are balanced by a load balance of the refer, and the requests not in the experiment or
+// assigned to a variant without endpoints go to the endpoints without variant, or to all the endpoints if none
+type variantLoadBalance struct {
+	experiment string
+	newLB      func() motan.LoadBalance
+	weight     string
+	groups     atomic.Value // *variantGroups
+}
+
+type variantGroups struct {
+	base     motan.LoadBalance
+	variants map[string]motan.LoadBalance
+}
+
+func newVariantLoadBalance(experiment string, newLB func() motan.LoadBalance) *variantLoadBalance {
+	v := &variantLoadBalance{experiment: experiment, newLB: newLB}
+	v.OnRefresh(nil)
+	return v
+}
+
+func (v *variantLoadBalance) OnRefresh(endpoints []motan.EndPoint) {
+	tagged := make(map[string][]motan.EndPoint)
+	untagged := make([]motan.EndPoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if variant := ep.GetURL().GetParam(motan.VariantKey, ""); variant != "" {
+			tagged[variant] = append(tagged[variant], ep)
+		} else {
+			untagged = append(untagged, ep)
+		}
+	}
+	if len(untagged) == 0 {
+		untagged = endpoints
+	}
+	groups := &variantGroups{base: v.loadBalance(untagged), variants: make(map[string]motan.LoadBalance, len(tagged))}
+	for variant, eps := range tagged {
+		groups.variants[variant] = v.loadBalance(eps)
+	}
+	v.groups.Store(groups)
+}
+
+func (v *variantLoadBalance) loadBalance(endpoints []motan.EndPoint) motan.LoadBalance {
+	lb := v.newLB()
+	if v.weight != "" {
+		lb.SetWeight(v.weight)
+	}
+	lb.OnRefresh(endpoints)
+	return lb
+}
+
+// route assigns the request to a variant and returns the load balance of the variant
+func (v *variantLoadBalance) route(request motan.Request) motan.LoadBalance {
+	groups := v.groups.Load().(*variantGroups)
+	if variant := motan.AssignExperiment(v.experiment, request); variant != "" {
+		if lb, ok := groups.variants[variant]; ok {
+			return lb
+		}
+	}
+	return groups.base
+}
+
+func (v *variantLoadBalance) Select(request motan.Request) motan.EndPoint {
+	return v.route(request).Select(request)
+}
+
+func (v *variantLoadBalance) SelectArray(request motan.Request) []motan.EndPoint {
+	return v.route(request).SelectArray(request)
+}
+
+// SetWeight sets the weight of the load balances created by the next refresh
+func (v *variantLoadBalance) SetWeight(weight string) {
+	v.weight = weight
+}
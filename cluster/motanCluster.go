@@ -25,7 +25,9 @@ type MotanCluster struct {
 	clusterFilter  motan.ClusterFilter
 	extFactory     motan.ExtensionFactory
 	registryRefers map[string][]motan.EndPoint
+	filterChains   *motan.FilterChainCache // nil if disabled by the url param filterChainCache: false
 	notifyLock     sync.Mutex
+	override       *EndpointOverride // the pin or exclude of the endpoints, nil if none
 	available      bool
 	closed         bool
 	proxy          bool
@@ -54,8 +56,12 @@ func (m *MotanCluster) GetURL() *motan.URL {
 	return m.url
 }
 
+// SetURL sets the url of the cluster, the filters are rebuilt by the url if the cluster is initialized
 func (m *MotanCluster) SetURL(url *motan.URL) {
 	m.url = url
+	if m.registryRefers != nil {
+		m.RefreshFilters()
+	}
 }
 func (m *MotanCluster) Call(request motan.Request) (res motan.Response) {
 	defer motan.HandlePanic(func() {
@@ -63,6 +69,12 @@ func (m *MotanCluster) Call(request motan.Request) (res motan.Response) {
 		vlog.Errorf("cluster call panic. req:%s\n", motan.GetReqInfo(request))
 	})
 	if m.available {
+		if scope := vlog.ServiceScopePrefix + m.url.Path; vlog.InfoEnabled(scope) {
+			start := time.Now()
+			res = m.clusterFilter.Filter(m.HaStrategy, m.LoadBalance, request)
+			vlog.ScopeInfof(scope, "cluster call. cluster:%s, req:%s, cost:%v, exception:%+v\n", m.GetIdentity(), motan.GetReqInfo(request), time.Since(start), res.GetException())
+			return res
+		}
 		return m.clusterFilter.Filter(m.HaStrategy, m.LoadBalance, request)
 	}
 	vlog.Infoln("cluster:" + m.GetIdentity() + "is not available!")
@@ -74,6 +86,10 @@ func (m *MotanCluster) initCluster() bool {
 	m.HaStrategy = m.extFactory.GetHa(m.url)
 	//lb
 	m.LoadBalance = m.extFactory.GetLB(m.url)
+	if experiment := m.url.GetParam(motan.ExperimentKey, ""); experiment != "" && m.LoadBalance != nil {
+		// the requests are routed to the endpoints of the variants assigned by the experiment
+		m.LoadBalance = newVariantLoadBalance(experiment, func() motan.LoadBalance { return m.extFactory.GetLB(m.url) })
+	}
 	//filter
 	m.initFilters()
 
@@ -111,12 +127,23 @@ func (m *MotanCluster) refresh() {
 		}
 	}
 	m.Refers = newRefers
-	m.LoadBalance.OnRefresh(newRefers)
+	m.LoadBalance.OnRefresh(m.selectable(newRefers))
 }
 func (m *MotanCluster) AddRegistry(registry motan.Registry) {
 	m.Registries = append(m.Registries, registry)
 }
 func (m *MotanCluster) Notify(registryURL *motan.URL, urls []*motan.URL) {
+	if c := GetDiscoveryCache(); c != nil && len(urls) > 0 {
+		c.Put(m.discoveryCacheKey(registryURL), urls)
+	}
+	m.notify(registryURL, urls)
+}
+
+func (m *MotanCluster) discoveryCacheKey(registryURL *motan.URL) string {
+	return registryURL.GetIdentity() + "|" + m.url.GetIdentity()
+}
+
+func (m *MotanCluster) notify(registryURL *motan.URL, urls []*motan.URL) {
 	vlog.Infof("cluster %s receive notify size %d. \n", m.GetIdentity(), len(urls))
 	m.notifyLock.Lock()
 	defer m.notifyLock.Unlock()
@@ -146,8 +173,11 @@ func (m *MotanCluster) Notify(registryURL *motan.URL, urls []*motan.URL) {
 			delete(endpointMap, u.GetIdentity())
 		}
 		if ep == nil {
-			newURL := u.Copy()
-			newURL.MergeParams(m.url.Parameters)
+			newURL := u.CopyWithParams(m.url.Parameters)
+			// the dial proxy of the cluster overrides the one of the registry
+			if dialProxy := registryURL.GetParam(motan.DialProxyKey, ""); dialProxy != "" && newURL.GetParam(motan.DialProxyKey, "") == "" {
+				newURL.PutParam(motan.DialProxyKey, dialProxy)
+			}
 			ep = m.extFactory.GetEndPoint(newURL)
 
 			if ep != nil {
@@ -179,9 +209,40 @@ func (m *MotanCluster) Notify(registryURL *motan.URL, urls []*motan.URL) {
 		m.registryRefers[registryURL.GetIdentity()] = endpoints
 	}
 	m.refresh()
+	if m.filterChains != nil {
+		urls := make([]*motan.URL, 0, len(m.Refers))
+		for _, ep := range m.Refers {
+			urls = append(urls, ep.GetURL())
+		}
+		m.filterChains.Retain(urls)
+	}
 	for _, ep := range endpointMap {
+		destroyEndpoint(ep)
+	}
+}
+
+// drainer is the endpoint can finish the requests being processed before destroyed
+type drainer interface {
+	Drain() <-chan struct{}
+}
+
+// destroyEndpoint destroys the endpoint removed by the registry, the endpoint is drained first if supported
+func destroyEndpoint(ep motan.EndPoint) {
+	caller := motan.Caller(ep)
+	if fep, ok := ep.(*motan.FilterEndPoint); ok {
+		caller = fep.Caller
+	}
+	d, ok := caller.(drainer)
+	if !ok {
 		ep.Destroy()
+		return
 	}
+	drained := d.Drain()
+	go func() {
+		defer motan.HandlePanic(nil)
+		<-drained
+		ep.Destroy()
+	}()
 }
 
 // remove rule protocol && set weight
@@ -198,24 +259,42 @@ func processWeight(m *MotanCluster, urls []*motan.URL) []*motan.URL {
 
 func (m *MotanCluster) addFilter(ep motan.EndPoint, filters []motan.Filter) motan.EndPoint {
 	fep := &motan.FilterEndPoint{URL: ep.GetURL(), Caller: ep}
-	statusFilters := make([]motan.Status, 0, len(filters))
-	var lastf motan.EndPointFilter
-	lastf = motan.GetLastEndPointFilter()
-	for _, f := range filters {
-		if filter := f.NewFilter(ep.GetURL()); filter != nil {
-			if ef, ok := filter.(motan.EndPointFilter); ok {
-				motan.CanSetContext(ef, m.Context)
-				ef.SetNext(lastf)
-				lastf = ef
-				if sf, ok := ef.(motan.Status); ok {
-					statusFilters = append(statusFilters, sf)
+	if m.filterChains != nil {
+		fep.Filter, fep.StatusFilters = m.filterChains.Get(ep.GetURL())
+	} else {
+		fep.Filter, fep.StatusFilters = motan.BuildEndPointFilterChain(ep.GetURL(), filters, m.Context)
+	}
+	return fep
+}
+
+// RefreshFilters rebuilds the cluster filters and the endpoint filter chains by the url of the cluster, it is the
+// hook for the filters or the url params changed at runtime. the requests being processed keep using the previous
+// filters, and the stats of the endpoints are reset
+func (m *MotanCluster) RefreshFilters() {
+	m.notifyLock.Lock()
+	defer m.notifyLock.Unlock()
+	m.clusterFilter, m.Filters = nil, nil
+	m.initFilters()
+	if m.clusterFilter == nil {
+		m.clusterFilter = motan.GetLastClusterFilter()
+	}
+	if m.Filters == nil {
+		m.Filters = make([]motan.Filter, 0)
+	}
+	for key, eps := range m.registryRefers {
+		refers := make([]motan.EndPoint, 0, len(eps))
+		for _, ep := range eps {
+			if fep, ok := ep.(*motan.FilterEndPoint); ok {
+				if caller, ok := fep.Caller.(motan.EndPoint); ok {
+					ep = m.addFilter(caller, m.Filters)
 				}
 			}
+			refers = append(refers, ep)
 		}
+		m.registryRefers[key] = refers
 	}
-	fep.StatusFilters = statusFilters
-	fep.Filter = lastf
-	return fep
+	m.refresh()
+	vlog.Infof("cluster %s filters refreshed\n", m.GetIdentity())
 }
 func (m *MotanCluster) GetIdentity() string {
 	return m.url.GetIdentity()
@@ -233,6 +312,10 @@ func (m *MotanCluster) Destroy() {
 			vlog.Infof("destroy endpoint %s .\n", e.GetURL().GetIdentity())
 			e.Destroy()
 		}
+		if m.override != nil {
+			m.override.timer.Stop()
+			m.override = nil
+		}
 		m.closed = true
 	}
 }
@@ -264,6 +347,14 @@ func (m *MotanCluster) parseRegistry() (err error) {
 			registry.Subscribe(m.url, m)
 			registries = append(registries, registry)
 			urls := registry.Discover(m.url)
+			if c := GetDiscoveryCache(); c != nil && len(urls) == 0 {
+				// the cached urls do not refresh the cache, so they expire if the registry keeps discovering nothing
+				if cached := c.Get(m.discoveryCacheKey(registryURL), m.url.GetTimeDuration(motan.DiscoveryCacheTTLKey, time.Second, 0)); len(cached) > 0 {
+					vlog.Warningf("registry %s discovers nothing, cluster %s uses %d cached urls\n", registryURL.GetIdentity(), m.GetIdentity(), len(cached))
+					m.notify(registryURL, cached)
+					continue
+				}
+			}
 			m.Notify(registryURL, urls)
 		} else {
 			err = errors.New("registry is invalid: " + r)
@@ -283,6 +374,13 @@ func (m *MotanCluster) initFilters() {
 	if len(endpointFilters) > 0 {
 		m.Filters = endpointFilters
 	}
+	if m.url.GetParam(FilterChainCacheKey, "true") == "false" {
+		m.filterChains = nil
+	} else if m.filterChains == nil {
+		m.filterChains = motan.NewFilterChainCache(endpointFilters, m.Context)
+	} else {
+		m.filterChains.SetFilters(endpointFilters)
+	}
 }
 
 func (m *MotanCluster) NotifyAgentCommand(commandInfo string) {
@@ -293,6 +391,9 @@ func (m *MotanCluster) NotifyAgentCommand(commandInfo string) {
 	}
 }
 
+// the endpoint filter chains are cached by the urls of the endpoints, unless the param is false
+const FilterChainCacheKey = "filterChainCache"
+
 const (
 	clusterIdcPlaceHolder         = "${idc}"
 	registryGroupInfoMaxCacheTime = time.Hour
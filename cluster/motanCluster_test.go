@@ -3,6 +3,7 @@ package cluster
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	motan "github.com/weibocom/motan-go/core"
 	"github.com/weibocom/motan-go/ha"
@@ -72,6 +73,39 @@ func TestNotify(t *testing.T) {
 
 }
 
+func TestFilterChainCache(t *testing.T) {
+	cluster := initCluster()
+	cluster.url.Parameters["filter"] = "test1,test2,test3,test4,test5,test6"
+	cluster.initFilters()
+	urls := []*motan.URL{{Host: "127.0.0.1", Port: 8001, Protocol: "test"}, {Host: "127.0.0.1", Port: 8002, Protocol: "test"}}
+	cluster.Notify(RegistryURL, urls)
+	if cluster.filterChains.Len() != 2 {
+		t.Fatalf("filter chains should be cached by endpoint urls. size:%d", cluster.filterChains.Len())
+	}
+	first := cluster.Refers[0].(*motan.FilterEndPoint)
+	filter, _ := cluster.filterChains.Get(first.URL)
+	if filter != first.Filter {
+		t.Errorf("the cached filter chain should be shared by the endpoint")
+	}
+	cluster.Notify(RegistryURL, urls[:1])
+	if cluster.filterChains.Len() != 1 {
+		t.Errorf("filter chains of the removed endpoints should be dropped. size:%d", cluster.filterChains.Len())
+	}
+
+	cluster.RefreshFilters()
+	refreshed := cluster.Refers[0].(*motan.FilterEndPoint)
+	if len(cluster.Refers) != 1 || refreshed.Filter == first.Filter || refreshed.Caller != first.Caller {
+		t.Errorf("filter chains should be rebuilt for the same endpoints")
+	}
+
+	cluster.url.Parameters[FilterChainCacheKey] = "false"
+	cluster.RefreshFilters()
+	if cluster.filterChains != nil {
+		t.Errorf("filter chain cache should be disabled")
+	}
+	checkEndpointFilter(cluster.Filters, 3, t)
+}
+
 func TestCall(t *testing.T) {
 	cluster := initCluster()
 	response := cluster.Call(&motan.MotanRequest{})
@@ -102,6 +136,97 @@ func TestDestroy(t *testing.T) {
 	}
 }
 
+func TestEndpointOverride(t *testing.T) {
+	cluster := initCluster()
+	urls := []*motan.URL{{Host: "127.0.0.1", Port: 8001, Protocol: "test"}, {Host: "127.0.0.1", Port: 8002, Protocol: "test"}}
+	cluster.Notify(RegistryURL, urls)
+	selected := func() map[string]bool {
+		addresses := make(map[string]bool)
+		for i := 0; i < 100; i++ {
+			addresses[cluster.LoadBalance.Select(&motan.MotanRequest{}).GetURL().GetAddressStr()] = true
+		}
+		return addresses
+	}
+	if err := cluster.Pin([]string{"127.0.0.1:9000"}, time.Minute); err == nil {
+		t.Errorf("pin should fail without the endpoints of the addresses")
+	}
+	if err := cluster.Exclude([]string{"127.0.0.1:8001", "127.0.0.1:8002"}, time.Minute); err == nil {
+		t.Errorf("exclude should fail if all endpoints are excluded")
+	}
+	if err := cluster.Pin([]string{"127.0.0.1:8001"}, time.Minute); err != nil {
+		t.Fatalf("pin fail. err:%v", err)
+	}
+	if s := selected(); len(s) != 1 || !s["127.0.0.1:8001"] {
+		t.Errorf("requests should be sent to the pinned endpoint. selected:%v", s)
+	}
+	// the override is kept after notified
+	cluster.Notify(RegistryURL, urls)
+	if s := selected(); len(s) != 1 || !s["127.0.0.1:8001"] || len(cluster.GetRefers()) != 2 {
+		t.Errorf("requests should be sent to the pinned endpoint after notified. selected:%v", s)
+	}
+	if err := cluster.Exclude([]string{"127.0.0.1:8001"}, 50*time.Millisecond); err != nil {
+		t.Fatalf("exclude fail. err:%v", err)
+	}
+	if o := cluster.GetOverride(); o == nil || o.Mode != OverrideExclude {
+		t.Errorf("exclude should replace the pin. override:%+v", o)
+	}
+	if s := selected(); len(s) != 1 || !s["127.0.0.1:8002"] {
+		t.Errorf("requests should not be sent to the excluded endpoint. selected:%v", s)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if o := cluster.GetOverride(); o != nil {
+		t.Errorf("override should expire. override:%+v", o)
+	}
+	if s := selected(); len(s) != 2 {
+		t.Errorf("requests should be sent to all endpoints after the override expired. selected:%v", s)
+	}
+	cluster.Pin([]string{"127.0.0.1:8002"}, time.Minute)
+	cluster.ClearOverride()
+	if cluster.GetOverride() != nil || len(selected()) != 2 {
+		t.Errorf("override should be cleared")
+	}
+}
+
+func TestExperimentRouting(t *testing.T) {
+	defer motan.RegistExperimentAssigner("exp1", nil)
+	motan.RegistExperimentAssigner("exp1", motan.ExperimentAssignerFunc(func(request motan.Request) string {
+		return request.GetAttachment("uid")
+	}))
+	cluster := initCluster()
+	cluster.url.Parameters[motan.ExperimentKey] = "exp1"
+	cluster.initCluster()
+	urls := []*motan.URL{
+		{Host: "127.0.0.1", Port: 8001, Protocol: "test"},
+		{Host: "127.0.0.1", Port: 8002, Protocol: "test", Parameters: map[string]string{motan.VariantKey: "b"}},
+		{Host: "127.0.0.1", Port: 8003, Protocol: "test", Parameters: map[string]string{motan.VariantKey: "c"}},
+	}
+	cluster.Notify(RegistryURL, urls)
+	selected := func(uid string) map[int]bool {
+		ports := make(map[int]bool)
+		for i := 0; i < 100; i++ {
+			request := &motan.MotanRequest{Attachment: motan.NewStringMap(0)}
+			request.SetAttachment("uid", uid)
+			ports[cluster.LoadBalance.Select(request).GetURL().Port] = true
+			if request.GetAttachment(motan.VariantAttachmentKey) != uid {
+				t.Fatalf("the variant should be recorded in the attachments. variant:%s", request.GetAttachment(motan.VariantAttachmentKey))
+			}
+		}
+		return ports
+	}
+	if ports := selected("b"); len(ports) != 1 || !ports[8002] {
+		t.Errorf("variant b should be routed to the endpoint of b. ports:%v", ports)
+	}
+	if ports := selected("c"); len(ports) != 1 || !ports[8003] {
+		t.Errorf("variant c should be routed to the endpoint of c. ports:%v", ports)
+	}
+	if ports := selected(""); len(ports) != 1 || !ports[8001] {
+		t.Errorf("requests not in the experiment should be routed to the endpoints without variant. ports:%v", ports)
+	}
+	if ports := selected("d"); len(ports) != 1 || !ports[8001] {
+		t.Errorf("variants without endpoints should be routed to the endpoints without variant. ports:%v", ports)
+	}
+}
+
 //-------------test struct--------------------
 func getCustomExt() motan.ExtensionFactory {
 	ext := &motan.DefaultExtensionFactory{}
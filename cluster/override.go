@@ -0,0 +1,130 @@
+package cluster
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// the modes of EndpointOverride
+const (
+	OverridePin     = "pin"
+	OverrideExclude = "exclude"
+)
+
+const defaultOverrideTTL = 10 * time.Minute
+
+// EndpointOverride pins the traffic of the cluster to the endpoints of the addresses, or excludes the endpoints of
+// the addresses from the load balance, until it expires. it is used to debug the live issues against a known-good
+// or a suspect instance
+type EndpointOverride struct {
+	Mode      string    `json:"mode"`
+	Addresses []string  `json:"addresses"`
+	ExpireAt  time.Time `json:"expire_at"`
+
+	addresses map[string]bool
+	timer     *time.Timer
+}
+
+// matches tells whether the endpoint is selectable by the override
+func (o *EndpointOverride) matches(ep motan.EndPoint) bool {
+	return o.addresses[ep.GetURL().GetAddressStr()] == (o.Mode == OverridePin)
+}
+
+// Pin sends the requests of the cluster only to the endpoints of the addresses(host:port) for the ttl, the default
+// ttl is 10 minutes if not positive. the previous pin or exclude is replaced
+func (m *MotanCluster) Pin(addresses []string, ttl time.Duration) error {
+	return m.setOverride(OverridePin, addresses, ttl)
+}
+
+// Exclude stops sending the requests of the cluster to the endpoints of the addresses(host:port) for the ttl like Pin
+func (m *MotanCluster) Exclude(addresses []string, ttl time.Duration) error {
+	return m.setOverride(OverrideExclude, addresses, ttl)
+}
+
+// ClearOverride removes the pin or exclude of the cluster before it expires
+func (m *MotanCluster) ClearOverride() {
+	m.notifyLock.Lock()
+	defer m.notifyLock.Unlock()
+	m.clearOverride(m.override)
+}
+
+// GetOverride returns the pin or exclude in effect, nil if none
+func (m *MotanCluster) GetOverride() *EndpointOverride {
+	m.notifyLock.Lock()
+	defer m.notifyLock.Unlock()
+	return m.override
+}
+
+func (m *MotanCluster) setOverride(mode string, addresses []string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = defaultOverrideTTL
+	}
+	o := &EndpointOverride{Mode: mode, ExpireAt: time.Now().Add(ttl), addresses: make(map[string]bool, len(addresses))}
+	for _, address := range addresses {
+		if address != "" && !o.addresses[address] {
+			o.addresses[address] = true
+			o.Addresses = append(o.Addresses, address)
+		}
+	}
+	if len(o.Addresses) == 0 {
+		return errors.New("no address to " + mode)
+	}
+	sort.Strings(o.Addresses)
+	m.notifyLock.Lock()
+	defer m.notifyLock.Unlock()
+	selectable := 0
+	for _, ep := range m.Refers {
+		if o.matches(ep) {
+			selectable++
+		}
+	}
+	if selectable == 0 {
+		return errors.New("no endpoint left to call after " + mode)
+	}
+	if m.override != nil {
+		m.override.timer.Stop()
+	}
+	m.override = o
+	o.timer = time.AfterFunc(ttl, func() {
+		m.notifyLock.Lock()
+		defer m.notifyLock.Unlock()
+		m.clearOverride(o)
+	})
+	m.refresh()
+	vlog.Infof("cluster %s %s endpoints %v for %v\n", m.GetIdentity(), mode, o.Addresses, ttl)
+	return nil
+}
+
+// clearOverride removes the override if it is still in effect, the notifyLock must be held
+func (m *MotanCluster) clearOverride(o *EndpointOverride) {
+	if o == nil || m.override != o {
+		return
+	}
+	o.timer.Stop()
+	m.override = nil
+	m.refresh()
+	vlog.Infof("cluster %s %s endpoints %v cleared\n", m.GetIdentity(), o.Mode, o.Addresses)
+}
+
+// selectable returns the endpoints selectable by the override, all the endpoints if no override or no endpoint matches
+func (m *MotanCluster) selectable(refers []motan.EndPoint) []motan.EndPoint {
+	if m.override == nil {
+		return refers
+	}
+	eps := make([]motan.EndPoint, 0, len(refers))
+	for _, ep := range refers {
+		if m.override.matches(ep) {
+			eps = append(eps, ep)
+		}
+	}
+	if len(eps) == 0 {
+		// the pinned endpoints are removed by the registry, the requests should not fail for the debugging
+		vlog.Warningf("cluster %s no endpoint matches the %s of %v, ignored\n", m.GetIdentity(), m.override.Mode, m.override.Addresses)
+		return refers
+	}
+	return eps
+}
@@ -0,0 +1,160 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	motan "github.com/weibocom/motan-go/core"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+// ext name
+const (
+	Gzip   = "gzip"
+	Snappy = "snappy"
+	Zstd   = "zstd"
+	LZ4    = "lz4"
+)
+
+func RegistDefaultCompressors(extFactory motan.ExtensionFactory) {
+	extFactory.RegistExtCompressor(Gzip, func() motan.Compressor {
+		return &GzipCompressor{}
+	})
+	extFactory.RegistExtCompressor(Snappy, func() motan.Compressor {
+		return &SnappyCompressor{}
+	})
+	extFactory.RegistExtCompressor(Zstd, func() motan.Compressor {
+		return &ZstdCompressor{}
+	})
+	extFactory.RegistExtCompressor(LZ4, func() motan.Compressor {
+		return &LZ4Compressor{}
+	})
+}
+
+// GzipCompressor uses the pooled gzip writers of the protocol
+type GzipCompressor struct{}
+
+func (g *GzipCompressor) GetName() string {
+	return Gzip
+}
+
+func (g *GzipCompressor) Compress(data []byte) ([]byte, error) {
+	return mpro.EncodeGzip(data)
+}
+
+func (g *GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	return mpro.DecodeGzip(data)
+}
+
+type SnappyCompressor struct{}
+
+func (s *SnappyCompressor) GetName() string {
+	return Snappy
+}
+
+func (s *SnappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (s *SnappyCompressor) Decompress(data []byte) ([]byte, error) {
+	size, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if size > mpro.GetMaxDecompressedSize() {
+		return nil, mpro.ErrDecompressedTooLarge
+	}
+	return snappy.Decode(nil, data)
+}
+
+var (
+	// zstd encoder and decoder are safe for concurrent EncodeAll and DecodeAll
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdErr     error
+	// the decoder is recreated if the max decompressed size changed
+	zstdDecoderLock  sync.Mutex
+	zstdDecoder      *zstd.Decoder
+	zstdDecoderLimit int
+)
+
+func initZstd() {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	})
+}
+
+// getZstdDecoder returns the decoder limited by the max decompressed size
+func getZstdDecoder() (*zstd.Decoder, error) {
+	limit := mpro.GetMaxDecompressedSize()
+	zstdDecoderLock.Lock()
+	defer zstdDecoderLock.Unlock()
+	if zstdDecoder != nil && zstdDecoderLimit == limit {
+		return zstdDecoder, nil
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	// the previous decoder is not closed as it may be decoding, it has no stream goroutines to release
+	zstdDecoder, zstdDecoderLimit = decoder, limit
+	return decoder, nil
+}
+
+type ZstdCompressor struct{}
+
+func (z *ZstdCompressor) GetName() string {
+	return Zstd
+}
+
+func (z *ZstdCompressor) Compress(data []byte) ([]byte, error) {
+	initZstd()
+	if zstdErr != nil {
+		return nil, zstdErr
+	}
+	return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+}
+
+func (z *ZstdCompressor) Decompress(data []byte) ([]byte, error) {
+	decoder, err := getZstdDecoder()
+	if err != nil {
+		return nil, err
+	}
+	data, err = decoder.DecodeAll(data, nil)
+	if err == zstd.ErrDecoderSizeExceeded {
+		return nil, mpro.ErrDecompressedTooLarge
+	}
+	return data, err
+}
+
+type LZ4Compressor struct{}
+
+func (l *LZ4Compressor) GetName() string {
+	return LZ4
+}
+
+func (l *LZ4Compressor) Compress(data []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+	w := lz4.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (l *LZ4Compressor) Decompress(data []byte) ([]byte, error) {
+	max := int64(mpro.GetMaxDecompressedSize())
+	data, err := ioutil.ReadAll(io.LimitReader(lz4.NewReader(bytes.NewReader(data)), max+1))
+	if err == nil && int64(len(data)) > max {
+		return nil, mpro.ErrDecompressedTooLarge
+	}
+	return data, err
+}
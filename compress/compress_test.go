@@ -0,0 +1,60 @@
+package compress
+
+import (
+	"bytes"
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+func TestCompressors(t *testing.T) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	RegistDefaultCompressors(ext)
+	data := bytes.Repeat([]byte("motan compress "), 1000)
+	for _, name := range []string{Gzip, Snappy, Zstd, LZ4} {
+		compressor := ext.GetCompressor(name)
+		if compressor == nil || compressor.GetName() != name {
+			t.Fatalf("compressor %s not registered", name)
+		}
+		compressed, err := compressor.Compress(data)
+		if err != nil {
+			t.Fatalf("compress fail. compressor:%s, err:%v", name, err)
+		}
+		if len(compressed) >= len(data) {
+			t.Errorf("data not compressed. compressor:%s, size:%d", name, len(compressed))
+		}
+		decompressed, err := compressor.Decompress(compressed)
+		if err != nil {
+			t.Fatalf("decompress fail. compressor:%s, err:%v", name, err)
+		}
+		if !bytes.Equal(data, decompressed) {
+			t.Errorf("decompressed data not correct. compressor:%s", name)
+		}
+	}
+	if ext.GetCompressor("unknown") != nil {
+		t.Errorf("unknown compressor should be nil")
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	defer mpro.SetMaxDecompressedSize(0)
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	RegistDefaultCompressors(ext)
+	data := bytes.Repeat([]byte("motan compress "), 1000)
+	for _, name := range []string{Gzip, Snappy, Zstd, LZ4} {
+		compressor := ext.GetCompressor(name)
+		compressed, _ := compressor.Compress(data)
+		mpro.SetMaxDecompressedSize(len(data))
+		if decompressed, err := compressor.Decompress(compressed); err != nil || !bytes.Equal(data, decompressed) {
+			t.Errorf("decompress in the limit fail. compressor:%s, err:%v", name, err)
+		}
+		mpro.SetMaxDecompressedSize(len(data) - 1)
+		if _, err := compressor.Decompress(compressed); err != mpro.ErrDecompressedTooLarge {
+			t.Errorf("decompress over the limit should fail. compressor:%s, err:%v", name, err)
+		}
+		mpro.SetMaxDecompressedSize(0)
+	}
+}
@@ -0,0 +1,4 @@
+/*
+Package compress implements the compressors of message body.
+*/
+package compress
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weibocom/motan-go/log"
+)
+
+// the params of the config center section
+const (
+	CenterProtocolKey = "protocol"
+	CenterAddressKey  = "address"
+	CenterIntervalKey = "interval" // seconds to check the changes
+
+	defaultCenterInterval = 30 * time.Second
+	centerRequestTimeout  = 3 * time.Second
+)
+
+// Center fetches the yaml config from a config center such as apollo or nacos
+type Center interface {
+	Fetch() ([]byte, error)
+}
+
+// CenterFactory creates the Center with the params of the config center section
+type CenterFactory func(params map[string]string) (Center, error)
+
+var (
+	centerLock      sync.Mutex
+	centerFactories = map[string]CenterFactory{
+		"apollo": newApolloCenter,
+		"nacos":  newNacosCenter,
+	}
+	remoteConfigs = make(map[string]*RemoteConfig)
+)
+
+// RegisterCenter registers the factory of the config center protocol
+func RegisterCenter(protocol string, factory CenterFactory) {
+	centerLock.Lock()
+	defer centerLock.Unlock()
+	centerFactories[protocol] = factory
+}
+
+// RemoteConfig is the config fetched from a config center. the last config fetched is used if the center is
+// unavailable, and the listeners are notified if the config changed
+type RemoteConfig struct {
+	center    Center
+	interval  time.Duration
+	lock      sync.Mutex
+	last      []byte
+	watched   []byte
+	watchOnce sync.Once
+	listeners []func()
+}
+
+// GetRemoteConfig returns the RemoteConfig of the config center params, the same params share the RemoteConfig
+// so the config is reloaded by the same one
+func GetRemoteConfig(params map[string]string) (*RemoteConfig, error) {
+	key := centerKey(params)
+	centerLock.Lock()
+	defer centerLock.Unlock()
+	if r, ok := remoteConfigs[key]; ok {
+		return r, nil
+	}
+	factory, ok := centerFactories[params[CenterProtocolKey]]
+	if !ok {
+		return nil, errors.New("unknown config center protocol: " + params[CenterProtocolKey])
+	}
+	center, err := factory(params)
+	if err != nil {
+		return nil, err
+	}
+	r := &RemoteConfig{center: center, interval: defaultCenterInterval}
+	if s, err := strconv.Atoi(params[CenterIntervalKey]); err == nil && s > 0 {
+		r.interval = time.Duration(s) * time.Second
+	}
+	remoteConfigs[key] = r
+	return r, nil
+}
+
+// Load fetches the config, the last config fetched is returned if it fails
+func (r *RemoteConfig) Load() (*Config, error) {
+	data, err := r.center.Fetch()
+	r.lock.Lock()
+	if err == nil {
+		r.last = data
+		if r.watched == nil {
+			r.watched = data
+		}
+	} else if r.last != nil {
+		vlog.Warningf("fetch config from config center fail, use the last one. err:%v\n", err)
+		data, err = r.last, nil
+	}
+	r.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return NewConfigFromBytes(data)
+}
+
+// Watch adds the listener called if the config changed, the config center is checked every interval
+func (r *RemoteConfig) Watch(onChange func()) {
+	r.lock.Lock()
+	r.listeners = append(r.listeners, onChange)
+	r.lock.Unlock()
+	r.watchOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(r.interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				r.check()
+			}
+		}()
+	})
+}
+
+func (r *RemoteConfig) check() {
+	data, err := r.center.Fetch()
+	if err != nil {
+		vlog.Warningf("check config center fail. err:%v\n", err)
+		return
+	}
+	r.lock.Lock()
+	if bytes.Equal(data, r.watched) {
+		r.lock.Unlock()
+		return
+	}
+	r.watched = data
+	listeners := append([]func(){}, r.listeners...)
+	r.lock.Unlock()
+	vlog.Infoln("config of config center changed")
+	for _, l := range listeners {
+		l()
+	}
+}
+
+func centerKey(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, k := range keys {
+		buf.WriteString(k + "=" + params[k] + "&")
+	}
+	return buf.String()
+}
+
+func centerAddress(params map[string]string) (string, error) {
+	address := params[CenterAddressKey]
+	if address == "" {
+		return "", errors.New("config center address not set")
+	}
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+	return strings.TrimSuffix(address, "/"), nil
+}
+
+func httpGet(u string) ([]byte, error) {
+	client := http.Client{Timeout: centerRequestTimeout}
+	res, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("config center responds %d: %s", res.StatusCode, body)
+	}
+	return body, nil
+}
+
+// apolloCenter fetches the yaml namespace of apollo, params: address of the config service, appId, cluster(default)
+// and namespace(application.yaml)
+type apolloCenter struct {
+	url string
+}
+
+func newApolloCenter(params map[string]string) (Center, error) {
+	address, err := centerAddress(params)
+	if err != nil {
+		return nil, err
+	}
+	appID := params["appId"]
+	if appID == "" {
+		return nil, errors.New("apollo appId not set")
+	}
+	cluster := params["cluster"]
+	if cluster == "" {
+		cluster = "default"
+	}
+	namespace := params["namespace"]
+	if namespace == "" {
+		namespace = "application.yaml"
+	}
+	return &apolloCenter{url: address + "/configfiles/json/" + url.PathEscape(appID) + "/" + url.PathEscape(cluster) + "/" + url.PathEscape(namespace)}, nil
+}
+
+func (a *apolloCenter) Fetch() ([]byte, error) {
+	body, err := httpGet(a.url)
+	if err != nil {
+		return nil, err
+	}
+	// the configurations of a yaml namespace is the content
+	var configurations map[string]string
+	if err = json.Unmarshal(body, &configurations); err != nil {
+		return nil, err
+	}
+	content, ok := configurations["content"]
+	if !ok {
+		return nil, errors.New("apollo namespace is not yaml")
+	}
+	return []byte(content), nil
+}
+
+// nacosCenter fetches the config of nacos, params: address of the server, dataId, group(DEFAULT_GROUP) and namespace
+type nacosCenter struct {
+	url string
+}
+
+func newNacosCenter(params map[string]string) (Center, error) {
+	address, err := centerAddress(params)
+	if err != nil {
+		return nil, err
+	}
+	if params["dataId"] == "" {
+		return nil, errors.New("nacos dataId not set")
+	}
+	group := params["group"]
+	if group == "" {
+		group = "DEFAULT_GROUP"
+	}
+	query := url.Values{"dataId": {params["dataId"]}, "group": {group}}
+	if params["namespace"] != "" {
+		query.Set("tenant", params["namespace"])
+	}
+	return &nacosCenter{url: address + "/nacos/v1/cs/configs?" + query.Encode()}, nil
+}
+
+func (n *nacosCenter) Fetch() ([]byte, error) {
+	return httpGet(n.url)
+}
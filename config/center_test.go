@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testConfigServer struct {
+	lock    sync.Mutex
+	content string
+	fail    bool
+}
+
+func (s *testConfigServer) set(content string, fail bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.content, s.fail = content, fail
+}
+
+func (s *testConfigServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch r.URL.Path {
+	case "/configfiles/json/motan/default/application.yaml":
+		b, _ := json.Marshal(map[string]string{"content": s.content})
+		w.Write(b)
+	case "/nacos/v1/cs/configs":
+		q := r.URL.Query()
+		if q.Get("dataId") != "motan.yaml" || q.Get("group") != "DEFAULT_GROUP" || q.Get("tenant") != "test" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(s.content))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRemoteConfig(t *testing.T) {
+	s := &testConfigServer{content: "motan-refer:\n  test:\n    path: test.service\n"}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	for _, params := range []map[string]string{
+		{CenterProtocolKey: "apollo", CenterAddressKey: server.URL, "appId": "motan"},
+		{CenterProtocolKey: "nacos", CenterAddressKey: server.URL[len("http://"):], "dataId": "motan.yaml", "namespace": "test"},
+	} {
+		r, err := GetRemoteConfig(params)
+		if err != nil {
+			t.Fatalf("get remote config fail. params:%v, err:%v", params, err)
+		}
+		if r2, _ := GetRemoteConfig(params); r2 != r {
+			t.Errorf("remote config should be shared by the same params")
+		}
+		s.set("motan-refer:\n  test:\n    path: test.service\n", false)
+		c, err := r.Load()
+		if err != nil {
+			t.Fatalf("load remote config fail. params:%v, err:%v", params, err)
+		}
+		if section, _ := c.GetSection("motan-refer"); section == nil || section["test"] == nil {
+			t.Errorf("wrong remote config. config:%v", c.GetOriginMap())
+		}
+		s.set("", true)
+		if c, err = r.Load(); err != nil || c.GetOriginMap()["motan-refer"] == nil {
+			t.Errorf("the last config should be used if the config center fails. err:%v", err)
+		}
+
+		changed := make(chan struct{}, 1)
+		r.interval = 10 * time.Millisecond
+		r.Watch(func() { changed <- struct{}{} })
+		time.Sleep(30 * time.Millisecond)
+		if len(changed) != 0 {
+			t.Errorf("listener should not be notified if the config center fails")
+		}
+		s.set("motan-refer: {}\n", false)
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+			t.Errorf("listener should be notified if the config changed")
+		}
+	}
+	if _, err := GetRemoteConfig(map[string]string{CenterProtocolKey: "unknown"}); err == nil {
+		t.Errorf("unknown config center protocol should fail")
+	}
+	if _, err := GetRemoteConfig(map[string]string{CenterProtocolKey: "nacos", CenterAddressKey: server.URL}); err == nil {
+		t.Errorf("nacos without dataId should fail")
+	}
+}
@@ -29,10 +29,17 @@ func NewConfigFromFile(path string) (*Config, error) {
 		return nil, errors.New("read config file fail. " + err.Error())
 	}
 	fmt.Printf("start parse config path:%s \n", path)
-	m := make(map[interface{}]interface{})
-	err = yaml.Unmarshal([]byte(data), &m)
+	c, err := NewConfigFromBytes(data)
 	if err != nil {
-		fmt.Printf("config unmarshal failed. " + err.Error())
+		fmt.Println(err.Error())
+	}
+	return c, err
+}
+
+// NewConfigFromBytes parse config from yaml content.
+func NewConfigFromBytes(data []byte) (*Config, error) {
+	m := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(data, &m); err != nil {
 		return nil, errors.New("config unmarshal failed. " + err.Error())
 	}
 	return &Config{conf: m}, nil
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/mitchellh/mapstructure"
@@ -107,3 +110,39 @@ func Test_Merge(t *testing.T) {
 	}
 
 }
+
+func Test_ExpandPlaceholders(t *testing.T) {
+	os.Setenv("MOTAN_TEST_HOST", "10.0.0.1")
+	os.Setenv("MOTAN_TEST_PORT", "9981")
+	defer os.Unsetenv("MOTAN_TEST_HOST")
+	defer os.Unsetenv("MOTAN_TEST_PORT")
+	f, _ := ioutil.TempFile("", "motan-secret-*")
+	defer os.Remove(f.Name())
+	f.WriteString("0123\n")
+	f.Close()
+	RegisterSecretProvider("test", SecretProviderFunc(func(key string) (string, error) {
+		if key == "db#password" {
+			return "pass", nil
+		}
+		return "", errors.New("not found")
+	}))
+
+	c, _ := NewConfigFromBytes([]byte("section:\n  host: ${MOTAN_TEST_HOST}\n  port: ${MOTAN_TEST_PORT}\n" +
+		"  address: \"${MOTAN_TEST_HOST}:${MOTAN_TEST_PORT}\"\n  group: ${MOTAN_TEST_GROUP:-default-group}\n" +
+		"  file: ${file:" + f.Name() + "}\n  list:\n    - ${test:db#password}\n  missing: ${test:none}\n  unknown: ${MOTAN_TEST_UNKNOWN}\n"))
+	errs := c.ExpandPlaceholders()
+	if len(errs) != 2 {
+		t.Errorf("the unresolved placeholders should be returned. errs:%v", errs)
+	}
+	s, _ := c.GetSection("section")
+	expects := map[string]interface{}{"host": "10.0.0.1", "port": 9981, "address": "10.0.0.1:9981", "group": "default-group",
+		"file": "0123", "missing": "${test:none}", "unknown": "${MOTAN_TEST_UNKNOWN}"}
+	for k, v := range expects {
+		if s[k] != v {
+			t.Errorf("wrong expanded value. key:%s, value:%v, expect:%v", k, s[k], v)
+		}
+	}
+	if l, _ := s["list"].([]interface{}); len(l) != 1 || l[0] != "pass" {
+		t.Errorf("wrong expanded list. list:%v", s["list"])
+	}
+}
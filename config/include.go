@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// IncludeKey is the top level key of the files included, a path or a list of paths relative to the including
+	// file, the glob patterns like conf.d/*.yaml are matched in the lexical order
+	IncludeKey = "include"
+
+	maxIncludeDepth = 8
+)
+
+// NewConfigWithIncludes parses the config file with the files included, and merges the overlay of the env over it
+// if the env is not empty and the overlay exists, e.g. agent.prod.yaml for agent.yaml.
+// the files are merged in order: the files included in the listed order, the file itself, then the overlay.
+// the later maps are merged into the former key by key, the later values replace the former ones and the lists are appended
+func NewConfigWithIncludes(path string, env string) (*Config, error) {
+	c, err := loadWithIncludes(path, make(map[string]bool), 0)
+	if err != nil {
+		return nil, err
+	}
+	if env != "" {
+		ext := filepath.Ext(path)
+		overlay := strings.TrimSuffix(path, ext) + "." + env + ext
+		if _, err := os.Stat(overlay); err == nil {
+			oc, err := loadWithIncludes(overlay, make(map[string]bool), 0)
+			if err != nil {
+				return nil, err
+			}
+			c.Merge(oc)
+		}
+	}
+	return c, nil
+}
+
+func loadWithIncludes(path string, loading map[string]bool, depth int) (*Config, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if loading[abs] {
+		return nil, errors.New("config include cycle: " + path)
+	}
+	if depth > maxIncludeDepth {
+		return nil, errors.New("config includes are nested too deep: " + path)
+	}
+	loading[abs] = true
+	defer delete(loading, abs)
+
+	c, err := NewConfigFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	includes, err := includePaths(abs, c.conf[IncludeKey])
+	if err != nil {
+		return nil, err
+	}
+	delete(c.conf, IncludeKey)
+	if len(includes) == 0 {
+		return c, nil
+	}
+	merged := NewConfig()
+	for _, include := range includes {
+		ic, err := loadWithIncludes(include, loading, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		merged.Merge(ic)
+	}
+	merged.Merge(c)
+	return merged, nil
+}
+
+// includePaths returns the files included by the file, the glob patterns matching nothing are ignored
+func includePaths(file string, include interface{}) ([]string, error) {
+	var patterns []string
+	switch v := include.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		patterns = []string{v}
+	case []interface{}:
+		for _, p := range v {
+			s, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid include of %s: %v", file, p)
+			}
+			patterns = append(patterns, s)
+		}
+	default:
+		return nil, fmt.Errorf("invalid include of %s: %v", file, include)
+	}
+	paths := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include of %s: %v", file, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("include of %s not found: %s", file, pattern)
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
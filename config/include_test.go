@@ -0,0 +1,68 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewConfigWithIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "motan-include")
+	if err != nil {
+		t.Fatalf("create dir fail. err:%v", err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "conf.d"), 0755)
+	files := map[string]string{
+		"base.yaml":           "motan-agent:\n  port: 9981\n  mport: 8002\n  application: base\n",
+		"conf.d/b.yaml":       "motan-refer:\n  refer-b:\n    path: com.weibo.B\n    group: b\n",
+		"conf.d/a.yaml":       "motan-refer:\n  refer-b:\n    group: a\n  refer-a:\n    path: com.weibo.A\n",
+		"agent.yaml":          "include:\n  - base.yaml\n  - conf.d/*.yaml\nmotan-agent:\n  application: agent\n",
+		"agent.prod.yaml":     "include: prod/*.yaml\nmotan-agent:\n  mport: 9002\n",
+		"cycle.yaml":          "include: cycle-included.yaml\n",
+		"cycle-included.yaml": "include: cycle.yaml\n",
+		"missing.yaml":        "include: none.yaml\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write file fail. err:%v", err)
+		}
+	}
+
+	c, err := NewConfigWithIncludes(filepath.Join(dir, "agent.yaml"), "")
+	if err != nil {
+		t.Fatalf("load config with includes fail. err:%v", err)
+	}
+	if _, ok := c.GetOriginMap()[IncludeKey]; ok {
+		t.Errorf("include key should be removed")
+	}
+	agent, _ := c.GetSection("motan-agent")
+	if agent["port"] != 9981 || agent["mport"] != 8002 || agent["application"] != "agent" {
+		t.Errorf("the file should be merged over the includes. agent:%v", agent)
+	}
+	refers, _ := c.GetSection("motan-refer")
+	// conf.d/a.yaml is merged before conf.d/b.yaml
+	if b, _ := refers["refer-b"].(map[interface{}]interface{}); b["group"] != "b" || b["path"] != "com.weibo.B" || refers["refer-a"] == nil {
+		t.Errorf("wrong merge of the globbed includes. refers:%v", refers)
+	}
+
+	c, err = NewConfigWithIncludes(filepath.Join(dir, "agent.yaml"), "prod")
+	if err != nil {
+		t.Fatalf("load config with overlay fail. err:%v", err)
+	}
+	agent, _ = c.GetSection("motan-agent")
+	if agent["mport"] != 9002 || agent["port"] != 9981 {
+		t.Errorf("the overlay should be merged over the config. agent:%v", agent)
+	}
+	if _, err = NewConfigWithIncludes(filepath.Join(dir, "agent.yaml"), "test"); err != nil {
+		t.Errorf("the overlay not exist should be ignored. err:%v", err)
+	}
+
+	if _, err = NewConfigWithIncludes(filepath.Join(dir, "cycle.yaml"), ""); err == nil {
+		t.Errorf("include cycle should fail")
+	}
+	if _, err = NewConfigWithIncludes(filepath.Join(dir, "missing.yaml"), ""); err == nil {
+		t.Errorf("include not found should fail")
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SecretProvider resolves the placeholders like ${name:key} in the config values, the name is the name registered
+// by RegisterSecretProvider. e.g. ${file:/etc/motan/db_password}, or ${vault:secret/db#password} if a provider
+// named vault is registered
+type SecretProvider interface {
+	GetSecret(key string) (string, error)
+}
+
+// SecretProviderFunc adapts a function as a SecretProvider
+type SecretProviderFunc func(key string) (string, error)
+
+func (f SecretProviderFunc) GetSecret(key string) (string, error) {
+	return f(key)
+}
+
+var (
+	secretLock      sync.RWMutex
+	secretProviders = map[string]SecretProvider{
+		"file": SecretProviderFunc(readSecretFile),
+	}
+	placeholderRex = regexp.MustCompile(`\$\{([^}]+)}`)
+)
+
+// RegisterSecretProvider registers the provider of the placeholders ${name:key}, such as a vault client
+func RegisterSecretProvider(name string, provider SecretProvider) {
+	secretLock.Lock()
+	defer secretLock.Unlock()
+	secretProviders[name] = provider
+}
+
+func getSecretProvider(name string) SecretProvider {
+	secretLock.RLock()
+	defer secretLock.RUnlock()
+	return secretProviders[name]
+}
+
+// readSecretFile reads the secret from the file, the trailing line break is trimmed
+func readSecretFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// ExpandPlaceholders replaces the placeholders in all the string values, ${ENV_VAR} or ${ENV_VAR:-default} by the
+// environment variables and ${name:key} by the secret providers. a value which is a single placeholder is parsed
+// as a yaml scalar, so ${PORT} can be an int. the unresolved placeholders are kept and returned as the errors
+func (c *Config) ExpandPlaceholders() []error {
+	var errs []error
+	c.conf = expandValue(c.conf, &errs).(map[interface{}]interface{})
+	return errs
+}
+
+func expandValue(v interface{}, errs *[]error) interface{} {
+	switch t := v.(type) {
+	case string:
+		return expandString(t, errs)
+	case map[interface{}]interface{}:
+		for k, sv := range t {
+			t[k] = expandValue(sv, errs)
+		}
+	case []interface{}:
+		for i, sv := range t {
+			t[i] = expandValue(sv, errs)
+		}
+	}
+	return v
+}
+
+func expandString(s string, errs *[]error) interface{} {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	whole := false
+	result := placeholderRex.ReplaceAllStringFunc(s, func(placeholder string) string {
+		value, err := resolvePlaceholder(placeholder[2 : len(placeholder)-1])
+		if err != nil {
+			*errs = append(*errs, err)
+			return placeholder
+		}
+		whole = placeholder == s
+		return value
+	})
+	if whole {
+		return scalarValue(result)
+	}
+	return result
+}
+
+func resolvePlaceholder(name string) (string, error) {
+	if i := strings.Index(name, ":"); i > 0 {
+		if provider := getSecretProvider(name[:i]); provider != nil {
+			value, err := provider.GetSecret(name[i+1:])
+			if err != nil {
+				return "", fmt.Errorf("resolve secret %s fail: %v", name, err)
+			}
+			return value, nil
+		}
+	}
+	env, def, hasDefault := name, "", false
+	if i := strings.Index(name, ":-"); i > 0 {
+		env, def, hasDefault = name[:i], name[i+2:], true
+	}
+	if value, ok := os.LookupEnv(env); ok {
+		return value, nil
+	}
+	if hasDefault {
+		return def, nil
+	}
+	return "", fmt.Errorf("unresolved placeholder ${%s}", name)
+}
+
+// scalarValue parses the int, float and bool values, the other values such as "0123" are kept as strings
+func scalarValue(s string) interface{} {
+	if i, err := strconv.Atoi(s); err == nil && strconv.Itoa(i) == s {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == s {
+		return f
+	}
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"github.com/weibocom/motan-go/log"
+)
+
+// AuthKey is the url param of the auth name. the requests of the service are signed by the clients and authenticated
+// by the server with the auth registered by the name, no filter needs to be configured
+const AuthKey = "auth"
+
+// AuthPrincipalKey is the attachment of the caller identity set by the auth after authenticated, it takes precedence
+// over the application of the caller in the access control. the attachment sent by the client is removed
+const AuthPrincipalKey = "M_apr"
+
+// Auth signs the requests on the client side and authenticates them on the server side, such as by a token or a hmac
+// of the request set in the attachments
+type Auth interface {
+	Name
+	Sign(request Request) error
+	Authenticate(request Request) error
+}
+
+// authFilter calls the auth of the url, the requests failed to be signed or authenticated are responded with 401
+type authFilter struct {
+	extFactory ExtensionFactory
+	auth       Auth
+	next       EndPointFilter
+}
+
+func (a *authFilter) GetName() string {
+	return AuthKey
+}
+
+func (a *authFilter) NewFilter(url *URL) Filter {
+	auth := a.extFactory.GetAuth(url)
+	if auth == nil {
+		return nil
+	}
+	return &authFilter{extFactory: a.extFactory, auth: auth}
+}
+
+// GetIndex makes the auth filter inside the access log filter, so the rejected requests are logged
+func (a *authFilter) GetIndex() int {
+	return 2
+}
+
+func (a *authFilter) GetType() int32 {
+	return EndPointFilterType
+}
+
+func (a *authFilter) HasNext() bool {
+	return a.next != nil
+}
+
+func (a *authFilter) SetNext(nextFilter EndPointFilter) {
+	a.next = nextFilter
+}
+
+func (a *authFilter) GetNext() EndPointFilter {
+	return a.next
+}
+
+func (a *authFilter) Filter(caller Caller, request Request) Response {
+	if _, ok := caller.(Provider); ok {
+		if attachments := request.GetAttachments(); attachments != nil {
+			attachments.Delete(AuthPrincipalKey)
+		}
+		if err := a.auth.Authenticate(request); err != nil {
+			vlog.Warningw("authenticate request fail", vlog.String("auth", a.auth.GetName()), vlog.String("service", request.GetServiceName()),
+				vlog.String("method", request.GetMethod()), vlog.String("remote", request.GetAttachment(HostKey)), vlog.Err(err))
+			return BuildExceptionResponse(request.GetRequestID(), NewAuthFailureException("authenticate fail: "+err.Error()))
+		}
+	} else if err := a.auth.Sign(request); err != nil {
+		return BuildExceptionResponse(request.GetRequestID(), NewAuthFailureException("sign request fail: "+err.Error()))
+	}
+	return a.next.Filter(caller, request)
+}
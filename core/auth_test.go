@@ -0,0 +1,81 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tokenAuth struct {
+	token string
+}
+
+func (t *tokenAuth) GetName() string {
+	return "token"
+}
+
+func (t *tokenAuth) Sign(request Request) error {
+	if t.token == "" {
+		return errors.New("no token")
+	}
+	request.SetAttachment("auth-token", t.token)
+	return nil
+}
+
+func (t *tokenAuth) Authenticate(request Request) error {
+	if request.GetAttachment("auth-token") != t.token {
+		return errors.New("wrong token")
+	}
+	return nil
+}
+
+type authTestProvider struct {
+	TestEndPoint
+}
+
+func (a *authTestProvider) SetService(s interface{}) {}
+
+func (a *authTestProvider) GetPath() string {
+	return a.URL.Path
+}
+
+func TestAuthFilter(t *testing.T) {
+	ext := &DefaultExtensionFactory{}
+	ext.Initialize()
+	ext.RegistExtAuth("token", func(url *URL) Auth {
+		return &tokenAuth{token: url.GetParam("authToken", "")}
+	})
+
+	_, filters := GetURLFilters(&URL{Parameters: map[string]string{}}, ext)
+	assert.Empty(t, filters, "no auth filter without auth param")
+
+	clientURL := &URL{Path: "test", Parameters: map[string]string{AuthKey: "token", "authToken": "t1"}}
+	_, filters = GetURLFilters(clientURL, ext)
+	clientChain, _ := BuildEndPointFilterChain(clientURL, filters, nil)
+	request := &MotanRequest{ServiceName: "test", Method: "hello", Attachment: NewStringMap(0)}
+	res := clientChain.Filter(&TestEndPoint{URL: clientURL}, request)
+	assert.Nil(t, res.GetException())
+	assert.Equal(t, "t1", request.GetAttachment("auth-token"))
+
+	serverURL := &URL{Path: "test", Parameters: map[string]string{AuthKey: "token", "authToken": "t2"}}
+	_, filters = GetURLFilters(serverURL, ext)
+	serverChain, _ := BuildEndPointFilterChain(serverURL, filters, nil)
+	provider := &authTestProvider{TestEndPoint{URL: serverURL}}
+	res = serverChain.Filter(provider, request)
+	assert.Equal(t, ErrCodeAuthFailure, res.GetException().ErrCode)
+	request.SetAttachment("auth-token", "t2")
+	assert.Nil(t, serverChain.Filter(provider, request).GetException())
+
+	// the requests are not sent if signing failed
+	noTokenURL := &URL{Path: "test", Parameters: map[string]string{AuthKey: "token"}}
+	noTokenChain, _ := BuildEndPointFilterChain(noTokenURL, filters, nil)
+	res = noTokenChain.Filter(&TestEndPoint{URL: noTokenURL}, &MotanRequest{Attachment: NewStringMap(0)})
+	assert.Equal(t, ErrCodeAuthFailure, res.GetException().ErrCode)
+
+	// the unknown auth is ignored
+	unknownURL := &URL{Path: "test", Parameters: map[string]string{AuthKey: "unknown"}}
+	_, filters = GetURLFilters(unknownURL, ext)
+	unknownChain, _ := BuildEndPointFilterChain(unknownURL, filters, nil)
+	assert.Equal(t, GetLastEndPointFilter(), unknownChain)
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultBaggagePrefix is the prefix of the attachment keys propagated as the baggage, such as baggage-tenant
+const DefaultBaggagePrefix = "baggage-"
+
+var baggagePrefix atomic.Value // string
+
+type baggageKey struct{}
+
+// SetBaggagePrefix sets the prefix of the attachment keys propagated as the baggage, the baggage is disabled if empty
+func SetBaggagePrefix(prefix string) {
+	baggagePrefix.Store(prefix)
+}
+
+func GetBaggagePrefix() string {
+	if prefix, ok := baggagePrefix.Load().(string); ok {
+		return prefix
+	}
+	return DefaultBaggagePrefix
+}
+
+// WithBaggage returns the context carrying the baggage of the parent merged with the items
+func WithBaggage(ctx context.Context, items map[string]string) context.Context {
+	if len(items) == 0 {
+		return ctx
+	}
+	parent := BaggageFromContext(ctx)
+	baggage := make(map[string]string, len(parent)+len(items))
+	for k, v := range parent {
+		baggage[k] = v
+	}
+	for k, v := range items {
+		baggage[k] = v
+	}
+	return context.WithValue(ctx, baggageKey{}, baggage)
+}
+
+// BaggageFromContext returns the baggage carried by the context, the map should not be modified
+func BaggageFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	baggage, _ := ctx.Value(baggageKey{}).(map[string]string)
+	return baggage
+}
+
+// ExtractBaggage returns the attachments of the request with the baggage prefix
+func ExtractBaggage(request Request) map[string]string {
+	prefix := GetBaggagePrefix()
+	attachments := request.GetAttachments()
+	if prefix == "" || attachments == nil {
+		return nil
+	}
+	var baggage map[string]string
+	attachments.ForEach(func(k, v string) bool {
+		if strings.HasPrefix(k, prefix) {
+			if baggage == nil {
+				baggage = make(map[string]string)
+			}
+			baggage[k] = v
+		}
+		return true
+	})
+	return baggage
+}
+
+// InjectBaggage sets the baggage of the context as the attachments of the request, the attachments already set
+// are not overwritten
+func InjectBaggage(ctx context.Context, request Request) {
+	if GetBaggagePrefix() == "" {
+		return
+	}
+	for k, v := range BaggageFromContext(ctx) {
+		if request.GetAttachment(k) == "" {
+			request.SetAttachment(k, v)
+		}
+	}
+}
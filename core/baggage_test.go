@@ -0,0 +1,29 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaggage(t *testing.T) {
+	incoming := &MotanRequest{Attachment: NewStringMap(0)}
+	incoming.SetAttachment("baggage-tenant", "t1")
+	incoming.SetAttachment("baggage-gray", "true")
+	incoming.SetAttachment("M_p", "service")
+	ctx := WithBaggage(context.Background(), ExtractBaggage(incoming))
+	assert.Equal(t, map[string]string{"baggage-tenant": "t1", "baggage-gray": "true"}, BaggageFromContext(ctx))
+
+	ctx = WithBaggage(ctx, map[string]string{"baggage-gray": "false"})
+	outgoing := &MotanRequest{Attachment: NewStringMap(0)}
+	outgoing.SetAttachment("baggage-tenant", "t2")
+	InjectBaggage(ctx, outgoing)
+	assert.Equal(t, "t2", outgoing.GetAttachment("baggage-tenant"), "the attachment set by the caller is not overwritten")
+	assert.Equal(t, "false", outgoing.GetAttachment("baggage-gray"))
+	assert.Equal(t, "", outgoing.GetAttachment("M_p"))
+
+	SetBaggagePrefix("")
+	defer SetBaggagePrefix(DefaultBaggagePrefix)
+	assert.Nil(t, ExtractBaggage(incoming))
+}
@@ -14,6 +14,8 @@ type BytesBuffer struct {
 	wpos  int    // write position
 	order binary.ByteOrder
 	temp  []byte
+
+	pooled bool // buf is acquired from the bytes pool
 }
 
 var ErrNotEnough = errors.New("BytesBuffer: not enough bytes")
@@ -88,6 +90,16 @@ func (b *BytesBuffer) Write(bytes []byte) {
 	b.wpos += l
 }
 
+// WriteString write a string append the BytesBuffer without converting it to a byte array
+func (b *BytesBuffer) WriteString(s string) {
+	l := len(s)
+	if len(b.buf) < b.wpos+l {
+		b.grow(l)
+	}
+	copy(b.buf[b.wpos:], s)
+	b.wpos += l
+}
+
 // WriteUint16 write a uint16 append the BytesBuffer acording to buffer's order
 func (b *BytesBuffer) WriteUint16(u uint16) {
 	if len(b.buf) < b.wpos+2 {
@@ -137,6 +149,13 @@ func (b *BytesBuffer) WriteVarint(u uint64) int {
 }
 
 func (b *BytesBuffer) grow(n int) {
+	if b.pooled {
+		buf := AcquireBytes(2*len(b.buf) + n)
+		copy(buf, b.buf[:b.wpos])
+		ReleaseBytes(b.buf)
+		b.buf = buf[:cap(buf)]
+		return
+	}
 	buf := make([]byte, 2*len(b.buf)+n)
 	copy(buf, b.buf[:b.wpos])
 	b.buf = buf
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+const (
+	// byte slices are pooled in power of two size classes from 256B to 1MB,
+	// larger slices are allocated and released to gc directly
+	minPooledBytesShift = 8
+	maxPooledBytesShift = 20
+)
+
+var (
+	bytesPools       [maxPooledBytesShift - minPooledBytesShift + 1]sync.Pool
+	bytesBufferPool  = sync.Pool{New: func() interface{} { return &BytesBuffer{temp: make([]byte, 8)} }}
+	maxPooledBufSize = 1 << maxPooledBytesShift
+)
+
+func bytesClass(size int) int {
+	class := 0
+	for size > 1<<uint(class+minPooledBytesShift) {
+		class++
+	}
+	return class
+}
+
+// AcquireBytes gets a byte slice with length size from the pool, the content of the slice is not zeroed
+func AcquireBytes(size int) []byte {
+	if size > maxPooledBufSize {
+		return make([]byte, size)
+	}
+	class := bytesClass(size)
+	if b, ok := bytesPools[class].Get().(*[]byte); ok {
+		return (*b)[:size]
+	}
+	return make([]byte, size, 1<<uint(class+minPooledBytesShift))
+}
+
+// ReleaseBytes puts a byte slice acquired by AcquireBytes back to the pool.
+// the slice must not be used after released.
+func ReleaseBytes(b []byte) {
+	c := cap(b)
+	if c < 1<<minPooledBytesShift || c > maxPooledBufSize || c&(c-1) != 0 {
+		return
+	}
+	b = b[:c]
+	bytesPools[bytesClass(c)].Put(&b)
+}
+
+// AcquireBytesBuffer gets an empty BytesBuffer with big endian order from the pool
+func AcquireBytesBuffer(initsize int) *BytesBuffer {
+	b := bytesBufferPool.Get().(*BytesBuffer)
+	buf := AcquireBytes(initsize)
+	b.buf = buf[:cap(buf)]
+	b.order = binary.BigEndian
+	b.pooled = true
+	return b
+}
+
+// ReleaseBytesBuffer puts a BytesBuffer acquired by AcquireBytesBuffer back to the pool.
+// the buffer and the bytes returned by its Bytes() must not be used after released.
+func ReleaseBytesBuffer(b *BytesBuffer) {
+	if b == nil || !b.pooled {
+		return
+	}
+	ReleaseBytes(b.buf)
+	b.buf = nil
+	b.rpos, b.wpos = 0, 0
+	b.pooled = false
+	bytesBufferPool.Put(b)
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestAcquireBytes(t *testing.T) {
+	for _, size := range []int{0, 1, 256, 257, 4000, 1 << 20} {
+		b := AcquireBytes(size)
+		if len(b) != size || cap(b)&(cap(b)-1) != 0 {
+			t.Errorf("acquire bytes not correct. size:%d, len:%d, cap:%d", size, len(b), cap(b))
+		}
+		ReleaseBytes(b)
+	}
+	// larger than the max size class is not pooled
+	b := AcquireBytes(1<<20 + 1)
+	if len(b) != 1<<20+1 {
+		t.Errorf("acquire large bytes not correct. len:%d", len(b))
+	}
+	ReleaseBytes(b)
+	// slices not from the pool are ignored
+	ReleaseBytes(make([]byte, 300))
+}
+
+func TestPooledBytesBuffer(t *testing.T) {
+	buf := AcquireBytesBuffer(4)
+	for i := 0; i < 1000; i++ {
+		buf.WriteString("motan")
+		buf.WriteUint32(uint32(i))
+	}
+	if buf.Len() != 9000 {
+		t.Fatalf("pooled buffer length not correct. len:%d", buf.Len())
+	}
+	rb := CreateBytesBuffer(buf.Bytes())
+	for i := 0; i < 1000; i++ {
+		s, _ := rb.Next(5)
+		n, _ := rb.ReadUint32()
+		if string(s) != "motan" || n != uint32(i) {
+			t.Fatalf("pooled buffer content not correct. i:%d, s:%s, n:%d", i, s, n)
+		}
+	}
+	ReleaseBytesBuffer(buf)
+
+	buf = AcquireBytesBuffer(16)
+	if buf.Len() != 0 || buf.GetRPos() != 0 {
+		t.Errorf("acquired buffer should be empty. len:%d", buf.Len())
+	}
+	ReleaseBytesBuffer(buf)
+	// buffers not from the pool are ignored
+	ReleaseBytesBuffer(NewBytesBuffer(16))
+}
+
+func BenchmarkPooledBytesBuffer(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buf := AcquireBytesBuffer(1024)
+			buf.WriteString("benchmark")
+			ReleaseBytesBuffer(buf)
+		}
+	})
+}
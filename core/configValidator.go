@@ -0,0 +1,294 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// the levels of the config issues
+const (
+	ConfigError   = "error"
+	ConfigWarning = "warning"
+)
+
+var (
+	knownSections = map[string]bool{
+		registrysSection: true, basicRefersSection: true, refersSection: true, basicServicesSection: true,
+		servicesSection: true, agentSection: true, clientSection: true, serverSection: true, importSection: true,
+		dynamicSection: true, SwitcherSection: true, configCenterSection: true,
+		"motan-tenant": true, "motan-gateway": true, "http-service": true, "http-upstream": true, "metrics": true, "tracing": true,
+	}
+	// the keys of the process sections, the url fields and the keys below are also known
+	commonSectionKeys = []string{"log_dir", "access_log", "mport", "baggage_prefix", "request_id_generator", "object_pool", RegistryKey, ApplicationKey, FilterKey}
+	knownSectionKeys  = map[string][]string{
+		agentSection: {"port", "eport", "wsport", "ws_allowed_origins", "pidfile", "runtime_dir", "snapshot_dir", "max_connections",
+			"deserialize_max_bytes", "deserialize_max_string_length", "deserialize_max_collection_size", "deserialize_max_depth",
+			"decompress_max_bytes", "config_reload_interval", "admin_token", "discovery_cache_ttl", "discovery_cache_max_entries", "discovery_cache_dir",
+			"health_report_interval", "startup_min_endpoints", "startup_warmup_timeout", "switcher_persist", "pprof_enable", "pprof_token",
+			"auto_subscribe_basic_refer", "auto_subscribe_max_clusters", "auto_subscribe_idle_timeout"},
+		clientSection: {"generic_basic_refer"},
+		serverSection: {"deserialize_max_bytes", "deserialize_max_string_length", "deserialize_max_collection_size", "deserialize_max_depth", "decompress_max_bytes"},
+	}
+)
+
+// ConfigIssue is a problem of the config found by ValidateConfig
+type ConfigIssue struct {
+	Level   string `json:"level"` // ConfigError or ConfigWarning
+	Section string `json:"section"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+// ConfigReport is the result of ValidateConfig, the process should not start if it has errors
+type ConfigReport struct {
+	File   string         `json:"file"`
+	Issues []*ConfigIssue `json:"issues"`
+}
+
+func (r *ConfigReport) add(level string, section string, key string, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, &ConfigIssue{Level: level, Section: section, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *ConfigReport) HasError() bool {
+	for _, issue := range r.Issues {
+		if issue.Level == ConfigError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ConfigReport) String() string {
+	var b strings.Builder
+	errors := 0
+	for _, issue := range r.Issues {
+		if issue.Level == ConfigError {
+			errors++
+		}
+		fmt.Fprintf(&b, "[%s] %s", issue.Level, issue.Section)
+		if issue.Key != "" {
+			fmt.Fprintf(&b, ".%s", issue.Key)
+		}
+		fmt.Fprintf(&b, ": %s\n", issue.Message)
+	}
+	fmt.Fprintf(&b, "config %s: %d errors, %d warnings\n", r.File, errors, len(r.Issues)-errors)
+	return b.String()
+}
+
+// ValidateConfig checks the config of the initialized context without connecting the registries or opening the ports.
+// the sections and the keys unknown, the ports conflicting, the registries missing and the extensions not registered
+// in the factory, such as the filters, the protocols and the registry types, are reported
+func ValidateConfig(c *Context, ext ExtensionFactory) *ConfigReport {
+	r := &ConfigReport{File: c.ConfigFile, Issues: make([]*ConfigIssue, 0)}
+	if c.Config == nil {
+		r.add(ConfigError, "", "", "config is not loaded")
+		return r
+	}
+	var names map[string][]string
+	if f, ok := ext.(interface{ GetExtensionNames() map[string][]string }); ok {
+		names = f.GetExtensionNames()
+	}
+	validateSections(c, r)
+	validateRegistries(c, r, names)
+	for _, section := range []string{refersSection, servicesSection} {
+		urls := c.RefersURLs
+		if section == servicesSection {
+			urls = c.ServiceURLs
+		}
+		for _, id := range sortedKeys(urls) {
+			validateURL(c, r, section, id, urls[id], names)
+		}
+	}
+	validatePorts(c, r, names)
+	validateMetricsSinks(c, r, names)
+	sort.SliceStable(r.Issues, func(i, j int) bool {
+		return r.Issues[i].Level == ConfigError && r.Issues[j].Level != ConfigError
+	})
+	return r
+}
+
+func validateSections(c *Context, r *ConfigReport) {
+	for k := range c.Config.GetOriginMap() {
+		if name, ok := k.(string); ok && !knownSections[name] {
+			r.add(ConfigWarning, name, "", "unknown section, ignored unless it is used by the extensions")
+		}
+	}
+	for section, keys := range knownSectionKeys {
+		conf, _ := c.Config.GetSection(section)
+		known := make(map[string]bool)
+		for _, k := range append(keys, commonSectionKeys...) {
+			known[k] = true
+		}
+		for k := range conf {
+			if key := InterfaceToString(k); !known[key] && !urlFields[key] {
+				r.add(ConfigWarning, section, key, "unknown key, used as a param of the url of the process")
+			}
+		}
+	}
+	for _, section := range []string{refersSection, servicesSection} {
+		basicKey, basics := basicReferKey, c.BasicReferURLs
+		if section == servicesSection {
+			basicKey, basics = basicServiceKey, c.BasicServiceURLs
+		}
+		conf, _ := c.Config.GetSection(section)
+		for k, v := range conf {
+			m, _ := v.(map[interface{}]interface{})
+			if name, ok := m[basicKey]; ok && basics[InterfaceToString(name)] == nil {
+				r.add(ConfigError, section, InterfaceToString(k), "%s not found: %v", basicKey, name)
+			}
+		}
+	}
+}
+
+func validateRegistries(c *Context, r *ConfigReport, names map[string][]string) {
+	for _, id := range sortedKeys(c.RegistryURLs) {
+		url := c.RegistryURLs[id]
+		if url.Protocol == "" {
+			r.add(ConfigError, registrysSection, id, "protocol is required")
+		} else if names != nil && !contains(names["registry"], url.Protocol) {
+			r.add(ConfigError, registrysSection, id, "registry type not registered: %s", url.Protocol)
+		}
+	}
+	for section, url := range map[string]*URL{agentSection: c.AgentURL, clientSection: c.ClientURL, serverSection: c.ServerURL} {
+		if url != nil {
+			validateRegistryIDs(c, r, section, RegistryKey, url)
+		}
+	}
+}
+
+func validateRegistryIDs(c *Context, r *ConfigReport, section string, key string, url *URL) {
+	for _, id := range splitNames(url.GetParam(RegistryKey, "")) {
+		if _, ok := c.RegistryURLs[id]; !ok {
+			r.add(ConfigError, section, key, "registry not found in %s: %s", registrysSection, id)
+		}
+	}
+}
+
+func validateURL(c *Context, r *ConfigReport, section string, id string, url *URL, names map[string][]string) {
+	if url.Path == "" {
+		r.add(ConfigError, section, id, "path is required")
+	}
+	if url.GetParam(RegistryKey, "") == "" && url.Host == "" {
+		r.add(ConfigWarning, section, id, "no registry, the url is neither registered nor discovered")
+	}
+	validateRegistryIDs(c, r, section, id, url)
+	if names == nil {
+		return
+	}
+	for _, f := range splitNames(url.GetParam(FilterKey, "")) {
+		if !contains(names["filter"], f) {
+			r.add(ConfigError, section, id, "filter not registered: %s", f)
+		}
+	}
+	checks := map[string]string{Hakey: "ha", Lbkey: "lb", SerializationKey: "serialization", ProviderKey: "provider"}
+	for _, key := range []string{Hakey, Lbkey, SerializationKey, ProviderKey} {
+		if v := url.GetParam(key, ""); v != "" && !contains(names[checks[key]], v) {
+			r.add(ConfigError, section, id, "%s not registered: %s", key, v)
+		}
+	}
+	if section == refersSection && url.Protocol != "" && !contains(names["endpoint"], url.Protocol) {
+		r.add(ConfigError, section, id, "protocol not registered: %s", url.Protocol)
+	}
+}
+
+func validateMetricsSinks(c *Context, r *ConfigReport, names map[string][]string) {
+	section, _ := c.Config.GetSection("metrics")
+	sinks, _ := section["sinks"].([]interface{})
+	for i, s := range sinks {
+		conf, _ := s.(map[interface{}]interface{})
+		protocol := InterfaceToString(conf["protocol"])
+		if protocol == "" {
+			r.add(ConfigError, "metrics", "sinks", "protocol of the sink %d is required", i)
+		} else if names != nil && !contains(names["metricsSink"], protocol) {
+			r.add(ConfigError, "metrics", "sinks", "metrics sink not registered: %s", protocol)
+		}
+	}
+}
+
+// validatePorts reports the ports configured for more than one purpose, the services can share a port of the same protocol
+func validatePorts(c *Context, r *ConfigReport, names map[string][]string) {
+	owners := make(map[int]string)
+	use := func(port int, owner string, section string, key string) {
+		if port <= 0 {
+			return
+		}
+		if o, ok := owners[port]; ok && o != owner {
+			r.add(ConfigError, section, key, "port %d conflicts with %s", port, o)
+			return
+		}
+		owners[port] = owner
+	}
+	for _, section := range []string{agentSection, clientSection, serverSection} {
+		conf, _ := c.Config.GetSection(section)
+		for _, key := range []string{"port", "eport", "mport", "wsport"} {
+			if port, ok := conf[key].(int); ok {
+				use(port, section+"."+key, section, key)
+			}
+		}
+	}
+	if conf, _ := c.Config.GetSection("motan-gateway"); conf != nil {
+		if port, ok := conf["port"].(int); ok {
+			use(port, "motan-gateway.port", "motan-gateway", "port")
+		}
+	}
+	tenants, _ := c.Config.GetSection("motan-tenant")
+	for k, v := range tenants {
+		if m, ok := v.(map[interface{}]interface{}); ok {
+			if port, ok := m["port"].(int); ok {
+				use(port, "motan-tenant."+InterfaceToString(k), "motan-tenant", InterfaceToString(k))
+			}
+		}
+	}
+	for _, id := range sortedKeys(c.ServiceURLs) {
+		url := c.ServiceURLs[id]
+		if url.GetParam(ExportKey, "") == "" {
+			r.add(ConfigError, servicesSection, id, "export is required")
+			continue
+		}
+		urls, err := ExportURLs(url)
+		if err != nil {
+			r.add(ConfigError, servicesSection, id, "invalid export: %v", err)
+			continue
+		}
+		for _, u := range urls {
+			protocol, port, err := ParseExportInfo(u.GetParam(ExportKey, ""))
+			if err != nil {
+				r.add(ConfigError, servicesSection, id, "invalid export: %s", u.GetParam(ExportKey, ""))
+				continue
+			}
+			if names != nil && !contains(names["server"], protocol) {
+				r.add(ConfigError, servicesSection, id, "export protocol not registered: %s", protocol)
+			}
+			use(port, "the services exported by "+protocol, servicesSection, id)
+		}
+	}
+}
+
+func sortedKeys(urls map[string]*URL) []string {
+	keys := make([]string, 0, len(urls))
+	for k := range urls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func splitNames(s string) []string {
+	names := make([]string, 0)
+	for _, name := range TrimSplit(s, ",") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "motan-validate-*.yaml")
+	if err != nil {
+		t.Fatalf("create config file fail. err:%v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`
+motan-agent:
+  port: 9981
+  mport: 8002
+  registry: "direct"
+  unknown_key: 1
+motan-registry:
+  direct:
+    protocol: direct
+  unknown:
+    protocol: etcd
+motan-refer:
+  ok-refer:
+    path: com.weibo.Ok
+    registry: direct
+    protocol: motan2
+    filter: "accessLog"
+    haStrategy: failover
+  bad-refer:
+    path: com.weibo.Bad
+    registry: "direct,missing"
+    protocol: motan2
+    filter: "accessLog,noFilter"
+    loadbalance: noLb
+motan-service:
+  ok-service:
+    path: com.weibo.Ok
+    registry: direct
+    export: "motan2:8100"
+  same-port-service:
+    path: com.weibo.Same
+    registry: direct
+    export: "motan2:8100"
+  conflict-service:
+    path: com.weibo.Conflict
+    registry: direct
+    export: "motan2:8002"
+motan-unknown:
+  key: value
+metrics:
+  sinks:
+    - protocol: kafka
+`)
+	f.Close()
+
+	ctx := &Context{ConfigFile: f.Name()}
+	ctx.Initialize()
+	ext := &DefaultExtensionFactory{}
+	ext.Initialize()
+	ext.RegistExtFilter("accessLog", func() Filter { return nil })
+	ext.RegistExtHa("failover", func(url *URL) HaStrategy { return nil })
+	ext.RegistExtLb("random", func(url *URL) LoadBalance { return nil })
+	ext.RegistExtEndpoint("motan2", func(url *URL) EndPoint { return nil })
+	ext.RegistExtRegistry("direct", func(url *URL) Registry { return nil })
+	ext.RegistExtServer("motan2", func(url *URL) Server { return nil })
+
+	report := ValidateConfig(ctx, ext)
+	if !report.HasError() {
+		t.Fatalf("config should have errors. report:%s", report)
+	}
+	expects := []string{
+		"[error] motan-registry.unknown: registry type not registered: etcd",
+		"[error] motan-refer.bad-refer: registry not found in motan-registry: missing",
+		"[error] motan-refer.bad-refer: filter not registered: noFilter",
+		"[error] motan-refer.bad-refer: loadbalance not registered: noLb",
+		"[error] motan-service.conflict-service: port 8002 conflicts with motan-agent.mport",
+		"[error] metrics.sinks: metrics sink not registered: kafka",
+		"[warning] motan-agent.unknown_key: unknown key",
+		"[warning] motan-unknown: unknown section",
+	}
+	s := report.String()
+	for _, e := range expects {
+		if !strings.Contains(s, e) {
+			t.Errorf("report should contain %q. report:%s", e, s)
+		}
+	}
+	for _, issue := range report.Issues {
+		if issue.Key == "ok-refer" || issue.Key == "ok-service" || issue.Key == "same-port-service" {
+			t.Errorf("valid url should not be reported. issue:%+v", issue)
+		}
+	}
+	if !strings.HasSuffix(s, "6 errors, 2 warnings\n") {
+		t.Errorf("wrong summary of the report. report:%s", s)
+	}
+}
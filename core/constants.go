@@ -2,7 +2,7 @@ package core
 
 import "time"
 
-//--------------all global public constants--------------
+// --------------all global public constants--------------
 // exception type
 const (
 	FrameworkException = iota
@@ -44,6 +44,51 @@ const (
 	HostKey           = "host"
 	RemoteIPKey       = "remoteIP"
 	ProxyRegistryKey  = "proxyRegistry"
+	TransportKey      = "transport"
+	DialProxyKey      = "dialProxy"
+	MaxFrameSizeKey   = "maxFrameSize"
+	// the max body size(bytes) of a message reassembled from the chunks, the connection is closed if exceeded
+	MaxMessageSizeKey = "maxMessageSize"
+	// the max requests of a batch request, and the max requests of a batch processed concurrently by the server
+	MaxBatchSizeKey     = "maxBatchSize"
+	BatchConcurrencyKey = "batchConcurrency"
+	CompressKey         = "compress"
+	TranscodeKey        = "transcode"
+	// the refer belongs to the namespace of the agent tenant, which is the application sharing the agent
+	TenantKey = "tenant"
+	// worker pool of the exported service, requests are processed in a new goroutine each if MaxWorkersKey is not set
+	MinWorkersKey         = "minWorkers"
+	MaxWorkersKey         = "maxWorkers"
+	WorkerQueueSizeKey    = "workerQueueSize"
+	WorkerQueueTimeoutKey = "workerQueueTimeout"
+	// overload protection of the server, requests are partly rejected if any threshold is exceeded
+	OverloadMaxCPUKey          = "overloadMaxCPU"
+	OverloadMaxGoroutinesKey   = "overloadMaxGoroutines"
+	OverloadMaxQueueLatencyKey = "overloadMaxQueueLatency"
+	// connection limits of the server, new connections exceeding the limits are closed at once
+	MaxConnectionsKey      = "maxConnections"
+	MaxConnectionsPerIPKey = "maxConnectionsPerIP"
+	// timeouts of the server connections in milliseconds. readTimeout limits reading a frame after it arrives,
+	// idleTimeout closes the connections receiving nothing without requests being processed
+	ReadTimeoutKey  = "readTimeout"
+	WriteTimeoutKey = "writeTimeout"
+	IdleTimeoutKey  = "idleTimeout"
+	// the lazy exported service binds its port at once, but registers to the registries after MSContext.Ready
+	LazyExportKey = "lazyExport"
+	// the server records the sampled requests into the dir for replaying, rate in percent and max size in MB
+	RecordDirKey     = "recordDir"
+	RecordRateKey    = "recordRate"
+	RecordMaxSizeKey = "recordMaxSize"
+	// comma separated methods of the exported service can or can not be called
+	ExportMethodsKey  = "exportMethods"
+	ExcludeMethodsKey = "excludeMethods"
+	// the applications can call the methods of the exported service, like "app1:Hello,World;app2:*"
+	ACLKey = "acl"
+	// instances of the pool provider, and the max time in milliseconds to wait for an idle instance
+	ProviderPoolSizeKey        = "providerPoolSize"
+	ProviderPoolWaitTimeoutKey = "providerPoolWaitTimeout"
+	// seconds, the cached discovery results of the refer are used in the time if the registry discovers nothing
+	DiscoveryCacheTTLKey = "discoveryCacheTTL"
 )
 
 // nodeType
@@ -55,9 +100,13 @@ const (
 
 // trace span name
 const (
+	Accept        = "accept" // the connection of the request was accepted by the server
 	Receive       = "receive"
 	Decode        = "decode"
+	Dequeue       = "dequeue" // the server starts processing the request after queueing
 	Convert       = "convert"
+	HandlerStart  = "handlerStart"
+	HandlerEnd    = "handlerEnd"
 	ClustFliter   = "clustFilter"
 	EpFilterStart = "selectEp"
 	EpFilterEnd   = "epFilter"
@@ -65,6 +114,13 @@ const (
 	Send          = "send"
 )
 
+// the values of the TraceContext set by the server
+const (
+	TraceServiceKey = "service"
+	TraceMethodKey  = "method"
+	TraceGroupKey   = "group"
+)
+
 const (
 	DefaultWriteTimeout = 5 * time.Second
 )
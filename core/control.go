@@ -0,0 +1,21 @@
+package core
+
+import "time"
+
+// the kinds of the control messages pushed by the servers, the servers may push the other kinds
+const (
+	ControlWeight        = "weight"         // the payload is the new weight of the server
+	ControlDrain         = "drain"          // the server is going to stop, the clients should stop sending requests
+	ControlConfigVersion = "config_version" // the payload is the config version of the server
+)
+
+// ControlMessage is a small message pushed by a server to its connected clients, it is not a response of any request
+type ControlMessage struct {
+	Kind     string
+	Payload  []byte
+	Address  string // the address of the server
+	Received time.Time
+}
+
+// ControlListener receives the control messages, it is called in a new goroutine for each message
+type ControlListener func(msg *ControlMessage)
@@ -3,6 +3,7 @@ package core
 import (
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,8 +17,25 @@ var (
 
 	once   sync.Once
 	holder *traceHolder
+
+	traceExporter atomic.Value // exporterHolder
 )
 
+// TraceExporter ships the finished trace contexts to a tracing system such as jaeger or zipkin,
+// Export is called in the request goroutine so it must not block
+type TraceExporter interface {
+	Export(tc *TraceContext)
+}
+
+type exporterHolder struct {
+	exporter TraceExporter
+}
+
+// SetTraceExporter sets the exporter of the finished trace contexts, nil to stop exporting
+func SetTraceExporter(exporter TraceExporter) {
+	traceExporter.Store(exporterHolder{exporter: exporter})
+}
+
 type TracePolicyFunc func(rid uint64, ext *StringMap) *TraceContext
 
 // NoTrace : not trace. default trace policy.
@@ -34,6 +52,17 @@ func RandomTrace(rid uint64, ext *StringMap) *TraceContext {
 	return nil
 }
 
+// SampleTrace : trace the requests by the rate in [0, 1]. the trace contexts are not held for the mesh trace,
+// they are exported by the TraceExporter when finished
+func SampleTrace(rate float64) TracePolicyFunc {
+	return func(rid uint64, ext *StringMap) *TraceContext {
+		if rate <= 0 || rate < 1 && rand.Float64() >= rate {
+			return nil
+		}
+		return newTraceContext(rid)
+	}
+}
+
 // AlwaysTrace : trace every request unless the tracecontext size over MaxTraceSize.
 func AlwaysTrace(rid uint64, ext *StringMap) *TraceContext {
 	return NewTraceContext(rid)
@@ -53,10 +82,7 @@ func (t *traceHolder) newTrace(rid uint64) *TraceContext {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 	if t.size <= t.max {
-		tc := &TraceContext{Rid: rid,
-			ReqSpans: make([]*Span, 0, 16),
-			ResSpans: make([]*Span, 0, 16),
-			Values:   make(map[string]interface{}, 16)}
+		tc := newTraceContext(rid)
 		t.tcs = append(t.tcs, tc)
 		t.size++
 		return tc
@@ -80,6 +106,13 @@ type Span struct {
 	Duration int64     `json:"duration"`
 }
 
+func newTraceContext(rid uint64) *TraceContext {
+	return &TraceContext{Rid: rid,
+		ReqSpans: make([]*Span, 0, 16),
+		ResSpans: make([]*Span, 0, 16),
+		Values:   make(map[string]interface{}, 16)}
+}
+
 // NewTraceContext : create a new TraceContext and hold to holder. it will return nil, if TraceContext size of holder is over MaxTraceSize.
 func NewTraceContext(rid uint64) *TraceContext {
 	once.Do(func() {
@@ -102,6 +135,20 @@ func (t *TraceContext) PutResSpan(span *Span) {
 	t.ResSpans = append(t.ResSpans, span)
 }
 
+// Finish : the request of the trace is finished, export the TraceContext if a TraceExporter is set
+func (t *TraceContext) Finish() {
+	if h, ok := traceExporter.Load().(exporterHolder); ok && h.exporter != nil {
+		h.exporter.Export(t)
+	}
+}
+
+// Spans : the request spans and the response spans, copied to be read while the request is processing
+func (t *TraceContext) Spans() (reqSpans []*Span, resSpans []*Span) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]*Span(nil), t.ReqSpans...), append([]*Span(nil), t.ResSpans...)
+}
+
 // GetTraceContexts get && remove all TraceContext in holder, and create a new TraceContext holder.
 func GetTraceContexts() []*TraceContext {
 	temp := holder
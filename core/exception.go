@@ -0,0 +1,115 @@
+package core
+
+// the error codes of Exception.ErrCode. the codes follow the meanings of the http status codes, the codes of
+// circuit open and overload are outside the standard ones. the providers of http and cgi may return the other
+// http status codes, which are classified as the client or the server errors by the range
+const (
+	ErrCodeBadRequest    = 400 // the default code of the client errors, such as no endpoint available
+	ErrCodeAuthFailure   = 401 // the request is not authenticated
+	ErrCodeForbidden     = 403 // the method is not exported or the caller is not allowed
+	ErrCodeNotFound      = 404 // the service, the method or the provider is not found
+	ErrCodeClientTimeout = 408 // the response is not received in the request timeout or the deadline of the caller
+	ErrCodeSerialization = 422 // the request or the response can not be encoded or decoded
+	ErrCodeReject        = 429 // the request is rejected by the rate limit
+	ErrCodeCircuitOpen   = 460 // the request is not sent as the circuit breaker of the endpoint is open
+	ErrCodeInternal      = 500 // the default code of the server errors, such as the panics of the providers
+	ErrCodeServerBusy    = 503 // the worker pool of the service is full or the provider is unavailable
+	ErrCodeServerTimeout = 504 // the deadline of the request has passed before it is processed by the server
+	ErrCodeOverload      = 529 // the request is rejected by the overload protection of the server
+)
+
+// the kinds of the error codes by ErrorKind, which are the labels of the error metrics
+var errorKinds = map[int]string{
+	ErrCodeBadRequest:    "bad_request",
+	ErrCodeAuthFailure:   "auth_failure",
+	ErrCodeForbidden:     "forbidden",
+	ErrCodeNotFound:      "not_found",
+	ErrCodeClientTimeout: "client_timeout",
+	ErrCodeSerialization: "serialization",
+	ErrCodeReject:        "reject",
+	ErrCodeCircuitOpen:   "circuit_open",
+	ErrCodeInternal:      "internal",
+	ErrCodeServerBusy:    "server_busy",
+	ErrCodeServerTimeout: "server_timeout",
+	ErrCodeOverload:      "overload",
+}
+
+// NewException returns the service exception of the code
+func NewException(code int, msg string) *Exception {
+	return &Exception{ErrCode: code, ErrMsg: msg, ErrType: ServiceException}
+}
+
+// NewBizException returns the exception of the errors returned by the service implements
+func NewBizException(msg string) *Exception {
+	return &Exception{ErrCode: ErrCodeInternal, ErrMsg: msg, ErrType: BizException}
+}
+
+func NewClientTimeoutException(msg string) *Exception {
+	return NewException(ErrCodeClientTimeout, msg)
+}
+
+func NewServerTimeoutException(msg string) *Exception {
+	return NewException(ErrCodeServerTimeout, msg)
+}
+
+func NewRejectException(msg string) *Exception {
+	return NewException(ErrCodeReject, msg)
+}
+
+func NewCircuitOpenException(msg string) *Exception {
+	return NewException(ErrCodeCircuitOpen, msg)
+}
+
+func NewSerializationException(msg string) *Exception {
+	return NewException(ErrCodeSerialization, msg)
+}
+
+func NewAuthFailureException(msg string) *Exception {
+	return NewException(ErrCodeAuthFailure, msg)
+}
+
+func NewOverloadException(msg string) *Exception {
+	return NewException(ErrCodeOverload, msg)
+}
+
+func NewServerBusyException(msg string) *Exception {
+	return NewException(ErrCodeServerBusy, msg)
+}
+
+// IsRetriable tells whether the request may succeed by another endpoint. the business errors and the errors
+// resulting in the same failure on any endpoint are not retriable, such as the auth failures, the serialization
+// errors and the expired deadlines
+func IsRetriable(e *Exception) bool {
+	if e == nil || e.ErrType == BizException {
+		return false
+	}
+	switch e.ErrCode {
+	case ErrCodeAuthFailure, ErrCodeForbidden, ErrCodeSerialization, ErrCodeServerTimeout:
+		return false
+	}
+	return true
+}
+
+// IsServerUnavailable tells whether the server can not process the requests for now, the endpoint should be
+// checked by the keepalive
+func IsServerUnavailable(e *Exception) bool {
+	return e != nil && (e.ErrCode == ErrCodeServerBusy || e.ErrCode == ErrCodeOverload)
+}
+
+// ErrorKind returns the kind of the exception like client_timeout, biz for the business errors and
+// client_error or server_error for the other codes
+func ErrorKind(e *Exception) string {
+	if e == nil {
+		return ""
+	}
+	if e.ErrType == BizException {
+		return "biz"
+	}
+	if kind, ok := errorKinds[e.ErrCode]; ok {
+		return kind
+	}
+	if e.ErrCode >= 500 {
+		return "server_error"
+	}
+	return "client_error"
+}
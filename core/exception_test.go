@@ -0,0 +1,33 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetriable(t *testing.T) {
+	assert.False(t, IsRetriable(nil))
+	assert.False(t, IsRetriable(NewBizException("biz")))
+	assert.False(t, IsRetriable(NewSerializationException("decode fail")))
+	assert.False(t, IsRetriable(NewAuthFailureException("bad token")))
+	assert.False(t, IsRetriable(NewServerTimeoutException("deadline exceeded")))
+	assert.True(t, IsRetriable(NewClientTimeoutException("timeout")))
+	assert.True(t, IsRetriable(NewCircuitOpenException("circuit open")))
+	assert.True(t, IsRetriable(NewOverloadException("overload")))
+	assert.True(t, IsRetriable(&Exception{ErrCode: 502, ErrMsg: "bad gateway", ErrType: ServiceException}))
+
+	assert.True(t, IsServerUnavailable(NewServerBusyException("busy")))
+	assert.True(t, IsServerUnavailable(NewOverloadException("overload")))
+	assert.False(t, IsServerUnavailable(NewException(ErrCodeInternal, "panic")))
+}
+
+func TestErrorKind(t *testing.T) {
+	assert.Equal(t, "", ErrorKind(nil))
+	assert.Equal(t, "biz", ErrorKind(NewBizException("biz")))
+	assert.Equal(t, "client_timeout", ErrorKind(NewClientTimeoutException("timeout")))
+	assert.Equal(t, "reject", ErrorKind(NewRejectException("rate limited")))
+	assert.Equal(t, "server_error", ErrorKind(NewException(502, "bad gateway")))
+	assert.Equal(t, "client_error", ErrorKind(NewException(413, "too large")))
+	assert.Equal(t, ServiceException, NewException(ErrCodeInternal, "panic").ErrType)
+}
@@ -0,0 +1,71 @@
+package core
+
+import "sync"
+
+// ExperimentKey is the url param of the refer naming the experiment assigner, the requests are assigned to the
+// variants by the assigner registered by the name and routed to the endpoints of the variants
+const ExperimentKey = "experiment"
+
+// VariantKey is the url param of the service tagging the providers with the variant of the experiment, such as "b".
+// the providers without the param serve the requests not in the experiment and the variants without providers
+const VariantKey = "variant"
+
+// the attachments recording the assignment of the request, they are propagated to the providers for the analytics
+const (
+	ExperimentAttachmentKey = "M_exp"
+	VariantAttachmentKey    = "M_var"
+)
+
+// ExperimentAssigner assigns the requests to the variants of an experiment, such as by the hash of a user id in the
+// attachments. an empty variant means the request is not in the experiment
+type ExperimentAssigner interface {
+	Assign(request Request) string
+}
+
+// ExperimentAssignerFunc is the ExperimentAssigner of a func
+type ExperimentAssignerFunc func(request Request) string
+
+func (f ExperimentAssignerFunc) Assign(request Request) string {
+	return f(request)
+}
+
+var (
+	experimentAssigners     = make(map[string]ExperimentAssigner)
+	experimentAssignersLock sync.RWMutex
+)
+
+// RegistExperimentAssigner registers the assigner of the experiment, the refers with the experiment param of the
+// name use it. the assigner registered later replaces the previous one, nil removes it
+func RegistExperimentAssigner(name string, assigner ExperimentAssigner) {
+	experimentAssignersLock.Lock()
+	defer experimentAssignersLock.Unlock()
+	if assigner == nil {
+		delete(experimentAssigners, name)
+		return
+	}
+	experimentAssigners[name] = assigner
+}
+
+func GetExperimentAssigner(name string) ExperimentAssigner {
+	experimentAssignersLock.RLock()
+	defer experimentAssignersLock.RUnlock()
+	return experimentAssigners[name]
+}
+
+// AssignExperiment assigns the request to a variant of the experiment and records the assignment in the attachments.
+// the variant assigned by the upstream is kept, so the requests of a call chain stay in the same variant
+func AssignExperiment(experiment string, request Request) string {
+	if variant := request.GetAttachment(VariantAttachmentKey); variant != "" && request.GetAttachment(ExperimentAttachmentKey) == experiment {
+		return variant
+	}
+	assigner := GetExperimentAssigner(experiment)
+	if assigner == nil {
+		return ""
+	}
+	variant := assigner.Assign(request)
+	if variant != "" {
+		request.SetAttachment(ExperimentAttachmentKey, experiment)
+		request.SetAttachment(VariantAttachmentKey, variant)
+	}
+	return variant
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignExperiment(t *testing.T) {
+	defer RegistExperimentAssigner("exp1", nil)
+	request := &MotanRequest{Attachment: NewStringMap(0)}
+	assert.Equal(t, "", AssignExperiment("exp1", request), "no assigner registered")
+
+	calls := 0
+	RegistExperimentAssigner("exp1", ExperimentAssignerFunc(func(request Request) string {
+		calls++
+		if request.GetAttachment("uid") == "" {
+			return ""
+		}
+		return "b"
+	}))
+	assert.Equal(t, "", AssignExperiment("exp1", request))
+	assert.Equal(t, "", request.GetAttachment(VariantAttachmentKey), "not in the experiment")
+
+	request.SetAttachment("uid", "1")
+	assert.Equal(t, "b", AssignExperiment("exp1", request))
+	assert.Equal(t, "exp1", request.GetAttachment(ExperimentAttachmentKey))
+	assert.Equal(t, "b", request.GetAttachment(VariantAttachmentKey))
+
+	// the variant assigned by the upstream is kept
+	request.SetAttachment(VariantAttachmentKey, "c")
+	assert.Equal(t, "c", AssignExperiment("exp1", request))
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BuildEndPointFilterChain links the new instances of the endpoint filters for the url, it returns the outermost
+// filter of the chain and the filters reporting the status. the last filter of the chain calls the caller
+func BuildEndPointFilterChain(url *URL, filters []Filter, context *Context) (EndPointFilter, []Status) {
+	lastf := GetLastEndPointFilter()
+	statusFilters := make([]Status, 0, len(filters))
+	for _, f := range filters {
+		filter := f.NewFilter(url)
+		if filter == nil {
+			continue
+		}
+		if ef, ok := filter.(EndPointFilter); ok {
+			CanSetContext(ef, context)
+			ef.SetNext(lastf)
+			lastf = ef
+			if sf, ok := ef.(Status); ok {
+				statusFilters = append(statusFilters, sf)
+			}
+		}
+	}
+	return lastf, statusFilters
+}
+
+// FilterChainCache caches the endpoint filter chains by the urls, a chain is built once for a url and shared by
+// the endpoints of the url, such as the endpoints of a provider discovered by several registries. the chain of
+// a url is rebuilt if the params of the url changed, and all chains are rebuilt after the filters changed or Invalidate
+type FilterChainCache struct {
+	lock    sync.Mutex
+	filters []Filter
+	context *Context
+	chains  map[string]*cachedFilterChain
+}
+
+type cachedFilterChain struct {
+	filter        EndPointFilter
+	statusFilters []Status
+}
+
+func NewFilterChainCache(filters []Filter, context *Context) *FilterChainCache {
+	return &FilterChainCache{filters: filters, context: context, chains: make(map[string]*cachedFilterChain)}
+}
+
+// Get returns the filter chain of the url, the chain is built if not cached
+func (c *FilterChainCache) Get(url *URL) (EndPointFilter, []Status) {
+	key := filterChainKey(url)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	chain, ok := c.chains[key]
+	if !ok {
+		chain = &cachedFilterChain{}
+		chain.filter, chain.statusFilters = BuildEndPointFilterChain(url, c.filters, c.context)
+		c.chains[key] = chain
+	}
+	return chain.filter, chain.statusFilters
+}
+
+// SetFilters replaces the filters of the chains and drops the chains built
+func (c *FilterChainCache) SetFilters(filters []Filter) {
+	c.lock.Lock()
+	c.filters = filters
+	c.chains = make(map[string]*cachedFilterChain)
+	c.lock.Unlock()
+}
+
+// Invalidate drops the chains built, the endpoints keep the chains got until they get the chains again
+func (c *FilterChainCache) Invalidate() {
+	c.lock.Lock()
+	c.chains = make(map[string]*cachedFilterChain)
+	c.lock.Unlock()
+}
+
+// Retain drops the chains of the urls not in use
+func (c *FilterChainCache) Retain(urls []*URL) {
+	keys := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		keys[filterChainKey(url)] = true
+	}
+	c.lock.Lock()
+	for key := range c.chains {
+		if !keys[key] {
+			delete(c.chains, key)
+		}
+	}
+	c.lock.Unlock()
+}
+
+func (c *FilterChainCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.chains)
+}
+
+// filterChainKey is the identity of the url with the sorted params, the filters may be configured by the params
+func filterChainKey(url *URL) string {
+	keys := make([]string, 0, len(url.Parameters))
+	for k := range url.Parameters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(url.GetIdentity())
+	for _, k := range keys {
+		b.WriteString("&")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(url.Parameters[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/weibocom/motan-go/log"
+)
+
+var (
+	// ErrAsyncTimeout is returned by AsyncResult.Get if the call is not finished in the timeout
+	ErrAsyncTimeout = errors.New("async call timeout")
+	// ErrAsyncCanceled is the error of the async calls canceled by AsyncResult.Cancel
+	ErrAsyncCanceled = errors.New("async call canceled")
+	// ErrAsyncLeaked is the error of the async calls not finished long after the request timeout
+	ErrAsyncLeaked = errors.New("async call not finished in time, maybe the response is lost")
+	// ErrTooManyPendingCalls is the error of the async calls rejected by the full PendingCalls
+	ErrTooManyPendingCalls = errors.New("too many pending async calls")
+)
+
+func (r *AsyncResult) finishedChan() chan struct{} {
+	if r.finishedCh == nil {
+		r.finishedCh = make(chan struct{})
+	}
+	return r.finishedCh
+}
+
+// Finish sets the error of the call and notifies the waiters and the callbacks, it returns false if the call
+// has been finished, e.g. the response is received after the call canceled
+func (r *AsyncResult) Finish(err error) bool {
+	return r.finish(err, false)
+}
+
+func (r *AsyncResult) finish(err error, aborted bool) bool {
+	r.lock.Lock()
+	if r.finished {
+		r.lock.Unlock()
+		return false
+	}
+	r.finished, r.aborted = true, aborted
+	r.Error = err
+	close(r.finishedChan())
+	callbacks, hooks, cancelFunc := r.callbacks, r.hooks, r.cancelFunc
+	r.callbacks, r.hooks, r.cancelFunc = nil, nil, nil
+	r.lock.Unlock()
+
+	if aborted && cancelFunc != nil {
+		cancelFunc()
+	}
+	for _, h := range hooks {
+		h()
+	}
+	if r.Done != nil {
+		// the result is always delivered as the callers may wait for it, but the full channel never blocks the finishing goroutine
+		select {
+		case r.Done <- r:
+		default:
+			go func() { r.Done <- r }()
+		}
+	}
+	// the callbacks never block the goroutine finishing the call, which may be the receiving loop of a connection
+	if len(callbacks) > 0 {
+		go func() {
+			defer HandlePanic(nil)
+			for _, cb := range callbacks {
+				cb(r)
+			}
+		}()
+	}
+	return true
+}
+
+// Finished returns the channel closed when the call is finished
+func (r *AsyncResult) Finished() <-chan struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.finishedChan()
+}
+
+func (r *AsyncResult) IsFinished() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.finished
+}
+
+// Get waits for the call and returns the error of the call, or ErrAsyncTimeout if the call is not finished in the
+// timeout. it waits until the call finished if the timeout <= 0, the call is not canceled by the timeout of Get
+func (r *AsyncResult) Get(timeout time.Duration) error {
+	finished := r.Finished()
+	if timeout <= 0 {
+		<-finished
+		return r.Error
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-finished:
+		return r.Error
+	case <-timer.C:
+		return ErrAsyncTimeout
+	}
+}
+
+// Then adds the callback called in another goroutine after the call finished, the callbacks are called in order
+func (r *AsyncResult) Then(callback func(result *AsyncResult)) *AsyncResult {
+	r.lock.Lock()
+	if !r.finished {
+		r.callbacks = append(r.callbacks, callback)
+		r.lock.Unlock()
+		return r
+	}
+	r.lock.Unlock()
+	go func() {
+		defer HandlePanic(nil)
+		callback(r)
+	}()
+	return r
+}
+
+// Cancel finishes the call with ErrAsyncCanceled, the response of the call is dropped
+func (r *AsyncResult) Cancel() bool {
+	return r.finish(ErrAsyncCanceled, true)
+}
+
+// BindContext cancels the call with the error of ctx if ctx is done before the call finished
+func (r *AsyncResult) BindContext(ctx context.Context) {
+	if ctx.Done() == nil {
+		return
+	}
+	finished := r.Finished()
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.finish(ctx.Err(), true)
+		case <-finished:
+		}
+	}()
+}
+
+// SetCancelFunc sets the function releasing the resources of the call such as the stream of the request,
+// which is called if the call is canceled or expired. it is called at once if the call has been aborted
+func (r *AsyncResult) SetCancelFunc(f func()) {
+	r.lock.Lock()
+	if !r.finished {
+		r.cancelFunc = f
+		r.lock.Unlock()
+		return
+	}
+	aborted := r.aborted
+	r.lock.Unlock()
+	if aborted {
+		f()
+	}
+}
+
+// onFinish adds the hook called in the goroutine finishing the call, it returns false if the call has been finished
+func (r *AsyncResult) onFinish(hook func()) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.finished {
+		return false
+	}
+	r.hooks = append(r.hooks, hook)
+	return true
+}
+
+// PendingCalls is the bounded table of the async calls not finished. the calls not finished in the leak timeout
+// are logged and finished with ErrAsyncLeaked by CheckLeaks, so that the waiters and the table never leak
+type PendingCalls struct {
+	max         int
+	leakTimeout time.Duration
+	lock        sync.Mutex
+	calls       map[*AsyncResult]time.Time
+	leaked      int64
+}
+
+func NewPendingCalls(max int, leakTimeout time.Duration) *PendingCalls {
+	return &PendingCalls{max: max, leakTimeout: leakTimeout, calls: make(map[*AsyncResult]time.Time)}
+}
+
+// Add adds the call to the table until it finished, ErrTooManyPendingCalls is returned if the table is full
+func (p *PendingCalls) Add(r *AsyncResult) error {
+	p.lock.Lock()
+	if p.max > 0 && len(p.calls) >= p.max {
+		p.lock.Unlock()
+		return ErrTooManyPendingCalls
+	}
+	p.calls[r] = time.Now()
+	p.lock.Unlock()
+	if !r.onFinish(func() { p.remove(r) }) {
+		p.remove(r)
+	}
+	return nil
+}
+
+func (p *PendingCalls) remove(r *AsyncResult) {
+	p.lock.Lock()
+	delete(p.calls, r)
+	p.lock.Unlock()
+}
+
+func (p *PendingCalls) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.calls)
+}
+
+// Leaked returns the number of the calls finished by CheckLeaks
+func (p *PendingCalls) Leaked() int64 {
+	return atomic.LoadInt64(&p.leaked)
+}
+
+// CheckLeaks finishes the calls added before the leak timeout with ErrAsyncLeaked, and returns the number of them
+func (p *PendingCalls) CheckLeaks() int {
+	expired := time.Now().Add(-p.leakTimeout)
+	var leaked []*AsyncResult
+	p.lock.Lock()
+	for r, t := range p.calls {
+		if t.Before(expired) {
+			leaked = append(leaked, r)
+		}
+	}
+	p.lock.Unlock()
+	n := 0
+	for _, r := range leaked {
+		if r.finish(ErrAsyncLeaked, true) {
+			n++
+		}
+	}
+	if n > 0 {
+		atomic.AddInt64(&p.leaked, int64(n))
+		vlog.Warningf("%d async calls are not finished in %s, maybe the responses are lost\n", n, p.leakTimeout)
+	}
+	return n
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncResultGet(t *testing.T) {
+	r := &AsyncResult{Done: make(chan *AsyncResult, 1)}
+	assert.Equal(t, ErrAsyncTimeout, r.Get(10*time.Millisecond))
+	assert.False(t, r.IsFinished())
+
+	err := errors.New("call fail")
+	go r.Finish(err)
+	assert.Equal(t, err, r.Get(0))
+	assert.Equal(t, r, <-r.Done)
+	assert.False(t, r.Finish(nil), "the call should be finished once")
+	assert.Equal(t, err, r.Error)
+
+	// the results are delivered even if there are more outstanding calls than the capacity of the done channel
+	done := make(chan *AsyncResult, 2)
+	results := make(map[*AsyncResult]bool)
+	for i := 0; i < 5; i++ {
+		r = &AsyncResult{Done: done}
+		results[r] = true
+		assert.True(t, r.Finish(nil), "finish should not block on the full done channel")
+		assert.Nil(t, r.Get(time.Second))
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case r = <-done:
+			assert.True(t, results[r], "the result should be delivered once")
+			delete(results, r)
+		case <-time.After(time.Second):
+			t.Fatalf("result not delivered. remains:%d", len(results))
+		}
+	}
+}
+
+func TestAsyncResultThen(t *testing.T) {
+	r := &AsyncResult{}
+	called := make(chan int, 2)
+	r.Then(func(result *AsyncResult) {
+		called <- 1
+	}).Then(func(result *AsyncResult) {
+		called <- 2
+	})
+	r.Finish(nil)
+	assert.Equal(t, 1, <-called)
+	assert.Equal(t, 2, <-called)
+
+	r.Then(func(result *AsyncResult) {
+		called <- 3
+	})
+	assert.Equal(t, 3, <-called, "callbacks added after finished should be called")
+}
+
+func TestAsyncResultCancel(t *testing.T) {
+	r := &AsyncResult{}
+	canceled := 0
+	r.SetCancelFunc(func() { canceled++ })
+	assert.True(t, r.Cancel())
+	assert.Equal(t, ErrAsyncCanceled, r.Get(0))
+	assert.Equal(t, 1, canceled)
+	r.SetCancelFunc(func() { canceled++ })
+	assert.Equal(t, 2, canceled, "cancel func should be called at once for the canceled call")
+	assert.False(t, r.Finish(nil), "the response should be dropped after canceled")
+
+	r = &AsyncResult{}
+	r.SetCancelFunc(func() { canceled++ })
+	r.Finish(nil)
+	assert.False(t, r.Cancel())
+	assert.Equal(t, 2, canceled, "cancel func should not be called for the finished call")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r = &AsyncResult{}
+	r.BindContext(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, r.Get(time.Second))
+}
+
+func TestPendingCalls(t *testing.T) {
+	p := NewPendingCalls(2, 50*time.Millisecond)
+	r1, r2 := &AsyncResult{}, &AsyncResult{}
+	assert.Nil(t, p.Add(r1))
+	assert.Nil(t, p.Add(r2))
+	assert.Equal(t, ErrTooManyPendingCalls, p.Add(&AsyncResult{}))
+	r1.Finish(nil)
+	assert.Equal(t, 1, p.Len())
+
+	assert.Equal(t, 0, p.CheckLeaks())
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, 1, p.CheckLeaks())
+	assert.Equal(t, ErrAsyncLeaked, r2.Get(0))
+	assert.Equal(t, 0, p.Len())
+	assert.Equal(t, int64(1), p.Leaked())
+}
@@ -24,6 +24,7 @@ const (
 	importSection        = "import-refer"
 	dynamicSection       = "dynamic-param"
 	SwitcherSection      = "switcher"
+	configCenterSection  = "motan-config-center"
 
 	// URLConfKey is config id
 	// config Keys
@@ -56,6 +57,8 @@ type Context struct {
 	AgentURL         *URL
 	ClientURL        *URL
 	ServerURL        *URL
+	// the config center configured by the motan-config-center section, nil if not configured
+	RemoteConfig *cfg.RemoteConfig
 }
 
 var (
@@ -67,6 +70,7 @@ var (
 	Port         = flag.Int("port", 0, "agent listen port")
 	Eport        = flag.Int("eport", 0, "agent export service port when as a reverse proxy server")
 	Mport        = flag.Int("mport", 0, "agent manage port")
+	Wsport       = flag.Int("wsport", 0, "agent websocket port for web clients, disabled if not set")
 	Pidfile      = flag.String("pidfile", "", "agent manage port")
 	CfgFile      = flag.String("c", "", "motan run conf")
 	LocalIP      = flag.String("localIP", "", "local ip for motan register")
@@ -75,6 +79,8 @@ var (
 	Pool         = flag.String("pool", "", "application pool config. like 'application-idc-level'")
 	Application  = flag.String("application", "", "assist for application pool config.")
 	Recover      = flag.Bool("recover", false, "recover from accidental exit")
+	Env          = flag.String("env", "", "the env of the config overlay, e.g. agent.prod.yaml is merged over agent.yaml if the env is prod")
+	DryRun       = flag.Bool("dryrun", false, "validate the config, print the report and exit without starting")
 )
 
 func (c *Context) confToURLs(section string) map[string]*URL {
@@ -146,7 +152,7 @@ func (c *Context) Initialize() {
 		if c.ConfigFile == "" {
 			c.ConfigFile = configFile
 		}
-		if cfgRs, err = cfg.NewConfigFromFile(c.ConfigFile); err != nil {
+		if cfgRs, err = cfg.NewConfigWithIncludes(c.ConfigFile, *Env); err != nil {
 			fmt.Printf("parse config fail. err:%s\n", err.Error())
 			return
 		}
@@ -181,6 +187,8 @@ func (c *Context) Initialize() {
 		}
 	}
 
+	expandPlaceholders(cfgRs)
+	c.loadRemoteConfig(cfgRs)
 	c.Config = cfgRs
 	c.parseRegistrys()
 	c.parseBasicRefers()
@@ -190,6 +198,39 @@ func (c *Context) Initialize() {
 	c.parseHostURL()
 }
 
+// loadRemoteConfig merges the config fetched from the config center over the local config
+func (c *Context) loadRemoteConfig(local *cfg.Config) {
+	section, err := local.GetSection(configCenterSection)
+	if err != nil || len(section) == 0 {
+		return
+	}
+	params := make(map[string]string, len(section))
+	for k, v := range section {
+		params[InterfaceToString(k)] = InterfaceToString(v)
+	}
+	remote, err := cfg.GetRemoteConfig(params)
+	if err != nil {
+		fmt.Printf("init config center fail. err:%s\n", err.Error())
+		return
+	}
+	c.RemoteConfig = remote
+	rc, err := remote.Load()
+	if err != nil {
+		fmt.Printf("load config from config center fail, use the local config. err:%s\n", err.Error())
+		return
+	}
+	expandPlaceholders(rc)
+	local.Merge(rc)
+}
+
+// expandPlaceholders resolves the environment variables and the secrets in the config values,
+// the values are resolved again when the config is reloaded
+func expandPlaceholders(c *cfg.Config) {
+	for _, err := range c.ExpandPlaceholders() {
+		fmt.Printf("expand config placeholder fail. err:%s\n", err.Error())
+	}
+}
+
 // pool config priority ： pool > application > service > basic
 func parsePool(path string, pool string) (*cfg.Config, error) {
 	c := cfg.NewConfig()
@@ -197,7 +238,7 @@ func parsePool(path string, pool string) (*cfg.Config, error) {
 	var err error
 
 	// basic config
-	tempcfg, err = cfg.NewConfigFromFile(path + basicConfig)
+	tempcfg, err = cfg.NewConfigWithIncludes(path+basicConfig, "")
 	if err == nil && tempcfg != nil {
 		c.Merge(tempcfg)
 	}
@@ -211,14 +252,14 @@ func parsePool(path string, pool string) (*cfg.Config, error) {
 	}
 	application = path + applicationPath + application + fileSuffix
 	if application != "" {
-		appconfig, err = cfg.NewConfigFromFile(application)
+		appconfig, err = cfg.NewConfigWithIncludes(application, "")
 		if err == nil && appconfig != nil {
 			// import-refer
 			is, err := appconfig.DIY(importSection)
 			if err == nil && is != nil {
 				if li, ok := is.([]interface{}); ok {
 					for _, r := range li {
-						tempcfg, err = cfg.NewConfigFromFile(path + servicePath + r.(string) + fileSuffix)
+						tempcfg, err = cfg.NewConfigWithIncludes(path+servicePath+r.(string)+fileSuffix, "")
 						if err == nil && tempcfg != nil {
 							c.Merge(tempcfg)
 						}
@@ -235,7 +276,7 @@ func parsePool(path string, pool string) (*cfg.Config, error) {
 		base := ""
 		for _, v := range poolPart {
 			base = base + v
-			tempcfg, err = cfg.NewConfigFromFile(path + poolPath + base + fileSuffix)
+			tempcfg, err = cfg.NewConfigWithIncludes(path+poolPath+base+fileSuffix, "")
 			if err == nil && tempcfg != nil {
 				c.Merge(tempcfg)
 			}
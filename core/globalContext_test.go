@@ -2,6 +2,10 @@ package core
 
 import (
 	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 )
 
@@ -31,3 +35,27 @@ func TestGetContext(t *testing.T) {
 		t.Error("parse serivce key fail")
 	}
 }
+
+func TestRemoteConfigMerge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("motan-refer:\n  remote-refer:\n    path: remote.service\n  local-refer:\n    group: remote-group\n"))
+	}))
+	defer server.Close()
+	f, err := ioutil.TempFile("", "motan-remote-*.yaml")
+	if err != nil {
+		t.Fatalf("create config file fail. err:%v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("motan-config-center:\n  protocol: nacos\n  address: " + server.URL + "\n  dataId: motan.yaml\n" +
+		"motan-refer:\n  local-refer:\n    path: local.service\n    group: local-group\n")
+	f.Close()
+
+	ctx := &Context{ConfigFile: f.Name()}
+	ctx.Initialize()
+	if ctx.RemoteConfig == nil || ctx.RefersURLs["remote-refer"] == nil {
+		t.Fatalf("refers of config center should be merged. refers:%v", ctx.RefersURLs)
+	}
+	if u := ctx.RefersURLs["local-refer"]; u.Path != "local.service" || u.Group != "remote-group" {
+		t.Errorf("config center should override the local config. url:%+v", u)
+	}
+}
@@ -10,6 +10,8 @@ import (
 type StringMap struct {
 	mu       sync.RWMutex
 	innerMap map[string]string
+	pooled   bool // acquired by AcquireStringMap
+	shared   bool // the inner map is shared by CopyOnWrite, it is copied before written
 }
 
 func NewStringMap(cap int) *StringMap {
@@ -21,16 +23,33 @@ func NewStringMap(cap int) *StringMap {
 
 func (m *StringMap) Store(key, value string) {
 	m.mu.Lock()
+	m.own(1)
 	m.innerMap[key] = value
 	m.mu.Unlock()
 }
 
 func (m *StringMap) Delete(key string) {
 	m.mu.Lock()
-	delete(m.innerMap, key)
+	if _, ok := m.innerMap[key]; ok {
+		m.own(0)
+		delete(m.innerMap, key)
+	}
 	m.mu.Unlock()
 }
 
+// own copies the inner map shared with the other maps before written, it must be called with the lock held
+func (m *StringMap) own(grow int) {
+	if !m.shared {
+		return
+	}
+	innerMap := make(map[string]string, len(m.innerMap)+grow)
+	for k, v := range m.innerMap {
+		innerMap[k] = v
+	}
+	m.innerMap = innerMap
+	m.shared = false
+}
+
 func (m *StringMap) Load(key string) (value string, ok bool) {
 	m.mu.RLock()
 	value, ok = m.innerMap[key]
@@ -64,6 +83,18 @@ func (m *StringMap) Range(f func(k, v string) bool) {
 	}
 }
 
+// ForEach calls f for each key and value under the read lock without copying the keys like Range,
+// f must not modify the map
+func (m *StringMap) ForEach(f func(k, v string) bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for k, v := range m.innerMap {
+		if !f(k, v) {
+			return
+		}
+	}
+}
+
 func (m *StringMap) RawMap() map[string]string {
 	m.mu.RLock()
 	rawMap := make(map[string]string, len(m.innerMap))
@@ -78,6 +109,17 @@ func (m *StringMap) Copy() *StringMap {
 	return &StringMap{innerMap: m.RawMap()}
 }
 
+// CopyOnWrite returns a copy sharing the entries with m, the entries are copied by m or the copy when it is
+// written first. it is cheaper than Copy for the maps which are seldom written after copied, such as the
+// attachments of the proxied requests
+func (m *StringMap) CopyOnWrite() *StringMap {
+	m.mu.Lock()
+	m.shared = true
+	innerMap := m.innerMap
+	m.mu.Unlock()
+	return &StringMap{innerMap: innerMap, shared: true}
+}
+
 func (m *StringMap) Len() int {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -35,6 +35,38 @@ func TestStringMap(t *testing.T) {
 	})
 }
 
+func TestStringMapCopyOnWrite(t *testing.T) {
+	origin := NewStringMap(0)
+	origin.Store("k1", "v1")
+	origin.Store("k2", "v2")
+	copied := origin.CopyOnWrite()
+	assert.Equal(t, origin.RawMap(), copied.RawMap())
+
+	copied.Store("k3", "v3")
+	copied.Delete("k1")
+	assert.Equal(t, map[string]string{"k1": "v1", "k2": "v2"}, origin.RawMap())
+	assert.Equal(t, map[string]string{"k2": "v2", "k3": "v3"}, copied.RawMap())
+
+	// the origin copies the entries too when written first
+	again := origin.CopyOnWrite()
+	origin.Store("k1", "new")
+	assert.Equal(t, "v1", again.LoadOrEmpty("k1"))
+
+	count := 0
+	origin.ForEach(func(k, v string) bool {
+		count++
+		return false
+	})
+	assert.Equal(t, 1, count)
+
+	// the entries shared are not cleared when the pooled map is released
+	pooled := AcquireStringMap(0)
+	pooled.Store("k", "v")
+	shared := pooled.CopyOnWrite()
+	ReleaseStringMap(pooled)
+	assert.Equal(t, "v", shared.LoadOrEmpty("k"))
+}
+
 func BenchmarkStringMap(b *testing.B) {
 	stringMap := NewStringMap(0)
 	for i := 0; i < b.N; i++ {
@@ -69,6 +101,30 @@ func BenchmarkStringMapRead(b *testing.B) {
 	})
 }
 
+func BenchmarkStringMapForEach(b *testing.B) {
+	stringMap := NewStringMap(0)
+	for i := 0; i < 100; i++ {
+		s := strconv.Itoa(i)
+		stringMap.Store(s, s)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		stringMap.ForEach(func(_, _ string) bool { return true })
+	}
+}
+
+func BenchmarkStringMapCopyOnWrite(b *testing.B) {
+	stringMap := NewStringMap(0)
+	for i := 0; i < 20; i++ {
+		s := strconv.Itoa(i)
+		stringMap.Store(s, s)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		stringMap.CopyOnWrite()
+	}
+}
+
 func TestCopyOnWriteMap_Load(t *testing.T) {
 	cowMap := NewCopyOnWriteMap()
 	value, b := cowMap.Load("testKey")
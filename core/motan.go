@@ -1,7 +1,12 @@
 package core
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -220,6 +225,20 @@ type Exporter interface {
 	WithURL
 }
 
+// WarmUpService is implemented by the services need to warm up before being available, such as filling the
+// connection pools and the caches. WarmUp is called once the service is exported, and retried until it succeeds.
+// it is not exposed as a remote method
+type WarmUpService interface {
+	WarmUp() error
+}
+
+// PooledService is implemented by the service instances of the pool provider need health checks or cleanup. the
+// unhealthy instances are evicted from the pool and closed. the methods are not exposed as remote methods
+type PooledService interface {
+	Healthy() bool
+	Close()
+}
+
 // Provider : service provider
 type Provider interface {
 	SetService(s interface{})
@@ -227,6 +246,20 @@ type Provider interface {
 	GetPath() string
 }
 
+// MethodDescriptor describes an exported method of a service, the types are the names in go
+type MethodDescriptor struct {
+	Name        string   `json:"name"`
+	Params      []string `json:"params"`
+	Result      string   `json:"result,omitempty"`
+	WithContext bool     `json:"withContext,omitempty"`
+	Stream      bool     `json:"stream,omitempty"`
+}
+
+// MethodDescriber is implemented by the providers which can describe the methods of their services
+type MethodDescriber interface {
+	DescribeMethods() []*MethodDescriptor
+}
+
 // MessageHandler : handler message(request) for Server
 type MessageHandler interface {
 	Call(request Request) (res Response)
@@ -235,6 +268,19 @@ type MessageHandler interface {
 	GetProvider(serviceName string) Provider
 }
 
+// Stream : message stream of a streaming call, used by both the client and the provider.
+// a streaming call can send multiple request messages and receive multiple response messages.
+type Stream interface {
+	// Send sends a message to the peer
+	Send(v interface{}) error
+	// Recv receives the next message into v. io.EOF is returned after the peer has finished sending
+	Recv(v interface{}) error
+	// CloseSend tells the peer nothing more will be sent
+	CloseSend() error
+	// Close abandons the stream, the peer is notified and the following calls return an error
+	Close() error
+}
+
 // Serialization : Serialization
 type Serialization interface {
 	GetSerialNum() int
@@ -244,6 +290,59 @@ type Serialization interface {
 	DeSerializeMulti(b []byte, v []interface{}) ([]interface{}, error)
 }
 
+// SerializationStream is optionally implemented by serializations which read and write streams directly.
+// the protocol layer serializes bodies into pooled buffers by it instead of allocating byte slices for the whole payload
+type SerializationStream interface {
+	SerializeTo(w io.Writer, v interface{}) error
+	SerializeMultiTo(w io.Writer, v []interface{}) error
+	DeSerializeFrom(r io.Reader, v interface{}) (interface{}, error)
+	DeSerializeMultiFrom(r io.Reader, v []interface{}) ([]interface{}, error)
+}
+
+// Compressor : compress and decompress message body
+type Compressor interface {
+	Name
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Transport : carries the motan2 frames instead of tcp, such as KCP, shared memory or in-process loopback.
+// the transport is selected by the url param "transport", and it handles the tls options itself
+type Transport interface {
+	Name
+	Dial(url *URL, timeout time.Duration) (net.Conn, error)
+	Listen(url *URL) (net.Listener, error)
+}
+
+// MetricsSnapshot : the metrics of a group and a service in a report period, see metrics.Snapshot.
+// the keys are like role:application:method.suffix, the histogram keys have no suffix
+type MetricsSnapshot interface {
+	GetGroup() string
+	GetService() string
+	IsReport() bool
+	Count(key string) int64
+	Sum(key string) int64
+	Max(key string) int64
+	Mean(key string) float64
+	Min(key string) int64
+	P90(key string) float64
+	P95(key string) float64
+	P99(key string) float64
+	P999(key string) float64
+	Percentile(key string, v float64) float64
+	Percentiles(key string, f []float64) []float64
+	RangeKey(f func(k string))
+	IsHistogram(key string) bool
+	IsCounter(key string) bool
+}
+
+// MetricsSink : writes the metrics snapshots of each report period to a metrics system, such as kafka or clickhouse.
+// the sinks are created by the protocol of the sinks configured in the metrics section
+type MetricsSink interface {
+	Name
+	Write(snapshots []MetricsSnapshot) error
+}
+
 // ExtensionFactory : can regiser and get all kinds of extension implements.
 type ExtensionFactory interface {
 	GetHa(url *URL) HaStrategy
@@ -255,6 +354,10 @@ type ExtensionFactory interface {
 	GetServer(url *URL) Server
 	GetMessageHandler(name string) MessageHandler
 	GetSerialization(name string, id int) Serialization
+	GetCompressor(name string) Compressor
+	GetTransport(name string) Transport
+	GetMetricsSink(url *URL) MetricsSink
+	GetAuth(url *URL) Auth
 	RegistExtFilter(name string, newFilter DefaultFilterFunc)
 	RegistExtHa(name string, newHa NewHaFunc)
 	RegistExtLb(name string, newLb NewLbFunc)
@@ -264,6 +367,10 @@ type ExtensionFactory interface {
 	RegistExtServer(name string, newServer NewServerFunc)
 	RegistryExtMessageHandler(name string, newMessage NewMessageHandlerFunc)
 	RegistryExtSerialization(name string, id int, newSerialization NewSerializationFunc)
+	RegistExtCompressor(name string, newCompressor NewCompressorFunc)
+	RegistExtTransport(name string, newTransport NewTransportFunc)
+	RegistExtMetricsSink(name string, newMetricsSink NewMetricsSinkFunc)
+	RegistExtAuth(name string, newAuth NewAuthFunc)
 }
 
 // Initializable :Initializable
@@ -304,6 +411,8 @@ type Exception struct {
 	ErrCode int    `json:"errcode"`
 	ErrMsg  string `json:"errmsg"`
 	ErrType int    `json:"errtype"`
+	// the correlation id of the request chain, for finding the logs of the failed call
+	CorrelationID string `json:"cid,omitempty"`
 }
 
 // RPCContext : Context for RPC call
@@ -313,6 +422,7 @@ type RPCContext struct {
 	Oneway          bool
 	Proxy           bool
 	GzipSize        int
+	Compress        string // comma separated compressor names in order of preference
 	SerializeNum    int
 	Serialized      bool
 
@@ -320,17 +430,110 @@ type RPCContext struct {
 	AsyncCall bool
 	Result    *AsyncResult
 	Reply     interface{}
+	// the call should be finished before the deadline if it is not zero.
+	// the remaining time is propagated to the provider, and the provider side passes it to downstream calls.
+	Deadline time.Time
+	// the call is aborted when the context is done. on the provider side it is done when the call finished.
+	Context context.Context
+
+	// for streaming call. the response value of a client streaming call is the Stream,
+	// and the provider gets the Stream of the call from the request rpc context.
+	StreamCall bool
+	Stream     Stream
+
+	// for batch call. the sub requests are sent in one message,
+	// and the response value of a batch call is the []Response in the same order.
+	BatchRequests []Request
 
 	// trace context
 	Tc *TraceContext
+
+	// the serialized bytes of the request and the response body, before the compression
+	requestSize  int
+	responseSize int
+}
+
+// RequestSize returns the serialized bytes of the request body, 0 if the request is not serialized yet
+func (r *RPCContext) RequestSize() int {
+	if r == nil {
+		return 0
+	}
+	return r.requestSize
+}
+
+func (r *RPCContext) SetRequestSize(size int) {
+	r.requestSize = size
+}
+
+// ResponseSize returns the serialized bytes of the response body, 0 if the response is not serialized yet
+func (r *RPCContext) ResponseSize() int {
+	if r == nil {
+		return 0
+	}
+	return r.responseSize
 }
 
-// AsyncResult : async call result
+func (r *RPCContext) SetResponseSize(size int) {
+	r.responseSize = size
+}
+
+// Done returns the done channel of the context, nil if there is no context
+func (r *RPCContext) Done() <-chan struct{} {
+	if r == nil || r.Context == nil {
+		return nil
+	}
+	return r.Context.Done()
+}
+
+// Err returns the error of the context, nil if there is no context or the context is not done
+func (r *RPCContext) Err() error {
+	if r == nil || r.Context == nil {
+		return nil
+	}
+	return r.Context.Err()
+}
+
+// CallContext calls the caller with the context. the deadline of the context is propagated as the request deadline,
+// and the waits of network and provider are aborted when the context is done.
+func CallContext(ctx context.Context, caller Caller, request Request) Response {
+	rc := request.GetRPCContext(true)
+	rc.Context = ctx
+	InjectBaggage(ctx, request)
+	if deadline, ok := ctx.Deadline(); ok && (rc.Deadline.IsZero() || deadline.Before(rc.Deadline)) {
+		rc.Deadline = deadline
+	}
+	if err := ctx.Err(); err == context.DeadlineExceeded {
+		return BuildExceptionResponse(request.GetRequestID(), NewClientTimeoutException(err.Error()))
+	} else if err != nil {
+		return BuildExceptionResponse(request.GetRequestID(), NewException(ErrCodeBadRequest, err.Error()))
+	}
+	return caller.Call(request)
+}
+
+// ErrDeadlineExceeded is returned for requests whose deadline has passed before they were sent or processed
+var ErrDeadlineExceeded = errors.New("request deadline exceeded")
+
+// ErrServerBusy is returned for requests rejected by the full worker pool of the service
+var ErrServerBusy = errors.New("server busy")
+
+// ErrServerOverloaded is returned for requests rejected by the overload protection of the server
+var ErrServerOverloaded = errors.New("server overloaded")
+
+// AsyncResult : async call result, it is also the future of the call by Get, Then and Cancel.
+// the result is sent to Done once when the call finished, in background if Done is full
 type AsyncResult struct {
 	StartTime int64
 	Done      chan *AsyncResult
 	Reply     interface{}
 	Error     error
+
+	lock       sync.Mutex
+	finished   bool
+	aborted    bool // canceled or expired before the response received
+	finishedCh chan struct{}
+	callbacks  []func(*AsyncResult)
+	hooks      []func()
+	cancelFunc func()
 }
 
 // DeserializableValue : for lazy deserialize
@@ -344,6 +547,9 @@ func (d *DeserializableValue) Deserialize(v interface{}) (interface{}, error) {
 	if d.Serialization == nil {
 		return nil, errors.New("deserialize fail in DeserializableValue, Serialization is nil")
 	}
+	if s, ok := d.Serialization.(SerializationStream); ok {
+		return s.DeSerializeFrom(bytes.NewReader(d.Body), v)
+	}
 	return d.Serialization.DeSerialize(d.Body, v)
 }
 
@@ -352,9 +558,38 @@ func (d *DeserializableValue) DeserializeMulti(v []interface{}) ([]interface{},
 	if d.Serialization == nil {
 		return nil, errors.New("deserialize fail in DeserializableValue, Serialization is nil")
 	}
+	if s, ok := d.Serialization.(SerializationStream); ok {
+		return s.DeSerializeMultiFrom(bytes.NewReader(d.Body), v)
+	}
 	return d.Serialization.DeSerializeMulti(d.Body, v)
 }
 
+// Transcode returns the body serialized by target without deserializing it if target has the same serial number.
+// otherwise the values are deserialized without types and serialized by target, multi is true for request arguments
+func (d *DeserializableValue) Transcode(target Serialization, multi bool) ([]byte, error) {
+	if target == nil {
+		return nil, errors.New("transcode fail in DeserializableValue, target Serialization is nil")
+	}
+	if d.Serialization != nil && d.Serialization.GetSerialNum() == target.GetSerialNum() {
+		return d.Body, nil
+	}
+	if len(d.Body) == 0 {
+		return d.Body, nil
+	}
+	if multi {
+		v, err := d.DeserializeMulti(nil)
+		if err != nil {
+			return nil, err
+		}
+		return target.SerializeMulti(v)
+	}
+	v, err := d.Deserialize(nil)
+	if err != nil {
+		return nil, err
+	}
+	return target.Serialize(v)
+}
+
 // MotanRequest : Request default implement
 type MotanRequest struct {
 	RequestID   uint64
@@ -365,6 +600,7 @@ type MotanRequest struct {
 	Attachment  *StringMap
 	RPCContext  *RPCContext
 	mu          sync.Mutex
+	pooled      bool // acquired by AcquireMotanRequest
 }
 
 // GetAttachment GetAttachment
@@ -438,7 +674,7 @@ func (m *MotanRequest) Clone() interface{} {
 		Arguments:   m.Arguments,
 	}
 	if m.Attachment != nil {
-		newRequest.Attachment = m.Attachment.Copy()
+		newRequest.Attachment = m.Attachment.CopyOnWrite()
 	}
 	if m.RPCContext != nil {
 		newRequest.RPCContext = &RPCContext{
@@ -446,11 +682,14 @@ func (m *MotanRequest) Clone() interface{} {
 			Oneway:       m.RPCContext.Oneway,
 			Proxy:        m.RPCContext.Proxy,
 			GzipSize:     m.RPCContext.GzipSize,
+			Compress:     m.RPCContext.Compress,
 			SerializeNum: m.RPCContext.SerializeNum,
 			Serialized:   m.RPCContext.Serialized,
 			AsyncCall:    m.RPCContext.AsyncCall,
 			Result:       m.RPCContext.Result,
 			Reply:        m.RPCContext.Reply,
+			Deadline:     m.RPCContext.Deadline,
+			Context:      m.RPCContext.Context,
 			Tc:           m.RPCContext.Tc,
 		}
 		if m.RPCContext.OriginalMessage != nil {
@@ -487,6 +726,7 @@ type MotanResponse struct {
 	Attachment  *StringMap
 	RPCContext  *RPCContext
 	mu          sync.Mutex
+	pooled      bool // acquired by AcquireMotanResponse
 }
 
 func (m *MotanResponse) GetAttachment(key string) string {
@@ -572,6 +812,10 @@ type NewRegistryFunc func(url *URL) Registry
 type NewServerFunc func(url *URL) Server
 type NewMessageHandlerFunc func() MessageHandler
 type NewSerializationFunc func() Serialization
+type NewCompressorFunc func() Compressor
+type NewTransportFunc func() Transport
+type NewMetricsSinkFunc func(url *URL) MetricsSink
+type NewAuthFunc func(url *URL) Auth
 
 type DefaultExtensionFactory struct {
 	// factories
@@ -584,6 +828,10 @@ type DefaultExtensionFactory struct {
 	servers           map[string]NewServerFunc
 	messageHandlers   map[string]NewMessageHandlerFunc
 	serializations    map[string]NewSerializationFunc
+	compressors       map[string]NewCompressorFunc
+	transports        map[string]NewTransportFunc
+	metricsSinks      map[string]NewMetricsSinkFunc
+	auths             map[string]NewAuthFunc
 
 	// singleton instance
 	registries      map[string]Registry
@@ -616,6 +864,64 @@ func (d *DefaultExtensionFactory) GetFilter(name string) Filter {
 	return nil
 }
 
+// GetFilterNames returns the sorted names of the registered filters
+func (d *DefaultExtensionFactory) GetFilterNames() []string {
+	names := make([]string, 0, len(d.filterFactories))
+	for name := range d.filterFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetExtensionNames returns the sorted names of the registered extensions by the kinds: filter, ha, lb, endpoint,
+// provider, registry, server, serialization, compressor, transport and metricsSink
+func (d *DefaultExtensionFactory) GetExtensionNames() map[string][]string {
+	names := make(map[string][]string)
+	add := func(kind string, name string) {
+		names[kind] = append(names[kind], name)
+	}
+	for name := range d.filterFactories {
+		add("filter", name)
+	}
+	for name := range d.haFactories {
+		add("ha", name)
+	}
+	for name := range d.lbFactories {
+		add("lb", name)
+	}
+	for name := range d.endpointFactories {
+		add("endpoint", name)
+	}
+	for name := range d.providerFactories {
+		add("provider", name)
+	}
+	for name := range d.registryFactories {
+		add("registry", name)
+	}
+	for name := range d.servers {
+		add("server", name)
+	}
+	for name := range d.serializations {
+		if _, err := strconv.Atoi(name); err != nil { // the serializations are also registered by the ids
+			add("serialization", name)
+		}
+	}
+	for name := range d.compressors {
+		add("compressor", name)
+	}
+	for name := range d.transports {
+		add("transport", name)
+	}
+	for name := range d.metricsSinks {
+		add("metricsSink", name)
+	}
+	for _, v := range names {
+		sort.Strings(v)
+	}
+	return names
+}
+
 func (d *DefaultExtensionFactory) GetRegistry(url *URL) Registry {
 	key := url.GetIdentity()
 	if registry, exist := d.registries[key]; exist {
@@ -698,6 +1004,41 @@ func (d *DefaultExtensionFactory) GetSerialization(name string, id int) Serializ
 	return nil
 }
 
+func (d *DefaultExtensionFactory) GetCompressor(name string) Compressor {
+	if newCompressor, ok := d.compressors[strings.TrimSpace(name)]; ok {
+		return newCompressor()
+	}
+	return nil
+}
+
+func (d *DefaultExtensionFactory) GetTransport(name string) Transport {
+	if newTransport, ok := d.transports[strings.TrimSpace(name)]; ok {
+		return newTransport()
+	}
+	return nil
+}
+
+func (d *DefaultExtensionFactory) GetMetricsSink(url *URL) MetricsSink {
+	if newMetricsSink, ok := d.metricsSinks[strings.TrimSpace(url.Protocol)]; ok {
+		return newMetricsSink(url)
+	}
+	vlog.Errorf("metrics sink name %s is not found in DefaultExtensionFactory!\n", url.Protocol)
+	return nil
+}
+
+// GetAuth returns the auth named by the auth param of the url, nil if the url has no auth
+func (d *DefaultExtensionFactory) GetAuth(url *URL) Auth {
+	name := strings.TrimSpace(url.GetParam(AuthKey, ""))
+	if name == "" {
+		return nil
+	}
+	if newAuth, ok := d.auths[name]; ok {
+		return newAuth(url)
+	}
+	vlog.Errorf("auth name %s is not found in DefaultExtensionFactory!\n", name)
+	return nil
+}
+
 func (d *DefaultExtensionFactory) RegistExtFilter(name string, newFilter DefaultFilterFunc) {
 	// 覆盖方式
 	d.filterFactories[name] = newFilter
@@ -736,6 +1077,22 @@ func (d *DefaultExtensionFactory) RegistryExtSerialization(name string, id int,
 	d.serializations[strconv.Itoa(id)] = newSerialization
 }
 
+func (d *DefaultExtensionFactory) RegistExtCompressor(name string, newCompressor NewCompressorFunc) {
+	d.compressors[name] = newCompressor
+}
+
+func (d *DefaultExtensionFactory) RegistExtTransport(name string, newTransport NewTransportFunc) {
+	d.transports[name] = newTransport
+}
+
+func (d *DefaultExtensionFactory) RegistExtMetricsSink(name string, newMetricsSink NewMetricsSinkFunc) {
+	d.metricsSinks[name] = newMetricsSink
+}
+
+func (d *DefaultExtensionFactory) RegistExtAuth(name string, newAuth NewAuthFunc) {
+	d.auths[name] = newAuth
+}
+
 func (d *DefaultExtensionFactory) Initialize() {
 	d.filterFactories = make(map[string]DefaultFilterFunc)
 	d.haFactories = make(map[string]NewHaFunc)
@@ -747,24 +1104,24 @@ func (d *DefaultExtensionFactory) Initialize() {
 	d.registries = make(map[string]Registry)
 	d.messageHandlers = make(map[string]NewMessageHandlerFunc)
 	d.serializations = make(map[string]NewSerializationFunc)
+	d.compressors = make(map[string]NewCompressorFunc)
+	d.transports = make(map[string]NewTransportFunc)
+	d.metricsSinks = make(map[string]NewMetricsSinkFunc)
+	d.auths = make(map[string]NewAuthFunc)
 }
 
+// the last filters are stateless and shared by all filter chains, they are created with the package so the
+// chains built concurrently get the same instances
 var (
-	lef *lastEndPointFilter
-	lcf *lastClusterFilter
+	lef = &lastEndPointFilter{}
+	lcf = &lastClusterFilter{}
 )
 
 func GetLastEndPointFilter() EndPointFilter {
-	if lef == nil {
-		lef = new(lastEndPointFilter)
-	}
 	return lef
 }
 
 func GetLastClusterFilter() ClusterFilter {
-	if lcf == nil {
-		lcf = new(lastClusterFilter)
-	}
 	return lcf
 }
 
@@ -839,13 +1196,63 @@ type FilterEndPoint struct {
 	Filter        EndPointFilter
 	StatusFilters []Status
 	Caller        Caller
+	Stats         EndPointStats
+}
+
+// EndPointStats is the call stats of an endpoint since it is created
+type EndPointStats struct {
+	calls   int64
+	errors  int64
+	latency int64 // nanoseconds of all calls
+	rolling RollingStats
+}
+
+func (s *EndPointStats) record(start time.Time, response Response) {
+	latency := time.Since(start)
+	failed := response == nil || response.GetException() != nil
+	atomic.AddInt64(&s.calls, 1)
+	atomic.AddInt64(&s.latency, int64(latency))
+	if failed {
+		atomic.AddInt64(&s.errors, 1)
+	}
+	s.rolling.Record(latency, failed)
+}
+
+// Rolling returns the stats of the calls in the recent seconds
+func (s *EndPointStats) Rolling() *RollingStats {
+	return &s.rolling
+}
+
+func (s *EndPointStats) Calls() int64 {
+	return atomic.LoadInt64(&s.calls)
+}
+
+func (s *EndPointStats) Errors() int64 {
+	return atomic.LoadInt64(&s.errors)
+}
+
+// Latency returns the total latency of the calls
+func (s *EndPointStats) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.latency))
+}
+
+// AvgLatency returns the average latency of the calls
+func (s *EndPointStats) AvgLatency() time.Duration {
+	calls := atomic.LoadInt64(&s.calls)
+	if calls == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&s.latency) / calls)
 }
 
 func (f *FilterEndPoint) Call(request Request) Response {
+	start := time.Now()
 	if request.GetRPCContext(true).Tc != nil {
-		request.GetRPCContext(true).Tc.PutReqSpan(&Span{Name: EpFilterStart, Addr: f.GetURL().GetAddressStr(), Time: time.Now()})
+		request.GetRPCContext(true).Tc.PutReqSpan(&Span{Name: EpFilterStart, Addr: f.GetURL().GetAddressStr(), Time: start})
 	}
-	return f.Filter.Filter(f.Caller, request)
+	response := f.Filter.Filter(f.Caller, request)
+	f.Stats.record(start, response)
+	return response
 }
 func (f *FilterEndPoint) GetURL() *URL {
 	return f.URL
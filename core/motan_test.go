@@ -1,9 +1,12 @@
 package core
 
 import (
+	"context"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestExtFactory(t *testing.T) {
@@ -99,3 +102,99 @@ func newMsHandler() MessageHandler {
 func newSerial() Serialization {
 	return nil
 }
+
+func TestCallContext(t *testing.T) {
+	ep := &TestEndPoint{URL: &URL{}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	request := &MotanRequest{Method: "test", Attachment: NewStringMap(0)}
+	request.GetRPCContext(true).Deadline = time.Now().Add(time.Hour)
+	res := CallContext(ctx, ep, request)
+	rc := request.GetRPCContext(false)
+	if res.GetException() != nil || rc.Context != ctx {
+		t.Errorf("call with context fail. res:%+v", res)
+	}
+	if deadline, _ := ctx.Deadline(); !rc.Deadline.Equal(deadline) {
+		t.Errorf("the earlier deadline of context should be used. deadline:%v", rc.Deadline)
+	}
+	if rc.Done() == nil || rc.Err() != nil {
+		t.Errorf("rpc context should use the context")
+	}
+
+	cancel()
+	res = CallContext(ctx, ep, &MotanRequest{Method: "test", Attachment: NewStringMap(0)})
+	if res.GetException() == nil || res.GetException().ErrMsg != context.Canceled.Error() {
+		t.Errorf("call with canceled context should fail. res:%+v", res)
+	}
+	var empty *RPCContext
+	if empty.Done() != nil || empty.Err() != nil {
+		t.Errorf("nil rpc context should have no context")
+	}
+}
+
+// textSerialization serializes strings joined by sep
+type textSerialization struct {
+	num int
+	sep string
+}
+
+func (s *textSerialization) GetSerialNum() int { return s.num }
+
+func (s *textSerialization) Serialize(v interface{}) ([]byte, error) {
+	return []byte(v.(string)), nil
+}
+
+func (s *textSerialization) SerializeMulti(v []interface{}) ([]byte, error) {
+	values := make([]string, 0, len(v))
+	for _, o := range v {
+		values = append(values, o.(string))
+	}
+	return []byte(strings.Join(values, s.sep)), nil
+}
+
+func (s *textSerialization) DeSerialize(b []byte, v interface{}) (interface{}, error) {
+	return string(b), nil
+}
+
+func (s *textSerialization) DeSerializeMulti(b []byte, v []interface{}) ([]interface{}, error) {
+	var values []interface{}
+	for _, o := range strings.Split(string(b), s.sep) {
+		values = append(values, o)
+	}
+	return values, nil
+}
+
+func TestDeserializableValueTranscode(t *testing.T) {
+	comma := &textSerialization{num: 1, sep: ","}
+	dv := &DeserializableValue{Serialization: comma, Body: []byte("a,b")}
+	b, err := dv.Transcode(&textSerialization{num: 1, sep: ","}, true)
+	if err != nil || &b[0] != &dv.Body[0] {
+		t.Errorf("body should be forwarded as is with the same serialization. body:%s, err:%v", b, err)
+	}
+	b, err = dv.Transcode(&textSerialization{num: 2, sep: ";"}, true)
+	if err != nil || string(b) != "a;b" {
+		t.Errorf("body should be transcoded. body:%s, err:%v", b, err)
+	}
+	b, err = dv.Transcode(&textSerialization{num: 2, sep: ";"}, false)
+	if err != nil || string(b) != "a,b" {
+		t.Errorf("single value should be transcoded. body:%s, err:%v", b, err)
+	}
+	if _, err = dv.Transcode(nil, true); err == nil {
+		t.Errorf("transcode without target should fail")
+	}
+}
+
+func TestFilterEndPointStats(t *testing.T) {
+	fep := &FilterEndPoint{URL: &URL{Host: "127.0.0.1", Port: 8002}, Caller: &TestEndPoint{ProcessTime: 10}, Filter: GetLastEndPointFilter()}
+	if fep.Stats.AvgLatency() != 0 {
+		t.Errorf("latency should be 0 without calls")
+	}
+	for i := 0; i < 2; i++ {
+		fep.Call(&MotanRequest{RequestID: uint64(i), Attachment: NewStringMap(0)})
+	}
+	if fep.Stats.Calls() != 2 || fep.Stats.Errors() != 0 {
+		t.Errorf("wrong endpoint stats. calls:%d, errors:%d", fep.Stats.Calls(), fep.Stats.Errors())
+	}
+	if fep.Stats.AvgLatency() < 10*time.Millisecond {
+		t.Errorf("wrong endpoint latency. latency:%v", fep.Stats.AvgLatency())
+	}
+}
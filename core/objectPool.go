@@ -0,0 +1,124 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// the string maps with more entries are released to gc, so the pool does not keep the big maps
+const maxPooledStringMapSize = 64
+
+var (
+	requestPool   = sync.Pool{New: func() interface{} { return &MotanRequest{} }}
+	responsePool  = sync.Pool{New: func() interface{} { return &MotanResponse{} }}
+	stringMapPool = sync.Pool{New: func() interface{} { return &StringMap{innerMap: make(map[string]string, DefaultAttachmentSize)} }}
+
+	objectPoolDisabled int32
+)
+
+// SetObjectPoolEnabled enables or disables the pools of the requests, the responses and the string maps.
+// the objects are allocated and released to gc if disabled, the objects acquired before are still released
+func SetObjectPoolEnabled(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&objectPoolDisabled, 0)
+	} else {
+		atomic.StoreInt32(&objectPoolDisabled, 1)
+	}
+}
+
+func ObjectPoolEnabled() bool {
+	return atomic.LoadInt32(&objectPoolDisabled) == 0
+}
+
+// AcquireMotanRequest gets an empty request from the pool
+func AcquireMotanRequest() *MotanRequest {
+	if !ObjectPoolEnabled() {
+		return &MotanRequest{}
+	}
+	r := requestPool.Get().(*MotanRequest)
+	r.pooled = true
+	return r
+}
+
+// ReleaseMotanRequest puts the request acquired by AcquireMotanRequest back to the pool, the other requests are
+// ignored. the attachments are not released with the request, as they may be the metadata of the message.
+// the request must not be used after released, so it should be released only by the owner after the call finished
+func ReleaseMotanRequest(request Request) {
+	r, ok := request.(*MotanRequest)
+	if !ok || r == nil || !r.pooled {
+		return
+	}
+	r.RequestID = 0
+	r.ServiceName = ""
+	r.Method = ""
+	r.MethodDesc = ""
+	r.Arguments = nil
+	r.Attachment = nil
+	r.RPCContext = nil
+	r.pooled = false
+	requestPool.Put(r)
+}
+
+// AcquireMotanResponse gets an empty response from the pool
+func AcquireMotanResponse() *MotanResponse {
+	if !ObjectPoolEnabled() {
+		return &MotanResponse{}
+	}
+	r := responsePool.Get().(*MotanResponse)
+	r.pooled = true
+	return r
+}
+
+// ReleaseMotanResponse puts the response acquired by AcquireMotanResponse back to the pool like ReleaseMotanRequest
+func ReleaseMotanResponse(response Response) {
+	r, ok := response.(*MotanResponse)
+	if !ok || r == nil || !r.pooled {
+		return
+	}
+	r.RequestID = 0
+	r.Value = nil
+	r.Exception = nil
+	r.ProcessTime = 0
+	r.Attachment = nil
+	r.RPCContext = nil
+	r.pooled = false
+	responsePool.Put(r)
+}
+
+// AcquireStringMap gets an empty string map from the pool
+func AcquireStringMap(cap int) *StringMap {
+	if !ObjectPoolEnabled() {
+		return NewStringMap(cap)
+	}
+	m := stringMapPool.Get().(*StringMap)
+	m.pooled = true
+	return m
+}
+
+// ReleaseStringMap clears the map acquired by AcquireStringMap and puts it back to the pool
+func ReleaseStringMap(m *StringMap) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	if !m.pooled {
+		m.mu.Unlock()
+		return
+	}
+	m.pooled = false
+	if len(m.innerMap) > maxPooledStringMapSize {
+		m.mu.Unlock()
+		return
+	}
+	if m.shared {
+		// the entries are still used by the copies
+		m.innerMap = make(map[string]string, DefaultAttachmentSize)
+		m.shared = false
+	} else {
+		for k := range m.innerMap {
+			delete(m.innerMap, k)
+		}
+	}
+	m.mu.Unlock()
+	stringMapPool.Put(m)
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectPool(t *testing.T) {
+	req := AcquireMotanRequest()
+	req.RequestID = 1
+	req.Method = "hello"
+	req.Arguments = []interface{}{"ray"}
+	req.SetAttachment("k", "v")
+	req.GetRPCContext(true).Proxy = true
+	ReleaseMotanRequest(req)
+	assert.Equal(t, uint64(0), req.RequestID)
+	assert.Nil(t, req.Arguments)
+	assert.Nil(t, req.Attachment)
+	assert.Nil(t, req.GetRPCContext(false))
+	assert.False(t, req.pooled)
+
+	// the requests not acquired from the pool are ignored
+	plain := &MotanRequest{RequestID: 2}
+	ReleaseMotanRequest(plain)
+	assert.Equal(t, uint64(2), plain.RequestID)
+
+	res := AcquireMotanResponse()
+	res.Value = "hello"
+	res.Exception = NewBizException("fail")
+	ReleaseMotanResponse(res)
+	assert.Nil(t, res.Value)
+	assert.Nil(t, res.Exception)
+
+	m := AcquireStringMap(4)
+	m.Store("k", "v")
+	ReleaseStringMap(m)
+	assert.Equal(t, 0, m.Len())
+	assert.False(t, m.pooled)
+
+	SetObjectPoolEnabled(false)
+	defer SetObjectPoolEnabled(true)
+	assert.False(t, AcquireMotanRequest().pooled)
+	assert.False(t, AcquireMotanResponse().pooled)
+	assert.False(t, AcquireStringMap(4).pooled)
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the names of the request id generators
+const (
+	TimestampRequestID = "timestamp" // the default one, the nanoseconds with a sequence in the low 20 bits
+	SnowflakeRequestID = "snowflake"
+	RandomRequestID    = "random"
+)
+
+// CorrelationIDKey is the attachment key of the correlation id, which is shared by all calls of a request chain
+// and is written to the access logs, the traces and the exceptions
+const CorrelationIDKey = "M_cid"
+
+// RequestIDGenerator generates the ids of the requests made by the clients, the ids should be unique in the process
+type RequestIDGenerator interface {
+	NewRequestID() uint64
+}
+
+// RequestIDGeneratorFunc is the RequestIDGenerator of a func, it can be used for the ids supplied by other systems
+type RequestIDGeneratorFunc func() uint64
+
+func (f RequestIDGeneratorFunc) NewRequestID() uint64 {
+	return f()
+}
+
+var (
+	requestIDGenerators = map[string]func() RequestIDGenerator{
+		TimestampRequestID: func() RequestIDGenerator { return &timestampGenerator{} },
+		SnowflakeRequestID: func() RequestIDGenerator { return NewSnowflakeGenerator(defaultWorkerID()) },
+		RandomRequestID:    func() RequestIDGenerator { return randomGenerator{} },
+	}
+	requestIDGeneratorsLock sync.Mutex
+	requestIDGenerator      atomic.Value // generatorHolder
+)
+
+type generatorHolder struct {
+	generator RequestIDGenerator
+}
+
+type correlationIDKey struct{}
+
+// RegistRequestIDGenerator registers the generator by the name, which can be used by UseRequestIDGenerator
+func RegistRequestIDGenerator(name string, newGenerator func() RequestIDGenerator) {
+	requestIDGeneratorsLock.Lock()
+	requestIDGenerators[name] = newGenerator
+	requestIDGeneratorsLock.Unlock()
+}
+
+// UseRequestIDGenerator sets the generator registered by the name as the generator of the requests
+func UseRequestIDGenerator(name string) error {
+	requestIDGeneratorsLock.Lock()
+	newGenerator, ok := requestIDGenerators[name]
+	requestIDGeneratorsLock.Unlock()
+	if !ok {
+		return errors.New("request id generator not found: " + name)
+	}
+	SetRequestIDGenerator(newGenerator())
+	return nil
+}
+
+// SetRequestIDGenerator sets the generator of the requests, the default generator is used if nil
+func SetRequestIDGenerator(generator RequestIDGenerator) {
+	requestIDGenerator.Store(generatorHolder{generator: generator})
+}
+
+var defaultGenerator = &timestampGenerator{}
+
+// NewRequestID returns a new request id by the generator set
+func NewRequestID() uint64 {
+	if h, ok := requestIDGenerator.Load().(generatorHolder); ok && h.generator != nil {
+		return h.generator.NewRequestID()
+	}
+	return defaultGenerator.NewRequestID()
+}
+
+type timestampGenerator struct {
+	offset uint64
+}
+
+func (g *timestampGenerator) NewRequestID() uint64 {
+	ns := uint64(time.Now().UnixNano())
+	offset := atomic.AddUint64(&g.offset, 1)
+	return (ns & 0xfffffffffff00000) | (offset & 0x000fffff)
+}
+
+// the epoch of the snowflake ids, 2020-01-01 00:00:00 UTC in milliseconds
+const snowflakeEpoch = 1577836800000
+
+// SnowflakeGenerator generates the ids of 41 bits milliseconds, 10 bits worker id and 12 bits sequence
+type SnowflakeGenerator struct {
+	lock     sync.Mutex
+	workerID uint64
+	lastMs   int64
+	sequence uint64
+}
+
+// NewSnowflakeGenerator returns the snowflake generator of the worker, only the low 10 bits of the worker id are used
+func NewSnowflakeGenerator(workerID int64) *SnowflakeGenerator {
+	return &SnowflakeGenerator{workerID: uint64(workerID) & 0x3ff}
+}
+
+func (g *SnowflakeGenerator) NewRequestID() uint64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	if ms < g.lastMs {
+		// the clock moved backwards, the ids keep increasing with the last time
+		ms = g.lastMs
+	}
+	if ms == g.lastMs {
+		g.sequence = (g.sequence + 1) & 0xfff
+		if g.sequence == 0 {
+			// the sequence of the millisecond is exhausted, borrow the next one
+			ms++
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = ms
+	return uint64(ms-snowflakeEpoch)<<22 | g.workerID<<12 | g.sequence
+}
+
+// defaultWorkerID is the worker id of the snowflake generator derived from the local ip and the pid
+func defaultWorkerID() int64 {
+	h := fnv.New32a()
+	h.Write([]byte(GetLocalIP() + ":" + strconv.Itoa(os.Getpid())))
+	return int64(h.Sum32())
+}
+
+type randomGenerator struct{}
+
+func (randomGenerator) NewRequestID() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return defaultGenerator.NewRequestID()
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// NewCorrelationID returns a new correlation id by the request id generator
+func NewCorrelationID() string {
+	return strconv.FormatUint(NewRequestID(), 16)
+}
+
+// WithCorrelationID returns the context carrying the correlation id for the calls made with the context
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+func CorrelationIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// EnsureCorrelationID returns the correlation id of the request. the id of the context or a new one is set to the
+// request if the request has no correlation id
+func EnsureCorrelationID(ctx context.Context, request Request) string {
+	if id := request.GetAttachment(CorrelationIDKey); id != "" {
+		return id
+	}
+	id := CorrelationIDFromContext(ctx)
+	if id == "" {
+		id = NewCorrelationID()
+	}
+	request.SetAttachment(CorrelationIDKey, id)
+	return id
+}
+
+// SetExceptionCorrelationID sets the correlation id to the exception of the response, the exception is copied as
+// it may be shared by the responses
+func SetExceptionCorrelationID(response Response, id string) {
+	res, ok := response.(*MotanResponse)
+	if !ok || res == nil || res.Exception == nil || id == "" || res.Exception.CorrelationID != "" {
+		return
+	}
+	e := *res.Exception
+	e.CorrelationID = id
+	res.Exception = &e
+}
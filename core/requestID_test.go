@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDGenerator(t *testing.T) {
+	defer SetRequestIDGenerator(nil)
+	for _, name := range []string{TimestampRequestID, SnowflakeRequestID, RandomRequestID} {
+		assert.Nil(t, UseRequestIDGenerator(name))
+		ids := make(map[uint64]bool, 10000)
+		for i := 0; i < 10000; i++ {
+			ids[NewRequestID()] = true
+		}
+		assert.Equal(t, 10000, len(ids), name)
+	}
+	assert.NotNil(t, UseRequestIDGenerator("unknown"))
+
+	g := NewSnowflakeGenerator(5)
+	last := g.NewRequestID()
+	assert.Equal(t, uint64(5), last>>12&0x3ff)
+	for i := 0; i < 10000; i++ {
+		id := g.NewRequestID()
+		assert.True(t, id > last)
+		last = id
+	}
+
+	var next uint64
+	RegistRequestIDGenerator("external", func() RequestIDGenerator {
+		return RequestIDGeneratorFunc(func() uint64 {
+			next++
+			return next
+		})
+	})
+	assert.Nil(t, UseRequestIDGenerator("external"))
+	assert.Equal(t, uint64(1), NewRequestID())
+	assert.Equal(t, "2", NewCorrelationID())
+}
+
+func TestCorrelationID(t *testing.T) {
+	request := &MotanRequest{Attachment: NewStringMap(0)}
+	cid := EnsureCorrelationID(context.Background(), request)
+	assert.NotEmpty(t, cid)
+	assert.Equal(t, cid, request.GetAttachment(CorrelationIDKey))
+	assert.Equal(t, cid, EnsureCorrelationID(WithCorrelationID(context.Background(), "other"), request))
+
+	ctx := WithCorrelationID(context.Background(), cid)
+	assert.Equal(t, cid, CorrelationIDFromContext(ctx))
+	outgoing := &MotanRequest{Attachment: NewStringMap(0)}
+	assert.Equal(t, cid, EnsureCorrelationID(ctx, outgoing))
+	assert.Equal(t, cid, outgoing.GetAttachment(CorrelationIDKey))
+
+	shared := NewException(ErrCodeInternal, "fail")
+	response := &MotanResponse{Exception: shared}
+	SetExceptionCorrelationID(response, cid)
+	assert.Equal(t, cid, response.GetException().CorrelationID)
+	assert.Equal(t, "", shared.CorrelationID, "the shared exception is not changed")
+	data, _ := json.Marshal(response.GetException())
+	assert.Contains(t, string(data), `"cid":"`+cid+`"`)
+}
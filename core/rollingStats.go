@@ -0,0 +1,155 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// RollingStatsWindow is the max seconds of the window of RollingStats
+	RollingStatsWindow = 60
+)
+
+// the upper bounds of the latency buckets, the latencies over the last bound are counted in the overflow bucket
+var rollingLatencyBounds = [...]time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+type rollingSlot struct {
+	sec     int64 // unix seconds of the counts
+	calls   int64
+	errors  int64
+	latency int64
+	buckets [len(rollingLatencyBounds) + 1]int64
+}
+
+// RollingStats counts the calls, the errors and the latency histogram per second in the last RollingStatsWindow
+// seconds. the counts are added by atomic operations, the lock is only used when a second begins
+type RollingStats struct {
+	lock  sync.Mutex
+	slots [RollingStatsWindow + 1]rollingSlot // one more for the current second
+}
+
+func (r *RollingStats) Record(latency time.Duration, failed bool) {
+	r.record(time.Now().Unix(), latency, failed)
+}
+
+func (r *RollingStats) record(now int64, latency time.Duration, failed bool) {
+	s := &r.slots[now%int64(len(r.slots))]
+	if atomic.LoadInt64(&s.sec) != now {
+		r.lock.Lock()
+		if atomic.LoadInt64(&s.sec) != now {
+			atomic.StoreInt64(&s.calls, 0)
+			atomic.StoreInt64(&s.errors, 0)
+			atomic.StoreInt64(&s.latency, 0)
+			for i := range s.buckets {
+				atomic.StoreInt64(&s.buckets[i], 0)
+			}
+			atomic.StoreInt64(&s.sec, now)
+		}
+		r.lock.Unlock()
+	}
+	atomic.AddInt64(&s.calls, 1)
+	atomic.AddInt64(&s.latency, int64(latency))
+	if failed {
+		atomic.AddInt64(&s.errors, 1)
+	}
+	i := 0
+	for i < len(rollingLatencyBounds) && latency > rollingLatencyBounds[i] {
+		i++
+	}
+	atomic.AddInt64(&s.buckets[i], 1)
+}
+
+// Window sums the counts of the last seconds, the current second is not included as it is not finished
+func (r *RollingStats) Window(seconds int) *RollingWindow {
+	return r.window(time.Now().Unix(), seconds)
+}
+
+func (r *RollingStats) window(now int64, seconds int) *RollingWindow {
+	if seconds > RollingStatsWindow {
+		seconds = RollingStatsWindow
+	}
+	w := &RollingWindow{Seconds: seconds}
+	for sec := now - int64(seconds); sec < now; sec++ {
+		s := &r.slots[sec%int64(len(r.slots))]
+		if atomic.LoadInt64(&s.sec) != sec {
+			continue
+		}
+		w.Calls += atomic.LoadInt64(&s.calls)
+		w.Errors += atomic.LoadInt64(&s.errors)
+		w.Latency += time.Duration(atomic.LoadInt64(&s.latency))
+		for i := range s.buckets {
+			w.buckets[i] += atomic.LoadInt64(&s.buckets[i])
+		}
+	}
+	return w
+}
+
+// RollingWindow is the counts in a window of RollingStats, the windows of the same seconds can be merged
+type RollingWindow struct {
+	Seconds int
+	Calls   int64
+	Errors  int64
+	Latency time.Duration
+	buckets [len(rollingLatencyBounds) + 1]int64
+}
+
+func (w *RollingWindow) Merge(other *RollingWindow) {
+	w.Calls += other.Calls
+	w.Errors += other.Errors
+	w.Latency += other.Latency
+	for i := range w.buckets {
+		w.buckets[i] += other.buckets[i]
+	}
+}
+
+func (w *RollingWindow) QPS() float64 {
+	if w.Seconds <= 0 {
+		return 0
+	}
+	return float64(w.Calls) / float64(w.Seconds)
+}
+
+func (w *RollingWindow) ErrorRate() float64 {
+	if w.Calls == 0 {
+		return 0
+	}
+	return float64(w.Errors) / float64(w.Calls)
+}
+
+func (w *RollingWindow) AvgLatency() time.Duration {
+	if w.Calls == 0 {
+		return 0
+	}
+	return w.Latency / time.Duration(w.Calls)
+}
+
+// Percentile estimates the latency of the percentile(0-100) by the linear interpolation in the bucket,
+// the latencies over the last bound are estimated as the last bound
+func (w *RollingWindow) Percentile(p float64) time.Duration {
+	if w.Calls == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(w.Calls)
+	var count float64
+	for i, n := range w.buckets {
+		if n == 0 || count+float64(n) < rank {
+			count += float64(n)
+			continue
+		}
+		if i == len(rollingLatencyBounds) {
+			break
+		}
+		lower := time.Duration(0)
+		if i > 0 {
+			lower = rollingLatencyBounds[i-1]
+		}
+		upper := rollingLatencyBounds[i]
+		return lower + time.Duration(float64(upper-lower)*(rank-count)/float64(n))
+	}
+	return rollingLatencyBounds[len(rollingLatencyBounds)-1]
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollingStats(t *testing.T) {
+	r := &RollingStats{}
+	now := time.Now().Unix()
+	// 10 calls per second in the last 60 seconds, 1 error per second in the last 10 seconds
+	for sec := now - 60; sec < now; sec++ {
+		for i := 0; i < 10; i++ {
+			r.record(sec, time.Duration(i+1)*time.Millisecond, sec >= now-10 && i == 0)
+		}
+	}
+	// the calls of the current second are not in the windows
+	r.record(now, time.Second, true)
+
+	w := r.window(now, 1)
+	if w.Calls != 10 || w.QPS() != 10 || w.ErrorRate() != 0.1 {
+		t.Errorf("wrong 1s window. calls:%d, qps:%v, error rate:%v", w.Calls, w.QPS(), w.ErrorRate())
+	}
+	w = r.window(now, 60)
+	if w.Calls != 600 || w.QPS() != 10 || w.Errors != 10 {
+		t.Errorf("wrong 60s window. calls:%d, qps:%v, errors:%d", w.Calls, w.QPS(), w.Errors)
+	}
+	if avg := w.AvgLatency(); avg != 5500*time.Microsecond {
+		t.Errorf("wrong avg latency: %v", avg)
+	}
+	// the latencies 1ms-10ms are evenly distributed
+	if p := w.Percentile(50); p < 4*time.Millisecond || p > 6*time.Millisecond {
+		t.Errorf("wrong p50: %v", p)
+	}
+	if p := w.Percentile(99); p < 9*time.Millisecond || p > 10*time.Millisecond {
+		t.Errorf("wrong p99: %v", p)
+	}
+
+	merged := r.window(now, 10)
+	merged.Merge(r.window(now, 10))
+	if merged.Calls != 200 || merged.QPS() != 20 {
+		t.Errorf("wrong merged window. calls:%d, qps:%v", merged.Calls, merged.QPS())
+	}
+
+	// the slot of the second now-1 is reused by the second now+60
+	r.record(now+60, time.Millisecond, false)
+	if w = r.window(now+61, 60); w.Calls != 1 {
+		t.Errorf("expired seconds should not be in the window. calls:%d", w.Calls)
+	}
+	if w = r.window(now, 60); w.Calls != 590 {
+		t.Errorf("the reused slot should not be in the window. calls:%d", w.Calls)
+	}
+}
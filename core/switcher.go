@@ -1,24 +1,39 @@
 package core
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/weibocom/motan-go/log"
 )
 
 var (
-	manager = &SwitcherManager{switcherMap: make(map[string]*Switcher)}
+	manager = &SwitcherManager{switcherMap: make(map[string]*Switcher), intSwitcherMap: make(map[string]*IntSwitcher)}
 )
 
+// SwitcherManager keeps the named bool switchers and int switchers registered by the filters, the clusters and the
+// servers. the values set at runtime survive restart if the persistence is enabled
 type SwitcherManager struct {
-	switcherLock sync.RWMutex
-	switcherMap  map[string]*Switcher
+	switcherLock   sync.RWMutex
+	switcherMap    map[string]*Switcher
+	intSwitcherMap map[string]*IntSwitcher
+
+	persistLock sync.Mutex
+	persistFile string
+	persisted   map[string]interface{} // the values set at runtime, bool or float64 as decoded from json
 }
 
 type SwitcherListener interface {
 	Notify(value bool)
 }
 
+type IntSwitcherListener interface {
+	Notify(value int64)
+}
+
 func GetSwitcherManager() *SwitcherManager {
 	return manager
 }
@@ -34,7 +49,10 @@ func (s *SwitcherManager) Register(name string, value bool, listeners ...Switche
 		vlog.Warningf("[switcher] register failed: %s has been registered\n", name)
 		return
 	}
-	newSwitcher := &Switcher{name: name, value: value, listeners: []SwitcherListener{}}
+	if v, ok := s.getPersisted(name).(bool); ok {
+		value = v
+	}
+	newSwitcher := &Switcher{name: name, value: value, listeners: []SwitcherListener{}, manager: s}
 	if len(listeners) > 0 {
 		newSwitcher.Watch(listeners...)
 	}
@@ -42,6 +60,26 @@ func (s *SwitcherManager) Register(name string, value bool, listeners ...Switche
 	vlog.Infof("[switcher] register %s success, value:%v, len(listeners):%d\n", name, value, len(listeners))
 }
 
+// RegisterInt registers an int switcher, such as a limit which can be changed at runtime
+func (s *SwitcherManager) RegisterInt(name string, value int64, listeners ...IntSwitcherListener) {
+	if name == "" {
+		vlog.Warningln("[switcher] register failed: switcher name is empty")
+		return
+	}
+	s.switcherLock.Lock()
+	defer s.switcherLock.Unlock()
+	if _, ok := s.intSwitcherMap[name]; ok {
+		vlog.Warningf("[switcher] register failed: %s has been registered\n", name)
+		return
+	}
+	if v, ok := s.getPersisted(name).(float64); ok {
+		value = int64(v)
+	}
+	newSwitcher := &IntSwitcher{name: name, value: value, listeners: listeners, manager: s}
+	s.intSwitcherMap[name] = newSwitcher
+	vlog.Infof("[switcher] register %s success, value:%d, len(listeners):%d\n", name, value, len(listeners))
+}
+
 func (s *SwitcherManager) GetAllSwitchers() map[string]bool {
 	s.switcherLock.RLock()
 	defer s.switcherLock.RUnlock()
@@ -62,11 +100,99 @@ func (s *SwitcherManager) GetSwitcher(name string) *Switcher {
 	return nil
 }
 
+func (s *SwitcherManager) GetAllIntSwitchers() map[string]int64 {
+	s.switcherLock.RLock()
+	defer s.switcherLock.RUnlock()
+	result := make(map[string]int64)
+	for key, sw := range s.intSwitcherMap {
+		result[key] = sw.Value()
+	}
+	return result
+}
+
+func (s *SwitcherManager) GetIntSwitcher(name string) *IntSwitcher {
+	s.switcherLock.RLock()
+	defer s.switcherLock.RUnlock()
+	if sw, ok := s.intSwitcherMap[name]; ok {
+		return sw
+	}
+	vlog.Warningf("[switcher] get int switcher failed: %s is not registered\n", name)
+	return nil
+}
+
+// EnablePersistence saves the values set at runtime to the file, and restores the saved values to the switchers,
+// including the switchers registered later
+func (s *SwitcherManager) EnablePersistence(file string) {
+	persisted := make(map[string]interface{})
+	if data, err := ioutil.ReadFile(file); err == nil {
+		if err = json.Unmarshal(data, &persisted); err != nil {
+			vlog.Warningf("[switcher] persisted file is broken and ignored. file:%s, err:%v\n", file, err)
+		}
+	} else if !os.IsNotExist(err) {
+		vlog.Warningf("[switcher] read persisted file fail. file:%s, err:%v\n", file, err)
+	}
+	s.persistLock.Lock()
+	s.persistFile = file
+	s.persisted = persisted
+	s.persistLock.Unlock()
+	for name, v := range persisted {
+		switch v := v.(type) {
+		case bool:
+			if sw := s.lookupSwitcher(name); sw != nil {
+				sw.SetValue(v)
+			}
+		case float64:
+			if sw := s.lookupIntSwitcher(name); sw != nil {
+				sw.SetValue(int64(v))
+			}
+		}
+	}
+	vlog.Infof("[switcher] persistence enabled. file:%s, persisted:%d\n", file, len(persisted))
+}
+
+func (s *SwitcherManager) lookupSwitcher(name string) *Switcher {
+	s.switcherLock.RLock()
+	defer s.switcherLock.RUnlock()
+	return s.switcherMap[name]
+}
+
+func (s *SwitcherManager) lookupIntSwitcher(name string) *IntSwitcher {
+	s.switcherLock.RLock()
+	defer s.switcherLock.RUnlock()
+	return s.intSwitcherMap[name]
+}
+
+func (s *SwitcherManager) getPersisted(name string) interface{} {
+	s.persistLock.Lock()
+	defer s.persistLock.Unlock()
+	return s.persisted[name]
+}
+
+func (s *SwitcherManager) persist(name string, value interface{}) {
+	s.persistLock.Lock()
+	defer s.persistLock.Unlock()
+	if s.persistFile == "" {
+		return
+	}
+	s.persisted[name] = value
+	data, _ := json.Marshal(s.persisted)
+	// replaced by rename, so the file is not broken if the process exits when writing
+	tmp := s.persistFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		vlog.Errorf("[switcher] persist fail. file:%s, err:%v\n", s.persistFile, err)
+		return
+	}
+	if err := os.Rename(tmp, s.persistFile); err != nil {
+		vlog.Errorf("[switcher] persist fail. file:%s, err:%v\n", s.persistFile, err)
+	}
+}
+
 type Switcher struct {
 	name         string
 	value        bool
 	listenerLock sync.RWMutex
 	listeners    []SwitcherListener
+	manager      *SwitcherManager
 }
 
 func (s *Switcher) GetName() string {
@@ -93,6 +219,9 @@ func (s *Switcher) SetValue(value bool) {
 	}
 	s.value = value
 	vlog.Infof("[switcher] value changed, name:%s, value:%v\n", name, value)
+	if s.manager != nil {
+		s.manager.persist(name, value)
+	}
 	listeners := s.listeners
 	if listeners != nil {
 		go func() {
@@ -105,3 +234,43 @@ func (s *Switcher) SetValue(value bool) {
 		}()
 	}
 }
+
+type IntSwitcher struct {
+	name         string
+	value        int64
+	listenerLock sync.RWMutex
+	listeners    []IntSwitcherListener
+	manager      *SwitcherManager
+}
+
+func (s *IntSwitcher) GetName() string {
+	return s.name
+}
+
+func (s *IntSwitcher) Value() int64 {
+	return atomic.LoadInt64(&s.value)
+}
+
+func (s *IntSwitcher) Watch(listeners ...IntSwitcherListener) {
+	s.listenerLock.Lock()
+	defer s.listenerLock.Unlock()
+	s.listeners = append(s.listeners, listeners...)
+	vlog.Infof("[switcher] watch %s success. len(listeners):%d\n", s.GetName(), len(listeners))
+}
+
+func (s *IntSwitcher) SetValue(value int64) {
+	if atomic.SwapInt64(&s.value, value) == value {
+		return
+	}
+	vlog.Infof("[switcher] value changed, name:%s, value:%d\n", s.name, value)
+	if s.manager != nil {
+		s.manager.persist(s.name, value)
+	}
+	go func() {
+		s.listenerLock.RLock()
+		defer s.listenerLock.RUnlock()
+		for _, listener := range s.listeners {
+			listener.Notify(value)
+		}
+	}()
+}
@@ -1,6 +1,9 @@
 package core
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -56,3 +59,66 @@ func TestSwitcher(t *testing.T) {
 		t.Error("GetAllSwitchers error. expect: 3, return:", len(switchers))
 	}
 }
+
+type intListener struct {
+	value int64
+	lock  sync.Mutex
+}
+
+func (l *intListener) Notify(value int64) {
+	l.lock.Lock()
+	l.value = value
+	l.lock.Unlock()
+}
+
+func (l *intListener) Value() int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.value
+}
+
+func TestIntSwitcher(t *testing.T) {
+	s := &SwitcherManager{switcherMap: make(map[string]*Switcher), intSwitcherMap: make(map[string]*IntSwitcher)}
+	l := &intListener{}
+	s.RegisterInt("limit", 100, l)
+	s.RegisterInt("limit", 200)
+	if s.GetIntSwitcher("limit").Value() != 100 {
+		t.Errorf("wrong int switcher value: %d", s.GetIntSwitcher("limit").Value())
+	}
+	s.GetIntSwitcher("limit").SetValue(50)
+	time.Sleep(100 * time.Millisecond) //wait notify
+	if l.Value() != 50 {
+		t.Errorf("int switcher listener not notified. value:%d", l.Value())
+	}
+	if all := s.GetAllIntSwitchers(); len(all) != 1 || all["limit"] != 50 || len(s.GetAllSwitchers()) != 0 {
+		t.Errorf("wrong int switchers: %v", all)
+	}
+}
+
+func TestSwitcherPersistence(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "switcher")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "switchers.json")
+	s := &SwitcherManager{switcherMap: make(map[string]*Switcher), intSwitcherMap: make(map[string]*IntSwitcher)}
+	s.Register("early", true)
+	s.EnablePersistence(file)
+	s.Register("flag", true)
+	s.RegisterInt("limit", 100)
+	s.GetSwitcher("early").SetValue(false)
+	s.GetSwitcher("flag").SetValue(false)
+	s.GetIntSwitcher("limit").SetValue(20)
+
+	// restarted, the switchers registered before and after the persistence is enabled are restored
+	s = &SwitcherManager{switcherMap: make(map[string]*Switcher), intSwitcherMap: make(map[string]*IntSwitcher)}
+	s.Register("early", true)
+	s.EnablePersistence(file)
+	s.Register("flag", true)
+	s.Register("other", true)
+	s.RegisterInt("limit", 100)
+	if s.GetSwitcher("early").IsOpen() || s.GetSwitcher("flag").IsOpen() || !s.GetSwitcher("other").IsOpen() {
+		t.Errorf("wrong restored switchers: %v", s.GetAllSwitchers())
+	}
+	if s.GetIntSwitcher("limit").Value() != 20 {
+		t.Errorf("wrong restored int switcher: %d", s.GetIntSwitcher("limit").Value())
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"strconv"
+	"time"
+)
+
+// the attachment keys of the throttle hints, which are returned by the servers with the requests rejected by the
+// rate limits or the overload protection
+const (
+	ThrottleRetryAfterKey = "M_tra" // the milliseconds the client should wait before calling the server again
+	ThrottleRemainingKey  = "M_trq" // the remaining quota of the current window, -1 if unknown
+)
+
+// ThrottleHint tells the client how long to back off the server which rejected the request
+type ThrottleHint struct {
+	RetryAfter time.Duration
+	Remaining  int64
+}
+
+// SetThrottleHint sets the hint to the attachments of the response
+func SetThrottleHint(response Response, hint ThrottleHint) {
+	if response == nil || hint.RetryAfter <= 0 {
+		return
+	}
+	response.SetAttachment(ThrottleRetryAfterKey, strconv.FormatInt(int64((hint.RetryAfter+time.Millisecond-1)/time.Millisecond), 10))
+	response.SetAttachment(ThrottleRemainingKey, strconv.FormatInt(hint.Remaining, 10))
+}
+
+// GetThrottleHint returns the hint of the response, ok is false if the response is not throttled
+func GetThrottleHint(response Response) (hint ThrottleHint, ok bool) {
+	if response == nil {
+		return hint, false
+	}
+	ms, err := strconv.ParseInt(response.GetAttachment(ThrottleRetryAfterKey), 10, 64)
+	if err != nil || ms <= 0 {
+		return hint, false
+	}
+	hint.RetryAfter = time.Duration(ms) * time.Millisecond
+	hint.Remaining = -1
+	if remaining, err := strconv.ParseInt(response.GetAttachment(ThrottleRemainingKey), 10, 64); err == nil {
+		hint.Remaining = remaining
+	}
+	return hint, true
+}
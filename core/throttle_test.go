@@ -0,0 +1,26 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottleHint(t *testing.T) {
+	res := BuildExceptionResponse(1, NewRejectException("rate limited"))
+	_, ok := GetThrottleHint(res)
+	assert.False(t, ok)
+
+	SetThrottleHint(res, ThrottleHint{RetryAfter: 1500 * time.Microsecond, Remaining: 3})
+	assert.Equal(t, "2", res.GetAttachment(ThrottleRetryAfterKey), "rounded up to milliseconds")
+	hint, ok := GetThrottleHint(res)
+	assert.True(t, ok)
+	assert.Equal(t, ThrottleHint{RetryAfter: 2 * time.Millisecond, Remaining: 3}, hint)
+
+	res = BuildExceptionResponse(1, NewServerBusyException("busy"))
+	res.SetAttachment(ThrottleRetryAfterKey, "100")
+	hint, ok = GetThrottleHint(res)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-1), hint.Remaining, "remaining is unknown")
+}
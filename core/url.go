@@ -5,6 +5,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/weibocom/motan-go/log"
@@ -18,30 +20,81 @@ type URL struct {
 	Group      string            `json:"group"`
 	Parameters map[string]string `json:"parameters"`
 
-	// cached info
-	address  string
+	// the info derived from the fields, which is computed once and shared by the callers. ClearCachedInfo must
+	// be called after the fields updated. the parsed int params are checked against the raw params when read.
+	// CopyWithParams gets a new url with the params updated instead of updating the url in use
+	derived atomic.Value // *urlDerived
+}
+
+// the parsed int params and the method param keys cached by a url at most
+const maxCachedIntParams = 1024
+
+type urlDerived struct {
 	identity string
+	address  string
+	portStr  string
+
+	intParams     sync.Map // param key -> intParam
+	intParamCount int32
+	methodKeys    sync.Map // methodParamKey -> the param key of the method
+}
+
+type methodParamKey struct {
+	method string
+	desc   string
+	key    string
+}
+
+// intParam is the parsed value of the raw param, it is parsed again if the param changed
+type intParam struct {
+	raw   string
+	value int64
+	ok    bool
+}
+
+func (u *URL) getDerived() *urlDerived {
+	if d, ok := u.derived.Load().(*urlDerived); ok && d != nil {
+		return d
+	}
+	d := &urlDerived{portStr: strconv.FormatInt(int64(u.Port), 10)}
+	d.identity = u.Protocol + "://" + u.Host + ":" + d.portStr + "/" + u.Path + "?group=" + u.Group
+	if u.IsUnixSocket() {
+		d.address = u.Host
+	} else {
+		d.address = u.Host + ":" + d.portStr
+	}
+	u.derived.Store(d)
+	return d
+}
+
+func (u *URL) methodParamKey(method string, desc string, key string) string {
+	d := u.getDerived()
+	k := methodParamKey{method: method, desc: desc, key: key}
+	if v, ok := d.methodKeys.Load(k); ok {
+		return v.(string)
+	}
+	mkey := method + "(" + desc + ")." + key
+	if atomic.AddInt32(&d.intParamCount, 1) <= maxCachedIntParams {
+		d.methodKeys.Store(k, mkey)
+	}
+	return mkey
 }
 
 var (
 	defaultSerialize = "simple"
 )
 
-//TODO int param cache
+// UnixSocketPrefix is the host prefix of unix domain socket urls, e.g. "unix:/var/run/motan.sock"
+const UnixSocketPrefix = "unix:"
 
 // GetIdentity return the identity of url. identity info includes protocol, host, port, path, group
 // the identity will cached, so must clear cached info after update above info by calling ClearCachedInfo()
 func (u *URL) GetIdentity() string {
-	if u.identity != "" {
-		return u.identity
-	}
-	u.identity = u.Protocol + "://" + u.Host + ":" + u.GetPortStr() + "/" + u.Path + "?group=" + u.Group
-	return u.identity
+	return u.getDerived().identity
 }
 
 func (u *URL) ClearCachedInfo() {
-	u.address = ""
-	u.identity = ""
+	u.derived.Store((*urlDerived)(nil))
 }
 
 func (u *URL) GetPositiveIntValue(key string, defaultvalue int64) int64 {
@@ -61,13 +114,23 @@ func (u *URL) GetIntValue(key string, defaultValue int64) int64 {
 }
 
 func (u *URL) GetInt(key string) (i int64, b bool) {
-	if v, ok := u.Parameters[key]; ok {
-		intvalue, err := strconv.ParseInt(v, 10, 64)
-		if err == nil {
-			return intvalue, true
-		}
+	v, ok := u.Parameters[key]
+	if !ok {
+		return 0, false
+	}
+	d := u.getDerived()
+	if c, ok := d.intParams.Load(key); ok && c.(intParam).raw == v {
+		return c.(intParam).value, c.(intParam).ok
+	}
+	intvalue, err := strconv.ParseInt(v, 10, 64)
+	p := intParam{raw: v, value: intvalue, ok: err == nil}
+	if !p.ok {
+		p.value = 0
 	}
-	return 0, b
+	if atomic.AddInt32(&d.intParamCount, 1) <= maxCachedIntParams {
+		d.intParams.Store(key, p)
+	}
+	return p.value, p.ok
 }
 
 func (u *URL) GetStringParamsWithDefault(key string, defaultvalue string) string {
@@ -82,8 +145,7 @@ func (u *URL) GetStringParamsWithDefault(key string, defaultvalue string) string
 }
 
 func (u *URL) GetMethodIntValue(method string, methodDesc string, key string, defaultValue int64) int64 {
-	mkey := method + "(" + methodDesc + ")." + key
-	result, b := u.GetInt(mkey)
+	result, b := u.GetInt(u.methodParamKey(method, methodDesc, key))
 	if b {
 		return result
 	}
@@ -102,6 +164,14 @@ func (u *URL) GetMethodPositiveIntValue(method string, methodDesc string, key st
 	return defaultValue
 }
 
+// GetMethodParam returns the method level param if exists, otherwise the service level param
+func (u *URL) GetMethodParam(method string, methodDesc string, key string, defaultValue string) string {
+	if v := u.GetParam(u.methodParamKey(method, methodDesc, key), ""); v != "" {
+		return v
+	}
+	return u.GetParam(key, defaultValue)
+}
+
 func (u *URL) GetParam(key string, defaultValue string) string {
 	if u.Parameters == nil || len(u.Parameters) == 0 {
 		return defaultValue
@@ -128,6 +198,7 @@ func (u *URL) PutParam(key string, value string) {
 		u.Parameters = make(map[string]string)
 	}
 	u.Parameters[key] = value
+	u.ClearCachedInfo()
 }
 
 func (u *URL) ToExtInfo() string {
@@ -184,15 +255,21 @@ func FromExtInfo(extinfo string) *URL {
 }
 
 func (u *URL) GetPortStr() string {
-	return strconv.FormatInt(int64(u.Port), 10)
+	return u.getDerived().portStr
 }
 
 func (u *URL) GetAddressStr() string {
-	if u.address != "" {
-		return u.address
-	}
-	u.address = u.Host + ":" + u.GetPortStr()
-	return u.address
+	return u.getDerived().address
+}
+
+// IsUnixSocket returns true if the host of url is a unix domain socket address
+func (u *URL) IsUnixSocket() bool {
+	return strings.HasPrefix(u.Host, UnixSocketPrefix)
+}
+
+// GetUnixSocketPath returns the socket file path of a unix domain socket url
+func (u *URL) GetUnixSocketPath() string {
+	return strings.TrimPrefix(u.Host, UnixSocketPrefix)
 }
 
 func (u *URL) Copy() *URL {
@@ -209,6 +286,24 @@ func (u *URL) MergeParams(params map[string]string) {
 	for k, v := range params {
 		u.Parameters[k] = v
 	}
+	u.ClearCachedInfo()
+}
+
+// CopyWithParams returns a copy of the url with the params put, the params with empty values are deleted
+func (u *URL) CopyWithParams(params map[string]string) *URL {
+	newURL := &URL{Protocol: u.Protocol, Host: u.Host, Port: u.Port, Group: u.Group, Path: u.Path}
+	newURL.Parameters = make(map[string]string, len(u.Parameters)+len(params))
+	for k, v := range u.Parameters {
+		newURL.Parameters[k] = v
+	}
+	for k, v := range params {
+		if v == "" {
+			delete(newURL.Parameters, k)
+		} else {
+			newURL.Parameters[k] = v
+		}
+	}
+	return newURL
 }
 
 func (u *URL) CanServe(other *URL) bool {
@@ -251,9 +346,12 @@ func IsSame(m1 map[string]string, m2 map[string]string, key string, defaultValue
 }
 
 func GetURLFilters(url *URL, extFactory ExtensionFactory) (clusterFilter ClusterFilter, endpointFilters []Filter) {
+	if url.GetParam(AuthKey, "") != "" {
+		// the auth filter gets the auth by the url of the chain
+		endpointFilters = append(endpointFilters, &authFilter{extFactory: extFactory})
+	}
 	if filters, ok := url.Parameters[FilterKey]; ok {
 		clusterFilters := make([]Filter, 0, 10)
-		endpointFilters = make([]Filter, 0, 10)
 		arr := TrimSplit(filters, ",")
 		for _, f := range arr {
 			filter := extFactory.GetFilter(f)
@@ -281,10 +379,9 @@ func GetURLFilters(url *URL, extFactory ExtensionFactory) (clusterFilter Cluster
 			}
 			clusterFilter = lastFilter
 		}
-		if len(endpointFilters) > 0 {
-			sort.Sort(filterSlice(endpointFilters))
-		}
-
+	}
+	if len(endpointFilters) > 0 {
+		sort.Sort(filterSlice(endpointFilters))
 	}
 	return clusterFilter, endpointFilters
 }
@@ -66,6 +66,18 @@ func TestGetInt(t *testing.T) {
 	v = url.GetMethodPositiveIntValue(method, methodDesc, key, 9)
 	intequals(9, v, t)
 
+	url.Parameters["verb"] = "GET"
+	if url.GetMethodParam(method, methodDesc, "verb", "POST") != "GET" {
+		t.Fatalf("service level param should be used")
+	}
+	url.Parameters[method+"("+methodDesc+").verb"] = "PUT"
+	if url.GetMethodParam(method, methodDesc, "verb", "POST") != "PUT" {
+		t.Fatalf("method level param should be used")
+	}
+	if url.GetMethodParam(method, methodDesc, "path", "/") != "/" {
+		t.Fatalf("default value should be used")
+	}
+
 }
 
 func intequals(expect int64, realvalue int64, t *testing.T) {
@@ -102,6 +114,49 @@ func TestCopyAndMerge(t *testing.T) {
 
 }
 
+func TestCopyWithParams(t *testing.T) {
+	url := &URL{Protocol: "motan2", Host: "127.0.0.1", Port: 8002, Path: "test.service", Group: "g1",
+		Parameters: map[string]string{"key1": "v1", "key2": "v2"}}
+	newURL := url.CopyWithParams(map[string]string{"key1": "new", "key2": "", "key3": "v3"})
+	if newURL.Parameters["key1"] != "new" || newURL.Parameters["key3"] != "v3" {
+		t.Fatalf("url copy with params not correct: %v", newURL.Parameters)
+	}
+	if _, ok := newURL.Parameters["key2"]; ok {
+		t.Fatal("the param with empty value should be deleted")
+	}
+	if url.Parameters["key1"] != "v1" || url.Parameters["key2"] != "v2" || len(url.Parameters) != 2 {
+		t.Fatalf("the params of the origin url should not be changed: %v", url.Parameters)
+	}
+	if newURL.GetIdentity() != url.GetIdentity() {
+		t.Fatalf("identity not equal, expect %s, real %s", url.GetIdentity(), newURL.GetIdentity())
+	}
+}
+
+func TestDerivedInfo(t *testing.T) {
+	url := &URL{Protocol: "motan2", Host: "127.0.0.1", Port: 8002, Path: "test.service", Group: "g1",
+		Parameters: map[string]string{"requestTimeout": "100", "m(s).requestTimeout": "200"}}
+	if url.GetAddressStr() != "127.0.0.1:8002" || url.GetPortStr() != "8002" {
+		t.Fatalf("address not correct: %s", url.GetAddressStr())
+	}
+	if url.GetIdentity() != "motan2://127.0.0.1:8002/test.service?group=g1" {
+		t.Fatalf("identity not correct: %s", url.GetIdentity())
+	}
+	url.Port = 8003
+	url.ClearCachedInfo()
+	if url.GetAddressStr() != "127.0.0.1:8003" || url.GetIdentity() != "motan2://127.0.0.1:8003/test.service?group=g1" {
+		t.Fatalf("cached info not cleared: %s, %s", url.GetAddressStr(), url.GetIdentity())
+	}
+
+	for i := 0; i < 2; i++ {
+		intequals(200, url.GetMethodIntValue("m", "s", "requestTimeout", 0), t)
+		intequals(100, url.GetMethodIntValue("m", "", "requestTimeout", 0), t)
+	}
+	url.PutParam("m(s).requestTimeout", "300")
+	intequals(300, url.GetMethodIntValue("m", "s", "requestTimeout", 0), t)
+	url.Parameters["requestTimeout"] = "x"
+	intequals(7, url.GetMethodIntValue("m", "", "requestTimeout", 7), t)
+}
+
 func TestCanServer(t *testing.T) {
 	params1 := make(map[string]string)
 	params2 := make(map[string]string)
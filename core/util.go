@@ -7,6 +7,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"unicode"
 
 	"github.com/weibocom/motan-go/log"
@@ -20,7 +21,8 @@ const (
 var (
 	PanicStatFunc func()
 
-	localIPs = make([]string, 0)
+	localIPs       atomic.Value // []string, cached once resolved with any ip
+	interfaceAddrs = net.InterfaceAddrs
 )
 
 func ParseExportInfo(export string) (string, int, error) {
@@ -43,6 +45,36 @@ func ParseExportInfo(export string) (string, int, error) {
 	return protocol, porti, err
 }
 
+// ExportURLs returns the url of each export of the service. the export param can have multiple exports separated by
+// comma, such as "motan2:8100,websocket:8101", so a service can be exported on multiple protocols and ports.
+// the url is returned itself if there is only one export, otherwise the copies of it are returned, the filters of
+// a copy are the filters of the service and the filters in the "<protocol>.filter" param
+func ExportURLs(url *URL) ([]*URL, error) {
+	exports := TrimSplit(url.GetParam(ExportKey, ""), ",")
+	if len(exports) <= 1 {
+		return []*URL{url}, nil
+	}
+	urls := make([]*URL, 0, len(exports))
+	for _, export := range exports {
+		protocol, port, err := ParseExportInfo(export)
+		if err != nil {
+			return nil, err
+		}
+		u := url.Copy()
+		u.Protocol = protocol
+		u.Port = port
+		u.PutParam(ExportKey, protocol+":"+strconv.Itoa(port))
+		if filters := url.GetParam(protocol+"."+FilterKey, ""); filters != "" {
+			if f := url.GetParam(FilterKey, ""); f != "" {
+				filters = f + "," + filters
+			}
+			u.PutParam(FilterKey, filters)
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
 func InterfaceToString(in interface{}) string {
 	rs := ""
 	switch in.(type) {
@@ -58,23 +90,28 @@ func InterfaceToString(in interface{}) string {
 	return rs
 }
 
-// GetLocalIPs ip from ipnet
+// GetLocalIPs ip from ipnet, the addresses are resolved again until any ip is found
 func GetLocalIPs() []string {
-	if len(localIPs) == 0 {
-		addrs, err := net.InterfaceAddrs()
-		if err != nil {
-			vlog.Warningf("get local ip fail. %s", err.Error())
-		} else {
-			for _, address := range addrs {
-				if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-					if ipnet.IP.To4() != nil {
-						localIPs = append(localIPs, ipnet.IP.String())
-					}
-				}
+	if ips, ok := localIPs.Load().([]string); ok {
+		return ips
+	}
+	ips := make([]string, 0)
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		vlog.Warningf("get local ip fail. %s", err.Error())
+		return ips
+	}
+	for _, address := range addrs {
+		if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
+			if ipnet.IP.To4() != nil {
+				ips = append(ips, ipnet.IP.String())
 			}
 		}
 	}
-	return localIPs
+	if len(ips) > 0 {
+		localIPs.Store(ips)
+	}
+	return ips
 }
 
 // GetLocalIP falg of localIP > ipnet
@@ -1,8 +1,11 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"strconv"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -30,6 +33,32 @@ func TestGetLocalIP(t *testing.T) {
 	}
 }
 
+func TestGetLocalIPsNotCachedIfEmpty(t *testing.T) {
+	defer func(f func() ([]net.Addr, error)) {
+		interfaceAddrs = f
+		localIPs = atomic.Value{}
+	}(interfaceAddrs)
+	localIPs = atomic.Value{}
+	calls := 0
+	var addrs []net.Addr
+	var err error
+	interfaceAddrs = func() ([]net.Addr, error) {
+		calls++
+		return addrs, err
+	}
+
+	err = errors.New("interfaces not ready")
+	assert.Empty(t, GetLocalIPs())
+	err = nil
+	addrs = []net.Addr{&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)}}
+	assert.Empty(t, GetLocalIPs(), "loopback ip should be ignored")
+	addrs = append(addrs, &net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(8, 32)})
+	assert.Equal(t, []string{"10.0.0.1"}, GetLocalIPs())
+	addrs = nil
+	assert.Equal(t, []string{"10.0.0.1"}, GetLocalIPs(), "resolved ips should be cached")
+	assert.Equal(t, 3, calls)
+}
+
 func TestSliceShuffle(t *testing.T) {
 	size := 32
 	s := make([]string, 0, size)
@@ -101,3 +130,37 @@ func TestSplitTrim(t *testing.T) {
 		assert.Equal(t, tt.expect, ret)
 	}
 }
+
+func TestExportURLs(t *testing.T) {
+	url := &URL{Protocol: "motan2", Path: "test", Parameters: map[string]string{ExportKey: "motan2:8100"}}
+	urls, err := ExportURLs(url)
+	if err != nil || len(urls) != 1 || urls[0] != url {
+		t.Errorf("single export should use the url itself. urls:%v, err:%v", urls, err)
+	}
+
+	url.PutParam(ExportKey, "motan2:8100, websocket:8101,8102")
+	url.PutParam(FilterKey, "accessLog")
+	url.PutParam("websocket."+FilterKey, "metrics")
+	urls, err = ExportURLs(url)
+	if err != nil || len(urls) != 3 {
+		t.Fatalf("wrong export urls. urls:%v, err:%v", urls, err)
+	}
+	expects := []struct {
+		protocol string
+		port     int
+		filter   string
+	}{{"motan2", 8100, "accessLog"}, {"websocket", 8101, "accessLog,metrics"}, {"motan2", 8102, "accessLog"}}
+	for i, e := range expects {
+		u := urls[i]
+		if u == url || u.Protocol != e.protocol || u.Port != e.port || u.GetParam(FilterKey, "") != e.filter || u.Path != "test" {
+			t.Errorf("wrong export url. url:%+v, expect:%+v", u, e)
+		}
+		if p, port, _ := ParseExportInfo(u.GetParam(ExportKey, "")); p != e.protocol || port != e.port {
+			t.Errorf("export param should be the single export. export:%s", u.GetParam(ExportKey, ""))
+		}
+	}
+	url.PutParam(ExportKey, "motan2:8100,motan2:port")
+	if _, err = ExportURLs(url); err == nil {
+		t.Errorf("wrong port should fail")
+	}
+}
@@ -0,0 +1,224 @@
+// Package ctl is the client of the agent admin api and the generic invoker of the services through the agent,
+// used by the command line tool motanctl.
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/weibocom/motan-go"
+	motancore "github.com/weibocom/motan-go/core"
+)
+
+const adminAPIPrefix = "/v2/"
+
+// Client calls the admin api of the manage port of an agent
+type Client struct {
+	Address    string // host:port of the manage port
+	Token      string // the admin_token of the agent
+	HTTPClient *http.Client
+}
+
+func NewClient(address string, token string) *Client {
+	return &Client{Address: address, Token: token, HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type apiResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// Get calls the admin api such as clusters, and returns the json body of the response
+func (c *Client) Get(api string, params url.Values) (json.RawMessage, error) {
+	return c.do(http.MethodGet, api, params, nil)
+}
+
+// Post calls the admin api with the params and the body, and returns the json body of the response
+func (c *Client) Post(api string, params url.Values, body []byte) (json.RawMessage, error) {
+	return c.do(http.MethodPost, api, params, body)
+}
+
+func (c *Client) do(method string, api string, params url.Values, body []byte) (json.RawMessage, error) {
+	u := "http://" + c.Address + adminAPIPrefix + api
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	r := &apiResponse{}
+	if err = json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("bad response of %s, status:%d, body:%s", api, res.StatusCode, data)
+	}
+	if r.Code != http.StatusOK {
+		return nil, fmt.Errorf("%s fail, code:%d, message:%s", api, r.Code, r.Message)
+	}
+	return r.Body, nil
+}
+
+func (c *Client) Clusters() (json.RawMessage, error) {
+	return c.Get("clusters", nil)
+}
+
+func (c *Client) Endpoints(cluster string) (json.RawMessage, error) {
+	return c.Get("endpoints", url.Values{"cluster": {cluster}})
+}
+
+func (c *Client) Registries() (json.RawMessage, error) {
+	return c.Get("registries", nil)
+}
+
+func (c *Client) Filters() (json.RawMessage, error) {
+	return c.Get("filters", nil)
+}
+
+func (c *Client) Config() (json.RawMessage, error) {
+	return c.Get("config", nil)
+}
+
+func (c *Client) Switchers() (json.RawMessage, error) {
+	return c.Get("switchers", nil)
+}
+
+func (c *Client) SetSwitcher(name string, value bool) (json.RawMessage, error) {
+	return c.Post("switchers", url.Values{"name": {name}, "value": {strconv.FormatBool(value)}}, nil)
+}
+
+func (c *Client) SetIntSwitcher(name string, value int64) (json.RawMessage, error) {
+	return c.Post("switchers", url.Values{"name": {name}, "value": {strconv.FormatInt(value, 10)}}, nil)
+}
+
+func (c *Client) Commands() (json.RawMessage, error) {
+	return c.Get("commands", nil)
+}
+
+// ApplyCommand applies the command json as the agent command of all the clusters
+func (c *Client) ApplyCommand(command []byte) (json.RawMessage, error) {
+	return c.Post("commands", nil, command)
+}
+
+// Reload reloads the config of the agent, the applied diff is returned
+func (c *Client) Reload() (json.RawMessage, error) {
+	return c.Post("reload", nil, nil)
+}
+
+func (c *Client) LogLevel() (json.RawMessage, error) {
+	return c.Get("loglevel", nil)
+}
+
+func (c *Client) SetLogLevel(level string) (json.RawMessage, error) {
+	return c.Post("loglevel", url.Values{"level": {level}}, nil)
+}
+
+// SetScopeLogLevel sets the log level of the scope such as endpoint, registry, cluster or service:{path}, or the
+// global level if the scope is empty. the level is kept until changed if the duration is 0
+func (c *Client) SetScopeLogLevel(scope string, level string, d time.Duration) (json.RawMessage, error) {
+	params := url.Values{"scope": {scope}, "level": {level}}
+	if d > 0 {
+		params.Set("duration", d.String())
+	}
+	return c.Post("loglevel", params, nil)
+}
+
+// ResetScopeLogLevel removes the log level of the scope, or the levels of all the scopes if the scope is empty
+func (c *Client) ResetScopeLogLevel(scope string) (json.RawMessage, error) {
+	return c.Post("loglevel", url.Values{"scope": {scope}, "reset": {"true"}}, nil)
+}
+
+// Health responds the health report of the endpoints
+func (c *Client) Health() (json.RawMessage, error) {
+	return c.Get("health", nil)
+}
+
+// DebugCall lets the agent execute a test call through the cluster of the service, or the endpoint if it is not empty,
+// and returns the value, the exception and the trace spans of the call
+func (c *Client) DebugCall(service string, method string, group string, endpoint string, args []interface{}, timeout time.Duration) (json.RawMessage, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"service":   service,
+		"method":    method,
+		"group":     group,
+		"endpoint":  endpoint,
+		"arguments": args,
+		"timeout":   int64(timeout / time.Millisecond),
+	})
+	return c.Post("call", nil, body)
+}
+
+// Stats returns the live stats of the cluster, or all the clusters if the cluster is empty
+func (c *Client) Stats(cluster string) (json.RawMessage, error) {
+	var params url.Values
+	if cluster != "" {
+		params = url.Values{"cluster": {cluster}}
+	}
+	return c.Get("stats", params)
+}
+
+// Discovery responds the cached discovery results of the agent
+func (c *Client) Discovery() (json.RawMessage, error) {
+	return c.Get("discovery", nil)
+}
+
+// FlushDiscovery flushes the cached discovery results of the key, or all the results if the key is empty
+func (c *Client) FlushDiscovery(key string) (json.RawMessage, error) {
+	var params url.Values
+	if key != "" {
+		params = url.Values{"key": {key}}
+	}
+	return c.Post("discovery", params, nil)
+}
+
+// Invoke calls the method of the service through the agent port by motan2 with the simple serialization,
+// the arguments are usually decoded from json
+func Invoke(address string, service string, method string, group string, args []interface{}, timeout time.Duration) (interface{}, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+	ext := motan.GetDefaultExtFactory()
+	u := &motancore.URL{Protocol: "motan2", Host: host, Port: p, Path: service, Group: group, Parameters: map[string]string{
+		motancore.SerializationKey: "simple",
+		motancore.TimeOutKey:       strconv.FormatInt(int64(timeout/time.Millisecond), 10),
+	}}
+	ep := ext.GetEndPoint(u)
+	if ep == nil {
+		return nil, errors.New("motan2 endpoint not found")
+	}
+	ep.SetSerialization(motancore.GetSerialization(u, ext))
+	motancore.Initialize(ep)
+	defer ep.Destroy()
+
+	request := &motancore.MotanRequest{ServiceName: service, Method: method, Arguments: args, Attachment: motancore.NewStringMap(motancore.DefaultAttachmentSize)}
+	request.GetRPCContext(true).ExtFactory = ext
+	// the value is deserialized as the types of the serialization without a reply
+	res := ep.Call(request)
+	if res.GetException() != nil {
+		return nil, fmt.Errorf("call fail, code:%d, message:%s", res.GetException().ErrCode, res.GetException().ErrMsg)
+	}
+	return res.GetValue(), nil
+}
@@ -0,0 +1,91 @@
+package ctl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weibocom/motan-go"
+	motancore "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/provider"
+	"github.com/weibocom/motan-go/server"
+)
+
+func TestClient(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":401,"message":"invalid admin token"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/switchers":
+			if r.Method != http.MethodPost || r.FormValue("name") != "s1" || r.FormValue("value") != "true" {
+				w.Write([]byte(`{"code":400,"message":"bad request"}`))
+				return
+			}
+			w.Write([]byte(`{"code":200,"message":"ok","body":{"s1":true}}`))
+		case "/v2/endpoints":
+			w.Write([]byte(`{"code":200,"message":"ok","body":[{"address":"` + r.FormValue("cluster") + `"}]}`))
+		default:
+			w.Write([]byte(`{"code":404,"message":"unknown admin api: ` + r.URL.Path + `"}`))
+		}
+	}))
+	defer s.Close()
+	address := strings.TrimPrefix(s.URL, "http://")
+	c := NewClient(address, "secret")
+	body, err := c.SetSwitcher("s1", true)
+	if err != nil || string(body) != `{"s1":true}` {
+		t.Errorf("wrong switcher response. body:%s, err:%v", body, err)
+	}
+	if body, err = c.Endpoints("g_0.1_motan2_test"); err != nil || !strings.Contains(string(body), "g_0.1_motan2_test") {
+		t.Errorf("wrong endpoints response. body:%s, err:%v", body, err)
+	}
+	if _, err = c.Clusters(); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("unknown api should fail. err:%v", err)
+	}
+	if _, err = NewClient(address, "wrong").Clusters(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("wrong token should fail. err:%v", err)
+	}
+}
+
+type echoService struct{}
+
+func (e *echoService) Echo(s string, n int64) map[string]interface{} {
+	return map[string]interface{}{"s": s, "n": n}
+}
+
+func TestInvoke(t *testing.T) {
+	ext := motan.GetDefaultExtFactory()
+	p := &provider.DefaultProvider{}
+	p.SetURL(&motancore.URL{Path: "echoService"})
+	p.SetService(&echoService{})
+	p.Initialize()
+	handler := &server.DefaultMessageHandler{}
+	handler.Initialize()
+	handler.AddProvider(p)
+	ms := &server.MotanServer{URL: &motancore.URL{Port: 64549}}
+	if err := ms.Open(false, false, handler, ext); err != nil {
+		t.Fatalf("open server fail. err:%v", err)
+	}
+	defer ms.Destroy()
+	time.Sleep(20 * time.Millisecond)
+
+	var args []interface{}
+	json.Unmarshal([]byte(`["hello", 3]`), &args)
+	args[1] = int64(args[1].(float64))
+	reply, err := Invoke("127.0.0.1:64549", "echoService", "Echo", "", args, time.Second)
+	if err != nil {
+		t.Fatalf("invoke fail. err:%v", err)
+	}
+	m, ok := reply.(map[interface{}]interface{})
+	if !ok || m["s"] != "hello" || m["n"] != int64(3) {
+		t.Errorf("wrong reply. reply:%#v", reply)
+	}
+	if _, err = Invoke("127.0.0.1:64549", "echoService", "Unknown", "", nil, time.Second); err == nil {
+		t.Errorf("unknown method should fail")
+	}
+}
@@ -0,0 +1,170 @@
+// motanctl operates a motan agent by the admin api of its manage port, and calls the services through the agent.
+//
+//	motanctl [-m 127.0.0.1:8002] [-token mytoken] clusters|registries|filters|config|switchers|commands|health|loglevel
+//	motanctl endpoints {cluster key}
+//	motanctl stats [cluster key]
+//	motanctl switch {name} {true|false|int value}
+//	motanctl command {command json file}
+//	motanctl reload
+//	motanctl discovery [flush [key]]
+//	motanctl [-scope scope] [-for 10m] loglevel {INFO|WARNING|ERROR|FATAL|reset}
+//	motanctl [-a 127.0.0.1:9981] [-g group] [-timeout 3s] call {service} {method} [json arguments array]
+//	motanctl [-g group] [-e endpoint] [-timeout 3s] debug {service} {method} [json arguments array]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/weibocom/motan-go/ctl"
+)
+
+func main() {
+	manage := flag.String("m", "127.0.0.1:8002", "address of the manage port of the agent")
+	token := flag.String("token", "", "admin token of the agent")
+	agent := flag.String("a", "127.0.0.1:9981", "address of the agent port for call")
+	group := flag.String("g", "", "group of the service for call")
+	timeout := flag.Duration("timeout", 3*time.Second, "timeout of call")
+	pinned := flag.String("e", "", "address of the endpoint for debug, called without load balance")
+	scope := flag.String("scope", "", "scope of loglevel, such as endpoint, registry, cluster or service:{path}")
+	window := flag.Duration("for", 0, "duration of loglevel, kept until changed if 0")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+	}
+	client := ctl.NewClient(*manage, *token)
+	var body json.RawMessage
+	var err error
+	switch cmd := args[0]; cmd {
+	case "clusters":
+		body, err = client.Clusters()
+	case "registries":
+		body, err = client.Registries()
+	case "filters":
+		body, err = client.Filters()
+	case "config":
+		body, err = client.Config()
+	case "switchers":
+		body, err = client.Switchers()
+	case "commands":
+		body, err = client.Commands()
+	case "reload":
+		body, err = client.Reload()
+	case "health":
+		body, err = client.Health()
+	case "stats":
+		cluster := ""
+		if len(args) > 1 {
+			cluster = args[1]
+		}
+		body, err = client.Stats(cluster)
+	case "discovery":
+		if len(args) > 1 && args[1] == "flush" {
+			key := ""
+			if len(args) > 2 {
+				key = args[2]
+			}
+			body, err = client.FlushDiscovery(key)
+		} else {
+			body, err = client.Discovery()
+		}
+	case "endpoints":
+		needArgs(args, 2)
+		body, err = client.Endpoints(args[1])
+	case "switch":
+		needArgs(args, 3)
+		if value, perr := strconv.ParseBool(args[2]); perr == nil {
+			body, err = client.SetSwitcher(args[1], value)
+		} else if value, perr := strconv.ParseInt(args[2], 10, 64); perr == nil {
+			body, err = client.SetIntSwitcher(args[1], value)
+		} else {
+			fail(perr)
+		}
+	case "command":
+		needArgs(args, 2)
+		command, rerr := ioutil.ReadFile(args[1])
+		if rerr != nil {
+			fail(rerr)
+		}
+		body, err = client.ApplyCommand(command)
+	case "loglevel":
+		if len(args) > 1 && args[1] == "reset" {
+			body, err = client.ResetScopeLogLevel(*scope)
+		} else if len(args) > 1 && (*scope != "" || *window > 0) {
+			body, err = client.SetScopeLogLevel(*scope, args[1], *window)
+		} else if len(args) > 1 {
+			body, err = client.SetLogLevel(args[1])
+		} else {
+			body, err = client.LogLevel()
+		}
+	case "call", "debug":
+		needArgs(args, 3)
+		var arguments []interface{}
+		if len(args) > 3 {
+			if err = json.Unmarshal([]byte(args[3]), &arguments); err != nil {
+				fail(fmt.Errorf("arguments must be a json array: %v", err))
+			}
+		}
+		if cmd == "debug" {
+			body, err = client.DebugCall(args[1], args[2], *group, *pinned, arguments, *timeout)
+			break
+		}
+		var reply interface{}
+		if reply, err = ctl.Invoke(*agent, args[1], args[2], *group, arguments, *timeout); err == nil {
+			body, err = json.Marshal(jsonValue(reply))
+		}
+	default:
+		usage()
+	}
+	if err != nil {
+		fail(err)
+	}
+	var out bytes.Buffer
+	if json.Indent(&out, body, "", "  ") != nil {
+		out.Write(body)
+	}
+	fmt.Println(out.String())
+}
+
+// jsonValue converts the maps decoded by the simple serialization to the maps of string keys
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprint(k)] = jsonValue(v)
+		}
+		return m
+	case []interface{}:
+		for i, v := range t {
+			t[i] = jsonValue(v)
+		}
+	case []byte:
+		return string(t)
+	}
+	return v
+}
+
+func needArgs(args []string, n int) {
+	if len(args) < n {
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: motanctl [flags] clusters|endpoints|registries|filters|config|switchers|switch|commands|command|reload|health|stats|discovery|loglevel|call|debug [args]")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "motanctl:", err)
+	os.Exit(1)
+}
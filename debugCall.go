@@ -0,0 +1,149 @@
+package motan
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+const defaultDebugCallTimeout = 3 * time.Second
+
+// debugCallRequest is the json body of the admin api /v2/call
+type debugCallRequest struct {
+	Service     string            `json:"service"`
+	Method      string            `json:"method"`
+	Group       string            `json:"group"`     // optional if only one cluster of the service
+	Arguments   []interface{}     `json:"arguments"` // json values, the numbers are float64
+	Attachments map[string]string `json:"attachments"`
+	Endpoint    string            `json:"endpoint"` // optional, host:port of a refer of the cluster to call without load balance
+	Timeout     int64             `json:"timeout"`  // milliseconds
+}
+
+type debugCallResponse struct {
+	Cluster   string              `json:"cluster"`
+	Endpoint  string              `json:"endpoint,omitempty"`
+	RequestID uint64              `json:"request_id"`
+	CostMs    float64             `json:"cost_ms"`
+	Value     interface{}         `json:"value"`
+	Exception *motan.Exception    `json:"exception,omitempty"`
+	Trace     *motan.TraceContext `json:"trace"`
+	Attach    map[string]string   `json:"attachments,omitempty"`
+}
+
+// debugCall executes the call of the request body through the filters and the load balance of the cluster,
+// or through the filters of the endpoint if it is pinned, and responds the result with the trace spans
+func (a *Agent) debugCall(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeHandlerResponse(res, http.StatusMethodNotAllowed, "call must be POST", nil)
+		return
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeHandlerResponse(res, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	dc := &debugCallRequest{}
+	if err = json.Unmarshal(body, dc); err != nil || dc.Service == "" || dc.Method == "" {
+		writeHandlerResponse(res, http.StatusBadRequest, "invalid call, service and method are required", nil)
+		return
+	}
+	key, c, err := a.findDebugCluster(dc.Service, dc.Group)
+	if err != nil {
+		writeHandlerResponse(res, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+	var caller motan.Caller = c
+	if dc.Endpoint != "" {
+		caller = nil
+		for _, ep := range c.GetRefers() {
+			if ep.GetURL().GetAddressStr() == dc.Endpoint {
+				caller = ep
+				break
+			}
+		}
+		if caller == nil {
+			writeHandlerResponse(res, http.StatusNotFound, "endpoint not found in cluster "+key+": "+dc.Endpoint, nil)
+			return
+		}
+	}
+
+	request := &motan.MotanRequest{
+		RequestID:   endpoint.GenerateRequestID(),
+		ServiceName: dc.Service,
+		Method:      dc.Method,
+		Arguments:   dc.Arguments,
+		Attachment:  motan.NewStringMap(motan.DefaultAttachmentSize),
+	}
+	for k, v := range dc.Attachments {
+		request.SetAttachment(k, v)
+	}
+	if request.GetAttachment(mpro.MSource) == "" {
+		request.SetAttachment(mpro.MSource, a.agentURL.GetParam(motan.ApplicationKey, ""))
+	}
+	timeout := defaultDebugCallTimeout
+	if dc.Timeout > 0 {
+		timeout = time.Duration(dc.Timeout) * time.Millisecond
+	}
+	tc := &motan.TraceContext{Rid: request.RequestID, ReqSpans: []*motan.Span{}, ResSpans: []*motan.Span{}, Values: map[string]interface{}{}}
+	rc := request.GetRPCContext(true)
+	rc.ExtFactory = a.extFactory
+	rc.Deadline = time.Now().Add(timeout)
+	rc.Tc = tc
+
+	start := time.Now()
+	response := caller.Call(request)
+	result := &debugCallResponse{Cluster: key, Endpoint: dc.Endpoint, RequestID: request.RequestID, CostMs: durationMs(time.Since(start)), Trace: tc}
+	if response == nil {
+		result.Exception = &motan.Exception{ErrCode: 500, ErrMsg: "call return nil", ErrType: motan.ServiceException}
+	} else {
+		result.Exception = response.GetException()
+		result.Value = debugCallValue(response.GetValue())
+		if attachments := response.GetAttachments(); attachments != nil {
+			result.Attach = attachments.RawMap()
+		}
+	}
+	writeHandlerResponse(res, http.StatusOK, "ok", result)
+}
+
+// findDebugCluster finds the cluster of the service, the group can be omitted if only one cluster of the service
+func (a *Agent) findDebugCluster(service string, group string) (string, *cluster.MotanCluster, error) {
+	var key string
+	var found *cluster.MotanCluster
+	matched := 0
+	a.clustermap.Range(func(k, v interface{}) bool {
+		c := v.(*cluster.MotanCluster)
+		if c.GetURL().Path == service && (group == "" || c.GetURL().Group == group) {
+			key, found = k.(string), c
+			matched++
+		}
+		return true
+	})
+	if matched == 0 {
+		return "", nil, errors.New("cluster not found. service:" + service + ", group:" + group)
+	}
+	if matched > 1 {
+		return "", nil, errors.New("more than one cluster of the service, the group is required. service:" + service)
+	}
+	return key, found, nil
+}
+
+func debugCallValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case *motan.DeserializableValue:
+		value, err := t.Deserialize(nil)
+		if err != nil {
+			return "deserialize fail: " + err.Error()
+		}
+		return jsonValue(value)
+	case []byte:
+		return string(t)
+	}
+	return jsonValue(v)
+}
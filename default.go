@@ -1,14 +1,21 @@
 package motan
 
 import (
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sync"
 
+	"github.com/weibocom/motan-go/compress"
 	motan "github.com/weibocom/motan-go/core"
 	"github.com/weibocom/motan-go/endpoint"
 	"github.com/weibocom/motan-go/filter"
 	"github.com/weibocom/motan-go/ha"
 	"github.com/weibocom/motan-go/lb"
+	"github.com/weibocom/motan-go/log"
+	"github.com/weibocom/motan-go/metrics"
+	mpro "github.com/weibocom/motan-go/protocol"
 	"github.com/weibocom/motan-go/provider"
 	"github.com/weibocom/motan-go/registry"
 	"github.com/weibocom/motan-go/serialize"
@@ -53,6 +60,7 @@ func GetDefaultManageHandlers() map[string]http.Handler {
 		defaultManageHandlers["/debug/pprof/trace"] = debug
 		defaultManageHandlers["/debug/mesh/trace"] = debug
 		defaultManageHandlers["/debug/pprof/sw"] = debug
+		defaultManageHandlers["/debug/runtime"] = debug
 
 		switcher := &SwitcherHandler{}
 		defaultManageHandlers["/switcher/set"] = switcher
@@ -65,6 +73,20 @@ func GetDefaultManageHandlers() map[string]http.Handler {
 		defaultManageHandlers["/registry/subscribe"] = dynamicConfigurer
 		defaultManageHandlers["/registry/list"] = dynamicConfigurer
 		defaultManageHandlers["/registry/info"] = dynamicConfigurer
+
+		defaultManageHandlers["/hotrestart"] = &HotRestartHandler{}
+		defaultManageHandlers["/config/reload"] = &ConfigReloadHandler{}
+		defaultManageHandlers["/metrics"] = metrics.PrometheusHandler()
+
+		admin := &AdminAPIHandler{}
+		for _, api := range []string{"clusters", "endpoints", "endpoints/override", "registries", "filters", "config", "switchers", "commands", "reload", "loglevel", "discovery", "health", "health/stream", "stats", "call"} {
+			defaultManageHandlers[adminAPIPrefix+api] = admin
+		}
+
+		health := &HealthHandler{}
+		defaultManageHandlers["/health"] = health
+		defaultManageHandlers["/health/live"] = health
+		defaultManageHandlers["/health/ready"] = health
 	})
 	return defaultManageHandlers
 }
@@ -90,4 +112,132 @@ func AddDefaultExt(d motan.ExtensionFactory) {
 	server.RegistDefaultServers(d)
 	server.RegistDefaultMessageHandlers(d)
 	serialize.RegistDefaultSerializations(d)
+	compress.RegistDefaultCompressors(d)
+	metrics.RegistDefaultSinks(d)
+}
+
+// initDeserializeLimits sets the serialize.DeserializeLimits by the deserialize_max_* keys of the section,
+// the default limits are kept if none of the keys is set. decompress_max_bytes limits the decompressed bodies
+func initDeserializeLimits(section map[interface{}]interface{}) {
+	if section == nil {
+		return
+	}
+	if v, ok := section["decompress_max_bytes"].(int); ok {
+		mpro.SetMaxDecompressedSize(v)
+	}
+	limits := serialize.GetDeserializeLimits()
+	changed := false
+	for key, limit := range map[string]*int{
+		"deserialize_max_string_length":   &limits.MaxStringLength,
+		"deserialize_max_collection_size": &limits.MaxCollectionSize,
+		"deserialize_max_depth":           &limits.MaxDepth,
+		"deserialize_max_bytes":           &limits.MaxTotalBytes,
+	} {
+		if v, ok := section[key].(int); ok {
+			*limit = v
+			changed = true
+		}
+	}
+	if changed {
+		serialize.SetDeserializeLimits(limits)
+		vlog.Infof("deserialize limits: %+v", limits)
+	}
+}
+
+// initBaggage sets the prefix of the attachment keys propagated from the requests of the server to the calls
+// of the clients with the context by the baggage_prefix key of the section
+func initBaggage(section map[interface{}]interface{}) {
+	if section == nil {
+		return
+	}
+	if prefix, ok := section["baggage_prefix"].(string); ok {
+		motan.SetBaggagePrefix(prefix)
+	}
+}
+
+// initRequestID sets the generator of the request ids and the correlation ids by request_id_generator
+func initRequestID(section map[interface{}]interface{}) {
+	if section == nil {
+		return
+	}
+	if name, ok := section["request_id_generator"].(string); ok && name != "" {
+		if err := motan.UseRequestIDGenerator(name); err != nil {
+			vlog.Errorf("init request id generator fail: %v", err)
+		}
+	}
+}
+
+// initObjectPool disables the pools of the requests, the responses and the metadata by object_pool: false,
+// in case the handlers or the filters keep the requests after the calls finished
+func initObjectPool(section map[interface{}]interface{}) {
+	if section == nil {
+		return
+	}
+	if enabled, ok := section["object_pool"].(bool); ok {
+		motan.SetObjectPoolEnabled(enabled)
+	}
+}
+
+// initAccessLog writes the access logs to the file of the access_log config in background, e.g.
+//
+//	access_log:
+//	  file: access.log # relative to the log_dir
+//	  max_size: 512    # MB
+//	  rotate: hour     # hour or day
+//	  max_backups: 24
+//	  compress: true
+//	  queue_size: 10000
+//
+// the access logs are written as the info logs if not configured
+func initAccessLog(section map[interface{}]interface{}, logdir string) {
+	if section == nil {
+		return
+	}
+	c, ok := section["access_log"].(map[interface{}]interface{})
+	if !ok {
+		return
+	}
+	conf := vlog.AsyncWriterConfig{File: "access.log"}
+	if file, ok := c["file"].(string); ok && file != "" {
+		conf.File = file
+	}
+	if !filepath.IsAbs(conf.File) {
+		conf.File = filepath.Join(logdir, conf.File)
+	}
+	if v, ok := c["max_size"].(int); ok {
+		conf.MaxSize = int64(v) * 1024 * 1024
+	}
+	if v, ok := c["rotate"].(string); ok {
+		conf.Rotate = v
+	}
+	if v, ok := c["max_backups"].(int); ok {
+		conf.MaxBackups = v
+	}
+	if v, ok := c["compress"].(bool); ok {
+		conf.Compress = v
+	}
+	if v, ok := c["queue_size"].(int); ok {
+		conf.QueueSize = v
+	}
+	w, err := vlog.NewAsyncWriter("access", conf)
+	if err != nil {
+		vlog.Errorf("init access log fail: %v", err)
+		return
+	}
+	vlog.SetAccessLogWriter(w)
+	vlog.Infof("access log: %+v", conf)
+}
+
+// dryRunConfig validates the config if the process is started with -dryrun, and exits with the report.
+// the exit code is 1 if the config has errors
+func dryRunConfig(context *motan.Context, extFactory motan.ExtensionFactory) {
+	if !*motan.DryRun {
+		return
+	}
+	report := motan.ValidateConfig(context, extFactory)
+	fmt.Print(report.String())
+	if report.HasError() {
+		os.Exit(1)
+	}
+	os.Exit(0)
 }
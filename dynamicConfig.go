@@ -13,6 +13,7 @@ import (
 
 	"github.com/weibocom/motan-go/core"
 	"github.com/weibocom/motan-go/log"
+	"github.com/weibocom/motan-go/provider"
 )
 
 const dynamicConfigRegistrySnapshot = "registry.snap"
@@ -184,6 +185,10 @@ func (h *DynamicConfigurerHandler) SetAgent(agent *Agent) {
 
 func (h *DynamicConfigurerHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	res.Header().Set("Content-Type", "application/json;charset=utf-8")
+	if h.agent.configurer == nil {
+		writeHandlerResponse(res, http.StatusServiceUnavailable, "agent is starting", nil)
+		return
+	}
 	switch req.RequestURI {
 	case "/registry/register":
 		h.register(res, req)
@@ -219,7 +224,7 @@ func (h *DynamicConfigurerHandler) getURL(req *http.Request) (*core.URL, error)
 	// such as 'direct://localhost:9981'
 	proxyRegistry := url.GetParam(core.ProxyRegistryKey, "")
 	if proxyRegistry != "" {
-		for id, url := range h.agent.Context.RegistryURLs {
+		for id, url := range h.agent.GetContext().RegistryURLs {
 			if fmt.Sprintf("%s://%s:%d", url.Protocol, url.Host, url.Port) == proxyRegistry {
 				registryID = id
 				break
@@ -246,7 +251,7 @@ func (h *DynamicConfigurerHandler) getURL(req *http.Request) (*core.URL, error)
 		filters = strings.Join(agentFilter, ",")
 	}
 	if filters == "" {
-		filters = h.agent.Context.AgentURL.GetParam(core.FilterKey, "")
+		filters = h.agent.GetContext().AgentURL.GetParam(core.FilterKey, "")
 	}
 	if filters != "" {
 		url.PutParam(core.FilterKey, filters)
@@ -261,6 +266,10 @@ func (h *DynamicConfigurerHandler) register(res http.ResponseWriter, req *http.R
 		return
 	}
 	url.PutParam(core.ProxyKey, url.Protocol+":"+url.GetPortStr())
+	if url.IsUnixSocket() {
+		// the local process listens on the unix socket
+		url.PutParam(provider.ProxyHostKey, url.Host)
+	}
 	url.PutParam(core.ExportKey, url.Protocol+":"+strconv.Itoa(h.agent.eport))
 	h.agent.initProxyURL(url)
 	err = h.agent.configurer.Register(url)
@@ -0,0 +1,82 @@
+package endpoint
+
+import (
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+// callBatch sends all sub requests of the batch request in one message.
+// the value of the returned response is the []motan.Response of sub requests in the same order.
+func (m *MotanEndpoint) callBatch(channel *Channel, request motan.Request, deadline time.Duration) motan.Response {
+	rc := request.GetRPCContext(true)
+	msgs := make([]*mpro.Message, 0, len(rc.BatchRequests))
+	for i, req := range rc.BatchRequests {
+		subRc := req.GetRPCContext(true)
+		subRc.Proxy = m.proxy
+		subRc.GzipSize = rc.GzipSize
+		subRc.Compress = rc.Compress
+		msg, err := mpro.ConvertToReqMessage(req, m.serialization)
+		if err != nil {
+			vlog.Errorf("convert motan batch request fail! ep: %s, req: %s, err:%s\n", m.url.GetAddressStr(), motan.GetReqInfo(req), err.Error())
+			return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewSerializationException("convert motan batch request fail!"))
+		}
+		// sub request ids only need to be unique in the batch
+		msg.Header.RequestID = uint64(i)
+		msg.Header.SetOneWay(false)
+		msg.SetTimeout(deadline)
+		if rc.Compress != "" {
+			msg.Metadata.Store(mpro.MAcceptCompress, rc.Compress)
+			mpro.CompressMessage(msg, m.extFactory, rc.Compress, channel.getPeerCompress(), rc.GzipSize)
+		}
+		msgs = append(msgs, msg)
+	}
+	batch := mpro.BuildBatchMessage(mpro.Req, request.GetRequestID(), msgs)
+	recvMsg, err := channel.Call(batch, deadline, nil)
+	if err != nil {
+		vlog.Errorf("motanEndpoint batch call fail. ep:%s, req:%s, size:%d, error: %s\n", m.url.GetAddressStr(), motan.GetReqInfo(request), len(msgs), err.Error())
+		m.recordErrAndKeepalive()
+		return m.errCodeMotanResponse(request, callErrCode(err), "channel call error:"+err.Error())
+	}
+	if !recvMsg.IsBatch() {
+		// the whole batch failed, e.g. the server could not decode it
+		recvMsg.Header.RequestID = request.GetRequestID()
+		response, err := mpro.ConvertToResponse(recvMsg, m.serialization)
+		if err != nil {
+			return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewSerializationException("convert response fail!"+err.Error()))
+		}
+		return response
+	}
+	resMsgs, err := mpro.DecodeBatch(recvMsg)
+	if err != nil || len(resMsgs) != len(msgs) {
+		vlog.Errorf("decode batch response fail. ep:%s, req:%s, err:%v\n", m.url.GetAddressStr(), motan.GetReqInfo(request), err)
+		return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewSerializationException("decode batch response fail!"))
+	}
+	m.resetErr()
+	responses := make([]motan.Response, len(rc.BatchRequests))
+	for _, resMsg := range resMsgs {
+		i := int(resMsg.Header.RequestID)
+		if i < 0 || i >= len(responses) || responses[i] != nil {
+			return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "batch response id not correct", ErrType: motan.ServiceException})
+		}
+		req := rc.BatchRequests[i]
+		resMsg.Header.SetProxy(m.proxy)
+		resMsg.Header.RequestID = req.GetRequestID()
+		err := mpro.DecompressMessage(resMsg, m.extFactory)
+		var response motan.Response
+		if err == nil {
+			response, err = mpro.ConvertToResponse(resMsg, m.serialization)
+		}
+		if err != nil {
+			response = motan.BuildExceptionResponse(req.GetRequestID(), motan.NewSerializationException("convert response fail!"+err.Error()))
+		} else if !m.proxy && response.GetException() == nil {
+			if err = response.ProcessDeserializable(req.GetRPCContext(true).Reply); err != nil {
+				response = motan.BuildExceptionResponse(req.GetRequestID(), motan.NewSerializationException(err.Error()))
+			}
+		}
+		responses[i] = response
+	}
+	return &motan.MotanResponse{RequestID: request.GetRequestID(), Value: responses, Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
+}
@@ -0,0 +1,136 @@
+package endpoint
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/transport"
+)
+
+// url params of connection limits
+const (
+	// max connections to one provider host from all endpoints, no limit if not set
+	MaxConnsPerHostKey = "maxConnsPerHost"
+	// what to do when the connection limit is reached: fail(default) fails the dial at once,
+	// wait waits for a released connection until the connect timeout
+	ConnLimitPolicyKey = "connLimitPolicy"
+	ConnLimitWait      = "wait"
+	ConnLimitFail      = "fail"
+)
+
+var (
+	ErrConnectionLimit = errors.New("outbound connection limit reached")
+
+	connLimits = newConnLimiter()
+)
+
+// connLimiter counts the outbound connections of endpoints. a connection is counted until it is closed
+type connLimiter struct {
+	lock  sync.Mutex
+	max   int
+	total int
+	hosts map[string]int
+	// closed and replaced when a connection is released
+	released chan struct{}
+}
+
+func newConnLimiter() *connLimiter {
+	return &connLimiter{hosts: make(map[string]int, 64), released: make(chan struct{})}
+}
+
+// SetMaxConnections sets the max outbound connections of all endpoints, no limit if max <= 0
+func SetMaxConnections(max int) {
+	connLimits.lock.Lock()
+	connLimits.max = max
+	connLimits.lock.Unlock()
+}
+
+// GetConnectionCount returns the number of outbound connections of all endpoints and of the host
+func GetConnectionCount(host string) (total int, hostCount int) {
+	connLimits.lock.Lock()
+	defer connLimits.lock.Unlock()
+	return connLimits.total, connLimits.hosts[host]
+}
+
+// acquire takes a connection slot of the host, waits at most wait for a released slot
+func (l *connLimiter) acquire(host string, hostMax int, wait time.Duration) error {
+	var timer *time.Timer
+	for {
+		l.lock.Lock()
+		if (l.max <= 0 || l.total < l.max) && (hostMax <= 0 || l.hosts[host] < hostMax) {
+			l.total++
+			l.hosts[host]++
+			l.lock.Unlock()
+			return nil
+		}
+		released := l.released
+		l.lock.Unlock()
+		if wait <= 0 {
+			return ErrConnectionLimit
+		}
+		if timer == nil {
+			timer = time.NewTimer(wait)
+			defer timer.Stop()
+		}
+		select {
+		case <-released:
+		case <-timer.C:
+			return ErrConnectionLimit
+		}
+	}
+}
+
+func (l *connLimiter) release(host string) {
+	l.lock.Lock()
+	l.total--
+	if l.hosts[host]--; l.hosts[host] <= 0 {
+		delete(l.hosts, host)
+	}
+	close(l.released)
+	l.released = make(chan struct{})
+	l.lock.Unlock()
+}
+
+// limitedConn releases its slot once it is closed
+type limitedConn struct {
+	net.Conn
+	host      string
+	closeOnce sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		connLimits.release(c.host)
+	})
+	return err
+}
+
+// dialLimited dials the url if the connection limits are not reached
+func dialLimited(url *motan.URL, timeout time.Duration, extFactory motan.ExtensionFactory) (net.Conn, error) {
+	host := url.Host
+	var wait time.Duration
+	if url.GetParam(ConnLimitPolicyKey, ConnLimitFail) == ConnLimitWait {
+		wait = timeout
+	}
+	start := time.Now()
+	if err := connLimits.acquire(host, int(url.GetPositiveIntValue(MaxConnsPerHostKey, 0)), wait); err != nil {
+		return nil, err
+	}
+	// the waiting time is part of the connect timeout
+	if wait > 0 {
+		if timeout -= time.Since(start); timeout <= 0 {
+			connLimits.release(host)
+			return nil, ErrConnectionLimit
+		}
+	}
+	conn, err := transport.DialExt(url, timeout, extFactory)
+	if err != nil {
+		connLimits.release(host)
+		return nil, err
+	}
+	return &limitedConn{Conn: conn, host: host}, nil
+}
@@ -0,0 +1,72 @@
+package endpoint
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func TestConnectionLimit(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			if _, err := lis.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	p, _ := strconv.Atoi(port)
+	url := &motan.URL{Host: "127.0.0.1", Port: p, Parameters: map[string]string{MaxConnsPerHostKey: "1"}}
+
+	conn, err := dialLimited(url, time.Second, nil)
+	if err != nil {
+		t.Fatalf("dial fail. err:%v", err)
+	}
+	if _, err = dialLimited(url, time.Second, nil); err != ErrConnectionLimit {
+		t.Fatalf("dial should fail fast if the host limit reached. err:%v", err)
+	}
+	if total, host := GetConnectionCount("127.0.0.1"); total != 1 || host != 1 {
+		t.Errorf("connection count not correct. total:%d, host:%d", total, host)
+	}
+
+	// wait for the released connection
+	url.PutParam(ConnLimitPolicyKey, ConnLimitWait)
+	go func(conn net.Conn) {
+		time.Sleep(50 * time.Millisecond)
+		conn.Close()
+		conn.Close()
+	}(conn)
+	conn, err = dialLimited(url, time.Second, nil)
+	if err != nil {
+		t.Fatalf("dial should succeed after the connection released. err:%v", err)
+	}
+	if _, err = dialLimited(url, 50*time.Millisecond, nil); err != ErrConnectionLimit {
+		t.Errorf("dial should fail after waiting timeout. err:%v", err)
+	}
+	conn.Close()
+
+	// total limit
+	SetMaxConnections(1)
+	defer SetMaxConnections(0)
+	delete(url.Parameters, MaxConnsPerHostKey)
+	delete(url.Parameters, ConnLimitPolicyKey)
+	conn, err = dialLimited(url, time.Second, nil)
+	if err != nil {
+		t.Fatalf("dial fail. err:%v", err)
+	}
+	defer conn.Close()
+	if _, err = dialLimited(url, time.Second, nil); err != ErrConnectionLimit {
+		t.Errorf("dial should fail if the total limit reached. err:%v", err)
+	}
+	if total, _ := GetConnectionCount("127.0.0.1"); total != 1 {
+		t.Errorf("connection count not correct. total:%d", total)
+	}
+}
@@ -0,0 +1,43 @@
+package endpoint
+
+import (
+	"sync"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+var (
+	controlListenerLock sync.Mutex
+	controlListenerID   int
+	controlListeners    = make(map[int]motan.ControlListener)
+)
+
+// AddControlListener adds the listener of the control messages pushed by the servers connected by any endpoint,
+// the returned func removes the listener
+func AddControlListener(listener motan.ControlListener) (remove func()) {
+	controlListenerLock.Lock()
+	defer controlListenerLock.Unlock()
+	controlListenerID++
+	id := controlListenerID
+	controlListeners[id] = listener
+	return func() {
+		controlListenerLock.Lock()
+		delete(controlListeners, id)
+		controlListenerLock.Unlock()
+	}
+}
+
+func dispatchControl(msg *motan.ControlMessage) {
+	controlListenerLock.Lock()
+	listeners := make([]motan.ControlListener, 0, len(controlListeners))
+	for _, l := range controlListeners {
+		listeners = append(listeners, l)
+	}
+	controlListenerLock.Unlock()
+	for _, l := range listeners {
+		go func(l motan.ControlListener) {
+			defer motan.HandlePanic(nil)
+			l(msg)
+		}(l)
+	}
+}
@@ -0,0 +1,73 @@
+package endpoint
+
+import (
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// Drain stops selecting the endpoint for new requests, the returned channel is closed after the requests being
+// processed finished or the grace period passed. the endpoint keeps draining until it is destroyed or recovered
+// from the drain notice of the provider
+func (m *MotanEndpoint) Drain() <-chan struct{} {
+	drained, _ := m.startDrain()
+	return drained
+}
+
+func (m *MotanEndpoint) startDrain() (<-chan struct{}, bool) {
+	m.drainLock.Lock()
+	defer m.drainLock.Unlock()
+	if m.drained != nil {
+		return m.drained, false
+	}
+	drained := make(chan struct{})
+	m.drained = drained
+	atomic.StoreInt32(&m.draining, 1)
+	vlog.Infof("motan2 endpoint %s start draining", m.url.GetAddressStr())
+	go func() {
+		defer close(drained)
+		grace := m.url.GetTimeDuration(DrainGracePeriodKey, time.Millisecond, defaultDrainGracePeriod)
+		deadline := time.Now().Add(grace)
+		for channels := m.channels; channels != nil && channels.inflight() > 0 && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	return drained, true
+}
+
+// onDrainNotice drains the endpoint when the provider is going to stop. the connections are closed after drained
+// and reconnected in background, the endpoint is available again after the restarted provider answers heartbeats
+func (m *MotanEndpoint) onDrainNotice() {
+	drained, started := m.startDrain()
+	if !started {
+		return
+	}
+	go func() {
+		defer motan.HandlePanic(nil)
+		<-drained
+		channels := m.channels
+		if channels == nil {
+			return
+		}
+		channels.closeChannels()
+		for attempt := 0; ; attempt++ {
+			timer := time.NewTimer(backoff(attempt, m.config.ReconnectBaseInterval, m.config.ReconnectMaxInterval))
+			select {
+			case <-timer.C:
+			case <-channels.closeCh:
+				timer.Stop()
+				return
+			}
+			if _, err := m.Ping(m.config.RequestTimeout); err == nil {
+				break
+			}
+		}
+		m.drainLock.Lock()
+		m.drained = nil
+		atomic.StoreInt32(&m.draining, 0)
+		m.drainLock.Unlock()
+		vlog.Infof("motan2 endpoint %s recovered from draining", m.url.GetAddressStr())
+	}()
+}
@@ -1,9 +1,6 @@
 package endpoint
 
 import (
-	"sync/atomic"
-	"time"
-
 	motan "github.com/weibocom/motan-go/core"
 	mpro "github.com/weibocom/motan-go/protocol"
 )
@@ -12,25 +9,26 @@ import (
 const (
 	Grpc   = "grpc"
 	Motan2 = "motan2"
+	HTTP   = "http"
 	Mock   = "mockEndpoint"
 )
 
-const (
-	pMask = 0xfffffffffff00000
-	sMask = 0x000fffff
-)
-
-var idOffset uint64 // id generator offset
-
 func RegistDefaultEndpoint(extFactory motan.ExtensionFactory) {
 	extFactory.RegistExtEndpoint(Motan2, func(url *motan.URL) motan.EndPoint {
-		return &MotanEndpoint{url: url}
+		if canLoopback(url) {
+			return &LoopbackEndpoint{url: url, extFactory: extFactory}
+		}
+		return &MotanEndpoint{url: url, extFactory: extFactory}
 	})
 
 	extFactory.RegistExtEndpoint(Grpc, func(url *motan.URL) motan.EndPoint {
 		return &GrpcEndPoint{url: url}
 	})
 
+	extFactory.RegistExtEndpoint(HTTP, func(url *motan.URL) motan.EndPoint {
+		return &HTTPEndpoint{url: url, extFactory: extFactory}
+	})
+
 	extFactory.RegistExtEndpoint(Mock, func(url *motan.URL) motan.EndPoint {
 		return &MockEndpoint{URL: url}
 	})
@@ -44,10 +42,9 @@ func GetRequestGroup(r motan.Request) string {
 	return group
 }
 
+// GenerateRequestID returns a new request id by the request id generator of core
 func GenerateRequestID() uint64 {
-	ms := uint64(time.Now().UnixNano())
-	offset := atomic.AddUint64(&idOffset, 1)
-	return (ms & pMask) | (offset & sMask)
+	return motan.NewRequestID()
 }
 
 type MockEndpoint struct {
@@ -0,0 +1,297 @@
+package endpoint
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	URL "net/url"
+	"strings"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	"github.com/weibocom/motan-go/transport"
+	"golang.org/x/net/http2"
+)
+
+// url params of http endpoint
+const (
+	// use http/2 if true. prior knowledge h2c is used without tls
+	HTTP2Key = "http2"
+	// max idle keep-alive connections and max connections to the provider. connections are not limited if HTTPMaxConnsKey not set
+	HTTPMaxIdleConnsKey = "maxIdleConns"
+	HTTPMaxConnsKey     = "maxConns"
+	// idle keep-alive connections are closed after the timeout(milliseconds)
+	HTTPIdleConnTimeoutKey = "idleConnTimeout"
+	// the http verb and the path template of methods, can be set for each method as method(desc).key.
+	// {service} and {method} in the path template are replaced by the service name and the method name of request
+	HTTPVerbKey = "httpVerb"
+	HTTPPathKey = "httpPath"
+	// set true to make the HA strategy retry a method with non-idempotent verb, can be set for each method
+	HTTPIdempotentKey = "idempotent"
+	// comma separated attachment:Header rules. the attachments of request are sent as the mapped headers,
+	// and the mapped headers of response are received as attachments
+	HTTPHeaderMappingKey = "headerMapping"
+)
+
+var (
+	defaultHTTPVerb            = http.MethodGet
+	defaultHTTPPath            = "/{method}"
+	defaultHTTPMaxIdleConns    = 16
+	defaultHTTPIdleConnTimeout = 90 * time.Second
+	errHTTPArguments           = errors.New("http endpoint only supports one argument of string, []byte or map[string]string")
+)
+
+// HTTPEndpoint calls http services. the request is sent by the verb and the path of the method,
+// the argument is used as the body, or as the query string of GET request if it is a map[string]string.
+type HTTPEndpoint struct {
+	url        *motan.URL
+	extFactory motan.ExtensionFactory
+	client     *http.Client
+	proxy      bool
+	baseURL    string
+	// attachment name to header name, and the reverse
+	headers     map[string]string
+	attachments map[string]string
+}
+
+func (h *HTTPEndpoint) Initialize() {
+	connectTimeout := h.url.GetTimeDuration("connectTimeout", time.Millisecond, defaultConnectTimeout)
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// address, tls and unix socket options are all in the url
+		return dialLimited(h.url, connectTimeout, h.extFactory)
+	}
+	scheme := "http"
+	if transport.IsTLSEnabled(h.url) {
+		scheme = "https"
+	}
+	var rt http.RoundTripper
+	if h.url.GetParam(HTTP2Key, "") == "true" {
+		rt = &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				if scheme == "http" {
+					return dial(context.Background(), network, addr)
+				}
+				conf, err := transport.NewClientTLSConfig(h.url)
+				if err != nil {
+					return nil, err
+				}
+				conf.NextProtos = []string{http2.NextProtoTLS}
+				return tls.DialWithDialer(&net.Dialer{Timeout: connectTimeout}, network, addr, conf)
+			},
+		}
+	} else {
+		maxIdleConns := int(h.url.GetPositiveIntValue(HTTPMaxIdleConnsKey, int64(defaultHTTPMaxIdleConns)))
+		t := &http.Transport{
+			MaxIdleConns:        maxIdleConns,
+			MaxIdleConnsPerHost: maxIdleConns,
+			MaxConnsPerHost:     int(h.url.GetPositiveIntValue(HTTPMaxConnsKey, 0)),
+			IdleConnTimeout:     h.url.GetTimeDuration(HTTPIdleConnTimeoutKey, time.Millisecond, defaultHTTPIdleConnTimeout),
+		}
+		if scheme == "https" {
+			t.DialTLSContext = dial
+		} else {
+			t.DialContext = dial
+		}
+		rt = t
+	}
+	h.client = &http.Client{Transport: rt}
+	h.baseURL = scheme + "://" + h.url.GetAddressStr()
+	h.headers = make(map[string]string)
+	h.attachments = make(map[string]string)
+	for _, rule := range motan.TrimSplit(h.url.GetParam(HTTPHeaderMappingKey, ""), ",") {
+		if rule == "" {
+			continue
+		}
+		kv := motan.TrimSplit(rule, ":")
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			vlog.Warningf("http endpoint ignore wrong header mapping rule: %s, url:%s\n", rule, h.url.GetIdentity())
+			continue
+		}
+		h.headers[kv[0]] = kv[1]
+		h.attachments[http.CanonicalHeaderKey(kv[1])] = kv[0]
+	}
+}
+
+func (h *HTTPEndpoint) Destroy() {
+	if h.client != nil {
+		vlog.Infof("http endpoint %s will destroyed", h.url.GetAddressStr())
+		h.client.CloseIdleConnections()
+	}
+}
+
+func (h *HTTPEndpoint) SetProxy(proxy bool) {
+	h.proxy = proxy
+}
+
+func (h *HTTPEndpoint) SetSerialization(s motan.Serialization) {}
+
+func (h *HTTPEndpoint) GetName() string {
+	return "httpEndpoint"
+}
+
+func (h *HTTPEndpoint) GetURL() *motan.URL {
+	return h.url
+}
+
+func (h *HTTPEndpoint) SetURL(url *motan.URL) {
+	h.url = url
+}
+
+func (h *HTTPEndpoint) IsAvailable() bool {
+	return true
+}
+
+func (h *HTTPEndpoint) Call(request motan.Request) motan.Response {
+	t := time.Now().UnixNano()
+	rc := request.GetRPCContext(true)
+	verb := strings.ToUpper(h.url.GetMethodParam(request.GetMethod(), request.GetMethodDesc(), HTTPVerbKey, defaultHTTPVerb))
+	idempotent := isIdempotent(verb) || h.url.GetMethodParam(request.GetMethod(), request.GetMethodDesc(), HTTPIdempotentKey, "") == "true"
+	req, err := h.buildRequest(request, verb)
+	if err != nil {
+		vlog.Errorf("http endpoint build request fail. ep:%s, req:%s, err:%s\n", h.url.GetAddressStr(), motan.GetReqInfo(request), err.Error())
+		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "build http request fail:" + err.Error(), ErrType: motan.ServiceException})
+	}
+	ctx := rc.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.url.GetTimeDuration("requestTimeout", time.Millisecond, defaultRequestTimeout))
+	defer cancel()
+	if !rc.Deadline.IsZero() {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithDeadline(ctx, rc.Deadline)
+		defer cancelDeadline()
+	}
+
+	httpRes, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		vlog.Errorf("http endpoint call fail. ep:%s, req:%s, error: %s\n", h.url.GetAddressStr(), motan.GetReqInfo(request), err.Error())
+		// the request may have been processed unless the connection was not established
+		errType := motan.ServiceException
+		if !idempotent && !isDialError(err) {
+			errType = motan.BizException
+		}
+		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: http.StatusServiceUnavailable, ErrMsg: "http call fail:" + err.Error(), ErrType: errType})
+	}
+	defer httpRes.Body.Close()
+	body, err := ioutil.ReadAll(httpRes.Body)
+	res := &motan.MotanResponse{RequestID: request.GetRequestID(), Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
+	res.ProcessTime = (time.Now().UnixNano() - t) / 1e6
+	if err != nil {
+		errType := motan.BizException
+		if idempotent {
+			errType = motan.ServiceException
+		}
+		res.Exception = &motan.Exception{ErrCode: httpRes.StatusCode, ErrMsg: "read http response fail:" + err.Error(), ErrType: errType}
+		return res
+	}
+	for k, v := range httpRes.Header {
+		if name, ok := h.attachments[k]; ok {
+			k = name
+		}
+		res.SetAttachment(k, v[0])
+	}
+	if httpRes.StatusCode >= http.StatusBadRequest {
+		// client errors and the server errors of non-idempotent requests should not be retried
+		errType := motan.BizException
+		if httpRes.StatusCode >= http.StatusInternalServerError && idempotent {
+			errType = motan.ServiceException
+		}
+		res.Exception = &motan.Exception{ErrCode: httpRes.StatusCode, ErrMsg: string(body), ErrType: errType}
+		return res
+	}
+	res.Value = string(body)
+	switch reply := rc.Reply.(type) {
+	case *string:
+		*reply = string(body)
+	case *[]byte:
+		*reply = body
+	}
+	return res
+}
+
+func (h *HTTPEndpoint) buildRequest(request motan.Request, verb string) (*http.Request, error) {
+	if len(request.GetArguments()) > 1 {
+		return nil, errHTTPArguments
+	}
+	if len(request.GetArguments()) == 1 {
+		if _, ok := request.GetArguments()[0].(*motan.DeserializableValue); ok {
+			if err := request.ProcessDeserializable(make([]interface{}, 1)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	path := h.url.GetMethodParam(request.GetMethod(), request.GetMethodDesc(), HTTPPathKey, defaultHTTPPath)
+	path = strings.Replace(path, "{service}", request.GetServiceName(), -1)
+	path = strings.Replace(path, "{method}", request.GetMethod(), -1)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	reqURL := h.baseURL + path
+	var body io.Reader
+	contentType := ""
+	if len(request.GetArguments()) == 1 {
+		switch arg := request.GetArguments()[0].(type) {
+		case nil:
+		case string:
+			body = strings.NewReader(arg)
+		case []byte:
+			body = bytes.NewReader(arg)
+		case map[string]string:
+			values := make(URL.Values, len(arg))
+			for k, v := range arg {
+				values.Set(k, v)
+			}
+			if verb == http.MethodGet || verb == http.MethodHead || verb == http.MethodDelete {
+				if strings.Contains(reqURL, "?") {
+					reqURL += "&" + values.Encode()
+				} else {
+					reqURL += "?" + values.Encode()
+				}
+			} else {
+				body = strings.NewReader(values.Encode())
+				contentType = "application/x-www-form-urlencoded"
+			}
+		default:
+			return nil, errHTTPArguments
+		}
+	}
+	req, err := http.NewRequest(verb, reqURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if request.GetAttachments() != nil {
+		request.GetAttachments().ForEach(func(k, v string) bool {
+			if name, ok := h.headers[k]; ok {
+				req.Header.Set(name, v)
+			} else {
+				req.Header.Add(strings.Replace(k, "M_", "MOTAN-", -1), v)
+			}
+			return true
+		})
+	}
+	return req, nil
+}
+
+func isIdempotent(verb string) bool {
+	switch verb {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
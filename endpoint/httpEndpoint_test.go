@@ -0,0 +1,116 @@
+package endpoint
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func newHTTPEndpoint(addr string, params map[string]string) *HTTPEndpoint {
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	ep := &HTTPEndpoint{url: &motan.URL{Protocol: HTTP, Host: host, Port: p, Parameters: params}}
+	ep.Initialize()
+	return ep
+}
+
+func TestHTTPEndpointCall(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Result", r.Method+" "+r.URL.Path)
+		w.Write([]byte(r.Header.Get("X-Trace") + ":" + r.URL.RawQuery + string(body)))
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	ep := newHTTPEndpoint(server.Listener.Addr().String(), map[string]string{
+		HTTPPathKey:             "/api/{service}/{method}",
+		"echo()." + HTTPVerbKey: "post",
+		HTTPHeaderMappingKey:    "traceId:X-Trace, result:X-Result",
+		HTTPMaxIdleConnsKey:     "2",
+	})
+	defer ep.Destroy()
+
+	for i := 0; i < 3; i++ {
+		var reply string
+		request := &motan.MotanRequest{ServiceName: "test", Method: "echo", Arguments: []interface{}{"hello"}, Attachment: motan.NewStringMap(0)}
+		request.SetAttachment("traceId", "t1")
+		request.GetRPCContext(true).Reply = &reply
+		res := ep.Call(request)
+		if res.GetException() != nil {
+			t.Fatalf("http call fail. err:%v", res.GetException())
+		}
+		if reply != "t1:hello" || res.GetAttachment("result") != "POST /api/test/echo" {
+			t.Errorf("http call result not correct. reply:%s, result:%s", reply, res.GetAttachment("result"))
+		}
+	}
+	request := &motan.MotanRequest{ServiceName: "test", Method: "query", Arguments: []interface{}{map[string]string{"a": "1"}}, Attachment: motan.NewStringMap(0)}
+	res := ep.Call(request)
+	if res.GetException() != nil || res.GetValue() != ":a=1" || res.GetAttachment("result") != "GET /api/test/query" {
+		t.Errorf("http GET call result not correct. value:%v, err:%v", res.GetValue(), res.GetException())
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("keep-alive connection should be reused. connections:%d", n)
+	}
+}
+
+func TestHTTPEndpointRetryable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/notFound" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	ep := newHTTPEndpoint(server.Listener.Addr().String(), map[string]string{
+		"create()." + HTTPVerbKey:       "POST",
+		"update()." + HTTPVerbKey:       "POST",
+		"update()." + HTTPIdempotentKey: "true",
+	})
+	defer ep.Destroy()
+
+	cases := map[string]int{"get": motan.ServiceException, "create": motan.BizException, "update": motan.ServiceException, "notFound": motan.BizException}
+	for method, errType := range cases {
+		res := ep.Call(&motan.MotanRequest{ServiceName: "test", Method: method, Attachment: motan.NewStringMap(0)})
+		if res.GetException() == nil || res.GetException().ErrType != errType {
+			t.Errorf("exception type not correct. method:%s, exception:%+v", method, res.GetException())
+		}
+	}
+
+	// the request is never sent if the connection fails, so it is always retryable
+	server.Close()
+	ep = newHTTPEndpoint(server.Listener.Addr().String(), ep.url.Parameters)
+	defer ep.Destroy()
+	res := ep.Call(&motan.MotanRequest{ServiceName: "test", Method: "create", Attachment: motan.NewStringMap(0)})
+	if res.GetException() == nil || res.GetException().ErrType != motan.ServiceException {
+		t.Errorf("dial error should be retryable. exception:%+v", res.GetException())
+	}
+}
+
+func TestHTTPEndpointH2C(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer server.Close()
+	ep := newHTTPEndpoint(server.Listener.Addr().String(), map[string]string{HTTP2Key: "true"})
+	defer ep.Destroy()
+	res := ep.Call(&motan.MotanRequest{ServiceName: "test", Method: "proto", Attachment: motan.NewStringMap(0)})
+	if res.GetException() != nil || res.GetValue() != "HTTP/2.0" {
+		t.Errorf("http2 call fail. value:%v, err:%v", res.GetValue(), res.GetException())
+	}
+}
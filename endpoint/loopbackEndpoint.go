@@ -0,0 +1,216 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+const (
+	// calls the provider in the same process directly if the url param is true and the provider is exported in this process
+	LoopbackKey = "loopback"
+)
+
+var (
+	localHandlers    = make(map[int]motan.MessageHandler, 8)
+	localHandlerLock sync.RWMutex
+)
+
+// RegistLocalHandler registers the message handler of the server exported on the port in this process
+func RegistLocalHandler(port int, handler motan.MessageHandler) {
+	localHandlerLock.Lock()
+	localHandlers[port] = handler
+	localHandlerLock.Unlock()
+}
+
+// UnregistLocalHandler removes the message handler of the port if it is still the registered one
+func UnregistLocalHandler(port int, handler motan.MessageHandler) {
+	localHandlerLock.Lock()
+	if localHandlers[port] == handler {
+		delete(localHandlers, port)
+	}
+	localHandlerLock.Unlock()
+}
+
+func getLocalHandler(port int) motan.MessageHandler {
+	localHandlerLock.RLock()
+	defer localHandlerLock.RUnlock()
+	return localHandlers[port]
+}
+
+func isLocalHost(host string) bool {
+	if host == "127.0.0.1" || host == "localhost" || host == motan.GetLocalIP() {
+		return true
+	}
+	for _, ip := range motan.GetLocalIPs() {
+		if host == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// canLoopback checks whether the url can be called by a LoopbackEndpoint
+func canLoopback(url *motan.URL) bool {
+	return url.GetParam(LoopbackKey, "") == "true" && !url.IsUnixSocket() && isLocalHost(url.Host) && getLocalHandler(url.Port) != nil
+}
+
+// LoopbackEndpoint calls the message handler of the local server without network.
+// the request and the response are still converted by the serialization, so the caller and the provider
+// never share values, and the filters of both the referer and the provider are called as usual.
+type LoopbackEndpoint struct {
+	url           *motan.URL
+	extFactory    motan.ExtensionFactory
+	serialization motan.Serialization
+	proxy         bool
+}
+
+func (l *LoopbackEndpoint) Initialize() {
+	vlog.Infof("loopback endpoint is used. url:%s\n", l.url.GetIdentity())
+}
+
+func (l *LoopbackEndpoint) GetName() string {
+	return "loopbackEndpoint"
+}
+
+func (l *LoopbackEndpoint) GetURL() *motan.URL {
+	return l.url
+}
+
+func (l *LoopbackEndpoint) SetURL(url *motan.URL) {
+	l.url = url
+}
+
+func (l *LoopbackEndpoint) IsAvailable() bool {
+	return getLocalHandler(l.url.Port) != nil
+}
+
+func (l *LoopbackEndpoint) SetSerialization(s motan.Serialization) {
+	l.serialization = s
+}
+
+func (l *LoopbackEndpoint) SetProxy(proxy bool) {
+	l.proxy = proxy
+}
+
+func (l *LoopbackEndpoint) Destroy() {}
+
+func (l *LoopbackEndpoint) Call(request motan.Request) motan.Response {
+	rc := request.GetRPCContext(true)
+	rc.Proxy = l.proxy
+	handler := getLocalHandler(l.url.Port)
+	if handler == nil {
+		return l.errResponse(request, motan.ErrCodeServerBusy, "local server is not available")
+	}
+	if rc.StreamCall || len(rc.BatchRequests) > 0 {
+		return l.errResponse(request, motan.ErrCodeInternal, "stream and batch call are not supported by loopback endpoint")
+	}
+	if err := rc.Err(); err != nil {
+		return l.errResponse(request, callErrCode(err), "call canceled: "+err.Error())
+	}
+	startTime := time.Now().UnixNano()
+	group := GetRequestGroup(request)
+	if group != l.url.Group && l.url.Group != "" {
+		request.SetAttachment(mpro.MGroup, l.url.Group)
+	}
+	msg, err := mpro.ConvertToReqMessage(request, l.serialization)
+	var req motan.Request
+	if err == nil {
+		// the attachments of the caller are not changed by the provider
+		msg.Metadata = msg.Metadata.Copy()
+		req, err = mpro.ConvertToRequest(msg, l.serialization)
+	}
+	if err != nil {
+		vlog.Errorf("loopback endpoint convert request fail! req: %s, err:%s\n", motan.GetReqInfo(request), err.Error())
+		return l.errResponse(request, motan.ErrCodeSerialization, "convert motan request fail!")
+	}
+	req.SetAttachment(motan.HostKey, "127.0.0.1")
+	prc := req.GetRPCContext(true)
+	prc.ExtFactory = l.extFactory
+	prc.Deadline = rc.Deadline
+	// the provider side context is done when the call finished like the motan server
+	parent := rc.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	var cancel context.CancelFunc
+	if rc.Deadline.IsZero() {
+		prc.Context, cancel = context.WithCancel(parent)
+	} else {
+		prc.Context, cancel = context.WithDeadline(parent, rc.Deadline)
+	}
+
+	if msg.Header.IsOneWay() {
+		go func() {
+			defer motan.HandlePanic(nil)
+			defer cancel()
+			handler.Call(req)
+		}()
+		return &motan.MotanResponse{RequestID: request.GetRequestID(), Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
+	}
+	if rc.AsyncCall {
+		rc.Result.StartTime = startTime
+		// the provider side context is done if the call is canceled or expired
+		rc.Result.SetCancelFunc(cancel)
+		go func() {
+			defer motan.HandlePanic(nil)
+			defer cancel()
+			result := rc.Result
+			response, err := l.call(handler, request, req)
+			if result.IsFinished() {
+				return
+			}
+			if err == nil {
+				err = response.ProcessDeserializable(result.Reply)
+			}
+			if err == nil && response.GetException() != nil {
+				err = errors.New(response.GetException().ErrMsg)
+			}
+			result.Finish(err)
+		}()
+		return defaultAsyncResponse
+	}
+	defer cancel()
+	response, err := l.call(handler, request, req)
+	if err != nil {
+		vlog.Errorf("loopback endpoint call fail. req: %s, err:%s\n", motan.GetReqInfo(request), err.Error())
+		return l.errResponse(request, motan.ErrCodeSerialization, "convert response fail!"+err.Error())
+	}
+	response.SetProcessTime(int64((time.Now().UnixNano() - startTime) / 1000000))
+	rc.SetResponseSize(response.GetRPCContext(true).ResponseSize())
+	if !l.proxy {
+		if err = response.ProcessDeserializable(rc.Reply); err != nil {
+			return l.errResponse(request, motan.ErrCodeSerialization, err.Error())
+		}
+	}
+	return response
+}
+
+// call calls the handler with the provider side request, and converts the response for the caller
+func (l *LoopbackEndpoint) call(handler motan.MessageHandler, request motan.Request, req motan.Request) (motan.Response, error) {
+	res := handler.Call(req)
+	if res == nil {
+		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "handler call return nil", ErrType: motan.ServiceException}), nil
+	}
+	res.GetRPCContext(true).Proxy = l.proxy
+	resMsg, err := mpro.ConvertToResMessage(res, l.serialization)
+	if err != nil {
+		return nil, err
+	}
+	resMsg.Header.SetProxy(l.proxy)
+	resMsg.Header.RequestID = request.GetRequestID()
+	return mpro.ConvertToResponse(resMsg, l.serialization)
+}
+
+func (l *LoopbackEndpoint) errResponse(request motan.Request, code int, errMsg string) motan.Response {
+	return &motan.MotanResponse{
+		RequestID:  request.GetRequestID(),
+		Attachment: motan.NewStringMap(motan.DefaultAttachmentSize),
+		Exception:  motan.NewException(code, errMsg),
+	}
+}
@@ -0,0 +1,76 @@
+package endpoint
+
+import (
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/serialize"
+)
+
+// echoHandler returns the first argument of the request
+type echoHandler struct {
+	request motan.Request
+}
+
+func (e *echoHandler) Call(request motan.Request) motan.Response {
+	e.request = request
+	var arg string
+	if err := request.ProcessDeserializable([]interface{}{&arg}); err != nil {
+		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: err.Error(), ErrType: motan.ServiceException})
+	}
+	request.SetAttachment("provider", "changed")
+	res := &motan.MotanResponse{RequestID: request.GetRequestID(), Value: arg, Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
+	res.SetAttachment("res", "ok")
+	return res
+}
+
+func (e *echoHandler) AddProvider(p motan.Provider) error            { return nil }
+func (e *echoHandler) RmProvider(p motan.Provider)                   {}
+func (e *echoHandler) GetProvider(serviceName string) motan.Provider { return nil }
+
+func TestLoopbackEndpoint(t *testing.T) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	RegistDefaultEndpoint(ext)
+	url := &motan.URL{Protocol: "motan2", Host: "127.0.0.1", Port: 64537, Parameters: map[string]string{LoopbackKey: "true"}}
+	if _, ok := ext.GetEndPoint(url).(*LoopbackEndpoint); ok {
+		t.Fatalf("loopback endpoint should not be used without local server")
+	}
+
+	handler := &echoHandler{}
+	RegistLocalHandler(url.Port, handler)
+	defer UnregistLocalHandler(url.Port, handler)
+	ep, ok := ext.GetEndPoint(url).(*LoopbackEndpoint)
+	if !ok {
+		t.Fatalf("loopback endpoint should be used for local server")
+	}
+	ep.SetSerialization(&serialize.SimpleSerialization{})
+	if !ep.IsAvailable() {
+		t.Errorf("loopback endpoint should be available")
+	}
+
+	var reply string
+	request := &motan.MotanRequest{RequestID: 1, ServiceName: "test.service", Method: "echo", Arguments: []interface{}{"hello"}, Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
+	request.GetRPCContext(true).Reply = &reply
+	res := ep.Call(request)
+	if res.GetException() != nil {
+		t.Fatalf("loopback call fail. exception:%+v", res.GetException())
+	}
+	if reply != "hello" || res.GetAttachment("res") != "ok" || res.GetRequestID() != 1 {
+		t.Errorf("loopback response not correct. reply:%s, response:%+v", reply, res)
+	}
+	if handler.request.GetServiceName() != "test.service" || handler.request.GetMethod() != "echo" {
+		t.Errorf("loopback request not correct. request:%+v", handler.request)
+	}
+	if request.GetAttachment("provider") != "" {
+		t.Errorf("attachments of the caller should not be changed by the provider")
+	}
+
+	UnregistLocalHandler(url.Port, handler)
+	if ep.IsAvailable() {
+		t.Errorf("loopback endpoint should be unavailable after the local server destroyed")
+	}
+	if res = ep.Call(request); res.GetException() == nil || res.GetException().ErrCode != 503 {
+		t.Errorf("loopback call should fail after the local server destroyed. response:%+v", res)
+	}
+}
@@ -2,8 +2,10 @@ package endpoint
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -14,13 +16,44 @@ import (
 	mpro "github.com/weibocom/motan-go/protocol"
 )
 
+// channel pool url params
+const (
+	// number of channels(connections) to one provider
+	ChannelPoolSizeKey = "channelPoolSize"
+	// max concurrent requests multiplexed on one channel
+	MaxStreamsKey = "maxStreamsPerChannel"
+	// reconnect backoff starts from the base interval and doubles up to the max interval(milliseconds)
+	ReconnectBaseIntervalKey = "reconnectBaseInterval"
+	ReconnectMaxIntervalKey  = "reconnectMaxInterval"
+	// heartbeats are sent on channels idle for the interval(milliseconds), disabled if not set
+	HeartbeatIntervalKey = "heartbeatInterval"
+	// a channel is closed and reconnected after this number of heartbeats failed in a row
+	MaxMissedHeartbeatsKey = "maxMissedHeartbeats"
+	// the longest time(milliseconds) waiting for the requests being processed when the provider is draining
+	DrainGracePeriodKey = "drainGracePeriod"
+	// the longest time(milliseconds) backing off the provider by the retry-after of its throttle hints
+	MaxThrottleBackoffKey = "maxThrottleBackoff"
+)
+
 var (
-	defaultChannelPoolSize     = 3
-	defaultRequestTimeout      = 1000 * time.Millisecond
-	defaultConnectTimeout      = 1000 * time.Millisecond
-	defaultKeepaliveInterval   = 10 * time.Second
-	defaultErrorCountThreshold = 10
+	defaultChannelPoolSize       = 3
+	defaultRequestTimeout        = 1000 * time.Millisecond
+	defaultConnectTimeout        = 1000 * time.Millisecond
+	defaultKeepaliveInterval     = 10 * time.Second
+	defaultErrorCountThreshold   = 10
+	defaultMaxStreams            = 0
+	defaultReconnectBaseInterval = 100 * time.Millisecond
+	defaultReconnectMaxInterval  = 30 * time.Second
+	defaultMaxMissedHeartbeats   = 3
+	defaultDrainGracePeriod      = 10 * time.Second
+	defaultMaxThrottleBackoff    = 30 * time.Second
+	// max concurrent dials of all channel pools, to avoid dial storms when many providers are down
+	defaultMaxOutstandingDials = 64
+	dialTokens                 = make(chan struct{}, defaultMaxOutstandingDials)
 	ErrChannelShutdown         = fmt.Errorf("The channel has been shutdown")
+	ErrChannelStreamLimit      = fmt.Errorf("All channels reach the max streams limit")
+	ErrChannelUnavailable      = fmt.Errorf("All channels are reconnecting")
+	ErrHeartbeatTimeout        = fmt.Errorf("Too many heartbeats missed")
 	ErrSendRequestTimeout      = fmt.Errorf("Timeout err: send request timeout")
 	ErrRecvRequestTimeout      = fmt.Errorf("Timeout err: receive request timeout")
 
@@ -30,20 +63,34 @@ var (
 )
 
 type MotanEndpoint struct {
-	url        *motan.URL
-	channels   *ChannelPool
-	destroyCh  chan struct{}
-	available  bool
+	url       *motan.URL
+	channels  *ChannelPool
+	destroyCh chan struct{}
+	// 1 if available, accessed atomically since it is set by the keepalive and the drain notices
+	available  int32
 	errorCount uint32
 	proxy      bool
 
 	// for heartbeat requestid
 	keepaliveID   uint64
 	serialization motan.Serialization
+	extFactory    motan.ExtensionFactory
+	config        *Config
+
+	// the endpoint is not available while draining
+	draining  int32
+	drainLock sync.Mutex
+	drained   chan struct{}
+	// the unix nanoseconds until which the endpoint is backed off by the throttle hint
+	throttledUntil int64
 }
 
 func (m *MotanEndpoint) setAvailable(available bool) {
-	m.available = available
+	if available {
+		atomic.StoreInt32(&m.available, 1)
+	} else {
+		atomic.StoreInt32(&m.available, 0)
+	}
 }
 
 func (m *MotanEndpoint) SetSerialization(s motan.Serialization) {
@@ -57,22 +104,37 @@ func (m *MotanEndpoint) SetProxy(proxy bool) {
 func (m *MotanEndpoint) Initialize() {
 	m.destroyCh = make(chan struct{}, 1)
 	connectTimeout := m.url.GetTimeDuration("connectTimeout", time.Millisecond, defaultConnectTimeout)
+	poolSize := int(m.url.GetPositiveIntValue(ChannelPoolSizeKey, int64(defaultChannelPoolSize)))
+	config := DefaultConfig()
+	config.MaxStreams = int(m.url.GetPositiveIntValue(MaxStreamsKey, int64(defaultMaxStreams)))
+
+	config.ReconnectBaseInterval = m.url.GetTimeDuration(ReconnectBaseIntervalKey, time.Millisecond, defaultReconnectBaseInterval)
+	config.ReconnectMaxInterval = m.url.GetTimeDuration(ReconnectMaxIntervalKey, time.Millisecond, defaultReconnectMaxInterval)
+	config.HeartbeatInterval = m.url.GetTimeDuration(HeartbeatIntervalKey, time.Millisecond, 0)
+	config.MaxMissedHeartbeats = int(m.url.GetPositiveIntValue(MaxMissedHeartbeatsKey, int64(defaultMaxMissedHeartbeats)))
+	// max frame body size(bytes) accepted from the provider, larger responses are sent in chunks. 0 disables chunks
+	config.MaxFrameSize = int(m.url.GetIntValue(motan.MaxFrameSizeKey, int64(mpro.DefaultMaxFrameSize)))
+	config.MaxMessageSize = int(m.url.GetPositiveIntValue(motan.MaxMessageSizeKey, int64(mpro.DefaultMaxMessageSize)))
+	config.ExtFactory = m.extFactory
+	config.Address = m.url.GetAddressStr()
+	config.onDrain = m.onDrainNotice
+	m.config = config
+	endpoints.Store(m, struct{}{})
 
 	factory := func() (net.Conn, error) {
-		return net.DialTimeout("tcp", m.url.GetAddressStr(), connectTimeout)
+		return dialLimited(m.url, connectTimeout, m.extFactory)
 	}
-	channels, err := NewChannelPool(defaultChannelPoolSize, factory, nil, m.serialization)
+	channels, err := NewChannelPool(poolSize, factory, config, m.serialization)
 	if err != nil {
 		vlog.Errorf("Channel pool init failed. err:%s\n", err.Error())
-		// retry connect
+		// retry connect with backoff, calls fail fast until the pool is ready
 		go func() {
 			defer motan.HandlePanic(nil)
-			ticker := time.NewTicker(60 * time.Second)
-			defer ticker.Stop()
-			for {
+			for attempt := 0; ; attempt++ {
+				timer := time.NewTimer(backoff(attempt, config.ReconnectBaseInterval, config.ReconnectMaxInterval))
 				select {
-				case <-ticker.C:
-					channels, err := NewChannelPool(defaultChannelPoolSize, factory, nil, m.serialization)
+				case <-timer.C:
+					channels, err := NewChannelPool(poolSize, factory, config, m.serialization)
 					if err == nil {
 						m.channels = channels
 						m.setAvailable(true)
@@ -80,6 +142,7 @@ func (m *MotanEndpoint) Initialize() {
 						return
 					}
 				case <-m.destroyCh:
+					timer.Stop()
 					return
 				}
 			}
@@ -93,6 +156,7 @@ func (m *MotanEndpoint) Initialize() {
 
 func (m *MotanEndpoint) Destroy() {
 	m.setAvailable(false)
+	endpoints.Delete(m)
 	m.destroyCh <- struct{}{}
 	if m.channels != nil {
 		vlog.Infof("motan2 endpoint %s will destroyed", m.url.GetAddressStr())
@@ -104,25 +168,41 @@ func (m *MotanEndpoint) Call(request motan.Request) motan.Response {
 	rc := request.GetRPCContext(true)
 	rc.Proxy = m.proxy
 	rc.GzipSize = int(m.url.GetIntValue(motan.GzipSizeKey, 0))
+	rc.Compress = m.url.GetParam(motan.CompressKey, "")
 
 	if m.channels == nil {
-		vlog.Errorf("motanEndpoint %s error: channels is null\n", m.url.GetAddressStr())
+		vlog.Errorw("motan endpoint channels is nil", vlog.String("ep", m.url.GetAddressStr()))
 		m.recordErrAndKeepalive()
 		return m.defaultErrMotanResponse(request, "motanEndpoint error: channels is null")
 	}
+	if err := rc.Err(); err != nil {
+		return m.errCodeMotanResponse(request, callErrCode(err), "call canceled: "+err.Error())
+	}
 	startTime := time.Now().UnixNano()
 	if rc.AsyncCall {
 		rc.Result.StartTime = startTime
 	}
+	// get request timeout, never longer than the remaining time of the caller
+	deadline := m.url.GetTimeDuration("requestTimeout", time.Millisecond, defaultRequestTimeout)
+	if !rc.Deadline.IsZero() {
+		remaining := rc.Deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return m.errCodeMotanResponse(request, motan.ErrCodeClientTimeout, motan.ErrDeadlineExceeded.Error())
+		}
+		if remaining < deadline {
+			deadline = remaining
+		}
+	}
 	// get a channel
 	channel, err := m.channels.Get()
 	if err != nil {
-		vlog.Errorf("motanEndpoint %s error: can not get a channel, msg: %s\n", m.url.GetAddressStr(), err.Error())
-		m.recordErrAndKeepalive()
+		vlog.Errorw("motan endpoint can not get a channel", vlog.String("ep", m.url.GetAddressStr()), vlog.Err(err))
+		// reaching the stream limit means the endpoint is busy, not broken
+		if err != ErrChannelStreamLimit {
+			m.recordErrAndKeepalive()
+		}
 		return m.defaultErrMotanResponse(request, "can not get a channel")
 	}
-	// get request timeout
-	deadline := m.url.GetTimeDuration("requestTimeout", time.Millisecond, defaultRequestTimeout)
 
 	// do call
 	group := GetRequestGroup(request)
@@ -130,34 +210,65 @@ func (m *MotanEndpoint) Call(request motan.Request) motan.Response {
 		request.SetAttachment(mpro.MGroup, m.url.Group)
 	}
 
+	if len(rc.BatchRequests) > 0 {
+		return m.callBatch(channel, request, deadline)
+	}
 	var msg *mpro.Message
 	msg, err = mpro.ConvertToReqMessage(request, m.serialization)
 
 	if err != nil {
-		vlog.Errorf("convert motan request fail! ep: %s, req: %s, err:%s\n", m.url.GetAddressStr(), motan.GetReqInfo(request), err.Error())
-		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "convert motan request fail!", ErrType: motan.ServiceException})
+		vlog.Errorw("convert motan request fail", vlog.String("ep", m.url.GetAddressStr()), vlog.Uint64("rid", request.GetRequestID()),
+			vlog.String("service", request.GetServiceName()), vlog.String("method", request.GetMethod()), vlog.Err(err))
+		return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewSerializationException("convert motan request fail!"))
 	}
 	if rc.Tc != nil {
 		rc.Tc.PutReqSpan(&motan.Span{Name: motan.Convert, Addr: m.GetURL().GetAddressStr(), Time: time.Now()})
 	}
+	if rc.StreamCall {
+		return m.openStream(channel, request, msg, deadline)
+	}
+	msg.SetTimeout(deadline)
+	if rc.Compress != "" {
+		// the provider learns the compressors of response from the request, and tells its own by the response
+		msg.Metadata.Store(mpro.MAcceptCompress, rc.Compress)
+		mpro.CompressMessage(msg, m.extFactory, rc.Compress, channel.getPeerCompress(), rc.GzipSize)
+	}
 	recvMsg, err := channel.Call(msg, deadline, rc)
 	if err != nil {
-		vlog.Errorf("motanEndpoint call fail. ep:%s, req:%s, msgid:%d, error: %s\n", m.url.GetAddressStr(), motan.GetReqInfo(request), msg.Header.RequestID, err.Error())
-		m.recordErrAndKeepalive()
-		return m.defaultErrMotanResponse(request, "channel call error:"+err.Error())
+		vlog.Errorw("motan endpoint call fail", vlog.String("ep", m.url.GetAddressStr()), vlog.Uint64("rid", request.GetRequestID()),
+			vlog.String("service", request.GetServiceName()), vlog.String("method", request.GetMethod()), vlog.Uint64("msgid", msg.Header.RequestID), vlog.Err(err))
+		// canceled by the caller, the endpoint is not broken
+		if rc.Err() == nil {
+			m.recordErrAndKeepalive()
+		}
+		return m.errCodeMotanResponse(request, callErrCode(err), "channel call error:"+err.Error())
+	}
+	if msg.Header.IsOneWay() {
+		m.resetErr()
+		return &motan.MotanResponse{RequestID: request.GetRequestID(), Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
 	}
 	if rc.AsyncCall {
 		return defaultAsyncResponse
 	}
 	recvMsg.Header.SetProxy(m.proxy)
 	recvMsg.Header.RequestID = request.GetRequestID()
+	if err = mpro.DecompressMessage(recvMsg, m.extFactory); err != nil {
+		vlog.Errorw("decompress response fail", vlog.String("ep", m.url.GetAddressStr()), vlog.Uint64("rid", request.GetRequestID()),
+			vlog.String("service", request.GetServiceName()), vlog.String("method", request.GetMethod()), vlog.Err(err))
+		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "decompress response fail!" + err.Error(), ErrType: motan.ServiceException})
+	}
 	response, err := mpro.ConvertToResponse(recvMsg, m.serialization)
 	if err != nil {
-		vlog.Errorf("convert to response fail.ep: %s, req: %s, err:%s\n", m.url.GetAddressStr(), motan.GetReqInfo(request), err.Error())
-		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "convert response fail!" + err.Error(), ErrType: motan.ServiceException})
+		vlog.Errorw("convert to response fail", vlog.String("ep", m.url.GetAddressStr()), vlog.Uint64("rid", request.GetRequestID()),
+			vlog.String("service", request.GetServiceName()), vlog.String("method", request.GetMethod()), vlog.Err(err))
+		return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewSerializationException("convert response fail!"+err.Error()))
 	}
-	excep := response.GetException()
-	if excep != nil && excep.ErrCode == 503 {
+	// the filters of the caller get both sizes by the request
+	rc.SetResponseSize(response.GetRPCContext(true).ResponseSize())
+	if hint, ok := motan.GetThrottleHint(response); ok && response.GetException() != nil {
+		// backed off by the hint, the provider is busy but not broken
+		m.throttle(hint)
+	} else if motan.IsServerUnavailable(response.GetException()) {
 		m.recordErrAndKeepalive()
 	} else {
 		// reset errorCount
@@ -166,7 +277,7 @@ func (m *MotanEndpoint) Call(request motan.Request) motan.Response {
 
 	if !m.proxy {
 		if err = response.ProcessDeserializable(rc.Reply); err != nil {
-			return m.defaultErrMotanResponse(request, err.Error())
+			return m.errCodeMotanResponse(request, motan.ErrCodeSerialization, err.Error())
 		}
 	}
 	return response
@@ -211,18 +322,26 @@ func (m *MotanEndpoint) keepalive() {
 }
 
 func (m *MotanEndpoint) defaultErrMotanResponse(request motan.Request, errMsg string) motan.Response {
+	return m.errCodeMotanResponse(request, motan.ErrCodeBadRequest, errMsg)
+}
+
+func (m *MotanEndpoint) errCodeMotanResponse(request motan.Request, code int, errMsg string) motan.Response {
 	response := &motan.MotanResponse{
 		RequestID:  request.GetRequestID(),
 		Attachment: motan.NewStringMap(motan.DefaultAttachmentSize),
-		Exception: &motan.Exception{
-			ErrCode: 400,
-			ErrMsg:  errMsg,
-			ErrType: motan.ServiceException,
-		},
+		Exception:  motan.NewException(code, errMsg),
 	}
 	return response
 }
 
+// callErrCode returns the error code of the channel call errors
+func callErrCode(err error) int {
+	if err == ErrSendRequestTimeout || err == ErrRecvRequestTimeout || err == motan.ErrDeadlineExceeded || err == context.DeadlineExceeded {
+		return motan.ErrCodeClientTimeout
+	}
+	return motan.ErrCodeBadRequest
+}
+
 func (m *MotanEndpoint) GetName() string {
 	return "motanEndpoint"
 }
@@ -236,17 +355,85 @@ func (m *MotanEndpoint) SetURL(url *motan.URL) {
 }
 
 func (m *MotanEndpoint) IsAvailable() bool {
-	return m.available
+	return atomic.LoadInt32(&m.available) == 1 && atomic.LoadInt32(&m.draining) == 0 && !m.Throttled()
+}
+
+// Ping sends a heartbeat on each connected channel of the pool, it returns the longest round trip time,
+// or the error if the pool is not ready or any heartbeat fails
+func (m *MotanEndpoint) Ping(timeout time.Duration) (time.Duration, error) {
+	channels := m.channels
+	if channels == nil {
+		return 0, errors.New("channel pool is not ready")
+	}
+	channels.channelsLock.RLock()
+	connected := make([]*Channel, 0, len(channels.channels))
+	for _, channel := range channels.channels {
+		if channel != nil && !channel.IsClosed() {
+			connected = append(connected, channel)
+		}
+	}
+	channels.channelsLock.RUnlock()
+	if len(connected) == 0 {
+		return 0, ErrChannelUnavailable
+	}
+	var rtt time.Duration
+	for _, channel := range connected {
+		start := time.Now()
+		if _, err := channel.Call(mpro.BuildHeartbeat(0, mpro.Req), timeout, nil); err != nil {
+			return 0, err
+		}
+		if cost := time.Since(start); cost > rtt {
+			rtt = cost
+		}
+	}
+	m.setAvailable(true)
+	return rtt, nil
+}
+
+// PoolStats returns the stats of the channel pool, the counters include the dials before the pool is ready
+func (m *MotanEndpoint) PoolStats() ChannelPoolStats {
+	if channels := m.channels; channels != nil {
+		return channels.Stats()
+	}
+	if m.config != nil {
+		return m.config.counters.stats()
+	}
+	return ChannelPoolStats{}
 }
 
 // Config : Config
 type Config struct {
 	RequestTimeout time.Duration
+	// max concurrent streams of one channel, no limit if MaxStreams <= 0
+	MaxStreams            int
+	ReconnectBaseInterval time.Duration
+	ReconnectMaxInterval  time.Duration
+	// idle heartbeat is disabled if HeartbeatInterval <= 0
+	HeartbeatInterval   time.Duration
+	MaxMissedHeartbeats int
+	// max frame body size advertised to the provider, chunked transfer is disabled if MaxFrameSize <= 0
+	MaxFrameSize int
+	// max body size of a response reassembled from the chunks, the default size if MaxMessageSize <= 0
+	MaxMessageSize int
+	// finds the compressors of compressed responses
+	ExtFactory motan.ExtensionFactory
+	// the address of the endpoint url, which is the address of the control messages received
+	Address string
+	// called when the provider is going to stop
+	onDrain func()
+	// the counters of all the pools created with the config
+	counters *poolCounters
 }
 
 func DefaultConfig() *Config {
 	return &Config{
-		RequestTimeout: defaultRequestTimeout,
+		RequestTimeout:        defaultRequestTimeout,
+		MaxStreams:            defaultMaxStreams,
+		ReconnectBaseInterval: defaultReconnectBaseInterval,
+		ReconnectMaxInterval:  defaultReconnectMaxInterval,
+		MaxMissedHeartbeats:   defaultMaxMissedHeartbeats,
+		MaxFrameSize:          mpro.DefaultMaxFrameSize,
+		counters:              &poolCounters{},
 	}
 }
 
@@ -276,6 +463,16 @@ type Channel struct {
 	// heartbeat
 	heartbeats    map[uint64]*Stream
 	heartbeatLock sync.Mutex
+	// unix nano of the last received message
+	lastRecvTime int64
+	// max frame body size advertised by the provider, 0 until the provider advertised it
+	peerMaxFrameSize int64
+	// 1 if the provider advertised that it handles the cancel frames
+	peerAcceptCancel int32
+	// comma separated compressor names accepted by the provider, the string is empty until the provider advertised it
+	peerCompress atomic.Value
+	// called once after the channel is closed
+	onClose func()
 
 	// shutdown
 	shutdown     bool
@@ -294,30 +491,47 @@ type Stream struct {
 	deadline time.Time
 
 	rc          *motan.RPCContext
-	isClose     bool
+	isClose     int32 // closed by both the caller and the recv loop
 	isHeartBeat bool
+
+	// frames of a streaming call, closed by streamDone
+	recvCh     chan *mpro.Message
+	streamDone chan struct{}
+	doneOnce   sync.Once
+	// the error returned by Recv after streamDone is closed, nil if the call finished normally
+	recvErr error
 }
 
 func (s *Stream) Send() error {
 	timer := time.NewTimer(s.deadline.Sub(time.Now()))
 	defer timer.Stop()
+	// the frames are encoded with copies of the body
+	defer s.sendMsg.ReleaseBody()
 
-	buf := s.sendMsg.Encode()
-	if s.rc != nil && s.rc.Tc != nil {
-		s.rc.Tc.PutReqSpan(&motan.Span{Name: motan.Encode, Addr: s.channel.address, Time: time.Now()})
+	if !s.isHeartBeat && s.channel.config.MaxFrameSize > 0 {
+		s.sendMsg.SetMaxFrameSize(s.channel.config.MaxFrameSize)
 	}
-	ready := sendReady{data: buf.Bytes()}
-	select {
-	case s.channel.sendCh <- ready:
-		if s.rc != nil && s.rc.Tc != nil {
-			s.rc.Tc.PutReqSpan(&motan.Span{Name: motan.Send, Addr: s.channel.address, Time: time.Now()})
+	// large messages are sent in chunks if the provider supports
+	frames := mpro.SplitChunks(s.sendMsg, int(atomic.LoadInt64(&s.channel.peerMaxFrameSize)))
+	for i, frame := range frames {
+		buf := frame.Encode()
+		if i == 0 && s.rc != nil && s.rc.Tc != nil {
+			s.rc.Tc.PutReqSpan(&motan.Span{Name: motan.Encode, Addr: s.channel.address, Time: time.Now()})
 		}
-		return nil
-	case <-timer.C:
-		return ErrSendRequestTimeout
-	case <-s.channel.shutdownCh:
-		return ErrChannelShutdown
+		select {
+		case s.channel.sendCh <- newSendReady(buf):
+		case <-timer.C:
+			return ErrSendRequestTimeout
+		case <-s.channel.shutdownCh:
+			return ErrChannelShutdown
+		case <-s.rc.Done():
+			return s.rc.Err()
+		}
+	}
+	if s.rc != nil && s.rc.Tc != nil {
+		s.rc.Tc.PutReqSpan(&motan.Span{Name: motan.Send, Addr: s.channel.address, Time: time.Now()})
 	}
+	return nil
 }
 
 // Recv sync recv
@@ -338,10 +552,24 @@ func (s *Stream) Recv() (*mpro.Message, error) {
 		return nil, ErrRecvRequestTimeout
 	case <-s.channel.shutdownCh:
 		return nil, ErrChannelShutdown
+	case <-s.rc.Done():
+		return nil, s.rc.Err()
 	}
 }
 
 func (s *Stream) notify(msg *mpro.Message, t time.Time) {
+	if s.recvCh != nil {
+		// the stream fails instead of blocking the channel if the frames are not consumed in time
+		select {
+		case s.recvCh <- msg:
+		case <-s.streamDone:
+		default:
+			vlog.Warningw("stream recv buffer is full, the stream is canceled", vlog.String("ep", s.channel.address), vlog.Uint64("rid", msg.Header.RequestID))
+			s.finish(ErrStreamBufferFull)
+			s.channel.cancel(s.sendMsg.Header.RequestID)
+		}
+		return
+	}
 	defer func() {
 		s.Close()
 	}()
@@ -353,21 +581,29 @@ func (s *Stream) notify(msg *mpro.Message, t time.Time) {
 		if s.rc.AsyncCall {
 			msg.Header.SetProxy(s.rc.Proxy)
 			result := s.rc.Result
-			response, err := mpro.ConvertToResponse(msg, s.channel.serialization)
+			err := mpro.DecompressMessage(msg, s.channel.config.ExtFactory)
+			var response motan.Response
+			if err == nil {
+				response, err = mpro.ConvertToResponse(msg, s.channel.serialization)
+			}
 			if err != nil {
-				vlog.Errorf("convert to response fail. ep: %s, requestid:%d, err:%s\n", s.channel.address, msg.Header.RequestID, err.Error())
-				result.Error = err
-				result.Done <- result
+				vlog.Errorw("convert to response fail", vlog.String("ep", s.channel.address), vlog.Uint64("rid", msg.Header.RequestID), vlog.Err(err))
+				result.Finish(err)
 				return
 			}
-			if err = response.ProcessDeserializable(result.Reply); err != nil {
-				result.Error = err
+			// the reply is not written if the call has been canceled
+			if result.IsFinished() {
+				return
+			}
+			err = response.ProcessDeserializable(result.Reply)
+			if err == nil && response.GetException() != nil {
+				err = errors.New(response.GetException().ErrMsg)
 			}
 			response.SetProcessTime(int64((time.Now().UnixNano() - result.StartTime) / 1000000))
 			if s.rc.Tc != nil {
 				s.rc.Tc.PutResSpan(&motan.Span{Name: motan.Convert, Addr: s.channel.address, Time: time.Now()})
 			}
-			result.Done <- result
+			result.Finish(err)
 			return
 		}
 	}
@@ -376,6 +612,15 @@ func (s *Stream) notify(msg *mpro.Message, t time.Time) {
 	s.recvNotifyCh <- struct{}{}
 }
 
+// finish closes the streaming call, err is returned by the following Recv calls
+func (s *Stream) finish(err error) {
+	s.doneOnce.Do(func() {
+		s.recvErr = err
+		close(s.streamDone)
+		s.Close()
+	})
+}
+
 func (s *Stream) SetDeadline(deadline time.Duration) {
 	s.deadline = time.Now().Add(deadline)
 }
@@ -398,19 +643,37 @@ func (c *Channel) NewStream(msg *mpro.Message, rc *motan.RPCContext) (*Stream, e
 	msg.Header.RequestID = GenerateRequestID()
 	if msg.Header.IsHeartbeat() {
 		c.heartbeatLock.Lock()
+		for c.heartbeats[msg.Header.RequestID] != nil {
+			msg.Header.RequestID = GenerateRequestID()
+		}
 		c.heartbeats[msg.Header.RequestID] = s
 		c.heartbeatLock.Unlock()
 		s.isHeartBeat = true
 	} else {
 		c.streamLock.Lock()
+		if c.config.MaxStreams > 0 && len(c.streams) >= c.config.MaxStreams {
+			c.streamLock.Unlock()
+			return nil, ErrChannelStreamLimit
+		}
+		// the ids of the pluggable generators may collide
+		for c.streams[msg.Header.RequestID] != nil {
+			msg.Header.RequestID = GenerateRequestID()
+		}
 		c.streams[msg.Header.RequestID] = s
 		c.streamLock.Unlock()
 	}
 	return s, nil
 }
 
+// StreamCount returns the number of in-flight streams(heartbeats excluded) of the channel
+func (c *Channel) StreamCount() int {
+	c.streamLock.Lock()
+	defer c.streamLock.Unlock()
+	return len(c.streams)
+}
+
 func (s *Stream) Close() {
-	if !s.isClose {
+	if atomic.CompareAndSwapInt32(&s.isClose, 0, 1) {
 		if s.isHeartBeat {
 			s.channel.heartbeatLock.Lock()
 			delete(s.channel.heartbeats, s.sendMsg.Header.RequestID)
@@ -420,12 +683,16 @@ func (s *Stream) Close() {
 			delete(s.channel.streams, s.sendMsg.Header.RequestID)
 			s.channel.streamLock.Unlock()
 		}
-		s.isClose = true
 	}
 }
 
 type sendReady struct {
 	data []byte
+	buf  *motan.BytesBuffer // released after data is written
+}
+
+func newSendReady(buf *motan.BytesBuffer) sendReady {
+	return sendReady{data: buf.Bytes(), buf: buf}
 }
 
 func (c *Channel) Call(msg *mpro.Message, deadline time.Duration, rc *motan.RPCContext) (*mpro.Message, error) {
@@ -435,16 +702,57 @@ func (c *Channel) Call(msg *mpro.Message, deadline time.Duration, rc *motan.RPCC
 	}
 	stream.SetDeadline(deadline)
 	if err := stream.Send(); err != nil {
+		stream.Close()
 		return nil, err
 	}
+	// the server never responds to oneway requests
+	if msg.Header.IsOneWay() {
+		stream.Close()
+		return nil, nil
+	}
 	if rc != nil && rc.AsyncCall {
+		// the stream is released and the provider stops processing if the call is canceled or expired
+		rc.Result.SetCancelFunc(func() {
+			stream.Close()
+			c.cancel(msg.Header.RequestID)
+		})
 		return nil, nil
 	}
-	return stream.Recv()
+	res, err := stream.Recv()
+	// timed out or canceled by the caller, the server can stop processing it
+	if err != nil && err != ErrChannelShutdown {
+		c.cancel(msg.Header.RequestID)
+	}
+	return res, err
+}
+
+// cancel sends the cancel frame of the request, the frame is dropped if the channel is busy.
+// nothing is sent until the provider advertised that it handles the cancel frames, the old ones may treat them as requests
+func (c *Channel) cancel(requestID uint64) {
+	if atomic.LoadInt32(&c.peerAcceptCancel) == 0 {
+		return
+	}
+	select {
+	case c.sendCh <- newSendReady(mpro.BuildCancelFrame(requestID).Encode()):
+	default:
+		vlog.Warningw("cancel frame dropped", vlog.String("ep", c.address), vlog.Uint64("rid", requestID))
+	}
+}
+
+func (c *Channel) getPeerCompress() string {
+	if accepted, ok := c.peerCompress.Load().(string); ok {
+		return accepted
+	}
+	return ""
 }
 
 func (c *Channel) IsClosed() bool {
-	return c.shutdown
+	select {
+	case <-c.shutdownCh:
+		return true
+	default:
+		return false
+	}
 }
 
 func (c *Channel) recv() {
@@ -457,14 +765,44 @@ func (c *Channel) recv() {
 }
 
 func (c *Channel) recvLoop() error {
+	assembler := mpro.NewChunkAssembler(mpro.ChunkLimits{MaxFrameSize: c.config.MaxFrameSize, MaxMessageSize: c.config.MaxMessageSize})
 	for {
 		res, t, err := mpro.DecodeWithTime(c.bufRead)
 		if err != nil {
 			return err
 		}
+		atomic.StoreInt64(&c.lastRecvTime, t.UnixNano())
+		if res, err = assembler.Add(res); err != nil {
+			return err
+		}
+		if res == nil {
+			// more chunks to come
+			continue
+		}
+		if size := res.GetMaxFrameSize(); size > 0 {
+			atomic.StoreInt64(&c.peerMaxFrameSize, int64(size))
+		}
+		if res.AcceptCancel() && atomic.LoadInt32(&c.peerAcceptCancel) == 0 {
+			atomic.StoreInt32(&c.peerAcceptCancel, 1)
+		}
+		if res.Metadata != nil {
+			if accepted := res.Metadata.LoadOrEmpty(mpro.MAcceptCompress); accepted != "" {
+				c.peerCompress.Store(accepted)
+			}
+		}
 		//TODO async
 		var handleErr error
-		if res.Header.IsHeartbeat() {
+		if kind := res.GetControl(); kind != "" {
+			address := c.config.Address
+			if address == "" {
+				address = c.address
+			}
+			vlog.Infow("receive control message", vlog.String("kind", kind), vlog.String("ep", c.address))
+			if kind == motan.ControlDrain && c.config.onDrain != nil {
+				c.config.onDrain()
+			}
+			dispatchControl(&motan.ControlMessage{Kind: kind, Payload: res.Body, Address: address, Received: t})
+		} else if res.Header.IsHeartbeat() {
 			handleErr = c.handleHeartbeat(res, t)
 		} else {
 			handleErr = c.handleMessage(res, t)
@@ -489,12 +827,14 @@ func (c *Channel) send() {
 				for sent < len(ready.data) {
 					n, err := c.conn.Write(ready.data[sent:])
 					if err != nil {
-						vlog.Errorf("Failed to write channel. ep: %s, err: %s\n", c.address, err.Error())
+						vlog.Errorw("write channel fail", vlog.String("ep", c.address), vlog.Err(err))
 						c.closeOnErr(err)
 						return
 					}
 					sent += n
 				}
+				atomic.AddInt64(&c.config.counters.bytesSent, int64(sent))
+				motan.ReleaseBytesBuffer(ready.buf)
 			}
 		case <-c.shutdownCh:
 			return
@@ -507,7 +847,7 @@ func (c *Channel) handleHeartbeat(msg *mpro.Message, t time.Time) error {
 	stream := c.heartbeats[msg.Header.RequestID]
 	c.heartbeatLock.Unlock()
 	if stream == nil {
-		vlog.Warningf("handle heartbeat message, missing stream: %d, ep:%s\n", msg.Header.RequestID, c.address)
+		vlog.Warningw("handle heartbeat message, missing stream", vlog.Uint64("rid", msg.Header.RequestID), vlog.String("ep", c.address))
 	} else {
 		stream.notify(msg, t)
 	}
@@ -519,7 +859,7 @@ func (c *Channel) handleMessage(msg *mpro.Message, t time.Time) error {
 	stream := c.streams[msg.Header.RequestID]
 	c.streamLock.Unlock()
 	if stream == nil {
-		vlog.Warningf("handle recv message, missing stream: %d, ep:%s\n", msg.Header.RequestID, c.address)
+		vlog.Warningw("handle recv message, missing stream", vlog.Uint64("rid", msg.Header.RequestID), vlog.String("ep", c.address))
 	} else {
 		stream.notify(msg, t)
 	}
@@ -535,6 +875,8 @@ func (c *Channel) closeOnErr(err error) {
 		vlog.Warningf("motan channel will close. ep:%s, err: %s\n", c.address, err.Error())
 		c.shutdownLock.Unlock()
 		c.Close()
+	} else {
+		c.shutdownLock.Unlock()
 	}
 }
 
@@ -547,71 +889,202 @@ func (c *Channel) Close() error {
 	c.shutdown = true
 	close(c.shutdownCh)
 	c.conn.Close()
+	if c.onClose != nil {
+		go c.onClose()
+	}
 	return nil
 }
 
+// heartbeat sends heartbeats when the channel has received nothing for an interval,
+// and closes the channel after MaxMissedHeartbeats heartbeats failed in a row.
+// so connections silently dropped by NAT or firewall are detected before requests are sent on them.
+func (c *Channel) heartbeat() {
+	defer motan.HandlePanic(nil)
+	interval := c.config.HeartbeatInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-ticker.C:
+			if time.Since(time.Unix(0, atomic.LoadInt64(&c.lastRecvTime))) < interval {
+				missed = 0
+				continue
+			}
+			if _, err := c.Call(mpro.BuildHeartbeat(0, mpro.Req), interval, nil); err != nil {
+				if c.IsClosed() {
+					return
+				}
+				missed++
+				vlog.Warningf("channel heartbeat failed. ep:%s, missed:%d, err:%s\n", c.address, missed, err.Error())
+				if missed >= c.config.MaxMissedHeartbeats {
+					c.closeOnErr(ErrHeartbeatTimeout)
+					return
+				}
+			} else {
+				missed = 0
+			}
+		case <-c.shutdownCh:
+			return
+		}
+	}
+}
+
 type ConnFactory func() (net.Conn, error)
 
+// ChannelPool multiplexes requests over a fixed number of channels.
+// channels are picked round-robin, a channel that has reached Config.MaxStreams concurrent streams is skipped.
+// a closed channel is reconnected in background with exponential backoff, requests never wait for dialing.
 type ChannelPool struct {
-	channels      chan *Channel
-	channelsLock  sync.Mutex
+	channels      []*Channel
+	channelsLock  sync.RWMutex
+	reconnecting  []int32
+	index         uint32
 	factory       ConnFactory
 	config        *Config
 	serialization motan.Serialization
-}
-
-func (c *ChannelPool) getChannels() chan *Channel {
-	channels := c.channels
-	return channels
+	closeCh       chan struct{}
 }
 
 func (c *ChannelPool) Get() (*Channel, error) {
-	channels := c.getChannels()
-	if channels == nil {
+	c.channelsLock.RLock()
+	size := len(c.channels)
+	c.channelsLock.RUnlock()
+	if size == 0 {
 		return nil, errors.New("channels is nil")
 	}
-	channel, ok := <-channels
-	if ok && (channel == nil || channel.IsClosed()) {
-		conn, err := c.factory()
-		if err != nil {
-			vlog.Errorf("create channel failed. err:%s\n", err.Error())
+	reachLimit := false
+	start := int(atomic.AddUint32(&c.index, 1))
+	for i := 0; i < size; i++ {
+		channel := c.getChannel((start + i) % size)
+		if channel == nil {
+			continue
+		}
+		if c.config.MaxStreams <= 0 || channel.StreamCount() < c.config.MaxStreams {
+			return channel, nil
 		}
-		channel = buildChannel(conn, c.config, c.serialization)
+		reachLimit = true
+	}
+	if reachLimit {
+		return nil, ErrChannelStreamLimit
 	}
-	if err := retChannelPool(channels, channel); err != nil && channel != nil {
-		channel.closeOnErr(err)
+	return nil, ErrChannelUnavailable
+}
+
+// getChannel returns the channel at index, or nil and triggers a reconnection if the channel is closed
+func (c *ChannelPool) getChannel(index int) *Channel {
+	c.channelsLock.RLock()
+	if c.channels == nil {
+		c.channelsLock.RUnlock()
+		return nil
 	}
-	if channel == nil {
-		return nil, errors.New("channel is nil")
+	channel := c.channels[index]
+	c.channelsLock.RUnlock()
+	if channel != nil && !channel.IsClosed() {
+		return channel
 	}
-	return channel, nil
+	c.reconnect(index)
+	return nil
 }
 
-func retChannelPool(channels chan *Channel, channel *Channel) (error error) {
-	defer func() {
-		if err := recover(); err != nil {
-			error = errors.New("ChannelPool has been closed")
+func (c *ChannelPool) reconnect(index int) {
+	if !atomic.CompareAndSwapInt32(&c.reconnecting[index], 0, 1) {
+		return
+	}
+	go func() {
+		defer motan.HandlePanic(nil)
+		defer atomic.StoreInt32(&c.reconnecting[index], 0)
+		for attempt := 0; ; attempt++ {
+			if attempt > 0 {
+				timer := time.NewTimer(backoff(attempt-1, c.config.ReconnectBaseInterval, c.config.ReconnectMaxInterval))
+				select {
+				case <-timer.C:
+				case <-c.closeCh:
+					timer.Stop()
+					return
+				}
+			}
+			conn, err := dial(c.factory, c.closeCh)
+			if err != nil {
+				atomic.AddInt64(&c.config.counters.dialFailures, 1)
+				vlog.Warningf("reconnect channel failed. attempt:%d, err:%s\n", attempt, err.Error())
+				continue
+			}
+			channel := buildChannel(conn, c.config, c.serialization)
+			if channel == nil {
+				conn.Close()
+				continue
+			}
+			c.channelsLock.Lock()
+			if c.channels == nil {
+				c.channelsLock.Unlock()
+				channel.Close()
+				return
+			}
+			c.watchClose(index, channel)
+			c.channels[index] = channel
+			c.channelsLock.Unlock()
+			atomic.AddInt64(&c.config.counters.reconnects, 1)
+			vlog.Infof("reconnect channel success. ep:%s, attempt:%d\n", channel.address, attempt)
+			return
 		}
 	}()
-	if channels == nil {
-		return errors.New("channels is nil")
+}
+
+// watchClose reconnects the channel as soon as it is closed, not until it is picked by a request
+func (c *ChannelPool) watchClose(index int, channel *Channel) {
+	channel.shutdownLock.Lock()
+	defer channel.shutdownLock.Unlock()
+	if channel.shutdown {
+		go c.reconnect(index)
+		return
+	}
+	channel.onClose = func() {
+		select {
+		case <-c.closeCh:
+		default:
+			c.reconnect(index)
+		}
+	}
+}
+
+// closeChannels closes the connected channels, which are reconnected in background
+func (c *ChannelPool) closeChannels() {
+	c.channelsLock.RLock()
+	channels := make([]*Channel, 0, len(c.channels))
+	for _, channel := range c.channels {
+		if channel != nil {
+			channels = append(channels, channel)
+		}
+	}
+	c.channelsLock.RUnlock()
+	for _, channel := range channels {
+		channel.Close()
 	}
-	channels <- channel
-	return nil
+}
+
+// inflight returns the number of the requests waiting for the responses
+func (c *ChannelPool) inflight() int {
+	c.channelsLock.RLock()
+	defer c.channelsLock.RUnlock()
+	count := 0
+	for _, channel := range c.channels {
+		if channel != nil && !channel.IsClosed() {
+			count += channel.StreamCount()
+		}
+	}
+	return count
 }
 
 func (c *ChannelPool) Close() error {
 	c.channelsLock.Lock() // to prevent channels closed many times
 	channels := c.channels
 	c.channels = nil
-	c.factory = nil
-	c.config = nil
-	c.channelsLock.Unlock()
-	if channels == nil {
-		return nil
+	if channels != nil {
+		close(c.closeCh)
 	}
-	close(channels)
-	for channel := range channels {
+	c.channelsLock.Unlock()
+	for _, channel := range channels {
 		if channel != nil {
 			channel.Close()
 		}
@@ -619,27 +1092,105 @@ func (c *ChannelPool) Close() error {
 	return nil
 }
 
+// Stats returns the connections, the queued requests and the counters of the pool
+func (c *ChannelPool) Stats() ChannelPoolStats {
+	stats := c.config.counters.stats()
+	c.channelsLock.RLock()
+	defer c.channelsLock.RUnlock()
+	for _, channel := range c.channels {
+		if channel == nil || channel.IsClosed() {
+			continue
+		}
+		stats.OpenConnections++
+		stats.InFlightStreams += channel.StreamCount()
+		stats.QueueDepth += len(channel.sendCh)
+	}
+	return stats
+}
+
+// NewChannelPool dials all channels of the pool. it fails only if no channel can be connected,
+// channels that failed to connect will be reconnected in background.
 func NewChannelPool(poolCap int, factory ConnFactory, config *Config, serialization motan.Serialization) (*ChannelPool, error) {
 	if poolCap <= 0 {
 		return nil, errors.New("invalid capacity settings")
 	}
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.counters == nil {
+		config.counters = &poolCounters{}
+	}
 	channelPool := &ChannelPool{
-		channels:      make(chan *Channel, poolCap),
+		channels:      make([]*Channel, poolCap),
+		reconnecting:  make([]int32, poolCap),
 		factory:       factory,
 		config:        config,
 		serialization: serialization,
+		closeCh:       make(chan struct{}),
 	}
+	var lastErr error
+	connected := 0
 	for i := 0; i < poolCap; i++ {
-		conn, err := factory()
+		conn, err := dial(factory, channelPool.closeCh)
 		if err != nil {
-			channelPool.Close()
-			return nil, err
+			atomic.AddInt64(&config.counters.dialFailures, 1)
+			lastErr = err
+			continue
+		}
+		if channelPool.channels[i] = buildChannel(conn, config, serialization); channelPool.channels[i] != nil {
+			channelPool.watchClose(i, channelPool.channels[i])
+			connected++
+		}
+	}
+	if connected == 0 {
+		channelPool.Close()
+		if lastErr == nil {
+			lastErr = errors.New("channel is nil")
+		}
+		return nil, lastErr
+	}
+	for i := 0; i < poolCap; i++ {
+		if channelPool.channels[i] == nil {
+			channelPool.reconnect(i)
 		}
-		channelPool.channels <- buildChannel(conn, config, serialization)
 	}
 	return channelPool, nil
 }
 
+// dial limits the outstanding dials of all channel pools by dialTokens
+func dial(factory ConnFactory, closeCh chan struct{}) (net.Conn, error) {
+	tokens := dialTokens
+	select {
+	case tokens <- struct{}{}:
+	case <-closeCh:
+		return nil, errors.New("ChannelPool has been closed")
+	}
+	defer func() { <-tokens }()
+	return factory()
+}
+
+// backoff returns the wait time before the next attempt: base * 2^attempt capped at max, with jitter in [d/2, d]
+func backoff(attempt int, base time.Duration, max time.Duration) time.Duration {
+	if base <= 0 {
+		base = defaultReconnectBaseInterval
+	}
+	if max < base {
+		max = base
+	}
+	d := max
+	if attempt < 32 && base<<uint(attempt) > 0 && base<<uint(attempt) < max {
+		d = base << uint(attempt)
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// SetMaxOutstandingDials sets the max concurrent dials of all channel pools
+func SetMaxOutstandingDials(max int) {
+	if max > 0 {
+		dialTokens = make(chan struct{}, max)
+	}
+}
+
 func buildChannel(conn net.Conn, config *Config, serialization motan.Serialization) *Channel {
 	if conn == nil {
 		return nil
@@ -650,21 +1201,29 @@ func buildChannel(conn net.Conn, config *Config, serialization motan.Serializati
 	if err := VerifyConfig(config); err != nil {
 		return nil
 	}
+	if config.counters == nil {
+		config.counters = &poolCounters{}
+	}
 	channel := &Channel{
 		conn:          conn,
 		config:        config,
-		bufRead:       bufio.NewReader(conn),
+		bufRead:       bufio.NewReader(&countingReader{Reader: conn, count: &config.counters.bytesReceived}),
 		sendCh:        make(chan sendReady, 256),
 		streams:       make(map[uint64]*Stream, 64),
 		heartbeats:    make(map[uint64]*Stream),
 		shutdownCh:    make(chan struct{}),
 		serialization: serialization,
 		address:       conn.RemoteAddr().String(),
+		lastRecvTime:  time.Now().UnixNano(),
 	}
 
 	go channel.recv()
 
 	go channel.send()
 
+	if config.HeartbeatInterval > 0 {
+		go channel.heartbeat()
+	}
+
 	return channel
 }
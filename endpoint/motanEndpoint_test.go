@@ -1,7 +1,12 @@
 package endpoint
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"strconv"
 	"testing"
@@ -9,6 +14,7 @@ import (
 
 	motan "github.com/weibocom/motan-go/core"
 	"github.com/weibocom/motan-go/log"
+	mpro "github.com/weibocom/motan-go/protocol"
 	"github.com/weibocom/motan-go/serialize"
 )
 
@@ -75,3 +81,446 @@ func handleConnection(conn net.Conn, timeout int) {
 	time.Sleep(time.Millisecond * 1000)
 	conn.Close()
 }
+
+func TestChannelPoolStreamLimit(t *testing.T) {
+	factory := func() (net.Conn, error) {
+		client, _ := net.Pipe()
+		return client, nil
+	}
+	config := DefaultConfig()
+	config.MaxStreams = 1
+	pool, err := NewChannelPool(2, factory, config, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+
+	used := make(map[*Channel]bool)
+	for i := 0; i < 2; i++ {
+		channel, err := pool.Get()
+		if err != nil {
+			t.Fatalf("get channel fail. err:%v", err)
+		}
+		if _, err = channel.NewStream(mpro.BuildHeartbeat(0, mpro.Req), nil); err != nil {
+			t.Fatalf("new heartbeat stream fail. err:%v", err)
+		}
+		if _, err = channel.NewStream(&mpro.Message{Header: &mpro.Header{}}, nil); err != nil {
+			t.Fatalf("new stream fail. err:%v", err)
+		}
+		used[channel] = true
+	}
+	if len(used) != 2 {
+		t.Errorf("streams should be spread over all channels. used:%d", len(used))
+	}
+	if _, err = pool.Get(); err != ErrChannelStreamLimit {
+		t.Errorf("get channel should reach stream limit. err:%v", err)
+	}
+
+	// closed channels are reconnected in background, calls fail fast until then
+	for channel := range used {
+		channel.Close()
+	}
+	if _, err = pool.Get(); err != ErrChannelUnavailable {
+		t.Errorf("get channel should fail fast while reconnecting. err:%v", err)
+	}
+	var channel *Channel
+	for i := 0; i < 100 && channel == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		channel, _ = pool.Get()
+	}
+	if channel == nil || channel.IsClosed() || used[channel] {
+		t.Errorf("closed channel should be reconnected")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := time.Second
+	for attempt := 0; attempt < 64; attempt++ {
+		expect := max
+		if attempt < 4 {
+			expect = base << uint(attempt)
+		}
+		d := backoff(attempt, base, max)
+		if d < expect/2 || d > expect {
+			t.Errorf("backoff out of range. attempt:%d, d:%v, expect:%v", attempt, d, expect)
+		}
+	}
+}
+
+func TestChannelHeartbeat(t *testing.T) {
+	// the server side reads everything and never responds
+	factory := func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go io.Copy(ioutil.Discard, server)
+		return client, nil
+	}
+	config := DefaultConfig()
+	config.HeartbeatInterval = 20 * time.Millisecond
+	config.MaxMissedHeartbeats = 2
+	config.ReconnectBaseInterval = time.Hour
+	pool, err := NewChannelPool(1, factory, config, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+	channel, _ := pool.Get()
+	select {
+	case <-channel.shutdownCh:
+	case <-time.After(time.Second):
+		t.Fatalf("channel should be closed by heartbeat timeout")
+	}
+	channel.shutdownLock.Lock()
+	shutdownErr := channel.shutdownErr
+	channel.shutdownLock.Unlock()
+	if shutdownErr != ErrHeartbeatTimeout {
+		t.Fatalf("channel should be closed by heartbeat timeout. err:%v", shutdownErr)
+	}
+	// reconnected immediately after closed
+	var newChannel *Channel
+	for i := 0; i < 100 && newChannel == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		newChannel, _ = pool.Get()
+	}
+	if newChannel == nil || newChannel == channel {
+		t.Errorf("channel should be reconnected after heartbeat timeout")
+	}
+}
+
+func TestOnewayCall(t *testing.T) {
+	factory := func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go io.Copy(ioutil.Discard, server)
+		return client, nil
+	}
+	pool, err := NewChannelPool(1, factory, nil, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+	channel, _ := pool.Get()
+	msg := &mpro.Message{Header: mpro.BuildHeader(mpro.Req, false, mpro.Simple, 0, mpro.Normal), Metadata: motan.NewStringMap(0)}
+	msg.Header.SetOneWay(true)
+	start := time.Now()
+	res, err := channel.Call(msg, time.Second, nil)
+	if err != nil || res != nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("oneway call should return without response. res:%v, err:%v", res, err)
+	}
+	if channel.StreamCount() != 0 {
+		t.Errorf("oneway call should not keep stream. count:%d", channel.StreamCount())
+	}
+}
+
+func TestCallAfterDeadline(t *testing.T) {
+	factory := func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go io.Copy(ioutil.Discard, server)
+		return client, nil
+	}
+	pool, err := NewChannelPool(1, factory, nil, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	ep := &MotanEndpoint{url: &motan.URL{Port: 8989, Protocol: "motan2"}, channels: pool, serialization: &serialize.SimpleSerialization{}}
+	defer pool.Close()
+	request := &motan.MotanRequest{ServiceName: "test", Method: "test", Attachment: motan.NewStringMap(0)}
+	request.GetRPCContext(true).Deadline = time.Now().Add(-time.Millisecond)
+	res := ep.Call(request)
+	if res.GetException() == nil || res.GetException().ErrMsg != motan.ErrDeadlineExceeded.Error() {
+		t.Errorf("call after deadline should fail. res:%+v", res)
+	}
+
+	// the remaining time shortens the request timeout
+	request = &motan.MotanRequest{ServiceName: "test", Method: "test", Attachment: motan.NewStringMap(0)}
+	request.GetRPCContext(true).Deadline = time.Now().Add(50 * time.Millisecond)
+	start := time.Now()
+	res = ep.Call(request)
+	if res.GetException() == nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("call should time out at the deadline. cost:%v, res:%+v", time.Since(start), res)
+	}
+}
+
+func TestCallCanceled(t *testing.T) {
+	factory := func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go io.Copy(ioutil.Discard, server)
+		return client, nil
+	}
+	pool, err := NewChannelPool(1, factory, nil, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+	ep := &MotanEndpoint{url: &motan.URL{Port: 8989, Protocol: "motan2"}, channels: pool, serialization: &serialize.SimpleSerialization{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	request := &motan.MotanRequest{ServiceName: "test", Method: "test", Attachment: motan.NewStringMap(0)}
+	request.GetRPCContext(true).Context = ctx
+	start := time.Now()
+	res := ep.Call(request)
+	if res.GetException() == nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("call should be aborted by context. cost:%v, res:%+v", time.Since(start), res)
+	}
+	if ep.errorCount != 0 {
+		t.Errorf("canceled call should not be recorded as endpoint error. count:%d", ep.errorCount)
+	}
+}
+
+func TestAsyncCallCanceled(t *testing.T) {
+	factory := func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go io.Copy(ioutil.Discard, server)
+		return client, nil
+	}
+	pool, err := NewChannelPool(1, factory, nil, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+	ep := &MotanEndpoint{url: &motan.URL{Port: 8989, Protocol: "motan2"}, channels: pool, serialization: &serialize.SimpleSerialization{}}
+	request := &motan.MotanRequest{ServiceName: "test", Method: "test", Attachment: motan.NewStringMap(0)}
+	rc := request.GetRPCContext(true)
+	rc.AsyncCall = true
+	rc.Result = &motan.AsyncResult{Done: make(chan *motan.AsyncResult, 1)}
+	if res := ep.Call(request); res.GetException() != nil {
+		t.Fatalf("async call fail. res:%+v", res)
+	}
+	channel, _ := pool.Get()
+	if channel.StreamCount() != 1 {
+		t.Errorf("async call should keep the stream until the response received. count:%d", channel.StreamCount())
+	}
+	rc.Result.Cancel()
+	if err = rc.Result.Get(time.Second); err != motan.ErrAsyncCanceled || channel.StreamCount() != 0 {
+		t.Errorf("canceled async call should release the stream. count:%d, err:%v", channel.StreamCount(), err)
+	}
+}
+
+func TestCancelFrameAdvertised(t *testing.T) {
+	cancels := make(chan uint64, 8)
+	factory := func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			buf := bufio.NewReader(server)
+			for {
+				msg, err := mpro.Decode(buf)
+				if err != nil {
+					return
+				}
+				if msg.IsCancel() {
+					cancels <- msg.Header.RequestID
+				} else if msg.Metadata.LoadOrEmpty(mpro.MMethod) == "reply" {
+					res := mpro.BuildExceptionResponse(msg.Header.RequestID, mpro.ExceptionToJSON(&motan.Exception{ErrCode: 500, ErrMsg: "reply", ErrType: motan.ServiceException}))
+					res.SetAcceptCancel()
+					server.Write(res.Encode().Bytes())
+				}
+			}
+		}()
+		return client, nil
+	}
+	pool, err := NewChannelPool(1, factory, nil, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+	ep := &MotanEndpoint{url: &motan.URL{Port: 8989, Protocol: "motan2"}, channels: pool, serialization: &serialize.SimpleSerialization{}}
+	call := func(method string, timeout time.Duration) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		request := &motan.MotanRequest{ServiceName: "test", Method: method, Attachment: motan.NewStringMap(0)}
+		request.GetRPCContext(true).Context = ctx
+		ep.Call(request)
+	}
+
+	// the provider not advertised may not know the cancel frames
+	call("wait", 20*time.Millisecond)
+	select {
+	case id := <-cancels:
+		t.Errorf("cancel frame should not be sent before the provider advertised. requestid:%d", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	call("reply", time.Second)
+	call("wait", 20*time.Millisecond)
+	select {
+	case <-cancels:
+	case <-time.After(time.Second):
+		t.Errorf("cancel frame should be sent after the provider advertised")
+	}
+}
+
+func TestChannelPoolStats(t *testing.T) {
+	heartbeat := mpro.BuildHeartbeat(1, mpro.Res).Encode().Bytes()
+	dials := 0
+	factory := func() (net.Conn, error) {
+		if dials++; dials == 1 {
+			return nil, errors.New("dial fail")
+		}
+		client, server := net.Pipe()
+		go server.Write(heartbeat)
+		go io.Copy(ioutil.Discard, server)
+		return client, nil
+	}
+	config := DefaultConfig()
+	config.ReconnectBaseInterval = time.Millisecond
+	pool, err := NewChannelPool(2, factory, config, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+	channel, _ := pool.Get()
+	msg := &mpro.Message{Header: mpro.BuildHeader(mpro.Req, false, mpro.Simple, 0, mpro.Normal), Metadata: motan.NewStringMap(0)}
+	msg.Header.SetOneWay(true)
+	if _, err = channel.Call(msg, time.Second, nil); err != nil {
+		t.Fatalf("oneway call fail. err:%v", err)
+	}
+	var stats ChannelPoolStats
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if stats = pool.Stats(); stats.OpenConnections == 2 && stats.BytesSent > 0 && stats.BytesReceived == int64(2*len(heartbeat)) {
+			break
+		}
+	}
+	if stats.OpenConnections != 2 || stats.DialFailures != 1 || stats.Reconnects != 1 || stats.InFlightStreams != 0 {
+		t.Errorf("wrong pool stats: %+v", stats)
+	}
+	if stats.BytesSent <= 0 || stats.BytesReceived != int64(2*len(heartbeat)) {
+		t.Errorf("wrong pool traffic: %+v", stats)
+	}
+}
+
+func TestPing(t *testing.T) {
+	// the server side responds the heartbeats
+	factory := func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			buf := bufio.NewReader(server)
+			for {
+				msg, err := mpro.Decode(buf)
+				if err != nil {
+					return
+				}
+				if msg.Header.IsHeartbeat() {
+					server.Write(mpro.BuildHeartbeat(msg.Header.RequestID, mpro.Res).Encode().Bytes())
+				}
+			}
+		}()
+		return client, nil
+	}
+	ep := &MotanEndpoint{url: &motan.URL{Port: 8989, Protocol: "motan2"}}
+	if _, err := ep.Ping(time.Second); err == nil {
+		t.Errorf("ping should fail before the pool is ready")
+	}
+	pool, err := NewChannelPool(2, factory, nil, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+	ep.channels = pool
+	rtt, err := ep.Ping(time.Second)
+	if err != nil || rtt <= 0 || !ep.IsAvailable() {
+		t.Errorf("ping fail. rtt:%v, err:%v", rtt, err)
+	}
+}
+
+func TestDrainNotice(t *testing.T) {
+	servers := make(chan net.Conn, 8)
+	factory := func() (net.Conn, error) {
+		client, server := net.Pipe()
+		servers <- server
+		go func() {
+			buf := bufio.NewReader(server)
+			for {
+				msg, err := mpro.Decode(buf)
+				if err != nil {
+					return
+				}
+				if msg.Header.IsHeartbeat() {
+					server.Write(mpro.BuildHeartbeat(msg.Header.RequestID, mpro.Res).Encode().Bytes())
+				}
+			}
+		}()
+		return client, nil
+	}
+	ep := &MotanEndpoint{url: &motan.URL{Port: 8989, Protocol: "motan2", Parameters: map[string]string{DrainGracePeriodKey: "300"}}}
+	config := DefaultConfig()
+	config.ReconnectBaseInterval = 10 * time.Millisecond
+	config.onDrain = ep.onDrainNotice
+	ep.config = config
+	pool, err := NewChannelPool(1, factory, config, &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+	ep.channels = pool
+	ep.setAvailable(true)
+
+	// a request being processed delays closing the connection
+	channel, _ := pool.Get()
+	stream, err := channel.NewStream(&mpro.Message{Header: mpro.BuildHeader(mpro.Req, false, mpro.Simple, 0, mpro.Normal), Metadata: motan.NewStringMap(0)}, nil)
+	if err != nil {
+		t.Fatalf("new stream fail. err:%v", err)
+	}
+	server := <-servers
+	server.Write(mpro.BuildControlMessage(motan.ControlDrain, nil).Encode().Bytes())
+	time.Sleep(50 * time.Millisecond)
+	if ep.IsAvailable() {
+		t.Errorf("endpoint should not be available while draining")
+	}
+	if channel.IsClosed() {
+		t.Errorf("channel should not be closed before the requests finished")
+	}
+	stream.Close()
+	start := time.Now()
+	for !ep.IsAvailable() && time.Since(start) < time.Second {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !ep.IsAvailable() || !channel.IsClosed() {
+		t.Fatalf("endpoint should be available after reconnected. available:%v, closed:%v", ep.IsAvailable(), channel.IsClosed())
+	}
+	if len(servers) != 1 {
+		t.Errorf("channel should be reconnected once, reconnects:%d", len(servers))
+	}
+}
+
+func TestThrottleHint(t *testing.T) {
+	factory := func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			buf := bufio.NewReader(server)
+			for {
+				msg, err := mpro.Decode(buf)
+				if err != nil {
+					return
+				}
+				res := mpro.BuildExceptionResponse(msg.Header.RequestID, mpro.ExceptionToJSON(motan.NewRejectException("rate limited")))
+				res.Metadata.Store(motan.ThrottleRetryAfterKey, "10000")
+				res.Metadata.Store(motan.ThrottleRemainingKey, "0")
+				server.Write(res.Encode().Bytes())
+			}
+		}()
+		return client, nil
+	}
+	ep := &MotanEndpoint{url: &motan.URL{Port: 8989, Protocol: "motan2", Parameters: map[string]string{MaxThrottleBackoffKey: "100"}}}
+	ep.SetSerialization(&serialize.SimpleSerialization{})
+	pool, err := NewChannelPool(1, factory, DefaultConfig(), &serialize.SimpleSerialization{})
+	if err != nil {
+		t.Fatalf("new channel pool fail. err:%v", err)
+	}
+	defer pool.Close()
+	ep.channels = pool
+	ep.setAvailable(true)
+
+	res := ep.Call(&motan.MotanRequest{ServiceName: "test", Method: "test", Attachment: motan.NewStringMap(0)})
+	if res.GetException() == nil || res.GetException().ErrCode != motan.ErrCodeReject {
+		t.Fatalf("call should be rejected. res:%+v", res)
+	}
+	if hint, ok := motan.GetThrottleHint(res); !ok || hint.RetryAfter != 10*time.Second || hint.Remaining != 0 {
+		t.Errorf("wrong throttle hint. hint:%+v, ok:%v", hint, ok)
+	}
+	if ep.IsAvailable() || !ep.Throttled() {
+		t.Errorf("endpoint should be backed off by the throttle hint")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if !ep.IsAvailable() {
+		t.Errorf("endpoint should be available after the max backoff")
+	}
+}
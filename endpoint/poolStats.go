@@ -0,0 +1,103 @@
+package endpoint
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/weibocom/motan-go/metrics"
+)
+
+var (
+	// the motan endpoints initialized and not destroyed, for the metrics
+	endpoints sync.Map
+)
+
+func init() {
+	registerPoolGauge("motan_endpoint_open_connections", "the connected channels of the endpoint", false, func(s ChannelPoolStats) float64 {
+		return float64(s.OpenConnections)
+	})
+	registerPoolGauge("motan_endpoint_inflight_streams", "the requests of the endpoint waiting for the responses", false, func(s ChannelPoolStats) float64 {
+		return float64(s.InFlightStreams)
+	})
+	registerPoolGauge("motan_endpoint_send_queue_depth", "the requests of the endpoint queued to be written to the connections", false, func(s ChannelPoolStats) float64 {
+		return float64(s.QueueDepth)
+	})
+	registerPoolGauge("motan_endpoint_dial_failures_total", "the failed dials of the endpoint", true, func(s ChannelPoolStats) float64 {
+		return float64(s.DialFailures)
+	})
+	registerPoolGauge("motan_endpoint_reconnects_total", "the channels of the endpoint reconnected after closed", true, func(s ChannelPoolStats) float64 {
+		return float64(s.Reconnects)
+	})
+	registerPoolGauge("motan_endpoint_sent_bytes_total", "the bytes written to the connections of the endpoint", true, func(s ChannelPoolStats) float64 {
+		return float64(s.BytesSent)
+	})
+	registerPoolGauge("motan_endpoint_received_bytes_total", "the bytes read from the connections of the endpoint", true, func(s ChannelPoolStats) float64 {
+		return float64(s.BytesReceived)
+	})
+}
+
+func registerPoolGauge(name string, help string, counter bool, value func(ChannelPoolStats) float64) {
+	values := func() []metrics.GaugeValue {
+		var values []metrics.GaugeValue
+		endpoints.Range(func(k, _ interface{}) bool {
+			m := k.(*MotanEndpoint)
+			values = append(values, metrics.GaugeValue{
+				Labels: map[string]string{"service": m.url.Path, "group": m.url.Group, "endpoint": m.url.GetAddressStr()},
+				Value:  value(m.PoolStats()),
+			})
+			return true
+		})
+		return values
+	}
+	if counter {
+		metrics.RegisterCounterFunc(name, help, values)
+	} else {
+		metrics.RegisterGauge(name, help, values)
+	}
+}
+
+// ChannelPoolStats is the connections and the traffic of a channel pool, the counters are the totals since the
+// endpoint is initialized
+type ChannelPoolStats struct {
+	OpenConnections int   `json:"open_connections"`
+	InFlightStreams int   `json:"inflight_streams"`
+	QueueDepth      int   `json:"queue_depth"` // the requests waiting to be written
+	DialFailures    int64 `json:"dial_failures"`
+	Reconnects      int64 `json:"reconnects"`
+	BytesSent       int64 `json:"bytes_sent"`
+	BytesReceived   int64 `json:"bytes_received"`
+}
+
+type poolCounters struct {
+	dialFailures  int64
+	reconnects    int64
+	bytesSent     int64
+	bytesReceived int64
+}
+
+func (p *poolCounters) stats() ChannelPoolStats {
+	if p == nil {
+		return ChannelPoolStats{}
+	}
+	return ChannelPoolStats{
+		DialFailures:  atomic.LoadInt64(&p.dialFailures),
+		Reconnects:    atomic.LoadInt64(&p.reconnects),
+		BytesSent:     atomic.LoadInt64(&p.bytesSent),
+		BytesReceived: atomic.LoadInt64(&p.bytesReceived),
+	}
+}
+
+// countingReader counts the bytes read from the connection
+type countingReader struct {
+	io.Reader
+	count *int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		atomic.AddInt64(r.count, int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,139 @@
+package endpoint
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+var (
+	defaultStreamRecvSize = 64
+	ErrStreamClosed       = errors.New("stream has been closed")
+	ErrStreamBufferFull   = errors.New("stream recv buffer is full")
+)
+
+// clientStream is the client side of a streaming call. all frames of the call use the request id of the open frame,
+// request frames are sent through the channel and response frames are received by the registered Stream.
+type clientStream struct {
+	stream        *Stream
+	serialization motan.Serialization
+	timeout       time.Duration
+	sendEndOnce   sync.Once
+}
+
+func (m *MotanEndpoint) openStream(channel *Channel, request motan.Request, msg *mpro.Message, timeout time.Duration) motan.Response {
+	if m.proxy {
+		return m.defaultErrMotanResponse(request, "streaming call is not supported in proxy mode")
+	}
+	msg.Metadata.Store(mpro.MStream, mpro.StreamOpen)
+	stream, err := channel.NewStream(msg, nil)
+	if err != nil {
+		return m.defaultErrMotanResponse(request, "open stream fail:"+err.Error())
+	}
+	stream.recvCh = make(chan *mpro.Message, defaultStreamRecvSize)
+	stream.streamDone = make(chan struct{})
+	stream.SetDeadline(timeout)
+	cs := &clientStream{stream: stream, serialization: m.serialization, timeout: timeout}
+	if err = stream.Send(); err != nil {
+		cs.close()
+		vlog.Errorf("motanEndpoint open stream fail. ep:%s, req:%s, error: %s\n", m.url.GetAddressStr(), motan.GetReqInfo(request), err.Error())
+		return m.defaultErrMotanResponse(request, "open stream fail:"+err.Error())
+	}
+	return &motan.MotanResponse{RequestID: request.GetRequestID(), Value: cs, Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
+}
+
+func (c *clientStream) Send(v interface{}) error {
+	if c.isDone() {
+		return ErrStreamClosed
+	}
+	body, err := c.serialization.Serialize(v)
+	if err != nil {
+		return err
+	}
+	return c.sendFrame(mpro.StreamData, body)
+}
+
+func (c *clientStream) CloseSend() (err error) {
+	c.sendEndOnce.Do(func() {
+		err = c.sendFrame(mpro.StreamEnd, nil)
+	})
+	return err
+}
+
+func (c *clientStream) sendFrame(frame string, body []byte) error {
+	msg := mpro.BuildStreamFrame(mpro.Req, c.serialization.GetSerialNum(), c.stream.sendMsg.Header.RequestID, frame, body)
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case c.stream.channel.sendCh <- newSendReady(msg.Encode()):
+		return nil
+	case <-timer.C:
+		return ErrSendRequestTimeout
+	case <-c.stream.streamDone:
+		return ErrStreamClosed
+	case <-c.stream.channel.shutdownCh:
+		return ErrChannelShutdown
+	}
+}
+
+// Recv receives the next response frame. the stream is closed when the provider finished or an error occurred.
+func (c *clientStream) Recv(v interface{}) error {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case msg := <-c.stream.recvCh:
+		res, err := mpro.ConvertToResponse(msg, c.serialization)
+		if err != nil {
+			c.close()
+			return err
+		}
+		if res.GetException() != nil {
+			c.close()
+			return errors.New(res.GetException().ErrMsg)
+		}
+		if msg.GetStreamFrame() == mpro.StreamEnd {
+			c.close()
+			return io.EOF
+		}
+		return res.ProcessDeserializable(v)
+	case <-timer.C:
+		c.stream.channel.cancel(c.stream.sendMsg.Header.RequestID)
+		c.close()
+		return ErrRecvRequestTimeout
+	case <-c.stream.streamDone:
+		if c.stream.recvErr != nil {
+			return c.stream.recvErr
+		}
+		return io.EOF
+	case <-c.stream.channel.shutdownCh:
+		return ErrChannelShutdown
+	}
+}
+
+// Close abandons the call, the provider is notified to stop processing it
+func (c *clientStream) Close() error {
+	if c.isDone() {
+		return nil
+	}
+	c.close()
+	c.stream.channel.cancel(c.stream.sendMsg.Header.RequestID)
+	return nil
+}
+
+func (c *clientStream) isDone() bool {
+	select {
+	case <-c.stream.streamDone:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *clientStream) close() {
+	c.stream.finish(nil)
+}
@@ -0,0 +1,35 @@
+package endpoint
+
+import (
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// throttle backs off the endpoint by the throttle hint of the provider, the endpoint is not selected until the
+// retry-after passed, so the requests go to the other endpoints instead of being retried on the throttled one
+func (m *MotanEndpoint) throttle(hint motan.ThrottleHint) {
+	backoff := hint.RetryAfter
+	if max := m.url.GetTimeDuration(MaxThrottleBackoffKey, time.Millisecond, defaultMaxThrottleBackoff); backoff > max {
+		backoff = max
+	}
+	until := time.Now().Add(backoff).UnixNano()
+	for {
+		last := atomic.LoadInt64(&m.throttledUntil)
+		if last >= until {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&m.throttledUntil, last, until) {
+			break
+		}
+	}
+	vlog.Infow("motan2 endpoint throttled", vlog.String("ep", m.url.GetAddressStr()), vlog.Duration("backoff", backoff), vlog.Int64("remaining", hint.Remaining))
+}
+
+// Throttled tells whether the endpoint is backed off by the throttle hint of the provider
+func (m *MotanEndpoint) Throttled() bool {
+	until := atomic.LoadInt64(&m.throttledUntil)
+	return until != 0 && time.Now().UnixNano() < until
+}
@@ -38,22 +38,33 @@ func (t *AccessLogEndPointFilter) Filter(caller motan.Caller, request motan.Requ
 	start := time.Now()
 	response := t.GetNext().Filter(caller, request)
 	success := true
-	l := 0
-	if response.GetValue() != nil {
-		if b, ok := response.GetValue().([]byte); ok {
-			l = len(b)
-		}
-		if s, ok := response.GetValue().(string); ok {
-			l = len(s)
-		}
-	}
+	reqSize, resSize := payloadSize(request, response)
 	if response.GetException() != nil {
 		success = false
 	}
-	vlog.Infof("access log--%s:%s,%d,pt:%d,size:%d,req:%s,%s,%s,%d, res:%d,%t,%+v\n", role, ip, caller.GetURL().Port, response.GetProcessTime(), l, request.GetServiceName(), request.GetMethod(), request.GetMethodDesc(), request.GetRequestID(), time.Since(start)/1000000, success, response.GetException())
+	vlog.AccessLogf("access log--%s:%s,%d,pt:%d,size:%d,reqsize:%d,req:%s,%s,%s,%d, res:%d,%t,%+v,cid:%s\n", role, ip, caller.GetURL().Port, response.GetProcessTime(), resSize, reqSize, request.GetServiceName(), request.GetMethod(), request.GetMethodDesc(), request.GetRequestID(), time.Since(start)/1000000, success, response.GetException(), request.GetAttachment(motan.CorrelationIDKey))
 	return response
 }
 
+// payloadSize returns the serialized bytes of the request and the response. the response size is the length
+// of the value if the response is not serialized yet, such as in the filters of the provider side
+func payloadSize(request motan.Request, response motan.Response) (int, int) {
+	rc := request.GetRPCContext(false)
+	resSize := rc.ResponseSize()
+	if resSize == 0 {
+		resSize = response.GetRPCContext(false).ResponseSize()
+	}
+	if resSize == 0 && response.GetValue() != nil {
+		switch v := response.GetValue().(type) {
+		case []byte:
+			resSize = len(v)
+		case string:
+			resSize = len(v)
+		}
+	}
+	return rc.RequestSize(), resSize
+}
+
 func (t *AccessLogEndPointFilter) HasNext() bool {
 	return t.next != nil
 }
@@ -3,6 +3,7 @@ package filter
 import (
 	"errors"
 	"strconv"
+	"sync"
 
 	"github.com/afex/hystrix-go/hystrix"
 	motan "github.com/weibocom/motan-go/core"
@@ -20,8 +21,22 @@ const (
 
 var (
 	errExecuteFailure = errors.New("grpc: invoke error")
+
+	circuitBreakers sync.Map // endpoint url identity -> *hystrix.CircuitBreaker
 )
 
+// GetCircuitBreakerState returns "open" or "closed" as the state of the circuit breaker of the endpoint,
+// or an empty string if the circuit breaker of the endpoint is not enabled
+func GetCircuitBreakerState(url *motan.URL) string {
+	if cb, ok := circuitBreakers.Load(url.GetIdentity()); ok {
+		if cb.(*hystrix.CircuitBreaker).IsOpen() {
+			return "open"
+		}
+		return "closed"
+	}
+	return ""
+}
+
 type CircuitBreakerEndPointFilter struct {
 	URL                  *motan.URL
 	next                 motan.EndPointFilter
@@ -48,6 +63,7 @@ func (t *CircuitBreakerEndPointFilter) NewFilter(url *motan.URL) motan.Filter {
 			circuitBreakerEnable = false
 			vlog.Errorf("CircuitBreaker not available! err %s\n", err.Error())
 		} else {
+			circuitBreakers.Store(url.GetIdentity(), circuitBreaker)
 			vlog.Infof("CircuitBreaker: %v = %+v \n", url.GetIdentity(), commandConfig)
 		}
 	}
@@ -68,9 +84,8 @@ func (t *CircuitBreakerEndPointFilter) Filter(caller motan.Caller, request motan
 				RequestID:   request.GetRequestID(),
 				Attachment:  motan.NewStringMap(motan.DefaultAttachmentSize),
 				ProcessTime: 0,
-				// todo exception 后续统一规划
-				Exception: &motan.Exception{ErrCode: 400, ErrMsg: err.Error(), ErrType: motan.ServiceException},
-				Value:     make([]byte, 0)}
+				Exception:   motan.NewCircuitOpenException(err.Error()),
+				Value:       make([]byte, 0)}
 			return err
 		})
 	} else {
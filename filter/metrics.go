@@ -78,6 +78,7 @@ func (m *MetricsFilter) Filter(caller motan.Caller, request motan.Request) motan
 	}
 	key := role + ":" + application + ":" + request.GetMethod()
 	addMetric(request.GetAttachment("M_g"), request.GetAttachment("M_p"), key, time.Since(start).Nanoseconds()/1e6, response)
+	addSizeMetric(request.GetAttachment("M_g"), request.GetAttachment("M_p"), key, request, response)
 	return response
 }
 
@@ -89,6 +90,8 @@ func addMetric(group string, service string, key string, cost int64, response mo
 			metrics.AddCounter(group, service, key+".biz_error_count", 1)
 		} else {
 			metrics.AddCounter(group, service, key+".other_error_count", 1)
+			// the counters of the error kinds like client_timeout_error_count
+			metrics.AddCounter(group, service, key+"."+motan.ErrorKind(exception)+"_error_count", 1)
 		}
 	}
 	metrics.AddCounter(group, service, key+metrics.ElapseTimeSuffix(cost), 1)
@@ -98,6 +101,17 @@ func addMetric(group string, service string, key string, cost int64, response mo
 	metrics.AddHistograms(group, service, key, cost)
 }
 
+// addSizeMetric counts the payload bytes, the average sizes are the bytes divided by the total count
+func addSizeMetric(group string, service string, key string, request motan.Request, response motan.Response) {
+	reqSize, resSize := payloadSize(request, response)
+	if reqSize > 0 {
+		metrics.AddCounter(group, service, key+".request_bytes", int64(reqSize))
+	}
+	if resSize > 0 {
+		metrics.AddCounter(group, service, key+".response_bytes", int64(resSize))
+	}
+}
+
 func (m *MetricsFilter) SetContext(context *motan.Context) {
 	metrics.StartReporter(context)
 }
@@ -3,6 +3,7 @@ package filter
 import (
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/juju/ratelimit"
 	"github.com/weibocom/motan-go/core"
@@ -12,17 +13,20 @@ import (
 const (
 	defaultCapacity    = 1000
 	methodConfigPrefix = "rateLimit."
+	// reject the requests over the rate with the throttle hints instead of waiting for the tokens
+	RateLimitRejectKey = "rateLimitReject"
 )
 
 type RateLimitFilter struct {
 	switcher      *core.Switcher
 	bucket        *ratelimit.Bucket            //limit service
 	methodBuckets map[string]*ratelimit.Bucket //limit method
+	reject        bool
 	next          core.EndPointFilter
 }
 
 func (r *RateLimitFilter) NewFilter(url *core.URL) core.Filter {
-	ret := &RateLimitFilter{}
+	ret := &RateLimitFilter{reject: url.GetParam(RateLimitRejectKey, "") == "true"}
 
 	//init bucket
 	if rate, err := strconv.ParseFloat(url.GetParam(RateLimit, ""), 64); err == nil {
@@ -57,15 +61,34 @@ func (r *RateLimitFilter) NewFilter(url *core.URL) core.Filter {
 func (r *RateLimitFilter) Filter(caller core.Caller, request core.Request) core.Response {
 	if r.switcher.IsOpen() {
 		if r.bucket != nil {
-			r.bucket.Wait(1)
+			if res := r.take(r.bucket, request); res != nil {
+				return res
+			}
 		}
 		if methodBucket, ok := r.methodBuckets[request.GetMethod()]; ok {
-			methodBucket.Wait(1)
+			if res := r.take(methodBucket, request); res != nil {
+				return res
+			}
 		}
 	}
 	return r.GetNext().Filter(caller, request)
 }
 
+// take waits for a token of the bucket, or returns the rejected response with the throttle hint if no token
+// is available in the reject mode
+func (r *RateLimitFilter) take(bucket *ratelimit.Bucket, request core.Request) core.Response {
+	if !r.reject {
+		bucket.Wait(1)
+		return nil
+	}
+	if bucket.TakeAvailable(1) == 1 {
+		return nil
+	}
+	res := core.BuildExceptionResponse(request.GetRequestID(), core.NewRejectException("request rejected by rate limit"))
+	core.SetThrottleHint(res, core.ThrottleHint{RetryAfter: time.Duration(float64(time.Second) / bucket.Rate()), Remaining: bucket.Available()})
+	return res
+}
+
 func GetRateLimitSwitcherName(url *core.URL) string {
 	return url.GetParam("conf-id", "") + "_rateLimit"
 }
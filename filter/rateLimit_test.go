@@ -65,3 +65,30 @@ func TestRateLimitFilter(t *testing.T) {
 		t.Error("Test switcher failed!")
 	}
 }
+
+func TestRateLimitReject(t *testing.T) {
+	defaultExtFactory := &core.DefaultExtensionFactory{}
+	defaultExtFactory.Initialize()
+	RegistDefaultFilters(defaultExtFactory)
+	request := &core.MotanRequest{Method: "testMethod"}
+	param := map[string]string{"rateLimit.testMethod": "10", RateLimitRejectKey: "true", "conf-id": "reject"}
+	filterURL := &core.URL{Host: "127.0.0.1", Port: 7888, Protocol: "mockEndpoint", Parameters: param}
+	ef := defaultExtFactory.GetFilter(RateLimit).NewFilter(filterURL).(core.EndPointFilter)
+	ef.SetNext(core.GetLastEndPointFilter())
+	caller := &core.TestEndPoint{URL: filterURL}
+
+	start := time.Now()
+	rejected := 0
+	for i := 0; i < 1001; i++ {
+		res := ef.Filter(caller, request)
+		if res.GetException() != nil && res.GetException().ErrCode == core.ErrCodeReject {
+			rejected++
+			if hint, ok := core.GetThrottleHint(res); !ok || hint.RetryAfter != 100*time.Millisecond {
+				t.Fatalf("rejected response should have the throttle hint. hint:%+v", hint)
+			}
+		}
+	}
+	if rejected != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("requests over the rate should be rejected without waiting. rejected:%d, elapsed:%v", rejected, time.Since(start))
+	}
+}
@@ -27,6 +27,9 @@ type CallData struct {
 func DefaultTraceRecordingFunc(span ot.Span, data *CallData) {
 	span.SetTag("service.type", "motan")
 	span.SetTag("service.group", data.Caller.GetURL().Group)
+	if cid := data.Request.GetAttachment(core.CorrelationIDKey); cid != "" {
+		span.SetTag("motan.correlation_id", cid)
+	}
 
 	if ex := data.Response.GetException(); ex != nil {
 		span.SetTag(string(ext.Error), true)
@@ -0,0 +1,338 @@
+// Package gen generates typed motan client stubs and provider invokers from Go interfaces,
+// so the arguments are bound at compile time instead of by reflection.
+//
+// for an interface
+//
+//	type UserService interface {
+//		Get(ctx context.Context, id int64) (*User, error)
+//	}
+//
+// the generated code in the same package has
+//
+//	UserServiceClient   // calls UserService by a *motan.Client
+//	UserServiceInvoker  // implements provider.Invoker by calling a UserService, and describes its methods
+//	NewUserServiceProvider(service UserService, url *core.URL) core.Provider
+//
+// the invoker can be registered by MSContext.RegisterService like other services. each method may take a
+// context.Context as its first parameter, and must return an error as its last result with at most one value.
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strconv"
+	"text/template"
+)
+
+// Method is a method of the generated service
+type Method struct {
+	Name        string
+	WithContext bool
+	Params      []string // types of the parameters except the context
+	Result      string   // type of the value result, empty if the method returns error only
+}
+
+// Service is an interface to generate
+type Service struct {
+	Name    string
+	Methods []*Method
+}
+
+// File is the parsed source file
+type File struct {
+	Package  string
+	Imports  []string // import specs used by the methods, sorted by path
+	Services []*Service
+}
+
+// Generate generates the code for the interfaces in the source file, all interfaces if no name is given
+func Generate(filename string, src []byte, names ...string) ([]byte, error) {
+	f, err := Parse(filename, src, names...)
+	if err != nil {
+		return nil, err
+	}
+	return f.Generate()
+}
+
+// Parse parses the interfaces in the source file, all interfaces if no name is given
+func Parse(filename string, src []byte, names ...string) (*File, error) {
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(names))
+	missing := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[n] = true
+		missing[n] = true
+	}
+	f := &File{Package: af.Name.Name}
+	usedPkgs := make(map[string]bool)
+	for _, decl := range af.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			it, ok := ts.Type.(*ast.InterfaceType)
+			if !ok || (len(wanted) > 0 && !wanted[ts.Name.Name]) {
+				continue
+			}
+			delete(missing, ts.Name.Name)
+			s, err := parseService(fset, ts.Name.Name, it, usedPkgs)
+			if err != nil {
+				return nil, err
+			}
+			f.Services = append(f.Services, s)
+		}
+	}
+	for n := range missing {
+		return nil, fmt.Errorf("interface %s is not found in %s", n, filename)
+	}
+	if len(f.Services) == 0 {
+		return nil, errors.New("no interface in " + filename)
+	}
+	paths := make(map[string]string)
+	for _, is := range af.Imports {
+		path, _ := strconv.Unquote(is.Path.Value)
+		name := importName(path)
+		if is.Name != nil {
+			name = is.Name.Name
+		}
+		if usedPkgs[name] {
+			paths[printNode(fset, is)] = path
+		}
+	}
+	for spec := range paths {
+		f.Imports = append(f.Imports, spec)
+	}
+	sort.Slice(f.Imports, func(i, j int) bool { return paths[f.Imports[i]] < paths[f.Imports[j]] })
+	return f, nil
+}
+
+func parseService(fset *token.FileSet, name string, it *ast.InterfaceType, usedPkgs map[string]bool) (*Service, error) {
+	s := &Service{Name: name}
+	for _, field := range it.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok {
+			return nil, fmt.Errorf("embedded interface %s in %s is not supported", printNode(fset, field.Type), name)
+		}
+		ast.Inspect(ft, func(n ast.Node) bool {
+			if se, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := se.X.(*ast.Ident); ok {
+					usedPkgs[id.Name] = true
+				}
+			}
+			return true
+		})
+		m := &Method{Name: field.Names[0].Name}
+		var params []string
+		for _, p := range ft.Params.List {
+			t := printNode(fset, p.Type)
+			if _, ok := p.Type.(*ast.Ellipsis); ok {
+				return nil, fmt.Errorf("variadic parameter of %s.%s is not supported", name, m.Name)
+			}
+			n := len(p.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				params = append(params, t)
+			}
+		}
+		if len(params) > 0 && params[0] == "context.Context" {
+			m.WithContext = true
+			params = params[1:]
+		}
+		m.Params = params
+		var results []string
+		if ft.Results != nil {
+			for _, r := range ft.Results.List {
+				t := printNode(fset, r.Type)
+				n := len(r.Names)
+				if n == 0 {
+					n = 1
+				}
+				for i := 0; i < n; i++ {
+					results = append(results, t)
+				}
+			}
+		}
+		if len(results) == 0 || len(results) > 2 || results[len(results)-1] != "error" {
+			return nil, fmt.Errorf("method %s.%s must return an error as its last result with at most one value", name, m.Name)
+		}
+		if len(results) == 2 {
+			m.Result = results[0]
+		}
+		s.Methods = append(s.Methods, m)
+	}
+	return s, nil
+}
+
+func printNode(fset *token.FileSet, n ast.Node) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, n)
+	return buf.String()
+}
+
+// importName returns the default name of the imported package
+func importName(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' {
+			return path[i+1:]
+		}
+	}
+	return path
+}
+
+// Generate generates the formatted code of the file
+func (f *File) Generate() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := codeTemplate.Execute(&buf, f); err != nil {
+		return nil, err
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code fail. err:%v\n%s", err, buf.String())
+	}
+	return code, nil
+}
+
+var codeTemplate = template.Must(template.New("motan").Funcs(template.FuncMap{
+	"args": func(m *Method) string {
+		var buf bytes.Buffer
+		if m.WithContext {
+			buf.WriteString("ctx context.Context")
+		}
+		for i, p := range m.Params {
+			if buf.Len() > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "a%d %s", i, p)
+		}
+		return buf.String()
+	},
+	"argList": func(m *Method) string {
+		if len(m.Params) == 0 {
+			return "nil"
+		}
+		var buf bytes.Buffer
+		buf.WriteString("[]interface{}{")
+		for i := range m.Params {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "a%d", i)
+		}
+		buf.WriteString("}")
+		return buf.String()
+	},
+	"argNames": func(m *Method, prefix string) string {
+		var buf bytes.Buffer
+		for i := range m.Params {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "%sa%d", prefix, i)
+		}
+		return buf.String()
+	},
+	"results": func(m *Method) string {
+		if m.Result == "" {
+			return "error"
+		}
+		return "(" + m.Result + ", error)"
+	},
+}).Parse(`// Code generated by motan-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+
+	motan "github.com/weibocom/motan-go"
+	motancore "github.com/weibocom/motan-go/core"
+	motanprovider "github.com/weibocom/motan-go/provider"
+)
+{{range $s := .Services}}
+// {{$s.Name}}Client calls {{$s.Name}} by the motan client
+type {{$s.Name}}Client struct {
+	client *motan.Client
+}
+
+// New{{$s.Name}}Client creates the client of {{$s.Name}}
+func New{{$s.Name}}Client(client *motan.Client) *{{$s.Name}}Client {
+	return &{{$s.Name}}Client{client: client}
+}
+{{range $m := $s.Methods}}
+func (c *{{$s.Name}}Client) {{$m.Name}}({{args $m}}) {{results $m}} {
+	{{- if $m.Result}}
+	var reply {{$m.Result}}
+	{{- end}}
+	{{- if $m.WithContext}}
+	err := c.client.CallContext(ctx, "{{$m.Name}}", {{argList $m}}, {{if $m.Result}}&reply{{else}}nil{{end}})
+	{{- else}}
+	err := c.client.Call("{{$m.Name}}", {{argList $m}}, {{if $m.Result}}&reply{{else}}nil{{end}})
+	{{- end}}
+	{{- if $m.Result}}
+	return reply, err
+	{{- else}}
+	return err
+	{{- end}}
+}
+{{end}}
+// {{$s.Name}}Invoker calls {{$s.Name}} for the requests without reflection
+type {{$s.Name}}Invoker struct {
+	Service {{$s.Name}}
+}
+
+// Invoke implements provider.Invoker
+func (i *{{$s.Name}}Invoker) Invoke(request motancore.Request) motancore.Response {
+	switch motancore.FirstUpper(request.GetMethod()) {
+	{{- range $m := $s.Methods}}
+	case "{{$m.Name}}":
+		{{- range $i, $p := $m.Params}}
+		var a{{$i}} {{$p}}
+		{{- end}}
+		{{- if $m.Params}}
+		if err := motanprovider.BindArgs(request, {{argNames $m "&"}}); err != nil {
+			return motanprovider.BuildArgsErrorResponse(request, err)
+		}
+		{{- end}}
+		{{if $m.Result}}r, err{{else}}err{{end}} := i.Service.{{$m.Name}}({{if $m.WithContext}}motanprovider.CallContext(request){{if $m.Params}}, {{end}}{{end}}{{argNames $m ""}})
+		return motanprovider.BuildResponse(request, {{if $m.Result}}r{{else}}nil{{end}}, err)
+	{{- end}}
+	}
+	return motanprovider.MethodNotFound(request)
+}
+
+// DescribeMethods implements motancore.MethodDescriber
+func (i *{{$s.Name}}Invoker) DescribeMethods() []*motancore.MethodDescriptor {
+	return []*motancore.MethodDescriptor{
+		{{- range $m := $s.Methods}}
+		{Name: "{{$m.Name}}", Params: []string{ {{- range $i, $p := $m.Params}}{{if $i}}, {{end}}{{printf "%q" $p}}{{end -}} }
+			{{- if $m.Result}}, Result: {{printf "%q" $m.Result}}{{end}}{{if $m.WithContext}}, WithContext: true{{end}}},
+		{{- end}}
+	}
+}
+
+// New{{$s.Name}}Provider creates the provider of {{$s.Name}}, which can be added to a message handler directly
+func New{{$s.Name}}Provider(service {{$s.Name}}, url *motancore.URL) motancore.Provider {
+	p := &motanprovider.DefaultProvider{}
+	p.SetURL(url)
+	p.SetService(&{{$s.Name}}Invoker{Service: service})
+	p.Initialize()
+	return p
+}
+{{end}}`))
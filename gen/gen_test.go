@@ -0,0 +1,102 @@
+package gen
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const testSource = `package user
+
+import (
+	"context"
+	"strings"
+	t "time"
+)
+
+var _ = strings.Repeat
+
+type User struct {
+	Name    string
+	Created t.Time
+}
+
+type UserService interface {
+	Get(ctx context.Context, id int64) (*User, error)
+	Rename(id int64, name string) error
+	Span(a, b t.Duration) (d t.Duration, err error)
+}
+
+type Other interface {
+	Ping() error
+}
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse("user.go", []byte(testSource), "UserService")
+	if err != nil {
+		t.Fatalf("parse fail. err:%v", err)
+	}
+	if f.Package != "user" || len(f.Services) != 1 || len(f.Services[0].Methods) != 3 {
+		t.Fatalf("wrong parsed file. file:%+v", f)
+	}
+	if strings.Join(f.Imports, ",") != `"context",t "time"` {
+		t.Errorf("only used imports should be kept. imports:%v", f.Imports)
+	}
+	get, rename, span := f.Services[0].Methods[0], f.Services[0].Methods[1], f.Services[0].Methods[2]
+	if !get.WithContext || strings.Join(get.Params, ",") != "int64" || get.Result != "*User" {
+		t.Errorf("wrong method. method:%+v", get)
+	}
+	if rename.WithContext || strings.Join(rename.Params, ",") != "int64,string" || rename.Result != "" {
+		t.Errorf("wrong method. method:%+v", rename)
+	}
+	if strings.Join(span.Params, ",") != "t.Duration,t.Duration" || span.Result != "t.Duration" {
+		t.Errorf("wrong method. method:%+v", span)
+	}
+	if f, err = Parse("user.go", []byte(testSource)); err != nil || len(f.Services) != 2 {
+		t.Errorf("all interfaces should be parsed by default. err:%v", err)
+	}
+	if _, err = Parse("user.go", []byte(testSource), "Unknown"); err == nil {
+		t.Errorf("unknown interface should fail")
+	}
+	for _, src := range []string{
+		"package a\ntype S interface { Get() int }",
+		"package a\ntype S interface { Get() (int, int, error) }",
+		"package a\ntype S interface { Get(a ...int) error }",
+		"package a\ntype S interface { Other }",
+	} {
+		if _, err = Parse("a.go", []byte(src)); err == nil {
+			t.Errorf("unsupported method should fail. source:%s", src)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	code, err := Generate("user.go", []byte(testSource))
+	if err != nil {
+		t.Fatalf("generate fail. err:%v", err)
+	}
+	if _, err = parser.ParseFile(token.NewFileSet(), "user_motan.go", code, 0); err != nil {
+		t.Fatalf("generated code can not be parsed. err:%v", err)
+	}
+	for _, s := range []string{
+		"func NewUserServiceClient(client *motan.Client) *UserServiceClient",
+		"func (c *UserServiceClient) Get(ctx context.Context, a0 int64) (*User, error)",
+		`err := c.client.CallContext(ctx, "Get", []interface{}{a0}, &reply)`,
+		`err := c.client.Call("Rename", []interface{}{a0, a1}, nil)`,
+		"func (c *UserServiceClient) Span(a0 t.Duration, a1 t.Duration) (t.Duration, error)",
+		"if err := motanprovider.BindArgs(request, &a0, &a1); err != nil",
+		"r, err := i.Service.Get(motanprovider.CallContext(request), a0)",
+		"err := i.Service.Rename(a0, a1)",
+		"func NewOtherProvider(service Other, url *motancore.URL) motancore.Provider",
+		`err := c.client.Call("Ping", nil, nil)`,
+		`{Name: "Get", Params: []string{"int64"}, Result: "*User", WithContext: true},`,
+		`{Name: "Rename", Params: []string{"int64", "string"}},`,
+		`{Name: "Ping", Params: []string{}},`,
+	} {
+		if !strings.Contains(string(code), s) {
+			t.Errorf("generated code should contain %s", s)
+		}
+	}
+}
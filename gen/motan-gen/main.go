@@ -0,0 +1,49 @@
+// motan-gen generates typed motan client stubs and provider invokers from the Go interfaces in a source file.
+//
+//	motan-gen -i user.go [-type UserService,OrderService] [-o user_motan.go]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/weibocom/motan-go/gen"
+)
+
+func main() {
+	input := flag.String("i", "", "source file with the interfaces")
+	types := flag.String("type", "", "comma separated interfaces to generate, all interfaces by default")
+	output := flag.String("o", "", "output file, <input>_motan.go by default")
+	flag.Parse()
+	if *input == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	src, err := ioutil.ReadFile(*input)
+	if err != nil {
+		fail(err)
+	}
+	var names []string
+	if *types != "" {
+		names = strings.Split(*types, ",")
+	}
+	code, err := gen.Generate(*input, src, names...)
+	if err != nil {
+		fail(err)
+	}
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(*input, ".go") + "_motan.go"
+	}
+	if err = ioutil.WriteFile(out, code, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "motan-gen:", err)
+	os.Exit(1)
+}
@@ -70,6 +70,7 @@ func (br *BackupRequestHA) Call(request motan.Request, loadBalance motan.LoadBal
 		delay = 10 // min 10ms
 	}
 	var lastErrorCh chan motan.Response
+	done := request.GetRPCContext(true).Done()
 
 	for i := 0; i <= int(retries) && i < len(epList); i++ {
 		ep := epList[i]
@@ -80,17 +81,17 @@ func (br *BackupRequestHA) Call(request motan.Request, loadBalance motan.LoadBal
 			vlog.Warningf("The permit is used up, request id: %d\n", request.GetRequestID())
 			break
 		}
-		// log & clone backup request
-		pr := request
+		// log & clone backup request. the first request is cloned too, as it may be still in use after the call
+		// returned by a backup request, while the request is released to the pool by the owner
 		if i > 0 {
 			vlog.Infof("[backup request ha] delay %d request id: %d, service: %s, method: %s\n", delay, request.GetRequestID(), request.GetServiceName(), request.GetMethod())
-			pr = request.Clone().(motan.Request)
 		}
+		pr := request.Clone().(motan.Request)
 		lastErrorCh = make(chan motan.Response, 1)
 		go func(postRequest motan.Request, endpoint motan.EndPoint, errorCh chan motan.Response) {
 			defer motan.HandlePanic(nil)
 			response := br.doCall(postRequest, endpoint)
-			if response != nil && (response.GetException() == nil || response.GetException().ErrType == motan.BizException) {
+			if response != nil && !motan.IsRetriable(response.GetException()) {
 				successCh <- response
 			} else {
 				errorCh <- response
@@ -104,6 +105,8 @@ func (br *BackupRequestHA) Call(request motan.Request, loadBalance motan.LoadBal
 			return resp
 		case <-lastErrorCh:
 		case <-timer.C:
+		case <-done:
+			return getErrorResponse(request.GetRequestID(), "call backup request fail: "+request.GetRPCContext(true).Err().Error())
 		}
 	}
 
@@ -114,20 +117,23 @@ func (br *BackupRequestHA) Call(request motan.Request, loadBalance motan.LoadBal
 		return resp
 	case resp = <-lastErrorCh:
 	case <-timer.C:
+	case <-done:
+		return getErrorResponse(request.GetRequestID(), "call backup request fail: "+request.GetRPCContext(true).Err().Error())
 	}
 
-	return getErrorResponse(request.GetRequestID(), fmt.Sprintf("call backup request fail: %s", "timeout"))
+	return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewClientTimeoutException("call backup request fail: timeout"))
 
 }
 
 func (br *BackupRequestHA) doCall(request motan.Request, endpoint motan.EndPoint) motan.Response {
 	response := endpoint.Call(request)
-	if response.GetException() == nil || response.GetException().ErrType == motan.BizException {
+	// the errors not retriable are returned as they are, such as the business errors
+	if !motan.IsRetriable(response.GetException()) {
 		return response
 	}
 	vlog.Warningf("BackupRequestHA call fail! url:%s, err:%+v\n", endpoint.GetURL().GetIdentity(), response.GetException())
-	return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 400, ErrMsg: fmt.Sprintf(
-		"call backup request fail.Exception:%s", response.GetException().ErrMsg), ErrType: motan.ServiceException})
+	return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewException(response.GetException().ErrCode, fmt.Sprintf(
+		"call backup request fail.Exception:%s", response.GetException().ErrMsg)))
 }
 
 func (br *BackupRequestHA) updateCallRecord(thresholdLimit int) {
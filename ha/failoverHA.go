@@ -27,23 +27,39 @@ func (f *FailOverHA) SetURL(url *motan.URL) {
 func (f *FailOverHA) Call(request motan.Request, loadBalance motan.LoadBalance) motan.Response {
 	retries := f.url.GetMethodPositiveIntValue(request.GetMethod(), request.GetMethodDesc(), "retries", defaultRetries)
 	var lastErr *motan.Exception
+	// the last response with the throttle hint and its endpoint, which should be backed off instead of retried
+	var throttled motan.Response
+	var throttledEP motan.EndPoint
 	for i := 0; i <= int(retries); i++ {
+		// never retry if the caller has given up
+		if err := request.GetRPCContext(false).Err(); err != nil {
+			return getErrorResponse(request.GetRequestID(), "FailOverHA call canceled: "+err.Error())
+		}
 		ep := loadBalance.Select(request)
+		if throttled != nil && (ep == nil || ep == throttledEP) {
+			// the other endpoints are not available, the caller gets the hint of the throttled one
+			return throttled
+		}
 		if ep == nil {
 			return getErrorResponse(request.GetRequestID(), fmt.Sprintf("No referers for request, RequestID: %d, Request info: %+v",
 				request.GetRequestID(), request.GetAttachments().RawMap()))
 		}
 		response := ep.Call(request)
-		if response.GetException() == nil || response.GetException().ErrType == motan.BizException {
+		// the errors not retriable are returned as they are, such as the business errors
+		if !motan.IsRetriable(response.GetException()) {
 			return response
 		}
 		lastErr = response.GetException()
+		if _, ok := motan.GetThrottleHint(response); ok {
+			throttled, throttledEP = response, ep
+		}
 		vlog.Warningf("FailOverHA call fail! url:%s, err:%+v\n", ep.GetURL().GetIdentity(), lastErr)
 	}
-	return getErrorResponse(request.GetRequestID(), fmt.Sprintf("FailOverHA call fail %d times.Exception:%s", retries+1, lastErr.ErrMsg))
+	return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewException(lastErr.ErrCode,
+		fmt.Sprintf("FailOverHA call fail %d times.Exception:%s", retries+1, lastErr.ErrMsg)))
 
 }
 
 func getErrorResponse(requestID uint64, errMsg string) *motan.MotanResponse {
-	return motan.BuildExceptionResponse(requestID, &motan.Exception{ErrCode: 400, ErrMsg: errMsg, ErrType: motan.ServiceException})
+	return motan.BuildExceptionResponse(requestID, motan.NewException(motan.ErrCodeBadRequest, errMsg))
 }
@@ -2,6 +2,7 @@ package ha
 
 import (
 	"testing"
+	"time"
 
 	motan "github.com/weibocom/motan-go/core"
 )
@@ -22,3 +23,65 @@ func TestGetName(t *testing.T) {
 		t.Errorf("ha call fail. res:%+v", res)
 	}
 }
+
+type errorEndPoint struct {
+	motan.TestEndPoint
+	exception *motan.Exception
+	calls     int
+}
+
+func (e *errorEndPoint) Call(request motan.Request) motan.Response {
+	e.calls++
+	return motan.BuildExceptionResponse(request.GetRequestID(), e.exception)
+}
+
+type errorLoadBalance struct {
+	motan.TestLoadBalance
+	ep motan.EndPoint
+}
+
+func (e *errorLoadBalance) Select(request motan.Request) motan.EndPoint {
+	return e.ep
+}
+
+func TestFailOverRetriable(t *testing.T) {
+	url := &motan.URL{Protocol: "motan", Path: "test/path", Parameters: map[string]string{"retries": "2"}}
+	ha := &FailOverHA{url: url}
+	request := &motan.MotanRequest{ServiceName: "test", Method: "test", Attachment: motan.NewStringMap(0)}
+
+	ep := &errorEndPoint{TestEndPoint: motan.TestEndPoint{URL: url}, exception: motan.NewClientTimeoutException("timeout")}
+	res := ha.Call(request, &errorLoadBalance{ep: ep})
+	if ep.calls != 3 || res.GetException() == nil || res.GetException().ErrCode != motan.ErrCodeClientTimeout {
+		t.Errorf("timeout should be retried. calls:%d, res:%+v", ep.calls, res.GetException())
+	}
+	ep = &errorEndPoint{TestEndPoint: motan.TestEndPoint{URL: url}, exception: motan.NewSerializationException("decode fail")}
+	res = ha.Call(request, &errorLoadBalance{ep: ep})
+	if ep.calls != 1 || res.GetException().ErrCode != motan.ErrCodeSerialization {
+		t.Errorf("serialization error should not be retried. calls:%d, res:%+v", ep.calls, res.GetException())
+	}
+}
+
+type throttledEndPoint struct {
+	errorEndPoint
+}
+
+func (e *throttledEndPoint) Call(request motan.Request) motan.Response {
+	res := e.errorEndPoint.Call(request)
+	motan.SetThrottleHint(res, motan.ThrottleHint{RetryAfter: time.Second})
+	return res
+}
+
+func TestFailOverThrottled(t *testing.T) {
+	url := &motan.URL{Protocol: "motan", Path: "test/path", Parameters: map[string]string{"retries": "2"}}
+	ha := &FailOverHA{url: url}
+	request := &motan.MotanRequest{ServiceName: "test", Method: "test", Attachment: motan.NewStringMap(0)}
+
+	ep := &throttledEndPoint{errorEndPoint{TestEndPoint: motan.TestEndPoint{URL: url}, exception: motan.NewRejectException("rate limited")}}
+	res := ha.Call(request, &errorLoadBalance{ep: ep})
+	if ep.calls != 1 || res.GetException() == nil || res.GetException().ErrCode != motan.ErrCodeReject {
+		t.Errorf("throttled endpoint should not be retried. calls:%d, res:%+v", ep.calls, res.GetException())
+	}
+	if _, ok := motan.GetThrottleHint(res); !ok {
+		t.Errorf("the throttle hint should be returned to the caller")
+	}
+}
@@ -0,0 +1,238 @@
+package motan
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/filter"
+	"github.com/weibocom/motan-go/log"
+)
+
+// the keys of motan-agent section for the health report of the endpoints
+const (
+	healthReportIntervalKey = "health_report_interval" // seconds, the endpoints are sampled and the report is pushed in the interval
+
+	defaultHealthReportInterval = 5 * time.Second
+	healthReportMaxEvents       = 100
+	healthSubscriberBuffer      = 16
+)
+
+// the types of the health events
+const (
+	healthEventEjected       = "ejected"
+	healthEventRecovered     = "recovered"
+	healthEventBreakerOpen   = "breaker_open"
+	healthEventBreakerClosed = "breaker_closed"
+)
+
+type endpointHealth struct {
+	Cluster      string  `json:"cluster"`
+	Address      string  `json:"address"`
+	Available    bool    `json:"available"`
+	Breaker      string  `json:"breaker,omitempty"`
+	Calls        int64   `json:"calls"`
+	Errors       int64   `json:"errors"`
+	QPS          float64 `json:"qps"`            // in the last interval
+	ErrorRate    float64 `json:"error_rate"`     // in the last interval
+	AvgLatencyMs float64 `json:"avg_latency_ms"` // in the last interval
+	Ejections    int     `json:"ejections"`
+	Recoveries   int     `json:"recoveries"`
+	EjectedAt    int64   `json:"ejected_at,omitempty"` // unix milliseconds, the endpoint is unavailable since then
+	RecoveredAt  int64   `json:"recovered_at,omitempty"`
+
+	latency time.Duration
+}
+
+type healthEvent struct {
+	Time    int64  `json:"time"` // unix milliseconds
+	Type    string `json:"type"`
+	Cluster string `json:"cluster"`
+	Address string `json:"address"`
+}
+
+type healthReport struct {
+	Time      int64             `json:"time"`
+	Interval  int64             `json:"interval"` // milliseconds
+	Endpoints []*endpointHealth `json:"endpoints"`
+	Events    []*healthEvent    `json:"events"` // the recent events, the latest last
+}
+
+// healthReporter samples the health of the endpoints of all the clusters in the interval, includes the availability,
+// the circuit breaker state and the error rate, and records the ejections and the recoveries of the endpoints.
+// the reports and the events are pushed to the subscribers of the admin api /v2/health/stream as server-sent events
+type healthReporter struct {
+	agent       *Agent
+	interval    time.Duration
+	lock        sync.Mutex
+	endpoints   map[string]*endpointHealth // cluster key|address -> health
+	events      []*healthEvent
+	report      *healthReport
+	subscribers map[chan []byte]struct{}
+}
+
+func newHealthReporter(a *Agent, section map[interface{}]interface{}) *healthReporter {
+	r := &healthReporter{
+		agent:       a,
+		interval:    defaultHealthReportInterval,
+		endpoints:   make(map[string]*endpointHealth),
+		events:      make([]*healthEvent, 0, healthReportMaxEvents),
+		subscribers: make(map[chan []byte]struct{}),
+	}
+	if n, ok := section[healthReportIntervalKey].(int); ok && n > 0 {
+		r.interval = time.Duration(n) * time.Second
+	}
+	r.report = &healthReport{Time: time.Now().UnixNano() / 1e6, Interval: int64(r.interval / time.Millisecond), Endpoints: []*endpointHealth{}, Events: r.events}
+	return r
+}
+
+func (r *healthReporter) start() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.sample()
+	}
+}
+
+func (r *healthReporter) sample() {
+	now := time.Now()
+	nowMs := now.UnixNano() / 1e6
+	endpoints := make(map[string]*endpointHealth)
+	events := make([]*healthEvent, 0)
+	r.lock.Lock()
+	r.agent.clustermap.Range(func(k, v interface{}) bool {
+		for _, ep := range v.(*cluster.MotanCluster).GetRefers() {
+			h := &endpointHealth{Cluster: k.(string), Address: ep.GetURL().GetAddressStr(), Available: ep.IsAvailable()}
+			h.Breaker = filter.GetCircuitBreakerState(ep.GetURL())
+			if fep, ok := ep.(*motan.FilterEndPoint); ok {
+				h.Calls, h.Errors, h.latency = fep.Stats.Calls(), fep.Stats.Errors(), fep.Stats.Latency()
+			}
+			key := h.Cluster + "|" + h.Address
+			prev := r.endpoints[key]
+			if prev == nil {
+				// the first sample, only the current state is known
+				prev = &endpointHealth{Available: true, Breaker: h.Breaker, Calls: h.Calls, Errors: h.Errors, latency: h.latency}
+			}
+			h.Ejections, h.Recoveries, h.EjectedAt, h.RecoveredAt = prev.Ejections, prev.Recoveries, prev.EjectedAt, prev.RecoveredAt
+			if prev.Available && !h.Available {
+				h.Ejections++
+				h.EjectedAt = nowMs
+				events = append(events, &healthEvent{Time: nowMs, Type: healthEventEjected, Cluster: h.Cluster, Address: h.Address})
+			} else if !prev.Available && h.Available {
+				h.Recoveries++
+				h.EjectedAt = 0
+				h.RecoveredAt = nowMs
+				events = append(events, &healthEvent{Time: nowMs, Type: healthEventRecovered, Cluster: h.Cluster, Address: h.Address})
+			}
+			if h.Breaker != prev.Breaker && h.Breaker != "" {
+				t := healthEventBreakerClosed
+				if h.Breaker == "open" {
+					t = healthEventBreakerOpen
+				}
+				events = append(events, &healthEvent{Time: nowMs, Type: t, Cluster: h.Cluster, Address: h.Address})
+			}
+			// the stats of the endpoints are reset if the endpoints are recreated
+			if calls := h.Calls - prev.Calls; calls > 0 && h.Errors >= prev.Errors {
+				h.QPS = float64(calls) / r.interval.Seconds()
+				h.ErrorRate = float64(h.Errors-prev.Errors) / float64(calls)
+				h.AvgLatencyMs = float64((h.latency-prev.latency)/time.Duration(calls)) / float64(time.Millisecond)
+			}
+			endpoints[key] = h
+		}
+		return true
+	})
+	r.endpoints = endpoints
+	r.events = append(r.events, events...)
+	if len(r.events) > healthReportMaxEvents {
+		r.events = r.events[len(r.events)-healthReportMaxEvents:]
+	}
+	report := &healthReport{Time: nowMs, Interval: int64(r.interval / time.Millisecond), Endpoints: make([]*endpointHealth, 0, len(endpoints)), Events: r.events}
+	for _, h := range endpoints {
+		report.Endpoints = append(report.Endpoints, h)
+	}
+	sort.Slice(report.Endpoints, func(i, j int) bool {
+		if report.Endpoints[i].Cluster != report.Endpoints[j].Cluster {
+			return report.Endpoints[i].Cluster < report.Endpoints[j].Cluster
+		}
+		return report.Endpoints[i].Address < report.Endpoints[j].Address
+	})
+	r.report = report
+	for _, e := range events {
+		vlog.Warningf("endpoint health changed. event:%s, cluster:%s, address:%s\n", e.Type, e.Cluster, e.Address)
+		r.publish("event", e)
+	}
+	r.publish("report", report)
+	r.lock.Unlock()
+}
+
+func (r *healthReporter) getReport() *healthReport {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.report
+}
+
+// publish sends the server-sent event to the subscribers, the event is dropped for the slow subscribers
+func (r *healthReporter) publish(event string, data interface{}) {
+	if len(r.subscribers) == 0 {
+		return
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	msg := []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, b))
+	for ch := range r.subscribers {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+func (r *healthReporter) subscribe() chan []byte {
+	ch := make(chan []byte, healthSubscriberBuffer)
+	r.lock.Lock()
+	r.subscribers[ch] = struct{}{}
+	r.lock.Unlock()
+	return ch
+}
+
+func (r *healthReporter) unsubscribe(ch chan []byte) {
+	r.lock.Lock()
+	delete(r.subscribers, ch)
+	r.lock.Unlock()
+}
+
+// serveStream pushes the reports and the events as server-sent events until the client disconnects
+func (r *healthReporter) serveStream(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		writeHandlerResponse(res, http.StatusInternalServerError, "streaming is not supported", nil)
+		return
+	}
+	ch := r.subscribe()
+	defer r.unsubscribe(ch)
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+	if b, err := json.Marshal(r.getReport()); err == nil {
+		fmt.Fprintf(res, "event: report\ndata: %s\n\n", b)
+	}
+	flusher.Flush()
+	for {
+		select {
+		case msg := <-ch:
+			if _, err := res.Write(msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
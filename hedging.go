@@ -0,0 +1,139 @@
+package motan
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+// the hedging params of the refer url, they can be set for a method like `hello().hedgingDelay: 50`
+const (
+	hedgingDelayKey       = "hedgingDelay" // milliseconds
+	hedgingMaxAttemptsKey = "hedgingMaxAttempts"
+	idempotentKey         = "idempotent"
+)
+
+// HedgingPolicy is the hedging of the sync calls of a method. another attempt is sent to the cluster if no response
+// is received in the delay or the last attempt failed, the first successful response is returned.
+// hedging is independent of the ha strategy of the cluster, the attempts are selected by the load balance
+type HedgingPolicy struct {
+	Delay       time.Duration
+	MaxAttempts int  // the extra attempts at most
+	Idempotent  bool // hedging is applied only if the method is idempotent, the provider may receive all attempts
+}
+
+func (p HedgingPolicy) enabled() bool {
+	return p.Idempotent && p.Delay > 0 && p.MaxAttempts > 0
+}
+
+// SetHedgingPolicy sets the hedging of the method, which takes precedence over the url params
+func (c *Client) SetHedgingPolicy(method string, policy HedgingPolicy) {
+	c.hedgingPolicies.Store(method, policy)
+}
+
+func (c *Client) hedgingPolicy(req motan.Request) HedgingPolicy {
+	if p, ok := c.hedgingPolicies.Load(req.GetMethod()); ok {
+		return p.(HedgingPolicy)
+	}
+	method, desc := req.GetMethod(), req.GetMethodDesc()
+	idempotent, _ := strconv.ParseBool(c.url.GetMethodParam(method, desc, idempotentKey, "false"))
+	return HedgingPolicy{
+		Delay:       time.Duration(c.url.GetMethodIntValue(method, desc, hedgingDelayKey, 0)) * time.Millisecond,
+		MaxAttempts: int(c.url.GetMethodIntValue(method, desc, hedgingMaxAttemptsKey, 0)),
+		Idempotent:  idempotent,
+	}
+}
+
+// call calls the cluster, the sync calls are hedged if the hedging policy of the method is enabled
+func (c *Client) call(req motan.Request) motan.Response {
+	rc := req.GetRPCContext(true)
+	if rc.AsyncCall || rc.Oneway || rc.StreamCall || req.GetMethod() == batchMethod {
+		return c.cluster.Call(req)
+	}
+	policy := c.hedgingPolicy(req)
+	if !policy.enabled() {
+		return c.cluster.Call(req)
+	}
+	return c.hedge(req, policy)
+}
+
+type hedgingResult struct {
+	response motan.Response
+	reply    interface{}
+}
+
+func (c *Client) hedge(req motan.Request, policy HedgingPolicy) motan.Response {
+	rc := req.GetRPCContext(true)
+	attempts := policy.MaxAttempts + 1
+	// the attempts are sent with their own replies, the reply of the returned response is copied to the caller's
+	results := make(chan hedgingResult, attempts)
+	send := func() {
+		attempt := req.Clone().(motan.Request)
+		reply := newReply(rc.Reply)
+		attempt.GetRPCContext(true).Reply = reply
+		go func() {
+			defer motan.HandlePanic(nil)
+			results <- hedgingResult{response: c.cluster.Call(attempt), reply: reply}
+		}()
+	}
+	send()
+	sent, received := 1, 0
+	timer := time.NewTimer(policy.Delay)
+	defer timer.Stop()
+	for {
+		select {
+		case r := <-results:
+			received++
+			if !motan.IsRetriable(r.response.GetException()) || (received == sent && sent == attempts) {
+				if r.response.GetException() == nil {
+					setReply(rc.Reply, r.reply)
+				}
+				return r.response
+			}
+			if received == sent {
+				// the last attempt failed, the next one is sent without waiting for the delay
+				send()
+				sent++
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(policy.Delay)
+			}
+		case <-timer.C:
+			if sent < attempts {
+				send()
+				sent++
+				timer.Reset(policy.Delay)
+			}
+		case <-rc.Done():
+			return motan.BuildExceptionResponse(req.GetRequestID(), motan.NewClientTimeoutException("hedging call fail: "+rc.Err().Error()))
+		}
+	}
+}
+
+// newReply returns a new value of the type the reply points to, or the reply itself if it is not a pointer
+func newReply(reply interface{}) interface{} {
+	if reply == nil {
+		return nil
+	}
+	t := reflect.TypeOf(reply)
+	if t.Kind() != reflect.Ptr {
+		return reply
+	}
+	return reflect.New(t.Elem()).Interface()
+}
+
+func setReply(reply interface{}, value interface{}) {
+	if reply == nil || value == nil || reply == value {
+		return
+	}
+	v := reflect.ValueOf(reply)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v.Elem().Set(reflect.ValueOf(value).Elem())
+	}
+}
@@ -0,0 +1,146 @@
+package motan
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+)
+
+// hedgingTestEndpoint calls the handler with the sequence of the attempt, the reply is the name of the attempt
+type hedgingTestEndpoint struct {
+	motan.TestEndPoint
+	attempts *int32
+	handle   func(attempt int32) (time.Duration, *motan.Exception)
+}
+
+func (e *hedgingTestEndpoint) Call(request motan.Request) motan.Response {
+	attempt := atomic.AddInt32(e.attempts, 1)
+	delay, exception := e.handle(attempt)
+	time.Sleep(delay)
+	if exception != nil {
+		return motan.BuildExceptionResponse(request.GetRequestID(), exception)
+	}
+	if reply, ok := request.GetRPCContext(true).Reply.(*string); ok {
+		*reply = "attempt " + strconv.Itoa(int(attempt))
+	}
+	return &motan.MotanResponse{RequestID: request.GetRequestID()}
+}
+
+func newHedgingTestClient(policy HedgingPolicy, handle func(attempt int32) (time.Duration, *motan.Exception)) (*Client, *int32) {
+	attempts := new(int32)
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	AddDefaultExt(ext)
+	ext.RegistExtEndpoint("hedge", func(url *motan.URL) motan.EndPoint {
+		return &hedgingTestEndpoint{TestEndPoint: motan.TestEndPoint{URL: url}, attempts: attempts, handle: handle}
+	})
+	ctx := &motan.Context{ClientURL: &motan.URL{}, RegistryURLs: map[string]*motan.URL{}}
+	url := &motan.URL{Protocol: "hedge", Path: "hedgingService", Parameters: map[string]string{motan.Lbkey: "random"}}
+	c := cluster.NewCluster(ctx, ext, url, false)
+	c.Notify(&motan.URL{Protocol: "direct"}, []*motan.URL{{Host: "127.0.0.1", Port: 8001, Protocol: "hedge", Path: "hedgingService"}})
+	client := &Client{url: url, cluster: c, extFactory: ext}
+	client.SetHedgingPolicy("hello", policy)
+	return client, attempts
+}
+
+var hedgingTestFailure = &motan.Exception{ErrCode: 503, ErrMsg: "attempt fail", ErrType: motan.ServiceException}
+
+func TestHedgingDelay(t *testing.T) {
+	client, attempts := newHedgingTestClient(HedgingPolicy{Delay: 20 * time.Millisecond, MaxAttempts: 1, Idempotent: true},
+		func(attempt int32) (time.Duration, *motan.Exception) {
+			if attempt == 1 {
+				return 300 * time.Millisecond, nil
+			}
+			return 0, nil
+		})
+	defer client.cluster.Destroy()
+	var reply string
+	start := time.Now()
+	if err := client.Call("hello", []interface{}{"ray"}, &reply); err != nil {
+		t.Fatalf("hedged call fail. err:%v", err)
+	}
+	if cost := time.Since(start); cost > 200*time.Millisecond {
+		t.Errorf("the hedged attempt should be returned without waiting for the slow one. cost:%v", cost)
+	}
+	if reply != "attempt 2" || atomic.LoadInt32(attempts) != 2 {
+		t.Errorf("reply of the hedged attempt should be returned. reply:%s, attempts:%d", reply, atomic.LoadInt32(attempts))
+	}
+	// the slow attempt finished later writes its own reply only
+	time.Sleep(350 * time.Millisecond)
+	if reply != "attempt 2" {
+		t.Errorf("reply should not be overwritten by the slow attempt. reply:%s", reply)
+	}
+}
+
+func TestHedgingFailover(t *testing.T) {
+	client, attempts := newHedgingTestClient(HedgingPolicy{Delay: time.Second, MaxAttempts: 2, Idempotent: true},
+		func(attempt int32) (time.Duration, *motan.Exception) {
+			if attempt < 3 {
+				return 10 * time.Millisecond, hedgingTestFailure
+			}
+			return 0, nil
+		})
+	defer client.cluster.Destroy()
+	var reply string
+	start := time.Now()
+	if err := client.Call("hello", []interface{}{"ray"}, &reply); err != nil {
+		t.Fatalf("hedged call fail. err:%v", err)
+	}
+	if cost := time.Since(start); cost > 500*time.Millisecond {
+		t.Errorf("the failed attempts should be retried without waiting for the delay. cost:%v", cost)
+	}
+	if reply != "attempt 3" || atomic.LoadInt32(attempts) != 3 {
+		t.Errorf("reply of the successful attempt should be returned. reply:%s, attempts:%d", reply, atomic.LoadInt32(attempts))
+	}
+}
+
+func TestHedgingMaxAttempts(t *testing.T) {
+	client, attempts := newHedgingTestClient(HedgingPolicy{Delay: 10 * time.Millisecond, MaxAttempts: 2, Idempotent: true},
+		func(attempt int32) (time.Duration, *motan.Exception) {
+			return 30 * time.Millisecond, hedgingTestFailure
+		})
+	defer client.cluster.Destroy()
+	var reply string
+	if err := client.Call("hello", []interface{}{"ray"}, &reply); err == nil {
+		t.Errorf("call should fail if all attempts failed")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(attempts); n != 3 || reply != "" {
+		t.Errorf("the attempts should not exceed the max attempts. attempts:%d, reply:%s", n, reply)
+	}
+
+	// the methods not idempotent are never hedged
+	client.SetHedgingPolicy("hello", HedgingPolicy{Delay: 10 * time.Millisecond, MaxAttempts: 2})
+	atomic.StoreInt32(attempts, 0)
+	client.Call("hello", []interface{}{"ray"}, &reply)
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(attempts); n != 1 {
+		t.Errorf("call not idempotent should not be hedged. attempts:%d", n)
+	}
+}
+
+func TestHedgingContextCanceled(t *testing.T) {
+	client, _ := newHedgingTestClient(HedgingPolicy{Delay: 20 * time.Millisecond, MaxAttempts: 2, Idempotent: true},
+		func(attempt int32) (time.Duration, *motan.Exception) {
+			return time.Second, nil
+		})
+	defer client.cluster.Destroy()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var reply string
+	start := time.Now()
+	if err := client.CallContext(ctx, "hello", []interface{}{"ray"}, &reply); err == nil {
+		t.Errorf("call should fail when the context is done")
+	}
+	if cost := time.Since(start); cost > 500*time.Millisecond {
+		t.Errorf("call should return when the context is done. cost:%v", cost)
+	}
+	if reply != "" {
+		t.Errorf("reply should not be set by the canceled call. reply:%s", reply)
+	}
+}
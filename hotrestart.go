@@ -0,0 +1,90 @@
+package motan
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mserver "github.com/weibocom/motan-go/server"
+	"github.com/weibocom/motan-go/transport"
+)
+
+var (
+	// HotRestartWait is the time the new process must keep running before this process shuts down
+	HotRestartWait = 3 * time.Second
+	// HotRestartDrainTimeout is the max time to wait for the requests being processed when shutting down
+	HotRestartDrainTimeout = 30 * time.Second
+
+	restarting int32
+)
+
+// hotRestart starts a new process with the listeners of this process, so the connections are accepted without
+// interruption. this process shuts the servers down gracefully and exits after the new process keeps running for
+// HotRestartWait, the restart is aborted if the new process exits before that
+func hotRestart(servers func() []motan.Server) (int, error) {
+	if !atomic.CompareAndSwapInt32(&restarting, 0, 1) {
+		return 0, errors.New("hot restart is in progress")
+	}
+	p, err := transport.StartInheritProcess()
+	if err != nil {
+		atomic.StoreInt32(&restarting, 0)
+		return 0, err
+	}
+	vlog.Infof("hot restart: new process %d started\n", p.Pid)
+	exited := make(chan error, 1)
+	go func() {
+		_, err := p.Wait()
+		exited <- err
+	}()
+	select {
+	case err = <-exited:
+		atomic.StoreInt32(&restarting, 0)
+		vlog.Errorf("hot restart: new process %d exited, err:%v\n", p.Pid, err)
+		return 0, errors.New("new process " + strconv.Itoa(p.Pid) + " exited")
+	case <-time.After(HotRestartWait):
+	}
+	go func() {
+		for _, s := range servers() {
+			if gs, ok := s.(mserver.GracefulServer); ok {
+				if err := gs.Shutdown(HotRestartDrainTimeout); err != nil {
+					vlog.Warningf("hot restart: shutdown server fail. port:%d, err:%v\n", s.GetURL().Port, err)
+				}
+			} else {
+				s.Destroy()
+			}
+		}
+		vlog.Infof("hot restart: process %d exit\n", os.Getpid())
+		vlog.Flush()
+		os.Exit(0)
+	}()
+	return p.Pid, nil
+}
+
+// HotRestartHandler restarts the agent without downtime by the new process inheriting the listeners
+type HotRestartHandler struct {
+	a *Agent
+}
+
+func (h *HotRestartHandler) SetAgent(agent *Agent) {
+	h.a = agent
+}
+
+func (h *HotRestartHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("hot restart needs POST"))
+		return
+	}
+	pid, err := h.a.HotRestart()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("hot restart fail. err:" + err.Error()))
+		return
+	}
+	w.Write([]byte("ok. new process:" + strconv.Itoa(pid)))
+}
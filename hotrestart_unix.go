@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package motan
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/weibocom/motan-go/log"
+)
+
+var hotRestartSignalOnce sync.Once
+
+// watchHotRestartSignal restarts the process by SIGUSR2
+func watchHotRestartSignal(restart func() (int, error)) {
+	hotRestartSignalOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGUSR2)
+		go func() {
+			for range ch {
+				if pid, err := restart(); err != nil {
+					vlog.Errorf("hot restart by signal fail. err:%v\n", err)
+				} else {
+					vlog.Infof("hot restart by signal, new process:%d\n", pid)
+				}
+			}
+		}()
+	})
+}
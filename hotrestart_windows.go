@@ -0,0 +1,5 @@
+package motan
+
+// watchHotRestartSignal does nothing, there is no SIGUSR2 on windows
+func watchHotRestartSignal(restart func() (int, error)) {
+}
@@ -39,24 +39,38 @@ func RegistDefaultLb(extFactory motan.ExtensionFactory) {
 type WeightedLbWraper struct {
 	url          *motan.URL
 	weightstring string
-	refers       innerRefers
+	refers       atomic.Value // refersHolder, replaced by OnRefresh while selecting
 	newLb        motan.NewLbFunc
 }
 
+type refersHolder struct {
+	refers innerRefers
+}
+
 func NewWeightLbFunc(newLb motan.NewLbFunc) motan.NewLbFunc {
 	return func(url *motan.URL) motan.LoadBalance {
-		return &WeightedLbWraper{url: url, newLb: newLb, refers: &singleGroupRefers{lb: newLb(url)}}
+		w := &WeightedLbWraper{url: url, newLb: newLb}
+		w.setRefers(&singleGroupRefers{lb: newLb(url)})
+		return w
 	}
 }
 
+func (w *WeightedLbWraper) getRefers() innerRefers {
+	return w.refers.Load().(refersHolder).refers
+}
+
+func (w *WeightedLbWraper) setRefers(refers innerRefers) {
+	w.refers.Store(refersHolder{refers: refers})
+}
+
 func (w *WeightedLbWraper) OnRefresh(endpoints []motan.EndPoint) {
 	if w.weightstring == "" { //not weighted lb
-		if sgr, ok := w.refers.(*singleGroupRefers); ok {
+		if sgr, ok := w.getRefers().(*singleGroupRefers); ok {
 			sgr.lb.OnRefresh(endpoints)
 		} else {
 			lb := w.newLb(w.url)
 			lb.OnRefresh(endpoints)
-			w.refers = &singleGroupRefers{lb: lb}
+			w.setRefers(&singleGroupRefers{lb: lb})
 		}
 		return
 	}
@@ -117,15 +131,15 @@ func (w *WeightedLbWraper) OnRefresh(endpoints []motan.EndPoint) {
 	}
 	wr.weightRing = motan.SliceShuffle(ring)
 	wr.ringSize = len(wr.weightRing)
-	w.refers = wr
+	w.setRefers(wr)
 }
 
 func (w *WeightedLbWraper) Select(request motan.Request) motan.EndPoint {
-	return w.refers.selectNext(request)
+	return w.getRefers().selectNext(request)
 }
 
 func (w *WeightedLbWraper) SelectArray(request motan.Request) []motan.EndPoint {
-	return w.refers.selectNextArray(request)
+	return w.getRefers().selectNextArray(request)
 }
 
 func (w *WeightedLbWraper) SetWeight(weight string) {
@@ -31,7 +31,7 @@ func TestWeightedLbWraper(t *testing.T) {
 		}
 	}
 	wlbw.OnRefresh(endpoints)
-	refers, ok := wlbw.refers.(*weightedRefers)
+	refers, ok := wlbw.getRefers().(*weightedRefers)
 	if !ok {
 		t.Errorf("refers type not weightedRefers, lb: %v\n", lb)
 	}
@@ -43,7 +43,7 @@ func TestWeightedLbWraper(t *testing.T) {
 	}
 	for k, v := range refers.groupLb {
 		lb := v.(*RoundrobinLB)
-		if len(lb.endpoints) != 5 {
+		if len(lb.getEndpoints()) != 5 {
 			t.Errorf("lb endpoint size not correct. group:%s, lb: %+v\n", k, v)
 		}
 	}
@@ -75,7 +75,7 @@ func TestWeightedLbWraper(t *testing.T) {
 	//test no weight
 	wlbw.SetWeight("")
 	wlbw.OnRefresh(endpoints)
-	_, ok = wlbw.refers.(*singleGroupRefers)
+	_, ok = wlbw.getRefers().(*singleGroupRefers)
 	if !ok {
 		t.Errorf("refers type not singleGroupRefers, lb: %v\n", lb)
 	}
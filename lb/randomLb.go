@@ -1,25 +1,32 @@
 package lb
 
 import (
+	"sync/atomic"
+
 	motan "github.com/weibocom/motan-go/core"
 )
 
 type RandomLB struct {
 	url       *motan.URL
-	endpoints []motan.EndPoint
+	endpoints atomic.Value // []motan.EndPoint, replaced by OnRefresh while selecting
 	weight    string
 }
 
 func (r *RandomLB) OnRefresh(endpoints []motan.EndPoint) {
-	r.endpoints = endpoints
+	r.endpoints.Store(endpoints)
+}
+
+func (r *RandomLB) getEndpoints() []motan.EndPoint {
+	eps, _ := r.endpoints.Load().([]motan.EndPoint)
+	return eps
 }
 func (r *RandomLB) Select(request motan.Request) motan.EndPoint {
-	eps := r.endpoints
+	eps := r.getEndpoints()
 	_, endpoint := SelectOneAtRandom(eps)
 	return endpoint
 }
 func (r *RandomLB) SelectArray(request motan.Request) []motan.EndPoint {
-	eps := r.endpoints
+	eps := r.getEndpoints()
 	index, endpoint := SelectOneAtRandom(eps)
 	if endpoint == nil {
 		return nil
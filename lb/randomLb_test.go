@@ -15,7 +15,8 @@ func TestRandomLB(t *testing.T) {
 			endpoints = append(endpoints, lbTestMockEndpoint{index: i, isAvail: true})
 		}
 	}
-	randomLb := &RandomLB{endpoints: endpoints}
+	randomLb := &RandomLB{}
+	randomLb.OnRefresh(endpoints)
 	for i := 0; i < 30; i++ {
 		ep := randomLb.Select(nil)
 		if !ep.IsAvailable() || ep.(lbTestMockEndpoint).index%2 == 0 {
@@ -8,23 +8,28 @@ import (
 
 type RoundrobinLB struct {
 	url       *motan.URL
-	endpoints []motan.EndPoint
+	endpoints atomic.Value // []motan.EndPoint, replaced by OnRefresh while selecting
 	index     uint32
 	weight    string
 }
 
 func (r *RoundrobinLB) OnRefresh(endpoints []motan.EndPoint) {
-	r.endpoints = endpoints
+	r.endpoints.Store(endpoints)
+}
+
+func (r *RoundrobinLB) getEndpoints() []motan.EndPoint {
+	eps, _ := r.endpoints.Load().([]motan.EndPoint)
+	return eps
 }
 
 func (r *RoundrobinLB) Select(request motan.Request) motan.EndPoint {
-	eps := r.endpoints
+	eps := r.getEndpoints()
 	_, endpoint := r.roundrobinSelect(eps)
 	return endpoint
 }
 
 func (r *RoundrobinLB) SelectArray(request motan.Request) []motan.EndPoint {
-	eps := r.endpoints
+	eps := r.getEndpoints()
 	index, endpoint := r.roundrobinSelect(eps)
 	if endpoint == nil {
 		return nil
@@ -15,7 +15,8 @@ func TestRoundrobinLB(t *testing.T) {
 			endpoints = append(endpoints, lbTestMockEndpoint{index: i, isAvail: true})
 		}
 	}
-	roundrobinLb := &RoundrobinLB{endpoints: endpoints}
+	roundrobinLb := &RoundrobinLB{}
+	roundrobinLb.OnRefresh(endpoints)
 	for i := 0; i < 30; i++ {
 		ep := roundrobinLb.Select(nil)
 		if !ep.IsAvailable() || (ep.(lbTestMockEndpoint).index > 2 && ep.(lbTestMockEndpoint).index < 6) {
@@ -0,0 +1,134 @@
+package motan
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/metrics"
+)
+
+// the rolling windows of the live stats, in seconds
+var liveStatsWindows = []int{1, 10, 60}
+
+type liveStatsWindow struct {
+	QPS          float64 `json:"qps"`
+	ErrorRate    float64 `json:"error_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	P50Ms        float64 `json:"p50_ms"`
+	P90Ms        float64 `json:"p90_ms"`
+	P99Ms        float64 `json:"p99_ms"`
+}
+
+type liveStatsEndpoint struct {
+	Address string                      `json:"address"`
+	Windows map[string]*liveStatsWindow `json:"windows"`
+}
+
+type liveStatsService struct {
+	Cluster   string                      `json:"cluster"`
+	Service   string                      `json:"service"`
+	Group     string                      `json:"group"`
+	Windows   map[string]*liveStatsWindow `json:"windows"`
+	Endpoints []*liveStatsEndpoint        `json:"endpoints"`
+}
+
+// liveStats reports the qps, the error rate and the latency percentiles of the last 1s, 10s and 60s per cluster and
+// per endpoint, from the rolling stats of the endpoints. all the clusters are reported if the key is empty
+func (a *Agent) liveStats(key string) []*liveStatsService {
+	services := make([]*liveStatsService, 0)
+	a.clustermap.Range(func(k, v interface{}) bool {
+		if key != "" && k.(string) != key {
+			return true
+		}
+		c := v.(*cluster.MotanCluster)
+		s := &liveStatsService{Cluster: k.(string), Service: c.GetURL().Path, Group: c.GetURL().Group, Endpoints: make([]*liveStatsEndpoint, 0)}
+		merged := make([]*motan.RollingWindow, len(liveStatsWindows))
+		for i, seconds := range liveStatsWindows {
+			merged[i] = &motan.RollingWindow{Seconds: seconds}
+		}
+		for _, ep := range c.GetRefers() {
+			fep, ok := ep.(*motan.FilterEndPoint)
+			if !ok {
+				continue
+			}
+			e := &liveStatsEndpoint{Address: ep.GetURL().GetAddressStr(), Windows: make(map[string]*liveStatsWindow, len(liveStatsWindows))}
+			for i, seconds := range liveStatsWindows {
+				w := fep.Stats.Rolling().Window(seconds)
+				merged[i].Merge(w)
+				e.Windows[liveStatsWindowName(seconds)] = newLiveStatsWindow(w)
+			}
+			s.Endpoints = append(s.Endpoints, e)
+		}
+		s.Windows = make(map[string]*liveStatsWindow, len(liveStatsWindows))
+		for i, seconds := range liveStatsWindows {
+			s.Windows[liveStatsWindowName(seconds)] = newLiveStatsWindow(merged[i])
+		}
+		sort.Slice(s.Endpoints, func(i, j int) bool { return s.Endpoints[i].Address < s.Endpoints[j].Address })
+		services = append(services, s)
+		return true
+	})
+	sort.Slice(services, func(i, j int) bool { return services[i].Cluster < services[j].Cluster })
+	return services
+}
+
+func liveStatsWindowName(seconds int) string {
+	return strconv.Itoa(seconds) + "s"
+}
+
+func newLiveStatsWindow(w *motan.RollingWindow) *liveStatsWindow {
+	return &liveStatsWindow{
+		QPS:          w.QPS(),
+		ErrorRate:    w.ErrorRate(),
+		AvgLatencyMs: durationMs(w.AvgLatency()),
+		P50Ms:        durationMs(w.Percentile(50)),
+		P90Ms:        durationMs(w.Percentile(90)),
+		P99Ms:        durationMs(w.Percentile(99)),
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// registerMetricsGauges exports the endpoint health of the clusters to the /metrics of the manage port
+func (a *Agent) registerMetricsGauges() {
+	metrics.RegisterGauge("motan_endpoint_available", "1 if the endpoint of the cluster is available, else 0", func() []metrics.GaugeValue {
+		var values []metrics.GaugeValue
+		a.clustermap.Range(func(k, v interface{}) bool {
+			c := v.(*cluster.MotanCluster)
+			for _, ep := range c.GetRefers() {
+				available := 0.0
+				if ep.IsAvailable() {
+					available = 1
+				}
+				values = append(values, metrics.GaugeValue{
+					Labels: map[string]string{"service": c.GetURL().Path, "group": c.GetURL().Group, "endpoint": ep.GetURL().GetAddressStr()},
+					Value:  available,
+				})
+			}
+			return true
+		})
+		return values
+	})
+	metrics.RegisterGauge("motan_cluster_available_endpoints", "the available endpoints of the cluster", func() []metrics.GaugeValue {
+		var values []metrics.GaugeValue
+		a.clustermap.Range(func(k, v interface{}) bool {
+			c := v.(*cluster.MotanCluster)
+			available := 0
+			for _, ep := range c.GetRefers() {
+				if ep.IsAvailable() {
+					available++
+				}
+			}
+			values = append(values, metrics.GaugeValue{
+				Labels: map[string]string{"service": c.GetURL().Path, "group": c.GetURL().Group},
+				Value:  float64(available),
+			})
+			return true
+		})
+		return values
+	})
+}
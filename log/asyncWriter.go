@@ -0,0 +1,334 @@
+package vlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	goLog "log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the time rotations of AsyncWriterConfig.Rotate
+const (
+	RotateHourly = "hour"
+	RotateDaily  = "day"
+)
+
+const (
+	defaultAsyncQueueSize     = 10000
+	defaultAsyncFlushInterval = time.Second
+	asyncBufferSize           = 256 * 1024
+	rotatedTimeFormat         = "20060102-150405"
+)
+
+var (
+	asyncWriters sync.Map // name -> *AsyncWriter
+
+	accessLogWriter atomic.Value // writerHolder
+)
+
+// AsyncWriterConfig is the file and the rotation of an AsyncWriter
+type AsyncWriterConfig struct {
+	File          string
+	MaxSize       int64  // bytes, the file is rotated if it will exceed the size, no limit if <= 0
+	Rotate        string // RotateHourly or RotateDaily, not rotated by time if empty
+	MaxBackups    int    // the rotated files kept, all kept if <= 0
+	Compress      bool   // the rotated files are compressed by gzip
+	QueueSize     int    // the lines waiting to be written, the oldest lines are dropped if the queue is full
+	FlushInterval time.Duration
+}
+
+// AsyncWriterStats is the counters of an AsyncWriter since created
+type AsyncWriterStats struct {
+	Written   int64 // lines
+	Dropped   int64 // lines
+	Rotations int64
+}
+
+// AsyncWriter writes the lines such as the access logs to the file in background, Write never blocks the callers.
+// the file is rotated by the size and the time, the rotated files are named by the rotation time like
+// access.log.20060102-150405 and compressed to access.log.20060102-150405.gz if configured
+type AsyncWriter struct {
+	name   string
+	conf   AsyncWriterConfig
+	queue  chan []byte
+	closed chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	backupLock sync.Mutex // the rotated files are compressed and removed one by one
+
+	file       *os.File
+	buf        *bufio.Writer
+	size       int64
+	nextRotate time.Time
+
+	written   int64
+	dropped   int64
+	rotations int64
+}
+
+// NewAsyncWriter opens the file and starts writing in background, the writer can be got by the name
+// in RangeAsyncWriters until closed
+func NewAsyncWriter(name string, conf AsyncWriterConfig) (*AsyncWriter, error) {
+	if conf.File == "" {
+		return nil, errors.New("log file of async writer " + name + " is empty")
+	}
+	if conf.Rotate != "" && conf.Rotate != RotateHourly && conf.Rotate != RotateDaily {
+		return nil, errors.New("unknown log rotation: " + conf.Rotate)
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = defaultAsyncQueueSize
+	}
+	if conf.FlushInterval <= 0 {
+		conf.FlushInterval = defaultAsyncFlushInterval
+	}
+	w := &AsyncWriter{
+		name:   name,
+		conf:   conf,
+		queue:  make(chan []byte, conf.QueueSize),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := w.open(time.Now()); err != nil {
+		return nil, err
+	}
+	go w.run()
+	asyncWriters.Store(name, w)
+	return w, nil
+}
+
+// RangeAsyncWriters calls f for each AsyncWriter not closed
+func RangeAsyncWriters(f func(name string, w *AsyncWriter) bool) {
+	asyncWriters.Range(func(k, v interface{}) bool {
+		return f(k.(string), v.(*AsyncWriter))
+	})
+}
+
+// Write queues a copy of p, the oldest line is dropped if the queue is full
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.closed:
+		return 0, errors.New("async writer " + w.name + " is closed")
+	default:
+	}
+	line := make([]byte, len(p))
+	copy(line, p)
+	for i := 0; i < 2; i++ {
+		select {
+		case w.queue <- line:
+			return len(p), nil
+		default:
+		}
+		select {
+		case <-w.queue:
+			atomic.AddInt64(&w.dropped, 1)
+		default:
+		}
+	}
+	atomic.AddInt64(&w.dropped, 1)
+	return len(p), nil
+}
+
+// Close writes the lines queued and closes the file
+func (w *AsyncWriter) Close() error {
+	w.once.Do(func() {
+		asyncWriters.Delete(w.name)
+		close(w.closed)
+	})
+	<-w.done
+	return nil
+}
+
+func (w *AsyncWriter) Stats() AsyncWriterStats {
+	return AsyncWriterStats{
+		Written:   atomic.LoadInt64(&w.written),
+		Dropped:   atomic.LoadInt64(&w.dropped),
+		Rotations: atomic.LoadInt64(&w.rotations),
+	}
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.conf.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line := <-w.queue:
+			w.write(line)
+		case <-ticker.C:
+			w.buf.Flush()
+		case <-w.closed:
+			for {
+				select {
+				case line := <-w.queue:
+					w.write(line)
+				default:
+					w.buf.Flush()
+					w.file.Close()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *AsyncWriter) write(line []byte) {
+	now := time.Now()
+	if (!w.nextRotate.IsZero() && !now.Before(w.nextRotate)) ||
+		(w.conf.MaxSize > 0 && w.size > 0 && w.size+int64(len(line)) > w.conf.MaxSize) {
+		if err := w.rotate(now); err != nil {
+			fmt.Fprintf(os.Stderr, "vlog: rotate %s fail: %v\n", w.conf.File, err)
+		}
+	}
+	n, err := w.buf.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vlog: write %s fail: %v\n", w.conf.File, err)
+		return
+	}
+	atomic.AddInt64(&w.written, 1)
+}
+
+func (w *AsyncWriter) open(now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(w.conf.File), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.conf.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size = f, info.Size()
+	if w.buf == nil {
+		w.buf = bufio.NewWriterSize(f, asyncBufferSize)
+	} else {
+		w.buf.Reset(f)
+	}
+	switch w.conf.Rotate {
+	case RotateHourly:
+		w.nextRotate = now.Truncate(time.Hour).Add(time.Hour)
+	case RotateDaily:
+		y, m, d := now.Date()
+		w.nextRotate = time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+	}
+	return nil
+}
+
+// rotate renames the file by the time and opens a new one, the rotated file is compressed and the old backups
+// are removed in background
+func (w *AsyncWriter) rotate(now time.Time) error {
+	w.buf.Flush()
+	w.file.Close()
+	rotated := w.conf.File + "." + now.Format(rotatedTimeFormat)
+	for i := 1; fileExists(rotated) || fileExists(rotated+".gz"); i++ {
+		rotated = fmt.Sprintf("%s.%s.%d", w.conf.File, now.Format(rotatedTimeFormat), i)
+	}
+	renameErr := os.Rename(w.conf.File, rotated)
+	if err := w.open(now); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	atomic.AddInt64(&w.rotations, 1)
+	go func() {
+		w.backupLock.Lock()
+		defer w.backupLock.Unlock()
+		if w.conf.Compress {
+			if err := compressFile(rotated); err != nil {
+				fmt.Fprintf(os.Stderr, "vlog: compress %s fail: %v\n", rotated, err)
+			}
+		}
+		w.removeBackups()
+	}()
+	return nil
+}
+
+// removeBackups removes the oldest rotated files more than MaxBackups
+func (w *AsyncWriter) removeBackups() {
+	if w.conf.MaxBackups <= 0 {
+		return
+	}
+	backups, _ := filepath.Glob(w.conf.File + ".[0-9]*")
+	// the files rotated in the same second are suffixed by the sequence like access.log.20060102-150405.1
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i], ".gz") < strings.TrimSuffix(backups[j], ".gz")
+	})
+	for i := 0; i < len(backups)-w.conf.MaxBackups; i++ {
+		os.Remove(backups[i])
+	}
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(name + ".gz")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+type writerHolder struct {
+	w io.Writer
+}
+
+// SetAccessLogWriter sets the writer of the access logs such as an AsyncWriter, the access logs are written
+// as the info logs if not set
+func SetAccessLogWriter(w io.Writer) {
+	accessLogWriter.Store(writerHolder{w: w})
+}
+
+// AccessLogf writes the access log line with the time to the access log writer, or the info log if not set
+func AccessLogf(format string, args ...interface{}) {
+	if !enabled(infoLog) {
+		return
+	}
+	if h, ok := accessLogWriter.Load().(writerHolder); ok && h.w != nil {
+		b := time.Now().AppendFormat(make([]byte, 0, 256), "2006-01-02 15:04:05.000 ")
+		b = fmt.Appendf(b, format, args...)
+		if len(b) == 0 || b[len(b)-1] != '\n' {
+			b = append(b, '\n')
+		}
+		h.w.Write(b)
+		return
+	}
+	if l := getLogger(); l != nil {
+		l.Infof(format, args...)
+	} else {
+		goLog.Printf(format, args...)
+	}
+}
@@ -0,0 +1,86 @@
+package vlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncWriterDropOldest(t *testing.T) {
+	// not started, the lines are kept in the queue
+	w := &AsyncWriter{name: "test", queue: make(chan []byte, 2), closed: make(chan struct{})}
+	for _, line := range []string{"1\n", "2\n", "3\n"} {
+		n, err := w.Write([]byte(line))
+		assert.Nil(t, err)
+		assert.Equal(t, len(line), n)
+	}
+	assert.Equal(t, int64(1), w.Stats().Dropped)
+	assert.Equal(t, "2\n", string(<-w.queue))
+	assert.Equal(t, "3\n", string(<-w.queue))
+}
+
+func TestAsyncWriterRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asyncwriter")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "access.log")
+
+	_, err = NewAsyncWriter("test", AsyncWriterConfig{File: file, Rotate: "minute"})
+	assert.NotNil(t, err)
+	w, err := NewAsyncWriter("test", AsyncWriterConfig{File: file, MaxSize: 10, MaxBackups: 2, Compress: true})
+	assert.Nil(t, err)
+	found := false
+	RangeAsyncWriters(func(name string, aw *AsyncWriter) bool {
+		found = found || aw == w
+		return true
+	})
+	assert.True(t, found)
+	for _, line := range []string{"line-1\n", "line-2\n", "line-3\n", "line-4\n"} {
+		w.Write([]byte(line))
+	}
+	assert.Nil(t, w.Close())
+	_, err = w.Write([]byte("closed\n"))
+	assert.NotNil(t, err)
+	stats := w.Stats()
+	assert.Equal(t, int64(4), stats.Written)
+	assert.Equal(t, int64(3), stats.Rotations)
+
+	content, _ := ioutil.ReadFile(file)
+	assert.Equal(t, "line-4\n", string(content))
+	var backups []string
+	for i := 0; i < 100; i++ {
+		backups, _ = filepath.Glob(file + ".*.gz")
+		if len(backups) == 2 {
+			if all, _ := filepath.Glob(file + ".*"); len(all) == 2 {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, 2, len(backups), "the oldest backup should be removed")
+	f, err := os.Open(backups[len(backups)-1])
+	assert.Nil(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	content, _ = ioutil.ReadAll(zr)
+	assert.Equal(t, "line-3\n", string(content))
+}
+
+func TestAccessLogf(t *testing.T) {
+	var buf bytes.Buffer
+	SetAccessLogWriter(&buf)
+	defer SetAccessLogWriter(nil)
+	AccessLogf("access log--%s,%d", "server", 1)
+	line := buf.String()
+	assert.True(t, strings.HasSuffix(line, " access log--server,1\n"), line)
+	_, err := time.Parse("2006-01-02 15:04:05.000", line[:23])
+	assert.Nil(t, err)
+}
@@ -0,0 +1,144 @@
+package vlog
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+type fieldKind uint8
+
+const (
+	anyField fieldKind = iota
+	stringField
+	intField
+	uintField
+	floatField
+	boolField
+	durationField
+	errorField
+)
+
+// Field is a key/value of the structured logs, the values of the basic types are kept without boxing
+type Field struct {
+	Key  string
+	kind fieldKind
+	num  int64
+	str  string
+	val  interface{}
+}
+
+func String(key string, value string) Field {
+	return Field{Key: key, kind: stringField, str: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, kind: intField, num: int64(value)}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: intField, num: value}
+}
+
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, kind: uintField, num: int64(value)}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: floatField, num: int64(math.Float64bits(value))}
+}
+
+func Bool(key string, value bool) Field {
+	f := Field{Key: key, kind: boolField}
+	if value {
+		f.num = 1
+	}
+	return f
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: durationField, num: int64(value)}
+}
+
+// Err is the field of the error named error, the value is nil if the error is nil
+func Err(err error) Field {
+	return Field{Key: "error", kind: errorField, val: err}
+}
+
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, kind: anyField, val: value}
+}
+
+// Value returns the value of the field in its type, the durations are time.Duration and the errors are error
+func (f Field) Value() interface{} {
+	switch f.kind {
+	case stringField:
+		return f.str
+	case intField:
+		return f.num
+	case uintField:
+		return uint64(f.num)
+	case floatField:
+		return math.Float64frombits(uint64(f.num))
+	case boolField:
+		return f.num == 1
+	case durationField:
+		return time.Duration(f.num)
+	}
+	return f.val
+}
+
+// StructuredLogger is implemented by the loggers with the key/value fields, such as the adapters of zap and zerolog.
+// the logs of Infow, Warningw and Errorw are formatted as the text of the message and the fields
+// like `msg key=value` if the Logger of LogInit is not a StructuredLogger
+type StructuredLogger interface {
+	Infow(msg string, fields ...Field)
+	Warningw(msg string, fields ...Field)
+	Errorw(msg string, fields ...Field)
+}
+
+// appendFields appends the message and the fields as `msg key=value`, the values with spaces or quotes are quoted
+func appendFields(b []byte, msg string, fields []Field) []byte {
+	b = append(b, msg...)
+	for _, f := range fields {
+		b = append(b, ' ')
+		b = append(b, f.Key...)
+		b = append(b, '=')
+		switch f.kind {
+		case stringField:
+			b = appendText(b, f.str)
+		case intField:
+			b = strconv.AppendInt(b, f.num, 10)
+		case uintField:
+			b = strconv.AppendUint(b, uint64(f.num), 10)
+		case floatField:
+			b = strconv.AppendFloat(b, math.Float64frombits(uint64(f.num)), 'g', -1, 64)
+		case boolField:
+			b = strconv.AppendBool(b, f.num == 1)
+		case durationField:
+			b = append(b, time.Duration(f.num).String()...)
+		case errorField:
+			if f.val == nil {
+				b = append(b, "<nil>"...)
+			} else {
+				b = appendText(b, f.val.(error).Error())
+			}
+		default:
+			b = appendText(b, fmt.Sprint(f.val))
+		}
+	}
+	return b
+}
+
+func appendText(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '"' || c == '=' || c >= 0x7f {
+			return strconv.AppendQuote(b, s)
+		}
+	}
+	if s == "" {
+		return append(b, `""`...)
+	}
+	return append(b, s...)
+}
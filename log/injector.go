@@ -3,11 +3,29 @@ package vlog
 import (
 	"flag"
 	"sync"
+	"sync/atomic"
 )
 
-var log Logger
+var log atomic.Value // loggerHolder, the logger may be replaced while logging
 var once sync.Once
 
+// loggerHolder holds the logger in atomic.Value, which stores the values of the same type only
+type loggerHolder struct {
+	logger Logger
+}
+
+// getLogger returns the logger set by LogInit, nil if not set
+func getLogger() Logger {
+	if h, ok := log.Load().(loggerHolder); ok {
+		return h.logger
+	}
+	return nil
+}
+
+func setLogger(logger Logger) {
+	log.Store(loggerHolder{logger: logger})
+}
+
 type Logger interface {
 	Infoln(...interface{})
 	Infof(string, ...interface{})
@@ -35,9 +53,9 @@ func LogInit(logger Logger) {
 		logging.setVState(0, nil, false)
 		if logger == nil {
 			go logging.flushDaemon()
-			log = &Log{Instance: &logging}
+			setLogger(&Log{Instance: &logging})
 		} else {
-			log = logger
+			setLogger(logger)
 		}
 	})
 }
@@ -80,6 +98,18 @@ func (l Log) Fatalf(format string, args ...interface{}) {
 	logging.printf(fatalLog, format, args...)
 }
 
+func (l Log) Infow(msg string, fields ...Field) {
+	logging.printw(infoLog, msg, fields)
+}
+
+func (l Log) Warningw(msg string, fields ...Field) {
+	logging.printw(warningLog, msg, fields)
+}
+
+func (l Log) Errorw(msg string, fields ...Field) {
+	logging.printw(errorLog, msg, fields)
+}
+
 func (l Log) Flush() {
 	logging.lockAndFlushAll()
 }
@@ -1,73 +1,165 @@
 package vlog
 
-import goLog "log"
+import (
+	"errors"
+	goLog "log"
+)
+
+// the logs below the level are dropped, the fatal logs are always written
+var minLevel = infoLog
+
+// SetLevel sets the minimal level of the logs by name: INFO, WARNING, ERROR or FATAL
+func SetLevel(name string) error {
+	s, ok := severityByName(name)
+	if !ok {
+		return errors.New("unknown log level: " + name)
+	}
+	scopeLock.Lock()
+	defer scopeLock.Unlock()
+	if levelTimer != nil {
+		levelTimer.Stop()
+	}
+	minLevel.set(s)
+	return nil
+}
+
+// GetLevel returns the name of the minimal level of the logs
+func GetLevel() string {
+	return severityName[minLevel.get()]
+}
+
+// enabled is called by the log functions, the level of the package of their callers is used if set
+func enabled(s severity) bool {
+	return s >= callerLevel(2)
+}
 
 func Infoln(args ...interface{}) {
-	if log != nil {
-		log.Infoln(args...)
+	if !enabled(infoLog) {
+		return
+	}
+	if l := getLogger(); l != nil {
+		l.Infoln(args...)
 	} else {
 		goLog.Println(args...)
 	}
 }
 
 func Infof(format string, args ...interface{}) {
-	if log != nil {
-		log.Infof(format, args...)
+	if !enabled(infoLog) {
+		return
+	}
+	if l := getLogger(); l != nil {
+		l.Infof(format, args...)
 	} else {
 		goLog.Printf(format, args...)
 	}
 }
 
 func Warningln(args ...interface{}) {
-	if log != nil {
-		log.Warningln(args...)
+	if !enabled(warningLog) {
+		return
+	}
+	if l := getLogger(); l != nil {
+		l.Warningln(args...)
 	} else {
 		goLog.Println(args...)
 	}
 }
 
 func Warningf(format string, args ...interface{}) {
-	if log != nil {
-		log.Warningf(format, args...)
+	if !enabled(warningLog) {
+		return
+	}
+	if l := getLogger(); l != nil {
+		l.Warningf(format, args...)
 	} else {
 		goLog.Printf(format, args...)
 	}
 }
 
 func Errorln(args ...interface{}) {
-	if log != nil {
-		log.Errorln(args...)
+	if !enabled(errorLog) {
+		return
+	}
+	if l := getLogger(); l != nil {
+		l.Errorln(args...)
 	} else {
 		goLog.Println(args...)
 	}
 }
 
 func Errorf(format string, args ...interface{}) {
-	if log != nil {
-		log.Errorf(format, args...)
+	if !enabled(errorLog) {
+		return
+	}
+	if l := getLogger(); l != nil {
+		l.Errorf(format, args...)
 	} else {
 		goLog.Printf(format, args...)
 	}
 }
 
+// Infow writes the info log of the message and the fields, the fields are not formatted if the log is dropped
+func Infow(msg string, fields ...Field) {
+	if !enabled(infoLog) {
+		return
+	}
+	l := getLogger()
+	if sl, ok := l.(StructuredLogger); ok {
+		sl.Infow(msg, fields...)
+	} else if l != nil {
+		l.Infoln(string(appendFields(nil, msg, fields)))
+	} else {
+		goLog.Println(string(appendFields(nil, msg, fields)))
+	}
+}
+
+func Warningw(msg string, fields ...Field) {
+	if !enabled(warningLog) {
+		return
+	}
+	l := getLogger()
+	if sl, ok := l.(StructuredLogger); ok {
+		sl.Warningw(msg, fields...)
+	} else if l != nil {
+		l.Warningln(string(appendFields(nil, msg, fields)))
+	} else {
+		goLog.Println(string(appendFields(nil, msg, fields)))
+	}
+}
+
+func Errorw(msg string, fields ...Field) {
+	if !enabled(errorLog) {
+		return
+	}
+	l := getLogger()
+	if sl, ok := l.(StructuredLogger); ok {
+		sl.Errorw(msg, fields...)
+	} else if l != nil {
+		l.Errorln(string(appendFields(nil, msg, fields)))
+	} else {
+		goLog.Println(string(appendFields(nil, msg, fields)))
+	}
+}
+
 func Fatalln(args ...interface{}) {
-	if log != nil {
-		log.Fatalln(args...)
+	if l := getLogger(); l != nil {
+		l.Fatalln(args...)
 	} else {
 		goLog.Println(args...)
 	}
 }
 
 func Fatalf(format string, args ...interface{}) {
-	if log != nil {
-		log.Fatalf(format, args...)
+	if l := getLogger(); l != nil {
+		l.Fatalf(format, args...)
 	} else {
 		goLog.Printf(format, args...)
 	}
 }
 
 func Flush() {
-	if log != nil {
-		log.Flush()
+	if l := getLogger(); l != nil {
+		l.Flush()
 	}
 }
@@ -0,0 +1,174 @@
+package vlog
+
+import (
+	"errors"
+	"path"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServiceScopePrefix is the prefix of the scopes of the services, e.g. service:com.weibo.TestService
+const ServiceScopePrefix = "service:"
+
+type scopeLevel struct {
+	level  severity
+	expire time.Time // zero if the level is kept until reset
+	timer  *time.Timer
+}
+
+// ScopeLevel is the level of a scope set at runtime
+type ScopeLevel struct {
+	Scope  string `json:"scope"`
+	Level  string `json:"level"`
+	Expire int64  `json:"expire,omitempty"` // unix milliseconds
+}
+
+var (
+	scopeLock   sync.Mutex
+	scopeLevels atomic.Value // map[string]*scopeLevel, replaced when changed
+	scopeCount  int32
+
+	levelTimer   *time.Timer // restores the level set by SetLevelFor
+	restoreLevel severity
+)
+
+// SetLevelFor sets the minimal level of the logs, and restores the current level after the duration
+func SetLevelFor(name string, d time.Duration) error {
+	s, ok := severityByName(name)
+	if !ok {
+		return errors.New("unknown log level: " + name)
+	}
+	scopeLock.Lock()
+	defer scopeLock.Unlock()
+	old := minLevel.get()
+	if levelTimer != nil && levelTimer.Stop() {
+		// the level before the previous window is restored
+		old = restoreLevel
+	}
+	restoreLevel = old
+	minLevel.set(s)
+	levelTimer = time.AfterFunc(d, func() {
+		minLevel.set(old)
+	})
+	return nil
+}
+
+// SetScopeLevel sets the minimal level of the logs of the scope for the duration, or until reset if the duration is 0.
+// the scope is the dir name of the package writing the logs, such as endpoint, registry and cluster,
+// or ServiceScopePrefix+path for the logs of the service written by ScopeInfof
+func SetScopeLevel(scope string, name string, d time.Duration) error {
+	if scope == "" {
+		return errors.New("log scope is empty")
+	}
+	s, ok := severityByName(name)
+	if !ok {
+		return errors.New("unknown log level: " + name)
+	}
+	scopeLock.Lock()
+	defer scopeLock.Unlock()
+	levels := copyScopeLevels()
+	if old := levels[scope]; old != nil && old.timer != nil {
+		old.timer.Stop()
+	}
+	l := &scopeLevel{level: s}
+	if d > 0 {
+		l.expire = time.Now().Add(d)
+		l.timer = time.AfterFunc(d, func() {
+			scopeLock.Lock()
+			defer scopeLock.Unlock()
+			levels := copyScopeLevels()
+			if levels[scope] == l {
+				delete(levels, scope)
+				storeScopeLevels(levels)
+			}
+		})
+	}
+	levels[scope] = l
+	storeScopeLevels(levels)
+	return nil
+}
+
+// ResetScopeLevel removes the level of the scope, or the levels of all the scopes if the scope is empty
+func ResetScopeLevel(scope string) {
+	scopeLock.Lock()
+	defer scopeLock.Unlock()
+	levels := copyScopeLevels()
+	for k, l := range levels {
+		if scope == "" || k == scope {
+			if l.timer != nil {
+				l.timer.Stop()
+			}
+			delete(levels, k)
+		}
+	}
+	storeScopeLevels(levels)
+}
+
+// GetScopeLevels returns the levels of the scopes set at runtime
+func GetScopeLevels() []ScopeLevel {
+	levels, _ := scopeLevels.Load().(map[string]*scopeLevel)
+	result := make([]ScopeLevel, 0, len(levels))
+	for scope, l := range levels {
+		sl := ScopeLevel{Scope: scope, Level: severityName[l.level]}
+		if !l.expire.IsZero() {
+			sl.Expire = l.expire.UnixNano() / 1e6
+		}
+		result = append(result, sl)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Scope < result[j].Scope })
+	return result
+}
+
+// InfoEnabled returns whether the info logs of the scope are written
+func InfoEnabled(scope string) bool {
+	return infoLog >= levelOf(scope)
+}
+
+// ScopeInfof writes the info log if the info logs of the scope are enabled, such as the logs of a service
+func ScopeInfof(scope string, format string, args ...interface{}) {
+	if !InfoEnabled(scope) {
+		return
+	}
+	if l := getLogger(); l != nil {
+		l.Infof(format, args...)
+	}
+}
+
+func levelOf(scope string) severity {
+	if atomic.LoadInt32(&scopeCount) > 0 {
+		levels, _ := scopeLevels.Load().(map[string]*scopeLevel)
+		if l, ok := levels[scope]; ok {
+			return l.level
+		}
+	}
+	return minLevel.get()
+}
+
+// callerLevel returns the level of the package of the caller which is skip frames above
+func callerLevel(skip int) severity {
+	if atomic.LoadInt32(&scopeCount) == 0 {
+		return minLevel.get()
+	}
+	_, file, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return minLevel.get()
+	}
+	return levelOf(path.Base(path.Dir(file)))
+}
+
+func copyScopeLevels() map[string]*scopeLevel {
+	levels, _ := scopeLevels.Load().(map[string]*scopeLevel)
+	c := make(map[string]*scopeLevel, len(levels)+1)
+	for k, v := range levels {
+		c[k] = v
+	}
+	return c
+}
+
+func storeScopeLevels(levels map[string]*scopeLevel) {
+	scopeLevels.Store(levels)
+	atomic.StoreInt32(&scopeCount, int32(len(levels)))
+}
@@ -0,0 +1,61 @@
+package vlog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScopeLevel(t *testing.T) {
+	old := getLogger()
+	c := &countLogger{}
+	setLogger(c)
+	defer func() {
+		setLogger(old)
+		SetLevel("INFO")
+		ResetScopeLevel("")
+	}()
+	SetLevel("ERROR")
+	// the scope of the logs written in this package is the dir name
+	if err := SetScopeLevel("log", "INFO", 0); err != nil {
+		t.Fatalf("set scope level fail: %v", err)
+	}
+	Infoln("written by the scope level")
+	if c.count != 1 {
+		t.Errorf("the info logs of the scope should be written. count:%d", c.count)
+	}
+	if err := SetScopeLevel("log", "debug", 0); err == nil {
+		t.Errorf("unknown log level should fail")
+	}
+
+	SetScopeLevel(ServiceScopePrefix+"TestService", "INFO", 50*time.Millisecond)
+	if !InfoEnabled(ServiceScopePrefix+"TestService") || InfoEnabled(ServiceScopePrefix+"OtherService") {
+		t.Errorf("info logs should be only enabled for the service scope")
+	}
+	if levels := GetScopeLevels(); len(levels) != 2 || levels[1].Scope != "service:TestService" || levels[1].Expire == 0 {
+		t.Errorf("wrong scope levels: %+v", levels)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if InfoEnabled(ServiceScopePrefix + "TestService") {
+		t.Errorf("the scope level should expire")
+	}
+
+	ResetScopeLevel("log")
+	Infoln("dropped")
+	if c.count != 1 || len(GetScopeLevels()) != 0 {
+		t.Errorf("the scope level should be reset. count:%d", c.count)
+	}
+}
+
+func TestSetLevelFor(t *testing.T) {
+	defer SetLevel("INFO")
+	SetLevel("WARNING")
+	SetLevelFor("INFO", 50*time.Millisecond)
+	SetLevelFor("ERROR", 50*time.Millisecond)
+	if GetLevel() != "ERROR" {
+		t.Errorf("wrong level in the window: %s", GetLevel())
+	}
+	time.Sleep(100 * time.Millisecond)
+	if GetLevel() != "WARNING" {
+		t.Errorf("the level before the windows should be restored: %s", GetLevel())
+	}
+}
@@ -242,6 +242,14 @@ func (l *loggingT) printf(s severity, format string, args ...interface{}) {
 	l.output(s, buf, file, line, false)
 }
 
+// printw writes the message and the fields without fmt
+func (l *loggingT) printw(s severity, msg string, fields []Field) {
+	buf, file, line := l.header(s, 0)
+	buf.Write(appendFields(buf.AvailableBuffer(), msg, fields))
+	buf.WriteByte('\n')
+	l.output(s, buf, file, line, false)
+}
+
 func (l *loggingT) printWithFileLine(s severity, file string, line int, alsoToStderr bool, args ...interface{}) {
 	buf := l.formatHeader(s, file, line)
 	fmt.Fprint(buf, args...)
@@ -2,7 +2,10 @@ package vlog
 
 import (
 	//"fmt"
+	"errors"
 	"flag"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -26,30 +29,124 @@ func TestInfo(t *testing.T) {
 	flag.Set("log_dir", ".")
 	// flag.Set("log_dir", "/Users/Arthur/Station/Go/src/motan-go/log")
 
-	go func() {
-		for {
-			Infoln("info log 1")
-			// Warningln("warning log")
-			// Errorln("error log")
-			time.Sleep(time.Millisecond * 10)
-		}
-	}()
-	go func() {
-		for {
-			Infoln("info log 2")
-			// Warningln("warning log")
-			// Errorln("error log")
-			time.Sleep(time.Millisecond * 10)
-		}
+	// the goroutines are stopped before the test returns, so they never log to the loggers of the other tests
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 1; i <= 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-time.After(10 * time.Millisecond):
+					Infoln("info log", i)
+				}
+			}
+		}(i)
+	}
+	time.Sleep(time.Second * 1)
+	close(stop)
+	wg.Wait()
+}
+
+type countLogger struct {
+	Log
+	count int
+}
+
+func (c *countLogger) Infoln(args ...interface{}) {
+	c.count++
+}
+
+func (c *countLogger) Errorln(args ...interface{}) {
+	c.count++
+}
+
+func TestSetLevel(t *testing.T) {
+	old := getLogger()
+	c := &countLogger{}
+	setLogger(c)
+	defer func() {
+		setLogger(old)
+		SetLevel("INFO")
 	}()
+	if err := SetLevel("error"); err != nil || GetLevel() != "ERROR" {
+		t.Errorf("set log level fail. level:%s, err:%v", GetLevel(), err)
+	}
+	Infoln("dropped")
+	Errorln("written")
+	if c.count != 1 {
+		t.Errorf("the logs below the level should be dropped. count:%d", c.count)
+	}
+	if err := SetLevel("debug"); err == nil || GetLevel() != "ERROR" {
+		t.Errorf("unknown log level should fail")
+	}
+}
 
-	go func() {
-		for {
-			Infoln("info log 3")
-			// Warningln("warning log")
-			// Errorln("error log")
-			time.Sleep(time.Millisecond * 10)
-		}
+// lineLogger is a Logger without the fields
+type lineLogger struct {
+	lines []string
+}
+
+func (l *lineLogger) Infoln(args ...interface{})                  {}
+func (l *lineLogger) Infof(format string, args ...interface{})    {}
+func (l *lineLogger) Warningf(format string, args ...interface{}) {}
+func (l *lineLogger) Errorln(args ...interface{})                 {}
+func (l *lineLogger) Errorf(format string, args ...interface{})   {}
+func (l *lineLogger) Fatalln(args ...interface{})                 {}
+func (l *lineLogger) Fatalf(format string, args ...interface{})   {}
+func (l *lineLogger) Flush()                                      {}
+func (l *lineLogger) Warningln(args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprint(args...))
+}
+
+type structuredLogger struct {
+	lineLogger
+	fields []Field
+}
+
+func (s *structuredLogger) Infow(msg string, fields ...Field)  {}
+func (s *structuredLogger) Errorw(msg string, fields ...Field) {}
+func (s *structuredLogger) Warningw(msg string, fields ...Field) {
+	s.fields = fields
+}
+
+func TestStructuredLog(t *testing.T) {
+	line := string(appendFields(nil, "call fail", []Field{String("ep", "127.0.0.1:8002"), String("msg", "a b"), String("empty", ""),
+		Int("n", -1), Uint64("rid", 123), Float64("rate", 0.5), Bool("ok", true), Duration("cost", 1500*time.Millisecond),
+		Err(errors.New("timeout")), Err(nil), Any("list", []int{1, 2})}))
+	expect := `call fail ep=127.0.0.1:8002 msg="a b" empty="" n=-1 rid=123 rate=0.5 ok=true cost=1.5s error=timeout error=<nil> list="[1 2]"`
+	if line != expect {
+		t.Errorf("wrong structured log.\nexpect:%s\nactual:%s", expect, line)
+	}
+	if v := Duration("cost", time.Second).Value(); v != time.Second {
+		t.Errorf("wrong field value: %v", v)
+	}
+
+	old := getLogger()
+	defer func() {
+		setLogger(old)
+		SetLevel("INFO")
 	}()
-	time.Sleep(time.Second * 1)
+	// the loggers without fields write the formatted line
+	l := &lineLogger{}
+	setLogger(l)
+	Warningw("call fail", Uint64("rid", 1))
+	if len(l.lines) != 1 || l.lines[0] != "call fail rid=1" {
+		t.Errorf("wrong log lines: %v", l.lines)
+	}
+	s := &structuredLogger{}
+	setLogger(s)
+	Warningw("call fail", Uint64("rid", 1), String("ep", "a"))
+	if len(s.fields) != 2 || len(s.lines) != 0 {
+		t.Errorf("the fields should be written by the structured logger: %v", s.fields)
+	}
+	SetLevel("ERROR")
+	s.fields = nil
+	Warningw("dropped", Uint64("rid", 1))
+	if s.fields != nil {
+		t.Errorf("the logs below the level should be dropped")
+	}
 }
@@ -0,0 +1,112 @@
+//go:build zap
+// +build zap
+
+// Package zapadapter writes the logs of vlog by zap, it is built with -tags zap
+package zapadapter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	vlog "github.com/weibocom/motan-go/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger is the vlog.Logger and vlog.StructuredLogger writing the logs by zap, e.g. vlog.LogInit(zapadapter.New(logger))
+type Logger struct {
+	l *zap.Logger
+}
+
+// New returns the adapter of the zap logger, the callers of the vlog functions are the callers of the logs
+func New(l *zap.Logger) *Logger {
+	return &Logger{l: l.WithOptions(zap.AddCallerSkip(3))}
+}
+
+func (z *Logger) Infoln(args ...interface{}) {
+	z.write(zapcore.InfoLevel, sprintln(args))
+}
+
+func (z *Logger) Infof(format string, args ...interface{}) {
+	z.write(zapcore.InfoLevel, sprintf(format, args))
+}
+
+func (z *Logger) Warningln(args ...interface{}) {
+	z.write(zapcore.WarnLevel, sprintln(args))
+}
+
+func (z *Logger) Warningf(format string, args ...interface{}) {
+	z.write(zapcore.WarnLevel, sprintf(format, args))
+}
+
+func (z *Logger) Errorln(args ...interface{}) {
+	z.write(zapcore.ErrorLevel, sprintln(args))
+}
+
+func (z *Logger) Errorf(format string, args ...interface{}) {
+	z.write(zapcore.ErrorLevel, sprintf(format, args))
+}
+
+func (z *Logger) Fatalln(args ...interface{}) {
+	z.write(zapcore.FatalLevel, sprintln(args))
+}
+
+func (z *Logger) Fatalf(format string, args ...interface{}) {
+	z.write(zapcore.FatalLevel, sprintf(format, args))
+}
+
+func (z *Logger) Flush() {
+	z.l.Sync()
+}
+
+func (z *Logger) Infow(msg string, fields ...vlog.Field) {
+	z.write(zapcore.InfoLevel, msg, fields...)
+}
+
+func (z *Logger) Warningw(msg string, fields ...vlog.Field) {
+	z.write(zapcore.WarnLevel, msg, fields...)
+}
+
+func (z *Logger) Errorw(msg string, fields ...vlog.Field) {
+	z.write(zapcore.ErrorLevel, msg, fields...)
+}
+
+// write converts the fields only if the level is enabled
+func (z *Logger) write(level zapcore.Level, msg string, fields ...vlog.Field) {
+	ce := z.l.Check(level, msg)
+	if ce == nil {
+		return
+	}
+	zf := make([]zap.Field, len(fields))
+	for i, f := range fields {
+		switch v := f.Value().(type) {
+		case string:
+			zf[i] = zap.String(f.Key, v)
+		case int64:
+			zf[i] = zap.Int64(f.Key, v)
+		case uint64:
+			zf[i] = zap.Uint64(f.Key, v)
+		case float64:
+			zf[i] = zap.Float64(f.Key, v)
+		case bool:
+			zf[i] = zap.Bool(f.Key, v)
+		case time.Duration:
+			zf[i] = zap.Duration(f.Key, v)
+		case error:
+			zf[i] = zap.NamedError(f.Key, v)
+		default:
+			zf[i] = zap.Any(f.Key, v)
+		}
+	}
+	ce.Write(zf...)
+}
+
+// the vlog messages end with line breaks, which are not needed by zap
+func sprintln(args []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+func sprintf(format string, args []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+}
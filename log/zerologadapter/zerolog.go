@@ -0,0 +1,108 @@
+//go:build zerolog
+// +build zerolog
+
+// Package zerologadapter writes the logs of vlog by zerolog, it is built with -tags zerolog
+package zerologadapter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	vlog "github.com/weibocom/motan-go/log"
+)
+
+// Logger is the vlog.Logger and vlog.StructuredLogger writing the logs by zerolog, e.g. vlog.LogInit(zerologadapter.New(logger))
+type Logger struct {
+	l zerolog.Logger
+}
+
+func New(l zerolog.Logger) *Logger {
+	return &Logger{l: l}
+}
+
+func (z *Logger) Infoln(args ...interface{}) {
+	z.l.Info().Msg(sprintln(args))
+}
+
+func (z *Logger) Infof(format string, args ...interface{}) {
+	z.l.Info().Msg(sprintf(format, args))
+}
+
+func (z *Logger) Warningln(args ...interface{}) {
+	z.l.Warn().Msg(sprintln(args))
+}
+
+func (z *Logger) Warningf(format string, args ...interface{}) {
+	z.l.Warn().Msg(sprintf(format, args))
+}
+
+func (z *Logger) Errorln(args ...interface{}) {
+	z.l.Error().Msg(sprintln(args))
+}
+
+func (z *Logger) Errorf(format string, args ...interface{}) {
+	z.l.Error().Msg(sprintf(format, args))
+}
+
+func (z *Logger) Fatalln(args ...interface{}) {
+	z.l.Fatal().Msg(sprintln(args))
+}
+
+func (z *Logger) Fatalf(format string, args ...interface{}) {
+	z.l.Fatal().Msg(sprintf(format, args))
+}
+
+// Flush does nothing, the writer of the zerolog logger is not buffered by zerolog
+func (z *Logger) Flush() {
+}
+
+func (z *Logger) Infow(msg string, fields ...vlog.Field) {
+	write(z.l.Info(), msg, fields)
+}
+
+func (z *Logger) Warningw(msg string, fields ...vlog.Field) {
+	write(z.l.Warn(), msg, fields)
+}
+
+func (z *Logger) Errorw(msg string, fields ...vlog.Field) {
+	write(z.l.Error(), msg, fields)
+}
+
+// write adds the fields to the event, the event is nil if the level is disabled
+func write(e *zerolog.Event, msg string, fields []vlog.Field) {
+	if e == nil {
+		return
+	}
+	for _, f := range fields {
+		switch v := f.Value().(type) {
+		case string:
+			e.Str(f.Key, v)
+		case int64:
+			e.Int64(f.Key, v)
+		case uint64:
+			e.Uint64(f.Key, v)
+		case float64:
+			e.Float64(f.Key, v)
+		case bool:
+			e.Bool(f.Key, v)
+		case time.Duration:
+			e.Dur(f.Key, v)
+		case error:
+			e.AnErr(f.Key, v)
+		default:
+			e.Interface(f.Key, v)
+		}
+	}
+	e.Msg(msg)
+}
+
+// the vlog messages end with line breaks, which are not needed by zerolog
+func sprintln(args []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+func sprintf(format string, args []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/weibocom/motan-go"
 	motancore "github.com/weibocom/motan-go/core"
@@ -15,6 +16,22 @@ func runClientDemo() {
 	mccontext := motan.GetClientContext("./clientdemo.yaml")
 	mccontext.Start(nil)
 	mclient := mccontext.GetClient("mytest-motan2")
+	// the interceptors are called around every call of the client
+	mclient.AddInterceptor(motan.Interceptor{
+		BeforeCall: func(request motancore.Request) error {
+			request.SetAttachment("token", "mytoken")
+			return nil
+		},
+		AfterCall: func(request motancore.Request, response motancore.Response) {
+			if response.GetException() != nil {
+				fmt.Printf("call %s fail: %s\n", request.GetMethod(), response.GetException().ErrMsg)
+			}
+		},
+	})
+	// ping the providers before the first call
+	for _, r := range mclient.WarmUp(time.Second) {
+		fmt.Printf("endpoint %s ready:%v, rtt:%v %s\n", r.Address, r.Ready, r.RTT, r.Error)
+	}
 
 	args := make(map[string]string, 16)
 	args["name"] = "ray"
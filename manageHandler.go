@@ -3,10 +3,12 @@ package motan
 import (
 	"bufio"
 	"bytes"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
@@ -21,6 +23,7 @@ import (
 	"github.com/weibocom/motan-go/cluster"
 	motan "github.com/weibocom/motan-go/core"
 	"github.com/weibocom/motan-go/protocol"
+	mserver "github.com/weibocom/motan-go/server"
 )
 
 // SetAgent : if need agent to do sth, the handler can implement this interface,
@@ -110,14 +113,29 @@ type jsonRetData struct {
 	Body body `json:"body"`
 }
 
-// DebugHandler control pprof dynamically
+// DebugHandler control pprof dynamically, the pprof and /debug/runtime are enabled by pprof_enable of the
+// motan-agent section or the header ctr: op of /debug/pprof/sw. if pprof_token is set, the requests must have
+// the header Authorization: Bearer {token}
 // ***the func of pprof is copied from net/http/pprof ***
 type DebugHandler struct {
 	enable bool
+	token  string
+}
+
+func (d *DebugHandler) SetAgent(agent *Agent) {
+	d.enable = agent.pprofEnable
+	d.token = agent.pprofToken
 }
 
 // ServeHTTP implement handler interface
 func (d *DebugHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if d.token != "" {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) != 1 {
+			http.Error(rw, "invalid pprof token", http.StatusUnauthorized)
+			return
+		}
+	}
 	if req.URL.Path == "/debug/pprof/sw" {
 		t := req.Header.Get("ctr")
 		switch t {
@@ -140,12 +158,62 @@ func (d *DebugHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			Trace(rw, req)
 		case "/debug/mesh/trace":
 			MeshTrace(rw, req)
+		case "/debug/runtime":
+			rw.Header().Set("Content-Type", "application/json;charset=utf-8")
+			json.NewEncoder(rw).Encode(getRuntimeStats())
 		default:
 			Index(rw, req)
 		}
+	} else {
+		http.Error(rw, "pprof is disabled", http.StatusForbidden)
 	}
 }
 
+type runtimeStats struct {
+	Goroutines  int     `json:"goroutines"`
+	Threads     int     `json:"threads"`
+	GoMaxProcs  int     `json:"gomaxprocs"`
+	OpenFiles   int     `json:"open_files"` // -1 if unknown
+	HeapAlloc   uint64  `json:"heap_alloc"`
+	HeapInuse   uint64  `json:"heap_inuse"`
+	HeapIdle    uint64  `json:"heap_idle"`
+	HeapObjects uint64  `json:"heap_objects"`
+	Sys         uint64  `json:"sys"`
+	NextGC      uint64  `json:"next_gc"`
+	NumGC       uint32  `json:"num_gc"`
+	PauseTotal  float64 `json:"gc_pause_total_ms"`
+	// the latest pauses first
+	RecentPauses []float64 `json:"gc_recent_pauses_ms"`
+}
+
+func getRuntimeStats() *runtimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := &runtimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		Threads:      pprof.Lookup("threadcreate").Count(),
+		GoMaxProcs:   runtime.GOMAXPROCS(0),
+		OpenFiles:    -1,
+		HeapAlloc:    m.HeapAlloc,
+		HeapInuse:    m.HeapInuse,
+		HeapIdle:     m.HeapIdle,
+		HeapObjects:  m.HeapObjects,
+		Sys:          m.Sys,
+		NextGC:       m.NextGC,
+		NumGC:        m.NumGC,
+		PauseTotal:   float64(m.PauseTotalNs) / 1e6,
+		RecentPauses: make([]float64, 0, 16),
+	}
+	// PauseNs is a circular buffer, the latest pause is at (NumGC+255)%256
+	for i := uint32(0); i < m.NumGC && i < 16; i++ {
+		stats.RecentPauses = append(stats.RecentPauses, float64(m.PauseNs[(m.NumGC-1-i)%256])/1e6)
+	}
+	if fds, err := ioutil.ReadDir("/proc/self/fd"); err == nil {
+		stats.OpenFiles = len(fds)
+	}
+	return stats
+}
+
 func MeshTrace(w http.ResponseWriter, r *http.Request) {
 	sec, _ := strconv.ParseInt(r.FormValue("seconds"), 10, 64)
 	if sec == 0 {
@@ -296,6 +364,25 @@ func (s *SwitcherHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HealthHandler reports the health of the process for the probes.
+// /health/live responds 200 while the process can respond, /health/ready and /health respond 200 if the exported
+// services are registered and available and the dependency checks pass, 503 if not, with the json of mserver.HealthStatus
+type HealthHandler struct{}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health/live" {
+		w.Write([]byte("ok."))
+		return
+	}
+	status := mserver.CheckHealth()
+	b, _ := json.Marshal(status)
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(b)
+}
+
 //------------ below code is copied from net/http/pprof -------------
 
 // Cmdline responds with the running program's
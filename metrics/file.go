@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+func init() {
+	registerLogWriterCounter("motan_log_written_total", "the lines written by the async log writer", func(s vlog.AsyncWriterStats) int64 {
+		return s.Written
+	})
+	registerLogWriterCounter("motan_log_dropped_total", "the lines dropped by the async log writer when the queue is full", func(s vlog.AsyncWriterStats) int64 {
+		return s.Dropped
+	})
+	registerLogWriterCounter("motan_log_rotations_total", "the file rotations of the async log writer", func(s vlog.AsyncWriterStats) int64 {
+		return s.Rotations
+	})
+}
+
+func registerLogWriterCounter(name string, help string, value func(vlog.AsyncWriterStats) int64) {
+	RegisterCounterFunc(name, help, func() []GaugeValue {
+		var values []GaugeValue
+		vlog.RangeAsyncWriters(func(writer string, w *vlog.AsyncWriter) bool {
+			values = append(values, GaugeValue{Labels: map[string]string{"writer": writer}, Value: float64(value(w.Stats()))})
+			return true
+		})
+		return values
+	})
+}
+
+// fileSink writes the graphite lines to the local file in background, e.g.
+// {protocol: file, path: ./logs/metrics.log, rotate: day, maxBackups: 7, compress: true}
+type fileSink struct {
+	w       *vlog.AsyncWriter
+	localIP string
+}
+
+// newFileSink creates the file sink by the params path, maxSize(MB), rotate(hour or day), maxBackups, compress and queueSize
+func newFileSink(url *motan.URL) motan.MetricsSink {
+	conf := vlog.AsyncWriterConfig{
+		File:       url.GetParam("path", ""),
+		Rotate:     url.GetParam("rotate", ""),
+		MaxBackups: int(url.GetIntValue("maxBackups", 0)),
+		QueueSize:  int(url.GetIntValue("queueSize", 0)),
+	}
+	conf.MaxSize = url.GetIntValue("maxSize", 0) * 1024 * 1024
+	conf.Compress, _ = strconv.ParseBool(url.GetParam("compress", "false"))
+	w, err := vlog.NewAsyncWriter("metrics-"+url.GetParam("name", File), conf)
+	if err != nil {
+		vlog.Warningf("create metrics file sink fail: %v\n", err)
+		return nil
+	}
+	return &fileSink{w: w, localIP: strings.Replace(motan.GetLocalIP(), ".", "_", -1)}
+}
+
+func (f *fileSink) GetName() string {
+	return File
+}
+
+func (f *fileSink) Write(snapshots []motan.MetricsSnapshot) error {
+	for _, message := range genGraphiteMessages(f.localIP, snapshots) {
+		if message != "" {
+			f.w.Write([]byte(message))
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	motan "github.com/weibocom/motan-go/core"
 	"github.com/weibocom/motan-go/log"
 )
 
@@ -26,10 +27,9 @@ var (
 )
 
 type graphite struct {
-	Host    string
-	Port    int
-	Name    string
-	localIP string
+	Host string
+	Port int
+	Name string
 }
 
 func newGraphite(ip, pool string, port int) *graphite {
@@ -41,17 +41,19 @@ func newGraphite(ip, pool string, port int) *graphite {
 }
 
 func (g *graphite) Write(snapshots []Snapshot) error {
+	return g.send(toMetricsSnapshots(snapshots))
+}
+
+func (g *graphite) send(snapshots []motan.MetricsSnapshot) error {
 	conn, err := net.Dial("udp", net.JoinHostPort(g.Host, strconv.Itoa(g.Port)))
 	if err != nil {
 		vlog.Warningf("open graphite conn fail. err:%s\n", err.Error())
 		return err
 	}
 	defer conn.Close()
-	if g.localIP == "" {
-		g.localIP = strings.Replace(strings.Split(conn.LocalAddr().String(), ":")[0], ".", "_", -1)
-	}
-
-	messages := GenGraphiteMessages(g.localIP, snapshots)
+	// resolved by every send instead of cached, the sink may be written by the reporters concurrently
+	localIP := strings.Replace(strings.Split(conn.LocalAddr().String(), ":")[0], ".", "_", -1)
+	messages := genGraphiteMessages(localIP, snapshots)
 	for _, message := range messages {
 		_, err = conn.Write([]byte(message))
 		if err != nil {
@@ -62,6 +64,10 @@ func (g *graphite) Write(snapshots []Snapshot) error {
 }
 
 func GenGraphiteMessages(localIP string, snapshots []Snapshot) []string {
+	return genGraphiteMessages(localIP, toMetricsSnapshots(snapshots))
+}
+
+func genGraphiteMessages(localIP string, snapshots []motan.MetricsSnapshot) []string {
 	messages := make([]string, 0, 16)
 	var buf bytes.Buffer
 	buf.Grow(messageMaxLen)
@@ -98,3 +104,20 @@ func GenGraphiteMessages(localIP string, snapshots []Snapshot) []string {
 	messages = append(messages, buf.String())
 	return messages
 }
+
+// graphiteSink is the graphite configured in the sinks of the metrics section, e.g. {protocol: graphite, host: localhost, port: 8883, name: test}
+type graphiteSink struct {
+	*graphite
+}
+
+func newGraphiteSink(url *motan.URL) motan.MetricsSink {
+	return &graphiteSink{graphite: newGraphite(url.Host, url.GetParam("name", ""), url.Port)}
+}
+
+func (g *graphiteSink) GetName() string {
+	return Graphite
+}
+
+func (g *graphiteSink) Write(snapshots []motan.MetricsSnapshot) error {
+	return g.send(snapshots)
+}
@@ -71,20 +71,7 @@ type StatItem interface {
 
 type Snapshot interface {
 	StatItem
-	Count(key string) int64
-	Sum(key string) int64
-	Max(key string) int64
-	Mean(key string) float64
-	Min(key string) int64
-	P90(key string) float64
-	P95(key string) float64
-	P99(key string) float64
-	P999(key string) float64
-	Percentile(key string, v float64) float64
-	Percentiles(key string, f []float64) []float64
-	RangeKey(f func(k string))
-	IsHistogram(key string) bool
-	IsCounter(key string) bool
+	motan.MetricsSnapshot
 }
 
 type StatWriter interface {
@@ -391,9 +378,11 @@ func (d *DefaultStatItem) IsCounter(key string) bool {
 }
 
 type metric struct {
-	Period    int
-	Processor int
-	Graphite  []graphite
+	Period     int
+	Processor  int
+	Graphite   []graphite
+	Prometheus prometheusConfig
+	Otlp       otlpConfig
 }
 
 func StartReporter(ctx *motan.Context) {
@@ -413,6 +402,19 @@ func StartReporter(ctx *motan.Context) {
 				w := newGraphite(g.Host, g.Name, g.Port)
 				AddWriter(g.Name, w)
 			}
+			prom.init(&m.Prometheus)
+			if m.Otlp.Endpoint != "" {
+				prom.lock.Lock()
+				prom.enable()
+				prom.lock.Unlock()
+				application := ""
+				if ctx.AgentURL != nil {
+					application = ctx.AgentURL.GetParam(motan.ApplicationKey, "")
+				} else if ctx.ClientURL != nil {
+					application = ctx.ClientURL.GetParam(motan.ApplicationKey, "")
+				}
+				go newOtlpExporter(&m.Otlp, application).run()
+			}
 		}
 		for i := 0; i < rp.processor; i++ {
 			go rp.eventLoop()
@@ -465,6 +467,7 @@ func (r *reporter) processEvent(evt *event) {
 	case eventHistograms:
 		item.AddHistograms(evt.key, evt.value)
 	}
+	prom.add(evt)
 }
 
 func (r *reporter) sink() {
@@ -473,9 +476,9 @@ func (r *reporter) sink() {
 		func() {
 			defer motan.HandlePanic(nil)
 			snap := r.snapshot() // must snapshot periodically whatever has writers or not
+			r.writersLock.RLock()
+			defer r.writersLock.RUnlock()
 			if len(snap) > 0 && len(r.writers) > 0 {
-				r.writersLock.RLock()
-				defer r.writersLock.RUnlock()
 				for name, writer := range r.writers {
 					if err := writer.Write(snap); err != nil {
 						vlog.Errorf("write metrics error. name:%s, err:%v\n", name, err)
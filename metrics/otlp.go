@@ -0,0 +1,261 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/weibocom/motan-go/log"
+)
+
+const (
+	defaultOtlpInterval = 10 * time.Second
+	otlpRequestTimeout  = 5 * time.Second
+	otlpScopeName       = "motan-go"
+
+	// the aggregation temporality of OTLP
+	otlpCumulative = 2
+)
+
+type otlpConfig struct {
+	Endpoint string            // the OTLP/HTTP url of the metrics, e.g. http://localhost:4318/v1/metrics
+	Interval int               // seconds
+	Headers  map[string]string // e.g. the auth headers of the collector
+	Resource map[string]string // the resource attributes, service.name is the application if not set
+}
+
+// otlpExporter pushes the metrics collected for Prometheus to the OTel collector in the OTLP/HTTP json encoding.
+// the counters and the histograms are cumulative, the gauges and the runtime stats are sampled when pushed
+type otlpExporter struct {
+	endpoint string
+	interval time.Duration
+	headers  map[string]string
+	resource []otlpAttribute
+	client   *http.Client
+}
+
+func newOtlpExporter(conf *otlpConfig, application string) *otlpExporter {
+	e := &otlpExporter{
+		endpoint: conf.Endpoint,
+		interval: defaultOtlpInterval,
+		headers:  conf.Headers,
+		client:   &http.Client{Timeout: otlpRequestTimeout},
+	}
+	if conf.Interval > 0 {
+		e.interval = time.Duration(conf.Interval) * time.Second
+	}
+	resource := make(map[string]string, len(conf.Resource)+1)
+	for k, v := range conf.Resource {
+		resource[k] = v
+	}
+	if resource["service.name"] == "" && application != "" {
+		resource["service.name"] = application
+	}
+	e.resource = otlpAttributes(resource)
+	return e
+}
+
+func (e *otlpExporter) run() {
+	vlog.Infof("push metrics to OTLP endpoint %s every %v\n", e.endpoint, e.interval)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := e.push(); err != nil {
+			vlog.Warningf("push metrics to OTLP endpoint fail. endpoint:%s, err:%v\n", e.endpoint, err)
+		}
+	}
+}
+
+func (e *otlpExporter) push() error {
+	body, err := json.Marshal(e.build(time.Now()))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("OTLP endpoint responds %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
+
+// the messages of the OTLP json encoding, the 64 bits integers are strings
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Unit        string         `json:"unit,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsInt             string          `json:"asInt,omitempty"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+type otlpAttribute struct {
+	Key   string        `json:"key"`
+	Value otlpAttrValue `json:"value"`
+}
+
+type otlpAttrValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func (e *otlpExporter) build(now time.Time) *otlpRequest {
+	p := prom
+	metrics := make([]otlpMetric, 0, 16)
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+
+	p.lock.RLock()
+	start := strconv.FormatInt(p.start.UnixNano(), 10)
+	for _, name := range sortedNames(p.counters) {
+		series := p.counters[name]
+		sum := &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+		for _, labels := range sortedNames(series) {
+			c := series[labels]
+			sum.DataPoints = append(sum.DataPoints, otlpNumberPoint{
+				Attributes: pairAttributes(c.attrs), StartTimeUnixNano: start, TimeUnixNano: ts, AsInt: strconv.FormatInt(c.value, 10),
+			})
+		}
+		metrics = append(metrics, otlpMetric{Name: name, Sum: sum})
+	}
+	for _, name := range sortedNames(p.histograms) {
+		series := p.histograms[name]
+		histogram := &otlpHistogram{AggregationTemporality: otlpCumulative}
+		for _, labels := range sortedNames(series) {
+			h := series[labels]
+			counts := make([]string, len(h.counts))
+			for i, n := range h.counts {
+				counts[i] = strconv.FormatInt(n, 10)
+			}
+			histogram.DataPoints = append(histogram.DataPoints, otlpHistogramPoint{
+				Attributes: pairAttributes(h.attrs), StartTimeUnixNano: start, TimeUnixNano: ts,
+				Count: strconv.FormatInt(h.count, 10), Sum: float64(h.sum), BucketCounts: counts, ExplicitBounds: p.buckets,
+			})
+		}
+		metrics = append(metrics, otlpMetric{Name: name, Unit: "ms", Histogram: histogram})
+	}
+	for _, name := range sortedNames(p.gauges) {
+		g := p.gauges[name]
+		var points []otlpNumberPoint
+		for _, v := range g.values() {
+			value := v.Value
+			point := otlpNumberPoint{Attributes: otlpAttributes(v.Labels), TimeUnixNano: ts, AsDouble: &value}
+			if g.counter {
+				point.StartTimeUnixNano = start
+			}
+			points = append(points, point)
+		}
+		if g.counter {
+			metrics = append(metrics, otlpMetric{Name: name, Description: g.help, Sum: &otlpSum{DataPoints: points, AggregationTemporality: otlpCumulative, IsMonotonic: true}})
+		} else {
+			metrics = append(metrics, otlpMetric{Name: name, Description: g.help, Gauge: &otlpGauge{DataPoints: points}})
+		}
+	}
+	p.lock.RUnlock()
+
+	for _, s := range runtimeStats() {
+		value := s.value
+		point := otlpNumberPoint{TimeUnixNano: ts, AsDouble: &value}
+		if s.kind == "counter" {
+			point.StartTimeUnixNano = start
+			metrics = append(metrics, otlpMetric{Name: s.name, Sum: &otlpSum{DataPoints: []otlpNumberPoint{point}, AggregationTemporality: otlpCumulative, IsMonotonic: true}})
+		} else {
+			metrics = append(metrics, otlpMetric{Name: s.name, Gauge: &otlpGauge{DataPoints: []otlpNumberPoint{point}}})
+		}
+	}
+	return &otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: e.resource},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: otlpScopeName}, Metrics: metrics}},
+	}}}
+}
+
+func pairAttributes(pairs []string) []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		attrs = append(attrs, otlpAttribute{Key: pairs[i], Value: otlpAttrValue{StringValue: pairs[i+1]}})
+	}
+	return attrs
+}
+
+func otlpAttributes(m map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(m)*2)
+	for _, k := range keys {
+		pairs = append(pairs, k, m[k])
+	}
+	return pairAttributes(pairs)
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weibocom/motan-go/config"
+)
+
+func TestOtlpExporter(t *testing.T) {
+	var received *otlpRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = &otlpRequest{}
+		json.Unmarshal(body, received)
+		auth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	c, _ := config.NewConfigFromBytes([]byte("metrics:\n  otlp:\n    endpoint: " + server.URL + "\n    interval: 5\n" +
+		"    headers:\n      Authorization: Bearer token\n    resource:\n      deployment.environment: test\n"))
+	var m metric
+	if err := c.GetStruct("metrics", &m); err != nil {
+		t.Fatalf("parse otlp config fail. err:%v", err)
+	}
+	e := newOtlpExporter(&m.Otlp, "test-app")
+	if e.interval.Seconds() != 5 || len(e.resource) != 2 {
+		t.Errorf("wrong otlp exporter. exporter:%+v", e)
+	}
+
+	prom.lock.Lock()
+	prom.enable()
+	prom.lock.Unlock()
+	prom.add(&event{event: eventCounter, group: "g", service: "otlp.s", key: "motan-client:app:m.otlp_count", value: 3})
+	prom.add(&event{event: eventHistograms, group: "g", service: "otlp.s", key: "motan-client:app:otlp", value: 7})
+	if err := e.push(); err != nil {
+		t.Fatalf("push metrics fail. err:%v", err)
+	}
+	if auth != "Bearer token" || received == nil || len(received.ResourceMetrics) != 1 {
+		t.Fatalf("wrong request received. auth:%s, request:%+v", auth, received)
+	}
+	rm := received.ResourceMetrics[0]
+	if rm.Resource.Attributes[1].Key != "service.name" || rm.Resource.Attributes[1].Value.StringValue != "test-app" {
+		t.Errorf("wrong resource attributes: %+v", rm.Resource.Attributes)
+	}
+	metrics := make(map[string]otlpMetric)
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		metrics[metric.Name] = metric
+	}
+	if sum := metrics["motan_otlp_count"].Sum; sum == nil || sum.DataPoints[0].AsInt != "3" || !sum.IsMonotonic {
+		t.Errorf("wrong counter: %+v", metrics["motan_otlp_count"])
+	}
+	h := metrics["motan_request_latency_ms"].Histogram
+	if h == nil || len(h.DataPoints[0].BucketCounts) != len(h.DataPoints[0].ExplicitBounds)+1 || h.DataPoints[0].Count == "0" {
+		t.Errorf("wrong histogram: %+v", metrics["motan_request_latency_ms"])
+	}
+	if metrics["go_goroutines"].Gauge == nil {
+		t.Errorf("runtime stats should be pushed")
+	}
+}
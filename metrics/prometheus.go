@@ -0,0 +1,320 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	prometheusPrefix      = "motan_"
+	prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	// the labels of the request metrics, the role is always labeled
+	prometheusLabels = []string{"application", "group", "service", "method"}
+	// the upper bounds in milliseconds of the latency histograms
+	defaultPrometheusBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+	elapseSuffixes           = map[string]bool{
+		elapseLess50ms[1:]: true, elapseLess100ms[1:]: true, elapseLess200ms[1:]: true, elapseLess500ms[1:]: true, elapseMore500ms[1:]: true,
+	}
+
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	prom = &prometheusCollector{
+		labels:     prometheusLabels,
+		buckets:    defaultPrometheusBuckets,
+		counters:   make(map[string]map[string]*promCounter),
+		histograms: make(map[string]map[string]*promHistogram),
+		gauges:     make(map[string]*promGauge),
+	}
+)
+
+type prometheusConfig struct {
+	Enable  bool
+	Labels  []string // the labels of the request metrics in application, group, service and method, all if not set
+	Buckets []float64
+}
+
+// GaugeValue is a value of a gauge registered by RegisterGauge
+type GaugeValue struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// RegisterGauge registers the gauge exported to Prometheus, the values are got when scraped.
+// the gauge registered with the same name is replaced
+func RegisterGauge(name string, help string, values func() []GaugeValue) {
+	prom.lock.Lock()
+	defer prom.lock.Unlock()
+	prom.gauges[name] = &promGauge{help: help, values: values}
+}
+
+// RegisterCounterFunc registers the counter exported to Prometheus like RegisterGauge, the values are the totals
+// since the process started and got when scraped, such as the bytes sent by the connections
+func RegisterCounterFunc(name string, help string, values func() []GaugeValue) {
+	prom.lock.Lock()
+	defer prom.lock.Unlock()
+	prom.gauges[name] = &promGauge{help: help, values: values, counter: true}
+}
+
+// PrometheusHandler responds the metrics in the Prometheus text format, the request metrics are collected
+// if the metrics reporter is started with prometheus enabled
+func PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes the request metrics, the gauges and the go runtime stats in the Prometheus text format
+func WritePrometheus(w io.Writer) {
+	bw := bufio.NewWriter(w)
+	prom.write(bw)
+	writeRuntimeStats(bw)
+	bw.Flush()
+}
+
+type promCounter struct {
+	attrs []string // the label names and values in turn
+	value int64
+}
+
+type promHistogram struct {
+	attrs  []string
+	counts []int64 // the last one is +Inf
+	sum    int64
+	count  int64
+}
+
+type promGauge struct {
+	help    string
+	values  func() []GaugeValue
+	counter bool // the values are monotonic totals
+}
+
+// prometheusCollector accumulates the metric events for Prometheus and OTLP, as the stat items are cleared when reported
+type prometheusCollector struct {
+	lock       sync.RWMutex
+	enabled    bool
+	start      time.Time // the time the counters start
+	labels     []string
+	buckets    []float64
+	counters   map[string]map[string]*promCounter // name -> labels -> counter
+	histograms map[string]map[string]*promHistogram
+	gauges     map[string]*promGauge
+}
+
+func (p *prometheusCollector) init(conf *prometheusConfig) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if conf.Enable {
+		p.enable()
+	}
+	if len(conf.Labels) > 0 {
+		p.labels = conf.Labels
+	}
+	if len(conf.Buckets) > 0 {
+		p.buckets = conf.Buckets
+		sort.Float64s(p.buckets)
+	}
+}
+
+// enable starts collecting the events, the lock must be held
+func (p *prometheusCollector) enable() {
+	if !p.enabled {
+		p.enabled = true
+		p.start = time.Now()
+	}
+}
+
+func (p *prometheusCollector) add(evt *event) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.enabled {
+		return
+	}
+	name, labels, attrs := p.parse(evt)
+	if name == "" {
+		return
+	}
+	if evt.event == eventCounter {
+		series := p.counters[name]
+		if series == nil {
+			series = make(map[string]*promCounter)
+			p.counters[name] = series
+		}
+		c := series[labels]
+		if c == nil {
+			c = &promCounter{attrs: attrs}
+			series[labels] = c
+		}
+		c.value += evt.value
+		return
+	}
+	series := p.histograms[name]
+	if series == nil {
+		series = make(map[string]*promHistogram)
+		p.histograms[name] = series
+	}
+	h := series[labels]
+	if h == nil {
+		h = &promHistogram{attrs: attrs, counts: make([]int64, len(p.buckets)+1)}
+		series[labels] = h
+	}
+	i := sort.SearchFloat64s(p.buckets, float64(evt.value))
+	h.counts[i]++
+	h.sum += evt.value
+	h.count++
+}
+
+// parse gets the name and the labels from the key of the event like role:application:method.suffix.
+// the counters of the elapse ranges are skipped as the histograms have the buckets
+func (p *prometheusCollector) parse(evt *event) (string, string, []string) {
+	base, suffix := evt.key, ""
+	if i := strings.LastIndex(evt.key, "."); i >= 0 {
+		base, suffix = evt.key[:i], evt.key[i+1:]
+	}
+	if elapseSuffixes[suffix] {
+		return "", "", nil
+	}
+	values := map[string]string{"role": base, "group": evt.group, "service": evt.service}
+	if parts := strings.SplitN(base, ":", minKeyLength); len(parts) == minKeyLength {
+		values["role"], values["application"], values["method"] = parts[0], parts[1], parts[2]
+	}
+	name := prometheusPrefix + promName(suffix)
+	if evt.event == eventHistograms {
+		if suffix == "" {
+			suffix = "request"
+		}
+		name = prometheusPrefix + promName(suffix) + "_latency_ms"
+	} else if suffix == "" {
+		name = prometheusPrefix + "count"
+	}
+	attrs := []string{"role", values["role"]}
+	var b strings.Builder
+	writeLabel(&b, "role", values["role"])
+	for _, l := range p.labels {
+		attrs = append(attrs, l, values[l])
+		writeLabel(&b, l, values[l])
+	}
+	return name, b.String(), attrs
+}
+
+func (p *prometheusCollector) write(w *bufio.Writer) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	for _, name := range sortedNames(p.counters) {
+		w.WriteString("# TYPE " + name + " counter\n")
+		series := p.counters[name]
+		for _, labels := range sortedNames(series) {
+			w.WriteString(name + "{" + labels + "} " + strconv.FormatInt(series[labels].value, 10) + "\n")
+		}
+	}
+	for _, name := range sortedNames(p.histograms) {
+		w.WriteString("# TYPE " + name + " histogram\n")
+		series := p.histograms[name]
+		for _, labels := range sortedNames(series) {
+			h := series[labels]
+			var cumulative int64
+			for i, count := range h.counts {
+				cumulative += count
+				le := "+Inf"
+				if i < len(p.buckets) {
+					le = strconv.FormatFloat(p.buckets[i], 'f', -1, 64)
+				}
+				w.WriteString(name + "_bucket{" + labels + ",le=\"" + le + "\"} " + strconv.FormatInt(cumulative, 10) + "\n")
+			}
+			w.WriteString(name + "_sum{" + labels + "} " + strconv.FormatInt(h.sum, 10) + "\n")
+			w.WriteString(name + "_count{" + labels + "} " + strconv.FormatInt(h.count, 10) + "\n")
+		}
+	}
+	for _, name := range sortedNames(p.gauges) {
+		g := p.gauges[name]
+		kind := "gauge"
+		if g.counter {
+			kind = "counter"
+		}
+		w.WriteString("# HELP " + name + " " + g.help + "\n# TYPE " + name + " " + kind + "\n")
+		for _, v := range g.values() {
+			keys := make([]string, 0, len(v.Labels))
+			for k := range v.Labels {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			var b strings.Builder
+			for _, k := range keys {
+				writeLabel(&b, k, v.Labels[k])
+			}
+			if b.Len() > 0 {
+				w.WriteString(name + "{" + b.String() + "} " + strconv.FormatFloat(v.Value, 'g', -1, 64) + "\n")
+			} else {
+				w.WriteString(name + " " + strconv.FormatFloat(v.Value, 'g', -1, 64) + "\n")
+			}
+		}
+	}
+}
+
+type runtimeStat struct {
+	name  string
+	kind  string // gauge or counter
+	value float64
+}
+
+func runtimeStats() []runtimeStat {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return []runtimeStat{
+		{"go_goroutines", "gauge", float64(runtime.NumGoroutine())},
+		{"go_memstats_alloc_bytes", "gauge", float64(m.Alloc)},
+		{"go_memstats_sys_bytes", "gauge", float64(m.Sys)},
+		{"go_memstats_heap_objects", "gauge", float64(m.HeapObjects)},
+		{"go_memstats_heap_inuse_bytes", "gauge", float64(m.HeapInuse)},
+		{"go_gc_cycles_total", "counter", float64(m.NumGC)},
+		{"go_gc_pause_seconds_total", "counter", float64(m.PauseTotalNs) / 1e9},
+	}
+}
+
+func writeRuntimeStats(w *bufio.Writer) {
+	for _, s := range runtimeStats() {
+		w.WriteString("# TYPE " + s.name + " " + s.kind + "\n" + s.name + " " + strconv.FormatFloat(s.value, 'g', -1, 64) + "\n")
+	}
+}
+
+func writeLabel(b *strings.Builder, name string, value string) {
+	if b.Len() > 0 {
+		b.WriteByte(',')
+	}
+	b.WriteString(name)
+	b.WriteString("=\"")
+	b.WriteString(labelEscaper.Replace(value))
+	b.WriteByte('"')
+}
+
+// promName replaces the chars not allowed in the metric names with '_'
+func promName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func sortedNames(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.String())
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	p := &prometheusCollector{
+		labels:     prometheusLabels,
+		buckets:    defaultPrometheusBuckets,
+		counters:   make(map[string]map[string]*promCounter),
+		histograms: make(map[string]map[string]*promHistogram),
+		gauges:     make(map[string]*promGauge),
+	}
+	p.add(&event{event: eventCounter, group: "g", service: "s", key: "motan-client-agent:app:method.total_count", value: 1})
+	if len(p.counters) != 0 {
+		t.Errorf("events should not be collected if prometheus is not enabled")
+	}
+	p.init(&prometheusConfig{Enable: true, Labels: []string{"service", "method"}, Buckets: []float64{100, 10}})
+	for i := 0; i < 3; i++ {
+		p.add(&event{event: eventCounter, group: "g" + string(rune('0'+i)), service: "s", key: "motan-client-agent:app:method.total_count", value: 1})
+	}
+	p.add(&event{event: eventCounter, group: "g", service: "s", key: "motan-client-agent:app:method.Less50ms", value: 1})
+	p.add(&event{event: eventCounter, group: "g", service: "s\"q", key: "motan-provider-pool.timeout_count", value: 2})
+	for _, v := range []int64{5, 10, 50, 500} {
+		p.add(&event{event: eventHistograms, group: "g", service: "s", key: "motan-client-agent:app:method", value: v})
+	}
+	p.gauges["motan_test_gauge"] = &promGauge{help: "test", values: func() []GaugeValue {
+		return []GaugeValue{{Labels: map[string]string{"b": "2", "a": "1"}, Value: 1.5}, {Value: 3}}
+	}}
+	p.gauges["motan_test_bytes_total"] = &promGauge{help: "test", counter: true, values: func() []GaugeValue {
+		return []GaugeValue{{Value: 1024}}
+	}}
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	p.write(w)
+	w.Flush()
+	s := buf.String()
+	expects := []string{
+		"# TYPE motan_total_count counter\nmotan_total_count{role=\"motan-client-agent\",service=\"s\",method=\"method\"} 3\n",
+		"motan_timeout_count{role=\"motan-provider-pool\",service=\"s\\\"q\",method=\"\"} 2\n",
+		"# TYPE motan_request_latency_ms histogram\n",
+		"motan_request_latency_ms_bucket{role=\"motan-client-agent\",service=\"s\",method=\"method\",le=\"10\"} 2\n",
+		"motan_request_latency_ms_bucket{role=\"motan-client-agent\",service=\"s\",method=\"method\",le=\"100\"} 3\n",
+		"motan_request_latency_ms_bucket{role=\"motan-client-agent\",service=\"s\",method=\"method\",le=\"+Inf\"} 4\n",
+		"motan_request_latency_ms_sum{role=\"motan-client-agent\",service=\"s\",method=\"method\"} 565\n",
+		"motan_request_latency_ms_count{role=\"motan-client-agent\",service=\"s\",method=\"method\"} 4\n",
+		"# TYPE motan_test_gauge gauge\nmotan_test_gauge{a=\"1\",b=\"2\"} 1.5\nmotan_test_gauge 3\n",
+		"# TYPE motan_test_bytes_total counter\nmotan_test_bytes_total 1024\n",
+	}
+	for _, e := range expects {
+		if !strings.Contains(s, e) {
+			t.Errorf("metrics should contain %q. metrics:\n%s", e, s)
+		}
+	}
+	if strings.Contains(s, "Less50ms") {
+		t.Errorf("the elapse counters should be skipped. metrics:\n%s", s)
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	RegisterGauge("motan_handler_test", "test", func() []GaugeValue { return []GaugeValue{{Value: 1}} })
+	res := httptest.NewRecorder()
+	PrometheusHandler().ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(res.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("wrong content type: %s", res.Header().Get("Content-Type"))
+	}
+	if body := res.Body.String(); !strings.Contains(body, "motan_handler_test 1\n") || !strings.Contains(body, "go_goroutines ") {
+		t.Errorf("wrong metrics:\n%s", body)
+	}
+}
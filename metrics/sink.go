@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"strconv"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// the names of the default metrics sinks
+const (
+	Graphite = "graphite"
+	File     = "file"
+)
+
+// RegistDefaultSinks registers the default metrics sinks
+func RegistDefaultSinks(extFactory motan.ExtensionFactory) {
+	extFactory.RegistExtMetricsSink(Graphite, newGraphiteSink)
+	extFactory.RegistExtMetricsSink(File, newFileSink)
+}
+
+// AddSinks creates the sinks configured in the metrics section by the extension factory, and starts the reporter
+// if any sink is added. a sink is a map of the protocol, which is the name registered by RegistExtMetricsSink,
+// the host, the port and the params, the sinks with the same name param replace the former ones
+func AddSinks(ctx *motan.Context, extFactory motan.ExtensionFactory) {
+	if ctx.Config == nil {
+		return
+	}
+	section, _ := ctx.Config.GetSection("metrics")
+	sinks, _ := section["sinks"].([]interface{})
+	added := 0
+	for _, s := range sinks {
+		conf, ok := s.(map[interface{}]interface{})
+		if !ok {
+			vlog.Warningf("invalid metrics sink config: %v\n", s)
+			continue
+		}
+		url := sinkURL(conf)
+		sink := extFactory.GetMetricsSink(url)
+		if sink == nil {
+			continue
+		}
+		AddWriter(url.GetParam("name", url.Protocol), &sinkWriter{sink: sink})
+		added++
+	}
+	if added > 0 {
+		StartReporter(ctx)
+	}
+}
+
+func sinkURL(conf map[interface{}]interface{}) *motan.URL {
+	url := &motan.URL{Parameters: make(map[string]string, len(conf))}
+	for k, v := range conf {
+		switch key := motan.InterfaceToString(k); key {
+		case "protocol":
+			url.Protocol = motan.InterfaceToString(v)
+		case "host":
+			url.Host = motan.InterfaceToString(v)
+		case "port":
+			url.Port, _ = strconv.Atoi(motan.InterfaceToString(v))
+		default:
+			url.Parameters[key] = motan.InterfaceToString(v)
+		}
+	}
+	return url
+}
+
+// sinkWriter writes the snapshots of the reporter to a MetricsSink
+type sinkWriter struct {
+	sink motan.MetricsSink
+}
+
+func (w *sinkWriter) Write(snapshots []Snapshot) error {
+	return w.sink.Write(toMetricsSnapshots(snapshots))
+}
+
+func toMetricsSnapshots(snapshots []Snapshot) []motan.MetricsSnapshot {
+	result := make([]motan.MetricsSnapshot, len(snapshots))
+	for i, s := range snapshots {
+		result[i] = s
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/weibocom/motan-go/config"
+	motan "github.com/weibocom/motan-go/core"
+)
+
+// mockSink keeps all the written snapshots, it is also written by the reporter in background
+type mockSink struct {
+	url       *motan.URL
+	lock      sync.Mutex
+	snapshots []motan.MetricsSnapshot
+}
+
+func (m *mockSink) GetName() string {
+	return "mockSink"
+}
+
+func (m *mockSink) Write(snapshots []motan.MetricsSnapshot) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.snapshots = append(m.snapshots, snapshots...)
+	return nil
+}
+
+func (m *mockSink) count(key string) int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var count int64
+	for _, s := range m.snapshots {
+		count += s.Count(key)
+	}
+	return count
+}
+
+func TestAddSinks(t *testing.T) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	RegistDefaultSinks(ext)
+	var sink *mockSink
+	ext.RegistExtMetricsSink("mockSink", func(url *motan.URL) motan.MetricsSink {
+		sink = &mockSink{url: url}
+		return sink
+	})
+	c, _ := config.NewConfigFromBytes([]byte("metrics:\n  sinks:\n    - protocol: mockSink\n      name: sink-test\n      host: localhost\n      port: 9092\n      topic: metrics\n" +
+		"    - protocol: graphite\n      name: graphite-sink-test\n      host: localhost\n      port: 8883\n    - protocol: unknown\n"))
+	AddSinks(&motan.Context{Config: c}, ext)
+
+	assert.NotNil(t, sink, "sink should be created")
+	assert.Equal(t, "localhost", sink.url.Host)
+	assert.Equal(t, 9092, sink.url.Port)
+	assert.Equal(t, "metrics", sink.url.GetParam("topic", ""))
+	rp.writersLock.RLock()
+	w := rp.writers["sink-test"]
+	g, ok := rp.writers["graphite-sink-test"].(*sinkWriter)
+	rp.writersLock.RUnlock()
+	assert.NotNil(t, w, "sink should be added as writer")
+	assert.True(t, ok, "graphite sink should be added as writer")
+	assert.Equal(t, Graphite, g.sink.GetName())
+
+	item := NewStatItem(group, service)
+	item.AddCounter("c1", 1)
+	assert.Nil(t, w.Write([]Snapshot{item.SnapshotAndClear()}))
+	assert.Equal(t, int64(1), sink.count("c1"))
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesink")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "metrics.log")
+	sink := newFileSink(&motan.URL{Protocol: File, Parameters: map[string]string{"path": file, "name": "test", "rotate": "day"}})
+	assert.NotNil(t, sink)
+	assert.Equal(t, File, sink.GetName())
+
+	item := NewStatItem(group, service)
+	item.AddCounter("motan-client-agent:test:c1", 1)
+	assert.Nil(t, sink.Write([]motan.MetricsSnapshot{item.SnapshotAndClear()}))
+	fs := sink.(*fileSink)
+	assert.Nil(t, fs.w.Close())
+	assert.Equal(t, int64(1), fs.w.Stats().Written)
+	content, _ := ioutil.ReadFile(file)
+	assert.True(t, strings.HasSuffix(string(content), ".c1:1|c\n"), string(content))
+
+	assert.Nil(t, newFileSink(&motan.URL{Protocol: File}), "sink without path should not be created")
+}
@@ -0,0 +1,154 @@
+package protocol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+var (
+	// DefaultMaxFrameSize is the default max frame body size, messages with larger body are sent in chunks
+	// to peers who advertised their max frame size
+	DefaultMaxFrameSize = 4 * 1024 * 1024
+	// DefaultMaxMessageSize is the default max body size of a message reassembled from the chunks
+	DefaultMaxMessageSize = 128 * 1024 * 1024
+	// DefaultMaxPendingChunks is the default max messages being reassembled from one connection
+	DefaultMaxPendingChunks = 16
+
+	ErrChunk              = errors.New("chunked message not correct")
+	ErrChunkTooLarge      = errors.New("chunked message too large")
+	ErrTooManyChunkedMsgs = errors.New("too many chunked messages being reassembled")
+)
+
+// SetMaxFrameSize advertises the max frame body size the sender accepts
+func (msg *Message) SetMaxFrameSize(size int) {
+	msg.Metadata.Store(MMaxFrameSize, strconv.Itoa(size))
+}
+
+// GetMaxFrameSize returns the max frame body size advertised by the peer, 0 if the peer does not support chunks
+func (msg *Message) GetMaxFrameSize() int {
+	if msg.Metadata == nil {
+		return 0
+	}
+	size, err := strconv.Atoi(msg.Metadata.LoadOrEmpty(MMaxFrameSize))
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+// SplitChunks splits the message into frames whose body is not larger than maxFrameSize.
+// the first frame carries all metadata, the others only carry the chunk position.
+// the message itself is returned if it needs no split.
+func SplitChunks(msg *Message, maxFrameSize int) []*Message {
+	total := len(msg.Body)
+	if maxFrameSize <= 0 || total <= maxFrameSize {
+		return []*Message{msg}
+	}
+	chunks := make([]*Message, 0, total/maxFrameSize+1)
+	for offset := 0; offset < total; offset += maxFrameSize {
+		end := offset + maxFrameSize
+		if end > total {
+			end = total
+		}
+		header := *msg.Header
+		chunk := &Message{Header: &header, Body: msg.Body[offset:end], Type: msg.Type}
+		if offset == 0 {
+			chunk.Metadata = msg.Metadata.CopyOnWrite()
+		} else {
+			chunk.Metadata = motan.NewStringMap(1)
+		}
+		chunk.Metadata.Store(MChunk, strconv.Itoa(offset)+"/"+strconv.Itoa(total))
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// ChunkLimits limits the chunked messages received from one connection, the zero fields use the defaults
+type ChunkLimits struct {
+	MaxFrameSize   int // the max chunk body size, the max frame size advertised to the peer. not checked if 0
+	MaxMessageSize int // the max body size of a reassembled message
+	MaxPending     int // the max messages being reassembled
+}
+
+// ChunkAssembler reassembles the chunked messages received from one connection. the connection should be closed
+// if Add returns an error, such as a message exceeding the limits.
+// ChunkAssembler is not thread safe, it should be used by the goroutine reading the connection.
+type ChunkAssembler struct {
+	pending map[uint64]*Message
+	limits  ChunkLimits
+}
+
+func NewChunkAssembler(limits ChunkLimits) *ChunkAssembler {
+	if limits.MaxMessageSize <= 0 {
+		limits.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if limits.MaxPending <= 0 {
+		limits.MaxPending = DefaultMaxPendingChunks
+	}
+	return &ChunkAssembler{pending: make(map[uint64]*Message, 4), limits: limits}
+}
+
+// Add returns the message if it is not chunked or it is the last chunk of a message, otherwise returns nil
+func (c *ChunkAssembler) Add(msg *Message) (*Message, error) {
+	if msg.Metadata == nil {
+		return msg, nil
+	}
+	v := msg.Metadata.LoadOrEmpty(MChunk)
+	if v == "" {
+		return msg, nil
+	}
+	requestID := msg.Header.RequestID
+	offset, total, err := parseChunk(v)
+	end := offset + len(msg.Body)
+	if err != nil || end > total {
+		delete(c.pending, requestID)
+		return nil, ErrChunk
+	}
+	if total > c.limits.MaxMessageSize || (c.limits.MaxFrameSize > 0 && len(msg.Body) > c.limits.MaxFrameSize) {
+		delete(c.pending, requestID)
+		return nil, ErrChunkTooLarge
+	}
+	whole := c.pending[requestID]
+	if offset == 0 {
+		if whole == nil && len(c.pending) >= c.limits.MaxPending {
+			return nil, ErrTooManyChunkedMsgs
+		}
+		whole = msg
+		whole.Metadata.Delete(MChunk)
+		body := make([]byte, total)
+		copy(body, msg.Body)
+		whole.Body = body
+		c.pending[requestID] = whole
+	} else {
+		if whole == nil || len(whole.Body) != total {
+			delete(c.pending, requestID)
+			return nil, ErrChunk
+		}
+		copy(whole.Body[offset:], msg.Body)
+	}
+	if end < total {
+		return nil, nil
+	}
+	delete(c.pending, requestID)
+	return whole, nil
+}
+
+func parseChunk(v string) (offset int, total int, err error) {
+	i := strings.Index(v, "/")
+	if i < 0 {
+		return 0, 0, ErrChunk
+	}
+	if offset, err = strconv.Atoi(v[:i]); err != nil {
+		return 0, 0, err
+	}
+	if total, err = strconv.Atoi(v[i+1:]); err != nil {
+		return 0, 0, err
+	}
+	if offset < 0 || total <= 0 || offset >= total {
+		return 0, 0, ErrChunk
+	}
+	return offset, total, nil
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/weibocom/motan-go/core"
+)
+
+func TestChunks(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789"), 105)
+	msg := &Message{Header: BuildHeader(Req, false, Simple, 99, Normal), Metadata: core.NewStringMap(0), Body: body}
+	msg.Metadata.Store(MMethod, "test")
+	if chunks := SplitChunks(msg, 0); len(chunks) != 1 || chunks[0] != msg {
+		t.Fatalf("message should not be split without max frame size")
+	}
+	if chunks := SplitChunks(msg, len(body)); len(chunks) != 1 || chunks[0] != msg {
+		t.Fatalf("message not larger than max frame size should not be split")
+	}
+	chunks := SplitChunks(msg, 100)
+	if len(chunks) != 11 {
+		t.Fatalf("chunk size not correct. size:%d", len(chunks))
+	}
+
+	assembler := NewChunkAssembler(ChunkLimits{})
+	var whole *Message
+	for i, chunk := range chunks {
+		if len(chunk.Body) > 100 {
+			t.Fatalf("chunk body is larger than max frame size. size:%d", len(chunk.Body))
+		}
+		decoded, err := Decode(bufio.NewReader(chunk.Encode()))
+		if err != nil {
+			t.Fatalf("decode chunk fail. err:%v", err)
+		}
+		whole, err = assembler.Add(decoded)
+		if err != nil {
+			t.Fatalf("assemble chunk fail. err:%v", err)
+		}
+		if i < len(chunks)-1 && whole != nil {
+			t.Fatalf("message should not be assembled before the last chunk")
+		}
+	}
+	if whole == nil || !bytes.Equal(whole.Body, body) || whole.Header.RequestID != 99 {
+		t.Fatalf("assembled message not correct. msg:%+v", whole)
+	}
+	assertTrue(whole.Metadata.LoadOrEmpty(MMethod) == "test", "meta", t)
+	assertTrue(whole.Metadata.LoadOrEmpty(MChunk) == "", "chunk meta", t)
+
+	// a chunk without the first one is not correct
+	if _, err := NewChunkAssembler(ChunkLimits{}).Add(chunks[1]); err != ErrChunk {
+		t.Errorf("chunk without the first chunk should fail. err:%v", err)
+	}
+	// not chunked message is returned directly
+	plain := BuildHeartbeat(1, Req)
+	if m, err := assembler.Add(plain); m != plain || err != nil {
+		t.Errorf("not chunked message should be returned directly")
+	}
+}
+
+func TestChunkLimits(t *testing.T) {
+	msg := &Message{Header: BuildHeader(Req, false, Simple, 1, Normal), Metadata: core.NewStringMap(0), Body: make([]byte, 10)}
+	msg.Metadata.Store(MChunk, "0/1073741824")
+	if _, err := NewChunkAssembler(ChunkLimits{}).Add(msg); err != ErrChunkTooLarge {
+		t.Errorf("message larger than the max message size should fail. err:%v", err)
+	}
+	msg.Metadata.Store(MChunk, "0/100")
+	if _, err := NewChunkAssembler(ChunkLimits{MaxFrameSize: 5}).Add(msg); err != ErrChunkTooLarge {
+		t.Errorf("chunk larger than the max frame size should fail. err:%v", err)
+	}
+	assembler := NewChunkAssembler(ChunkLimits{MaxPending: 2})
+	for i := uint64(0); i < 3; i++ {
+		chunk := &Message{Header: BuildHeader(Req, false, Simple, i, Normal), Metadata: core.NewStringMap(0), Body: make([]byte, 10)}
+		chunk.Metadata.Store(MChunk, "0/100")
+		_, err := assembler.Add(chunk)
+		if i < 2 && err != nil {
+			t.Fatalf("assemble chunk fail. err:%v", err)
+		}
+		if i == 2 && err != ErrTooManyChunkedMsgs {
+			t.Errorf("messages being reassembled should be limited. err:%v", err)
+		}
+	}
+}
+
+func TestMaxFrameSize(t *testing.T) {
+	msg := &Message{Header: BuildHeader(Req, false, Simple, 1, Normal), Metadata: core.NewStringMap(0)}
+	assertTrue(msg.GetMaxFrameSize() == 0, "no max frame size", t)
+	msg.SetMaxFrameSize(1024)
+	assertTrue(msg.GetMaxFrameSize() == 1024, "max frame size", t)
+}
@@ -0,0 +1,112 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+const (
+	// bodies not larger than this size are not compressed if the min size is not configured
+	DefaultCompressSize = 1024
+	gzipCompressor      = "gzip"
+)
+
+// DefaultMaxDecompressedSize is the default max size of a decompressed body
+const DefaultMaxDecompressedSize = 128 * 1024 * 1024
+
+var (
+	ErrCompressorNil        = errors.New("compressor is nil")
+	ErrDecompressedTooLarge = errors.New("decompressed body too large")
+	maxDecompressedSize     = int64(DefaultMaxDecompressedSize)
+)
+
+// SetMaxDecompressedSize sets the max size of the bodies decompressed by gzip and the compressors, so a small
+// compressed body can not exhaust the memory. the default size is used if size <= 0
+func SetMaxDecompressedSize(size int) {
+	if size <= 0 {
+		size = DefaultMaxDecompressedSize
+	}
+	atomic.StoreInt64(&maxDecompressedSize, int64(size))
+}
+
+func GetMaxDecompressedSize() int {
+	return int(atomic.LoadInt64(&maxDecompressedSize))
+}
+
+// CompressMessage compresses the body with the first compressor in codecs which is also in accepted.
+// gzip is always accepted by the gzip flag of header, other compressors are marked by the metadata MCompress.
+// bodies not larger than minSize(DefaultCompressSize if minSize <= 0) are not compressed.
+func CompressMessage(msg *Message, extFactory motan.ExtensionFactory, codecs string, accepted string, minSize int) {
+	if codecs == "" || msg.Metadata == nil || msg.Header.IsGzip() || msg.Metadata.LoadOrEmpty(MCompress) != "" {
+		return
+	}
+	if minSize <= 0 {
+		minSize = DefaultCompressSize
+	}
+	if len(msg.Body) <= minSize {
+		return
+	}
+	for _, name := range strings.Split(codecs, ",") {
+		name = strings.TrimSpace(name)
+		if name == gzipCompressor {
+			EncodeMessageGzip(msg, minSize)
+			return
+		}
+		if !acceptCompressor(accepted, name) || extFactory == nil {
+			continue
+		}
+		compressor := extFactory.GetCompressor(name)
+		if compressor == nil {
+			continue
+		}
+		data, err := compressor.Compress(msg.Body)
+		if err != nil {
+			vlog.Warningf("compress message fail! compressor:%s, request id:%d, err:%s\n", name, msg.Header.RequestID, err.Error())
+			return
+		}
+		msg.Body = data
+		// the metadata may be the attachments of the request which are reused by retries
+		msg.Metadata = msg.Metadata.CopyOnWrite()
+		msg.Metadata.Store(MCompress, name)
+		return
+	}
+}
+
+// DecompressMessage decompresses the body compressed by the compressor in the metadata MCompress.
+// messages compressed by gzip flag are left to the converters.
+func DecompressMessage(msg *Message, extFactory motan.ExtensionFactory) error {
+	if msg.Metadata == nil {
+		return nil
+	}
+	name := msg.Metadata.LoadOrEmpty(MCompress)
+	if name == "" {
+		return nil
+	}
+	if extFactory == nil {
+		return ErrCompressorNil
+	}
+	compressor := extFactory.GetCompressor(name)
+	if compressor == nil {
+		return ErrCompressorNil
+	}
+	data, err := compressor.Decompress(msg.Body)
+	if err != nil {
+		return err
+	}
+	msg.Body = data
+	msg.Metadata.Delete(MCompress)
+	return nil
+}
+
+func acceptCompressor(accepted string, name string) bool {
+	for _, a := range strings.Split(accepted, ",") {
+		if strings.TrimSpace(a) == name {
+			return true
+		}
+	}
+	return false
+}
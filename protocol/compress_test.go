@@ -0,0 +1,79 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/weibocom/motan-go/core"
+)
+
+type reverseCompressor struct{}
+
+func (r *reverseCompressor) GetName() string {
+	return "reverse"
+}
+
+func (r *reverseCompressor) Compress(data []byte) ([]byte, error) {
+	ret := make([]byte, len(data))
+	for i, b := range data {
+		ret[len(data)-1-i] = b
+	}
+	return ret, nil
+}
+
+func (r *reverseCompressor) Decompress(data []byte) ([]byte, error) {
+	return r.Compress(data)
+}
+
+func TestCompressMessage(t *testing.T) {
+	ext := &core.DefaultExtensionFactory{}
+	ext.Initialize()
+	ext.RegistExtCompressor("reverse", func() core.Compressor {
+		return &reverseCompressor{}
+	})
+	body := bytes.Repeat([]byte("0123456789"), 200)
+	newMsg := func() *Message {
+		return &Message{Header: BuildHeader(Req, false, Simple, 99, Normal), Metadata: core.NewStringMap(0), Body: body}
+	}
+
+	// small body
+	msg := newMsg()
+	CompressMessage(msg, ext, "reverse", "reverse", 4096)
+	if msg.Metadata.LoadOrEmpty(MCompress) != "" || !bytes.Equal(msg.Body, body) {
+		t.Errorf("body smaller than min size should not be compressed")
+	}
+	// not accepted by peer, fall back to gzip
+	msg = newMsg()
+	metadata := msg.Metadata
+	CompressMessage(msg, ext, "reverse,gzip", "zstd", 0)
+	if !msg.Header.IsGzip() || msg.Metadata.LoadOrEmpty(MCompress) != "" {
+		t.Errorf("message should be compressed by gzip")
+	}
+	// negotiated
+	msg = newMsg()
+	CompressMessage(msg, ext, "zstd,reverse,gzip", "snappy, reverse", 0)
+	if msg.Header.IsGzip() || msg.Metadata.LoadOrEmpty(MCompress) != "reverse" || bytes.Equal(msg.Body, body) {
+		t.Fatalf("message should be compressed by reverse")
+	}
+	if metadata.LoadOrEmpty(MCompress) != "" {
+		t.Errorf("metadata of the origin message should not be changed")
+	}
+	decoded, err := Decode(bufio.NewReader(msg.Encode()))
+	if err != nil {
+		t.Fatalf("decode message fail. err:%v", err)
+	}
+	if err = DecompressMessage(decoded, ext); err != nil {
+		t.Fatalf("decompress message fail. err:%v", err)
+	}
+	if !bytes.Equal(decoded.Body, body) || decoded.Metadata.LoadOrEmpty(MCompress) != "" {
+		t.Errorf("decompressed message not correct")
+	}
+
+	// unknown compressor
+	msg = newMsg()
+	msg.Metadata.Store(MCompress, "unknown")
+	if err = DecompressMessage(msg, ext); err != ErrCompressorNil {
+		t.Errorf("decompress with unknown compressor should fail. err:%v", err)
+	}
+}
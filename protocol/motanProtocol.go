@@ -19,6 +19,8 @@ import (
 
 const (
 	DefaultMetaSize = 16
+	// initial size of the pooled buffers which bodies are serialized into
+	DefaultBodyBufferSize = 1024
 )
 
 //message type
@@ -34,17 +36,37 @@ const (
 	defaultProtocol = "motan2"
 )
 const (
-	MPath          = "M_p"
-	MMethod        = "M_m"
-	MExceptionn    = "M_e"
-	MProcessTime   = "M_pt"
-	MMethodDesc    = "M_md"
-	MGroup         = "M_g"
-	MProxyProtocol = "M_pp"
-	MVersion       = "M_v"
-	MModule        = "M_mdu"
-	MSource        = "M_s"
-	MRequestID     = "M_rid"
+	MPath           = "M_p"
+	MMethod         = "M_m"
+	MExceptionn     = "M_e"
+	MProcessTime    = "M_pt"
+	MMethodDesc     = "M_md"
+	MGroup          = "M_g"
+	MProxyProtocol  = "M_pp"
+	MVersion        = "M_v"
+	MModule         = "M_mdu"
+	MSource         = "M_s"
+	MRequestID      = "M_rid"
+	MStream         = "M_st"
+	MBatch          = "M_bt"
+	MDeadline       = "M_dl"  // remaining timeout of the request in milliseconds
+	MCancel         = "M_cc"  // the caller has given up the request with the same request id
+	MAcceptCancel   = "M_acc" // the sender handles the cancel frames, the peer sends no cancel frame until it advertised
+	MMaxFrameSize   = "M_mfs" // max frame body size the sender accepts, larger messages are sent in chunks
+	MChunk          = "M_ck"  // "offset/total" of the frame body in the body of a chunked message
+	MCompress       = "M_cp"  // name of the compressor of the body, the gzip flag of header is used for gzip
+	MAcceptCompress = "M_acp" // comma separated compressor names the sender can decompress
+	MQueueTime      = "M_qt"  // microseconds the request waited in the server before processing
+	MHandlerTime    = "M_ht"  // microseconds the handler of the server took
+	MControl        = "M_ctl" // kind of the control message pushed by the server, the body is the payload
+)
+
+// stream frame types, the value of metadata MStream.
+// frames of a streaming call share the request id of the open frame.
+const (
+	StreamOpen = "o" // first request frame of a streaming call
+	StreamData = "d" // a message sent by either side
+	StreamEnd  = "e" // the sender will send nothing more
 )
 
 type Header struct {
@@ -60,6 +82,9 @@ type Message struct {
 	Metadata *motan.StringMap
 	Body     []byte
 	Type     int
+
+	// pooled buffer of the body serialized by SerializationStream, released by ReleaseBody
+	bodyBuf *motan.BytesBuffer
 }
 
 //serialize
@@ -100,8 +125,13 @@ var (
 	ErrSerializeNum   = errors.New("message serialize number not correct")
 	ErrSerializeNil   = errors.New("message serialize not found")
 	ErrSerializedData = errors.New("message serialized data not correct")
+	ErrBatchSize      = errors.New("batch message size not correct")
+	ErrBatchTooLarge  = errors.New("batch message has too many messages")
 )
 
+// DefaultMaxBatchSize is the default max messages of a batch message
+const DefaultMaxBatchSize = 1024
+
 // BuildRequestHeader build a proxy request header
 func BuildRequestHeader(requestID uint64) *Header {
 	return BuildHeader(Req, true, defaultSerialize, requestID, Normal)
@@ -122,6 +152,157 @@ func BuildHeartbeat(requestID uint64, msgType int) *Message {
 	return request
 }
 
+// BuildStreamFrame build a data or end frame of a streaming call
+func BuildStreamFrame(msgType int, serialize int, requestID uint64, frame string, body []byte) *Message {
+	msg := &Message{
+		Header:   BuildHeader(msgType, false, serialize, requestID, Normal),
+		Metadata: motan.NewStringMap(DefaultMetaSize),
+		Body:     body,
+		Type:     msgType,
+	}
+	msg.Metadata.Store(MStream, frame)
+	return msg
+}
+
+// BuildBatchMessage packs messages into one message, the body is the concatenation of all encoded messages
+func BuildBatchMessage(msgType int, requestID uint64, msgs []*Message) *Message {
+	buf := motan.NewBytesBuffer(256 * len(msgs))
+	for _, msg := range msgs {
+		msgBuf := msg.Encode()
+		buf.Write(msgBuf.Bytes())
+		motan.ReleaseBytesBuffer(msgBuf)
+	}
+	batch := &Message{
+		Header:   BuildHeader(msgType, false, defaultSerialize, requestID, Normal),
+		Metadata: motan.NewStringMap(DefaultMetaSize),
+		Body:     buf.Bytes(),
+		Type:     msgType,
+	}
+	batch.Metadata.Store(MBatch, strconv.Itoa(len(msgs)))
+	return batch
+}
+
+// IsBatch returns true if the message is built by BuildBatchMessage
+func (msg *Message) IsBatch() bool {
+	return msg.Metadata != nil && msg.Metadata.LoadOrEmpty(MBatch) != ""
+}
+
+// DecodeBatch unpacks the messages of a batch message with at most DefaultMaxBatchSize messages
+func DecodeBatch(batch *Message) ([]*Message, error) {
+	return DecodeBatchLimit(batch, DefaultMaxBatchSize)
+}
+
+// DecodeBatchLimit unpacks the messages of a batch message, ErrBatchTooLarge is returned if the batch has more than
+// maxSize messages
+func DecodeBatchLimit(batch *Message, maxSize int) ([]*Message, error) {
+	size, err := strconv.Atoi(batch.Metadata.LoadOrEmpty(MBatch))
+	if err != nil || size < 0 {
+		return nil, ErrBatchSize
+	}
+	if size > maxSize {
+		return nil, ErrBatchTooLarge
+	}
+	body := batch.Body
+	if batch.Header.IsGzip() {
+		if body, err = DecodeGzip(body); err != nil {
+			return nil, err
+		}
+	}
+	// every message has a header and the sizes of the metadata and the body at least
+	if size > len(body)/(HeaderLength+8) {
+		return nil, ErrBatchSize
+	}
+	msgs := make([]*Message, 0, size)
+	buf := bufio.NewReader(bytes.NewReader(body))
+	for i := 0; i < size; i++ {
+		msg, err := Decode(buf)
+		if err != nil {
+			return nil, err
+		}
+		msg.Type = batch.Type
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// BuildCancelFrame builds the control frame to cancel the request with the request id
+func BuildCancelFrame(requestID uint64) *Message {
+	msg := &Message{
+		Header:   BuildHeader(Req, false, defaultSerialize, requestID, Normal),
+		Metadata: motan.NewStringMap(DefaultMetaSize),
+		Body:     make([]byte, 0),
+		Type:     Req,
+	}
+	msg.Header.SetOneWay(true)
+	msg.Metadata.Store(MCancel, "1")
+	return msg
+}
+
+// MaxControlPayloadSize is the max payload size of the control messages, which should be small
+const MaxControlPayloadSize = 64 * 1024
+
+// BuildControlMessage builds the control message pushed by the server to the clients of the connection.
+// it is a oneway response without request, the clients not knowing control messages drop it as an unknown response
+func BuildControlMessage(kind string, payload []byte) *Message {
+	msg := &Message{
+		Header:   BuildHeader(Res, false, defaultSerialize, 0, Normal),
+		Metadata: motan.NewStringMap(DefaultMetaSize),
+		Body:     payload,
+		Type:     Res,
+	}
+	msg.Header.SetOneWay(true)
+	msg.Metadata.Store(MControl, kind)
+	return msg
+}
+
+// GetControl returns the kind of the control message, empty string means not a control message
+func (msg *Message) GetControl() string {
+	if msg.Metadata == nil {
+		return ""
+	}
+	return msg.Metadata.LoadOrEmpty(MControl)
+}
+
+// IsCancel returns true if the message is built by BuildCancelFrame
+func (msg *Message) IsCancel() bool {
+	return msg.Metadata != nil && msg.Metadata.LoadOrEmpty(MCancel) != ""
+}
+
+// SetAcceptCancel advertises that the sender handles the cancel frames
+func (msg *Message) SetAcceptCancel() {
+	msg.Metadata.Store(MAcceptCancel, "1")
+}
+
+// AcceptCancel returns true if the peer advertised that it handles the cancel frames
+func (msg *Message) AcceptCancel() bool {
+	return msg.Metadata != nil && msg.Metadata.LoadOrEmpty(MAcceptCancel) != ""
+}
+
+// SetTimeout sets the remaining timeout of the request, the receiver computes the deadline from its receive time
+func (msg *Message) SetTimeout(timeout time.Duration) {
+	msg.Metadata.Store(MDeadline, strconv.FormatInt(int64(timeout/time.Millisecond), 10))
+}
+
+// GetDeadline returns the deadline of the request received at the time, false if the caller set no timeout
+func (msg *Message) GetDeadline(received time.Time) (time.Time, bool) {
+	if msg.Metadata == nil {
+		return time.Time{}, false
+	}
+	timeout, err := strconv.ParseInt(msg.Metadata.LoadOrEmpty(MDeadline), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return received.Add(time.Duration(timeout) * time.Millisecond), true
+}
+
+// GetStreamFrame returns the stream frame type of a message, empty string means not a stream message
+func (msg *Message) GetStreamFrame() string {
+	if msg.Metadata == nil {
+		return ""
+	}
+	return msg.Metadata.LoadOrEmpty(MStream)
+}
+
 func (h *Header) SetVersion(version int) error {
 	if version > 31 {
 		return ErrVersion
@@ -239,9 +420,22 @@ func BuildHeader(msgType int, proxy bool, serialize int, requestID uint64, msgSt
 	return header
 }
 
+// Encode encodes the message into a pooled BytesBuffer.
+// the buffer can be released by motan.ReleaseBytesBuffer after its bytes are written.
 func (msg *Message) Encode() (buf *motan.BytesBuffer) {
-	metabuf := motan.NewBytesBuffer(256)
-	msg.Metadata.Range(func(k, v string) bool {
+	bodysize := len(msg.Body)
+	buf = motan.AcquireBytesBuffer(int(HeaderLength + bodysize + 256 + 8))
+	// encode header.
+	buf.WriteUint16(MotanMagic)
+	buf.WriteByte(msg.Header.MsgType)
+	buf.WriteByte(msg.Header.VersionStatus)
+	buf.WriteByte(msg.Header.Serialize)
+	buf.WriteUint64(msg.Header.RequestID)
+
+	// encode meta directly, the size is written back after all entries are written
+	sizePos := buf.GetWPos()
+	buf.WriteUint32(0)
+	msg.Metadata.ForEach(func(k, v string) bool {
 		if k == "" || v == "" {
 			return true
 		}
@@ -249,31 +443,18 @@ func (msg *Message) Encode() (buf *motan.BytesBuffer) {
 			vlog.Errorf("metadata not correct.k:%s, v:%s\n", k, v)
 			return true
 		}
-		metabuf.Write([]byte(k))
-		metabuf.WriteByte('\n')
-		metabuf.Write([]byte(v))
-		metabuf.WriteByte('\n')
+		if buf.GetWPos() > sizePos+4 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(k)
+		buf.WriteByte('\n')
+		buf.WriteString(v)
 		return true
 	})
-
-	if metabuf.Len() > 0 {
-		metabuf.SetWPos(metabuf.GetWPos() - 1)
-	}
-	metasize := metabuf.Len()
-	bodysize := len(msg.Body)
-	buf = motan.NewBytesBuffer(int(HeaderLength + bodysize + metasize + 8))
-	// encode header.
-	buf.WriteUint16(MotanMagic)
-	buf.WriteByte(msg.Header.MsgType)
-	buf.WriteByte(msg.Header.VersionStatus)
-	buf.WriteByte(msg.Header.Serialize)
-	buf.WriteUint64(msg.Header.RequestID)
-
-	// encode meta
-	buf.WriteUint32(uint32(metasize))
-	if metasize > 0 {
-		buf.Write(metabuf.Bytes())
-	}
+	metaEnd := buf.GetWPos()
+	buf.SetWPos(sizePos)
+	buf.WriteUint32(uint32(metaEnd - sizePos - 4))
+	buf.SetWPos(metaEnd)
 
 	// encode body
 	buf.WriteUint32(uint32(bodysize))
@@ -290,18 +471,53 @@ func (msg *Message) Clone() interface{} {
 		Type:   msg.Type,
 	}
 	if msg.Metadata != nil {
-		newMessage.Metadata = msg.Metadata.Copy()
+		newMessage.Metadata = msg.Metadata.CopyOnWrite()
 	}
 	return newMessage
 }
 
+// bytesBufferWriter writes to a BytesBuffer as io.Writer
+type bytesBufferWriter struct {
+	buf *motan.BytesBuffer
+}
+
+func (w bytesBufferWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	return len(p), nil
+}
+
+// serializeBody serializes the body into a pooled buffer by the stream serialization
+func (msg *Message) serializeBody(f func(w io.Writer) error) error {
+	buf := motan.AcquireBytesBuffer(DefaultBodyBufferSize)
+	if err := f(bytesBufferWriter{buf: buf}); err != nil {
+		motan.ReleaseBytesBuffer(buf)
+		return err
+	}
+	msg.ReleaseBody()
+	msg.bodyBuf = buf
+	msg.Body = buf.Bytes()
+	return nil
+}
+
+// ReleaseBody puts the pooled body buffer back after the message is encoded, the body must not be used after released.
+// it does nothing if the body is not serialized into a pooled buffer
+func (msg *Message) ReleaseBody() {
+	if msg.bodyBuf == nil {
+		return
+	}
+	motan.ReleaseBytesBuffer(msg.bodyBuf)
+	msg.bodyBuf = nil
+	msg.Body = nil
+}
+
 func Decode(buf *bufio.Reader) (msg *Message, err error) {
 	msg, _, err = DecodeWithTime(buf)
 	return msg, err
 }
 
 func DecodeWithTime(buf *bufio.Reader) (msg *Message, start time.Time, err error) {
-	temp := make([]byte, HeaderLength, HeaderLength)
+	temp := motan.AcquireBytes(HeaderLength)
+	defer motan.ReleaseBytes(temp)
 
 	// decode header
 	_, err = io.ReadAtLeast(buf, temp, HeaderLength)
@@ -327,10 +543,12 @@ func DecodeWithTime(buf *bufio.Reader) (msg *Message, start time.Time, err error
 		return nil, start, err
 	}
 	metasize := int(binary.BigEndian.Uint32(temp[:4]))
-	metamap := motan.NewStringMap(DefaultMetaSize)
+	metamap := motan.AcquireStringMap(DefaultMetaSize)
 	if metasize > 0 {
-		metadata, err := readBytes(buf, metasize)
-		if err != nil {
+		// the metadata are copied to strings, so the bytes can be reused
+		metadata := motan.AcquireBytes(metasize)
+		defer motan.ReleaseBytes(metadata)
+		if _, err = io.ReadFull(buf, metadata); err != nil {
 			return nil, start, err
 		}
 		s, e := 0, 0
@@ -368,7 +586,7 @@ func DecodeWithTime(buf *bufio.Reader) (msg *Message, start time.Time, err error
 	if err != nil {
 		return nil, start, err
 	}
-	msg = &Message{header, metamap, body, Req}
+	msg = &Message{Header: header, Metadata: metamap, Body: body, Type: Req}
 	return msg, start, err
 }
 
@@ -383,12 +601,7 @@ func DecodeGzipBody(body []byte) []byte {
 
 func readBytes(buf *bufio.Reader, size int) ([]byte, error) {
 	tempbytes := make([]byte, size)
-	var s, n = 0, 0
-	var err error
-	for s < size && err == nil {
-		n, err = buf.Read(tempbytes[s:])
-		s += n
-	}
+	_, err := io.ReadFull(buf, tempbytes)
 	return tempbytes, err
 }
 
@@ -467,7 +680,11 @@ func DecodeGzip(data []byte) (ret []byte, err error) {
 			}
 		}()
 
-		_, err = buf.ReadFrom(r)
+		max := int64(GetMaxDecompressedSize())
+		n, err := buf.ReadFrom(io.LimitReader(r, max+1))
+		if err == nil && n > max {
+			return nil, ErrDecompressedTooLarge
+		}
 		return buf.Bytes(), err
 	}
 	return data, nil
@@ -475,7 +692,8 @@ func DecodeGzip(data []byte) (ret []byte, err error) {
 
 // ConvertToRequest convert motan2 protocol request message  to motan Request
 func ConvertToRequest(request *Message, serialize motan.Serialization) (motan.Request, error) {
-	motanRequest := &motan.MotanRequest{Arguments: make([]interface{}, 0)}
+	motanRequest := motan.AcquireMotanRequest()
+	motanRequest.Arguments = make([]interface{}, 0)
 	motanRequest.RequestID = request.Header.RequestID
 	if idStr, ok := request.Metadata.Load(MRequestID); !ok {
 		if request.Header.IsProxy() {
@@ -494,11 +712,13 @@ func ConvertToRequest(request *Message, serialize motan.Serialization) (motan.Re
 	rc := motanRequest.GetRPCContext(true)
 	rc.OriginalMessage = request
 	rc.Proxy = request.Header.IsProxy()
+	rc.Oneway = request.Header.IsOneWay()
 	if request.Body != nil && len(request.Body) > 0 {
 		if request.Header.IsGzip() {
 			request.Body = DecodeGzipBody(request.Body)
 			request.Header.SetGzip(false)
 		}
+		rc.SetRequestSize(len(request.Body))
 		if !rc.Proxy && serialize == nil {
 			return nil, ErrSerializeNil
 		}
@@ -515,7 +735,10 @@ func ConvertToReqMessage(request motan.Request, serialize motan.Serialization) (
 	if rc.Proxy && rc.OriginalMessage != nil {
 		if msg, ok := rc.OriginalMessage.(*Message); ok {
 			msg.Header.SetProxy(true)
-			EncodeMessageGzip(msg, rc.GzipSize)
+			rc.SetRequestSize(len(msg.Body))
+			if rc.Compress == "" {
+				EncodeMessageGzip(msg, rc.GzipSize)
+			}
 			return msg, nil
 		}
 	}
@@ -541,6 +764,19 @@ func ConvertToReqMessage(request motan.Request, serialize motan.Serialization) (
 				vlog.Warningf("convert request value fail! serialized value size > 1. request:%+v\n", request)
 				return nil, ErrSerializedData
 			}
+		} else if dv, ok := proxyArguments(request); ok {
+			// arguments of a proxy request are forwarded without deserializing if the serializations match
+			b, err := dv.Transcode(serialize, true)
+			if err != nil {
+				return nil, err
+			}
+			req.Body = b
+		} else if ss, ok := serialize.(motan.SerializationStream); ok {
+			if err := req.serializeBody(func(w io.Writer) error {
+				return ss.SerializeMultiTo(w, request.GetArguments())
+			}); err != nil {
+				return nil, err
+			}
 		} else {
 			b, err := serialize.SerializeMulti(request.GetArguments())
 			if err != nil {
@@ -550,8 +786,12 @@ func ConvertToReqMessage(request motan.Request, serialize motan.Serialization) (
 		}
 	}
 
+	rc.SetRequestSize(len(req.Body))
 	req.Metadata = request.GetAttachments()
-	EncodeMessageGzip(req, rc.GzipSize)
+	// compressed by CompressMessage after negotiation if the compressors are configured
+	if rc.Compress == "" {
+		EncodeMessageGzip(req, rc.GzipSize)
+	}
 	if rc.Oneway {
 		req.Header.SetOneWay(true)
 	}
@@ -572,12 +812,22 @@ func ConvertToReqMessage(request motan.Request, serialize motan.Serialization) (
 
 }
 
+// proxyArguments returns the arguments which are not deserialized yet
+func proxyArguments(request motan.Request) (*motan.DeserializableValue, bool) {
+	if len(request.GetArguments()) != 1 {
+		return nil, false
+	}
+	dv, ok := request.GetArguments()[0].(*motan.DeserializableValue)
+	return dv, ok
+}
+
 // ConvertToResMessage convert motan Response to protocol response
 func ConvertToResMessage(response motan.Response, serialize motan.Serialization) (*Message, error) {
 	rc := response.GetRPCContext(true)
 	if rc.Proxy && rc.OriginalMessage != nil {
 		if msg, ok := rc.OriginalMessage.(*Message); ok {
 			msg.Header.SetProxy(true)
+			rc.SetResponseSize(len(msg.Body))
 			return msg, nil
 		}
 	}
@@ -607,6 +857,18 @@ func ConvertToResMessage(response motan.Response, serialize motan.Serialization)
 				vlog.Warningf("convert response value fail! serialized value not []byte. res:%+v\n", response)
 				return nil, ErrSerializedData
 			}
+		} else if dv, ok := response.GetValue().(*motan.DeserializableValue); ok {
+			b, err := dv.Transcode(serialize, false)
+			if err != nil {
+				return nil, err
+			}
+			res.Body = b
+		} else if ss, ok := serialize.(motan.SerializationStream); ok {
+			if err := res.serializeBody(func(w io.Writer) error {
+				return ss.SerializeTo(w, response.GetValue())
+			}); err != nil {
+				return nil, err
+			}
 		} else {
 			b, err := serialize.Serialize(response.GetValue())
 			if err != nil {
@@ -616,8 +878,11 @@ func ConvertToResMessage(response motan.Response, serialize motan.Serialization)
 		}
 	}
 
+	rc.SetResponseSize(len(res.Body))
 	res.Metadata = response.GetAttachments()
-	EncodeMessageGzip(res, rc.GzipSize)
+	if rc.Compress == "" {
+		EncodeMessageGzip(res, rc.GzipSize)
+	}
 	if rc.Proxy {
 		res.Header.SetProxy(true)
 	}
@@ -627,7 +892,7 @@ func ConvertToResMessage(response motan.Response, serialize motan.Serialization)
 
 // ConvertToResponse convert protocol response to motan Response
 func ConvertToResponse(response *Message, serialize motan.Serialization) (motan.Response, error) {
-	mres := &motan.MotanResponse{}
+	mres := motan.AcquireMotanResponse()
 	rc := mres.GetRPCContext(true)
 	rc.Proxy = response.Header.IsProxy()
 	mres.RequestID = response.Header.RequestID
@@ -641,6 +906,7 @@ func ConvertToResponse(response *Message, serialize motan.Serialization) (motan.
 			response.Body = DecodeGzipBody(response.Body)
 			response.Header.SetGzip(false)
 		}
+		rc.SetResponseSize(len(response.Body))
 		if !rc.Proxy && serialize == nil {
 			return nil, ErrSerializeNil
 		}
@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"testing"
 	"time"
@@ -211,3 +213,214 @@ func buildBytes(size int) []byte {
 	}
 	return result.Bytes()
 }
+
+func TestBatchMessage(t *testing.T) {
+	msgs := make([]*Message, 0, 3)
+	for i := 0; i < 3; i++ {
+		msg := &Message{Header: BuildHeader(Req, false, Simple, uint64(i), Normal), Metadata: core.NewStringMap(0), Body: []byte(fmt.Sprintf("body%d", i))}
+		msg.Metadata.Store(MMethod, fmt.Sprintf("m%d", i))
+		msgs = append(msgs, msg)
+	}
+	batch := BuildBatchMessage(Req, 123, msgs)
+	newBatch, err := Decode(bufio.NewReader(batch.Encode()))
+	if err != nil {
+		t.Fatalf("decode batch message fail. err:%v", err)
+	}
+	assertTrue(newBatch.IsBatch(), "batch", t)
+	assertTrue(newBatch.Header.RequestID == 123, "request id", t)
+	decoded, err := DecodeBatch(newBatch)
+	if err != nil || len(decoded) != 3 {
+		t.Fatalf("decode batch fail. size:%d, err:%v", len(decoded), err)
+	}
+	for i, msg := range decoded {
+		assertTrue(msg.Header.RequestID == uint64(i), "sub request id", t)
+		assertTrue(msg.Metadata.LoadOrEmpty(MMethod) == fmt.Sprintf("m%d", i), "sub meta", t)
+		assertTrue(string(msg.Body) == fmt.Sprintf("body%d", i), "sub body", t)
+	}
+
+	newBatch.Metadata.Store(MBatch, "4")
+	if _, err = DecodeBatch(newBatch); err == nil {
+		t.Errorf("decode batch with wrong size should fail")
+	}
+	newBatch.Metadata.Store(MBatch, "2147483647")
+	if _, err = DecodeBatch(newBatch); err != ErrBatchTooLarge {
+		t.Errorf("decode batch with too many messages should fail. err:%v", err)
+	}
+	newBatch.Metadata.Store(MBatch, "1000")
+	if _, err = DecodeBatch(newBatch); err != ErrBatchSize {
+		t.Errorf("decode batch with more messages than the body should fail. err:%v", err)
+	}
+	assertTrue(!msgs[0].IsBatch(), "not batch", t)
+}
+
+func TestDeadline(t *testing.T) {
+	msg := &Message{Header: BuildHeader(Req, false, Simple, 1, Normal), Metadata: core.NewStringMap(0)}
+	if _, ok := msg.GetDeadline(time.Now()); ok {
+		t.Errorf("message without timeout should have no deadline")
+	}
+	msg.SetTimeout(1500 * time.Millisecond)
+	newMsg, _ := Decode(bufio.NewReader(msg.Encode()))
+	received := time.Now()
+	deadline, ok := newMsg.GetDeadline(received)
+	if !ok || !deadline.Equal(received.Add(1500*time.Millisecond)) {
+		t.Errorf("deadline not correct. deadline:%v, received:%v", deadline, received)
+	}
+}
+
+func TestCancelFrame(t *testing.T) {
+	msg, err := Decode(bufio.NewReader(BuildCancelFrame(456).Encode()))
+	if err != nil {
+		t.Fatalf("decode cancel frame fail. err:%v", err)
+	}
+	assertTrue(msg.IsCancel(), "cancel", t)
+	assertTrue(msg.Header.RequestID == 456, "request id", t)
+	assertTrue(!BuildHeartbeat(1, Req).IsCancel(), "not cancel", t)
+
+	res := BuildExceptionResponse(456, "{}")
+	assertTrue(!res.AcceptCancel(), "cancel not advertised", t)
+	res.SetAcceptCancel()
+	msg, err = Decode(bufio.NewReader(res.Encode()))
+	if err != nil {
+		t.Fatalf("decode response fail. err:%v", err)
+	}
+	assertTrue(msg.AcceptCancel(), "cancel advertised", t)
+}
+
+// lineSerialization writes strings in lines, and reads by streams only
+type lineSerialization struct {
+	streamReads int
+}
+
+func (l *lineSerialization) GetSerialNum() int { return 6 }
+
+func (l *lineSerialization) Serialize(v interface{}) ([]byte, error) {
+	return nil, errors.New("should serialize by stream")
+}
+
+func (l *lineSerialization) SerializeMulti(v []interface{}) ([]byte, error) {
+	return nil, errors.New("should serialize by stream")
+}
+
+func (l *lineSerialization) DeSerialize(b []byte, v interface{}) (interface{}, error) {
+	return nil, errors.New("should deserialize by stream")
+}
+
+func (l *lineSerialization) DeSerializeMulti(b []byte, v []interface{}) ([]interface{}, error) {
+	return nil, errors.New("should deserialize by stream")
+}
+
+func (l *lineSerialization) SerializeTo(w io.Writer, v interface{}) error {
+	_, err := fmt.Fprintf(w, "%v\n", v)
+	return err
+}
+
+func (l *lineSerialization) SerializeMultiTo(w io.Writer, v []interface{}) error {
+	for _, o := range v {
+		if err := l.SerializeTo(w, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *lineSerialization) DeSerializeFrom(r io.Reader, v interface{}) (interface{}, error) {
+	values, err := l.DeSerializeMultiFrom(r, nil)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+func (l *lineSerialization) DeSerializeMultiFrom(r io.Reader, v []interface{}) ([]interface{}, error) {
+	l.streamReads++
+	var values []interface{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		values = append(values, s.Text())
+	}
+	return values, s.Err()
+}
+
+func TestStreamSerialization(t *testing.T) {
+	s := &lineSerialization{}
+	request := &core.MotanRequest{ServiceName: "test.service", Method: "hello", Arguments: []interface{}{"a", 1},
+		Attachment: core.NewStringMap(0)}
+	msg, err := ConvertToReqMessage(request, s)
+	if err != nil {
+		t.Fatalf("convert request by stream serialization fail. err:%v", err)
+	}
+	if string(msg.Body) != "a\n1\n" || msg.bodyBuf == nil {
+		t.Errorf("body should be serialized into pooled buffer. body:%q", msg.Body)
+	}
+	buf := msg.Encode()
+	msg.ReleaseBody()
+	if msg.Body != nil || msg.bodyBuf != nil {
+		t.Errorf("body should be released")
+	}
+	msg.ReleaseBody()
+
+	decoded, err := Decode(bufio.NewReader(buf))
+	if err != nil {
+		t.Fatalf("decode message fail. err:%v", err)
+	}
+	req, err := ConvertToRequest(decoded, s)
+	if err != nil {
+		t.Fatalf("convert to request fail. err:%v", err)
+	}
+	args, err := req.GetArguments()[0].(*core.DeserializableValue).DeserializeMulti(nil)
+	if err != nil || len(args) != 2 || args[0] != "a" || args[1] != "1" || s.streamReads != 1 {
+		t.Errorf("arguments should be deserialized by stream. args:%v, err:%v", args, err)
+	}
+
+	response := &core.MotanResponse{RequestID: 1, Value: "ok", Attachment: core.NewStringMap(0)}
+	res, err := ConvertToResMessage(response, s)
+	if err != nil || string(res.Body) != "ok\n" {
+		t.Fatalf("convert response by stream serialization fail. body:%q, err:%v", res.Body, err)
+	}
+	res.ReleaseBody()
+}
+
+func TestProxyArguments(t *testing.T) {
+	s := &lineSerialization{}
+	request := &core.MotanRequest{ServiceName: "test.service", Method: "hello", Attachment: core.NewStringMap(0),
+		Arguments: []interface{}{&core.DeserializableValue{Serialization: s, Body: []byte("a\nb\n")}}}
+	request.GetRPCContext(true).Proxy = true
+	msg, err := ConvertToReqMessage(request, s)
+	if err != nil || string(msg.Body) != "a\nb\n" || s.streamReads != 0 {
+		t.Errorf("proxy arguments should be forwarded without deserializing. body:%q, err:%v", msg.Body, err)
+	}
+	response := &core.MotanResponse{RequestID: 1, Attachment: core.NewStringMap(0),
+		Value: &core.DeserializableValue{Serialization: s, Body: []byte("ok\n")}}
+	res, err := ConvertToResMessage(response, s)
+	if err != nil || string(res.Body) != "ok\n" || s.streamReads != 0 {
+		t.Errorf("proxy value should be forwarded without deserializing. body:%q, err:%v", res.Body, err)
+	}
+}
+
+func TestPayloadSize(t *testing.T) {
+	s := &lineSerialization{}
+	request := &core.MotanRequest{ServiceName: "test.service", Method: "hello", Arguments: []interface{}{"a", 1},
+		Attachment: core.NewStringMap(0)}
+	msg, err := ConvertToReqMessage(request, s)
+	if err != nil || request.GetRPCContext(false).RequestSize() != 4 {
+		t.Fatalf("request size should be recorded. size:%d, err:%v", request.GetRPCContext(false).RequestSize(), err)
+	}
+	req, err := ConvertToRequest(msg, s)
+	if err != nil || req.GetRPCContext(false).RequestSize() != 4 {
+		t.Errorf("request size of provider side should be recorded. size:%d, err:%v", req.GetRPCContext(false).RequestSize(), err)
+	}
+
+	response := &core.MotanResponse{RequestID: 1, Value: "ok", Attachment: core.NewStringMap(0)}
+	resMsg, err := ConvertToResMessage(response, s)
+	if err != nil || response.GetRPCContext(false).ResponseSize() != 3 {
+		t.Fatalf("response size should be recorded. size:%d, err:%v", response.GetRPCContext(false).ResponseSize(), err)
+	}
+	res, err := ConvertToResponse(resMsg, s)
+	if err != nil || res.GetRPCContext(false).ResponseSize() != 3 {
+		t.Errorf("response size of caller side should be recorded. size:%d, err:%v", res.GetRPCContext(false).ResponseSize(), err)
+	}
+	var rc *core.RPCContext
+	if rc.RequestSize() != 0 || rc.ResponseSize() != 0 {
+		t.Errorf("sizes of nil context should be 0")
+	}
+}
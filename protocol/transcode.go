@@ -0,0 +1,58 @@
+package protocol
+
+import (
+	"fmt"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+// TranscodeError is returned if the body can not be converted between serializations,
+// such as the untyped values decoded from simple can not be encoded by protobuf
+type TranscodeError struct {
+	From int
+	To   int
+	Err  error
+}
+
+func (e *TranscodeError) Error() string {
+	return fmt.Sprintf("transcode body from serialization %d to %d fail: %v", e.From, e.To, e.Err)
+}
+
+// TranscodeMessage re-encodes the body of msg by target and sets the serialize number of header.
+// request bodies are transcoded as multi arguments, compressed bodies are decompressed first.
+// the message is not changed if it is already serialized by target
+func TranscodeMessage(msg *Message, extFactory motan.ExtensionFactory, target motan.Serialization) error {
+	from := msg.Header.GetSerialize()
+	if target == nil {
+		return &TranscodeError{From: from, To: -1, Err: ErrSerializeNil}
+	}
+	to := target.GetSerialNum()
+	if from == to {
+		return nil
+	}
+	if len(msg.Body) > 0 {
+		source := extFactory.GetSerialization("", from)
+		if source == nil {
+			return &TranscodeError{From: from, To: to, Err: ErrSerializeNil}
+		}
+		if err := DecompressMessage(msg, extFactory); err != nil {
+			return &TranscodeError{From: from, To: to, Err: err}
+		}
+		if msg.Header.IsGzip() {
+			body, err := DecodeGzip(msg.Body)
+			if err != nil {
+				return &TranscodeError{From: from, To: to, Err: err}
+			}
+			msg.Body = body
+			msg.Header.SetGzip(false)
+		}
+		dv := &motan.DeserializableValue{Serialization: source, Body: msg.Body}
+		body, err := dv.Transcode(target, msg.Header.isRequest())
+		if err != nil {
+			return &TranscodeError{From: from, To: to, Err: err}
+		}
+		msg.ReleaseBody()
+		msg.Body = body
+	}
+	return msg.Header.SetSerialize(to)
+}
@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/weibocom/motan-go/core"
+)
+
+// wordSerialization serializes strings joined by sep
+type wordSerialization struct {
+	num int
+	sep string
+}
+
+func (w *wordSerialization) GetSerialNum() int { return w.num }
+
+func (w *wordSerialization) Serialize(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, ErrSerializedData
+	}
+	return []byte(s), nil
+}
+
+func (w *wordSerialization) SerializeMulti(v []interface{}) ([]byte, error) {
+	var b []byte
+	for i, o := range v {
+		s, err := w.Serialize(o)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			b = append(b, w.sep...)
+		}
+		b = append(b, s...)
+	}
+	return b, nil
+}
+
+func (w *wordSerialization) DeSerialize(b []byte, v interface{}) (interface{}, error) {
+	return string(b), nil
+}
+
+func (w *wordSerialization) DeSerializeMulti(b []byte, v []interface{}) ([]interface{}, error) {
+	var values []interface{}
+	start := 0
+	for i := 0; i <= len(b); i++ {
+		if i == len(b) || string(b[i]) == w.sep {
+			values = append(values, string(b[start:i]))
+			start = i + 1
+		}
+	}
+	return values, nil
+}
+
+func TestTranscodeMessage(t *testing.T) {
+	ext := &core.DefaultExtensionFactory{}
+	ext.Initialize()
+	ext.RegistryExtSerialization("comma", 20, func() core.Serialization { return &wordSerialization{num: 20, sep: ","} })
+	semicolon := &wordSerialization{num: 21, sep: ";"}
+
+	req := &Message{Header: BuildHeader(Req, false, 20, 1, Normal), Metadata: core.NewStringMap(0)}
+	req.Body, _ = EncodeGzip([]byte("a,b"))
+	req.Header.SetGzip(true)
+	if err := TranscodeMessage(req, ext, semicolon); err != nil {
+		t.Fatalf("transcode request fail. err:%v", err)
+	}
+	if string(req.Body) != "a;b" || req.Header.GetSerialize() != 21 || req.Header.IsGzip() {
+		t.Errorf("request not transcoded. body:%s, serialize:%d", req.Body, req.Header.GetSerialize())
+	}
+
+	res := &Message{Header: BuildHeader(Res, false, 21, 1, Normal), Metadata: core.NewStringMap(0), Body: []byte("x;y")}
+	if err := TranscodeMessage(res, ext, ext.GetSerialization("comma", -1)); err == nil {
+		t.Errorf("transcode from unknown serialization should fail")
+	}
+	res.Header.SetSerialize(20)
+	if err := TranscodeMessage(res, ext, semicolon); err != nil || string(res.Body) != "x;y" {
+		t.Errorf("response should be transcoded as single value. body:%s, err:%v", res.Body, err)
+	}
+	err := TranscodeMessage(res, ext, nil)
+	if te, ok := err.(*TranscodeError); !ok || te.From != 21 {
+		t.Errorf("transcode without target should fail. err:%v", err)
+	}
+}
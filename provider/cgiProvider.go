@@ -66,7 +66,7 @@ func (c *CgiProvider) Call(request motan.Request) motan.Response {
 		}
 	}
 
-	request.GetAttachments().Range(func(k, v string) bool {
+	request.GetAttachments().ForEach(func(k, v string) bool {
 		env["MOTAN_"+k] = v
 		return true
 	})
@@ -10,6 +10,8 @@ import (
 	"net/http"
 	URL "net/url"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +30,8 @@ type HTTPProvider struct {
 	srvURLMap  srvURLMapT
 	gctx       *motan.Context
 	mixVars    []string
+	upstreams  map[string]*httpUpstream
+	patterns   map[string]*regexp.Regexp
 }
 
 const (
@@ -39,10 +43,40 @@ const (
 	MotanRequestHTTPMethodKey = "HTTP_Method"
 )
 
+// the keys of the url params or the method confs in the http-service section, see main/agentdemo.yaml
+const (
+	httpURLFormatKey   = "URL_FORMAT"
+	httpReqMethodKey   = "HTTP_REQUEST_METHOD"
+	httpUpstreamKey    = "UPSTREAM"     // the upstream of the http-upstream section, the host of URL_FORMAT is replaced by its servers
+	httpHostKey        = "HOST"         // rewrites the Host header
+	httpPathPatternKey = "PATH_PATTERN" // rewrites the path matching the regexp by PATH_REPLACE
+	httpPathReplaceKey = "PATH_REPLACE"
+	httpTimeoutKey     = "TIMEOUT" // milliseconds, requestTimeout of the url by default
+	httpRetriesKey     = "RETRIES" // retries on other upstream servers if the request fails or the status is 502, 503 or 504
+)
+
+// httpRoute is the http request of a motan method
+type httpRoute struct {
+	url         string
+	method      string
+	upstream    *httpUpstream
+	host        string
+	pathPattern *regexp.Regexp
+	pathReplace string
+	timeout     time.Duration
+	retries     int
+}
+
 // Initialize http provider
 func (h *HTTPProvider) Initialize() {
-	h.httpClient = http.Client{Timeout: 1 * time.Second}
+	timeout := h.url.GetTimeDuration("requestTimeout", time.Millisecond, 1000*time.Millisecond)
+	h.httpClient = http.Client{Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: timeout}).DialContext,
+		MaxIdleConnsPerHost: 32,
+	}}
 	h.srvURLMap = make(srvURLMapT)
+	h.patterns = make(map[string]*regexp.Regexp)
+	h.compilePattern(h.url.Parameters[httpPathPatternKey])
 	urlConf, _ := h.gctx.Config.GetSection("http-service")
 	if urlConf != nil {
 		for confID, info := range urlConf {
@@ -53,18 +87,38 @@ func (h *HTTPProvider) Initialize() {
 					sconf := make(sConfT)
 					for k, v := range getSrvConf.(map[interface{}]interface{}) {
 						// @TODO gracful panic when got a conf err, like more %s in URL_FORMAT
-						sconf[k.(string)] = v.(string)
+						sconf[k.(string)] = motan.InterfaceToString(v)
 					}
+					h.compilePattern(sconf[httpPathPatternKey])
 					srvConf[method] = sconf
 				}
 			}
 			h.srvURLMap[confID.(string)] = srvConf
 		}
 	}
+	h.upstreams = newHTTPUpstreams(h.gctx)
+}
+
+func (h *HTTPProvider) compilePattern(pattern string) {
+	if pattern == "" || h.patterns[pattern] != nil {
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		vlog.Errorf("http provider path pattern compile fail. pattern:%s, err:%v\n", pattern, err)
+		return
+	}
+	h.patterns[pattern] = re
 }
 
 // Destroy a HTTPProvider
 func (h *HTTPProvider) Destroy() {
+	for _, u := range h.upstreams {
+		u.destroy()
+	}
+	if t, ok := h.httpClient.Transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
 }
 
 // SetSerialization for set a motan.SetSerialization to HTTPProvider
@@ -78,47 +132,85 @@ func (h *HTTPProvider) SetContext(context *motan.Context) {
 	h.gctx = context
 }
 
-func buildReqURL(request motan.Request, h *HTTPProvider) (string, string, error) {
+// buildRoute returns the route of the request by the url params, which are overridden by the conf of the method
+func buildRoute(request motan.Request, h *HTTPProvider) (*httpRoute, error) {
 	method := request.GetMethod()
-	httpReqURLFmt := h.url.Parameters["URL_FORMAT"]
-	httpReqMethod := ""
-	if getHTTPReqMethod, ok := h.url.Parameters["HTTP_REQUEST_METHOD"]; ok {
-		httpReqMethod = getHTTPReqMethod
-	} else {
-		httpReqMethod = DefaultMotanHTTPMethod
+	conf := make(map[string]string, len(h.url.Parameters))
+	for k, v := range h.url.Parameters {
+		conf[k] = v
 	}
 	// when set a extconf check the specific method conf first,then use the DefaultMotanMethodConfKey conf
-	if _, haveExtConf := h.srvURLMap[h.url.Parameters[motan.URLConfKey]]; haveExtConf {
-		var specificConf = make(map[string]string, 2)
-		if getSpecificConf, ok := h.srvURLMap[h.url.Parameters[motan.URLConfKey]][method]; ok {
-			specificConf = getSpecificConf
-		} else if getSpecificConf, ok := h.srvURLMap[h.url.Parameters[motan.URLConfKey]][DefaultMotanMethodConfKey]; ok {
-			specificConf = getSpecificConf
+	if srvConf, haveExtConf := h.srvURLMap[h.url.Parameters[motan.URLConfKey]]; haveExtConf {
+		specificConf, ok := srvConf[method]
+		if !ok {
+			specificConf = srvConf[DefaultMotanMethodConfKey]
 		}
-		if getHTTPReqURL, ok := specificConf["URL_FORMAT"]; ok {
-			httpReqURLFmt = getHTTPReqURL
-		}
-		if getHTTPReqMethod, ok := specificConf["HTTP_REQUEST_METHOD"]; ok {
-			httpReqMethod = getHTTPReqMethod
+		for k, v := range specificConf {
+			conf[k] = v
 		}
 	}
+	route := &httpRoute{method: DefaultMotanHTTPMethod, host: conf[httpHostKey], pathReplace: conf[httpPathReplaceKey]}
+	if m := conf[httpReqMethodKey]; m != "" {
+		route.method = m
+	}
 	// when motan request have a http method specific in attachment use this method
 	if motanRequestHTTPMethod, ok := request.GetAttachments().Load(MotanRequestHTTPMethodKey); ok {
-		httpReqMethod = motanRequestHTTPMethod
+		route.method = motanRequestHTTPMethod
 	}
-	var httpReqURL string
+	httpReqURLFmt := conf[httpURLFormatKey]
 	if count := strings.Count(httpReqURLFmt, "%s"); count > 0 {
 		if count > 1 {
 			errMsg := "Get err URL_FORMAT: " + httpReqURLFmt
 			vlog.Errorln(errMsg)
-			return httpReqURL, httpReqMethod, errors.New(errMsg)
+			return nil, errors.New(errMsg)
 		}
-		httpReqURL = fmt.Sprintf(httpReqURLFmt, method)
+		route.url = fmt.Sprintf(httpReqURLFmt, method)
 	} else {
-		httpReqURL = httpReqURLFmt
+		route.url = httpReqURLFmt
+	}
+	if name := conf[httpUpstreamKey]; name != "" {
+		if route.upstream = h.upstreams[name]; route.upstream == nil {
+			return nil, errors.New("http upstream not found: " + name)
+		}
+	}
+	if pattern := conf[httpPathPatternKey]; pattern != "" {
+		if route.pathPattern = h.patterns[pattern]; route.pathPattern == nil {
+			return nil, errors.New("bad http path pattern: " + pattern)
+		}
+	}
+	route.timeout = h.url.GetTimeDuration("requestTimeout", time.Millisecond, 1000*time.Millisecond)
+	if ms, err := strconv.Atoi(conf[httpTimeoutKey]); err == nil && ms > 0 {
+		route.timeout = time.Duration(ms) * time.Millisecond
 	}
+	route.retries, _ = strconv.Atoi(conf[httpRetriesKey])
+	return route, nil
+}
 
-	return httpReqURL, httpReqMethod, nil
+// target returns the url of the request to the server of the upstream, the path is rewritten if the pattern matches
+func (r *httpRoute) target(server string) (*URL.URL, error) {
+	u, err := URL.Parse(r.url)
+	if err != nil {
+		return nil, err
+	}
+	if r.upstream != nil {
+		u.Scheme, u.Host = r.upstream.scheme, server
+	}
+	if r.pathPattern != nil {
+		u.Path = r.pathPattern.ReplaceAllString(u.Path, r.pathReplace)
+		u.RawPath = ""
+	}
+	return u, nil
+}
+
+func retriable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
 }
 
 func buildQueryStr(request motan.Request, url *motan.URL, mixVars []string) (res string, err error) {
@@ -169,65 +261,83 @@ func (h *HTTPProvider) Call(request motan.Request) motan.Response {
 		return resp
 	}
 	resp.RequestID = request.GetRequestID()
-	httpReqURL, httpReqMethod, err := buildReqURL(request, h)
+	route, err := buildRoute(request, h)
 	if err != nil {
 		fillException(resp, t, err)
 		return resp
 	}
-	//vlog.Infof("HTTPProvider read to call: Method:%s, URL:%s", httpReqMethod, httpReqURL)
 	queryStr, err := buildQueryStr(request, h.url, h.mixVars)
 	if err != nil {
 		fillException(resp, t, err)
 		return resp
 	}
-	var reqBody io.Reader
-	if httpReqMethod == "GET" {
-		httpReqURL = httpReqURL + "?" + queryStr
-	} else if httpReqMethod == "POST" {
+	var form string
+	if route.method == "POST" {
 		data, err := URL.ParseQuery(queryStr)
 		if err != nil {
 			vlog.Errorf("new HTTP Provider ParseQuery err: %v", err)
 		}
-		reqBody = strings.NewReader(data.Encode())
+		form = data.Encode()
 	}
-	req, err := http.NewRequest(httpReqMethod, httpReqURL, reqBody)
-	if err != nil {
-		vlog.Errorf("new HTTP Provider NewRequest err: %v", err)
-		fillException(resp, t, err)
-		return resp
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded") //设置后，post参数才可正常传递
-	request.GetAttachments().Range(func(k, v string) bool {
-		k = strings.Replace(k, "M_", "MOTAN-", -1)
-		req.Header.Add(k, v)
-		return true
-	})
-
 	ip := ""
 	if remoteIP, exist := request.GetAttachments().Load(motan.RemoteIPKey); exist {
 		ip = remoteIP
 	} else {
 		ip = request.GetAttachment(motan.HostKey)
 	}
-	req.Header.Add("x-forwarded-for", ip)
-	req.Header.Set("Accept-Encoding", "") //强制不走gzip
+	c := h.httpClient
+	c.Timeout = route.timeout
 
-	timeout := h.url.GetTimeDuration("requestTimeout", time.Millisecond, 1000*time.Millisecond)
-	c := http.Client{
-		Transport: &http.Transport{
-			Dial: func(netw, addr string) (net.Conn, error) {
-				deadline := time.Now().Add(timeout)
-				c, err := net.DialTimeout(netw, addr, timeout)
-				if err != nil {
-					return nil, err
-				}
-				c.SetDeadline(deadline)
-				return c, nil
-			},
-		},
+	var httpResp *http.Response
+	var target *URL.URL
+	tried := make(map[string]bool)
+	for attempt := 0; ; attempt++ {
+		server := ""
+		if route.upstream != nil {
+			server = route.upstream.next(tried)
+			tried[server] = true
+		}
+		if target, err = route.target(server); err != nil {
+			fillException(resp, t, err)
+			return resp
+		}
+		if route.method == "GET" {
+			if target.RawQuery != "" {
+				target.RawQuery += "&" + queryStr
+			} else {
+				target.RawQuery = queryStr
+			}
+		}
+		var reqBody io.Reader
+		if route.method == "POST" {
+			reqBody = strings.NewReader(form)
+		}
+		req, reqErr := http.NewRequest(route.method, target.String(), reqBody)
+		if reqErr != nil {
+			vlog.Errorf("new HTTP Provider NewRequest err: %v", reqErr)
+			fillException(resp, t, reqErr)
+			return resp
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded") //设置后，post参数才可正常传递
+		request.GetAttachments().ForEach(func(k, v string) bool {
+			k = strings.Replace(k, "M_", "MOTAN-", -1)
+			req.Header.Add(k, v)
+			return true
+		})
+		req.Header.Add("x-forwarded-for", ip)
+		req.Header.Set("Accept-Encoding", "") //强制不走gzip
+		if route.host != "" {
+			req.Host = route.host
+		}
+		httpResp, err = c.Do(req)
+		if attempt >= route.retries || !retriable(httpResp, err) || (route.upstream != nil && len(tried) == len(route.upstream.servers)) {
+			break
+		}
+		if err == nil {
+			httpResp.Body.Close()
+		}
+		vlog.Warningf("HTTP Provider retries the request. url:%s, attempt:%d, err:%v\n", target, attempt+1, err)
 	}
-
-	httpResp, err := c.Do(req)
 	if err != nil {
 		vlog.Errorf("new HTTP Provider Do HTTP Call err: %v", err)
 		fillException(resp, t, err)
@@ -239,7 +349,7 @@ func (h *HTTPProvider) Call(request motan.Request) motan.Response {
 	body, err := ioutil.ReadAll(httpResp.Body)
 	l := len(body)
 	if l == 0 {
-		vlog.Warningf("server_agent result is empty :%d,%d,%s\n", statusCode, request.GetRequestID(), httpReqURL)
+		vlog.Warningf("server_agent result is empty :%d,%d,%s\n", statusCode, request.GetRequestID(), target)
 	}
 	resp.ProcessTime = int64((time.Now().UnixNano() - t) / 1e6)
 	if err != nil {
@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weibocom/motan-go/config"
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func newHTTPTestProvider(t *testing.T, conf string, params map[string]string) *HTTPProvider {
+	c, err := config.NewConfigFromBytes([]byte(conf))
+	if err != nil {
+		t.Fatalf("parse config fail. err:%v", err)
+	}
+	p := &HTTPProvider{url: &motan.URL{Path: "test.http.service", Parameters: params}}
+	p.SetContext(&motan.Context{Config: c})
+	p.Initialize()
+	return p
+}
+
+func TestHTTPProviderRoute(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(r.Host + " " + r.URL.Path + " " + r.URL.Query().Get("name")))
+	}))
+	defer backend.Close()
+	servers := strings.TrimPrefix(unavailable.URL, "http://") + "," + strings.TrimPrefix(backend.URL, "http://")
+	p := newHTTPTestProvider(t, `
+http-upstream:
+  test-backend:
+    servers: "`+servers+`"
+  test-slow:
+    servers: "`+strings.TrimPrefix(backend.URL, "http://")+`"
+http-service:
+  test-conf:
+    http_default_motan_method:
+      URL_FORMAT: "http://backend/v1/%s"
+      UPSTREAM: test-backend
+      HOST: api.weibo.com
+      PATH_PATTERN: "^/v1/"
+      PATH_REPLACE: "/v2/"
+      RETRIES: 1
+    slow:
+      URL_FORMAT: "http://backend/v1/%s"
+      UPSTREAM: test-slow
+      PATH_PATTERN: "^/v1/"
+      PATH_REPLACE: "/v2/"
+      TIMEOUT: 50
+    missing:
+      URL_FORMAT: "http://backend/%s"
+      UPSTREAM: unknown
+`, map[string]string{motan.URLConfKey: "test-conf"})
+	defer p.Destroy()
+
+	// the unavailable server is retried by the other one whichever is chosen first
+	for i := 0; i < 4; i++ {
+		request := &motan.MotanRequest{Method: "echo", Arguments: []interface{}{map[string]string{"name": "motan"}}, Attachment: motan.NewStringMap(0)}
+		res := p.Call(request)
+		if res.GetException() != nil || res.GetValue() != "api.weibo.com /v2/echo motan" {
+			t.Errorf("wrong routed response. value:%v, exception:%v", res.GetValue(), res.GetException())
+		}
+	}
+
+	res := p.Call(&motan.MotanRequest{Method: "slow", Attachment: motan.NewStringMap(0)})
+	if res.GetException() == nil {
+		t.Errorf("request should fail if the route timeout exceeded")
+	}
+	res = p.Call(&motan.MotanRequest{Method: "missing", Attachment: motan.NewStringMap(0)})
+	if res.GetException() == nil || !strings.Contains(res.GetException().ErrMsg, "upstream not found") {
+		t.Errorf("request should fail if the upstream not found. exception:%v", res.GetException())
+	}
+}
+
+func TestHTTPUpstreamHealthCheck(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer unhealthy.Close()
+	healthyAddr := strings.TrimPrefix(healthy.URL, "http://")
+	c, _ := config.NewConfigFromBytes([]byte(`
+http-upstream:
+  test-backend:
+    servers: "` + healthyAddr + "," + strings.TrimPrefix(unhealthy.URL, "http://") + `"
+    healthCheckPath: /health
+    healthCheckInterval: 10
+`))
+	upstreams := newHTTPUpstreams(&motan.Context{Config: c})
+	u := upstreams["test-backend"]
+	if u == nil || len(u.servers) != 2 || u.interval != 10*time.Millisecond {
+		t.Fatalf("wrong upstream. upstream:%+v", u)
+	}
+	defer u.destroy()
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		if addr := u.next(nil); addr != healthyAddr {
+			t.Errorf("unhealthy server should be skipped. server:%s", addr)
+		}
+	}
+	// all the servers are candidates if none is healthy
+	if addr := u.next(map[string]bool{healthyAddr: true}); addr == "" || addr == healthyAddr {
+		t.Errorf("unhealthy server should be chosen if no healthy one. server:%s", addr)
+	}
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+const (
+	defaultHealthCheckInterval = 5 * time.Second
+	defaultHealthCheckTimeout  = time.Second
+)
+
+// httpUpstream is a pool of http backends of the http-upstream section, the backends are chosen in turn.
+// if the health check path is set, the backends are checked every interval and the unhealthy ones are skipped
+type httpUpstream struct {
+	name     string
+	scheme   string
+	servers  []*upstreamServer
+	index    uint32
+	path     string
+	interval time.Duration
+	client   *http.Client
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type upstreamServer struct {
+	addr      string
+	unhealthy int32
+}
+
+// newHTTPUpstreams parses the upstreams of the http-upstream section and starts the health checks
+func newHTTPUpstreams(ctx *motan.Context) map[string]*httpUpstream {
+	upstreams := make(map[string]*httpUpstream)
+	if ctx == nil || ctx.Config == nil {
+		return upstreams
+	}
+	section, _ := ctx.Config.GetSection("http-upstream")
+	for k, v := range section {
+		conf, _ := v.(map[interface{}]interface{})
+		u := &httpUpstream{
+			name:     motan.InterfaceToString(k),
+			scheme:   "http",
+			path:     motan.InterfaceToString(conf["healthCheckPath"]),
+			interval: defaultHealthCheckInterval,
+			client:   &http.Client{Timeout: defaultHealthCheckTimeout},
+			stop:     make(chan struct{}),
+		}
+		if scheme := motan.InterfaceToString(conf["scheme"]); scheme != "" {
+			u.scheme = scheme
+		}
+		for _, addr := range motan.TrimSplit(motan.InterfaceToString(conf["servers"]), ",") {
+			if addr != "" {
+				u.servers = append(u.servers, &upstreamServer{addr: addr})
+			}
+		}
+		if len(u.servers) == 0 {
+			vlog.Warningf("http upstream without servers is ignored. upstream:%s\n", u.name)
+			continue
+		}
+		if ms, err := strconv.Atoi(motan.InterfaceToString(conf["healthCheckInterval"])); err == nil && ms > 0 {
+			u.interval = time.Duration(ms) * time.Millisecond
+		}
+		if u.path != "" {
+			go u.healthCheck()
+		}
+		upstreams[u.name] = u
+		vlog.Infof("http upstream inited. upstream:%s, servers:%d, health check:%s\n", u.name, len(u.servers), u.path)
+	}
+	return upstreams
+}
+
+// next returns the address of the next healthy backend excluding the tried ones,
+// all the backends are candidates if none is healthy
+func (u *httpUpstream) next(tried map[string]bool) string {
+	var fallback string
+	for i := 0; i < len(u.servers); i++ {
+		s := u.servers[int(atomic.AddUint32(&u.index, 1))%len(u.servers)]
+		if tried[s.addr] {
+			continue
+		}
+		if atomic.LoadInt32(&s.unhealthy) == 0 {
+			return s.addr
+		}
+		if fallback == "" {
+			fallback = s.addr
+		}
+	}
+	return fallback
+}
+
+func (u *httpUpstream) healthCheck() {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+	for {
+		u.checkServers()
+		select {
+		case <-ticker.C:
+		case <-u.stop:
+			return
+		}
+	}
+}
+
+func (u *httpUpstream) checkServers() {
+	for _, s := range u.servers {
+		healthy := false
+		if res, err := u.client.Get(u.scheme + "://" + s.addr + u.path); err == nil {
+			res.Body.Close()
+			healthy = res.StatusCode >= 200 && res.StatusCode < 300
+		}
+		var unhealthy int32
+		if !healthy {
+			unhealthy = 1
+		}
+		if atomic.SwapInt32(&s.unhealthy, unhealthy) != unhealthy {
+			vlog.Infof("http upstream server health changed. upstream:%s, server:%s, healthy:%t\n", u.name, s.addr, healthy)
+		}
+	}
+}
+
+func (u *httpUpstream) destroy() {
+	u.stopOnce.Do(func() { close(u.stop) })
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// Invoker calls the methods of a service without reflection, such as the adapters generated by motan-gen.
+// DefaultProvider calls the Invoker if the service implements it
+type Invoker interface {
+	Invoke(request motan.Request) motan.Response
+}
+
+// BindArgs sets the arguments of the request into the pointers of the parameters. the arguments are deserialized
+// into the parameter types if they are not deserialized yet, or converted to the parameter types.
+func BindArgs(request motan.Request, params ...interface{}) error {
+	args := request.GetArguments()
+	if len(args) == 1 {
+		if _, ok := args[0].(*motan.DeserializableValue); ok {
+			if err := request.ProcessDeserializable(params); err != nil {
+				return fmt.Errorf("deserialize arguments fail.%s", err.Error())
+			}
+			args = request.GetArguments()
+		}
+	}
+	if len(args) > len(params) {
+		return fmt.Errorf("method %s takes %d arguments, but %d are given", request.GetMethod(), len(params), len(args))
+	}
+	for i, arg := range args {
+		p := reflect.ValueOf(params[i])
+		if arg == params[i] {
+			// deserialized into the pointer
+			continue
+		}
+		if av := reflect.ValueOf(arg); av.Kind() == reflect.Ptr && av.Type() == p.Type() {
+			arg = av.Elem().Interface()
+		}
+		v, err := argValue(arg, p.Elem().Type())
+		if err != nil {
+			return fmt.Errorf("argument %d of method %s %s", i, request.GetMethod(), err.Error())
+		}
+		p.Elem().Set(v)
+	}
+	return nil
+}
+
+// CallContext returns the context of the call, background context if the request has none
+func CallContext(request motan.Request) context.Context {
+	if ctx := request.GetRPCContext(true).Context; ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// BuildResponse builds the response with the results of the method, the error is returned as a BizException
+func BuildResponse(request motan.Request, value interface{}, err error) motan.Response {
+	if err != nil {
+		return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewBizException(err.Error()))
+	}
+	return &motan.MotanResponse{RequestID: request.GetRequestID(), Value: value}
+}
+
+// BuildArgsErrorResponse builds the response of the request with wrong arguments
+func BuildArgsErrorResponse(request motan.Request, err error) motan.Response {
+	return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: err.Error(), ErrType: motan.ServiceException})
+}
+
+// MethodNotFound builds the response of the request calling an unknown method
+func MethodNotFound(request motan.Request) motan.Response {
+	vlog.Errorw("method not found in provider", vlog.Uint64("rid", request.GetRequestID()), vlog.String("service", request.GetServiceName()), vlog.String("method", request.GetMethod()))
+	return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewException(motan.ErrCodeNotFound, "method "+request.GetMethod()+" is not found in provider."))
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/serialize"
+)
+
+type echoInvoker struct{}
+
+func (e *echoInvoker) Invoke(request motan.Request) motan.Response {
+	var name string
+	var n int
+	if err := BindArgs(request, &name, &n); err != nil {
+		return BuildArgsErrorResponse(request, err)
+	}
+	if n < 0 {
+		return BuildResponse(request, nil, errors.New("negative"))
+	}
+	return BuildResponse(request, name+string(rune('0'+n)), nil)
+}
+
+func TestInvoker(t *testing.T) {
+	p := &DefaultProvider{}
+	p.SetURL(&motan.URL{Path: "echo"})
+	p.SetService(&echoInvoker{})
+	p.Initialize()
+	if p.invoker == nil || len(p.methods) != 0 {
+		t.Fatalf("invoker should be called without reflection")
+	}
+
+	// deserialize arguments
+	s := &serialize.SimpleSerialization{}
+	body, _ := s.SerializeMulti([]interface{}{"a", 1})
+	request := &motan.MotanRequest{Method: "echo", Arguments: []interface{}{&motan.DeserializableValue{Serialization: s, Body: body}}, Attachment: motan.NewStringMap(0)}
+	res := p.Call(request)
+	if res.GetException() != nil || res.GetValue() != "a1" {
+		t.Errorf("invoke with serialized arguments fail. res:%+v, exception:%+v", res, res.GetException())
+	}
+	// convert arguments
+	res = p.Call(&motan.MotanRequest{Method: "echo", Arguments: []interface{}{"b", int64(2)}, Attachment: motan.NewStringMap(0)})
+	if res.GetException() != nil || res.GetValue() != "b2" {
+		t.Errorf("invoke with converted arguments fail. res:%+v", res)
+	}
+	res = p.Call(&motan.MotanRequest{Method: "echo", Arguments: []interface{}{"b", -1}, Attachment: motan.NewStringMap(0)})
+	if res.GetException() == nil || res.GetException().ErrType != motan.BizException {
+		t.Errorf("error should be a biz exception. res:%+v", res)
+	}
+	for _, args := range [][]interface{}{{"b", "2"}, {"b", 2, 3}} {
+		res = p.Call(&motan.MotanRequest{Method: "echo", Arguments: args, Attachment: motan.NewStringMap(0)})
+		if res.GetException() == nil || res.GetException().ErrType != motan.ServiceException {
+			t.Errorf("wrong arguments should be rejected. args:%v, res:%+v", args, res)
+		}
+	}
+	if res = MethodNotFound(request); res.GetException() == nil {
+		t.Errorf("method not found should be an exception")
+	}
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	motan "github.com/weibocom/motan-go/core"
@@ -20,16 +21,30 @@ const (
 	DefaultHost  = "127.0.0.1"
 )
 
+// Initialize creates the endpoint to the local process by the param proxy such as "motan2:8100".
+// the local process can listen on a unix socket by the param proxy.host such as "unix:/var/run/app.sock",
+// then only the protocol of proxy is used, such as "motan2"
 func (m *MotanProvider) Initialize() {
-	protocol, port, err := motan.ParseExportInfo(m.url.GetParam(motan.ProxyKey, ""))
-	if err != nil {
-		vlog.Errorf("reverse proxy service config in %s error!\n", motan.ProxyKey)
-		return
-	} else if port <= 0 {
-		vlog.Errorln("reverse proxy service port config error!")
-		return
-	}
+	proxy := m.url.GetParam(motan.ProxyKey, "")
 	host := m.url.GetParam(ProxyHostKey, DefaultHost)
+	var protocol string
+	var port int
+	if strings.HasPrefix(host, motan.UnixSocketPrefix) {
+		if protocol = strings.TrimSpace(strings.SplitN(proxy, ":", 2)[0]); protocol == "" {
+			vlog.Errorf("reverse proxy service config in %s error!\n", motan.ProxyKey)
+			return
+		}
+	} else {
+		var err error
+		protocol, port, err = motan.ParseExportInfo(proxy)
+		if err != nil {
+			vlog.Errorf("reverse proxy service config in %s error!\n", motan.ProxyKey)
+			return
+		} else if port <= 0 {
+			vlog.Errorln("reverse proxy service port config error!")
+			return
+		}
+	}
 	m.ep = m.extFactory.GetEndPoint(&motan.URL{Protocol: protocol, Host: host, Port: port})
 	if m.ep == nil {
 		vlog.Errorf("Can not find %s endpoint in ExtensionFactory!\n", protocol)
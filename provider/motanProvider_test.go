@@ -1,10 +1,16 @@
 package provider
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	motan "github.com/weibocom/motan-go/core"
 	"github.com/weibocom/motan-go/endpoint"
+	"github.com/weibocom/motan-go/protocol"
+	"github.com/weibocom/motan-go/serialize"
+	"github.com/weibocom/motan-go/server"
 )
 
 const (
@@ -44,3 +50,51 @@ func TestGetName(t *testing.T) {
 	}
 
 }
+
+type unixSocketHandler struct{}
+
+func (h *unixSocketHandler) Call(request motan.Request) motan.Response {
+	return &motan.MotanResponse{RequestID: request.GetRequestID(), Value: []byte("ok:" + request.GetMethod()), Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}
+}
+func (h *unixSocketHandler) AddProvider(p motan.Provider) error            { return nil }
+func (h *unixSocketHandler) RmProvider(p motan.Provider)                   {}
+func (h *unixSocketHandler) GetProvider(serviceName string) motan.Provider { return nil }
+
+func TestProxyUnixSocket(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "motan-provider")
+	defer os.RemoveAll(dir)
+	factory := &motan.DefaultExtensionFactory{}
+	factory.Initialize()
+	endpoint.RegistDefaultEndpoint(factory)
+	serialize.RegistDefaultSerializations(factory)
+	host := motan.UnixSocketPrefix + filepath.Join(dir, "app.sock")
+	s := &server.MotanServer{URL: &motan.URL{Host: host}}
+	if err := s.Open(false, true, &unixSocketHandler{}, factory); err != nil {
+		t.Fatalf("open unix socket server fail. err:%v", err)
+	}
+	defer s.Destroy()
+
+	url := &motan.URL{Path: serviceName, Parameters: map[string]string{motan.ProxyKey: "motan2", ProxyHostKey: host}}
+	p := &MotanProvider{url: url, extFactory: factory}
+	p.Initialize()
+	defer p.Destroy()
+	if !p.IsAvailable() {
+		t.Fatal("provider to unix socket should be available")
+	}
+	// the requests forwarded by the agent are the messages decoded in proxy mode
+	msg, _ := protocol.ConvertToReqMessage(&motan.MotanRequest{RequestID: 1, ServiceName: serviceName, Method: "hello", Arguments: []interface{}{"world"},
+		Attachment: motan.NewStringMap(motan.DefaultAttachmentSize)}, &serialize.SimpleSerialization{})
+	msg.Header.SetProxy(true)
+	request, _ := protocol.ConvertToRequest(msg, nil)
+	res := p.Call(request)
+	if res.GetException() != nil {
+		t.Fatalf("call through unix socket fail. exception:%+v", res.GetException())
+	}
+	dv, ok := res.GetValue().(*motan.DeserializableValue)
+	if !ok {
+		t.Fatalf("proxy response should not be deserialized. value:%#v", res.GetValue())
+	}
+	if v, err := (&serialize.SimpleSerialization{}).DeSerialize(dv.Body, nil); err != nil || string(v.([]byte)) != "ok:hello" {
+		t.Errorf("wrong response through unix socket. value:%v, err:%v", v, err)
+	}
+}
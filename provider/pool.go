@@ -0,0 +1,313 @@
+package provider
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	"github.com/weibocom/motan-go/metrics"
+)
+
+const (
+	defaultPoolSize        = 8
+	defaultPoolWaitTimeout = time.Second
+	poolMetricKey          = "motan-provider-pool"
+)
+
+var (
+	errPoolClosed = errors.New("provider pool closed")
+	// the pools initialized and not destroyed, for the metrics
+	pools sync.Map
+)
+
+func init() {
+	metrics.RegisterGauge("motan_provider_pool_instances", "the instances of the provider pools by the states size, created and idle", func() []metrics.GaugeValue {
+		var values []metrics.GaugeValue
+		pools.Range(func(k, _ interface{}) bool {
+			p := k.(*PoolProvider)
+			stats := p.Stats()
+			for state, n := range map[string]int{"size": stats.Size, "created": stats.Created, "idle": stats.Idle} {
+				values = append(values, metrics.GaugeValue{
+					Labels: map[string]string{"service": p.url.Path, "group": p.url.Group, "state": state},
+					Value:  float64(n),
+				})
+			}
+			return true
+		})
+		return values
+	})
+}
+
+// ServiceFactory creates a service instance of the PoolProvider
+type ServiceFactory func() (interface{}, error)
+
+// PoolStats is the instances of a PoolProvider
+type PoolStats struct {
+	Size    int // max instances
+	Created int // instances alive
+	Idle    int
+}
+
+type pooledInstance struct {
+	service  interface{}
+	provider *DefaultProvider
+}
+
+func (i *pooledInstance) healthy() bool {
+	if ps, ok := i.service.(motan.PooledService); ok {
+		return ps.Healthy()
+	}
+	return true
+}
+
+func (i *pooledInstance) close() {
+	if ps, ok := i.service.(motan.PooledService); ok {
+		defer motan.HandlePanic(nil)
+		ps.Close()
+	}
+}
+
+// PoolProvider calls each request by a service instance checked out from a pool, for the services can not be called
+// concurrently such as the ones wrapping non thread safe clients or cgo handles. the instances are created by the
+// ServiceFactory set by SetService on demand up to the pool size, and evicted if a call panics or the instance is not
+// healthy, see motan.PooledService. the requests wait for an idle instance up to the wait timeout if the pool is full
+type PoolProvider struct {
+	url         *motan.URL
+	factory     ServiceFactory
+	size        int32
+	waitTimeout time.Duration
+	idle        chan *pooledInstance
+	created     int32
+	closed      int32
+	// the first instance describes the methods
+	prototype *DefaultProvider
+}
+
+// NewPoolProvider creates the initialized pool provider
+func NewPoolProvider(url *motan.URL, factory ServiceFactory) *PoolProvider {
+	p := &PoolProvider{url: url, factory: factory}
+	p.Initialize()
+	return p
+}
+
+func (p *PoolProvider) Initialize() {
+	p.size = int32(p.url.GetPositiveIntValue(motan.ProviderPoolSizeKey, defaultPoolSize))
+	p.waitTimeout = p.url.GetTimeDuration(motan.ProviderPoolWaitTimeoutKey, time.Millisecond, defaultPoolWaitTimeout)
+	p.idle = make(chan *pooledInstance, p.size)
+	if p.factory == nil {
+		vlog.Errorf("can not init pool provider without service factory. url:%v\n", p.url)
+		return
+	}
+	// fails fast if the factory is broken, and the first instance describes the methods
+	inst, err := p.create()
+	if err != nil {
+		vlog.Errorf("create the first instance of pool provider fail. url:%v, err:%v\n", p.url, err)
+		return
+	}
+	p.prototype = inst.provider
+	p.idle <- inst
+	pools.Store(p, struct{}{})
+}
+
+// SetService sets the ServiceFactory of the instances
+func (p *PoolProvider) SetService(s interface{}) {
+	switch f := s.(type) {
+	case ServiceFactory:
+		p.factory = f
+	case func() (interface{}, error):
+		p.factory = f
+	default:
+		vlog.Errorf("service of pool provider must be a ServiceFactory. service:%v\n", s)
+	}
+}
+
+func (p *PoolProvider) GetURL() *motan.URL {
+	return p.url
+}
+
+func (p *PoolProvider) SetURL(url *motan.URL) {
+	p.url = url
+}
+
+func (p *PoolProvider) GetPath() string {
+	return p.url.Path
+}
+
+func (p *PoolProvider) GetName() string {
+	return Pool
+}
+
+func (p *PoolProvider) IsAvailable() bool {
+	return p.factory != nil && atomic.LoadInt32(&p.closed) == 0
+}
+
+// Destroy closes the idle instances, the instances being used are closed when they are returned
+func (p *PoolProvider) Destroy() {
+	atomic.StoreInt32(&p.closed, 1)
+	pools.Delete(p)
+	for {
+		select {
+		case inst := <-p.idle:
+			p.evict(inst, "")
+		default:
+			return
+		}
+	}
+}
+
+// Stats returns the instances of the pool
+func (p *PoolProvider) Stats() PoolStats {
+	return PoolStats{Size: int(p.size), Created: int(atomic.LoadInt32(&p.created)), Idle: len(p.idle)}
+}
+
+// DescribeMethods describes the methods by the first instance
+func (p *PoolProvider) DescribeMethods() []*motan.MethodDescriptor {
+	if p.prototype == nil {
+		return nil
+	}
+	return p.prototype.DescribeMethods()
+}
+
+func (p *PoolProvider) Call(request motan.Request) motan.Response {
+	inst, err := p.checkout()
+	if err != nil {
+		vlog.Warningf("checkout pool provider instance fail. %s, err:%v\n", motan.GetReqInfo(request), err)
+		return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewServerBusyException("provider pool unavailable: "+err.Error()))
+	}
+	panicked := true
+	defer func() {
+		if panicked {
+			// the instance may be broken
+			p.evict(inst, "panic")
+			return
+		}
+		p.checkin(inst)
+	}()
+	res := inst.provider.Call(request)
+	panicked = false
+	return res
+}
+
+func (p *PoolProvider) checkout() (*pooledInstance, error) {
+	var timer *time.Timer
+	var start time.Time
+	for {
+		if atomic.LoadInt32(&p.closed) == 1 {
+			return nil, errPoolClosed
+		}
+		select {
+		case inst := <-p.idle:
+			if p.usable(inst) {
+				p.recordWait(start)
+				return inst, nil
+			}
+			continue
+		default:
+		}
+		if inst, ok, err := p.tryCreate(); ok {
+			return inst, err
+		}
+		if timer == nil {
+			start = time.Now()
+			timer = time.NewTimer(p.waitTimeout)
+			defer timer.Stop()
+		}
+		select {
+		case inst := <-p.idle:
+			if p.usable(inst) {
+				p.recordWait(start)
+				return inst, nil
+			}
+		case <-timer.C:
+			metrics.AddCounter(p.url.Group, p.url.Path, poolMetricKey+".timeout_count", 1)
+			return nil, motan.ErrServerBusy
+		}
+	}
+}
+
+// usable evicts the unhealthy instance
+func (p *PoolProvider) usable(inst *pooledInstance) bool {
+	if inst.healthy() {
+		return true
+	}
+	p.evict(inst, "unhealthy")
+	return false
+}
+
+func (p *PoolProvider) recordWait(start time.Time) {
+	if !start.IsZero() {
+		metrics.AddHistograms(p.url.Group, p.url.Path, poolMetricKey+".wait", int64(time.Since(start)/time.Millisecond))
+	}
+}
+
+// tryCreate creates an instance if the pool is not full, ok is false if it is full
+func (p *PoolProvider) tryCreate() (inst *pooledInstance, ok bool, err error) {
+	for {
+		n := atomic.LoadInt32(&p.created)
+		if n >= p.size {
+			return nil, false, nil
+		}
+		if atomic.CompareAndSwapInt32(&p.created, n, n+1) {
+			break
+		}
+	}
+	inst, err = p.newInstance()
+	if err != nil {
+		atomic.AddInt32(&p.created, -1)
+	}
+	return inst, true, err
+}
+
+// create creates an instance regardless of the pool size
+func (p *PoolProvider) create() (*pooledInstance, error) {
+	atomic.AddInt32(&p.created, 1)
+	inst, err := p.newInstance()
+	if err != nil {
+		atomic.AddInt32(&p.created, -1)
+	}
+	return inst, err
+}
+
+func (p *PoolProvider) newInstance() (inst *pooledInstance, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("service factory panic")
+			vlog.Errorf("service factory of pool provider panic. url:%v, err:%v\n", p.url, r)
+		}
+	}()
+	s, err := p.factory()
+	if err != nil {
+		return nil, err
+	}
+	dp := &DefaultProvider{url: p.url}
+	dp.SetService(s)
+	dp.Initialize()
+	metrics.AddCounter(p.url.Group, p.url.Path, poolMetricKey+".create_count", 1)
+	return &pooledInstance{service: s, provider: dp}, nil
+}
+
+func (p *PoolProvider) checkin(inst *pooledInstance) {
+	if atomic.LoadInt32(&p.closed) == 1 {
+		p.evict(inst, "")
+		return
+	}
+	// never blocks since the instances are not more than the capacity
+	p.idle <- inst
+	if atomic.LoadInt32(&p.closed) == 1 {
+		// destroyed while returning
+		p.Destroy()
+	}
+}
+
+// evict closes the instance, reason is empty if the pool is closed
+func (p *PoolProvider) evict(inst *pooledInstance, reason string) {
+	atomic.AddInt32(&p.created, -1)
+	inst.close()
+	if reason != "" {
+		vlog.Warningf("evict pool provider instance. url:%s, reason:%s\n", p.url.GetIdentity(), reason)
+		metrics.AddCounter(p.url.Group, p.url.Path, poolMetricKey+"."+reason+"_evict_count", 1)
+	}
+}
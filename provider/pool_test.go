@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+type pooledService struct {
+	busy    int32
+	healthy int32
+	closed  *int32
+	block   chan struct{}
+}
+
+func (s *pooledService) Work() (string, error) {
+	if !atomic.CompareAndSwapInt32(&s.busy, 0, 1) {
+		return "", errors.New("called concurrently")
+	}
+	defer atomic.StoreInt32(&s.busy, 0)
+	if s.block != nil {
+		<-s.block
+	}
+	time.Sleep(time.Millisecond)
+	return "ok", nil
+}
+
+func (s *pooledService) Broken() {
+	atomic.StoreInt32(&s.healthy, 0)
+}
+
+func (s *pooledService) Crash() {
+	panic("crash")
+}
+
+func (s *pooledService) Healthy() bool {
+	return atomic.LoadInt32(&s.healthy) == 1
+}
+
+func (s *pooledService) Close() {
+	atomic.AddInt32(s.closed, 1)
+}
+
+func newTestPool(params map[string]string, block chan struct{}) (*PoolProvider, *int32, *int32) {
+	var created, closed int32
+	p := NewPoolProvider(&motan.URL{Path: "pooledService", Parameters: params}, func() (interface{}, error) {
+		atomic.AddInt32(&created, 1)
+		return &pooledService{healthy: 1, closed: &closed, block: block}, nil
+	})
+	return p, &created, &closed
+}
+
+func callPool(p *PoolProvider, method string) motan.Response {
+	return p.Call(&motan.MotanRequest{Method: method, Attachment: motan.NewStringMap(0)})
+}
+
+func TestPoolProvider(t *testing.T) {
+	p, created, _ := newTestPool(map[string]string{motan.ProviderPoolSizeKey: "3"}, nil)
+	if *created != 1 || p.Stats().Idle != 1 {
+		t.Errorf("the first instance should be created when initializing. stats:%+v", p.Stats())
+	}
+	var wg sync.WaitGroup
+	var failed int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if res := callPool(p, "work"); res.GetException() != nil {
+					atomic.AddInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if failed != 0 || atomic.LoadInt32(created) != 3 {
+		t.Errorf("instances should not be used concurrently or more than the pool size. failed:%d, created:%d", failed, *created)
+	}
+	if stats := p.Stats(); stats.Size != 3 || stats.Created != 3 || stats.Idle != 3 {
+		t.Errorf("wrong pool stats. stats:%+v", stats)
+	}
+	methods := p.DescribeMethods()
+	if len(methods) != 3 || methods[0].Name != "Broken" || methods[1].Name != "Crash" || methods[2].Name != "Work" {
+		t.Errorf("the methods of PooledService should not be exposed. methods:%+v", methods)
+	}
+}
+
+func TestPoolProviderWaitTimeout(t *testing.T) {
+	block := make(chan struct{})
+	p, _, _ := newTestPool(map[string]string{motan.ProviderPoolSizeKey: "1", motan.ProviderPoolWaitTimeoutKey: "20"}, block)
+	done := make(chan motan.Response)
+	go func() { done <- callPool(p, "work") }()
+	time.Sleep(10 * time.Millisecond)
+	res := callPool(p, "work")
+	if res.GetException() == nil || res.GetException().ErrCode != 503 {
+		t.Errorf("request should fail if no instance is idle in time. res:%+v", res)
+	}
+	close(block)
+	if res = <-done; res.GetException() != nil {
+		t.Errorf("request holding the instance should succeed. exception:%+v", res.GetException())
+	}
+}
+
+func TestPoolProviderEviction(t *testing.T) {
+	p, created, closed := newTestPool(map[string]string{motan.ProviderPoolSizeKey: "2"}, nil)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("panic of the call should be thrown")
+			}
+		}()
+		callPool(p, "crash")
+	}()
+	if p.Stats().Created != 0 || atomic.LoadInt32(closed) != 1 {
+		t.Errorf("instance should be evicted after panic. stats:%+v, closed:%d", p.Stats(), *closed)
+	}
+
+	callPool(p, "broken")
+	if res := callPool(p, "work"); res.GetException() != nil {
+		t.Errorf("unhealthy instance should be replaced. exception:%+v", res.GetException())
+	}
+	if atomic.LoadInt32(created) != 3 || atomic.LoadInt32(closed) != 2 || p.Stats().Created != 1 {
+		t.Errorf("unhealthy instance should be evicted. created:%d, closed:%d, stats:%+v", *created, *closed, p.Stats())
+	}
+
+	p.Destroy()
+	if atomic.LoadInt32(closed) != 3 || p.IsAvailable() {
+		t.Errorf("idle instances should be closed after destroy. closed:%d", *closed)
+	}
+	if res := callPool(p, "work"); res.GetException() == nil {
+		t.Errorf("destroyed pool should reject requests")
+	}
+}
@@ -1,7 +1,11 @@
 package provider
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
 
 	motan "github.com/weibocom/motan-go/core"
 	"github.com/weibocom/motan-go/log"
@@ -14,6 +18,7 @@ const (
 	MOTAN2  = "motan2"
 	Mock    = "mockProvider"
 	Default = "default"
+	Pool    = "pool"
 )
 
 func RegistDefaultProvider(extFactory motan.ExtensionFactory) {
@@ -37,33 +42,192 @@ func RegistDefaultProvider(extFactory motan.ExtensionFactory) {
 	extFactory.RegistExtProvider(Default, func(url *motan.URL) motan.Provider {
 		return &DefaultProvider{url: url}
 	})
+
+	extFactory.RegistExtProvider(Pool, func(url *motan.URL) motan.Provider {
+		return &PoolProvider{url: url}
+	})
 }
 
+var (
+	streamType  = reflect.TypeOf((*motan.Stream)(nil)).Elem()
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// DefaultProvider calls the exported methods of the service by reflection. a method can take a context.Context
+// as its first parameter and a motan.Stream as its last parameter, the other parameters are deserialized from
+// the arguments of the request. the first result is the response value, and the last error result is returned
+// as a BizException. the service can be a struct or a pointer to a struct, or an Invoker which is called
+// without reflection.
 type DefaultProvider struct {
 	service interface{}
-	methods map[string]reflect.Value
+	invoker Invoker
+	methods map[string]*providerMethod
 	url     *motan.URL
 }
 
+// providerMethod is the binding of a service method computed when the provider initializes
+type providerMethod struct {
+	name        string
+	method      reflect.Value
+	argTypes    []reflect.Type
+	withContext bool
+	withStream  bool
+	valueIndex  int // -1 if the method returns no value
+	errorIndex  int // -1 if the method returns no error
+}
+
+func newProviderMethod(name string, method reflect.Value) *providerMethod {
+	t := method.Type()
+	m := &providerMethod{name: name, method: method, valueIndex: -1, errorIndex: -1}
+	first, last := 0, t.NumIn()
+	if last > 0 && t.In(last-1) == streamType {
+		m.withStream = true
+		last--
+	}
+	if last > 0 && t.In(0) == contextType {
+		m.withContext = true
+		first = 1
+	}
+	for i := first; i < last; i++ {
+		m.argTypes = append(m.argTypes, t.In(i))
+	}
+	if n := t.NumOut(); n > 0 {
+		if t.Out(n-1) == errorType {
+			m.errorIndex = n - 1
+		}
+		if t.Out(0) != errorType || n > 1 {
+			m.valueIndex = 0
+		}
+	}
+	return m
+}
+
+func (m *providerMethod) describe() *motan.MethodDescriptor {
+	md := &motan.MethodDescriptor{Name: m.name, Params: make([]string, 0, len(m.argTypes)), WithContext: m.withContext, Stream: m.withStream}
+	for _, t := range m.argTypes {
+		md.Params = append(md.Params, t.String())
+	}
+	if m.valueIndex >= 0 {
+		md.Result = m.method.Type().Out(m.valueIndex).String()
+	}
+	return md
+}
+
+// bindArgs converts the arguments of the request to the parameters of the method
+func (m *providerMethod) bindArgs(request motan.Request) ([]reflect.Value, error) {
+	rc := request.GetRPCContext(true)
+	if len(m.argTypes) > 0 {
+		values := make([]interface{}, 0, len(m.argTypes))
+		for _, t := range m.argTypes {
+			values = append(values, t)
+		}
+		if err := request.ProcessDeserializable(values); err != nil {
+			return nil, errors.New("deserialize arguments fail." + err.Error())
+		}
+	}
+	args := request.GetArguments()
+	if len(args) > len(m.argTypes) {
+		return nil, fmt.Errorf("method %s takes %d arguments, but %d are given", m.name, len(m.argTypes), len(args))
+	}
+	vs := make([]reflect.Value, 0, len(m.argTypes)+2)
+	if m.withContext {
+		ctx := rc.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		vs = append(vs, reflect.ValueOf(&ctx).Elem())
+	}
+	for i, t := range m.argTypes {
+		// missing arguments are zero values
+		var arg interface{}
+		if i < len(args) {
+			arg = args[i]
+		}
+		v, err := argValue(arg, t)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d of method %s %s", i, m.name, err.Error())
+		}
+		vs = append(vs, v)
+	}
+	if m.withStream {
+		vs = append(vs, reflect.ValueOf(rc.Stream))
+	}
+	return vs, nil
+}
+
+// argValue converts the argument to the parameter type, nil is the zero value
+func argValue(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(t), nil
+	}
+	v := reflect.ValueOf(arg)
+	if !v.Type().AssignableTo(t) {
+		if !v.Type().ConvertibleTo(t) {
+			return v, fmt.Errorf("should be %v, but is %v", t, v.Type())
+		}
+		v = v.Convert(t)
+	}
+	return v, nil
+}
+
 func (d *DefaultProvider) Initialize() {
-	d.methods = make(map[string]reflect.Value, 32)
-	if d.service != nil && d.url != nil {
+	d.methods = make(map[string]*providerMethod, 32)
+	if invoker, ok := d.service.(Invoker); ok && d.url != nil {
+		d.invoker = invoker
+	} else if d.service != nil && d.url != nil {
 		v := reflect.ValueOf(d.service)
+		if v.Kind() == reflect.Struct {
+			// the methods with pointer receivers are available on the pointer only
+			ptr := reflect.New(v.Type())
+			ptr.Elem().Set(v)
+			v = ptr
+		}
 		if v.Kind() != reflect.Ptr {
-			vlog.Errorf("can not init provider. service is not a pointer. service :%v, url:%v\n", d.service, d.url)
+			vlog.Errorf("can not init provider. service is not a struct or a pointer. service :%v, url:%v\n", d.service, d.url)
 			return
 		}
+		// the methods of the lifecycle interfaces are not exposed
+		hidden := make(map[string]bool)
+		if _, ok := v.Interface().(motan.WarmUpService); ok {
+			hidden["WarmUp"] = true
+		}
+		if _, ok := v.Interface().(motan.PooledService); ok {
+			hidden["Healthy"] = true
+			hidden["Close"] = true
+		}
 		for i := 0; i < v.NumMethod(); i++ {
 			name := v.Type().Method(i).Name
-			vm := v.MethodByName(name)
-			d.methods[name] = vm
+			if hidden[name] {
+				continue
+			}
+			d.methods[name] = newProviderMethod(name, v.Method(i))
+		}
+		if len(d.methods) == 0 {
+			vlog.Warningf("no exported method in provider. service :%v, url:%v\n", d.service, d.url)
 		}
-
 	} else {
 		vlog.Errorf("can not init provider. service :%v, url:%v\n", d.service, d.url)
 	}
 }
 
+// DescribeMethods describes the methods of the service sorted by name, the invoker describes the methods
+// if it implements motan.MethodDescriber
+func (d *DefaultProvider) DescribeMethods() []*motan.MethodDescriptor {
+	if d.invoker != nil {
+		if md, ok := d.invoker.(motan.MethodDescriber); ok {
+			return md.DescribeMethods()
+		}
+		return nil
+	}
+	methods := make([]*motan.MethodDescriptor, 0, len(d.methods))
+	for _, m := range d.methods {
+		methods = append(methods, m.describe())
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods
+}
+
 func (d *DefaultProvider) SetService(s interface{}) {
 	d.service = s
 }
@@ -87,32 +251,30 @@ func (d *DefaultProvider) IsAvailable() bool {
 func (d *DefaultProvider) Destroy() {}
 
 func (d *DefaultProvider) Call(request motan.Request) (res motan.Response) {
+	if d.invoker != nil {
+		return d.invoker.Invoke(request)
+	}
 	m, exit := d.methods[motan.FirstUpper(request.GetMethod())]
 	if !exit {
-		vlog.Errorf("method not found in provider. %s\n", motan.GetReqInfo(request))
-		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "method " + request.GetMethod() + " is not found in provider.", ErrType: motan.ServiceException})
+		return MethodNotFound(request)
 	}
-
-	inNum := m.Type().NumIn()
-	if inNum > 0 {
-		values := make([]interface{}, 0, inNum)
-		for i := 0; i < inNum; i++ {
-			values = append(values, m.Type().In(i))
-		}
-		err := request.ProcessDeserializable(values)
-		if err != nil {
-			return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "deserialize arguments fail." + err.Error(), ErrType: motan.ServiceException})
-		}
+	isStream := request.GetRPCContext(true).Stream != nil
+	if isStream != m.withStream {
+		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "method " + request.GetMethod() + " does not match the streaming call.", ErrType: motan.ServiceException})
 	}
-
-	vs := make([]reflect.Value, 0, len(request.GetArguments()))
-	for _, arg := range request.GetArguments() {
-		vs = append(vs, reflect.ValueOf(arg))
+	vs, err := m.bindArgs(request)
+	if err != nil {
+		return BuildArgsErrorResponse(request, err)
 	}
-	ret := m.Call(vs)
+	ret := m.method.Call(vs)
 	mres := &motan.MotanResponse{RequestID: request.GetRequestID()}
-	if len(ret) > 0 { // only use first return value.
-		mres.Value = ret[0]
+	if m.errorIndex >= 0 && !ret[m.errorIndex].IsNil() {
+		mres.Exception = motan.NewBizException(ret[m.errorIndex].Interface().(error).Error())
+		return mres
+	}
+	// messages are sent by the stream, only the error result is used
+	if !isStream && m.valueIndex >= 0 {
+		mres.Value = ret[m.valueIndex]
 	}
 	return mres
 }
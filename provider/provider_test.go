@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+type ctxKey struct{}
+
+type contextService struct{}
+
+func (c *contextService) Hello(ctx context.Context, name string) string {
+	v, _ := ctx.Value(ctxKey{}).(string)
+	return v + name
+}
+
+func (c *contextService) Ping(ctx context.Context) bool {
+	return ctx != nil
+}
+
+func TestDefaultProviderContext(t *testing.T) {
+	p := &DefaultProvider{}
+	p.SetURL(&motan.URL{Path: "contextService"})
+	p.SetService(&contextService{})
+	p.Initialize()
+
+	request := &motan.MotanRequest{Method: "hello", Arguments: []interface{}{"motan"}, Attachment: motan.NewStringMap(0)}
+	request.GetRPCContext(true).Context = context.WithValue(context.Background(), ctxKey{}, "hello ")
+	res := p.Call(request)
+	if res.GetException() != nil || res.GetValue().(interface{ String() string }).String() != "hello motan" {
+		t.Errorf("call with context fail. res:%+v", res)
+	}
+
+	// a background context is used if the request has none
+	res = p.Call(&motan.MotanRequest{Method: "ping", Attachment: motan.NewStringMap(0)})
+	if res.GetException() != nil {
+		t.Errorf("call without context fail. res:%+v", res)
+	}
+}
+
+type bindService struct {
+	prefix string
+}
+
+func (b bindService) Repeat(ctx context.Context, s string, n int) (string, error) {
+	if n < 0 {
+		return "", errors.New("negative count")
+	}
+	return b.prefix + strings.Repeat(s, n), nil
+}
+
+func (b *bindService) Check(n int32) error {
+	if n == 0 {
+		return errors.New("zero")
+	}
+	return nil
+}
+
+func TestDefaultProviderBinding(t *testing.T) {
+	p := &DefaultProvider{}
+	p.SetURL(&motan.URL{Path: "bindService"})
+	// methods with pointer receivers are available for struct services too
+	p.SetService(bindService{prefix: ">"})
+	p.Initialize()
+	m := p.methods["Repeat"]
+	if m == nil || p.methods["Check"] == nil || !m.withContext || m.withStream || len(m.argTypes) != 2 || m.valueIndex != 0 || m.errorIndex != 1 {
+		t.Fatalf("wrong method binding. method:%+v", m)
+	}
+
+	// arguments are converted to the parameter types
+	res := p.Call(&motan.MotanRequest{Method: "repeat", Arguments: []interface{}{"ab", int64(2)}, Attachment: motan.NewStringMap(0)})
+	if res.GetException() != nil || res.GetValue().(reflect.Value).String() != ">abab" {
+		t.Errorf("call with converted arguments fail. res:%+v", res)
+	}
+	// missing arguments are zero values
+	res = p.Call(&motan.MotanRequest{Method: "repeat", Arguments: []interface{}{"ab"}, Attachment: motan.NewStringMap(0)})
+	if res.GetException() != nil || res.GetValue().(reflect.Value).String() != ">" {
+		t.Errorf("call with missing arguments fail. res:%+v", res)
+	}
+	// the error result is a biz exception
+	res = p.Call(&motan.MotanRequest{Method: "repeat", Arguments: []interface{}{"ab", -1}, Attachment: motan.NewStringMap(0)})
+	if res.GetException() == nil || res.GetException().ErrType != motan.BizException || res.GetException().ErrMsg != "negative count" {
+		t.Errorf("error result should be a biz exception. res:%+v", res)
+	}
+	res = p.Call(&motan.MotanRequest{Method: "check", Arguments: []interface{}{1}, Attachment: motan.NewStringMap(0)})
+	if res.GetException() != nil || res.GetValue() != nil {
+		t.Errorf("method returning error only should have no value. res:%+v", res)
+	}
+	res = p.Call(&motan.MotanRequest{Method: "check", Arguments: []interface{}{0}, Attachment: motan.NewStringMap(0)})
+	if res.GetException() == nil || res.GetException().ErrMsg != "zero" {
+		t.Errorf("error result should be returned. res:%+v", res)
+	}
+
+	// type mismatched or too many arguments
+	for _, args := range [][]interface{}{{"ab", "2"}, {"ab", 2, 3}} {
+		res = p.Call(&motan.MotanRequest{Method: "repeat", Arguments: args, Attachment: motan.NewStringMap(0)})
+		if res.GetException() == nil || res.GetException().ErrType != motan.ServiceException {
+			t.Errorf("wrong arguments should be rejected. args:%v, res:%+v", args, res)
+		}
+	}
+}
+
+func TestDefaultProviderDescribeMethods(t *testing.T) {
+	p := &DefaultProvider{}
+	p.SetURL(&motan.URL{Path: "bindService"})
+	p.SetService(&bindService{})
+	p.Initialize()
+	methods := p.DescribeMethods()
+	if len(methods) != 2 {
+		t.Fatalf("wrong methods. methods:%+v", methods)
+	}
+	check, repeat := methods[0], methods[1]
+	if check.Name != "Check" || strings.Join(check.Params, ",") != "int32" || check.Result != "" || check.WithContext {
+		t.Errorf("wrong method descriptor. method:%+v", check)
+	}
+	if repeat.Name != "Repeat" || strings.Join(repeat.Params, ",") != "string,int" || repeat.Result != "string" || !repeat.WithContext {
+		t.Errorf("wrong method descriptor. method:%+v", repeat)
+	}
+}
@@ -40,6 +40,7 @@ func (d *DirectRegistry) Discover(url *motan.URL) []*motan.URL {
 		newURL := *url
 		newURL.Host = u.Host
 		newURL.Port = u.Port
+		newURL.ClearCachedInfo()
 		result = append(result, &newURL)
 	}
 	return result
@@ -62,10 +63,14 @@ func (d *DirectRegistry) GetRegisteredServices() []*motan.URL {
 func (d *DirectRegistry) StartSnapshot(conf *motan.SnapshotConf) {}
 func parseURLs(url *motan.URL) []*motan.URL {
 	urls := make([]*motan.URL, 0)
-	if len(url.Host) > 0 && url.Port > 0 {
+	if len(url.Host) > 0 && (url.Port > 0 || url.IsUnixSocket()) {
 		urls = append(urls, url)
 	} else if address, exist := url.Parameters[motan.AddressKey]; exist {
 		for _, add := range strings.Split(address, ",") {
+			if add = strings.TrimSpace(add); strings.HasPrefix(add, motan.UnixSocketPrefix) {
+				urls = append(urls, &motan.URL{Host: add})
+				continue
+			}
 			hostport := motan.TrimSplit(add, ":")
 			if len(hostport) == 2 {
 				port, err := strconv.Atoi(hostport[1])
@@ -66,3 +66,19 @@ func TestMultiAddress(t *testing.T) {
 		}
 	}
 }
+
+func TestUnixSocketAddress(t *testing.T) {
+	params := make(map[string]string)
+	params["address"] = "unix:/tmp/motan.sock,127.0.0.1:8002"
+	registry := &DirectRegistry{url: &motan.URL{Parameters: params}}
+	urls := registry.Discover(&motan.URL{Protocol: "motan2", Host: "10.210.230.10", Port: 8999})
+	if len(urls) != 2 {
+		t.Fatalf("discover size should be 2. size: %d", len(urls))
+	}
+	if !urls[0].IsUnixSocket() || urls[0].GetUnixSocketPath() != "/tmp/motan.sock" || urls[0].GetAddressStr() != "unix:/tmp/motan.sock" {
+		t.Fatalf("discover unix socket url not correct. url: %+v", urls[0])
+	}
+	if urls[1].IsUnixSocket() || urls[1].GetAddressStr() != "127.0.0.1:8002" {
+		t.Fatalf("discover tcp url not correct. url: %+v", urls[1])
+	}
+}
@@ -26,6 +26,7 @@ const (
 	Consul = "consul"
 	ZK     = "zookeeper"
 	Mesh   = "mesh"
+	Xds    = "xds"
 )
 
 type SnapshotNodeInfo struct {
@@ -107,6 +108,10 @@ func RegistDefaultRegistry(extFactory motan.ExtensionFactory) {
 	extFactory.RegistExtRegistry(Mesh, func(url *motan.URL) motan.Registry {
 		return &MeshRegistry{url: url}
 	})
+
+	extFactory.RegistExtRegistry(Xds, func(url *motan.URL) motan.Registry {
+		return &XdsRegistry{url: url}
+	})
 }
 
 func IsAgent(url *motan.URL) bool {
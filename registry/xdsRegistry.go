@@ -0,0 +1,535 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// xds registry url params
+const (
+	XdsClusterFormatKey = "clusterFormat" // the xDS cluster name of a service, {group} and {path} are replaced
+	XdsRouteConfigKey   = "routeConfig"   // the RDS route configuration of the weighted groups, routes are not used if not set
+	XdsNodeIDKey        = "nodeId"        // the local ip by default
+	XdsNodeClusterKey   = "nodeCluster"   // the application by default
+	XdsPollIntervalKey  = "pollInterval"  // ms
+)
+
+const (
+	defaultXdsClusterFormat = "{path}|{group}"
+	defaultXdsPollInterval  = 5000 // ms
+
+	xdsTypeCluster  = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	xdsTypeEndpoint = "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment"
+	xdsTypeRoute    = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+)
+
+var xdsDiscoveryPaths = map[string]string{
+	xdsTypeCluster:  "/v3/discovery:clusters",
+	xdsTypeEndpoint: "/v3/discovery:endpoints",
+	xdsTypeRoute:    "/v3/discovery:routes",
+}
+
+type xdsNode struct {
+	ID      string `json:"id"`
+	Cluster string `json:"cluster"`
+}
+
+type xdsDiscoveryRequest struct {
+	Node          xdsNode  `json:"node"`
+	ResourceNames []string `json:"resourceNames,omitempty"`
+	TypeURL       string   `json:"typeUrl"`
+}
+
+type xdsDiscoveryResponse struct {
+	VersionInfo string            `json:"versionInfo"`
+	Resources   []json.RawMessage `json:"resources"`
+}
+
+type xdsCluster struct {
+	Name string `json:"name"`
+}
+
+type xdsClusterLoadAssignment struct {
+	ClusterName string `json:"clusterName"`
+	Endpoints   []struct {
+		LbEndpoints []struct {
+			Endpoint struct {
+				Address struct {
+					SocketAddress struct {
+						Address   string `json:"address"`
+						PortValue int    `json:"portValue"`
+					} `json:"socketAddress"`
+				} `json:"address"`
+			} `json:"endpoint"`
+			HealthStatus string `json:"healthStatus"`
+		} `json:"lbEndpoints"`
+	} `json:"endpoints"`
+}
+
+type xdsRouteConfiguration struct {
+	Name         string `json:"name"`
+	VirtualHosts []struct {
+		Domains []string `json:"domains"`
+		Routes  []struct {
+			Route struct {
+				Cluster          string `json:"cluster"`
+				WeightedClusters struct {
+					Clusters []struct {
+						Name   string `json:"name"`
+						Weight int    `json:"weight"`
+					} `json:"clusters"`
+				} `json:"weightedClusters"`
+			} `json:"route"`
+		} `json:"routes"`
+	} `json:"virtualHosts"`
+}
+
+type xdsSubscription struct {
+	url       *motan.URL
+	listeners map[motan.NotifyListener]*motan.URL
+	nodes     string // the sorted addresses last notified
+}
+
+type xdsCommandSubscription struct {
+	listeners map[motan.CommandNotifyListener]*motan.URL
+	command   string
+}
+
+// XdsRegistry discovers the endpoints, the groups and the weighted routes of the services from the xDS control planes
+// compatible with Istio, by the REST-JSON transport of the xDS v3 api. the xDS cluster of a service is named by the
+// param clusterFormat, and the weighted clusters of the routes in the param routeConfig are the traffic control
+// commands of the services. the subscribed clusters and routes are polled in the interval
+type XdsRegistry struct {
+	url            *motan.URL
+	client         *http.Client
+	node           xdsNode
+	format         string
+	clusterPattern *regexp.Regexp
+	routeConfig    string
+	interval       time.Duration
+	lock           sync.Mutex
+	services       map[string]*xdsSubscription        // xDS cluster name -> subscription
+	commands       map[string]*xdsCommandSubscription // service path -> subscription
+	startPolling   sync.Once
+}
+
+func (r *XdsRegistry) Initialize() {
+	r.format = r.url.GetParam(XdsClusterFormatKey, defaultXdsClusterFormat)
+	pattern := strings.NewReplacer(`\{group\}`, `(?P<group>.+?)`, `\{path\}`, `(?P<path>.+?)`).Replace(regexp.QuoteMeta(r.format))
+	r.clusterPattern = regexp.MustCompile("^" + pattern + "$")
+	r.routeConfig = r.url.GetParam(XdsRouteConfigKey, "")
+	r.node = xdsNode{ID: r.url.GetParam(XdsNodeIDKey, motan.GetLocalIP()), Cluster: r.url.GetParam(XdsNodeClusterKey, r.url.GetParam(motan.ApplicationKey, ""))}
+	r.interval = time.Duration(r.url.GetPositiveIntValue(XdsPollIntervalKey, defaultXdsPollInterval)) * time.Millisecond
+	r.client = &http.Client{Timeout: time.Duration(r.url.GetPositiveIntValue(motan.TimeOutKey, DefaultTimeout)) * time.Millisecond}
+	r.services = make(map[string]*xdsSubscription)
+	r.commands = make(map[string]*xdsCommandSubscription)
+}
+
+func (r *XdsRegistry) GetURL() *motan.URL {
+	return r.url
+}
+
+func (r *XdsRegistry) SetURL(url *motan.URL) {
+	r.url = url
+}
+
+func (r *XdsRegistry) GetName() string {
+	return Xds
+}
+
+// ClusterName returns the xDS cluster name of the service
+func (r *XdsRegistry) ClusterName(url *motan.URL) string {
+	return strings.NewReplacer("{group}", url.Group, "{path}", url.Path).Replace(r.format)
+}
+
+// parseGroup returns the group of the xDS cluster name if the cluster is of the service path, any path is matched if
+// the path is empty
+func (r *XdsRegistry) parseGroup(name string, path string) (string, bool) {
+	m := r.clusterPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", false
+	}
+	group := ""
+	for i, n := range r.clusterPattern.SubexpNames() {
+		switch n {
+		case "group":
+			group = m[i]
+		case "path":
+			if path != "" && m[i] != path {
+				return "", false
+			}
+		}
+	}
+	return group, group != ""
+}
+
+func (r *XdsRegistry) Subscribe(url *motan.URL, listener motan.NotifyListener) {
+	name := r.ClusterName(url)
+	r.lock.Lock()
+	s, ok := r.services[name]
+	if !ok {
+		s = &xdsSubscription{url: url, listeners: make(map[motan.NotifyListener]*motan.URL)}
+		r.services[name] = s
+	}
+	s.listeners[listener] = url
+	r.lock.Unlock()
+	vlog.Infof("[XdsRegistry] subscribe service. cluster:%s, listener:%s\n", name, listener.GetIdentity())
+	r.startPolling.Do(func() { go r.poll() })
+}
+
+func (r *XdsRegistry) Unsubscribe(url *motan.URL, listener motan.NotifyListener) {
+	name := r.ClusterName(url)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if s, ok := r.services[name]; ok {
+		delete(s.listeners, listener)
+		if len(s.listeners) == 0 {
+			delete(r.services, name)
+		}
+	}
+}
+
+func (r *XdsRegistry) Discover(url *motan.URL) []*motan.URL {
+	name := r.ClusterName(url)
+	assignments, err := r.fetchEndpoints([]string{name})
+	if err != nil {
+		vlog.Errorf("[XdsRegistry] discover service fail. cluster:%s, err:%v\n", name, err)
+		return nil
+	}
+	return buildXdsURLs(url, assignments[name])
+}
+
+// DiscoverAllGroups returns the groups of all the xDS clusters
+func (r *XdsRegistry) DiscoverAllGroups() ([]string, error) {
+	resources, err := r.fetch(xdsTypeCluster, nil)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(resources))
+	found := make(map[string]bool)
+	for _, res := range resources {
+		c := &xdsCluster{}
+		if json.Unmarshal(res, c) != nil {
+			continue
+		}
+		if g, ok := r.parseGroup(c.Name, ""); ok && !found[g] {
+			found[g] = true
+			groups = append(groups, g)
+		}
+	}
+	return groups, nil
+}
+
+func (r *XdsRegistry) SubscribeCommand(url *motan.URL, listener motan.CommandNotifyListener) {
+	if r.routeConfig == "" {
+		return
+	}
+	r.lock.Lock()
+	s, ok := r.commands[url.Path]
+	if !ok {
+		s = &xdsCommandSubscription{listeners: make(map[motan.CommandNotifyListener]*motan.URL)}
+		r.commands[url.Path] = s
+	}
+	s.listeners[listener] = url
+	r.lock.Unlock()
+	r.startPolling.Do(func() { go r.poll() })
+}
+
+func (r *XdsRegistry) UnSubscribeCommand(url *motan.URL, listener motan.CommandNotifyListener) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if s, ok := r.commands[url.Path]; ok {
+		delete(s.listeners, listener)
+		if len(s.listeners) == 0 {
+			delete(r.commands, url.Path)
+		}
+	}
+}
+
+// DiscoverCommand returns the traffic control command of the weighted clusters of the route of the service
+func (r *XdsRegistry) DiscoverCommand(url *motan.URL) string {
+	if r.routeConfig == "" {
+		return ""
+	}
+	config, err := r.fetchRouteConfig()
+	if err != nil {
+		vlog.Errorf("[XdsRegistry] discover route fail. route config:%s, err:%v\n", r.routeConfig, err)
+		return ""
+	}
+	return r.buildCommand(config, url.Path)
+}
+
+func (r *XdsRegistry) poll() {
+	defer motan.HandlePanic(nil)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.pollEndpoints()
+		r.pollRoutes()
+	}
+}
+
+func (r *XdsRegistry) pollEndpoints() {
+	r.lock.Lock()
+	names := make([]string, 0, len(r.services))
+	for name := range r.services {
+		names = append(names, name)
+	}
+	r.lock.Unlock()
+	if len(names) == 0 {
+		return
+	}
+	assignments, err := r.fetchEndpoints(names)
+	if err != nil {
+		vlog.Errorf("[XdsRegistry] poll endpoints fail. err:%v\n", err)
+		return
+	}
+	for _, name := range names {
+		r.lock.Lock()
+		s, ok := r.services[name]
+		if !ok {
+			r.lock.Unlock()
+			continue
+		}
+		urls := buildXdsURLs(s.url, assignments[name])
+		nodes := make([]string, 0, len(urls))
+		for _, u := range urls {
+			nodes = append(nodes, u.GetAddressStr())
+		}
+		sort.Strings(nodes)
+		changed := strings.Join(nodes, ",") != s.nodes
+		s.nodes = strings.Join(nodes, ",")
+		listeners := make(map[motan.NotifyListener]*motan.URL, len(s.listeners))
+		for l, u := range s.listeners {
+			listeners[l] = u
+		}
+		r.lock.Unlock()
+		if changed {
+			vlog.Infof("[XdsRegistry] endpoints changed. cluster:%s, endpoints:%d\n", name, len(urls))
+			for l, u := range listeners {
+				l.Notify(r.url, buildXdsURLs(u, assignments[name]))
+			}
+		}
+	}
+}
+
+func (r *XdsRegistry) pollRoutes() {
+	r.lock.Lock()
+	empty := len(r.commands) == 0
+	r.lock.Unlock()
+	if r.routeConfig == "" || empty {
+		return
+	}
+	config, err := r.fetchRouteConfig()
+	if err != nil {
+		vlog.Errorf("[XdsRegistry] poll routes fail. route config:%s, err:%v\n", r.routeConfig, err)
+		return
+	}
+	type notification struct {
+		listener motan.CommandNotifyListener
+		command  string
+	}
+	notifications := make([]notification, 0)
+	r.lock.Lock()
+	for path, s := range r.commands {
+		command := r.buildCommand(config, path)
+		if command == s.command {
+			continue
+		}
+		s.command = command
+		for l := range s.listeners {
+			notifications = append(notifications, notification{l, command})
+		}
+	}
+	r.lock.Unlock()
+	for _, n := range notifications {
+		n.listener.NotifyCommand(r.url, cluster.ServiceCmd, n.command)
+	}
+}
+
+// buildCommand returns the traffic control command of the first route of the virtual host of the domain path, or of
+// the domain * if no host has the domain path. the route to a cluster of the service is the command of the group,
+// and the route to the weighted clusters is the command of the weighted groups
+func (r *XdsRegistry) buildCommand(config *xdsRouteConfiguration, path string) string {
+	if config == nil {
+		return ""
+	}
+	hostIndex := -1
+	for i, vh := range config.VirtualHosts {
+		for _, d := range vh.Domains {
+			if d == path {
+				hostIndex = i
+			} else if d == "*" && hostIndex < 0 {
+				hostIndex = i
+			}
+		}
+	}
+	if hostIndex < 0 {
+		return ""
+	}
+	groups := make([]string, 0, 4)
+	for _, route := range config.VirtualHosts[hostIndex].Routes {
+		if g, ok := r.parseGroup(route.Route.Cluster, path); ok {
+			groups = append(groups, g)
+		}
+		for _, c := range route.Route.WeightedClusters.Clusters {
+			if g, ok := r.parseGroup(c.Name, path); ok {
+				groups = append(groups, g+":"+strconv.Itoa(c.Weight))
+			}
+		}
+		if len(groups) > 0 {
+			break
+		}
+	}
+	if len(groups) == 0 {
+		return ""
+	}
+	command := &cluster.Command{ClientCommandList: []cluster.ClientCommand{{Index: 1, Version: "xds", CommandType: cluster.CMDTrafficControl,
+		Pattern: path, MergeGroups: groups, Remark: "xds route " + config.Name}}}
+	b, _ := json.Marshal(command)
+	return string(b)
+}
+
+func (r *XdsRegistry) fetchEndpoints(names []string) (map[string]*xdsClusterLoadAssignment, error) {
+	resources, err := r.fetch(xdsTypeEndpoint, names)
+	if err != nil {
+		return nil, err
+	}
+	assignments := make(map[string]*xdsClusterLoadAssignment, len(resources))
+	for _, res := range resources {
+		a := &xdsClusterLoadAssignment{}
+		if err = json.Unmarshal(res, a); err != nil {
+			return nil, err
+		}
+		assignments[a.ClusterName] = a
+	}
+	return assignments, nil
+}
+
+func (r *XdsRegistry) fetchRouteConfig() (*xdsRouteConfiguration, error) {
+	resources, err := r.fetch(xdsTypeRoute, []string{r.routeConfig})
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range resources {
+		c := &xdsRouteConfiguration{}
+		if err = json.Unmarshal(res, c); err != nil {
+			return nil, err
+		}
+		if c.Name == r.routeConfig {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// fetch requests the resources of the type, the resources are converted to the lower camel case json
+func (r *XdsRegistry) fetch(typeURL string, names []string) ([]json.RawMessage, error) {
+	body, _ := json.Marshal(&xdsDiscoveryRequest{Node: r.node, ResourceNames: names, TypeURL: typeURL})
+	res, err := r.client.Post("http://"+r.url.GetAddressStr()+xdsDiscoveryPaths[typeURL], "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("xds discovery fail. status:%d, body:%s", res.StatusCode, data)
+	}
+	var v interface{}
+	if err = json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	data, _ = json.Marshal(lowerCamelKeys(v))
+	response := &xdsDiscoveryResponse{}
+	if err = json.Unmarshal(data, response); err != nil {
+		return nil, err
+	}
+	if response.Resources == nil {
+		return nil, errors.New("xds discovery response has no resources")
+	}
+	return response.Resources, nil
+}
+
+// lowerCamelKeys converts the snake case keys of the proto json to the lower camel case, both are valid proto json
+func lowerCamelKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			parts := strings.Split(k, "_")
+			for i := 1; i < len(parts); i++ {
+				if parts[i] != "" {
+					r := []rune(parts[i])
+					r[0] = unicode.ToUpper(r[0])
+					parts[i] = string(r)
+				}
+			}
+			m[strings.Join(parts, "")] = lowerCamelKeys(v)
+		}
+		return m
+	case []interface{}:
+		for i, v := range t {
+			t[i] = lowerCamelKeys(v)
+		}
+	}
+	return v
+}
+
+func buildXdsURLs(url *motan.URL, assignment *xdsClusterLoadAssignment) []*motan.URL {
+	result := make([]*motan.URL, 0)
+	if assignment == nil {
+		return result
+	}
+	for _, locality := range assignment.Endpoints {
+		for _, e := range locality.LbEndpoints {
+			switch e.HealthStatus {
+			case "", "UNKNOWN", "HEALTHY", "DEGRADED":
+			default:
+				continue
+			}
+			address := e.Endpoint.Address.SocketAddress
+			newURL := url.Copy()
+			newURL.Host = address.Address
+			newURL.Port = address.PortValue
+			result = append(result, newURL)
+		}
+	}
+	return result
+}
+
+func (r *XdsRegistry) Register(serverURL *motan.URL) {
+	vlog.Warningf("[XdsRegistry] services are registered by the control plane, register is ignored. url:%s\n", serverURL.GetIdentity())
+}
+
+func (r *XdsRegistry) UnRegister(serverURL *motan.URL) {
+}
+
+func (r *XdsRegistry) Available(serverURL *motan.URL) {
+}
+
+func (r *XdsRegistry) Unavailable(serverURL *motan.URL) {
+}
+
+func (r *XdsRegistry) GetRegisteredServices() []*motan.URL {
+	return nil
+}
+
+func (r *XdsRegistry) StartSnapshot(conf *motan.SnapshotConf) {}
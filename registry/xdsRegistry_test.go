@@ -0,0 +1,159 @@
+package registry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+)
+
+type testXdsServer struct {
+	lock      sync.Mutex
+	endpoints map[string][]string // cluster -> host:port
+	weights   map[string]int      // cluster -> weight of the route
+}
+
+func (s *testXdsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	req := &xdsDiscoveryRequest{}
+	json.Unmarshal(body, req)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	resources := make([]interface{}, 0)
+	switch r.URL.Path {
+	case "/v3/discovery:clusters":
+		for name := range s.endpoints {
+			resources = append(resources, map[string]interface{}{"@type": xdsTypeCluster, "name": name})
+		}
+	case "/v3/discovery:endpoints":
+		// snake case as the envoy rest api
+		for _, name := range req.ResourceNames {
+			lbEndpoints := make([]interface{}, 0)
+			for _, address := range s.endpoints[name] {
+				host, port := address[:strings.Index(address, ":")], address[strings.Index(address, ":")+1:]
+				p, _ := strconv.Atoi(port)
+				lbEndpoints = append(lbEndpoints, map[string]interface{}{
+					"endpoint":      map[string]interface{}{"address": map[string]interface{}{"socket_address": map[string]interface{}{"address": host, "port_value": p}}},
+					"health_status": "HEALTHY",
+				})
+			}
+			lbEndpoints = append(lbEndpoints, map[string]interface{}{
+				"endpoint":      map[string]interface{}{"address": map[string]interface{}{"socket_address": map[string]interface{}{"address": "10.0.0.99", "port_value": 8100}}},
+				"health_status": "UNHEALTHY",
+			})
+			resources = append(resources, map[string]interface{}{"@type": xdsTypeEndpoint, "cluster_name": name, "endpoints": []interface{}{map[string]interface{}{"lb_endpoints": lbEndpoints}}})
+		}
+	case "/v3/discovery:routes":
+		// lower camel case as the proto json
+		clusters := make([]interface{}, 0)
+		for name, weight := range s.weights {
+			clusters = append(clusters, map[string]interface{}{"name": name, "weight": weight})
+		}
+		resources = append(resources, map[string]interface{}{"@type": xdsTypeRoute, "name": "motan-routes", "virtualHosts": []interface{}{
+			map[string]interface{}{"domains": []string{"*"}, "routes": []interface{}{map[string]interface{}{"route": map[string]interface{}{"weightedClusters": map[string]interface{}{"clusters": clusters}}}}},
+		}})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"version_info": "1", "resources": resources})
+}
+
+type testXdsListener struct {
+	lock     sync.Mutex
+	urls     []*motan.URL
+	commands []string
+}
+
+func (l *testXdsListener) GetIdentity() string {
+	return "testXdsListener"
+}
+
+func (l *testXdsListener) Notify(registryURL *motan.URL, urls []*motan.URL) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.urls = urls
+}
+
+func (l *testXdsListener) NotifyCommand(registryURL *motan.URL, commandType int, commandInfo string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.commands = append(l.commands, commandInfo)
+}
+
+func (l *testXdsListener) addresses() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	addresses := make([]string, 0, len(l.urls))
+	for _, u := range l.urls {
+		addresses = append(addresses, u.GetAddressStr())
+	}
+	sort.Strings(addresses)
+	return strings.Join(addresses, ",")
+}
+
+func TestXdsRegistry(t *testing.T) {
+	s := &testXdsServer{
+		endpoints: map[string][]string{"com.weibo.TestService|group-a": {"10.0.0.1:8100", "10.0.0.2:8100"}, "com.weibo.TestService|group-b": {"10.0.0.3:8100"}},
+		weights:   map[string]int{"com.weibo.TestService|group-a": 80, "com.weibo.TestService|group-b": 20},
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	addr := server.Listener.Addr().(*net.TCPAddr)
+
+	factory := &motan.DefaultExtensionFactory{}
+	factory.Initialize()
+	RegistDefaultRegistry(factory)
+	registryURL := &motan.URL{Protocol: Xds, Host: addr.IP.String(), Port: addr.Port, Parameters: map[string]string{XdsRouteConfigKey: "motan-routes", XdsPollIntervalKey: "20"}}
+	r := factory.GetRegistry(registryURL).(*XdsRegistry)
+
+	url := &motan.URL{Protocol: "motan2", Path: "com.weibo.TestService", Group: "group-a"}
+	if name := r.ClusterName(url); name != "com.weibo.TestService|group-a" {
+		t.Errorf("wrong cluster name: %s", name)
+	}
+	urls := r.Discover(url)
+	if len(urls) != 2 || urls[0].Protocol != "motan2" || urls[0].Group != "group-a" || urls[0].Port != 8100 {
+		t.Fatalf("wrong discovered urls: %v", urls)
+	}
+	groups, err := r.DiscoverAllGroups()
+	sort.Strings(groups)
+	if err != nil || strings.Join(groups, ",") != "group-a,group-b" {
+		t.Errorf("wrong discovered groups: %v, err:%v", groups, err)
+	}
+	command := cluster.ParseCommand(r.DiscoverCommand(url))
+	if command == nil || len(command.ClientCommandList) != 1 || command.ClientCommandList[0].CommandType != cluster.CMDTrafficControl {
+		t.Fatalf("wrong discovered command: %+v", command)
+	}
+	mergeGroups := command.ClientCommandList[0].MergeGroups
+	sort.Strings(mergeGroups)
+	if strings.Join(mergeGroups, ",") != "group-a:80,group-b:20" {
+		t.Errorf("wrong merge groups of the command: %v", mergeGroups)
+	}
+
+	listener := &testXdsListener{}
+	r.Subscribe(url, listener)
+	r.SubscribeCommand(url, listener)
+	s.lock.Lock()
+	s.endpoints["com.weibo.TestService|group-a"] = []string{"10.0.0.1:8100", "10.0.0.4:8100"}
+	s.weights["com.weibo.TestService|group-b"] = 50
+	s.lock.Unlock()
+	for i := 0; i < 100 && listener.addresses() != "10.0.0.1:8100,10.0.0.4:8100"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if addresses := listener.addresses(); addresses != "10.0.0.1:8100,10.0.0.4:8100" {
+		t.Errorf("endpoints change not notified: %s", addresses)
+	}
+	listener.lock.Lock()
+	commands := listener.commands
+	listener.lock.Unlock()
+	if len(commands) == 0 || !strings.Contains(commands[len(commands)-1], "group-b:50") {
+		t.Errorf("route change not notified: %v", commands)
+	}
+}
@@ -0,0 +1,198 @@
+package motan
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	"gopkg.in/yaml.v2"
+)
+
+// the replaced clusters are destroyed after the delay, so the requests being processed are not broken
+var reloadDestroyDelay = 10 * time.Second
+
+// ConfigDiff is the changes of refers and services applied by a config reload,
+// the refers are identified by the cluster keys and the services by the export url identities
+type ConfigDiff struct {
+	AddedRefers     []string `json:"added_refers"`
+	RemovedRefers   []string `json:"removed_refers"`
+	ChangedRefers   []string `json:"changed_refers"`
+	AddedServices   []string `json:"added_services"`
+	RemovedServices []string `json:"removed_services"`
+	ChangedServices []string `json:"changed_services"`
+}
+
+func (d *ConfigDiff) IsEmpty() bool {
+	return len(d.AddedRefers)+len(d.RemovedRefers)+len(d.ChangedRefers)+
+		len(d.AddedServices)+len(d.RemovedServices)+len(d.ChangedServices) == 0
+}
+
+// ReloadConfig parses the config sources of the agent again and applies the changes of the refers and services.
+// only the clusters and exporters changed are rebuilt, the ones subscribed or exported dynamically are not affected.
+// the other sections such as the agent ports take effect after restarting
+func (a *Agent) ReloadConfig() (*ConfigDiff, error) {
+	a.reloadLock.Lock()
+	defer a.reloadLock.Unlock()
+	current := a.GetContext()
+	ctx := &motan.Context{ConfigFile: current.ConfigFile}
+	ctx.Initialize()
+	if ctx.Config == nil {
+		return nil, errors.New("parse config fail: " + ctx.ConfigFile)
+	}
+	diff := &ConfigDiff{}
+	if bytes.Equal(configData(ctx), configData(current)) {
+		return diff, nil
+	}
+	oldRefers, newRefers := a.configRefers(current), a.configRefers(ctx)
+	oldServices, newServices := a.configServices(current), a.configServices(ctx)
+	// the new clusters and exporters use the registries of the new config
+	a.setContext(ctx)
+
+	diff.RemovedRefers, diff.AddedRefers, diff.ChangedRefers = diffURLs(oldRefers, newRefers)
+	for _, key := range diff.RemovedRefers {
+		if c := a.clustermap.Delete(key); c != nil {
+			delayDestroy(c.(*cluster.MotanCluster))
+		}
+	}
+	for _, key := range append(diff.AddedRefers, diff.ChangedRefers...) {
+		old := a.clustermap.LoadOrNil(key)
+		a.initCluster(newRefers[key])
+		if old != nil {
+			delayDestroy(old.(*cluster.MotanCluster))
+		}
+	}
+
+	diff.RemovedServices, diff.AddedServices, diff.ChangedServices = diffURLs(oldServices, newServices)
+	for _, id := range append(diff.RemovedServices, diff.ChangedServices...) {
+		a.unexportService(id)
+	}
+	for _, id := range append(diff.AddedServices, diff.ChangedServices...) {
+		a.doExportService(newServices[id])
+	}
+	vlog.Infof("agent config reloaded. diff:%+v\n", diff)
+	return diff, nil
+}
+
+// watchConfig reloads the config if the config sources changed, it is checked every interval
+func (a *Agent) watchConfig(interval time.Duration) {
+	vlog.Infof("agent watches the config changes every %v\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.reloadOnChange()
+	}
+}
+
+// reloadOnChange reloads the config once the config sources or the config center changed
+func (a *Agent) reloadOnChange() {
+	if _, err := a.ReloadConfig(); err != nil {
+		vlog.Errorf("reload agent config fail. err:%v\n", err)
+	}
+}
+
+func (a *Agent) unexportService(id string) {
+	a.svcLock.Lock()
+	defer a.svcLock.Unlock()
+	if exporter := a.serviceExporters.Delete(id); exporter != nil {
+		exporter.(motan.Exporter).Unexport()
+	}
+}
+
+// configRefers returns the refers of the config by the cluster keys, with the params filled as initCluster
+func (a *Agent) configRefers(ctx *motan.Context) map[string]*motan.URL {
+	urls := make(map[string]*motan.URL, len(ctx.RefersURLs))
+	for _, url := range ctx.RefersURLs {
+		u := url.Copy()
+		if u.Parameters[motan.ApplicationKey] == "" {
+			u.Parameters[motan.ApplicationKey] = a.agentURL.Parameters[motan.ApplicationKey]
+		}
+		urls[clusterKey(u)] = u
+	}
+	return urls
+}
+
+// configServices returns the export urls of the config by the identities, with the params filled as startServerAgent
+func (a *Agent) configServices(ctx *motan.Context) map[string]*motan.URL {
+	urls := make(map[string]*motan.URL, len(ctx.ServiceURLs))
+	for _, url := range ctx.ServiceURLs {
+		exports, err := motan.ExportURLs(url.Copy())
+		if err != nil {
+			continue
+		}
+		for _, u := range exports {
+			a.initProxyURL(u)
+			urls[u.GetIdentity()] = u
+		}
+	}
+	return urls
+}
+
+func configData(ctx *motan.Context) []byte {
+	data, _ := yaml.Marshal(ctx.Config.GetOriginMap())
+	return data
+}
+
+// diffURLs returns the sorted keys removed from, added to or changed in the urls
+func diffURLs(old, new map[string]*motan.URL) (removed, added, changed []string) {
+	for k, o := range old {
+		if n, ok := new[k]; !ok {
+			removed = append(removed, k)
+		} else if !sameURL(o, n) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	sort.Strings(changed)
+	return removed, added, changed
+}
+
+func sameURL(u1, u2 *motan.URL) bool {
+	if u1.Protocol != u2.Protocol || u1.Host != u2.Host || u1.Port != u2.Port || u1.Path != u2.Path || u1.Group != u2.Group ||
+		len(u1.Parameters) != len(u2.Parameters) {
+		return false
+	}
+	for k, v := range u1.Parameters {
+		if v2, ok := u2.Parameters[k]; !ok || v != v2 {
+			return false
+		}
+	}
+	return true
+}
+
+func delayDestroy(c *cluster.MotanCluster) {
+	time.AfterFunc(reloadDestroyDelay, c.Destroy)
+}
+
+// ConfigReloadHandler reloads the agent config and responds the applied diff
+type ConfigReloadHandler struct {
+	agent *Agent
+}
+
+func (h *ConfigReloadHandler) SetAgent(agent *Agent) {
+	h.agent = agent
+}
+
+func (h *ConfigReloadHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json;charset=utf-8")
+	if req.Method != http.MethodPost {
+		writeHandlerResponse(res, http.StatusMethodNotAllowed, "config reload needs POST", nil)
+		return
+	}
+	diff, err := h.agent.ReloadConfig()
+	if err != nil {
+		writeHandlerResponse(res, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeHandlerResponse(res, http.StatusOK, "ok", diff)
+}
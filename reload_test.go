@@ -0,0 +1,145 @@
+package motan
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+)
+
+const reloadTestConfig = `
+motan-registry:
+  direct-registry:
+    protocol: direct
+    address: "127.0.0.1:64591"
+
+motan-basicRefer:
+  basic:
+    group: reload-group
+    protocol: test
+    registry: direct-registry
+    requestTimeout: 1000
+
+motan-refer:
+`
+
+func TestSameURL(t *testing.T) {
+	u := &motan.URL{Protocol: "motan2", Host: "127.0.0.1", Port: 8001, Path: "service", Group: "group", Parameters: map[string]string{"timeout": "100"}}
+	if !sameURL(u, u.Copy()) {
+		t.Error("copied url should be the same")
+	}
+	for name, modify := range map[string]func(u *motan.URL){
+		"host":         func(u *motan.URL) { u.Host = "127.0.0.2" },
+		"group":        func(u *motan.URL) { u.Group = "other" },
+		"param value":  func(u *motan.URL) { u.Parameters["timeout"] = "200" },
+		"param added":  func(u *motan.URL) { u.Parameters["retries"] = "1" },
+		"param absent": func(u *motan.URL) { u.Parameters = map[string]string{"retries": "100"} },
+	} {
+		other := u.Copy()
+		modify(other)
+		if sameURL(u, other) || sameURL(other, u) {
+			t.Errorf("urls with different %s should not be the same", name)
+		}
+	}
+}
+
+func TestDiffURLs(t *testing.T) {
+	url := func(timeout string) *motan.URL {
+		return &motan.URL{Protocol: "motan2", Path: "service", Parameters: map[string]string{"timeout": timeout}}
+	}
+	old := map[string]*motan.URL{"b": url("100"), "a": url("100"), "c": url("100"), "d": url("100")}
+	new := map[string]*motan.URL{"c": url("100"), "d": url("200"), "f": url("100"), "e": url("100")}
+	removed, added, changed := diffURLs(old, new)
+	if !reflect.DeepEqual(removed, []string{"a", "b"}) || !reflect.DeepEqual(added, []string{"e", "f"}) || !reflect.DeepEqual(changed, []string{"d"}) {
+		t.Errorf("diff not correct. removed:%v, added:%v, changed:%v", removed, added, changed)
+	}
+	if removed, added, changed = diffURLs(old, old); removed != nil || added != nil || changed != nil {
+		t.Errorf("same urls should have no diff. removed:%v, added:%v, changed:%v", removed, added, changed)
+	}
+}
+
+func TestReloadConfigClusters(t *testing.T) {
+	delay := reloadDestroyDelay
+	reloadDestroyDelay = 0
+	defer func() { reloadDestroyDelay = delay }()
+	dir, err := ioutil.TempDir("", "motan-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "agent.yaml")
+	writeConfig := func(refers string) {
+		if err := ioutil.WriteFile(file, []byte(reloadTestConfig+refers), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(`
+  kept: {path: kept.service, basicRefer: basic}
+  changed: {path: changed.service, basicRefer: basic}
+  removed: {path: removed.service, basicRefer: basic}
+`)
+
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	AddDefaultExt(ext)
+	ext.RegistExtEndpoint("test", func(url *motan.URL) motan.EndPoint {
+		return &motan.TestEndPoint{URL: url}
+	})
+	a := NewAgent(ext)
+	a.agentURL = &motan.URL{Parameters: map[string]string{motan.ApplicationKey: "reload-test"}}
+	ctx := &motan.Context{ConfigFile: file}
+	ctx.Initialize()
+	if ctx.Config == nil {
+		t.Fatal("parse config fail")
+	}
+	a.setContext(ctx)
+	a.initClusters()
+	defer a.clustermap.Range(func(k, v interface{}) bool {
+		v.(*cluster.MotanCluster).Destroy()
+		return true
+	})
+	key := func(path string) string {
+		return getClusterKey("reload-group", "0.1", "test", path)
+	}
+	kept, changed := a.clustermap.LoadOrNil(key("kept.service")), a.clustermap.LoadOrNil(key("changed.service"))
+	if kept == nil || changed == nil || a.clustermap.LoadOrNil(key("removed.service")) == nil {
+		t.Fatal("clusters not initialized")
+	}
+
+	if diff, err := a.ReloadConfig(); err != nil || !diff.IsEmpty() {
+		t.Errorf("reload without changes should have no diff. diff:%+v, err:%v", diff, err)
+	}
+	if a.GetContext() != ctx {
+		t.Error("context should not be replaced without changes")
+	}
+
+	writeConfig(`
+  kept: {path: kept.service, basicRefer: basic}
+  changed: {path: changed.service, basicRefer: basic, requestTimeout: 2000}
+  added: {path: added.service, basicRefer: basic}
+`)
+	diff, err := a.ReloadConfig()
+	if err != nil {
+		t.Fatalf("reload fail. err:%v", err)
+	}
+	if !reflect.DeepEqual(diff.AddedRefers, []string{key("added.service")}) || !reflect.DeepEqual(diff.RemovedRefers, []string{key("removed.service")}) ||
+		!reflect.DeepEqual(diff.ChangedRefers, []string{key("changed.service")}) {
+		t.Errorf("diff not correct. diff:%+v", diff)
+	}
+	if a.GetContext() == ctx || a.GetContext().RefersURLs["added"] == nil {
+		t.Error("context should be replaced by the reloaded one")
+	}
+	if a.clustermap.LoadOrNil(key("kept.service")) != kept {
+		t.Error("cluster not changed should be kept")
+	}
+	if c := a.clustermap.LoadOrNil(key("changed.service")); c == nil || c == changed || c.(*cluster.MotanCluster).GetURL().GetParam(motan.TimeOutKey, "") != "2000" {
+		t.Errorf("changed cluster should be rebuilt. cluster:%v", c)
+	}
+	if a.clustermap.LoadOrNil(key("added.service")) == nil || a.clustermap.LoadOrNil(key("removed.service")) != nil {
+		t.Error("clusters not swapped")
+	}
+}
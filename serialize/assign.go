@@ -0,0 +1,325 @@
+package serialize
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// max nesting depth when assigning decoded values, values with reference cycles can not be assigned
+const maxAssignDepth = 64
+
+// naming conventions of the struct fields without name tag
+const (
+	NamingCamelCase = "camelCase"  // the field name with the first letter in lower case, which is the java bean convention
+	NamingSnakeCase = "snake_case" // the field name in lower case with underscores between words
+	NamingNone      = "none"       // the field name as is
+)
+
+var (
+	ErrAssignDepth = errors.New("value is nested too deep or has reference cycle")
+
+	structFieldsCache sync.Map // fieldsKey -> []structField
+)
+
+type fieldsKey struct {
+	t      reflect.Type
+	tag    string
+	naming string
+}
+
+// structField is an exported field of a struct with the name used in serialization
+type structField struct {
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+// getStructFields returns the exported fields of the struct type. the name of a field is the tag value if it is set,
+// otherwise the field name in the naming convention. fields with tag "-" are skipped
+func getStructFields(t reflect.Type, tag string, naming string) []structField {
+	key := fieldsKey{t: t, tag: tag, naming: naming}
+	if fields, ok := structFieldsCache.Load(key); ok {
+		return fields.([]structField)
+	}
+	fields := make([]structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Tag.Get(tag)
+		var opts string
+		if idx := strings.Index(name, ","); idx >= 0 {
+			name, opts = name[:idx], name[idx:]
+		}
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = fieldName(f.Name, naming)
+		}
+		fields = append(fields, structField{index: i, name: name, omitEmpty: strings.Contains(opts, ",omitempty")})
+	}
+	structFieldsCache.Store(key, fields)
+	return fields
+}
+
+func fieldName(name string, naming string) string {
+	switch naming {
+	case NamingSnakeCase:
+		return snakeCase(name)
+	case NamingNone:
+		return name
+	}
+	return firstLower(name)
+}
+
+func firstLower(s string) string {
+	r := []rune(s)
+	if len(r) == 0 || unicode.IsLower(r[0]) {
+		return s
+	}
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// snakeCase converts UserID to user_id and HTTPServer to http_server
+func snakeCase(s string) string {
+	r := []rune(s)
+	b := make([]rune, 0, len(r)+4)
+	for i, c := range r {
+		if unicode.IsUpper(c) {
+			if i > 0 && (unicode.IsLower(r[i-1]) || unicode.IsDigit(r[i-1]) ||
+				(unicode.IsUpper(r[i-1]) && i+1 < len(r) && unicode.IsLower(r[i+1]))) {
+				b = append(b, '_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+// assigner assigns decoded values to go values of other types
+type assigner struct {
+	tag    string
+	naming string
+	// map entries not matching any field of the struct fail the assignment
+	strict bool
+	// called before the default conversions, done is true if the value is assigned by the hook
+	hook func(dst reflect.Value, src interface{}) (done bool, err error)
+}
+
+func (a *assigner) fields(t reflect.Type) []structField {
+	return getStructFields(t, a.tag, a.naming)
+}
+
+// decodeInto assigns the decoded value to v like the other serializations:
+// v is nil to use the decoded value as is, a pointer to fill, or a reflect.Type to create a value of.
+func (a *assigner) decodeInto(value interface{}, v interface{}) (interface{}, error) {
+	if v == nil {
+		return value, nil
+	}
+	if rt, ok := v.(reflect.Type); ok {
+		nv := reflect.New(rt).Elem()
+		if err := a.assign(nv, value, 0); err != nil {
+			return nil, err
+		}
+		return nv.Interface(), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, fmt.Errorf("can not deserialize into non-pointer %T", v)
+	}
+	if err := a.assign(rv.Elem(), value, 0); err != nil {
+		return nil, err
+	}
+	return rv.Elem().Interface(), nil
+}
+
+// assign sets the decoded value src to dst, converting numbers, lists and maps to the type of dst.
+// maps are assigned to structs by the field names
+func (a *assigner) assign(dst reflect.Value, src interface{}, depth int) error {
+	if depth > maxAssignDepth {
+		return ErrAssignDepth
+	}
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if a.hook != nil {
+		if done, err := a.hook(dst, src); done || err != nil {
+			return err
+		}
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dst.Type()) {
+		dst.Set(sv)
+		return nil
+	}
+	if sv.Kind() == reflect.Ptr && dst.Kind() != reflect.Ptr {
+		if sv.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		return a.assign(dst, sv.Elem().Interface(), depth+1)
+	}
+	switch dst.Kind() {
+	case reflect.Ptr:
+		nv := reflect.New(dst.Type().Elem())
+		if sv.Kind() == reflect.Ptr {
+			if sv.IsNil() {
+				dst.Set(reflect.Zero(dst.Type()))
+				return nil
+			}
+			src = sv.Elem().Interface()
+		}
+		if err := a.assign(nv.Elem(), src, depth+1); err != nil {
+			return err
+		}
+		dst.Set(nv)
+		return nil
+	case reflect.Bool:
+		if sv.Kind() == reflect.Bool {
+			dst.SetBool(sv.Bool())
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch sv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			dst.SetInt(sv.Int())
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			dst.SetInt(int64(sv.Uint()))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			dst.SetInt(int64(sv.Float()))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch sv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			dst.SetUint(uint64(sv.Int()))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			dst.SetUint(sv.Uint())
+			return nil
+		case reflect.Float32, reflect.Float64:
+			dst.SetUint(uint64(sv.Float()))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch sv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			dst.SetFloat(float64(sv.Int()))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			dst.SetFloat(float64(sv.Uint()))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			dst.SetFloat(sv.Float())
+			return nil
+		}
+	case reflect.String:
+		if sv.Kind() == reflect.String {
+			dst.SetString(sv.String())
+			return nil
+		}
+		if b, ok := src.([]byte); ok {
+			dst.SetString(string(b))
+			return nil
+		}
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 && sv.Kind() == reflect.String {
+			dst.SetBytes([]byte(sv.String()))
+			return nil
+		}
+		if sv.Kind() == reflect.Slice || sv.Kind() == reflect.Array {
+			s := reflect.MakeSlice(dst.Type(), sv.Len(), sv.Len())
+			for i := 0; i < sv.Len(); i++ {
+				if err := a.assign(s.Index(i), sv.Index(i).Interface(), depth+1); err != nil {
+					return err
+				}
+			}
+			dst.Set(s)
+			return nil
+		}
+	case reflect.Array:
+		if (sv.Kind() == reflect.Slice || sv.Kind() == reflect.Array) && sv.Len() <= dst.Len() {
+			for i := 0; i < sv.Len(); i++ {
+				if err := a.assign(dst.Index(i), sv.Index(i).Interface(), depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if sv.Kind() == reflect.Map {
+			m := reflect.MakeMapWithSize(dst.Type(), sv.Len())
+			kt, vt := dst.Type().Key(), dst.Type().Elem()
+			for _, k := range sv.MapKeys() {
+				nk := reflect.New(kt).Elem()
+				if err := a.assign(nk, k.Interface(), depth+1); err != nil {
+					return err
+				}
+				nv := reflect.New(vt).Elem()
+				if err := a.assign(nv, sv.MapIndex(k).Interface(), depth+1); err != nil {
+					return err
+				}
+				m.SetMapIndex(nk, nv)
+			}
+			dst.Set(m)
+			return nil
+		}
+	case reflect.Struct:
+		if sv.Kind() == reflect.Map {
+			return a.assignStruct(dst, sv, depth)
+		}
+	}
+	if sv.Type().ConvertibleTo(dst.Type()) && sv.Kind() == dst.Kind() {
+		dst.Set(sv.Convert(dst.Type()))
+		return nil
+	}
+	return fmt.Errorf("can not assign %T to %s", src, dst.Type())
+}
+
+// assignStruct sets the fields of dst by the entries of the map with string keys
+func (a *assigner) assignStruct(dst reflect.Value, sv reflect.Value, depth int) error {
+	fields := a.fields(dst.Type())
+	for _, k := range sv.MapKeys() {
+		name, ok := k.Interface().(string)
+		if !ok {
+			continue
+		}
+		f, ok := findField(fields, name)
+		if !ok {
+			if a.strict {
+				return fmt.Errorf("unknown field %s of %s", name, dst.Type())
+			}
+			continue
+		}
+		if err := a.assign(dst.Field(f.index), sv.MapIndex(k).Interface(), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findField finds the field by name, a case insensitive match is used if there is no exact one
+func findField(fields []structField, name string) (structField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
@@ -0,0 +1,902 @@
+package serialize
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+// struct tag of the hessian field names
+const hessianTag = "hessian"
+
+var hessianAssigner = &assigner{tag: hessianTag}
+
+// max chars or bytes of a string or binary chunk
+const hessianChunkSize = 0x8000
+
+var (
+	ErrHessianClassRef = errors.New("hessian2 class definition not found")
+	ErrHessianRef      = errors.New("hessian2 reference not found")
+
+	timeType = reflect.TypeOf(time.Time{})
+
+	hessianClasses     = make(map[string]reflect.Type, 16)
+	hessianClassNames  = make(map[reflect.Type]string, 16)
+	hessianClassesLock sync.RWMutex
+)
+
+// RegisterHessianClass maps the java class name to the struct type of v. values of the type are sent as objects of the class,
+// and objects of the class are received as pointers to the type. structs not registered are sent as untyped maps
+func RegisterHessianClass(className string, v interface{}) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	hessianClassesLock.Lock()
+	hessianClasses[className] = t
+	hessianClassNames[t] = className
+	hessianClassesLock.Unlock()
+}
+
+func getHessianClassName(t reflect.Type) string {
+	hessianClassesLock.RLock()
+	defer hessianClassesLock.RUnlock()
+	return hessianClassNames[t]
+}
+
+func getHessianClass(className string) reflect.Type {
+	hessianClassesLock.RLock()
+	defer hessianClassesLock.RUnlock()
+	return hessianClasses[className]
+}
+
+// Hessian2Serialization is the hessian 2.0 serialization, which is the default serialization of java motan.
+// int8/int16/int32 are sent as java int and int/int64 as java long. ints are received as int32, longs as int64,
+// lists as []interface{}, maps as map[interface{}]interface{}, and objects of unregistered classes as map[string]interface{}.
+// the field names are the hessian tags, or the field names with the first letter in lower case.
+type Hessian2Serialization struct{}
+
+func (h *Hessian2Serialization) GetSerialNum() int {
+	return 0
+}
+
+func (h *Hessian2Serialization) Serialize(v interface{}) ([]byte, error) {
+	buf := motan.AcquireBytesBuffer(DefaultBufferSize)
+	defer motan.ReleaseBytesBuffer(buf)
+	e := &hessianEncoder{buf: buf}
+	err := e.encode(v)
+	return copyBytes(buf), err
+}
+
+func (h *Hessian2Serialization) SerializeMulti(v []interface{}) ([]byte, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	buf := motan.AcquireBytesBuffer(DefaultBufferSize)
+	defer motan.ReleaseBytesBuffer(buf)
+	// class definitions are shared by all values like java Hessian2Output
+	e := &hessianEncoder{buf: buf}
+	for _, o := range v {
+		if err := e.encode(o); err != nil {
+			return nil, err
+		}
+	}
+	return copyBytes(buf), nil
+}
+
+func (h *Hessian2Serialization) DeSerialize(b []byte, v interface{}) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	d, err := newHessianDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+	value, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	return hessianAssigner.decodeInto(value, v)
+}
+
+func (h *Hessian2Serialization) DeSerializeMulti(b []byte, v []interface{}) ([]interface{}, error) {
+	d, err := newHessianDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]interface{}, 0, len(v))
+	if v != nil {
+		for _, o := range v {
+			value, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			if value, err = hessianAssigner.decodeInto(value, o); err != nil {
+				return nil, err
+			}
+			ret = append(ret, value)
+		}
+		return ret, nil
+	}
+	for d.buf.Remain() > 0 {
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, value)
+	}
+	return ret, nil
+}
+
+type hessianEncoder struct {
+	buf *motan.BytesBuffer
+	// index of the written class definitions
+	classes map[reflect.Type]int
+}
+
+func (e *hessianEncoder) encode(v interface{}) error {
+	if rv, ok := v.(reflect.Value); ok {
+		return e.encodeValue(rv)
+	}
+	return e.encodeValue(reflect.ValueOf(v))
+}
+
+func (e *hessianEncoder) encodeValue(rv reflect.Value) error {
+	if !rv.IsValid() {
+		e.buf.WriteByte('N')
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			e.buf.WriteByte('N')
+			return nil
+		}
+		return e.encodeValue(rv.Elem())
+	case reflect.Bool:
+		if rv.Bool() {
+			e.buf.WriteByte('T')
+		} else {
+			e.buf.WriteByte('F')
+		}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		e.writeInt(int32(rv.Int()))
+	case reflect.Uint8, reflect.Uint16:
+		e.writeInt(int32(rv.Uint()))
+	case reflect.Int, reflect.Int64:
+		e.writeLong(rv.Int())
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		e.writeLong(int64(rv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		e.writeDouble(rv.Float())
+	case reflect.String:
+		e.writeString(rv.String())
+	case reflect.Slice:
+		if rv.IsNil() {
+			e.buf.WriteByte('N')
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBinary(rv.Bytes())
+			return nil
+		}
+		return e.writeList(rv)
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			e.writeBinary(b)
+			return nil
+		}
+		return e.writeList(rv)
+	case reflect.Map:
+		if rv.IsNil() {
+			e.buf.WriteByte('N')
+			return nil
+		}
+		return e.writeMap(rv)
+	case reflect.Struct:
+		if rv.Type() == timeType {
+			e.buf.WriteByte(0x4a)
+			e.buf.WriteUint64(uint64(rv.Interface().(time.Time).UnixNano() / int64(time.Millisecond)))
+			return nil
+		}
+		return e.writeObject(rv)
+	default:
+		return fmt.Errorf("not support type by Hessian2Serialization: %s", rv.Type())
+	}
+	return nil
+}
+
+func (e *hessianEncoder) writeInt(v int32) {
+	switch {
+	case v >= -16 && v <= 47:
+		e.buf.WriteByte(byte(0x90 + v))
+	case v >= -2048 && v <= 2047:
+		e.buf.WriteByte(byte(0xc8 + (v >> 8)))
+		e.buf.WriteByte(byte(v))
+	case v >= -262144 && v <= 262143:
+		e.buf.WriteByte(byte(0xd4 + (v >> 16)))
+		e.buf.WriteUint16(uint16(v))
+	default:
+		e.buf.WriteByte('I')
+		e.buf.WriteUint32(uint32(v))
+	}
+}
+
+func (e *hessianEncoder) writeLong(v int64) {
+	switch {
+	case v >= -8 && v <= 15:
+		e.buf.WriteByte(byte(0xe0 + v))
+	case v >= -2048 && v <= 2047:
+		e.buf.WriteByte(byte(0xf8 + (v >> 8)))
+		e.buf.WriteByte(byte(v))
+	case v >= -262144 && v <= 262143:
+		e.buf.WriteByte(byte(0x3c + (v >> 16)))
+		e.buf.WriteUint16(uint16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		e.buf.WriteByte(0x59)
+		e.buf.WriteUint32(uint32(v))
+	default:
+		e.buf.WriteByte('L')
+		e.buf.WriteUint64(uint64(v))
+	}
+}
+
+func (e *hessianEncoder) writeDouble(v float64) {
+	if i := int32(v); v >= math.MinInt32 && v <= math.MaxInt32 && float64(i) == v {
+		switch {
+		case i == 0:
+			e.buf.WriteByte(0x5b)
+			return
+		case i == 1:
+			e.buf.WriteByte(0x5c)
+			return
+		case i >= -128 && i <= 127:
+			e.buf.WriteByte(0x5d)
+			e.buf.WriteByte(byte(i))
+			return
+		case i >= -32768 && i <= 32767:
+			e.buf.WriteByte(0x5e)
+			e.buf.WriteUint16(uint16(i))
+			return
+		}
+	}
+	// the value in milliunits if it fits in an int
+	if m := v * 1000; m >= math.MinInt32 && m <= math.MaxInt32 && 0.001*float64(int32(m)) == v {
+		e.buf.WriteByte(0x5f)
+		e.buf.WriteUint32(uint32(int32(m)))
+		return
+	}
+	e.buf.WriteByte('D')
+	e.buf.WriteUint64(math.Float64bits(v))
+}
+
+// writeString writes the string in chunks of utf-16 chars, each char is encoded in utf-8 like java
+func (e *hessianEncoder) writeString(s string) {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		for len(s) > hessianChunkSize {
+			e.buf.WriteByte('R')
+			e.buf.WriteUint16(hessianChunkSize)
+			e.buf.WriteString(s[:hessianChunkSize])
+			s = s[hessianChunkSize:]
+		}
+		e.writeStringLength(len(s))
+		e.buf.WriteString(s)
+		return
+	}
+	u := utf16.Encode([]rune(s))
+	for len(u) > hessianChunkSize {
+		n := hessianChunkSize
+		// never split a surrogate pair
+		if u[n-1] >= 0xd800 && u[n-1] < 0xdc00 {
+			n--
+		}
+		e.buf.WriteByte('R')
+		e.buf.WriteUint16(uint16(n))
+		e.writeChars(u[:n])
+		u = u[n:]
+	}
+	e.writeStringLength(len(u))
+	e.writeChars(u)
+}
+
+func (e *hessianEncoder) writeStringLength(n int) {
+	switch {
+	case n <= 31:
+		e.buf.WriteByte(byte(n))
+	case n <= 1023:
+		e.buf.WriteByte(byte(0x30 + (n >> 8)))
+		e.buf.WriteByte(byte(n))
+	default:
+		e.buf.WriteByte('S')
+		e.buf.WriteUint16(uint16(n))
+	}
+}
+
+func (e *hessianEncoder) writeChars(u []uint16) {
+	for _, c := range u {
+		switch {
+		case c < 0x80:
+			e.buf.WriteByte(byte(c))
+		case c < 0x800:
+			e.buf.WriteByte(byte(0xc0 | c>>6))
+			e.buf.WriteByte(byte(0x80 | c&0x3f))
+		default:
+			e.buf.WriteByte(byte(0xe0 | c>>12))
+			e.buf.WriteByte(byte(0x80 | (c>>6)&0x3f))
+			e.buf.WriteByte(byte(0x80 | c&0x3f))
+		}
+	}
+}
+
+func (e *hessianEncoder) writeBinary(b []byte) {
+	for len(b) > hessianChunkSize {
+		e.buf.WriteByte('A')
+		e.buf.WriteUint16(hessianChunkSize)
+		e.buf.Write(b[:hessianChunkSize])
+		b = b[hessianChunkSize:]
+	}
+	n := len(b)
+	switch {
+	case n <= 15:
+		e.buf.WriteByte(byte(0x20 + n))
+	case n <= 1023:
+		e.buf.WriteByte(byte(0x34 + (n >> 8)))
+		e.buf.WriteByte(byte(n))
+	default:
+		e.buf.WriteByte('B')
+		e.buf.WriteUint16(uint16(n))
+	}
+	e.buf.Write(b)
+}
+
+// writeList writes an untyped fixed length list
+func (e *hessianEncoder) writeList(rv reflect.Value) error {
+	n := rv.Len()
+	if n <= 7 {
+		e.buf.WriteByte(byte(0x78 + n))
+	} else {
+		e.buf.WriteByte(0x58)
+		e.writeInt(int32(n))
+	}
+	for i := 0; i < n; i++ {
+		if err := e.encodeValue(rv.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMap writes an untyped map, which is a java HashMap
+func (e *hessianEncoder) writeMap(rv reflect.Value) error {
+	e.buf.WriteByte('H')
+	for _, k := range rv.MapKeys() {
+		if err := e.encodeValue(k); err != nil {
+			return err
+		}
+		if err := e.encodeValue(rv.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('Z')
+	return nil
+}
+
+func (e *hessianEncoder) writeObject(rv reflect.Value) error {
+	t := rv.Type()
+	fields := hessianAssigner.fields(t)
+	className := getHessianClassName(t)
+	if className == "" {
+		e.buf.WriteByte('H')
+		for _, f := range fields {
+			e.writeString(f.name)
+			if err := e.encodeValue(rv.Field(f.index)); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte('Z')
+		return nil
+	}
+	if e.classes == nil {
+		e.classes = make(map[reflect.Type]int, 4)
+	}
+	idx, ok := e.classes[t]
+	if !ok {
+		idx = len(e.classes)
+		e.classes[t] = idx
+		e.buf.WriteByte('C')
+		e.writeString(className)
+		e.writeInt(int32(len(fields)))
+		for _, f := range fields {
+			e.writeString(f.name)
+		}
+	}
+	if idx <= 15 {
+		e.buf.WriteByte(byte(0x60 + idx))
+	} else {
+		e.buf.WriteByte('O')
+		e.writeInt(int32(idx))
+	}
+	for _, f := range fields {
+		if err := e.encodeValue(rv.Field(f.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type hessianClassDef struct {
+	t      reflect.Type
+	fields []string
+}
+
+type hessianDecoder struct {
+	buf     *motan.BytesBuffer
+	refs    []interface{}
+	types   []string
+	classes []*hessianClassDef
+	limits  *DeserializeLimits
+	// nesting depth of the lists, maps and objects being read
+	depth int
+}
+
+func newHessianDecoder(b []byte) (*hessianDecoder, error) {
+	limits := loadLimits()
+	if err := limits.checkTotal(len(b)); err != nil {
+		return nil, err
+	}
+	return &hessianDecoder{buf: motan.CreateBytesBuffer(b), limits: limits}, nil
+}
+
+func (d *hessianDecoder) enter() error {
+	d.depth++
+	return d.limits.checkDepth(d.depth)
+}
+
+func (d *hessianDecoder) leave() {
+	d.depth--
+}
+
+func (d *hessianDecoder) decode() (interface{}, error) {
+	tag, err := d.buf.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeTag(tag)
+}
+
+func (d *hessianDecoder) decodeTag(tag byte) (interface{}, error) {
+	switch {
+	case tag == 'N':
+		return nil, nil
+	case tag == 'T':
+		return true, nil
+	case tag == 'F':
+		return false, nil
+	case tag >= 0x80 && tag <= 0xbf:
+		return int32(tag) - 0x90, nil
+	case tag >= 0xc0 && tag <= 0xcf:
+		b, err := d.buf.ReadByte()
+		return (int32(tag)-0xc8)<<8 | int32(b), err
+	case tag >= 0xd0 && tag <= 0xd7:
+		u, err := d.buf.ReadUint16()
+		return (int32(tag)-0xd4)<<16 | int32(u), err
+	case tag == 'I':
+		u, err := d.buf.ReadUint32()
+		return int32(u), err
+	case tag >= 0xd8 && tag <= 0xef:
+		return int64(tag) - 0xe0, nil
+	case tag >= 0xf0:
+		b, err := d.buf.ReadByte()
+		return (int64(tag)-0xf8)<<8 | int64(b), err
+	case tag >= 0x38 && tag <= 0x3f:
+		u, err := d.buf.ReadUint16()
+		return (int64(tag)-0x3c)<<16 | int64(u), err
+	case tag == 0x59:
+		u, err := d.buf.ReadUint32()
+		return int64(int32(u)), err
+	case tag == 'L':
+		u, err := d.buf.ReadUint64()
+		return int64(u), err
+	case tag == 0x5b:
+		return float64(0), nil
+	case tag == 0x5c:
+		return float64(1), nil
+	case tag == 0x5d:
+		b, err := d.buf.ReadByte()
+		return float64(int8(b)), err
+	case tag == 0x5e:
+		u, err := d.buf.ReadUint16()
+		return float64(int16(u)), err
+	case tag == 0x5f:
+		u, err := d.buf.ReadUint32()
+		return float64(int32(u)) * 0.001, err
+	case tag == 'D':
+		u, err := d.buf.ReadUint64()
+		return math.Float64frombits(u), err
+	case tag == 0x4a:
+		u, err := d.buf.ReadUint64()
+		return time.Unix(0, int64(u)*int64(time.Millisecond)), err
+	case tag == 0x4b:
+		u, err := d.buf.ReadUint32()
+		return time.Unix(int64(int32(u))*60, 0), err
+	case isHessianString(tag):
+		return d.readString(tag)
+	case tag <= 0x2f || (tag >= 0x34 && tag <= 0x37) || tag == 'A' || tag == 'B':
+		return d.readBinary(tag)
+	case tag == 'V' || (tag >= 0x70 && tag <= 0x77):
+		if _, err := d.readType(); err != nil {
+			return nil, err
+		}
+		n := int(tag) - 0x70
+		if tag == 'V' {
+			var err error
+			if n, err = d.readInt(); err != nil {
+				return nil, err
+			}
+		}
+		return d.readList(n)
+	case tag == 0x58:
+		n, err := d.readInt()
+		if err != nil {
+			return nil, err
+		}
+		return d.readList(n)
+	case tag >= 0x78:
+		return d.readList(int(tag) - 0x78)
+	case tag == 0x55:
+		if _, err := d.readType(); err != nil {
+			return nil, err
+		}
+		return d.readList(-1)
+	case tag == 0x57:
+		return d.readList(-1)
+	case tag == 'M':
+		if _, err := d.readType(); err != nil {
+			return nil, err
+		}
+		return d.readMap()
+	case tag == 'H':
+		return d.readMap()
+	case tag == 'C':
+		if err := d.readClassDef(); err != nil {
+			return nil, err
+		}
+		// the class definition is followed by the object
+		return d.decode()
+	case tag == 'O':
+		idx, err := d.readInt()
+		if err != nil {
+			return nil, err
+		}
+		return d.readObject(idx)
+	case tag >= 0x60 && tag <= 0x6f:
+		return d.readObject(int(tag) - 0x60)
+	case tag == 0x51:
+		idx, err := d.readInt()
+		if err != nil {
+			return nil, err
+		}
+		if idx < 0 || idx >= len(d.refs) {
+			return nil, ErrHessianRef
+		}
+		return d.refs[idx], nil
+	}
+	return nil, fmt.Errorf("unknown hessian2 tag: 0x%x", tag)
+}
+
+func isHessianString(tag byte) bool {
+	return tag <= 0x1f || (tag >= 0x30 && tag <= 0x33) || tag == 'R' || tag == 'S'
+}
+
+func (d *hessianDecoder) readInt() (int, error) {
+	v, err := d.decode()
+	if err != nil {
+		return 0, err
+	}
+	switch i := v.(type) {
+	case int32:
+		return int(i), nil
+	case int64:
+		return int(i), nil
+	}
+	return 0, fmt.Errorf("hessian2 int expected, but got %T", v)
+}
+
+// readType reads a type name or a reference to the type names read before
+func (d *hessianDecoder) readType() (string, error) {
+	tag, err := d.buf.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if isHessianString(tag) {
+		t, err := d.readString(tag)
+		if err == nil {
+			d.types = append(d.types, t)
+		}
+		return t, err
+	}
+	v, err := d.decodeTag(tag)
+	if err != nil {
+		return "", err
+	}
+	if idx, ok := v.(int32); ok && idx >= 0 && int(idx) < len(d.types) {
+		return d.types[idx], nil
+	}
+	return "", fmt.Errorf("hessian2 type reference not found: %v", v)
+}
+
+func (d *hessianDecoder) readString(tag byte) (string, error) {
+	var chars []uint16
+	for {
+		var n int
+		final := true
+		switch {
+		case tag <= 0x1f:
+			n = int(tag)
+		case tag >= 0x30 && tag <= 0x33:
+			b, err := d.buf.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			n = int(tag-0x30)<<8 | int(b)
+		case tag == 'R' || tag == 'S':
+			u, err := d.buf.ReadUint16()
+			if err != nil {
+				return "", err
+			}
+			n = int(u)
+			final = tag == 'S'
+		default:
+			return "", fmt.Errorf("hessian2 string chunk expected, but got tag 0x%x", tag)
+		}
+		if err := d.limits.checkString(len(chars) + n); err != nil {
+			return "", err
+		}
+		var err error
+		if chars, err = d.readChars(chars, n); err != nil {
+			return "", err
+		}
+		if final {
+			return string(utf16.Decode(chars)), nil
+		}
+		if tag, err = d.buf.ReadByte(); err != nil {
+			return "", err
+		}
+	}
+}
+
+// readChars reads n utf-16 chars encoded in utf-8. the surrogates are encoded separately by java,
+// but 4 bytes utf-8 sequences of other implementations are also accepted
+func (d *hessianDecoder) readChars(chars []uint16, n int) ([]uint16, error) {
+	for i := 0; i < n; {
+		b, err := d.buf.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		var c rune
+		var more int
+		switch {
+		case b < 0x80:
+			chars = append(chars, uint16(b))
+			i++
+			continue
+		case b&0xe0 == 0xc0:
+			c, more = rune(b&0x1f), 1
+		case b&0xf0 == 0xe0:
+			c, more = rune(b&0x0f), 2
+		case b&0xf8 == 0xf0:
+			c, more = rune(b&0x07), 3
+		default:
+			return nil, errors.New("hessian2 string is not valid utf-8")
+		}
+		tail, err := d.buf.Next(more)
+		if err != nil {
+			return nil, motan.ErrNotEnough
+		}
+		for _, t := range tail {
+			c = c<<6 | rune(t&0x3f)
+		}
+		if c >= 0x10000 {
+			r1, r2 := utf16.EncodeRune(c)
+			chars = append(chars, uint16(r1), uint16(r2))
+			i += 2
+		} else {
+			chars = append(chars, uint16(c))
+			i++
+		}
+	}
+	return chars, nil
+}
+
+func (d *hessianDecoder) readBinary(tag byte) ([]byte, error) {
+	var data []byte
+	for {
+		var n int
+		final := true
+		switch {
+		case tag >= 0x20 && tag <= 0x2f:
+			n = int(tag - 0x20)
+		case tag >= 0x34 && tag <= 0x37:
+			b, err := d.buf.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			n = int(tag-0x34)<<8 | int(b)
+		case tag == 'A' || tag == 'B':
+			u, err := d.buf.ReadUint16()
+			if err != nil {
+				return nil, err
+			}
+			n = int(u)
+			final = tag == 'B'
+		default:
+			return nil, fmt.Errorf("hessian2 binary chunk expected, but got tag 0x%x", tag)
+		}
+		if err := d.limits.checkString(len(data) + n); err != nil {
+			return nil, err
+		}
+		b, err := d.buf.Next(n)
+		if err != nil {
+			return nil, motan.ErrNotEnough
+		}
+		if final && data == nil {
+			return b, nil
+		}
+		data = append(data, b...)
+		if final {
+			return data, nil
+		}
+		if tag, err = d.buf.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readList reads n values, or values until the end tag 'Z' if n < 0
+func (d *hessianDecoder) readList(n int) ([]interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	if err := d.limits.checkCollection(n); err != nil {
+		return nil, err
+	}
+	size := n
+	if size < 0 || size > d.buf.Remain() {
+		size = d.buf.Remain()
+	}
+	list := make([]interface{}, 0, size)
+	ref := len(d.refs)
+	d.refs = append(d.refs, nil)
+	for i := 0; n < 0 || i < n; i++ {
+		tag, err := d.buf.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 && tag == 'Z' {
+			break
+		}
+		if err = d.limits.checkCollection(i + 1); err != nil {
+			return nil, err
+		}
+		v, err := d.decodeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	d.refs[ref] = list
+	return list, nil
+}
+
+func (d *hessianDecoder) readMap() (map[interface{}]interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	m := make(map[interface{}]interface{}, 16)
+	d.refs = append(d.refs, m)
+	for {
+		tag, err := d.buf.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if tag == 'Z' {
+			return m, nil
+		}
+		if err = d.limits.checkCollection(len(m) + 1); err != nil {
+			return nil, err
+		}
+		k, err := d.decodeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("hessian2 map key type %T is not supported", k)
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+}
+
+func (d *hessianDecoder) readClassDef() error {
+	tag, err := d.buf.ReadByte()
+	if err != nil {
+		return err
+	}
+	className, err := d.readString(tag)
+	if err != nil {
+		return err
+	}
+	n, err := d.readInt()
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > d.buf.Remain() {
+		return ErrWrongSize
+	}
+	if err = d.limits.checkCollection(n); err != nil {
+		return err
+	}
+	def := &hessianClassDef{t: getHessianClass(className), fields: make([]string, 0, n)}
+	for i := 0; i < n; i++ {
+		if tag, err = d.buf.ReadByte(); err != nil {
+			return err
+		}
+		field, err := d.readString(tag)
+		if err != nil {
+			return err
+		}
+		def.fields = append(def.fields, field)
+	}
+	d.classes = append(d.classes, def)
+	return nil
+}
+
+func (d *hessianDecoder) readObject(idx int) (interface{}, error) {
+	if idx < 0 || idx >= len(d.classes) {
+		return nil, ErrHessianClassRef
+	}
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	def := d.classes[idx]
+	fields := make(map[string]interface{}, len(def.fields))
+	ref := len(d.refs)
+	d.refs = append(d.refs, fields)
+	for _, name := range def.fields {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = v
+	}
+	if def.t == nil {
+		return fields, nil
+	}
+	obj := reflect.New(def.t)
+	if err := hessianAssigner.assignStruct(obj.Elem(), reflect.ValueOf(fields), 0); err != nil {
+		return nil, err
+	}
+	d.refs[ref] = obj.Interface()
+	return obj.Interface(), nil
+}
@@ -0,0 +1,200 @@
+package serialize
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type hessianCar struct {
+	Color string
+	Model string
+	Year  int32  `hessian:"productionYear"`
+	Skip  string `hessian:"-"`
+}
+
+type hessianGarage struct {
+	Name  string
+	Cars  []*hessianCar
+	Tags  map[string]int64
+	Since time.Time
+}
+
+func TestHessian2Wire(t *testing.T) {
+	h := &Hessian2Serialization{}
+	cases := []struct {
+		v    interface{}
+		wire []byte
+	}{
+		{nil, []byte{'N'}},
+		{true, []byte{'T'}},
+		{int32(0), []byte{0x90}},
+		{int32(-16), []byte{0x80}},
+		{int32(47), []byte{0xbf}},
+		{int32(48), []byte{0xc8, 0x30}},
+		{int16(-256), []byte{0xc7, 0x00}},
+		{int32(262143), []byte{0xd7, 0xff, 0xff}},
+		{int32(262144), []byte{'I', 0x00, 0x04, 0x00, 0x00}},
+		{int64(0), []byte{0xe0}},
+		{int64(-8), []byte{0xd8}},
+		{16, []byte{0xf8, 0x10}},
+		{int64(262143), []byte{0x3f, 0xff, 0xff}},
+		{int64(1 << 20), []byte{0x59, 0x00, 0x10, 0x00, 0x00}},
+		{int64(1 << 40), []byte{'L', 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{0.0, []byte{0x5b}},
+		{1.0, []byte{0x5c}},
+		{float32(127), []byte{0x5d, 0x7f}},
+		{-32768.0, []byte{0x5e, 0x80, 0x00}},
+		{12.25, []byte{0x5f, 0x00, 0x00, 0x2f, 0xda}},
+		{"", []byte{0x00}},
+		{"hello", []byte{0x05, 'h', 'e', 'l', 'l', 'o'}},
+		{"é", []byte{0x01, 0xc3, 0xa9}},
+		{"\U0001F600", []byte{0x02, 0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80}},
+		{[]byte{1, 2, 3}, []byte{0x23, 0x01, 0x02, 0x03}},
+		{[]int32{1, 2}, []byte{0x7a, 0x91, 0x92}},
+	}
+	for _, c := range cases {
+		b, err := h.Serialize(c.v)
+		if err != nil {
+			t.Fatalf("serialize %v fail. err:%v", c.v, err)
+		}
+		if !bytes.Equal(b, c.wire) {
+			t.Errorf("wrong hessian2 bytes of %v(%T). expect:%x, real:%x", c.v, c.v, c.wire, b)
+		}
+		v, err := h.DeSerialize(b, nil)
+		if err != nil {
+			t.Fatalf("deserialize %x fail. err:%v", b, err)
+		}
+		if c.v == nil {
+			continue
+		}
+		nv := reflect.New(reflect.TypeOf(c.v))
+		if _, err = hessianAssigner.decodeInto(v, nv.Interface()); err != nil || !reflect.DeepEqual(nv.Elem().Interface(), c.v) {
+			t.Errorf("deserialize %x not correct. expect:%v, real:%v, err:%v", b, c.v, nv.Elem().Interface(), err)
+		}
+	}
+}
+
+func TestHessian2Object(t *testing.T) {
+	RegisterHessianClass("example.Car", &hessianCar{})
+	h := &Hessian2Serialization{}
+	// objects written by java Hessian2Output
+	wire := []byte{'C', 0x0b}
+	wire = append(wire, "example.Car"...)
+	wire = append(wire, 0x92, 0x05)
+	wire = append(wire, "color"...)
+	wire = append(wire, 0x05)
+	wire = append(wire, "model"...)
+	wire = append(wire, 'O', 0x90, 0x03)
+	wire = append(wire, "red"...)
+	wire = append(wire, 0x08)
+	wire = append(wire, "corvette"...)
+	wire = append(wire, 0x60, 0x05)
+	wire = append(wire, "green"...)
+	wire = append(wire, 0x05)
+	wire = append(wire, "civic"...)
+	values, err := h.DeSerializeMulti(wire, nil)
+	if err != nil {
+		t.Fatalf("deserialize java objects fail. err:%v", err)
+	}
+	if len(values) != 2 || !reflect.DeepEqual(values[0], &hessianCar{Color: "red", Model: "corvette"}) ||
+		!reflect.DeepEqual(values[1], &hessianCar{Color: "green", Model: "civic"}) {
+		t.Errorf("java objects not correct. values:%+v", values)
+	}
+
+	// class definition is shared by the values of a multi serialization
+	cars := []interface{}{&hessianCar{Color: "red", Model: "corvette", Year: 1953, Skip: "x"}, hessianCar{Color: "blue"}}
+	b, err := h.SerializeMulti(cars)
+	if err != nil {
+		t.Fatalf("serialize objects fail. err:%v", err)
+	}
+	if bytes.Count(b, []byte("example.Car")) != 1 || bytes.Contains(b, []byte("skip")) {
+		t.Errorf("class definition should be written once without skipped field. bytes:%x", b)
+	}
+	var c1 hessianCar
+	var c2 *hessianCar
+	values, err = h.DeSerializeMulti(b, []interface{}{&c1, &c2})
+	if err != nil {
+		t.Fatalf("deserialize objects fail. err:%v", err)
+	}
+	if c1.Color != "red" || c1.Model != "corvette" || c1.Year != 1953 || c1.Skip != "" || c2 == nil || c2.Color != "blue" {
+		t.Errorf("objects not correct. c1:%+v, c2:%+v", c1, c2)
+	}
+
+	// unregistered structs are sent as maps, and can be deserialized to structs
+	garage := &hessianGarage{Name: "home", Cars: []*hessianCar{{Color: "red"}, nil}, Tags: map[string]int64{"a": 1},
+		Since: time.Unix(1500000000, 123000000)}
+	b, err = h.Serialize(garage)
+	if err != nil {
+		t.Fatalf("serialize garage fail. err:%v", err)
+	}
+	m, err := h.DeSerialize(b, nil)
+	if err != nil {
+		t.Fatalf("deserialize garage fail. err:%v", err)
+	}
+	if gm, ok := m.(map[interface{}]interface{}); !ok || gm["name"] != "home" {
+		t.Errorf("unregistered struct should be deserialized as map. value:%+v", m)
+	}
+	v, err := h.DeSerialize(b, reflect.TypeOf(&hessianGarage{}))
+	if err != nil {
+		t.Fatalf("deserialize garage by type fail. err:%v", err)
+	}
+	if g := v.(*hessianGarage); g.Name != "home" || len(g.Cars) != 2 || g.Cars[0].Color != "red" || g.Cars[1] != nil ||
+		g.Tags["a"] != 1 || !g.Since.Equal(garage.Since) {
+		t.Errorf("garage not correct. garage:%+v", g)
+	}
+}
+
+func TestHessian2Chunks(t *testing.T) {
+	h := &Hessian2Serialization{}
+	for _, s := range []string{strings.Repeat("a", 70000), strings.Repeat("中\U0001F600", 20000)} {
+		b, err := h.Serialize(s)
+		if err != nil {
+			t.Fatalf("serialize long string fail. err:%v", err)
+		}
+		if b[0] != 'R' {
+			t.Errorf("long string should be chunked. tag:%x", b[0])
+		}
+		var rs string
+		if _, err = h.DeSerialize(b, &rs); err != nil || rs != s {
+			t.Errorf("long string not correct. len:%d, err:%v", len(rs), err)
+		}
+	}
+	data := bytes.Repeat([]byte{1, 2, 3}, 30000)
+	b, err := h.Serialize(data)
+	if err != nil {
+		t.Fatalf("serialize long binary fail. err:%v", err)
+	}
+	var rb []byte
+	if _, err = h.DeSerialize(b, &rb); err != nil || !bytes.Equal(rb, data) || b[0] != 'A' {
+		t.Errorf("long binary not correct. len:%d, err:%v", len(rb), err)
+	}
+}
+
+func TestHessian2Refs(t *testing.T) {
+	h := &Hessian2Serialization{}
+	// a variable length typed list, an untyped map, and a reference to the list
+	wire := []byte{0x55, 0x04}
+	wire = append(wire, "[int"...)
+	wire = append(wire, 0x91, 0x92, 'Z', 'H', 0x01, 'k', 0x51, 0x90, 'Z', 0x57, 'Z')
+	values, err := h.DeSerializeMulti(wire, nil)
+	if err != nil {
+		t.Fatalf("deserialize refs fail. err:%v", err)
+	}
+	list := []interface{}{int32(1), int32(2)}
+	if len(values) != 3 || !reflect.DeepEqual(values[0], list) ||
+		!reflect.DeepEqual(values[1], map[interface{}]interface{}{"k": list}) || len(values[2].([]interface{})) != 0 {
+		t.Errorf("refs not correct. values:%+v", values)
+	}
+	if _, err = h.DeSerialize([]byte{0x51, 0x91}, nil); err != ErrHessianRef {
+		t.Errorf("unknown ref should fail. err:%v", err)
+	}
+	if _, err = h.DeSerialize([]byte{0x61}, nil); err != ErrHessianClassRef {
+		t.Errorf("unknown class should fail. err:%v", err)
+	}
+	if _, err = h.DeSerialize([]byte{0x05, 'a'}, nil); err == nil {
+		t.Errorf("truncated string should fail")
+	}
+}
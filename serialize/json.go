@@ -0,0 +1,422 @@
+package serialize
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// struct tag of the json field names
+const jsonTag = "json"
+
+// JSONTimeUnixMilli is the time format of unix milliseconds, which is the way java serializes dates by default
+const JSONTimeUnixMilli = "unix_ms"
+
+var (
+	ErrJSONFloat = errors.New("json can not represent NaN or Inf")
+
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// JSONSerialization is the JSON serialization, the multi values are sent as a json array.
+// structs are sent as objects by the json tags, the fields without tag are named by the Naming convention
+// (camelCase by default). times are formatted by the TimeFormat layout (time.RFC3339Nano by default) or
+// JSONTimeUnixMilli. numbers are received as int64 or float64, objects as map[string]interface{}.
+// register a JSONSerialization with other options by RegistryExtSerialization to replace the default one.
+type JSONSerialization struct {
+	Naming                string
+	DisallowUnknownFields bool
+	TimeFormat            string
+}
+
+func (j *JSONSerialization) GetSerialNum() int {
+	return 2
+}
+
+func (j *JSONSerialization) Serialize(v interface{}) ([]byte, error) {
+	e := &jsonEncoder{s: j, buf: make([]byte, 0, 256)}
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (j *JSONSerialization) SerializeMulti(v []interface{}) ([]byte, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	e := &jsonEncoder{s: j, buf: make([]byte, 0, 256)}
+	e.buf = append(e.buf, '[')
+	for i, o := range v {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		if err := e.encode(reflect.ValueOf(o), 0); err != nil {
+			return nil, err
+		}
+	}
+	e.buf = append(e.buf, ']')
+	return e.buf, nil
+}
+
+func (j *JSONSerialization) DeSerialize(b []byte, v interface{}) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	value, err := decodeJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	return j.assigner().decodeInto(value, v)
+}
+
+// DeSerializeMulti reads the values from a json array, a body not in array is taken as a single value
+func (j *JSONSerialization) DeSerializeMulti(b []byte, v []interface{}) ([]interface{}, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, nil
+	}
+	value, err := decodeJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := value.([]interface{})
+	if !ok || b[0] != '[' {
+		values = []interface{}{value}
+	}
+	if v == nil {
+		return values, nil
+	}
+	if len(values) != len(v) {
+		return nil, fmt.Errorf("json value count not match. expect:%d, real:%d", len(v), len(values))
+	}
+	a := j.assigner()
+	ret := make([]interface{}, 0, len(v))
+	for i, o := range v {
+		rv, err := a.decodeInto(values[i], o)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, rv)
+	}
+	return ret, nil
+}
+
+func (j *JSONSerialization) assigner() *assigner {
+	return &assigner{tag: jsonTag, naming: j.Naming, strict: j.DisallowUnknownFields, hook: j.assignHook}
+}
+
+// assignHook converts json values to the go types which have no json value type
+func (j *JSONSerialization) assignHook(dst reflect.Value, src interface{}) (bool, error) {
+	if dst.Type() == timeType {
+		t, err := j.parseTime(src)
+		if err != nil {
+			return true, err
+		}
+		dst.Set(reflect.ValueOf(t))
+		return true, nil
+	}
+	if dst.Kind() != reflect.Ptr && dst.CanAddr() && reflect.PtrTo(dst.Type()).Implements(jsonUnmarshalerType) {
+		b, err := json.Marshal(src)
+		if err != nil {
+			return true, err
+		}
+		return true, dst.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(b)
+	}
+	s, ok := src.(string)
+	if !ok {
+		return false, nil
+	}
+	switch dst.Kind() {
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() != reflect.Uint8 {
+			return false, nil
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return true, err
+		}
+		dst.SetBytes(b)
+		return true, nil
+	// numbers in string, such as the keys of objects
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return true, err
+		}
+		dst.SetInt(i)
+		return true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return true, err
+		}
+		dst.SetUint(u)
+		return true, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return true, err
+		}
+		dst.SetFloat(f)
+		return true, nil
+	}
+	return false, nil
+}
+
+// parseTime accepts both the formatted times and unix milliseconds
+func (j *JSONSerialization) parseTime(src interface{}) (time.Time, error) {
+	switch t := src.(type) {
+	case int64:
+		return time.Unix(0, t*int64(time.Millisecond)), nil
+	case float64:
+		return time.Unix(0, int64(t*float64(time.Millisecond))), nil
+	case string:
+		layout := j.TimeFormat
+		if layout == "" || layout == JSONTimeUnixMilli {
+			layout = time.RFC3339Nano
+		}
+		return time.Parse(layout, t)
+	}
+	return time.Time{}, fmt.Errorf("can not assign %T to time", src)
+}
+
+// decodeJSON decodes the body with numbers in int64 if possible, otherwise float64.
+// the DeserializeLimits except total bytes are checked on the decoded values
+func decodeJSON(b []byte) (interface{}, error) {
+	limits := loadLimits()
+	if err := limits.checkTotal(len(b)); err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var value interface{}
+	if err := d.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("json has extra data after the value")
+	}
+	return normalizeJSON(value, limits, 0)
+}
+
+func normalizeJSON(v interface{}, limits *DeserializeLimits, depth int) (interface{}, error) {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	case string:
+		if err := limits.checkString(len(t)); err != nil {
+			return nil, err
+		}
+	case []interface{}:
+		if err := checkJSONCollection(limits, depth+1, len(t)); err != nil {
+			return nil, err
+		}
+		for i, o := range t {
+			n, err := normalizeJSON(o, limits, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = n
+		}
+	case map[string]interface{}:
+		if err := checkJSONCollection(limits, depth+1, len(t)); err != nil {
+			return nil, err
+		}
+		for k, o := range t {
+			if err := limits.checkString(len(k)); err != nil {
+				return nil, err
+			}
+			n, err := normalizeJSON(o, limits, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = n
+		}
+	}
+	return v, nil
+}
+
+func checkJSONCollection(limits *DeserializeLimits, depth int, n int) error {
+	if err := limits.checkDepth(depth); err != nil {
+		return err
+	}
+	return limits.checkCollection(n)
+}
+
+type jsonEncoder struct {
+	s   *JSONSerialization
+	buf []byte
+}
+
+func (e *jsonEncoder) encode(rv reflect.Value, depth int) error {
+	if depth > maxAssignDepth {
+		return ErrAssignDepth
+	}
+	if !rv.IsValid() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	if rv.Type() == timeType {
+		e.writeTime(rv.Interface().(time.Time))
+		return nil
+	}
+	if rv.Type().Implements(jsonMarshalerType) && !(rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		b, err := rv.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return err
+		}
+		e.buf = append(e.buf, b...)
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		return e.encode(rv.Elem(), depth+1)
+	case reflect.Bool:
+		e.buf = strconv.AppendBool(e.buf, rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.buf = strconv.AppendInt(e.buf, rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.buf = strconv.AppendUint(e.buf, rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return ErrJSONFloat
+		}
+		e.buf = strconv.AppendFloat(e.buf, f, 'g', -1, rv.Type().Bits())
+	case reflect.String:
+		e.writeString(rv.String())
+	case reflect.Slice:
+		if rv.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBytes(rv.Bytes())
+			return nil
+		}
+		return e.writeArray(rv, depth)
+	case reflect.Array:
+		return e.writeArray(rv, depth)
+	case reflect.Map:
+		if rv.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		e.buf = append(e.buf, '{')
+		for i, k := range rv.MapKeys() {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.writeString(mapKey(k))
+			e.buf = append(e.buf, ':')
+			if err := e.encode(rv.MapIndex(k), depth+1); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, '}')
+	case reflect.Struct:
+		e.buf = append(e.buf, '{')
+		first := true
+		for _, f := range getStructFields(rv.Type(), jsonTag, e.s.Naming) {
+			fv := rv.Field(f.index)
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			if !first {
+				e.buf = append(e.buf, ',')
+			}
+			first = false
+			e.writeString(f.name)
+			e.buf = append(e.buf, ':')
+			if err := e.encode(fv, depth+1); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, '}')
+	default:
+		return fmt.Errorf("not support type by JSONSerialization: %s", rv.Type())
+	}
+	return nil
+}
+
+func (e *jsonEncoder) writeArray(rv reflect.Value, depth int) error {
+	e.buf = append(e.buf, '[')
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		if err := e.encode(rv.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	e.buf = append(e.buf, ']')
+	return nil
+}
+
+func (e *jsonEncoder) writeString(s string) {
+	b, _ := json.Marshal(s)
+	e.buf = append(e.buf, b...)
+}
+
+func (e *jsonEncoder) writeBytes(b []byte) {
+	e.buf = append(e.buf, '"')
+	e.buf = append(e.buf, base64.StdEncoding.EncodeToString(b)...)
+	e.buf = append(e.buf, '"')
+}
+
+func (e *jsonEncoder) writeTime(t time.Time) {
+	switch e.s.TimeFormat {
+	case JSONTimeUnixMilli:
+		e.buf = strconv.AppendInt(e.buf, t.UnixNano()/int64(time.Millisecond), 10)
+	case "":
+		e.writeString(t.Format(time.RFC3339Nano))
+	default:
+		e.writeString(t.Format(e.s.TimeFormat))
+	}
+}
+
+func mapKey(k reflect.Value) string {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
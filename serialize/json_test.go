@@ -0,0 +1,106 @@
+package serialize
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type jsonOrder struct {
+	OrderID   int64
+	UserName  string `json:"user"`
+	Items     []string
+	Price     float64 `json:",omitempty"`
+	Data      []byte
+	Extra     map[int]string
+	CreatedAt time.Time
+	Raw       json.RawMessage
+	Ignored   string `json:"-"`
+}
+
+func TestJSONNaming(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	order := &jsonOrder{OrderID: 1, UserName: "ray", Items: []string{"a"}, Data: []byte{1, 2},
+		Extra: map[int]string{7: "x"}, CreatedAt: created, Raw: json.RawMessage(`{"k":[1]}`), Ignored: "i"}
+	cases := []struct {
+		s    *JSONSerialization
+		wire string
+	}{
+		{&JSONSerialization{}, `{"orderID":1,"user":"ray","items":["a"],"data":"AQI=","extra":{"7":"x"},` +
+			`"createdAt":"2020-01-02T03:04:05.006Z","raw":{"k":[1]}}`},
+		{&JSONSerialization{Naming: NamingSnakeCase, TimeFormat: JSONTimeUnixMilli},
+			`{"order_id":1,"user":"ray","items":["a"],"data":"AQI=","extra":{"7":"x"},"created_at":1577934245006,"raw":{"k":[1]}}`},
+		{&JSONSerialization{Naming: NamingNone, TimeFormat: "2006-01-02 15:04:05.000"},
+			`{"OrderID":1,"user":"ray","Items":["a"],"Data":"AQI=","Extra":{"7":"x"},"CreatedAt":"2020-01-02 03:04:05.006","Raw":{"k":[1]}}`},
+	}
+	for _, c := range cases {
+		b, err := c.s.Serialize(order)
+		if err != nil {
+			t.Fatalf("serialize order fail. err:%v", err)
+		}
+		if string(b) != c.wire {
+			t.Errorf("wrong json of naming %s. expect:%s, real:%s", c.s.Naming, c.wire, b)
+		}
+		var ro jsonOrder
+		if _, err = c.s.DeSerialize(b, &ro); err != nil {
+			t.Fatalf("deserialize order fail. err:%v", err)
+		}
+		order.Ignored = ""
+		if !ro.CreatedAt.Equal(created) {
+			t.Errorf("time not correct. time:%v", ro.CreatedAt)
+		}
+		ro.CreatedAt = created
+		if !reflect.DeepEqual(&ro, order) {
+			t.Errorf("order not correct. expect:%+v, real:%+v", order, ro)
+		}
+	}
+}
+
+func TestJSONUnknownFields(t *testing.T) {
+	b := []byte(`{"orderId":"12","user":"ray","unknown":true}`)
+	v, err := (&JSONSerialization{}).DeSerialize(b, reflect.TypeOf(&jsonOrder{}))
+	if err != nil {
+		t.Fatalf("unknown fields should be ignored. err:%v", err)
+	}
+	if o := v.(*jsonOrder); o.OrderID != 12 || o.UserName != "ray" {
+		t.Errorf("order not correct. order:%+v", o)
+	}
+	if _, err = (&JSONSerialization{DisallowUnknownFields: true}).DeSerialize(b, reflect.TypeOf(&jsonOrder{})); err == nil ||
+		!strings.Contains(err.Error(), "unknown") {
+		t.Errorf("unknown fields should fail. err:%v", err)
+	}
+}
+
+func TestJSONMulti(t *testing.T) {
+	j := &JSONSerialization{}
+	b, err := j.SerializeMulti([]interface{}{"a", 1, []int{2}, nil, map[string]float64{"f": 1.5}})
+	if err != nil {
+		t.Fatalf("serialize multi fail. err:%v", err)
+	}
+	if string(b) != `["a",1,[2],null,{"f":1.5}]` {
+		t.Errorf("wrong multi json. json:%s", b)
+	}
+	values, err := j.DeSerializeMulti(b, nil)
+	expect := []interface{}{"a", int64(1), []interface{}{int64(2)}, nil, map[string]interface{}{"f": 1.5}}
+	if err != nil || !reflect.DeepEqual(values, expect) {
+		t.Errorf("multi values not correct. values:%v, err:%v", values, err)
+	}
+	var s string
+	var n int32
+	if _, err = j.DeSerializeMulti([]byte(` ["s", 3.0] `), []interface{}{&s, &n}); err != nil || s != "s" || n != 3 {
+		t.Errorf("typed multi values not correct. s:%s, n:%d, err:%v", s, n, err)
+	}
+	// a single value not in array
+	values, err = j.DeSerializeMulti([]byte(`{"user":"ray"}`), []interface{}{reflect.TypeOf(jsonOrder{})})
+	if err != nil || len(values) != 1 || values[0].(jsonOrder).UserName != "ray" {
+		t.Errorf("single value not correct. values:%v, err:%v", values, err)
+	}
+	if _, err = j.DeSerializeMulti(b, []interface{}{&s}); err == nil {
+		t.Errorf("value count not match should fail")
+	}
+	if _, err = j.Serialize(map[string]interface{}{"nan": func() {}}); err == nil {
+		t.Errorf("unsupported type should fail")
+	}
+}
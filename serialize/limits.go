@@ -0,0 +1,82 @@
+package serialize
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// DefaultMaxDeserializeDepth is the default max nesting depth of the decoded values
+const DefaultMaxDeserializeDepth = 256
+
+// names of the limits in LimitError
+const (
+	LimitStringLength   = "string length"
+	LimitCollectionSize = "collection size"
+	LimitDepth          = "nesting depth"
+	LimitTotalBytes     = "total bytes"
+)
+
+// DeserializeLimits limits the values decoded from the payloads, so a malicious or corrupt payload fails
+// with a LimitError instead of exhausting the memory or the stack. a limit less than or equal to 0 means no limit.
+// strings, collections and depth are checked by simple, hessian2, msgpack and json, only the total bytes
+// are checked by protobuf and thrift, which decode into the generated messages only.
+type DeserializeLimits struct {
+	MaxStringLength   int // bytes of a string or a byte array
+	MaxCollectionSize int // elements of an array, or entries of a map or an object
+	MaxDepth          int // nesting depth of arrays, maps and objects
+	MaxTotalBytes     int // bytes of a body
+}
+
+// LimitError is returned when a decoded value exceeds the DeserializeLimits
+type LimitError struct {
+	Limit string
+	Size  int
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("deserialize limit exceeded: %s %d is larger than %d", e.Limit, e.Size, e.Max)
+}
+
+var deserializeLimits atomic.Value
+
+func init() {
+	deserializeLimits.Store(&DeserializeLimits{MaxDepth: DefaultMaxDeserializeDepth})
+}
+
+// SetDeserializeLimits sets the limits used by all the serializations
+func SetDeserializeLimits(limits DeserializeLimits) {
+	deserializeLimits.Store(&limits)
+}
+
+// GetDeserializeLimits returns the limits used by all the serializations
+func GetDeserializeLimits() DeserializeLimits {
+	return *loadLimits()
+}
+
+func loadLimits() *DeserializeLimits {
+	return deserializeLimits.Load().(*DeserializeLimits)
+}
+
+func checkLimit(limit string, size int, max int) error {
+	if max > 0 && size > max {
+		return &LimitError{Limit: limit, Size: size, Max: max}
+	}
+	return nil
+}
+
+func (l *DeserializeLimits) checkString(n int) error {
+	return checkLimit(LimitStringLength, n, l.MaxStringLength)
+}
+
+func (l *DeserializeLimits) checkCollection(n int) error {
+	return checkLimit(LimitCollectionSize, n, l.MaxCollectionSize)
+}
+
+func (l *DeserializeLimits) checkDepth(depth int) error {
+	return checkLimit(LimitDepth, depth, l.MaxDepth)
+}
+
+func (l *DeserializeLimits) checkTotal(n int) error {
+	return checkLimit(LimitTotalBytes, n, l.MaxTotalBytes)
+}
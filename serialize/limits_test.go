@@ -0,0 +1,72 @@
+package serialize
+
+import (
+	"reflect"
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func TestDeserializeLimits(t *testing.T) {
+	defer SetDeserializeLimits(GetDeserializeLimits())
+	if GetDeserializeLimits().MaxDepth != DefaultMaxDeserializeDepth {
+		t.Errorf("wrong default limits. limits:%+v", GetDeserializeLimits())
+	}
+	nested := []interface{}{[]interface{}{[]interface{}{"a"}}}
+	cases := []struct {
+		value  interface{}
+		limits DeserializeLimits
+		limit  string
+	}{
+		{"0123456789", DeserializeLimits{MaxStringLength: 5}, LimitStringLength},
+		{[]interface{}{"a", "b", "c"}, DeserializeLimits{MaxCollectionSize: 2}, LimitCollectionSize},
+		{map[string]interface{}{"a": "1", "b": "2", "c": "3"}, DeserializeLimits{MaxCollectionSize: 2}, LimitCollectionSize},
+		{nested, DeserializeLimits{MaxDepth: 2}, LimitDepth},
+		{"0123456789", DeserializeLimits{MaxTotalBytes: 5}, LimitTotalBytes},
+	}
+	serializations := []motan.Serialization{&SimpleSerialization{}, &Hessian2Serialization{}, &MsgpackSerialization{}, &JSONSerialization{}}
+	for _, s := range serializations {
+		for _, c := range cases {
+			SetDeserializeLimits(DeserializeLimits{})
+			b, err := s.Serialize(c.value)
+			if err != nil {
+				t.Fatalf("serialize fail. serialization:%d, err:%v", s.GetSerialNum(), err)
+			}
+			if _, err = s.DeSerialize(b, nil); err != nil {
+				t.Errorf("deserialize without limits fail. serialization:%d, err:%v", s.GetSerialNum(), err)
+			}
+			SetDeserializeLimits(c.limits)
+			_, err = s.DeSerialize(b, nil)
+			if le, ok := err.(*LimitError); !ok || le.Limit != c.limit {
+				t.Errorf("limit %s not checked. serialization:%d, err:%v", c.limit, s.GetSerialNum(), err)
+			}
+			if _, err = s.DeSerializeMulti(b, nil); err == nil {
+				t.Errorf("limit %s not checked in multi values. serialization:%d", c.limit, s.GetSerialNum())
+			}
+		}
+	}
+
+	SetDeserializeLimits(DeserializeLimits{MaxStringLength: 10, MaxCollectionSize: 3, MaxDepth: 3})
+	for _, s := range serializations {
+		for _, c := range cases[:4] {
+			b, _ := s.Serialize(c.value)
+			if _, err := s.DeSerialize(b, nil); err != nil {
+				t.Errorf("values within limits should pass. serialization:%d, err:%v", s.GetSerialNum(), err)
+			}
+		}
+	}
+
+	SetDeserializeLimits(DeserializeLimits{MaxTotalBytes: 4})
+	b, _ := (&ThriftSerialization{}).Serialize(&thriftUser{Name: "ray", Age: 30})
+	if _, err := (&ThriftSerialization{}).DeSerialize(b, &thriftUser{}); err == nil {
+		t.Errorf("total bytes not checked by thrift")
+	}
+	b, _ = (&PbSerialization{}).Serialize("0123456789")
+	if _, err := (&PbSerialization{}).DeSerialize(b, reflect.TypeOf("")); err == nil {
+		t.Errorf("total bytes not checked by protobuf")
+	}
+	err := &LimitError{Limit: LimitDepth, Size: 3, Max: 2}
+	if err.Error() != "deserialize limit exceeded: nesting depth 3 is larger than 2" {
+		t.Errorf("wrong error message. message:%s", err.Error())
+	}
+}
@@ -0,0 +1,628 @@
+package serialize
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// struct tag of the msgpack field names
+const msgpackTag = "msgpack"
+
+var msgpackAssigner = &assigner{tag: msgpackTag}
+
+// msgpack timestamp extension type
+const msgpackTimestamp = -1
+
+var (
+	ErrMsgpackExt = errors.New("msgpack extension type not supported")
+)
+
+// MsgpackSerialization is the MessagePack serialization. structs are sent as maps by the field names,
+// which are the msgpack tags or the field names with the first letter in lower case.
+// ints are received as int64 (uint64 if it overflows int64), floats as float64 or float32,
+// arrays as []interface{}, maps as map[interface{}]interface{} and timestamps as time.Time.
+type MsgpackSerialization struct{}
+
+func (m *MsgpackSerialization) GetSerialNum() int {
+	return 3
+}
+
+func (m *MsgpackSerialization) Serialize(v interface{}) ([]byte, error) {
+	e := &MsgpackEncoder{buf: make([]byte, 0, 256)}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (m *MsgpackSerialization) SerializeMulti(v []interface{}) ([]byte, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	e := &MsgpackEncoder{buf: make([]byte, 0, 256)}
+	for _, o := range v {
+		if err := e.encode(o); err != nil {
+			return nil, err
+		}
+	}
+	return e.buf, nil
+}
+
+func (m *MsgpackSerialization) DeSerialize(b []byte, v interface{}) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	return m.DeSerializeFrom(bytes.NewReader(b), v)
+}
+
+func (m *MsgpackSerialization) DeSerializeMulti(b []byte, v []interface{}) ([]interface{}, error) {
+	return m.DeSerializeMultiFrom(bytes.NewReader(b), v)
+}
+
+func (m *MsgpackSerialization) SerializeTo(w io.Writer, v interface{}) error {
+	return NewMsgpackEncoder(w).Encode(v)
+}
+
+func (m *MsgpackSerialization) SerializeMultiTo(w io.Writer, v []interface{}) error {
+	e := NewMsgpackEncoder(w)
+	for _, o := range v {
+		if err := e.Encode(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeSerializeFrom reads one value, nil is returned for an empty stream
+func (m *MsgpackSerialization) DeSerializeFrom(r io.Reader, v interface{}) (interface{}, error) {
+	value, err := NewMsgpackDecoder(r).Decode(v)
+	if err == io.EOF {
+		return nil, nil
+	}
+	return value, err
+}
+
+// DeSerializeMultiFrom reads the values of v, or all values to the end of stream if v is nil
+func (m *MsgpackSerialization) DeSerializeMultiFrom(r io.Reader, v []interface{}) ([]interface{}, error) {
+	ret := make([]interface{}, 0, len(v))
+	d := NewMsgpackDecoder(r)
+	if v != nil {
+		for _, o := range v {
+			value, err := d.Decode(o)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, value)
+		}
+		return ret, nil
+	}
+	for {
+		value, err := d.Decode(nil)
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, value)
+	}
+}
+
+// MsgpackEncoder writes msgpack values to a stream
+type MsgpackEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewMsgpackEncoder(w io.Writer) *MsgpackEncoder {
+	return &MsgpackEncoder{w: w, buf: make([]byte, 0, 256)}
+}
+
+// Encode writes one value to the stream
+func (e *MsgpackEncoder) Encode(v interface{}) error {
+	e.buf = e.buf[:0]
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+func (e *MsgpackEncoder) encode(v interface{}) error {
+	if rv, ok := v.(reflect.Value); ok {
+		return e.encodeValue(rv)
+	}
+	return e.encodeValue(reflect.ValueOf(v))
+}
+
+func (e *MsgpackEncoder) encodeValue(rv reflect.Value) error {
+	if !rv.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encodeValue(rv.Elem())
+	case reflect.Bool:
+		if rv.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeUint(rv.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, 0xca)
+		e.buf = appendUint32(e.buf, math.Float32bits(float32(rv.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = appendUint64(e.buf, math.Float64bits(rv.Float()))
+	case reflect.String:
+		e.writeString(rv.String())
+	case reflect.Slice:
+		if rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBinary(rv.Bytes())
+			return nil
+		}
+		return e.writeArray(rv)
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			e.writeBinary(b)
+			return nil
+		}
+		return e.writeArray(rv)
+	case reflect.Map:
+		if rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		e.writeLength(rv.Len(), 0x80, 0xde)
+		for _, k := range rv.MapKeys() {
+			if err := e.encodeValue(k); err != nil {
+				return err
+			}
+			if err := e.encodeValue(rv.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		if rv.Type() == timeType {
+			e.writeTime(rv.Interface().(time.Time))
+			return nil
+		}
+		fields := msgpackAssigner.fields(rv.Type())
+		e.writeLength(len(fields), 0x80, 0xde)
+		for _, f := range fields {
+			e.writeString(f.name)
+			if err := e.encodeValue(rv.Field(f.index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("not support type by MsgpackSerialization: %s", rv.Type())
+	}
+	return nil
+}
+
+func (e *MsgpackEncoder) writeInt(v int64) {
+	switch {
+	case v >= 0:
+		e.writeUint(uint64(v))
+	case v >= -32:
+		e.buf = append(e.buf, byte(v))
+	case v >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = appendUint16(e.buf, uint16(v))
+	case v >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = appendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = appendUint64(e.buf, uint64(v))
+	}
+}
+
+func (e *MsgpackEncoder) writeUint(v uint64) {
+	switch {
+	case v <= 0x7f:
+		e.buf = append(e.buf, byte(v))
+	case v <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = appendUint16(e.buf, uint16(v))
+	case v <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = appendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = appendUint64(e.buf, v)
+	}
+}
+
+func (e *MsgpackEncoder) writeString(s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		e.buf = append(e.buf, byte(0xa0|n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *MsgpackEncoder) writeBinary(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *MsgpackEncoder) writeArray(rv reflect.Value) error {
+	e.writeLength(rv.Len(), 0x90, 0xdc)
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.encodeValue(rv.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLength writes the length of array or map, tag16 + 1 is the tag of 32 bits length
+func (e *MsgpackEncoder) writeLength(n int, fixTag byte, tag16 byte) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, fixTag|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, tag16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, tag16+1)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+}
+
+// writeTime writes the timestamp extension in the smallest format
+func (e *MsgpackEncoder) writeTime(t time.Time) {
+	sec, nsec := uint64(t.Unix()), uint64(t.Nanosecond())
+	if sec>>34 == 0 {
+		if data := nsec<<34 | sec; data&0xffffffff00000000 == 0 {
+			e.buf = append(e.buf, 0xd6, 0xff)
+			e.buf = appendUint32(e.buf, uint32(data))
+		} else {
+			e.buf = append(e.buf, 0xd7, 0xff)
+			e.buf = appendUint64(e.buf, data)
+		}
+		return
+	}
+	e.buf = append(e.buf, 0xc7, 12, 0xff)
+	e.buf = appendUint32(e.buf, uint32(nsec))
+	e.buf = appendUint64(e.buf, sec)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// MsgpackDecoder reads msgpack values from a stream
+type MsgpackDecoder struct {
+	r       byteReader
+	scratch [8]byte
+	limits  *DeserializeLimits
+	// bytes read and the nesting depth of the arrays and maps being read
+	read  int
+	depth int
+}
+
+// NewMsgpackDecoder creates a decoder with the current DeserializeLimits, the MaxTotalBytes limits all the bytes read by the decoder
+func NewMsgpackDecoder(r io.Reader) *MsgpackDecoder {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &MsgpackDecoder{r: br, limits: loadLimits()}
+}
+
+// Decode reads one value from the stream. v is nil to return the value as is, a pointer to fill, or a reflect.Type to create a value of
+func (d *MsgpackDecoder) Decode(v interface{}) (interface{}, error) {
+	value, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	return msgpackAssigner.decodeInto(value, v)
+}
+
+func (d *MsgpackDecoder) readByte() (byte, error) {
+	if err := d.limits.checkTotal(d.read + 1); err != nil {
+		return 0, err
+	}
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.read++
+	}
+	return b, err
+}
+
+func (d *MsgpackDecoder) readN(n int) ([]byte, error) {
+	if err := d.limits.checkTotal(d.read + n); err != nil {
+		return nil, err
+	}
+	d.read += n
+	if n <= len(d.scratch) {
+		b := d.scratch[:n]
+		_, err := io.ReadFull(d.r, b)
+		return b, err
+	}
+	// the size is checked by the remaining bytes if possible, so a broken length never allocates too much
+	if l, ok := d.r.(interface{ Len() int }); ok && l.Len() < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	return b, err
+}
+
+func (d *MsgpackDecoder) readUint(n int) (uint64, error) {
+	b, err := d.readN(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// decodeElem decodes the elements of arrays and maps, the end of stream is unexpected there
+func (d *MsgpackDecoder) decodeElem() (interface{}, error) {
+	v, err := d.decode()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func (d *MsgpackDecoder) decode() (interface{}, error) {
+	tag, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case tag <= 0x7f:
+		return int64(tag), nil
+	case tag >= 0xe0:
+		return int64(int8(tag)), nil
+	case tag >= 0xa0 && tag <= 0xbf:
+		return d.readString(int(tag & 0x1f))
+	case tag >= 0x90 && tag <= 0x9f:
+		return d.readArray(int(tag & 0x0f))
+	case tag >= 0x80 && tag <= 0x8f:
+		return d.readMap(int(tag & 0x0f))
+	}
+	switch tag {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.readUint(1 << (tag - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0:
+		u, err := d.readUint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.readUint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.readUint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.readUint(8)
+		return int64(u), err
+	case 0xca:
+		u, err := d.readUint(4)
+		return math.Float32frombits(uint32(u)), err
+	case 0xcb:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readUint(1 << (tag - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readUint(1 << (tag - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		if err = d.limits.checkString(int(n)); err != nil {
+			return nil, err
+		}
+		b, err := d.readN(int(n))
+		if err != nil {
+			return nil, err
+		}
+		if n <= uint64(len(d.scratch)) {
+			// never return the scratch buffer
+			b = append([]byte(nil), b...)
+		}
+		return b, nil
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (tag - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.readArray(int(n))
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (tag - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.readMap(int(n))
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (tag - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readUint(1 << (tag - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(int(n))
+	}
+	return nil, fmt.Errorf("unknown msgpack tag: 0x%x", tag)
+}
+
+func (d *MsgpackDecoder) readString(n int) (string, error) {
+	if err := d.limits.checkString(n); err != nil {
+		return "", err
+	}
+	b, err := d.readN(n)
+	return string(b), err
+}
+
+// enter checks the depth and the size of the array or map to read
+func (d *MsgpackDecoder) enter(n int) error {
+	d.depth++
+	if err := d.limits.checkDepth(d.depth); err != nil {
+		return err
+	}
+	return d.limits.checkCollection(n)
+}
+
+func (d *MsgpackDecoder) leave() {
+	d.depth--
+}
+
+func (d *MsgpackDecoder) readArray(n int) ([]interface{}, error) {
+	if err := d.enter(n); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	a := make([]interface{}, 0, minInt(n, 64))
+	for i := 0; i < n; i++ {
+		v, err := d.decodeElem()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func (d *MsgpackDecoder) readMap(n int) (map[interface{}]interface{}, error) {
+	if err := d.enter(n); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	m := make(map[interface{}]interface{}, minInt(n, 64))
+	for i := 0; i < n; i++ {
+		k, err := d.decodeElem()
+		if err != nil {
+			return nil, err
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("msgpack map key type %T is not supported", k)
+		}
+		v, err := d.decodeElem()
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// readExt reads the extension with n bytes data, only the timestamp is supported
+func (d *MsgpackDecoder) readExt(n int) (interface{}, error) {
+	t, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if int8(t) != msgpackTimestamp {
+		return nil, ErrMsgpackExt
+	}
+	switch n {
+	case 4:
+		sec, err := d.readUint(4)
+		return time.Unix(int64(sec), 0), err
+	case 8:
+		data, err := d.readUint(8)
+		return time.Unix(int64(data&0x3ffffffff), int64(data>>34)), err
+	case 12:
+		nsec, err := d.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		sec, err := d.readUint(8)
+		return time.Unix(int64(sec), int64(nsec)), err
+	}
+	return nil, ErrMsgpackExt
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
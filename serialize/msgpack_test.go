@@ -0,0 +1,160 @@
+package serialize
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type msgpackUser struct {
+	Name    string
+	Age     int            `msgpack:"age"`
+	Tags    []string       `msgpack:"tags,omitempty"`
+	Attrs   map[string]int `msgpack:"attrs"`
+	Created time.Time
+	Friend  *msgpackUser
+}
+
+func TestMsgpackWire(t *testing.T) {
+	m := &MsgpackSerialization{}
+	cases := []struct {
+		v    interface{}
+		wire []byte
+	}{
+		{nil, []byte{0xc0}},
+		{false, []byte{0xc2}},
+		{int64(1), []byte{0x01}},
+		{-1, []byte{0xff}},
+		{-33, []byte{0xd0, 0xdf}},
+		{200, []byte{0xcc, 0xc8}},
+		{int16(-300), []byte{0xd1, 0xfe, 0xd4}},
+		{uint32(70000), []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{int64(-1) << 40, []byte{0xd3, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{uint64(1) << 63, []byte{0xcf, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{float32(1.5), []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{[]byte{1, 2}, []byte{0xc4, 0x02, 0x01, 0x02}},
+		{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{map[string]bool{"a": true}, []byte{0x81, 0xa1, 'a', 0xc3}},
+		{time.Unix(1, 0), []byte{0xd6, 0xff, 0, 0, 0, 1}},
+		{time.Unix(1, 1), []byte{0xd7, 0xff, 0, 0, 0, 0x04, 0, 0, 0, 1}},
+	}
+	for _, c := range cases {
+		b, err := m.Serialize(c.v)
+		if err != nil {
+			t.Fatalf("serialize %v fail. err:%v", c.v, err)
+		}
+		if !bytes.Equal(b, c.wire) {
+			t.Errorf("wrong msgpack bytes of %v(%T). expect:%x, real:%x", c.v, c.v, c.wire, b)
+		}
+		if c.v == nil {
+			continue
+		}
+		v, err := m.DeSerialize(b, reflect.TypeOf(c.v))
+		if err != nil || !reflect.DeepEqual(v, c.v) {
+			t.Errorf("deserialize %x not correct. expect:%v, real:%v, err:%v", b, c.v, v, err)
+		}
+	}
+
+	// large values use the long formats
+	s := strings.Repeat("x", 70000)
+	b, _ := m.Serialize(s)
+	if b[0] != 0xdb {
+		t.Errorf("long string should use str32. tag:%x", b[0])
+	}
+	var rs string
+	if _, err := m.DeSerialize(b, &rs); err != nil || rs != s {
+		t.Errorf("long string not correct. len:%d, err:%v", len(rs), err)
+	}
+	if _, err := m.DeSerialize([]byte{0xdb, 0x7f, 0xff, 0xff, 0xff, 'a'}, nil); err == nil {
+		t.Errorf("truncated string should fail")
+	}
+	if _, err := m.DeSerialize([]byte{0xd4, 0x01, 0x00}, nil); err != ErrMsgpackExt {
+		t.Errorf("unknown extension should fail. err:%v", err)
+	}
+}
+
+func TestMsgpackStruct(t *testing.T) {
+	m := &MsgpackSerialization{}
+	user := &msgpackUser{Name: "ray", Age: 30, Tags: []string{"a", "b"}, Attrs: map[string]int{"x": 1},
+		Created: time.Unix(1500000000, 123456789), Friend: &msgpackUser{Name: "joe"}}
+	b, err := m.SerializeMulti([]interface{}{user, "end"})
+	if err != nil {
+		t.Fatalf("serialize struct fail. err:%v", err)
+	}
+	var ru msgpackUser
+	var end string
+	if _, err = m.DeSerializeMulti(b, []interface{}{&ru, &end}); err != nil {
+		t.Fatalf("deserialize struct fail. err:%v", err)
+	}
+	if ru.Name != "ray" || ru.Age != 30 || !reflect.DeepEqual(ru.Tags, user.Tags) || ru.Attrs["x"] != 1 ||
+		!ru.Created.Equal(user.Created) || ru.Friend == nil || ru.Friend.Name != "joe" || end != "end" {
+		t.Errorf("struct not correct. user:%+v, end:%s", ru, end)
+	}
+	values, err := m.DeSerializeMulti(b, nil)
+	if err != nil || len(values) != 2 {
+		t.Fatalf("deserialize without types fail. values:%v, err:%v", values, err)
+	}
+	if um, ok := values[0].(map[interface{}]interface{}); !ok || um["name"] != "ray" || um["age"] != int64(30) {
+		t.Errorf("struct should be deserialized as map. value:%+v", values[0])
+	}
+}
+
+// onlyReader hides the io.ByteReader of the underlying reader
+type onlyReader struct {
+	r io.Reader
+}
+
+func (o *onlyReader) Read(p []byte) (int, error) {
+	return o.r.Read(p)
+}
+
+func TestMsgpackStream(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		e := NewMsgpackEncoder(w)
+		for i := 0; i < 100; i++ {
+			e.Encode(map[string]interface{}{"seq": i, "data": strings.Repeat("d", i)})
+		}
+		w.Close()
+	}()
+	d := NewMsgpackDecoder(&onlyReader{r: r})
+	for i := 0; i < 100; i++ {
+		var v struct {
+			Seq  int
+			Data string
+		}
+		if _, err := d.Decode(&v); err != nil {
+			t.Fatalf("decode stream fail. seq:%d, err:%v", i, err)
+		}
+		if v.Seq != i || len(v.Data) != i {
+			t.Errorf("stream value not correct. expect:%d, value:%+v", i, v)
+		}
+	}
+	if _, err := d.Decode(nil); err != io.EOF {
+		t.Errorf("decode should return EOF at the end of stream. err:%v", err)
+	}
+}
+
+func TestMsgpackSerializationStream(t *testing.T) {
+	m := &MsgpackSerialization{}
+	var buf bytes.Buffer
+	if err := m.SerializeMultiTo(&buf, []interface{}{"a", []int{1, 2}}); err != nil {
+		t.Fatalf("serialize to stream fail. err:%v", err)
+	}
+	values, err := m.DeSerializeMultiFrom(&onlyReader{r: bytes.NewReader(buf.Bytes())}, nil)
+	if err != nil || !reflect.DeepEqual(values, []interface{}{"a", []interface{}{int64(1), int64(2)}}) {
+		t.Errorf("deserialize from stream not correct. values:%v, err:%v", values, err)
+	}
+	if v, err := m.DeSerializeFrom(bytes.NewReader(nil), nil); v != nil || err != nil {
+		t.Errorf("empty stream should be nil. value:%v, err:%v", v, err)
+	}
+	// the end of stream inside an array is not a clean end
+	if _, err = m.DeSerializeMultiFrom(bytes.NewReader([]byte{0x92, 0x01}), nil); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated array should fail. err:%v", err)
+	}
+}
@@ -65,6 +65,9 @@ func (g *GrpcPbSerialization) DeSerialize(b []byte, v interface{}) (interface{},
 	if v == nil {
 		return nil, ErrNilParam
 	}
+	if err := loadLimits().checkTotal(len(b)); err != nil {
+		return nil, err
+	}
 	if message, ok := v.(proto.Message); ok {
 		err := proto.Unmarshal(b, message)
 		return message, err
@@ -165,11 +168,17 @@ func (p *PbSerialization) serializeBuf(buf *proto.Buffer, v interface{}) (err er
 }
 
 func (p *PbSerialization) DeSerialize(b []byte, v interface{}) (interface{}, error) {
+	if err := loadLimits().checkTotal(len(b)); err != nil {
+		return nil, err
+	}
 	buf := proto.NewBuffer(b)
 	return p.deSerializeBuf(buf, v)
 }
 
 func (p *PbSerialization) DeSerializeMulti(b []byte, v []interface{}) ([]interface{}, error) {
+	if err := loadLimits().checkTotal(len(b)); err != nil {
+		return nil, err
+	}
 	ret := make([]interface{}, len(v), len(v))
 	buf := proto.NewBuffer(b)
 	for i, sv := range v {
@@ -5,9 +5,15 @@ import (
 )
 
 const (
-	Simple = "simple"
-	Pb     = "protobuf"
-	GrpcPb = "grpc-pb"
+	Simple   = "simple"
+	Pb       = "protobuf"
+	GrpcPb   = "grpc-pb"
+	Hessian2 = "hessian2"
+	Msgpack  = "msgpack"
+	JSON     = "json"
+
+	Thrift        = "thrift"
+	ThriftCompact = "thrift-compact"
 )
 
 func RegistDefaultSerializations(extFactory motan.ExtensionFactory) {
@@ -20,4 +26,19 @@ func RegistDefaultSerializations(extFactory motan.ExtensionFactory) {
 	extFactory.RegistryExtSerialization(GrpcPb, 1, func() motan.Serialization {
 		return &GrpcPbSerialization{}
 	})
+	extFactory.RegistryExtSerialization(Hessian2, 0, func() motan.Serialization {
+		return &Hessian2Serialization{}
+	})
+	extFactory.RegistryExtSerialization(Msgpack, 3, func() motan.Serialization {
+		return &MsgpackSerialization{}
+	})
+	extFactory.RegistryExtSerialization(JSON, 2, func() motan.Serialization {
+		return &JSONSerialization{}
+	})
+	extFactory.RegistryExtSerialization(Thrift, 9, func() motan.Serialization {
+		return &ThriftSerialization{}
+	})
+	extFactory.RegistryExtSerialization(ThriftCompact, 10, func() motan.Serialization {
+		return &ThriftSerialization{Compact: true}
+	})
 }
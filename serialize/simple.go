@@ -2,9 +2,12 @@ package serialize
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"math"
 	"reflect"
+	"sync"
+	"sync/atomic"
 
 	motan "github.com/weibocom/motan-go/core"
 )
@@ -23,10 +26,24 @@ const (
 	sInt64
 	sFloat32
 	sFloat64
+	// types of v2
+	sInt8
+	sByteArrayMap // [string][]byte
 
 	// [string]interface{}
 	sMap   = 20
 	sArray = 21
+	// struct registered by RegisterSimpleStruct, v2 only
+	sStruct = 22
+)
+
+// versions of simple serialization, v2 bodies start with simpleV2Mark which is not a v1 type
+const (
+	SimpleV1     = 1
+	SimpleV2     = 2
+	simpleV2Mark = 0xf2
+	// struct tag of the simple field names
+	simpleTag = "simple"
 )
 
 var DefaultBufferSize = 2048
@@ -34,9 +51,121 @@ var DefaultBufferSize = 2048
 var (
 	ErrNotSupport = errors.New("not support type by SimpleSerialization")
 	ErrWrongSize  = errors.New("read byte size not correct")
+
+	simpleStructs     sync.Map // name -> reflect.Type, reflect.Type -> name
+	simpleAssigner    = &assigner{tag: simpleTag, hook: simpleCodecHook}
+	simpleEncoderPool = sync.Pool{New: func() interface{} { return &simpleEncoder{} }}
+
+	mapType   = reflect.TypeOf(map[interface{}]interface{}{})
+	arrayType = reflect.TypeOf([]interface{}{})
+
+	simpleCodecs     sync.Map // reflect.Type -> *typeCodec
+	simpleCodecCount int32    // the codecs are not looked up until any is registered
 )
 
+// typeCodec converts the values of a type not supported by the serialization to supported values and back
+type typeCodec struct {
+	encode func(v interface{}) (interface{}, error)
+	decode func(v interface{}) (interface{}, error)
+}
+
+// RegisterSimpleStruct registers the struct type of v by name, so it can be serialized by simple v2.
+// the fields are named by the simple tags or the field names with the first letter in lower case
+func RegisterSimpleStruct(name string, v interface{}) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	simpleStructs.Store(name, t)
+	simpleStructs.Store(t, name)
+}
+
+// RegisterTypeCodec registers the functions to serialize the type of v by simple serialization.
+// encode converts a value of the type to a value simple supports, such as an int64 for time.Time or a string for
+// a decimal, and decode converts the decoded value back to a value of the type. the value is sent as the encoded
+// value, so the receivers without the codec get the encoded value. codecs are used before the built-in encodings
+// except for string, int, int64, bool, []byte and map[string]string, which are written directly
+func RegisterTypeCodec(v interface{}, encode func(v interface{}) (interface{}, error), decode func(v interface{}) (interface{}, error)) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if _, ok := simpleCodecs.Load(t); !ok {
+		atomic.AddInt32(&simpleCodecCount, 1)
+	}
+	simpleCodecs.Store(t, &typeCodec{encode: encode, decode: decode})
+}
+
+func getSimpleCodec(t reflect.Type) *typeCodec {
+	if atomic.LoadInt32(&simpleCodecCount) == 0 {
+		return nil
+	}
+	if c, ok := simpleCodecs.Load(t); ok {
+		return c.(*typeCodec)
+	}
+	return nil
+}
+
+// simpleCodecHook assigns the decoded values to the types with codecs
+func simpleCodecHook(dst reflect.Value, src interface{}) (bool, error) {
+	c := getSimpleCodec(dst.Type())
+	if c == nil {
+		return false, nil
+	}
+	v, err := c.decode(src)
+	if err != nil {
+		return true, err
+	}
+	if v == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return true, nil
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.Type().AssignableTo(dst.Type()) {
+		return true, fmt.Errorf("codec of %s decodes to %T", dst.Type(), v)
+	}
+	dst.Set(rv)
+	return true, nil
+}
+
+// isSimpleAssignTarget returns true if v is a type or a pointer of a type other than the decoded type,
+// such as the structs sent as maps by generic calls
+func isSimpleAssignTarget(v interface{}, decoded reflect.Type) bool {
+	if v == nil {
+		return false
+	}
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+		if t.Kind() != reflect.Ptr {
+			return false
+		}
+		t = t.Elem()
+	}
+	return t != decoded && t.Kind() != reflect.Interface
+}
+
+// isSimpleCodecTarget returns true if v is a type with codec or a pointer of it
+func isSimpleCodecTarget(v interface{}) bool {
+	if v == nil || atomic.LoadInt32(&simpleCodecCount) == 0 {
+		return false
+	}
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+		if t.Kind() != reflect.Ptr {
+			return false
+		}
+		t = t.Elem()
+	}
+	return getSimpleCodec(t) != nil
+}
+
+// SimpleSerialization is the simple serialization. bodies are written in v1 unless Version is SimpleV2,
+// v2 supports more types such as int8, map[string][]byte and registered structs.
+// both versions are accepted when deserializing
 type SimpleSerialization struct {
+	Version int
 }
 
 func (s *SimpleSerialization) GetSerialNum() int {
@@ -44,29 +173,81 @@ func (s *SimpleSerialization) GetSerialNum() int {
 }
 
 func (s *SimpleSerialization) Serialize(v interface{}) ([]byte, error) {
-	buf := motan.NewBytesBuffer(DefaultBufferSize)
-	err := serializeBuf(v, buf)
-	return buf.Bytes(), err
+	e := s.acquireEncoder()
+	defer releaseSimpleEncoder(e)
+	err := e.encode(v)
+	return copyBytes(e.buf), err
 }
 
 func (s *SimpleSerialization) SerializeMulti(v []interface{}) ([]byte, error) {
 	if len(v) == 0 {
 		return nil, nil
 	}
-	buf := motan.NewBytesBuffer(DefaultBufferSize)
+	e := s.acquireEncoder()
+	defer releaseSimpleEncoder(e)
 	for _, o := range v {
-		err := serializeBuf(o, buf)
+		err := e.encode(o)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return buf.Bytes(), nil
+	return copyBytes(e.buf), nil
 }
 
-func serializeBuf(v interface{}, buf *motan.BytesBuffer) error {
-	if v == nil {
+func (s *SimpleSerialization) acquireEncoder() *simpleEncoder {
+	e := simpleEncoderPool.Get().(*simpleEncoder)
+	e.buf = motan.AcquireBytesBuffer(DefaultBufferSize)
+	e.v2 = s.Version == SimpleV2
+	if e.v2 {
+		e.buf.WriteByte(simpleV2Mark)
+	}
+	return e
+}
+
+// copyBytes copies the content of a pooled buffer out with the exact size
+func copyBytes(buf *motan.BytesBuffer) []byte {
+	b := make([]byte, buf.Len())
+	copy(b, buf.Bytes())
+	return b
+}
+
+// simpleEncoder writes values into a pooled buffer, the encoders are pooled too
+type simpleEncoder struct {
+	buf *motan.BytesBuffer
+	v2  bool
+}
+
+func releaseSimpleEncoder(e *simpleEncoder) {
+	motan.ReleaseBytesBuffer(e.buf)
+	e.buf = nil
+	simpleEncoderPool.Put(e)
+}
+
+func (e *simpleEncoder) encode(v interface{}) error {
+	buf := e.buf
+	// common types are written without reflection
+	switch tv := v.(type) {
+	case nil:
 		buf.WriteByte(sNull)
 		return nil
+	case string:
+		encodeString(tv, buf)
+		return nil
+	case int64:
+		encodeInt64(tv, buf)
+		return nil
+	case int:
+		encodeInt64(int64(tv), buf)
+		return nil
+	case bool:
+		encodeBool(tv, buf)
+		return nil
+	case []byte:
+		encodeBytes(tv, buf)
+		return nil
+	case map[string]string:
+		encodeStringMap(reflect.ValueOf(tv), buf)
+		return nil
 	}
 	var rv reflect.Value
 	if nrv, ok := v.(reflect.Value); ok {
@@ -79,6 +260,13 @@ func serializeBuf(v interface{}, buf *motan.BytesBuffer) error {
 		rv = reflect.ValueOf(rv.Interface())
 		k = rv.Kind()
 	}
+	if k == reflect.Invalid {
+		buf.WriteByte(sNull)
+		return nil
+	}
+	if c := getSimpleCodec(rv.Type()); c != nil {
+		return e.encodeCodec(c, rv)
+	}
 
 	switch k {
 	case reflect.String:
@@ -87,52 +275,101 @@ func serializeBuf(v interface{}, buf *motan.BytesBuffer) error {
 		encodeBool(rv.Bool(), buf)
 	case reflect.Uint8:
 		encodeByte(byte(rv.Uint()), buf)
+	case reflect.Int8:
+		if e.v2 {
+			encodeInt8(rv.Int(), buf)
+		} else {
+			encodeInt16(rv.Int(), buf)
+		}
 	case reflect.Int16:
 		encodeInt16(rv.Int(), buf)
-	case reflect.Int32:
-		encodeInt32(rv.Int(), buf)
-	case reflect.Int, reflect.Int64:
-		encodeInt64(rv.Int(), buf)
+	case reflect.Int32, reflect.Uint16:
+		encodeInt32(int64(rvInt(rv)), buf)
+	case reflect.Int, reflect.Int64, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		encodeInt64(rvInt(rv), buf)
 	case reflect.Float32:
 		encodeFloat32(rv.Float(), buf)
 	case reflect.Float64:
 		encodeFloat64(rv.Float(), buf)
 	case reflect.Slice:
-		t := rv.Type().String()
-		if t == "[]string" {
+		t := rv.Type()
+		if t.Elem().Kind() == reflect.String {
 			encodeStringArray(rv, buf)
-		} else if t == "[]uint8" {
+		} else if t.Elem().Kind() == reflect.Uint8 {
 			encodeBytes(rv.Bytes(), buf)
 		} else {
-			return encodeArray(rv, buf)
+			return e.encodeArray(rv)
 		}
 	case reflect.Map:
-		t := rv.Type().String()
-		if "map[string]string" == t {
+		t := rv.Type()
+		if t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String {
 			encodeStringMap(rv, buf)
+		} else if e.v2 && t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Slice &&
+			t.Elem().Elem().Kind() == reflect.Uint8 {
+			encodeByteArrayMap(rv, buf)
 		} else {
-			return encodeMap(rv, buf)
+			return e.encodeMap(rv)
+		}
+	case reflect.Ptr:
+		if rv.IsNil() {
+			buf.WriteByte(sNull)
+			return nil
 		}
+		return e.encode(rv.Elem())
+	case reflect.Struct:
+		return e.encodeStruct(rv)
 	default:
 		return ErrNotSupport
 	}
 	return nil
 }
 
+func (e *simpleEncoder) encodeCodec(c *typeCodec, rv reflect.Value) error {
+	v, err := c.encode(rv.Interface())
+	if err != nil {
+		return err
+	}
+	if v != nil && reflect.TypeOf(v) == rv.Type() {
+		return fmt.Errorf("codec of %s encodes to the same type", rv.Type())
+	}
+	return e.encode(v)
+}
+
+func rvInt(rv reflect.Value) int64 {
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint())
+	}
+	return rv.Int()
+}
+
 func (s *SimpleSerialization) DeSerialize(b []byte, v interface{}) (interface{}, error) {
 	if len(b) == 0 {
 		return nil, nil
 	}
-	buf := motan.CreateBytesBuffer(b)
-	return deSerializeBuf(buf, v)
+	if err := loadLimits().checkTotal(len(b)); err != nil {
+		return nil, err
+	}
+	return deSerializeBuf(createSimpleBuffer(b), v, 0)
+}
+
+// createSimpleBuffer skips the version mark of v2 bodies, the types of v2 are decoded regardless of the version
+func createSimpleBuffer(b []byte) *motan.BytesBuffer {
+	if len(b) > 0 && b[0] == simpleV2Mark {
+		b = b[1:]
+	}
+	return motan.CreateBytesBuffer(b)
 }
 
 func (s *SimpleSerialization) DeSerializeMulti(b []byte, v []interface{}) (ret []interface{}, err error) {
+	if err := loadLimits().checkTotal(len(b)); err != nil {
+		return nil, err
+	}
 	ret = make([]interface{}, 0, len(v))
-	buf := motan.CreateBytesBuffer(b)
+	buf := createSimpleBuffer(b)
 	if v != nil {
 		for _, o := range v {
-			rv, err := deSerializeBuf(buf, o)
+			rv, err := deSerializeBuf(buf, o, 0)
 			if err != nil {
 				return nil, err
 			}
@@ -140,7 +377,7 @@ func (s *SimpleSerialization) DeSerializeMulti(b []byte, v []interface{}) (ret [
 		}
 	} else {
 		for buf.Remain() > 0 {
-			rv, err := deSerializeBuf(buf, nil)
+			rv, err := deSerializeBuf(buf, nil, 0)
 			if err != nil {
 				if err == io.EOF {
 					break
@@ -155,7 +392,15 @@ func (s *SimpleSerialization) DeSerializeMulti(b []byte, v []interface{}) (ret [
 	return ret, nil
 }
 
-func deSerializeBuf(buf *motan.BytesBuffer, v interface{}) (interface{}, error) {
+// deSerializeBuf decodes a value in depth maps, arrays or structs
+func deSerializeBuf(buf *motan.BytesBuffer, v interface{}, depth int) (interface{}, error) {
+	if isSimpleCodecTarget(v) {
+		value, err := deSerializeBuf(buf, nil, depth)
+		if err != nil {
+			return nil, err
+		}
+		return simpleAssigner.decodeInto(value, v)
+	}
 	tp, err := buf.ReadByte()
 	if err != nil {
 		return nil, err
@@ -185,10 +430,30 @@ func deSerializeBuf(buf *motan.BytesBuffer, v interface{}) (interface{}, error)
 		return decodeFloat32(buf, v)
 	case sFloat64:
 		return decodeFloat64(buf, v)
+	case sInt8:
+		return decodeInt8(buf, v)
+	case sByteArrayMap:
+		return decodeByteArrayMap(buf, v)
 	case sMap:
-		return decodeMap(buf, v)
+		if isSimpleAssignTarget(v, mapType) {
+			m, err := decodeMap(buf, nil, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			return simpleAssigner.decodeInto(m, v)
+		}
+		return decodeMap(buf, v, depth+1)
 	case sArray:
-		return decodeArray(buf, v)
+		if isSimpleAssignTarget(v, arrayType) {
+			a, err := decodeArray(buf, nil, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			return simpleAssigner.decodeInto(a, v)
+		}
+		return decodeArray(buf, v, depth+1)
+	case sStruct:
+		return decodeStruct(buf, v, depth+1)
 	}
 	return nil, ErrNotSupport
 }
@@ -199,10 +464,8 @@ func encodeString(s string, buf *motan.BytesBuffer) {
 }
 
 func encodeStringNoTag(s string, buf *motan.BytesBuffer) {
-	b := []byte(s)
-	l := len(b)
-	buf.WriteUint32(uint32(l))
-	buf.Write(b)
+	buf.WriteUint32(uint32(len(s)))
+	buf.WriteString(s)
 }
 
 func encodeStringMap(v reflect.Value, buf *motan.BytesBuffer) {
@@ -247,17 +510,18 @@ func encodeBool(b bool, buf *motan.BytesBuffer) {
 	}
 }
 
-func encodeMap(v reflect.Value, buf *motan.BytesBuffer) error {
+func (e *simpleEncoder) encodeMap(v reflect.Value) error {
+	buf := e.buf
 	buf.WriteByte(sMap)
 	pos := buf.GetWPos()
 	buf.SetWPos(pos + 4)
 	var err error
 	for _, mk := range v.MapKeys() {
-		err = serializeBuf(mk, buf)
+		err = e.encode(mk)
 		if err != nil {
 			return err
 		}
-		err = serializeBuf(v.MapIndex(mk), buf)
+		err = e.encode(v.MapIndex(mk))
 		if err != nil {
 			return err
 		}
@@ -269,13 +533,14 @@ func encodeMap(v reflect.Value, buf *motan.BytesBuffer) error {
 	return err
 }
 
-func encodeArray(v reflect.Value, buf *motan.BytesBuffer) error {
+func (e *simpleEncoder) encodeArray(v reflect.Value) error {
+	buf := e.buf
 	buf.WriteByte(sArray)
 	pos := buf.GetWPos()
 	buf.SetWPos(pos + 4)
 	var err error
 	for i := 0; i < v.Len(); i++ {
-		err = serializeBuf(v.Index(i), buf)
+		err = e.encode(v.Index(i))
 		if err != nil {
 			return err
 		}
@@ -287,6 +552,51 @@ func encodeArray(v reflect.Value, buf *motan.BytesBuffer) error {
 	return nil
 }
 
+// encodeStruct writes the name of a registered struct and its fields in name-value pairs
+func (e *simpleEncoder) encodeStruct(v reflect.Value) error {
+	name, ok := simpleStructs.Load(v.Type())
+	if !e.v2 || !ok {
+		return ErrNotSupport
+	}
+	buf := e.buf
+	buf.WriteByte(sStruct)
+	encodeStringNoTag(name.(string), buf)
+	pos := buf.GetWPos()
+	buf.SetWPos(pos + 4)
+	for _, f := range simpleAssigner.fields(v.Type()) {
+		encodeStringNoTag(f.name, buf)
+		if err := e.encode(v.Field(f.index)); err != nil {
+			return err
+		}
+	}
+	npos := buf.GetWPos()
+	buf.SetWPos(pos)
+	buf.WriteUint32(uint32(npos - pos - 4))
+	buf.SetWPos(npos)
+	return nil
+}
+
+func encodeByteArrayMap(v reflect.Value, buf *motan.BytesBuffer) {
+	buf.WriteByte(sByteArrayMap)
+	pos := buf.GetWPos()
+	buf.SetWPos(pos + 4)
+	for _, mk := range v.MapKeys() {
+		encodeStringNoTag(mk.String(), buf)
+		b := v.MapIndex(mk).Bytes()
+		buf.WriteUint32(uint32(len(b)))
+		buf.Write(b)
+	}
+	npos := buf.GetWPos()
+	buf.SetWPos(pos)
+	buf.WriteUint32(uint32(npos - pos - 4))
+	buf.SetWPos(npos)
+}
+
+func encodeInt8(i int64, buf *motan.BytesBuffer) {
+	buf.WriteByte(sInt8)
+	buf.WriteByte(byte(i))
+}
+
 func encodeByte(i byte, buf *motan.BytesBuffer) {
 	buf.WriteByte(sByte)
 	buf.WriteByte(i)
@@ -351,6 +661,9 @@ func decodeString(buf *motan.BytesBuffer, v interface{}) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if err = loadLimits().checkString(size); err != nil {
+		return "", err
+	}
 	b, err := buf.Next(size)
 	if err != nil {
 		return "", motan.ErrNotEnough
@@ -376,7 +689,11 @@ func decodeStringMap(buf *motan.BytesBuffer, v interface{}) (map[string]string,
 	pos := buf.GetRPos()
 	endPos := pos + total
 	var k, tv string
+	limits := loadLimits()
 	for buf.GetRPos() < endPos {
+		if err = limits.checkCollection(len(m) + 1); err != nil {
+			return nil, err
+		}
 		k, err = decodeString(buf, nil)
 		if err != nil {
 			return nil, err
@@ -403,6 +720,9 @@ func decodeBytes(buf *motan.BytesBuffer, v interface{}) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = loadLimits().checkString(size); err != nil {
+		return nil, err
+	}
 	b, err := buf.Next(size)
 	if err != nil {
 		return nil, motan.ErrNotEnough
@@ -415,7 +735,91 @@ func decodeBytes(buf *motan.BytesBuffer, v interface{}) ([]byte, error) {
 	return b, nil
 }
 
-func decodeMap(buf *motan.BytesBuffer, v interface{}) (map[interface{}]interface{}, error) {
+func decodeByteArrayMap(buf *motan.BytesBuffer, v interface{}) (map[string][]byte, error) {
+	total, err := buf.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	if total <= 0 {
+		return nil, nil
+	}
+	m := make(map[string][]byte, 16)
+	endPos := buf.GetRPos() + total
+	limits := loadLimits()
+	for buf.GetRPos() < endPos {
+		if err = limits.checkCollection(len(m) + 1); err != nil {
+			return nil, err
+		}
+		k, err := decodeString(buf, nil)
+		if err != nil {
+			return nil, err
+		}
+		b, err := decodeBytes(buf, nil)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = b
+	}
+	if buf.GetRPos() != endPos {
+		return nil, ErrWrongSize
+	}
+	if v != nil {
+		if mv, ok := v.(*map[string][]byte); ok {
+			*mv = m
+		}
+	}
+	return m, nil
+}
+
+// decodeStruct returns a pointer of the registered struct, or a map of the fields if the name is not registered.
+// the value is assigned to v if v is a pointer or a type
+func decodeStruct(buf *motan.BytesBuffer, v interface{}, depth int) (interface{}, error) {
+	limits := loadLimits()
+	if err := limits.checkDepth(depth); err != nil {
+		return nil, err
+	}
+	name, err := decodeString(buf, nil)
+	if err != nil {
+		return nil, err
+	}
+	total, err := buf.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{}, 16)
+	endPos := buf.GetRPos() + total
+	for buf.GetRPos() < endPos {
+		if err = limits.checkCollection(len(fields) + 1); err != nil {
+			return nil, err
+		}
+		k, err := decodeString(buf, nil)
+		if err != nil {
+			return nil, err
+		}
+		fields[k], err = deSerializeBuf(buf, nil, depth)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if buf.GetRPos() != endPos {
+		return nil, ErrWrongSize
+	}
+	var value interface{} = fields
+	if t, ok := simpleStructs.Load(name); ok {
+		obj := reflect.New(t.(reflect.Type))
+		if err = simpleAssigner.assignStruct(obj.Elem(), reflect.ValueOf(fields), 0); err != nil {
+			return nil, err
+		}
+		value = obj.Interface()
+	}
+	return simpleAssigner.decodeInto(value, v)
+}
+
+func decodeMap(buf *motan.BytesBuffer, v interface{}, depth int) (map[interface{}]interface{}, error) {
+	limits := loadLimits()
+	if err := limits.checkDepth(depth); err != nil {
+		return nil, err
+	}
 	total, err := buf.ReadInt()
 	if err != nil {
 		return nil, err
@@ -429,11 +833,14 @@ func decodeMap(buf *motan.BytesBuffer, v interface{}) (map[interface{}]interface
 	pos := buf.GetRPos()
 	endPos := pos + total
 	for buf.GetRPos() < endPos {
-		k, err = deSerializeBuf(buf, nil)
+		if err = limits.checkCollection(len(m) + 1); err != nil {
+			return nil, err
+		}
+		k, err = deSerializeBuf(buf, nil, depth)
 		if err != nil {
 			return nil, err
 		}
-		tv, err = deSerializeBuf(buf, nil)
+		tv, err = deSerializeBuf(buf, nil, depth)
 		if err != nil {
 			return nil, err
 		}
@@ -450,7 +857,11 @@ func decodeMap(buf *motan.BytesBuffer, v interface{}) (map[interface{}]interface
 	return m, nil
 }
 
-func decodeArray(buf *motan.BytesBuffer, v interface{}) ([]interface{}, error) {
+func decodeArray(buf *motan.BytesBuffer, v interface{}, depth int) ([]interface{}, error) {
+	limits := loadLimits()
+	if err := limits.checkDepth(depth); err != nil {
+		return nil, err
+	}
 	total, err := buf.ReadInt() // total size
 	if err != nil {
 		return nil, err
@@ -463,7 +874,10 @@ func decodeArray(buf *motan.BytesBuffer, v interface{}) ([]interface{}, error) {
 	endPos := pos + total
 	var tv interface{}
 	for buf.GetRPos() < endPos {
-		tv, err = deSerializeBuf(buf, nil)
+		if err = limits.checkCollection(len(a) + 1); err != nil {
+			return nil, err
+		}
+		tv, err = deSerializeBuf(buf, nil, depth)
 		if err != nil {
 			return nil, err
 		}
@@ -492,7 +906,11 @@ func decodeStringArray(buf *motan.BytesBuffer, v interface{}) ([]string, error)
 	pos := buf.GetRPos()
 	endPos := pos + total
 	var tv string
+	limits := loadLimits()
 	for buf.GetRPos() < endPos {
+		if err = limits.checkCollection(len(a) + 1); err != nil {
+			return nil, err
+		}
 		tv, err = decodeString(buf, nil)
 		if err != nil {
 			return nil, err
@@ -523,6 +941,19 @@ func decodeByte(buf *motan.BytesBuffer, v interface{}) (byte, error) {
 	return byte(b), nil
 }
 
+func decodeInt8(buf *motan.BytesBuffer, v interface{}) (int8, error) {
+	b, err := buf.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if v != nil {
+		if bv, ok := v.(*int8); ok {
+			*bv = int8(b)
+		}
+	}
+	return int8(b), nil
+}
+
 func decodeInt16(buf *motan.BytesBuffer, v interface{}) (int16, error) {
 	i, err := buf.ReadUint16()
 	if err != nil {
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	motan "github.com/weibocom/motan-go/core"
 )
@@ -376,3 +378,181 @@ func verifyBytes(ba []byte, simple *SimpleSerialization, t *testing.T) {
 		}
 	}
 }
+
+type simpleItem struct {
+	Name  string
+	Price float32
+}
+
+type simpleOrder struct {
+	ID     int64 `simple:"id"`
+	Level  int8
+	Items  []*simpleItem
+	Main   simpleItem
+	Blobs  map[string][]byte
+	Count  uint16
+	Ignore string `simple:"-"`
+}
+
+func TestSimpleV2(t *testing.T) {
+	RegisterSimpleStruct("test.Order", &simpleOrder{})
+	RegisterSimpleStruct("test.Item", simpleItem{})
+	v1 := &SimpleSerialization{}
+	v2 := &SimpleSerialization{Version: SimpleV2}
+	order := &simpleOrder{ID: 7, Level: -3, Items: []*simpleItem{{Name: "a", Price: 1.5}, nil}, Main: simpleItem{Name: "m"},
+		Blobs: map[string][]byte{"k": {1, 2}}, Count: 65535, Ignore: "x"}
+	b, err := v2.SerializeMulti([]interface{}{order, int8(-8), map[string][]byte{"b": {3}}})
+	if err != nil {
+		t.Fatalf("serialize v2 fail. err:%v", err)
+	}
+	if b[0] != simpleV2Mark {
+		t.Errorf("v2 body should start with version mark. body:%x", b)
+	}
+	// v2 bodies are decoded by both
+	for _, s := range []*SimpleSerialization{v1, v2} {
+		var ro *simpleOrder
+		var i8 int8
+		var bm map[string][]byte
+		if _, err = s.DeSerializeMulti(b, []interface{}{&ro, &i8, &bm}); err != nil {
+			t.Fatalf("deserialize v2 fail. err:%v", err)
+		}
+		order.Ignore = ""
+		if !reflect.DeepEqual(ro, order) || i8 != -8 || string(bm["b"]) != "\x03" {
+			t.Errorf("v2 values not correct. order:%+v, int8:%d, map:%v", ro, i8, bm)
+		}
+	}
+	values, err := v1.DeSerializeMulti(b, nil)
+	if err != nil || len(values) != 3 {
+		t.Fatalf("deserialize v2 without types fail. values:%v, err:%v", values, err)
+	}
+	if _, ok := values[0].(*simpleOrder); !ok {
+		t.Errorf("registered struct should be deserialized as pointer. value:%+v", values[0])
+	}
+	v, err := v2.DeSerialize(b, reflect.TypeOf(simpleOrder{}))
+	if err != nil || v.(simpleOrder).ID != 7 {
+		t.Errorf("deserialize by struct type not correct. value:%+v, err:%v", v, err)
+	}
+
+	// v1 bodies are decoded by v2, and v1 widens the types it does not have
+	b, err = v1.SerializeMulti([]interface{}{int8(-8), uint32(1 << 31), "s"})
+	if err != nil || b[0] != sInt16 {
+		t.Fatalf("serialize v1 fail. body:%x, err:%v", b, err)
+	}
+	values, err = v2.DeSerializeMulti(b, nil)
+	if err != nil || !reflect.DeepEqual(values, []interface{}{int16(-8), int64(1 << 31), "s"}) {
+		t.Errorf("v1 values not correct. values:%v, err:%v", values, err)
+	}
+	if _, err = v1.Serialize(order); err != ErrNotSupport {
+		t.Errorf("struct should not be supported by v1. err:%v", err)
+	}
+	if _, err = v2.Serialize(struct{ A int }{1}); err != ErrNotSupport {
+		t.Errorf("unregistered struct should not be supported. err:%v", err)
+	}
+}
+
+func TestSimpleGenericArgs(t *testing.T) {
+	s := &SimpleSerialization{}
+	// structs are sent as maps by generic calls
+	b, err := s.SerializeMulti([]interface{}{map[string]interface{}{"name": "a", "price": 1.5}, []interface{}{int64(1), int64(2)}})
+	if err != nil {
+		t.Fatalf("serialize generic args fail. err:%v", err)
+	}
+	var ids []int
+	values, err := s.DeSerializeMulti(b, []interface{}{reflect.TypeOf(&simpleItem{}), &ids})
+	if err != nil {
+		t.Fatalf("deserialize generic args fail. err:%v", err)
+	}
+	if item := values[0].(*simpleItem); item.Name != "a" || item.Price != 1.5 || !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("generic args not correct. item:%+v, ids:%v", item, ids)
+	}
+	var m map[interface{}]interface{}
+	if _, err = s.DeSerializeMulti(b, []interface{}{&m, nil}); err != nil || m["name"] != "a" {
+		t.Errorf("map should be deserialized as is. map:%v, err:%v", m, err)
+	}
+}
+
+// simpleDecimal is a decimal sent as string by its codec
+type simpleDecimal struct {
+	unscaled int64
+	scale    int
+}
+
+type simpleEvent struct {
+	At      time.Time
+	Prev    *time.Time
+	Price   simpleDecimal
+	History map[string]time.Time
+}
+
+func TestSimpleTypeCodec(t *testing.T) {
+	RegisterTypeCodec(time.Time{}, func(v interface{}) (interface{}, error) {
+		return v.(time.Time).UnixNano() / int64(time.Millisecond), nil
+	}, func(v interface{}) (interface{}, error) {
+		ms, ok := v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("time expects int64, but got %T", v)
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
+	})
+	RegisterTypeCodec(reflect.TypeOf(simpleDecimal{}), func(v interface{}) (interface{}, error) {
+		d := v.(simpleDecimal)
+		return strconv.FormatInt(d.unscaled, 10) + "e-" + strconv.Itoa(d.scale), nil
+	}, func(v interface{}) (interface{}, error) {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("decimal expects string, but got %T", v)
+		}
+		parts := strings.Split(str, "e-")
+		unscaled, _ := strconv.ParseInt(parts[0], 10, 64)
+		scale, _ := strconv.Atoi(parts[1])
+		return simpleDecimal{unscaled: unscaled, scale: scale}, nil
+	})
+	RegisterSimpleStruct("test.Event", simpleEvent{})
+
+	at := time.Unix(1577934245, 6000000)
+	s := &SimpleSerialization{}
+	b, err := s.Serialize(at)
+	if err != nil {
+		t.Fatalf("serialize time fail. err:%v", err)
+	}
+	if expect, _ := s.Serialize(int64(1577934245006)); string(b) != string(expect) {
+		t.Errorf("time should be sent as the encoded value. body:%x", b)
+	}
+	var rt time.Time
+	if _, err = s.DeSerialize(b, &rt); err != nil || !rt.Equal(at) {
+		t.Errorf("deserialize time fail. time:%v, err:%v", rt, err)
+	}
+	v, err := s.DeSerialize(b, reflect.TypeOf(time.Time{}))
+	if err != nil || !v.(time.Time).Equal(at) {
+		t.Errorf("deserialize time by type fail. time:%v, err:%v", v, err)
+	}
+	if v, err = s.DeSerialize(b, nil); err != nil || v != int64(1577934245006) {
+		t.Errorf("encoded value should be returned without type. value:%v, err:%v", v, err)
+	}
+
+	// codec types in structs, maps and pointers
+	event := &simpleEvent{At: at, Prev: &at, Price: simpleDecimal{unscaled: 1234, scale: 2}, History: map[string]time.Time{"a": at}}
+	v2 := &SimpleSerialization{Version: SimpleV2}
+	if b, err = v2.Serialize(event); err != nil {
+		t.Fatalf("serialize codec types in struct fail. err:%v", err)
+	}
+	var re *simpleEvent
+	if _, err = v2.DeSerialize(b, &re); err != nil {
+		t.Fatalf("deserialize codec types in struct fail. err:%v", err)
+	}
+	if !re.At.Equal(at) || !re.Prev.Equal(at) || re.Price != event.Price || !re.History["a"].Equal(at) {
+		t.Errorf("codec values not correct. event:%+v", re)
+	}
+	if _, err = s.DeSerialize(b[1:], reflect.TypeOf(simpleDecimal{})); err == nil {
+		t.Errorf("wrong encoded value should fail")
+	}
+}
+
+func BenchmarkSimpleSerializeMulti(b *testing.B) {
+	simple := &SimpleSerialization{}
+	args := []interface{}{"hello", map[string]string{"k": "v"}, int64(1), []interface{}{"a", 1}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		simple.SerializeMulti(args)
+	}
+}
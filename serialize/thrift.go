@@ -0,0 +1,128 @@
+package serialize
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+var (
+	ErrNotThriftStruct = errors.New("param must be thrift struct in ThriftSerialization")
+)
+
+// ThriftSerialization serializes the structs generated by thrift, such as the args and result structs of the services.
+// multi values are written one after another. the binary protocol is used by default, Compact for the compact protocol
+type ThriftSerialization struct {
+	Compact bool
+}
+
+func (t *ThriftSerialization) GetSerialNum() int {
+	if t.Compact {
+		return 10
+	}
+	return 9
+}
+
+func (t *ThriftSerialization) Serialize(v interface{}) ([]byte, error) {
+	return t.SerializeMulti([]interface{}{v})
+}
+
+func (t *ThriftSerialization) SerializeMulti(v []interface{}) ([]byte, error) {
+	buf := thrift.NewTMemoryBufferLen(256)
+	p := t.protocol(buf)
+	for _, sv := range v {
+		s, err := toThriftStruct(sv)
+		if err != nil {
+			return nil, err
+		}
+		if err = s.Write(p); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.Flush(context.Background()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (t *ThriftSerialization) DeSerialize(b []byte, v interface{}) (interface{}, error) {
+	ret, err := t.DeSerializeMulti(b, []interface{}{v})
+	if err != nil {
+		return nil, err
+	}
+	return ret[0], nil
+}
+
+func (t *ThriftSerialization) DeSerializeMulti(b []byte, v []interface{}) ([]interface{}, error) {
+	if err := loadLimits().checkTotal(len(b)); err != nil {
+		return nil, err
+	}
+	buf := thrift.NewTMemoryBuffer()
+	buf.Write(b)
+	p := t.protocol(buf)
+	ret := make([]interface{}, len(v))
+	for i, sv := range v {
+		s, err := newThriftStruct(sv)
+		if err != nil {
+			return nil, err
+		}
+		if err = s.Read(p); err != nil {
+			return nil, err
+		}
+		ret[i] = s
+	}
+	return ret, nil
+}
+
+func (t *ThriftSerialization) protocol(trans thrift.TTransport) thrift.TProtocol {
+	if t.Compact {
+		return thrift.NewTCompactProtocol(trans)
+	}
+	return thrift.NewTBinaryProtocolTransport(trans)
+}
+
+func toThriftStruct(v interface{}) (thrift.TStruct, error) {
+	if rv, ok := v.(reflect.Value); ok {
+		if !rv.IsValid() {
+			return nil, ErrNilParam
+		}
+		if rv.Kind() == reflect.Struct {
+			// the methods of generated structs have pointer receivers
+			pv := reflect.New(rv.Type())
+			pv.Elem().Set(rv)
+			rv = pv
+		}
+		v = rv.Interface()
+	}
+	if v == nil {
+		return nil, ErrNilParam
+	}
+	if s, ok := v.(thrift.TStruct); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, ErrNilParam
+		}
+		return s, nil
+	}
+	return nil, ErrNotThriftStruct
+}
+
+// newThriftStruct returns the struct to read into: v itself if it is a pointer of the struct, or a new one of the type
+func newThriftStruct(v interface{}) (thrift.TStruct, error) {
+	if v == nil {
+		return nil, ErrNilParam
+	}
+	if s, ok := v.(thrift.TStruct); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, ErrNilParam
+		}
+		return s, nil
+	}
+	if rt, ok := v.(reflect.Type); ok && rt.Kind() == reflect.Ptr {
+		if s, ok := reflect.New(rt.Elem()).Interface().(thrift.TStruct); ok {
+			return s, nil
+		}
+	}
+	return nil, ErrNotThriftStruct
+}
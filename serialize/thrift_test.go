@@ -0,0 +1,117 @@
+package serialize
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// thriftUser is written in the way thrift generates structs for `struct User { 1: string name, 2: i32 age }`
+type thriftUser struct {
+	Name string
+	Age  int32
+}
+
+func (u *thriftUser) Read(p thrift.TProtocol) error {
+	if _, err := p.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		_, fieldType, fieldID, err := p.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if fieldType == thrift.STOP {
+			break
+		}
+		switch {
+		case fieldID == 1 && fieldType == thrift.STRING:
+			if u.Name, err = p.ReadString(); err != nil {
+				return err
+			}
+		case fieldID == 2 && fieldType == thrift.I32:
+			if u.Age, err = p.ReadI32(); err != nil {
+				return err
+			}
+		default:
+			if err = p.Skip(fieldType); err != nil {
+				return err
+			}
+		}
+		if err = p.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	return p.ReadStructEnd()
+}
+
+func (u *thriftUser) Write(p thrift.TProtocol) error {
+	if err := p.WriteStructBegin("User"); err != nil {
+		return err
+	}
+	if err := p.WriteFieldBegin("name", thrift.STRING, 1); err != nil {
+		return err
+	}
+	if err := p.WriteString(u.Name); err != nil {
+		return err
+	}
+	if err := p.WriteFieldEnd(); err != nil {
+		return err
+	}
+	if err := p.WriteFieldBegin("age", thrift.I32, 2); err != nil {
+		return err
+	}
+	if err := p.WriteI32(u.Age); err != nil {
+		return err
+	}
+	if err := p.WriteFieldEnd(); err != nil {
+		return err
+	}
+	if err := p.WriteFieldStop(); err != nil {
+		return err
+	}
+	return p.WriteStructEnd()
+}
+
+func TestThriftSerialization(t *testing.T) {
+	binaryWire := []byte{0x0b, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03, 'r', 'a', 'y', 0x08, 0x00, 0x02, 0x00, 0x00, 0x00, 0x1e, 0x00}
+	compactWire := []byte{0x18, 0x03, 'r', 'a', 'y', 0x15, 0x3c, 0x00}
+	for _, c := range []struct {
+		s    *ThriftSerialization
+		wire []byte
+	}{{&ThriftSerialization{}, binaryWire}, {&ThriftSerialization{Compact: true}, compactWire}} {
+		b, err := c.s.Serialize(&thriftUser{Name: "ray", Age: 30})
+		if err != nil {
+			t.Fatalf("serialize thrift struct fail. err:%v", err)
+		}
+		if !bytes.Equal(b, c.wire) {
+			t.Errorf("wrong thrift bytes. compact:%t, expect:%x, real:%x", c.s.Compact, c.wire, b)
+		}
+		var u thriftUser
+		if _, err = c.s.DeSerialize(b, &u); err != nil || u.Name != "ray" || u.Age != 30 {
+			t.Errorf("deserialize thrift struct not correct. user:%+v, err:%v", u, err)
+		}
+
+		b, err = c.s.SerializeMulti([]interface{}{&thriftUser{Name: "a"}, reflect.ValueOf(thriftUser{Name: "b", Age: 1})})
+		if err != nil {
+			t.Fatalf("serialize multi thrift structs fail. err:%v", err)
+		}
+		tp := reflect.TypeOf(&thriftUser{})
+		values, err := c.s.DeSerializeMulti(b, []interface{}{tp, tp})
+		if err != nil || len(values) != 2 || !reflect.DeepEqual(values[1], &thriftUser{Name: "b", Age: 1}) {
+			t.Errorf("deserialize multi thrift structs not correct. values:%v, err:%v", values, err)
+		}
+	}
+	s := &ThriftSerialization{}
+	if _, err := s.Serialize("ray"); err != ErrNotThriftStruct {
+		t.Errorf("non thrift value should fail. err:%v", err)
+	}
+	if _, err := s.Serialize(nil); err != ErrNilParam {
+		t.Errorf("nil value should fail. err:%v", err)
+	}
+	if _, err := s.DeSerialize(binaryWire[:5], &thriftUser{}); err == nil {
+		t.Errorf("truncated bytes should fail")
+	}
+}
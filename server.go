@@ -5,12 +5,14 @@ import (
 	"flag"
 	"fmt"
 	"reflect"
-	"strconv"
 	"sync"
 
 	motan "github.com/weibocom/motan-go/core"
 	"github.com/weibocom/motan-go/log"
+	"github.com/weibocom/motan-go/metrics"
+	"github.com/weibocom/motan-go/provider"
 	mserver "github.com/weibocom/motan-go/server"
+	"github.com/weibocom/motan-go/tracing"
 )
 
 // MSContext is Motan Server Context
@@ -22,16 +24,12 @@ type MSContext struct {
 	portServer   map[int]motan.Server
 	serviceImpls map[string]interface{}
 	registries   map[string]motan.Registry // all registries used for services
+	exporters    []*mserver.DefaultExporter
 
 	csync  sync.Mutex
 	inited bool
 }
 
-const (
-	defaultServerPort = "9982"
-	defaultProtocol   = "motan2"
-)
-
 var (
 	serverContextMap   = make(map[string]*MSContext, 8)
 	serverContextMutex sync.Mutex
@@ -65,7 +63,12 @@ func GetMotanServerContext(confFile string) *MSContext {
 			logdir = "."
 		}
 		initLog(logdir)
+		initAccessLog(section, logdir)
 		registerSwitchers(ms.context)
+		initDeserializeLimits(section)
+		initBaggage(section)
+		initRequestID(section)
+		initObjectPool(section)
 	}
 	return ms
 }
@@ -77,80 +80,151 @@ func (m *MSContext) Start(extfactory motan.ExtensionFactory) {
 	if m.extFactory == nil {
 		m.extFactory = GetDefaultExtFactory()
 	}
+	dryRunConfig(m.context, m.extFactory)
+	metrics.AddSinks(m.context, m.extFactory)
+	tracing.Start(m.context)
 
 	for _, url := range m.context.ServiceURLs {
 		m.export(url)
 	}
+	watchHotRestartSignal(m.HotRestart)
+}
+
+// HotRestart starts a new process inheriting the listeners, and this process exits after the requests being processed
+// by the servers of all server contexts are finished. the pid of the new process is returned
+func (m *MSContext) HotRestart() (int, error) {
+	return hotRestart(func() []motan.Server {
+		serverContextMutex.Lock()
+		defer serverContextMutex.Unlock()
+		servers := make([]motan.Server, 0, 8)
+		for _, ms := range serverContextMap {
+			ms.csync.Lock()
+			for _, s := range ms.portServer {
+				servers = append(servers, s)
+			}
+			ms.csync.Unlock()
+		}
+		return servers
+	})
 }
 
 func (m *MSContext) export(url *motan.URL) {
 	defer motan.HandlePanic(nil)
 	service := m.serviceImpls[url.Parameters[motan.RefKey]]
-	if service != nil {
-		//TODO multi protocol support. convert to multi url
-		export := url.GetParam(motan.ExportKey, "")
-		port := defaultServerPort
-		protocol := defaultProtocol
-		if export != "" {
-			s := motan.TrimSplit(export, ":")
-			if len(s) == 1 {
-				port = s[0]
-			} else if len(s) == 2 {
-				if s[0] != "" {
-					protocol = s[0]
-				}
-				port = s[1]
-			}
-		}
-		url.Protocol = protocol
-		porti, err := strconv.Atoi(port)
+	if service == nil {
+		return
+	}
+	urls, err := motan.ExportURLs(url)
+	if err != nil {
+		vlog.Errorf("export port not int. url:%+v, err:%v\n", url, err)
+		return
+	}
+	var warmUp func() error
+	if w, ok := service.(motan.WarmUpService); ok {
+		warmUp = onceWarmUp(w.WarmUp)
+	}
+	// the exports of a service share the provider, and each has its own filters
+	var p motan.Provider
+	for _, u := range urls {
+		u.Protocol, u.Port, err = motan.ParseExportInfo(u.GetParam(motan.ExportKey, ""))
 		if err != nil {
-			vlog.Errorf("export port not int. port:%s, url:%+v\n", port, url)
+			vlog.Errorf("export port not int. url:%+v\n", u)
 			return
 		}
-		url.Port = porti
-		if url.Host == "" {
-			url.Host = motan.GetLocalIP()
-		}
-		url.ClearCachedInfo()
-		provider := GetDefaultExtFactory().GetProvider(url)
-		provider.SetService(service)
-		motan.Initialize(provider)
-		provider = mserver.WrapWithFilter(provider, m.extFactory, m.context)
-
-		exporter := &mserver.DefaultExporter{}
-		exporter.SetProvider(provider)
-
-		server := m.portServer[url.Port]
-
-		if server == nil {
-			server = m.extFactory.GetServer(url)
-			handler := GetDefaultExtFactory().GetMessageHandler("default")
-			motan.Initialize(handler)
-			handler.AddProvider(provider)
-			server.Open(false, false, handler, m.extFactory)
-			m.portServer[url.Port] = server
-		} else if canShareChannel(*url, *server.GetURL()) {
-			server.GetMessageHandler().AddProvider(provider)
-		} else {
-			vlog.Errorf("service export fail! can not share channel.url:%v, port url:%v\n", url, server.GetURL())
-			return
+		if u.Host == "" {
+			u.Host = motan.GetLocalIP()
 		}
-		err = exporter.Export(server, m.extFactory, m.context)
-		if err != nil {
-			vlog.Errorf("service export fail! url:%v, err:%v\n", url, err)
+		if _, ok := service.(provider.ServiceFactory); ok && u.GetParam(motan.ProviderKey, "") == "" {
+			u.PutParam(motan.ProviderKey, provider.Pool)
+		}
+		u.ClearCachedInfo()
+		if p == nil {
+			p = GetDefaultExtFactory().GetProvider(u)
+			p.SetService(service)
+			motan.Initialize(p)
+			m.exportProvider(u, p, warmUp)
 		} else {
-			vlog.Infof("service export success. url:%v\n", url)
-			for _, r := range exporter.Registries {
-				rid := r.GetURL().GetIdentity()
-				if _, ok := m.registries[rid]; !ok {
-					m.registries[rid] = r
-				}
+			m.exportProvider(u, &exportedProvider{Provider: p, url: u}, warmUp)
+		}
+	}
+}
+
+func (m *MSContext) exportProvider(url *motan.URL, provider motan.Provider, warmUp func() error) {
+	provider = mserver.WrapWithFilter(provider, m.extFactory, m.context)
+
+	exporter := &mserver.DefaultExporter{}
+	exporter.SetProvider(provider)
+	exporter.SetWarmUp(warmUp)
+
+	server := m.portServer[url.Port]
+
+	if server == nil {
+		server = m.extFactory.GetServer(url)
+		handler := GetDefaultExtFactory().GetMessageHandler("default")
+		motan.Initialize(handler)
+		handler.AddProvider(provider)
+		server.Open(false, false, handler, m.extFactory)
+		m.portServer[url.Port] = server
+	} else if canShareChannel(*url, *server.GetURL()) {
+		server.GetMessageHandler().AddProvider(provider)
+	} else {
+		vlog.Errorf("service export fail! can not share channel.url:%v, port url:%v\n", url, server.GetURL())
+		return
+	}
+	err := exporter.Export(server, m.extFactory, m.context)
+	if err != nil {
+		vlog.Errorf("service export fail! url:%v, err:%v\n", url, err)
+	} else {
+		vlog.Infof("service export success. url:%v\n", url)
+		m.exporters = append(m.exporters, exporter)
+		for _, r := range exporter.Registries {
+			rid := r.GetURL().GetIdentity()
+			if _, ok := m.registries[rid]; !ok {
+				m.registries[rid] = r
 			}
 		}
 	}
 }
 
+// onceWarmUp makes the exports of a service share the warm-up, which is not called again after success
+func onceWarmUp(warmUp func() error) func() error {
+	var lock sync.Mutex
+	done := false
+	return func() error {
+		lock.Lock()
+		defer lock.Unlock()
+		if done {
+			return nil
+		}
+		if err := warmUp(); err != nil {
+			return err
+		}
+		done = true
+		return nil
+	}
+}
+
+// exportedProvider is the provider shared by another export of the service, with the url of this export
+type exportedProvider struct {
+	motan.Provider
+	url *motan.URL
+}
+
+func (e *exportedProvider) GetURL() *motan.URL {
+	return e.url
+}
+
+func (e *exportedProvider) SetURL(url *motan.URL) {
+	e.url = url
+}
+
+func (e *exportedProvider) DescribeMethods() []*motan.MethodDescriptor {
+	if md, ok := e.Provider.(motan.MethodDescriber); ok {
+		return md.DescribeMethods()
+	}
+	return nil
+}
+
 func (m *MSContext) Initialize() {
 	m.csync.Lock()
 	defer m.csync.Unlock()
@@ -199,15 +273,93 @@ func (m *MSContext) RegisterService(s interface{}, sid string) error {
 	return nil
 }
 
-// ServicesAvailable will enable all service registed in registries
+// Ready registers the lazy exported services to the registries. the servers are listening since Start,
+// but the services with motan.LazyExportKey are not registered until Ready is called
+// PushControl pushes the control message to the clients connected to all servers of the context, such as the weight
+// changes or the drain notices, the number of the clients sent is returned
+func (m *MSContext) PushControl(kind string, payload []byte) (int, error) {
+	m.csync.Lock()
+	defer m.csync.Unlock()
+	sent := 0
+	for _, s := range m.portServer {
+		if p, ok := s.(mserver.ControlPusher); ok {
+			n, err := p.Push(kind, payload)
+			if err != nil {
+				return sent, err
+			}
+			sent += n
+		}
+	}
+	return sent, nil
+}
+
+func (m *MSContext) Ready() {
+	m.csync.Lock()
+	defer m.csync.Unlock()
+	for _, e := range m.exporters {
+		e.Ready()
+	}
+}
+
+// UpdateServiceParams updates the url params of all exports of the service path at runtime,
+// see mserver.DefaultExporter.UpdateParams
+func (m *MSContext) UpdateServiceParams(path string, params map[string]string) error {
+	m.csync.Lock()
+	defer m.csync.Unlock()
+	found := false
+	for _, e := range m.exporters {
+		if e.GetURL().Path != path {
+			continue
+		}
+		found = true
+		if err := e.UpdateParams(params); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return errors.New("service not exported: " + path)
+	}
+	return nil
+}
+
+// RegisterServiceFactory registers the factory of the service instances with serviceId for config ref. the service is
+// exported by a pool provider, which calls each request by an instance checked out from the pool, for the services can
+// not be called concurrently. the size of the pool is set by providerPoolSize in the service config
+func (m *MSContext) RegisterServiceFactory(factory provider.ServiceFactory, sid string) error {
+	if factory == nil || sid == "" {
+		vlog.Errorln("MSContext register service factory without factory or service id!")
+		return errors.New("register service factory without factory or service id")
+	}
+	for _, url := range m.context.ServiceURLs {
+		if url.Parameters != nil && sid == url.Parameters[motan.RefKey] {
+			m.serviceImpls[sid] = factory
+			return nil
+		}
+	}
+	vlog.Errorf("can not find export config for register service factory. sid:%s\n", sid)
+	return errors.New("can not find export config for register service factory")
+}
+
+// ServicesAvailable will enable all service registed in registries.
+// the services registered later, such as the lazy exported ones or the ones warming up, are enabled after registering
 func (m *MSContext) ServicesAvailable() {
 	// TODO: same as agent
 	availableService(m.registries)
+	m.csync.Lock()
+	defer m.csync.Unlock()
+	for _, e := range m.exporters {
+		e.Available()
+	}
 }
 
 // ServicesUnavailable will enable all service registed in registries
 func (m *MSContext) ServicesUnavailable() {
 	unavailableService(m.registries)
+	m.csync.Lock()
+	defer m.csync.Unlock()
+	for _, e := range m.exporters {
+		e.Unavailable()
+	}
 }
 
 func canShareChannel(u1 motan.URL, u2 motan.URL) bool {
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	"github.com/weibocom/motan-go/metrics"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+// ACLRule allows the application to call the methods of the service
+type ACLRule struct {
+	Service     string   `json:"service"`     // the path of the service, "*" or empty for all services of the group
+	Application string   `json:"application"` // the caller application or the auth principal, "*" for any caller
+	Methods     []string `json:"methods"`     // "*" or empty for all methods
+}
+
+func (r *ACLRule) matchService(service string) bool {
+	return r.Service == "" || r.Service == "*" || r.Service == service
+}
+
+func (r *ACLRule) allow(caller string, method string) bool {
+	if r.Application != "*" && r.Application != caller {
+		return false
+	}
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if m == "*" || motan.FirstUpper(m) == method {
+			return true
+		}
+	}
+	return false
+}
+
+// ACL is the access control of the services. the rules of a service are configured statically by ACLKey in the
+// export config and dynamically by the acl of the service commands of the registries. a service without any rule
+// can be called by any caller, otherwise only the callers allowed by the rules can call it
+type ACL struct {
+	lock    sync.RWMutex
+	static  map[string][]ACLRule // service -> rules
+	command map[string][]ACLRule // service -> rules
+}
+
+func NewACL() *ACL {
+	return &ACL{static: make(map[string][]ACLRule), command: make(map[string][]ACLRule)}
+}
+
+// DefaultACL is the access control of the services of all servers
+var DefaultACL = NewACL()
+
+// ParseACL parses the rules of the service like "app1:Hello,World;app2:*"
+func ParseACL(service string, acl string) []ACLRule {
+	var rules []ACLRule
+	for _, item := range motan.TrimSplit(acl, ";") {
+		if item == "" {
+			continue
+		}
+		rule := ACLRule{Service: service, Application: item}
+		if i := strings.Index(item, ":"); i >= 0 {
+			rule.Application = strings.TrimSpace(item[:i])
+			rule.Methods = motan.TrimSplit(item[i+1:], ",")
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (a *ACL) SetStaticRules(service string, rules []ACLRule) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if len(rules) == 0 {
+		delete(a.static, service)
+	} else {
+		a.static[service] = rules
+	}
+}
+
+func (a *ACL) SetCommandRules(service string, rules []ACLRule) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if len(rules) == 0 {
+		delete(a.command, service)
+	} else {
+		a.command[service] = rules
+	}
+}
+
+// Allow returns true if the caller can call the method of the service
+func (a *ACL) Allow(service string, method string, caller string) bool {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	static, command := a.static[service], a.command[service]
+	if len(static) == 0 && len(command) == 0 {
+		return true
+	}
+	method = motan.FirstUpper(method)
+	for _, rules := range [][]ACLRule{static, command} {
+		for i := range rules {
+			if rules[i].allow(caller, method) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// aclCaller returns the identity of the caller, the principal authenticated or the application of the caller
+func aclCaller(request motan.Request) string {
+	if principal := request.GetAttachment(motan.AuthPrincipalKey); principal != "" {
+		return principal
+	}
+	return request.GetAttachment(mpro.MSource)
+}
+
+// aclFilter rejects the requests not allowed by the acl, it is inside the auth filter to use the principal
+type aclFilter struct {
+	acl  *ACL
+	url  *motan.URL
+	next motan.EndPointFilter
+}
+
+func (f *aclFilter) GetName() string {
+	return motan.ACLKey
+}
+
+func (f *aclFilter) NewFilter(url *motan.URL) motan.Filter {
+	return &aclFilter{acl: f.acl, url: url}
+}
+
+func (f *aclFilter) GetIndex() int {
+	return 3
+}
+
+func (f *aclFilter) GetType() int32 {
+	return motan.EndPointFilterType
+}
+
+func (f *aclFilter) HasNext() bool {
+	return f.next != nil
+}
+
+func (f *aclFilter) SetNext(nextFilter motan.EndPointFilter) {
+	f.next = nextFilter
+}
+
+func (f *aclFilter) GetNext() motan.EndPointFilter {
+	return f.next
+}
+
+func (f *aclFilter) Filter(caller motan.Caller, request motan.Request) motan.Response {
+	service := request.GetServiceName()
+	if who := aclCaller(request); !f.acl.Allow(service, request.GetMethod(), who) {
+		// the audit log of the denials
+		vlog.Warningw("acl deny request", vlog.String("service", service), vlog.String("method", request.GetMethod()), vlog.String("caller", who),
+			vlog.String("remote", request.GetAttachment(motan.HostKey)), vlog.Uint64("rid", request.GetRequestID()))
+		metrics.AddCounter(f.url.Group, service, "motan-server-acl:"+who+":"+request.GetMethod()+".deny_count", 1)
+		return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewException(motan.ErrCodeForbidden, "access denied: "+service+"."+request.GetMethod()))
+	}
+	return f.next.Filter(caller, request)
+}
+
+// aclCommand is the acl of the service commands, other fields of the commands are used by the clients
+type aclCommand struct {
+	ACL []ACLRule `json:"acl"`
+}
+
+// aclCommandListener updates the command rules of an exported service by the service commands of a registry
+type aclCommandListener struct {
+	url *motan.URL
+	acl *ACL
+}
+
+func (l *aclCommandListener) GetIdentity() string {
+	return "aclCommandListener-" + l.url.GetIdentity()
+}
+
+func (l *aclCommandListener) NotifyCommand(registryURL *motan.URL, commandType int, commandInfo string) {
+	var command aclCommand
+	if commandInfo != "" {
+		if err := json.Unmarshal([]byte(commandInfo), &command); err != nil {
+			vlog.Warningf("parse acl command fail. service:%s, err:%v\n", l.url.Path, err)
+			return
+		}
+	}
+	rules := make([]ACLRule, 0, len(command.ACL))
+	for _, rule := range command.ACL {
+		if rule.matchService(l.url.Path) {
+			rules = append(rules, rule)
+		}
+	}
+	vlog.Infof("acl command of service %s updated. rules:%+v\n", l.url.Path, rules)
+	l.acl.SetCommandRules(l.url.Path, rules)
+}
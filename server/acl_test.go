@@ -0,0 +1,72 @@
+package server
+
+import (
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+	mpro "github.com/weibocom/motan-go/protocol"
+	"github.com/weibocom/motan-go/provider"
+)
+
+func TestACL(t *testing.T) {
+	acl := NewACL()
+	if !acl.Allow("s1", "hello", "app1") {
+		t.Errorf("service without rules should be allowed")
+	}
+	acl.SetStaticRules("s1", ParseACL("s1", "app1:hello, World;app2:*;app3"))
+	for _, c := range []struct {
+		method, caller string
+		allowed        bool
+	}{
+		{"Hello", "app1", true}, {"world", "app1", true}, {"other", "app1", false},
+		{"other", "app2", true}, {"other", "app3", true}, {"hello", "app4", false}, {"hello", "", false},
+	} {
+		if acl.Allow("s1", c.method, c.caller) != c.allowed {
+			t.Errorf("wrong acl. method:%s, caller:%s, expect:%v", c.method, c.caller, c.allowed)
+		}
+	}
+	if !acl.Allow("s2", "hello", "app4") {
+		t.Errorf("the rules of other services should not be applied")
+	}
+
+	l := &aclCommandListener{url: &motan.URL{Path: "s1"}, acl: acl}
+	l.NotifyCommand(nil, 1, `{"clientCommandList":[],"acl":[{"service":"s1","application":"app4","methods":["hello"]},{"service":"s2","application":"app5"}]}`)
+	if !acl.Allow("s1", "hello", "app4") || acl.Allow("s1", "hello", "app5") {
+		t.Errorf("the command rules of the service should be applied")
+	}
+	l.NotifyCommand(nil, 1, `{"clientCommandList":[]}`)
+	if acl.Allow("s1", "hello", "app4") {
+		t.Errorf("the command rules should be removed")
+	}
+	acl.SetStaticRules("s1", nil)
+	if !acl.Allow("s1", "hello", "app4") {
+		t.Errorf("service without rules should be allowed")
+	}
+}
+
+func TestACLFilter(t *testing.T) {
+	defer DefaultACL.SetStaticRules("replayService", nil)
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	p := &provider.DefaultProvider{}
+	p.SetURL(&motan.URL{Path: "replayService", Parameters: map[string]string{motan.ACLKey: "app1:echo"}})
+	p.SetService(&replayService{})
+	p.Initialize()
+	wrapper := WrapWithFilter(p, ext, &motan.Context{})
+	call := func(caller string) motan.Response {
+		request := &motan.MotanRequest{ServiceName: "replayService", Method: "echo", Arguments: []interface{}{"a"}, Attachment: motan.NewStringMap(0)}
+		request.SetAttachment(mpro.MSource, caller)
+		return wrapper.Call(request)
+	}
+	if res := call("app1"); res.GetException() != nil {
+		t.Errorf("allowed caller should be called. exception:%+v", res.GetException())
+	}
+	if res := call("app2"); res.GetException() == nil || res.GetException().ErrCode != motan.ErrCodeForbidden {
+		t.Errorf("caller not allowed should be rejected. res:%+v", res)
+	}
+	// the rules are updated with the url
+	wrapper.SetURL(&motan.URL{Path: "replayService"})
+	if res := call("app2"); res.GetException() != nil {
+		t.Errorf("caller should be allowed after the acl removed. exception:%+v", res.GetException())
+	}
+}
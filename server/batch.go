@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+// the default max requests of a batch processed concurrently
+const defaultBatchConcurrency = 16
+
+// processBatch handles the requests of a batch message concurrently, at most batchConcurrency requests at the same
+// time, and packs the responses in the same order. the batch with more than maxBatchSize requests is rejected
+func (m *MotanServer) processBatch(ctx context.Context, batch *mpro.Message, received time.Time) *mpro.Message {
+	maxSize := m.maxBatchSize
+	if maxSize <= 0 {
+		maxSize = mpro.DefaultMaxBatchSize
+	}
+	requests, err := mpro.DecodeBatchLimit(batch, maxSize)
+	if err != nil {
+		vlog.Errorf("motan server decode batch message fail. rid:%d, err:%s\n", batch.Header.RequestID, err.Error())
+		code := motan.ErrCodeInternal
+		if err == mpro.ErrBatchTooLarge {
+			code = motan.ErrCodeBadRequest
+		}
+		return mpro.BuildExceptionResponse(batch.Header.RequestID, mpro.ExceptionToJSON(motan.NewException(code, "decode batch fail. err:"+err.Error())))
+	}
+	concurrency := m.batchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	host := batch.Metadata.LoadOrEmpty(motan.HostKey)
+	responses := make([]*mpro.Message, len(requests))
+	tokens := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		// every request of a batch needs a response
+		request.Header.SetOneWay(false)
+		request.Metadata.Store(motan.HostKey, host)
+		wg.Add(1)
+		tokens <- struct{}{}
+		go func(i int, request *mpro.Message) {
+			defer wg.Done()
+			defer func() { <-tokens }()
+			defer motan.HandlePanic(func() {
+				responses[i] = mpro.BuildExceptionResponse(request.Header.RequestID, mpro.ExceptionToJSON(&motan.Exception{ErrCode: 500, ErrMsg: "batch request process panic", ErrType: motan.ServiceException}))
+			})
+			requestID := request.Header.RequestID
+			res := m.handleMessage(ctx, request, received, nil)
+			res.Header.RequestID = requestID
+			responses[i] = res
+		}(i, request)
+	}
+	wg.Wait()
+	return mpro.BuildBatchMessage(mpro.Res, batch.Header.RequestID, responses)
+}
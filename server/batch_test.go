@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+type batchService struct{}
+
+func (s *batchService) Hello(name string) string {
+	return "hello " + name
+}
+
+func TestBatchCall(t *testing.T) {
+	server, ep := startTestServer(t, "batchService", &batchService{}, nil, nil)
+	defer server.Destroy()
+	defer ep.Destroy()
+
+	names := []string{"a", "b", "c"}
+	replies := make([]string, len(names)+1)
+	requests := make([]motan.Request, 0, len(names)+1)
+	for i, name := range names {
+		req := &motan.MotanRequest{ServiceName: "batchService", Method: "hello", Arguments: []interface{}{name}, Attachment: motan.NewStringMap(0)}
+		req.GetRPCContext(true).Reply = &replies[i]
+		requests = append(requests, req)
+	}
+	notFound := &motan.MotanRequest{ServiceName: "batchService", Method: "notFound", Attachment: motan.NewStringMap(0)}
+	notFound.GetRPCContext(true).Reply = &replies[len(names)]
+	requests = append(requests, notFound)
+
+	request := &motan.MotanRequest{ServiceName: "batchService", Method: "$batch", Attachment: motan.NewStringMap(0)}
+	request.GetRPCContext(true).BatchRequests = requests
+	res := ep.Call(request)
+	if res.GetException() != nil {
+		t.Fatalf("batch call fail. err:%v", res.GetException())
+	}
+	responses, ok := res.GetValue().([]motan.Response)
+	if !ok || len(responses) != len(requests) {
+		t.Fatalf("response value should be all responses of batch. value:%v", res.GetValue())
+	}
+	for i, name := range names {
+		if responses[i].GetException() != nil || replies[i] != "hello "+name {
+			t.Errorf("batch sub call fail. reply:%s, err:%v", replies[i], responses[i].GetException())
+		}
+	}
+	if responses[len(names)].GetException() == nil {
+		t.Errorf("batch sub call of unknown method should fail")
+	}
+}
+
+func TestBatchTooLarge(t *testing.T) {
+	server := &MotanServer{maxBatchSize: 2}
+	msgs := make([]*mpro.Message, 0, 3)
+	for i := 0; i < 3; i++ {
+		msgs = append(msgs, &mpro.Message{Header: mpro.BuildHeader(mpro.Req, false, mpro.Simple, uint64(i), mpro.Normal), Metadata: motan.NewStringMap(0)})
+	}
+	res := server.processBatch(context.Background(), mpro.BuildBatchMessage(mpro.Req, 1, msgs), time.Now())
+	if res.IsBatch() || !strings.Contains(res.Metadata.LoadOrEmpty(mpro.MExceptionn), "400") {
+		t.Errorf("batch with too many requests should be rejected. res:%+v", res.Metadata.RawMap())
+	}
+}
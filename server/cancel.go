@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// serverCalls holds the cancel functions of the calls in process of one connection,
+// so the calls can be canceled by the cancel frames from the client.
+type serverCalls struct {
+	lock    sync.Mutex
+	cancels map[uint64]context.CancelFunc
+}
+
+func newServerCalls() *serverCalls {
+	return &serverCalls{cancels: make(map[uint64]context.CancelFunc, 64)}
+}
+
+// start returns the context of the call and the function to call when it finished
+func (s *serverCalls) start(requestID uint64) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.lock.Lock()
+	s.cancels[requestID] = cancel
+	s.lock.Unlock()
+	return ctx, func() {
+		s.lock.Lock()
+		delete(s.cancels, requestID)
+		s.lock.Unlock()
+		cancel()
+	}
+}
+
+func (s *serverCalls) cancel(requestID uint64) bool {
+	s.lock.Lock()
+	cancel := s.cancels[requestID]
+	delete(s.cancels, requestID)
+	s.lock.Unlock()
+	if cancel == nil {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// cancelAll cancels all calls when the connection is closed, no response can be sent anymore
+func (s *serverCalls) cancelAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for id, cancel := range s.cancels {
+		cancel()
+		delete(s.cancels, id)
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
+)
+
+type cancelService struct {
+	canceled chan error
+}
+
+func (c *cancelService) Ping() bool {
+	return true
+}
+
+func (c *cancelService) Wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		c.canceled <- ctx.Err()
+	case <-time.After(5 * time.Second):
+		c.canceled <- nil
+	}
+	return true
+}
+
+func TestCancelFrame(t *testing.T) {
+	service := &cancelService{canceled: make(chan error, 1)}
+	server, ep := startTestServer(t, "cancelService", service, nil, map[string]string{"requestTimeout": "10000", endpoint.ChannelPoolSizeKey: "1"})
+	defer server.Destroy()
+	defer ep.Destroy()
+
+	// the cancel frames are sent after the server advertised it handles them
+	if res := ep.Call(&motan.MotanRequest{ServiceName: "cancelService", Method: "ping", Attachment: motan.NewStringMap(0)}); res.GetException() != nil {
+		t.Fatalf("ping fail. res:%+v", res)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	request := &motan.MotanRequest{ServiceName: "cancelService", Method: "wait", Attachment: motan.NewStringMap(0)}
+	res := motan.CallContext(ctx, ep, request)
+	if res.GetException() == nil {
+		t.Fatalf("canceled call should fail")
+	}
+	select {
+	case err := <-service.canceled:
+		if err != context.Canceled {
+			t.Errorf("provider should be canceled by the cancel frame. err:%v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("provider is not canceled")
+	}
+}
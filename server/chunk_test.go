@@ -0,0 +1,34 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
+)
+
+type echoService struct{}
+
+func (e *echoService) Echo(s string) string {
+	return s
+}
+
+func TestChunkedCall(t *testing.T) {
+	server, ep := startTestServer(t, "echoService", &echoService{}, map[string]string{motan.MaxFrameSizeKey: "1024"},
+		map[string]string{motan.MaxFrameSizeKey: "512", endpoint.ChannelPoolSizeKey: "1"})
+	defer server.Destroy()
+	defer ep.Destroy()
+
+	payload := strings.Repeat("motan", 2000)
+	// the response of the first call is chunked, the request of the second call is chunked too
+	for i := 0; i < 2; i++ {
+		var reply string
+		request := &motan.MotanRequest{ServiceName: "echoService", Method: "echo", Arguments: []interface{}{payload}, Attachment: motan.NewStringMap(0)}
+		request.GetRPCContext(true).Reply = &reply
+		res := ep.Call(request)
+		if res.GetException() != nil || reply != payload {
+			t.Fatalf("chunked call fail. len:%d, err:%v", len(reply), res.GetException())
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	motan "github.com/weibocom/motan-go/core"
+)
+
+// CompressNone disables the compression of the method level config when the service compresses the responses
+const CompressNone = "none"
+
+// SetResponseCompression sets the compression of the response by the config of the service url. the method level
+// config such as "Get().compress" and "Get().mingzSize" takes precedence over the service level. compress is the
+// codecs in order of preference, negotiated with the codecs the client advertised, and mingzSize is the min body size
+// to compress. the responses are only gzipped by mingzSize if compress is not set, as before
+func SetResponseCompression(url *motan.URL, request motan.Request, res motan.Response) {
+	rc := res.GetRPCContext(true)
+	method, desc := request.GetMethod(), request.GetMethodDesc()
+	codecs := url.GetMethodParam(method, desc, motan.CompressKey, "")
+	if codecs == CompressNone {
+		rc.Compress = ""
+		rc.GzipSize = 0
+		return
+	}
+	rc.Compress = codecs
+	rc.GzipSize = int(url.GetMethodIntValue(method, desc, motan.GzipSizeKey, 0))
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/weibocom/motan-go/compress"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
+	"github.com/weibocom/motan-go/serialize"
+)
+
+type countingCompressor struct {
+	compress.SnappyCompressor
+	compressed   *int32
+	decompressed *int32
+}
+
+func (c *countingCompressor) GetName() string {
+	return "counting"
+}
+
+func (c *countingCompressor) Compress(data []byte) ([]byte, error) {
+	atomic.AddInt32(c.compressed, 1)
+	return c.SnappyCompressor.Compress(data)
+}
+
+func (c *countingCompressor) Decompress(data []byte) ([]byte, error) {
+	atomic.AddInt32(c.decompressed, 1)
+	return c.SnappyCompressor.Decompress(data)
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	var compressed, decompressed int32
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	serialize.RegistDefaultSerializations(ext)
+	endpoint.RegistDefaultEndpoint(ext)
+	ext.RegistExtCompressor("counting", func() motan.Compressor {
+		return &countingCompressor{compressed: &compressed, decompressed: &decompressed}
+	})
+
+	server := openTestServer(t, ext, newTestHandler("echoService", &echoService{}, map[string]string{motan.CompressKey: "counting"}), nil)
+	defer server.Destroy()
+
+	ep := ext.GetEndPoint(&motan.URL{Protocol: "motan2", Host: "127.0.0.1", Port: server.URL.Port, Parameters: map[string]string{motan.CompressKey: "counting,gzip", endpoint.ChannelPoolSizeKey: "1"}})
+	ep.SetSerialization(&serialize.SimpleSerialization{})
+	motan.Initialize(ep)
+	defer ep.Destroy()
+
+	payload := strings.Repeat("motan", 2000)
+	call := func() {
+		var reply string
+		request := &motan.MotanRequest{ServiceName: "echoService", Method: "echo", Arguments: []interface{}{payload}, Attachment: motan.NewStringMap(0)}
+		request.GetRPCContext(true).Reply = &reply
+		res := ep.Call(request)
+		if res.GetException() != nil || reply != payload {
+			t.Fatalf("compressed call fail. len:%d, err:%v", len(reply), res.GetException())
+		}
+	}
+	// the first request is compressed by gzip because the compressors of the server are unknown
+	call()
+	if atomic.LoadInt32(&compressed) != 1 || atomic.LoadInt32(&decompressed) != 1 {
+		t.Fatalf("only the response should be compressed. compressed:%d, decompressed:%d", compressed, decompressed)
+	}
+	call()
+	if atomic.LoadInt32(&compressed) != 3 || atomic.LoadInt32(&decompressed) != 3 {
+		t.Fatalf("the request and response should be compressed. compressed:%d, decompressed:%d", compressed, decompressed)
+	}
+}
+
+func TestSetResponseCompression(t *testing.T) {
+	url := &motan.URL{Parameters: map[string]string{
+		motan.CompressKey:     "snappy,gzip",
+		motan.GzipSizeKey:     "100",
+		"Big().compress":      "gzip",
+		"Big().mingzSize":     "10000",
+		"Small().compress":    CompressNone,
+		"Other(int).compress": "snappy",
+	}}
+	for _, c := range []struct {
+		method, desc, compress string
+		size                   int
+	}{
+		{"Get", "", "snappy,gzip", 100},
+		{"Big", "", "gzip", 10000},
+		{"Small", "", "", 0},
+		{"Other", "int", "snappy", 100},
+		{"Other", "", "snappy,gzip", 100},
+	} {
+		res := &motan.MotanResponse{}
+		SetResponseCompression(url, &motan.MotanRequest{Method: c.method, MethodDesc: c.desc}, res)
+		if rc := res.GetRPCContext(true); rc.Compress != c.compress || rc.GzipSize != c.size {
+			t.Errorf("wrong compression. method:%s(%s), compress:%s, size:%d", c.method, c.desc, rc.Compress, rc.GzipSize)
+		}
+	}
+
+	// only gzip by size without compress config
+	res := &motan.MotanResponse{}
+	SetResponseCompression(&motan.URL{Parameters: map[string]string{motan.GzipSizeKey: "200"}}, &motan.MotanRequest{Method: "Get"}, res)
+	if rc := res.GetRPCContext(true); rc.Compress != "" || rc.GzipSize != 200 {
+		t.Errorf("wrong gzip size. rc:%+v", rc)
+	}
+}
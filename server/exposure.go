@@ -0,0 +1,58 @@
+package server
+
+import (
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// MethodExposure controls the methods of an exported service can be called, by the whitelist of ExportMethodsKey or
+// the blacklist of ExcludeMethodsKey in the export config. the method names are matched ignoring the case of the
+// first letter, as the providers do
+type MethodExposure struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// NewMethodExposure creates the exposure with the params of the service url, nil if all methods are exposed
+func NewMethodExposure(url *motan.URL) *MethodExposure {
+	include := methodSet(url.GetParam(motan.ExportMethodsKey, ""))
+	exclude := methodSet(url.GetParam(motan.ExcludeMethodsKey, ""))
+	if include == nil && exclude == nil {
+		return nil
+	}
+	return &MethodExposure{include: include, exclude: exclude}
+}
+
+func methodSet(methods string) map[string]bool {
+	if methods == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, m := range motan.TrimSplit(methods, ",") {
+		if m != "" {
+			set[motan.FirstUpper(m)] = true
+		}
+	}
+	return set
+}
+
+// IsExposed returns true if the method can be called, nil exposure exposes all methods
+func (e *MethodExposure) IsExposed(method string) bool {
+	if e == nil {
+		return true
+	}
+	if method == "" {
+		return false
+	}
+	method = motan.FirstUpper(method)
+	if e.include != nil && !e.include[method] {
+		return false
+	}
+	return !e.exclude[method]
+}
+
+// MethodNotExported builds the response of the request calling a method not exposed by the export config
+func MethodNotExported(request motan.Request) motan.Response {
+	vlog.Warningf("method not exported. %s\n", motan.GetReqInfo(request))
+	return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewException(motan.ErrCodeForbidden, "method not exported: "+request.GetServiceName()+"."+request.GetMethod()))
+}
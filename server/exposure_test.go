@@ -0,0 +1,55 @@
+package server
+
+import (
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/provider"
+)
+
+func TestMethodExposure(t *testing.T) {
+	if NewMethodExposure(&motan.URL{}) != nil {
+		t.Errorf("exposure should be nil without config")
+	}
+	var e *MethodExposure
+	if !e.IsExposed("any") {
+		t.Errorf("all methods should be exposed by nil exposure")
+	}
+	e = NewMethodExposure(&motan.URL{Parameters: map[string]string{motan.ExportMethodsKey: "hello, World"}})
+	for m, exposed := range map[string]bool{"Hello": true, "hello": true, "world": true, "Other": false, "": false} {
+		if e.IsExposed(m) != exposed {
+			t.Errorf("wrong exposure of whitelist. method:%s, expect:%v", m, exposed)
+		}
+	}
+	e = NewMethodExposure(&motan.URL{Parameters: map[string]string{motan.ExportMethodsKey: "Hello,World", motan.ExcludeMethodsKey: "world"}})
+	for m, exposed := range map[string]bool{"hello": true, "World": false, "Other": false} {
+		if e.IsExposed(m) != exposed {
+			t.Errorf("wrong exposure of whitelist and blacklist. method:%s, expect:%v", m, exposed)
+		}
+	}
+}
+
+func TestMessageHandlerExposure(t *testing.T) {
+	p := &provider.DefaultProvider{}
+	p.SetURL(&motan.URL{Path: "replayService", Parameters: map[string]string{motan.ExcludeMethodsKey: "Echo"}})
+	p.SetService(&replayService{})
+	p.Initialize()
+	handler := &DefaultMessageHandler{}
+	handler.Initialize()
+	handler.AddProvider(p)
+	res := handler.Call(&motan.MotanRequest{ServiceName: "replayService", Method: "echo", Arguments: []interface{}{"a"}, Attachment: motan.NewStringMap(0)})
+	if res.GetException() == nil || res.GetException().ErrMsg != "method not exported: replayService.echo" {
+		t.Errorf("method not exported should be rejected. res:%+v", res)
+	}
+
+	// the exposure is updated with the provider
+	p2 := &provider.DefaultProvider{}
+	p2.SetURL(&motan.URL{Path: "replayService"})
+	p2.SetService(&replayService{})
+	p2.Initialize()
+	handler.AddProvider(p2)
+	res = handler.Call(&motan.MotanRequest{ServiceName: "replayService", Method: "echo", Arguments: []interface{}{"a"}, Attachment: motan.NewStringMap(0)})
+	if res.GetException() != nil {
+		t.Errorf("exposed method should be called. exception:%+v", res.GetException())
+	}
+}
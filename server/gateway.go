@@ -0,0 +1,317 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mpro "github.com/weibocom/motan-go/protocol"
+	"github.com/weibocom/motan-go/transport"
+)
+
+const (
+	// GatewayGroupHeader sets the group of the service if the route has no group
+	GatewayGroupHeader = "X-Motan-Group"
+
+	gatewayMaxBodySize = 4 * 1024 * 1024
+)
+
+// GatewayConfig is the config of the http gateway, e.g. the motan-gateway section of the agent
+type GatewayConfig struct {
+	Port    int             `mapstructure:"port"`
+	Headers string          `mapstructure:"headers"` // the header mapping of all routes
+	Routes  []*GatewayRoute `mapstructure:"routes"`
+}
+
+// GatewayRoute maps the http requests of the path to the method of the service. if no route is configured,
+// the path /service/method is mapped to the method of the service
+type GatewayRoute struct {
+	// exact path, or the prefix ending with /* whose rest is the method if Method is not set
+	Path        string `mapstructure:"path"`
+	Service     string `mapstructure:"service"`
+	Method      string `mapstructure:"method"`
+	Group       string `mapstructure:"group"`
+	Protocol    string `mapstructure:"protocol"`    // the protocol of the cluster, motan2 by default
+	HTTPMethods string `mapstructure:"httpMethods"` // comma separated, all allowed if not set
+	// comma separated header:attachment, the values of the headers are set as the attachments
+	Headers string `mapstructure:"headers"`
+	// comma separated names of the query or form params as the arguments in order,
+	// or the params are the only argument as a map if not set. a json body is always the arguments if it is an array
+	Args string `mapstructure:"args"`
+
+	headers     map[string]string
+	httpMethods map[string]bool
+	args        []string
+}
+
+// GatewayServer maps the http requests to the motan calls of the handler, so the rest clients can call motan services.
+// the arguments are from the json body, the form or the query params, and the value of the response is
+// responded in json, or as plain text if the client only accepts text/plain
+type GatewayServer struct {
+	URL        *motan.URL
+	Config     *GatewayConfig
+	handler    motan.MessageHandler
+	listener   net.Listener
+	extFactory motan.ExtensionFactory
+	requestID  uint64
+	headers    map[string]string
+	routes     map[string]*GatewayRoute
+	prefixes   []*GatewayRoute // longest first
+}
+
+func (g *GatewayServer) Open(block bool, proxy bool, handler motan.MessageHandler, extFactory motan.ExtensionFactory) error {
+	if g.Config == nil {
+		g.Config = &GatewayConfig{}
+	}
+	g.headers = parseHeaderMapping(g.Config.Headers)
+	g.routes = make(map[string]*GatewayRoute)
+	for _, r := range g.Config.Routes {
+		if r.Path == "" || r.Service == "" {
+			vlog.Warningf("gateway route without path or service is ignored. route:%+v\n", r)
+			continue
+		}
+		r.headers = parseHeaderMapping(r.Headers)
+		if r.HTTPMethods != "" {
+			r.httpMethods = make(map[string]bool)
+			for _, m := range motan.TrimSplit(r.HTTPMethods, ",") {
+				r.httpMethods[strings.ToUpper(m)] = true
+			}
+		}
+		if r.Args != "" {
+			r.args = motan.TrimSplit(r.Args, ",")
+		}
+		if strings.HasSuffix(r.Path, "/*") {
+			g.prefixes = append(g.prefixes, r)
+		} else {
+			g.routes[r.Path] = r
+		}
+	}
+	sort.SliceStable(g.prefixes, func(i, j int) bool { return len(g.prefixes[i].Path) > len(g.prefixes[j].Path) })
+
+	lis, err := transport.ListenExt(g.URL, extFactory)
+	if err != nil {
+		vlog.Errorf("listen gateway port:%d fail. err: %v\n", g.URL.Port, err)
+		return err
+	}
+	g.listener = lis
+	g.handler = handler
+	g.extFactory = extFactory
+	vlog.Infof("gateway server is started. port:%d, routes:%d\n", g.URL.Port, len(g.Config.Routes))
+	if block {
+		return http.Serve(lis, g)
+	}
+	go http.Serve(lis, g)
+	return nil
+}
+
+func (g *GatewayServer) GetMessageHandler() motan.MessageHandler {
+	return g.handler
+}
+
+func (g *GatewayServer) SetMessageHandler(mh motan.MessageHandler) {
+	g.handler = mh
+}
+
+func (g *GatewayServer) GetURL() *motan.URL {
+	return g.URL
+}
+
+func (g *GatewayServer) SetURL(url *motan.URL) {
+	g.URL = url
+}
+
+func (g *GatewayServer) GetName() string {
+	return "gateway"
+}
+
+func (g *GatewayServer) Destroy() {
+	err := g.listener.Close()
+	if err != nil {
+		vlog.Errorf("gateway server destroy fail.url %v, err :%s\n", g.URL, err.Error())
+	} else {
+		vlog.Infof("gateway server destroy sucess.url %v\n", g.URL)
+	}
+}
+
+func (g *GatewayServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer motan.HandlePanic(func() {
+		writeGatewayError(w, &motan.Exception{ErrCode: 500, ErrMsg: "gateway panic", ErrType: motan.ServiceException})
+	})
+	route, method := g.match(r.URL.Path)
+	if route == nil {
+		writeGatewayError(w, &motan.Exception{ErrCode: 404, ErrMsg: "no route for " + r.URL.Path, ErrType: motan.ServiceException})
+		return
+	}
+	if route.httpMethods != nil && !route.httpMethods[r.Method] {
+		writeGatewayError(w, &motan.Exception{ErrCode: 405, ErrMsg: "method not allowed: " + r.Method, ErrType: motan.ServiceException})
+		return
+	}
+	args, ex := gatewayArguments(r, route)
+	if ex != nil {
+		writeGatewayError(w, ex)
+		return
+	}
+	call := &JSONCall{Service: route.Service, Method: method, Group: route.Group, Arguments: args, Attachments: make(map[string]string)}
+	if call.Group == "" {
+		call.Group = r.Header.Get(GatewayGroupHeader)
+	}
+	for _, headers := range []map[string]string{g.headers, route.headers} {
+		for h, key := range headers {
+			if v := r.Header.Get(h); v != "" {
+				call.Attachments[key] = v
+			}
+		}
+	}
+	if route.Protocol != "" {
+		call.Attachments[mpro.MProxyProtocol] = route.Protocol
+	}
+	result := callJSON(g.handler, call, atomic.AddUint64(&g.requestID, 1), getRemoteIP(r.RemoteAddr))
+	if result.Exception != nil {
+		writeGatewayError(w, result.Exception)
+		return
+	}
+	writeGatewayValue(w, r, result.Value)
+}
+
+// match returns the route and the method of the path
+func (g *GatewayServer) match(path string) (*GatewayRoute, string) {
+	if len(g.Config.Routes) == 0 {
+		// /service/method
+		i := strings.LastIndex(path, "/")
+		if i <= 0 || i == len(path)-1 {
+			return nil, ""
+		}
+		return &GatewayRoute{Service: path[1:i]}, path[i+1:]
+	}
+	if r, ok := g.routes[path]; ok {
+		return r, r.Method
+	}
+	for _, r := range g.prefixes {
+		prefix := r.Path[:len(r.Path)-1]
+		if strings.HasPrefix(path, prefix) {
+			if r.Method != "" {
+				return r, r.Method
+			}
+			if method := path[len(prefix):]; method != "" && !strings.Contains(method, "/") {
+				return r, method
+			}
+		}
+	}
+	return nil, ""
+}
+
+func gatewayArguments(r *http.Request, route *GatewayRoute) ([]interface{}, *motan.Exception) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, &motan.Exception{ErrCode: 400, ErrMsg: "bad content type: " + contentType, ErrType: motan.ServiceException}
+		}
+		switch mediaType {
+		case "application/json":
+			// one more byte is read to tell the bodies too large from the ones of the max size
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, gatewayMaxBodySize+1))
+			if err != nil {
+				return nil, &motan.Exception{ErrCode: 400, ErrMsg: "read body fail: " + err.Error(), ErrType: motan.ServiceException}
+			}
+			if len(body) > gatewayMaxBodySize {
+				return nil, &motan.Exception{ErrCode: 413, ErrMsg: "body too large, the max size is " + strconv.Itoa(gatewayMaxBodySize), ErrType: motan.ServiceException}
+			}
+			if len(body) > 0 {
+				var v interface{}
+				if err = json.Unmarshal(body, &v); err != nil {
+					return nil, &motan.Exception{ErrCode: 400, ErrMsg: "bad json body: " + err.Error(), ErrType: motan.ServiceException}
+				}
+				if args, ok := v.([]interface{}); ok {
+					return args, nil
+				}
+				return []interface{}{v}, nil
+			}
+		case "application/x-www-form-urlencoded":
+		default:
+			if r.ContentLength != 0 {
+				return nil, &motan.Exception{ErrCode: 415, ErrMsg: "unsupported content type: " + mediaType, ErrType: motan.ServiceException}
+			}
+		}
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, &motan.Exception{ErrCode: 400, ErrMsg: "bad form: " + err.Error(), ErrType: motan.ServiceException}
+	}
+	if route.args != nil {
+		args := make([]interface{}, 0, len(route.args))
+		for _, name := range route.args {
+			args = append(args, r.Form.Get(name))
+		}
+		return args, nil
+	}
+	if len(r.Form) == 0 {
+		return nil, nil
+	}
+	params := make(map[string]string, len(r.Form))
+	for k := range r.Form {
+		params[k] = r.Form.Get(k)
+	}
+	return []interface{}{params}, nil
+}
+
+// writeGatewayValue writes the value in json, or as plain text if the client only accepts text/plain
+func writeGatewayValue(w http.ResponseWriter, r *http.Request, value interface{}) {
+	accept := r.Header.Get("Accept")
+	if accept != "" && !strings.Contains(accept, "json") && !strings.Contains(accept, "*/*") {
+		if !strings.Contains(accept, "text/plain") {
+			writeGatewayError(w, &motan.Exception{ErrCode: 406, ErrMsg: "not acceptable: " + accept, ErrType: motan.ServiceException})
+			return
+		}
+		switch v := value.(type) {
+		case string:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(v))
+			return
+		case []byte:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write(v)
+			return
+		}
+		writeGatewayError(w, &motan.Exception{ErrCode: 406, ErrMsg: "value is not text", ErrType: motan.ServiceException})
+		return
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		writeGatewayError(w, &motan.Exception{ErrCode: 500, ErrMsg: "encode json result fail. err:" + err.Error(), ErrType: motan.ServiceException})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// writeGatewayError responds the exception in json, the status is the error code if it is a http error status
+func writeGatewayError(w http.ResponseWriter, ex *motan.Exception) {
+	status := ex.ErrCode
+	if status < 400 || status > 599 {
+		status = http.StatusInternalServerError
+	}
+	b, _ := json.Marshal(&JSONResult{Exception: ex})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// parseHeaderMapping parses the comma separated header:attachment
+func parseHeaderMapping(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, kv := range motan.TrimSplit(s, ",") {
+		if i := strings.Index(kv, ":"); i > 0 && i < len(kv)-1 {
+			headers[strings.TrimSpace(kv[:i])] = strings.TrimSpace(kv[i+1:])
+		}
+	}
+	return headers
+}
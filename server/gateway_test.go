@@ -0,0 +1,127 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/provider"
+	"github.com/weibocom/motan-go/serialize"
+)
+
+type attachmentRecorder struct {
+	*DefaultMessageHandler
+	attachments map[string]string
+}
+
+func (h *attachmentRecorder) Call(request motan.Request) motan.Response {
+	h.attachments = request.GetAttachments().RawMap()
+	return h.DefaultMessageHandler.Call(request)
+}
+
+func TestGatewayServer(t *testing.T) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	serialize.RegistDefaultSerializations(ext)
+	p := &provider.DefaultProvider{}
+	p.SetURL(&motan.URL{Path: "echoService"})
+	p.SetService(&echoService{})
+	p.Initialize()
+	handler := &DefaultMessageHandler{}
+	handler.Initialize()
+	handler.AddProvider(p)
+	recorder := &attachmentRecorder{DefaultMessageHandler: handler}
+	server := &GatewayServer{URL: &motan.URL{Port: 64548}, Config: &GatewayConfig{
+		Headers: "X-Trace-Id:traceId",
+		Routes: []*GatewayRoute{
+			{Path: "/echo", Service: "echoService", Method: "echo", HTTPMethods: "GET", Args: "s", Headers: "X-User:user"},
+			{Path: "/api/echo/*", Service: "echoService"},
+		},
+	}}
+	if err := server.Open(false, false, recorder, ext); err != nil {
+		t.Fatalf("open gateway server fail. err:%v", err)
+	}
+	defer server.Destroy()
+	time.Sleep(20 * time.Millisecond)
+	base := "http://127.0.0.1:64548"
+
+	call := func(method, path, contentType, accept, body string, headers map[string]string) (int, string, string) {
+		req, _ := http.NewRequest(method, base+path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("call gateway fail. err:%v", err)
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, res.Header.Get("Content-Type"), string(b)
+	}
+
+	// query args by the route, with the header mapping
+	status, _, body := call("GET", "/echo?s="+url.QueryEscape("hello gateway"), "", "", "", map[string]string{"X-Trace-Id": "t1", "X-User": "u1"})
+	if status != http.StatusOK || body != `"hello gateway"` {
+		t.Errorf("wrong query call. status:%d, body:%s", status, body)
+	}
+	if recorder.attachments["traceId"] != "t1" || recorder.attachments["user"] != "u1" {
+		t.Errorf("headers should be mapped to the attachments. attachments:%v", recorder.attachments)
+	}
+	if status, _, _ = call("POST", "/echo", "", "", "", nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("http method not allowed should be 405. status:%d", status)
+	}
+
+	// json body by the prefix route
+	status, _, body = call("POST", "/api/echo/echo", "application/json", "", `["hello json"]`, nil)
+	if status != http.StatusOK || body != `"hello json"` {
+		t.Errorf("wrong json call. status:%d, body:%s", status, body)
+	}
+	if status, _, body = call("POST", "/api/echo/echo", "application/json", "", `[`, nil); status != http.StatusBadRequest {
+		t.Errorf("bad json body should be 400. status:%d, body:%s", status, body)
+	}
+	large := `["` + strings.Repeat("a", gatewayMaxBodySize) + `"]`
+	if status, _, body = call("POST", "/api/echo/echo", "application/json", "", large, nil); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("body too large should be 413. status:%d, body:%.100s", status, body)
+	}
+	if status, _, _ = call("POST", "/api/echo/echo", "application/xml", "", "<s/>", nil); status != http.StatusUnsupportedMediaType {
+		t.Errorf("unsupported content type should be 415. status:%d", status)
+	}
+
+	// content negotiation
+	status, contentType, body := call("POST", "/api/echo/echo", "application/json", "text/plain", `"hello text"`, nil)
+	if status != http.StatusOK || body != "hello text" || !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("wrong text call. status:%d, content type:%s, body:%s", status, contentType, body)
+	}
+	if status, _, _ = call("POST", "/api/echo/echo", "application/json", "application/xml", `"hello"`, nil); status != http.StatusNotAcceptable {
+		t.Errorf("not acceptable should be 406. status:%d", status)
+	}
+
+	if status, _, _ = call("GET", "/unknown", "", "", "", nil); status != http.StatusNotFound {
+		t.Errorf("no route should be 404. status:%d", status)
+	}
+	if status, _, body = call("POST", "/api/echo/unknown", "application/json", "", `["hello"]`, nil); status != http.StatusNotFound {
+		t.Errorf("unknown method should be 404. status:%d, body:%s", status, body)
+	}
+}
+
+func TestGatewayMatchWithoutRoutes(t *testing.T) {
+	g := &GatewayServer{Config: &GatewayConfig{}}
+	if r, method := g.match("/com.weibo.EchoService/echo"); r == nil || r.Service != "com.weibo.EchoService" || method != "echo" {
+		t.Errorf("path should be mapped to the service and method. route:%+v, method:%s", r, method)
+	}
+	for _, path := range []string{"/", "/echo", "/com.weibo.EchoService/"} {
+		if r, _ := g.match(path); r != nil {
+			t.Errorf("path should not match. path:%s", path)
+		}
+	}
+}
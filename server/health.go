@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+const (
+	// HealthService is the service name of the built-in health check answered by every motan server,
+	// the response value is the json of HealthStatus
+	HealthService = "motan.HealthService"
+	HealthMethod  = "check"
+)
+
+var (
+	// HealthCheckTimeout is the max time to wait for a dependency check
+	HealthCheckTimeout = 3 * time.Second
+
+	healthChecks     = make(map[string]func() error)
+	healthChecksLock sync.RWMutex
+
+	errHealthCheckPanic   = errors.New("health check panic")
+	errHealthCheckTimeout = errors.New("health check timeout")
+)
+
+// HealthStatus is the health of the process. Live is true while the process can respond,
+// Ready is true if all exported services are registered and available, and all dependency checks pass
+type HealthStatus struct {
+	Live     bool            `json:"live"`
+	Ready    bool            `json:"ready"`
+	Services []ServiceHealth `json:"services"`
+	Checks   []CheckResult   `json:"checks"`
+}
+
+// ServiceHealth is the state of an exported service
+type ServiceHealth struct {
+	Service    string   `json:"service"`
+	Group      string   `json:"group"`
+	Protocol   string   `json:"protocol"`
+	Port       int      `json:"port"`
+	Available  bool     `json:"available"`
+	Registered bool     `json:"registered"`
+	Registries []string `json:"registries,omitempty"`
+}
+
+// CheckResult is the result of a dependency check
+type CheckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// RegisterHealthCheck registers a dependency check such as the connection of a database, the process is not ready
+// while the check returns an error. the check with the same name is replaced
+func RegisterHealthCheck(name string, check func() error) {
+	healthChecksLock.Lock()
+	defer healthChecksLock.Unlock()
+	healthChecks[name] = check
+}
+
+// UnregisterHealthCheck removes the dependency check
+func UnregisterHealthCheck(name string) {
+	healthChecksLock.Lock()
+	defer healthChecksLock.Unlock()
+	delete(healthChecks, name)
+}
+
+// CheckHealth reports the state of the exported services and runs the dependency checks
+func CheckHealth() *HealthStatus {
+	status := &HealthStatus{Live: true, Ready: true, Services: []ServiceHealth{}}
+	exporters.Range(func(k, _ interface{}) bool {
+		s := k.(*DefaultExporter).health()
+		status.Ready = status.Ready && s.Available && s.Registered
+		status.Services = append(status.Services, s)
+		return true
+	})
+	sort.Slice(status.Services, func(i, j int) bool {
+		a, b := status.Services[i], status.Services[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Port < b.Port
+	})
+	status.Checks = runHealthChecks()
+	for _, c := range status.Checks {
+		status.Ready = status.Ready && c.OK
+	}
+	return status
+}
+
+// runHealthChecks runs the checks concurrently, a check not finished in HealthCheckTimeout fails
+func runHealthChecks() []CheckResult {
+	healthChecksLock.RLock()
+	names := make([]string, 0, len(healthChecks))
+	checks := make([]func() error, 0, len(healthChecks))
+	for name := range healthChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checks = append(checks, healthChecks[name])
+	}
+	healthChecksLock.RUnlock()
+
+	results := make([]CheckResult, len(names))
+	done := make([]chan error, len(names))
+	for i, check := range checks {
+		done[i] = make(chan error, 1)
+		go func(check func() error, ch chan error) {
+			defer motan.HandlePanic(func() {
+				ch <- errHealthCheckPanic
+			})
+			ch <- check()
+		}(check, done[i])
+	}
+	timer := time.NewTimer(HealthCheckTimeout)
+	defer timer.Stop()
+	expired := false
+	for i, name := range names {
+		results[i].Name = name
+		var err error
+		if expired {
+			// the remaining checks are not waited
+			select {
+			case err = <-done[i]:
+			default:
+				err = errHealthCheckTimeout
+			}
+		} else {
+			select {
+			case err = <-done[i]:
+			case <-timer.C:
+				expired = true
+				err = errHealthCheckTimeout
+			}
+		}
+		results[i].OK = err == nil
+		if err != nil {
+			results[i].Error = err.Error()
+			vlog.Warningf("health check %s fail. err:%v\n", name, err)
+		}
+	}
+	return results
+}
+
+// callHandler answers the requests of the built-in services, and calls the handler for the others
+func callHandler(handler motan.MessageHandler, request motan.Request) motan.Response {
+	switch request.GetServiceName() {
+	case HealthService:
+		return healthResponse(request)
+	case MetaService:
+		return metaResponse(request)
+	}
+	return handler.Call(request)
+}
+
+func healthResponse(request motan.Request) motan.Response {
+	data, err := json.Marshal(CheckHealth())
+	if err != nil {
+		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "health check fail. err:" + err.Error(), ErrType: motan.ServiceException})
+	}
+	return &motan.MotanResponse{RequestID: request.GetRequestID(), Value: string(data)}
+}
+
+func (d *DefaultExporter) health() ServiceHealth {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	s := ServiceHealth{
+		Service:    d.url.Path,
+		Group:      d.url.Group,
+		Protocol:   d.url.Protocol,
+		Port:       d.url.Port,
+		Available:  d.IsAvailable(),
+		Registered: d.registered,
+	}
+	if d.registered {
+		for _, r := range d.Registries {
+			s.Registries = append(s.Registries, r.GetURL().GetIdentity())
+		}
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func TestCheckHealth(t *testing.T) {
+	oldTimeout := HealthCheckTimeout
+	HealthCheckTimeout = 50 * time.Millisecond
+	defer func() { HealthCheckTimeout = oldTimeout }()
+
+	exporter, _, export := newTestExporter(map[string]string{motan.LazyExportKey: "true"})
+	if err := export(); err != nil {
+		t.Fatalf("export fail. err:%v", err)
+	}
+	defer exporter.Unexport()
+	status := CheckHealth()
+	if !status.Live || status.Ready || len(status.Services) != 1 || status.Services[0].Registered {
+		t.Errorf("lazy exported service should not be ready. status:%+v", status)
+	}
+
+	exporter.Ready()
+	exporter.Available()
+	exporter.checkProvider()
+	status = CheckHealth()
+	if !status.Ready || !status.Services[0].Available || len(status.Services[0].Registries) != 1 {
+		t.Errorf("available service should be ready. status:%+v", status)
+	}
+
+	RegisterHealthCheck("db", func() error { return nil })
+	RegisterHealthCheck("cache", func() error { return errors.New("connection refused") })
+	RegisterHealthCheck("slow", func() error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	RegisterHealthCheck("panic", func() error { panic("check panic") })
+	defer func() {
+		for _, name := range []string{"db", "cache", "slow", "panic"} {
+			UnregisterHealthCheck(name)
+		}
+	}()
+	start := time.Now()
+	status = CheckHealth()
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("slow check should time out")
+	}
+	expect := map[string]string{"cache": "connection refused", "db": "", "panic": errHealthCheckPanic.Error(), "slow": errHealthCheckTimeout.Error()}
+	if status.Ready || len(status.Checks) != len(expect) {
+		t.Fatalf("failed checks should make the process not ready. status:%+v", status)
+	}
+	for _, c := range status.Checks {
+		if c.Error != expect[c.Name] || c.OK != (c.Error == "") {
+			t.Errorf("wrong check result. result:%+v", c)
+		}
+	}
+
+	UnregisterHealthCheck("cache")
+	UnregisterHealthCheck("slow")
+	UnregisterHealthCheck("panic")
+	request := &motan.MotanRequest{RequestID: 1, ServiceName: HealthService, Method: HealthMethod}
+	res := callHandler(nil, request)
+	if res.GetException() != nil || res.GetRequestID() != 1 {
+		t.Fatalf("wrong health check response. res:%+v", res)
+	}
+	status = &HealthStatus{}
+	if err := json.Unmarshal([]byte(res.GetValue().(string)), status); err != nil {
+		t.Fatalf("unmarshal health status fail. err:%v", err)
+	}
+	if !status.Ready || len(status.Services) != 1 || len(status.Checks) != 1 {
+		t.Errorf("wrong health status. status:%+v", status)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"sort"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+// MetaService is the service name of the built-in introspection answered by every motan server. the response value is
+// the json of []ServiceMeta, which can be filtered by the service name as the optional argument
+const MetaService = "$meta"
+
+// ServiceMeta describes an exported service and its exposed methods, Methods is empty if the provider can not
+// describe its methods. Serialization is empty if the service is not bound to a serialization
+type ServiceMeta struct {
+	Service       string                    `json:"service"`
+	Group         string                    `json:"group"`
+	Protocol      string                    `json:"protocol"`
+	Port          int                       `json:"port"`
+	Serialization string                    `json:"serialization,omitempty"`
+	Methods       []*motan.MethodDescriptor `json:"methods"`
+}
+
+// DescribeServices describes the exported services sorted by name and port, all services if service is empty
+func DescribeServices(service string) []ServiceMeta {
+	metas := make([]ServiceMeta, 0, 16)
+	exporters.Range(func(k, _ interface{}) bool {
+		e := k.(*DefaultExporter)
+		url := e.GetURL()
+		if url == nil || (service != "" && url.Path != service) {
+			return true
+		}
+		meta := ServiceMeta{
+			Service:       url.Path,
+			Group:         url.Group,
+			Protocol:      url.Protocol,
+			Port:          url.Port,
+			Serialization: url.GetParam(motan.SerializationKey, ""),
+			Methods:       []*motan.MethodDescriptor{},
+		}
+		if md, ok := e.GetProvider().(motan.MethodDescriber); ok {
+			exposure := NewMethodExposure(url)
+			for _, m := range md.DescribeMethods() {
+				if exposure.IsExposed(m.Name) {
+					meta.Methods = append(meta.Methods, m)
+				}
+			}
+		}
+		metas = append(metas, meta)
+		return true
+	})
+	sort.Slice(metas, func(i, j int) bool {
+		if metas[i].Service != metas[j].Service {
+			return metas[i].Service < metas[j].Service
+		}
+		return metas[i].Port < metas[j].Port
+	})
+	return metas
+}
+
+func metaResponse(request motan.Request) motan.Response {
+	var service string
+	if len(request.GetArguments()) > 0 {
+		if err := request.ProcessDeserializable([]interface{}{&service}); err != nil {
+			return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "deserialize arguments fail." + err.Error(), ErrType: motan.ServiceException})
+		}
+		switch arg := request.GetArguments()[0].(type) {
+		case string:
+			service = arg
+		case *string:
+			service = *arg
+		}
+	}
+	data, err := json.Marshal(DescribeServices(service))
+	if err != nil {
+		return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "describe services fail. err:" + err.Error(), ErrType: motan.ServiceException})
+	}
+	return &motan.MotanResponse{RequestID: request.GetRequestID(), Value: string(data)}
+}
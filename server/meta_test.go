@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func TestDescribeServices(t *testing.T) {
+	exporter, _, export := newTestExporter(map[string]string{motan.SerializationKey: "simple"})
+	if err := export(); err != nil {
+		t.Fatalf("export fail. err:%v", err)
+	}
+	defer exporter.Unexport()
+	exporter.SetProvider(WrapWithFilter(exporter.GetProvider(), &motan.DefaultExtensionFactory{}, nil))
+
+	for _, args := range [][]interface{}{nil, {"test.warmup"}, {"unknown"}} {
+		request := &motan.MotanRequest{RequestID: 1, ServiceName: MetaService, Method: "describe", Arguments: args}
+		res := callHandler(nil, request)
+		if res.GetException() != nil {
+			t.Fatalf("describe services fail. exception:%+v", res.GetException())
+		}
+		var metas []ServiceMeta
+		if err := json.Unmarshal([]byte(res.GetValue().(string)), &metas); err != nil {
+			t.Fatalf("unmarshal service meta fail. err:%v", err)
+		}
+		if len(args) > 0 && args[0] == "unknown" {
+			if len(metas) != 0 {
+				t.Errorf("services should be filtered by name. metas:%+v", metas)
+			}
+			continue
+		}
+		if len(metas) != 1 {
+			t.Fatalf("wrong services. metas:%+v", metas)
+		}
+		m := metas[0]
+		if m.Service != "test.warmup" || m.Port != 64544 || m.Protocol != "motan2" || m.Serialization != "simple" {
+			t.Errorf("wrong service meta. meta:%+v", m)
+		}
+		// WarmUp is not exposed
+		if len(m.Methods) != 1 || m.Methods[0].Name != "Hello" || len(m.Methods[0].Params) != 1 ||
+			m.Methods[0].Params[0] != "string" || m.Methods[0].Result != "string" {
+			t.Errorf("wrong methods. methods:%+v", m.Methods)
+		}
+	}
+}
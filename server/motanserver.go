@@ -2,15 +2,21 @@ package server
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
 	"github.com/weibocom/motan-go/log"
+	"github.com/weibocom/motan-go/metrics"
 	mpro "github.com/weibocom/motan-go/protocol"
+	"github.com/weibocom/motan-go/transport"
 )
 
 type MotanServer struct {
@@ -19,10 +25,48 @@ type MotanServer struct {
 	listener   net.Listener
 	extFactory motan.ExtensionFactory
 	proxy      bool
+	// max frame body size advertised to clients, 0 if chunked requests are not accepted
+	maxFrameSize int
+	// max body size of a request reassembled from the chunks
+	maxMessageSize int
+	// max requests of a batch, and max requests of a batch processed concurrently
+	maxBatchSize     int
+	batchConcurrency int
+
+	closed   int32
+	draining int32
+	conns    sync.Map // net.Conn -> struct{}
+	connWG   sync.WaitGroup
+	// worker pools of the services, nil if the service has no worker pool
+	pools    sync.Map // motan.Provider -> *workerPool
+	overload *overloadProtector
+	recorder *requestRecorder
+
+	maxConns      int
+	maxConnsPerIP int
+	connLock      sync.Mutex
+	connCount     int
+	ipConnCount   map[string]int
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+}
+
+// GracefulServer is a server can be shut down without breaking the requests being processed
+type GracefulServer interface {
+	// Shutdown stops accepting connections and reading requests, then closes the connections after the requests
+	// being processed are finished. the connections are closed anyway when timeout, and an error is returned
+	Shutdown(timeout time.Duration) error
+}
+
+// ControlPusher is a server can push the control messages to the connected clients
+type ControlPusher interface {
+	Push(kind string, payload []byte) (int, error)
 }
 
 func (m *MotanServer) Open(block bool, proxy bool, handler motan.MessageHandler, extFactory motan.ExtensionFactory) error {
-	lis, err := net.Listen("tcp", ":"+strconv.Itoa(int(m.URL.Port)))
+	lis, err := transport.ListenExt(m.URL, extFactory)
 	if err != nil {
 		vlog.Errorf("listen port:%d fail. err: %v\n", m.URL.Port, err)
 		return err
@@ -31,6 +75,20 @@ func (m *MotanServer) Open(block bool, proxy bool, handler motan.MessageHandler,
 	m.handler = handler
 	m.extFactory = extFactory
 	m.proxy = proxy
+	m.initConnOptions()
+	m.maxConns = int(m.URL.GetPositiveIntValue(motan.MaxConnectionsKey, 0))
+	m.maxConnsPerIP = int(m.URL.GetPositiveIntValue(motan.MaxConnectionsPerIPKey, 0))
+	m.ipConnCount = make(map[string]int)
+	if m.overload = newOverloadProtector(m.URL); m.overload != nil {
+		m.overload.start()
+	}
+	if m.recorder, err = newRequestRecorder(m.URL); err != nil {
+		vlog.Errorf("create request recorder fail, requests are not recorded. port:%d, err:%v\n", m.URL.Port, err)
+	}
+	if !proxy {
+		// referers in this process can call the providers by loopback endpoints
+		endpoint.RegistLocalHandler(m.URL.Port, handler)
+	}
 	vlog.Infof("motan server is started. port:%d\n", m.URL.Port)
 	if block {
 		m.run()
@@ -40,6 +98,20 @@ func (m *MotanServer) Open(block bool, proxy bool, handler motan.MessageHandler,
 	return nil
 }
 
+// initConnOptions reads the options of the connections handled by handleConn from the url
+func (m *MotanServer) initConnOptions() {
+	m.maxFrameSize = int(m.URL.GetIntValue(motan.MaxFrameSizeKey, int64(mpro.DefaultMaxFrameSize)))
+	m.maxMessageSize = int(m.URL.GetPositiveIntValue(motan.MaxMessageSizeKey, int64(mpro.DefaultMaxMessageSize)))
+	m.maxBatchSize = int(m.URL.GetPositiveIntValue(motan.MaxBatchSizeKey, int64(mpro.DefaultMaxBatchSize)))
+	m.batchConcurrency = int(m.URL.GetPositiveIntValue(motan.BatchConcurrencyKey, defaultBatchConcurrency))
+	m.readTimeout = m.URL.GetTimeDuration(motan.ReadTimeoutKey, time.Millisecond, 0)
+	m.writeTimeout = m.URL.GetTimeDuration(motan.WriteTimeoutKey, time.Millisecond, motan.DefaultWriteTimeout)
+	if m.writeTimeout <= 0 {
+		m.writeTimeout = motan.DefaultWriteTimeout
+	}
+	m.idleTimeout = m.URL.GetTimeDuration(motan.IdleTimeoutKey, time.Millisecond, 0)
+}
+
 func (m *MotanServer) GetMessageHandler() motan.MessageHandler {
 	return m.handler
 }
@@ -61,6 +133,16 @@ func (m *MotanServer) GetName() string {
 }
 
 func (m *MotanServer) Destroy() {
+	if !atomic.CompareAndSwapInt32(&m.closed, 0, 1) {
+		return
+	}
+	if m.overload != nil {
+		m.overload.destroy()
+	}
+	if m.recorder != nil {
+		m.recorder.destroy()
+	}
+	endpoint.UnregistLocalHandler(m.URL.Port, m.handler)
 	err := m.listener.Close()
 	if err != nil {
 		vlog.Errorf("motan server destroy fail.url %v, err :%s\n", m.URL, err.Error())
@@ -69,80 +151,515 @@ func (m *MotanServer) Destroy() {
 	}
 }
 
+func (m *MotanServer) Shutdown(timeout time.Duration) error {
+	// the clients stop sending requests and reconnect after their requests finished
+	m.Push(motan.ControlDrain, nil)
+	atomic.StoreInt32(&m.draining, 1)
+	m.Destroy()
+	// the connections stop reading and close after the requests being processed are finished
+	m.conns.Range(func(k, v interface{}) bool {
+		k.(net.Conn).SetReadDeadline(time.Now())
+		return true
+	})
+	done := make(chan struct{})
+	go func() {
+		m.connWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		vlog.Infof("motan server shutdown. port:%d\n", m.URL.Port)
+		return nil
+	case <-time.After(timeout):
+		m.conns.Range(func(k, v interface{}) bool {
+			k.(net.Conn).Close()
+			return true
+		})
+		return errors.New("motan server shutdown timeout, connections are closed with requests being processed")
+	}
+}
+
+// Push sends the control message to all connected clients, the number of the clients sent is returned.
+// the clients receive the message by the control listeners, see endpoint.AddControlListener
+func (m *MotanServer) Push(kind string, payload []byte) (int, error) {
+	if kind == "" {
+		return 0, errors.New("control message without kind")
+	}
+	if len(payload) > mpro.MaxControlPayloadSize {
+		return 0, errors.New("control message payload too large")
+	}
+	buf := mpro.BuildControlMessage(kind, payload).Encode()
+	defer motan.ReleaseBytesBuffer(buf)
+	sent := 0
+	m.conns.Range(func(k, v interface{}) bool {
+		conn := k.(net.Conn)
+		conn.SetWriteDeadline(time.Now().Add(m.writeTimeout))
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			vlog.Warningw("push control message fail", vlog.String("kind", kind), vlog.String("conn", conn.RemoteAddr().String()), vlog.Err(err))
+			return true
+		}
+		sent++
+		return true
+	})
+	vlog.Infow("push control message", vlog.String("kind", kind), vlog.Int("clients", sent))
+	return sent, nil
+}
+
 func (m *MotanServer) run() {
+	var delay time.Duration
 	for {
 		conn, err := m.listener.Accept()
 		if err != nil {
-			vlog.Errorf("motan server accept from port %v fail. err:%s\n", m.listener.Addr(), err.Error())
-		} else {
+			if atomic.LoadInt32(&m.closed) == 1 {
+				return
+			}
+			// back off to avoid busy loop when accept fails continually, such as too many open files
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay *= 2; delay > time.Second {
+				delay = time.Second
+			}
+			vlog.Errorf("motan server accept from port %v fail. retry in %v, err:%s\n", m.listener.Addr(), delay, err.Error())
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+		ip, ok := m.acquireConn(conn)
+		if !ok {
+			conn.Close()
+			continue
+		}
+		m.connWG.Add(1)
+		go func() {
+			defer m.connWG.Done()
+			defer m.releaseConn(ip)
+			m.handleConn(conn)
+		}()
+	}
+}
 
-			go m.handleConn(conn)
+// acquireConn counts the new connection, false if it exceeds the connection limits of the server.
+// the ip is empty for the connections not from tcp, they are not limited by the client ip
+func (m *MotanServer) acquireConn(conn net.Conn) (ip string, ok bool) {
+	if ta, isTCP := conn.RemoteAddr().(*net.TCPAddr); isTCP {
+		ip = ta.IP.String()
+	}
+	m.connLock.Lock()
+	defer m.connLock.Unlock()
+	var reason string
+	if m.maxConns > 0 && m.connCount >= m.maxConns {
+		reason = "max_connections"
+	} else if ip != "" && m.maxConnsPerIP > 0 && m.ipConnCount[ip] >= m.maxConnsPerIP {
+		reason = "max_connections_per_ip"
+	}
+	if reason != "" {
+		vlog.Warningf("motan server reject connection by %s. port:%d, remote:%s, connections:%d, ip connections:%d\n", reason, m.URL.Port, conn.RemoteAddr().String(), m.connCount, m.ipConnCount[ip])
+		metrics.AddCounter(m.URL.Group, m.URL.Path, m.connMetricKey()+"."+reason+"_reject_count", 1)
+		return ip, false
+	}
+	m.connCount++
+	if ip != "" {
+		m.ipConnCount[ip]++
+	}
+	return ip, true
+}
+
+func (m *MotanServer) releaseConn(ip string) {
+	m.connLock.Lock()
+	defer m.connLock.Unlock()
+	m.connCount--
+	if ip != "" {
+		if m.ipConnCount[ip]--; m.ipConnCount[ip] <= 0 {
+			delete(m.ipConnCount, ip)
+		}
+	}
+}
+
+func (m *MotanServer) connMetricKey() string {
+	return "motan-server-connection:" + m.URL.GetPortStr()
+}
+
+var errIdleTimeout = errors.New("idle timeout")
+
+// connTasks counts the requests and streams being processed of a connection
+type connTasks struct {
+	wg    sync.WaitGroup
+	count int32
+}
+
+func (c *connTasks) add() {
+	c.wg.Add(1)
+	atomic.AddInt32(&c.count, 1)
+}
+
+func (c *connTasks) done() {
+	atomic.AddInt32(&c.count, -1)
+	c.wg.Done()
+}
+
+func (c *connTasks) wait() {
+	c.wg.Wait()
+}
+
+// waitFrame waits until the next frame arrives, errIdleTimeout is returned if nothing arrives within the idle timeout
+// while no request is being processed. the frame must be read within the read timeout after it arrives.
+func (m *MotanServer) waitFrame(conn net.Conn, buf *bufio.Reader, pending *connTasks) error {
+	if m.readTimeout <= 0 && m.idleTimeout <= 0 {
+		return nil
+	}
+	for {
+		m.setReadDeadline(conn, m.idleTimeout)
+		_, err := buf.Peek(1)
+		if err == nil {
+			break
+		}
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() || atomic.LoadInt32(&m.draining) == 1 {
+			return err
+		}
+		// the client is waiting for the responses
+		if atomic.LoadInt32(&pending.count) == 0 {
+			return errIdleTimeout
 		}
 	}
+	m.setReadDeadline(conn, m.readTimeout)
+	return nil
+}
+
+// setReadDeadline sets the read deadline after the timeout, or no deadline if the timeout is 0.
+// the connection stops reading at once if the server is shutting down
+func (m *MotanServer) setReadDeadline(conn net.Conn, timeout time.Duration) {
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+	} else {
+		conn.SetReadDeadline(time.Time{})
+	}
+	if atomic.LoadInt32(&m.draining) == 1 {
+		conn.SetReadDeadline(time.Now())
+	}
 }
 
 func (m *MotanServer) handleConn(conn net.Conn) {
 	defer conn.Close()
 	defer motan.HandlePanic(nil)
+	accepted := time.Now()
+	m.conns.Store(conn, struct{}{})
+	defer m.conns.Delete(conn)
+	if atomic.LoadInt32(&m.draining) == 1 {
+		conn.SetReadDeadline(time.Now())
+	}
 	buf := bufio.NewReader(conn)
 
 	var ip string
 	if ta, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
 		ip = ta.IP.String()
+	} else if _, ok := conn.RemoteAddr().(*net.UnixAddr); ok {
+		// unix socket clients are always on the same host
+		ip = "127.0.0.1"
 	} else {
 		ip = getRemoteIP(conn.RemoteAddr().String())
 	}
 
+	streams := newServerStreams()
+	defer streams.closeAll()
+	calls := newServerCalls()
+	defer calls.cancelAll()
+	// the requests being processed are finished before closing when the server is shutting down
+	pending := &connTasks{}
+	defer func() {
+		if atomic.LoadInt32(&m.draining) == 1 {
+			pending.wait()
+		}
+	}()
+	assembler := mpro.NewChunkAssembler(mpro.ChunkLimits{MaxFrameSize: m.maxFrameSize, MaxMessageSize: m.maxMessageSize})
 	for {
+		if err := m.waitFrame(conn, buf, pending); err != nil {
+			if err == errIdleTimeout {
+				vlog.Infof("close idle connection. conn:%s, idle timeout:%v\n", conn.RemoteAddr().String(), m.idleTimeout)
+			} else if atomic.LoadInt32(&m.draining) == 0 && err.Error() != "EOF" {
+				vlog.Warningw("read motan message fail", vlog.String("conn", conn.RemoteAddr().String()), vlog.Err(err))
+			}
+			break
+		}
 		request, t, err := mpro.DecodeWithTime(buf)
 		if err != nil {
+			if atomic.LoadInt32(&m.draining) == 1 {
+				break
+			}
 			if err.Error() != "EOF" {
-				vlog.Warningf("decode motan message fail! con:%s, err:%s\n.", conn.RemoteAddr().String(), err.Error())
+				vlog.Warningw("decode motan message fail", vlog.String("conn", conn.RemoteAddr().String()), vlog.Err(err))
 			}
 			break
 		}
+		if request, err = assembler.Add(request); err != nil {
+			vlog.Warningw("assemble chunked message fail", vlog.String("conn", conn.RemoteAddr().String()), vlog.Err(err))
+			break
+		}
+		if request == nil {
+			// more chunks to come
+			continue
+		}
+		if request.IsCancel() {
+			if !calls.cancel(request.Header.RequestID) && !streams.cancel(request.Header.RequestID) {
+				vlog.Infow("cancel frame of finished request", vlog.Uint64("rid", request.Header.RequestID), vlog.String("remote", ip))
+			}
+			continue
+		}
+		switch request.GetStreamFrame() {
+		case mpro.StreamData, mpro.StreamEnd:
+			// frames must be dispatched in order, so never process them asynchronously
+			streams.dispatch(request)
+			continue
+		case mpro.StreamOpen:
+			request.Metadata.Store(motan.HostKey, ip)
+			stream := streams.open(request, conn, m.writeTimeout, m.extFactory)
+			pending.add()
+			go func() {
+				defer pending.done()
+				m.processStream(request, stream, streams)
+			}()
+			continue
+		}
 
+		if m.recorder != nil && !request.Header.IsHeartbeat() && m.recorder.sample() {
+			m.recorder.record(request, t)
+		}
 		request.Metadata.Store(motan.HostKey, ip)
+		decoded := time.Now()
 		var trace *motan.TraceContext
 		if !request.Header.IsHeartbeat() {
 			trace = motan.TracePolicy(request.Header.RequestID, request.Metadata)
 			if trace != nil {
 				trace.Addr = ip
+				trace.Values[motan.TraceServiceKey] = request.Metadata.LoadOrEmpty(mpro.MPath)
+				trace.Values[motan.TraceMethodKey] = request.Metadata.LoadOrEmpty(mpro.MMethod)
+				trace.Values[motan.TraceGroupKey] = request.Metadata.LoadOrEmpty(mpro.MGroup)
+				trace.PutReqSpan(&motan.Span{Name: motan.Accept, Time: accepted})
 				trace.PutReqSpan(&motan.Span{Name: motan.Receive, Time: t})
-				trace.PutReqSpan(&motan.Span{Name: motan.Decode, Time: time.Now()})
+				trace.PutReqSpan(&motan.Span{Name: motan.Decode, Time: decoded})
 			}
 		}
-		go m.processReq(request, trace, conn)
+		ctx, done := calls.start(request.Header.RequestID)
+		pending.add()
+		if m.overload != nil && !request.Header.IsHeartbeat() && m.overload.reject() {
+			m.rejectReq(done, request, conn, motan.ErrServerOverloaded)
+			pending.done()
+			continue
+		}
+		process := func() {
+			defer pending.done()
+			m.processReq(ctx, done, request, t, decoded, trace, conn)
+		}
+		pool := m.getWorkerPool(request)
+		if pool == nil {
+			go process()
+			continue
+		}
+		reject := func() {
+			defer pending.done()
+			m.rejectReq(done, request, conn, motan.ErrServerBusy)
+		}
+		if !pool.submit(process, reject) {
+			reject()
+		}
+	}
+}
+
+// getWorkerPool returns the worker pool of the requested service, nil if the requests are processed in new goroutines.
+// batch requests may call multiple services, and streams may last long, so they never use worker pools.
+func (m *MotanServer) getWorkerPool(request *mpro.Message) *workerPool {
+	if request.IsBatch() || request.Header.IsHeartbeat() {
+		return nil
+	}
+	p := m.handler.GetProvider(request.Metadata.LoadOrEmpty(mpro.MPath))
+	if p == nil || p.GetURL() == nil {
+		return nil
 	}
+	pool, ok := m.pools.Load(p)
+	if !ok {
+		pool, _ = m.pools.LoadOrStore(p, newWorkerPool(p.GetURL()))
+	}
+	return pool.(*workerPool)
 }
 
-func (m *MotanServer) processReq(request *mpro.Message, tc *motan.TraceContext, conn net.Conn) {
+// rejectReq responds the request rejected by the worker pool or the overload protection
+func (m *MotanServer) rejectReq(done func(), request *mpro.Message, conn net.Conn, reason error) {
+	defer done()
+	vlog.Warningw("motan server reject request", vlog.Uint64("rid", request.Header.RequestID), vlog.String("service", request.Metadata.LoadOrEmpty(mpro.MPath)),
+		vlog.String("method", request.Metadata.LoadOrEmpty(mpro.MMethod)), vlog.String("reason", reason.Error()))
+	if request.Header.IsOneWay() {
+		return
+	}
+	// the clients back off the server by the throttle hints instead of retrying at once
+	e := motan.NewServerBusyException(reason.Error())
+	retryAfter := busyRetryAfter
+	if reason == motan.ErrServerOverloaded {
+		e = motan.NewOverloadException(reason.Error())
+		retryAfter = overloadCheckInterval
+	}
+	res := mpro.BuildExceptionResponse(request.Header.RequestID, mpro.ExceptionToJSON(e))
+	res.Metadata.Store(motan.ThrottleRetryAfterKey, strconv.FormatInt(int64(retryAfter/time.Millisecond), 10))
+	res.Metadata.Store(motan.ThrottleRemainingKey, "0")
+	buf := res.Encode()
+	defer motan.ReleaseBytesBuffer(buf)
+	conn.SetWriteDeadline(time.Now().Add(m.writeTimeout))
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		vlog.Errorw("connection will close", vlog.String("conn", conn.RemoteAddr().String()), vlog.Err(err))
+		conn.Close()
+	}
+}
+
+// processReq handles the request and writes the response. ctx is canceled by the cancel frame of the request,
+// and done must be called when the request finished. the time the request waited after decoded and the total
+// process time are set in the response metadata.
+func (m *MotanServer) processReq(ctx context.Context, done func(), request *mpro.Message, received time.Time, decoded time.Time, tc *motan.TraceContext, conn net.Conn) {
 	defer motan.HandlePanic(nil)
+	defer done()
+	if tc != nil {
+		defer tc.Finish()
+	} else {
+		// the metadata of the pooled request message is not used after the response written
+		defer motan.ReleaseStringMap(request.Metadata)
+	}
+	dequeued := time.Now()
+	queueTime := dequeued.Sub(decoded)
+	if m.overload != nil {
+		m.overload.recordLatency(queueTime)
+	}
+	if tc != nil {
+		tc.PutReqSpan(&motan.Span{Name: motan.Dequeue, Time: dequeued})
+	}
+	lastRequestID := request.Header.RequestID
+	peerMaxFrameSize := request.GetMaxFrameSize()
+	var res *mpro.Message
+	if request.IsBatch() {
+		res = m.processBatch(ctx, request, received)
+	} else {
+		res = m.handleMessage(ctx, request, received, tc)
+	}
+	// the client never waits for the response of oneway request
+	if res == nil {
+		return
+	}
+	// the body serialized into pooled buffer is released after all frames are written
+	defer res.ReleaseBody()
+	if !res.Header.IsHeartbeat() {
+		res.Metadata.Store(mpro.MQueueTime, strconv.FormatInt(int64(queueTime/time.Microsecond), 10))
+		res.Metadata.Store(mpro.MProcessTime, strconv.FormatInt(int64(time.Since(received)/time.Millisecond), 10))
+		res.SetAcceptCancel()
+	}
+	// the client has given up the request
+	if ctx.Err() != nil {
+		return
+	}
+	// recover the communication identifier
+	res.Header.RequestID = lastRequestID
+	if peerMaxFrameSize > 0 && m.maxFrameSize > 0 {
+		res.SetMaxFrameSize(m.maxFrameSize)
+	}
+	// large responses are sent in chunks if the client supports
+	for i, frame := range mpro.SplitChunks(res, peerMaxFrameSize) {
+		resBuf := frame.Encode()
+		if i == 0 && tc != nil {
+			tc.PutResSpan(&motan.Span{Name: motan.Encode, Time: time.Now()})
+		}
+		conn.SetWriteDeadline(time.Now().Add(m.writeTimeout))
+		_, err := conn.Write(resBuf.Bytes())
+		motan.ReleaseBytesBuffer(resBuf)
+		if err != nil {
+			vlog.Errorw("connection will close", vlog.String("conn", conn.RemoteAddr().String()), vlog.Err(err))
+			conn.Close()
+			return
+		}
+	}
+	if tc != nil {
+		tc.PutResSpan(&motan.Span{Name: motan.Send, Time: time.Now()})
+	}
+}
+
+// handleMessage calls the handler with the request message and returns the response message, nil for oneway request.
+// requests are rejected without calling the handler if the deadline of the caller has passed.
+func (m *MotanServer) handleMessage(ctx context.Context, request *mpro.Message, received time.Time, tc *motan.TraceContext) *mpro.Message {
 	request.Header.SetProxy(m.proxy)
 	// TODO request , response reuse
 	var res *mpro.Message
-	lastRequestID := request.Header.RequestID
+	var handlerTime time.Duration
+	deadline, hasDeadline := request.GetDeadline(received)
 	if request.Header.IsHeartbeat() {
 		res = mpro.BuildHeartbeat(request.Header.RequestID, mpro.Res)
+	} else if hasDeadline && !time.Now().Before(deadline) {
+		vlog.Warningw("motan server reject expired request", vlog.Uint64("rid", request.Header.RequestID), vlog.String("service", request.Metadata.LoadOrEmpty(mpro.MPath)),
+			vlog.String("method", request.Metadata.LoadOrEmpty(mpro.MMethod)))
+		res = mpro.BuildExceptionResponse(request.Header.RequestID, mpro.ExceptionToJSON(motan.NewServerTimeoutException(motan.ErrDeadlineExceeded.Error())))
 	} else {
 		var mres motan.Response
+		// compressors the client can decompress, empty if the client does not negotiate
+		accepted := request.Metadata.LoadOrEmpty(mpro.MAcceptCompress)
+		request.Metadata.Delete(mpro.MAcceptCompress)
 		serialization := m.extFactory.GetSerialization("", request.Header.GetSerialize())
-		req, err := mpro.ConvertToRequest(request, serialization)
+		var req motan.Request
+		err := mpro.DecompressMessage(request, m.extFactory)
+		if err == nil {
+			req, err = mpro.ConvertToRequest(request, serialization)
+		}
 		if err != nil {
-			vlog.Errorf("motan server convert to motan request fail. rid :%d, service: %s, method:%s,err:%s\n", request.Header.RequestID, request.Metadata.LoadOrEmpty(mpro.MPath), request.Metadata.LoadOrEmpty(mpro.MMethod), err.Error())
-			res = mpro.BuildExceptionResponse(request.Header.RequestID, mpro.ExceptionToJSON(&motan.Exception{ErrCode: 500, ErrMsg: "deserialize fail. err:" + err.Error() + " method:" + request.Metadata.LoadOrEmpty(mpro.MMethod), ErrType: motan.ServiceException}))
+			vlog.Errorw("motan server convert to motan request fail", vlog.Uint64("rid", request.Header.RequestID), vlog.String("service", request.Metadata.LoadOrEmpty(mpro.MPath)),
+				vlog.String("method", request.Metadata.LoadOrEmpty(mpro.MMethod)), vlog.Err(err))
+			res = mpro.BuildExceptionResponse(request.Header.RequestID, mpro.ExceptionToJSON(motan.NewSerializationException("deserialize fail. err:"+err.Error()+" method:"+request.Metadata.LoadOrEmpty(mpro.MMethod))))
 		} else {
+			// the pooled request and response are released after the response message built, the handler
+			// must not use them after returned
+			defer motan.ReleaseMotanRequest(req)
 			req.GetRPCContext(true).ExtFactory = m.extFactory
+			// the context is done when the call finished, canceled by the client or the deadline passed,
+			// downstream calls in the handler use the remaining time
+			var cancel context.CancelFunc
+			if hasDeadline {
+				req.GetRPCContext(true).Deadline = deadline
+				ctx, cancel = context.WithDeadline(ctx, deadline)
+			} else {
+				ctx, cancel = context.WithCancel(ctx)
+			}
+			defer cancel()
+			// the baggage of the request is propagated to the calls made by the handler with the context
+			ctx = motan.WithBaggage(ctx, motan.ExtractBaggage(req))
+			// the correlation id of the client or a new one is shared by the calls made by the handler
+			cid := motan.EnsureCorrelationID(ctx, req)
+			ctx = motan.WithCorrelationID(ctx, cid)
+			req.GetRPCContext(true).Context = ctx
 			if tc != nil {
 				tc.PutReqSpan(&motan.Span{Name: motan.Convert, Time: time.Now()})
 				req.GetRPCContext(true).Tc = tc
 			}
 
-			mres = m.handler.Call(req)
-			//TODO oneway
+			handlerStart := time.Now()
+			if tc != nil {
+				tc.PutReqSpan(&motan.Span{Name: motan.HandlerStart, Time: handlerStart})
+			}
+			mres = callHandler(m.handler, req)
+			defer motan.ReleaseMotanResponse(mres)
+			motan.SetExceptionCorrelationID(mres, cid)
+			handlerEnd := time.Now()
+			if tc != nil {
+				tc.PutResSpan(&motan.Span{Name: motan.HandlerEnd, Time: handlerEnd})
+			}
+			if request.Header.IsOneWay() {
+				return nil
+			}
+			handlerTime = handlerEnd.Sub(handlerStart)
 			if mres != nil {
 				mres.GetRPCContext(true).Proxy = m.proxy
 				res, err = mpro.ConvertToResMessage(mres, serialization)
+				if rc := mres.GetRPCContext(true); err == nil && rc.Compress != "" {
+					if accepted == "" {
+						// the client only knows gzip
+						if !res.Header.IsGzip() {
+							mpro.EncodeMessageGzip(res, rc.GzipSize)
+						}
+					} else {
+						res.Metadata.Store(mpro.MAcceptCompress, rc.Compress)
+						mpro.CompressMessage(res, m.extFactory, rc.Compress, accepted, rc.GzipSize)
+					}
+				}
 				if tc != nil {
 					tc.PutResSpan(&motan.Span{Name: motan.Convert, Time: time.Now()})
 				}
@@ -151,26 +668,17 @@ func (m *MotanServer) processReq(request *mpro.Message, tc *motan.TraceContext,
 			}
 
 			if err != nil {
-				res = mpro.BuildExceptionResponse(request.Header.RequestID, mpro.ExceptionToJSON(&motan.Exception{ErrCode: 500, ErrMsg: "convert to response fail. err:" + err.Error(), ErrType: motan.ServiceException}))
+				res = mpro.BuildExceptionResponse(request.Header.RequestID, mpro.ExceptionToJSON(motan.NewSerializationException("convert to response fail. err:"+err.Error())))
 			}
 		}
 	}
-	// recover the communication identifier
-	res.Header.RequestID = lastRequestID
-	resBuf := res.Encode()
-	if tc != nil {
-		tc.PutResSpan(&motan.Span{Name: motan.Encode, Time: time.Now()})
-	}
-
-	conn.SetWriteDeadline(time.Now().Add(motan.DefaultWriteTimeout))
-	_, err := conn.Write(resBuf.Bytes())
-	if err != nil {
-		vlog.Errorf("connection will close. conn: %s, err:%s\n", conn.RemoteAddr().String(), err.Error())
-		conn.Close()
+	if request.Header.IsOneWay() {
+		return nil
 	}
-	if tc != nil {
-		tc.PutResSpan(&motan.Span{Name: motan.Send, Time: time.Now()})
+	if handlerTime > 0 {
+		res.Metadata.Store(mpro.MHandlerTime, strconv.FormatInt(int64(handlerTime/time.Microsecond), 10))
 	}
+	return res
 }
 
 func getRemoteIP(address string) string {
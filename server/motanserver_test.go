@@ -0,0 +1,331 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
+	mpro "github.com/weibocom/motan-go/protocol"
+	"github.com/weibocom/motan-go/provider"
+	"github.com/weibocom/motan-go/serialize"
+)
+
+// freePort returns a port not in use, picked by listening on :0
+func freePort(t *testing.T) int {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("pick free port fail. err:%v", err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+// newTestHandler returns the message handler of the service, the params are set to the provider url
+func newTestHandler(path string, service interface{}, params map[string]string) *DefaultMessageHandler {
+	p := &provider.DefaultProvider{}
+	p.SetURL(&motan.URL{Path: path, Parameters: params})
+	p.SetService(service)
+	p.Initialize()
+	handler := &DefaultMessageHandler{}
+	handler.Initialize()
+	handler.AddProvider(p)
+	return handler
+}
+
+// openTestServer opens the motan server of the handler on a free port, the params are set to the server url.
+// the extension factory with the default serializations is used if ext is nil
+func openTestServer(t *testing.T, ext motan.ExtensionFactory, handler motan.MessageHandler, params map[string]string) *MotanServer {
+	if ext == nil {
+		factory := &motan.DefaultExtensionFactory{}
+		factory.Initialize()
+		serialize.RegistDefaultSerializations(factory)
+		ext = factory
+	}
+	server := &MotanServer{URL: &motan.URL{Port: freePort(t), Parameters: params}}
+	if err := server.Open(false, false, handler, ext); err != nil {
+		t.Fatalf("open server fail. err:%v", err)
+	}
+	return server
+}
+
+// newTestEndpoint returns the endpoint connected to the server, the params are set to the endpoint url
+func newTestEndpoint(server *MotanServer, params map[string]string) *endpoint.MotanEndpoint {
+	ep := &endpoint.MotanEndpoint{}
+	ep.SetURL(&motan.URL{Protocol: "motan2", Host: "127.0.0.1", Port: server.URL.Port, Parameters: params})
+	ep.SetSerialization(&serialize.SimpleSerialization{})
+	ep.Initialize()
+	return ep
+}
+
+// startTestServer opens the motan server of the service on a free port, and returns it with an endpoint connected to it
+func startTestServer(t *testing.T, path string, service interface{}, serverParams, epParams map[string]string) (*MotanServer, *endpoint.MotanEndpoint) {
+	server := openTestServer(t, nil, newTestHandler(path, service, nil), serverParams)
+	return server, newTestEndpoint(server, epParams)
+}
+
+type deadlineHandler struct {
+	DefaultMessageHandler
+	deadline time.Time
+	called   bool
+}
+
+func (d *deadlineHandler) Call(request motan.Request) motan.Response {
+	d.called = true
+	d.deadline = request.GetRPCContext(true).Deadline
+	return &motan.MotanResponse{RequestID: request.GetRequestID(), Value: "ok", Attachment: motan.NewStringMap(0)}
+}
+
+func TestHandleDeadline(t *testing.T) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	serialize.RegistDefaultSerializations(ext)
+	handler := &deadlineHandler{}
+	server := &MotanServer{URL: &motan.URL{}, handler: handler, extFactory: ext}
+
+	buildRequest := func(timeout time.Duration) *mpro.Message {
+		msg := &mpro.Message{Header: mpro.BuildHeader(mpro.Req, false, mpro.Simple, 1, mpro.Normal), Metadata: motan.NewStringMap(0)}
+		msg.Metadata.Store(mpro.MPath, "test")
+		msg.Metadata.Store(mpro.MMethod, "test")
+		msg.SetTimeout(timeout)
+		return msg
+	}
+
+	received := time.Now()
+	res := server.handleMessage(context.Background(), buildRequest(500*time.Millisecond), received, nil)
+	if !handler.called || res.Header.GetStatus() != mpro.Normal {
+		t.Fatalf("request in deadline should be handled")
+	}
+	if !handler.deadline.Equal(received.Add(500 * time.Millisecond)) {
+		t.Errorf("deadline should be propagated to handler. deadline:%v", handler.deadline)
+	}
+
+	handler.called = false
+	res = server.handleMessage(context.Background(), buildRequest(10*time.Millisecond), time.Now().Add(-20*time.Millisecond), nil)
+	if handler.called || res.Header.GetStatus() != mpro.Exception {
+		t.Errorf("expired request should be rejected without calling handler")
+	}
+}
+
+type slowService struct{}
+
+func (s *slowService) Sleep() string {
+	time.Sleep(300 * time.Millisecond)
+	return "done"
+}
+
+func TestShutdown(t *testing.T) {
+	server, ep := startTestServer(t, "slowService", &slowService{}, nil, map[string]string{"requestTimeout": "2000"})
+	defer ep.Destroy()
+
+	var reply string
+	result := make(chan motan.Response, 1)
+	go func() {
+		request := &motan.MotanRequest{ServiceName: "slowService", Method: "Sleep", Attachment: motan.NewStringMap(0)}
+		request.GetRPCContext(true).Reply = &reply
+		result <- ep.Call(request)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	if err := server.Shutdown(2 * time.Second); err != nil {
+		t.Errorf("shutdown should wait for the request being processed. err:%v", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Errorf("shutdown returned before the request finished")
+	}
+	res := <-result
+	if res.GetException() != nil {
+		t.Fatalf("request being processed should be finished. exception:%v", res.GetException())
+	}
+	if reply != "done" {
+		t.Errorf("wrong reply of the request being processed. reply:%s", reply)
+	}
+	if conn, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(server.URL.Port), time.Second); err == nil {
+		conn.Close()
+		t.Errorf("server should not accept after shutdown")
+	}
+}
+
+func TestConnectionLimits(t *testing.T) {
+	handler := &DefaultMessageHandler{}
+	handler.Initialize()
+	server := openTestServer(t, nil, handler, map[string]string{motan.MaxConnectionsPerIPKey: "1"})
+	defer server.Destroy()
+	addr := "127.0.0.1:" + strconv.Itoa(server.URL.Port)
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial fail. err:%v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial fail. err:%v", err)
+	}
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = second.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+		t.Errorf("connection exceeding the limit of the ip should be closed. err:%v", err)
+	}
+	second.Close()
+	first.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err = first.Read(make([]byte, 1)); !isTimeout(err) {
+		t.Errorf("connection within the limit should be kept. err:%v", err)
+	}
+	first.Close()
+	time.Sleep(20 * time.Millisecond)
+	third, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial fail. err:%v", err)
+	}
+	third.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err = third.Read(make([]byte, 1)); !isTimeout(err) {
+		t.Errorf("connection should be accepted after the closed one is released. err:%v", err)
+	}
+	third.Close()
+
+	// connections not from tcp are only limited by the max connections
+	limited := &MotanServer{URL: &motan.URL{}, maxConns: 1, maxConnsPerIP: 1, ipConnCount: make(map[string]int)}
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	ip, ok := limited.acquireConn(c1)
+	if !ok || ip != "" {
+		t.Errorf("connection within the limit should be accepted. ip:%s", ip)
+	}
+	if _, ok = limited.acquireConn(c2); ok {
+		t.Errorf("connection exceeding max connections should be rejected")
+	}
+	limited.releaseConn(ip)
+	if _, ok = limited.acquireConn(c2); !ok {
+		t.Errorf("connection should be accepted after release")
+	}
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+func TestConnectionTimeouts(t *testing.T) {
+	server := openTestServer(t, nil, newTestHandler("slowService", &slowService{}, nil), map[string]string{motan.IdleTimeoutKey: "100", motan.ReadTimeoutKey: "100"})
+	defer server.Destroy()
+	addr := "127.0.0.1:" + strconv.Itoa(server.URL.Port)
+
+	// idle connection
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial fail. err:%v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	start := time.Now()
+	if _, err = conn.Read(make([]byte, 1)); err == nil || isTimeout(err) || time.Since(start) < 100*time.Millisecond {
+		t.Errorf("idle connection should be closed after idle timeout. err:%v", err)
+	}
+	conn.Close()
+
+	// slow client sending part of a frame
+	conn, err = net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial fail. err:%v", err)
+	}
+	conn.Write(mpro.BuildHeartbeat(1, mpro.Req).Encode().Bytes()[:5])
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = conn.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+		t.Errorf("connection should be closed after read timeout. err:%v", err)
+	}
+	conn.Close()
+
+	// the connection with requests being processed is not idle
+	ep := newTestEndpoint(server, map[string]string{"requestTimeout": "2000"})
+	defer ep.Destroy()
+	var reply string
+	request := &motan.MotanRequest{ServiceName: "slowService", Method: "Sleep", Attachment: motan.NewStringMap(0)}
+	request.GetRPCContext(true).Reply = &reply
+	if res := ep.Call(request); res.GetException() != nil || reply != "done" {
+		t.Errorf("request longer than idle timeout should be finished. exception:%v", res.GetException())
+	}
+}
+
+func TestPipelineTiming(t *testing.T) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	serialize.RegistDefaultSerializations(ext)
+	server := &MotanServer{URL: &motan.URL{}, handler: &deadlineHandler{}, extFactory: ext}
+	server.initConnOptions()
+	request := &mpro.Message{Header: mpro.BuildHeader(mpro.Req, false, mpro.Simple, 1, mpro.Normal), Metadata: motan.NewStringMap(0)}
+	request.Metadata.Store(mpro.MPath, "test")
+	request.Metadata.Store(mpro.MMethod, "test")
+	tc := &motan.TraceContext{}
+	received := time.Now().Add(-30 * time.Millisecond)
+	decoded := time.Now().Add(-20 * time.Millisecond)
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	finished := make(chan struct{})
+	go server.processReq(context.Background(), func() { close(finished) }, request, received, decoded, tc, c1)
+	res, err := mpro.Decode(bufio.NewReader(c2))
+	if err != nil {
+		t.Fatalf("decode response fail. err:%v", err)
+	}
+	qt, _ := strconv.Atoi(res.Metadata.LoadOrEmpty(mpro.MQueueTime))
+	pt, _ := strconv.Atoi(res.Metadata.LoadOrEmpty(mpro.MProcessTime))
+	if qt < 20000 || pt < 30 {
+		t.Errorf("wrong queue time or process time. queue:%dus, process:%dms", qt, pt)
+	}
+	if _, ok := res.Metadata.Load(mpro.MHandlerTime); !ok {
+		t.Errorf("handler time should be set")
+	}
+	// the send span is put after the response is written
+	<-finished
+	names := make([]string, 0, 8)
+	for _, span := range tc.ReqSpans {
+		names = append(names, span.Name)
+	}
+	for _, span := range tc.ResSpans {
+		names = append(names, span.Name)
+	}
+	if strings.Join(names, ",") != "dequeue,convert,handlerStart,handlerEnd,convert,encode,send" {
+		t.Errorf("wrong trace spans. spans:%v", names)
+	}
+}
+
+func TestPushControl(t *testing.T) {
+	handler := &DefaultMessageHandler{}
+	handler.Initialize()
+	server := openTestServer(t, nil, handler, nil)
+	defer server.Destroy()
+
+	received := make(chan *motan.ControlMessage, 4)
+	remove := endpoint.AddControlListener(func(msg *motan.ControlMessage) {
+		received <- msg
+	})
+	defer remove()
+	ep := newTestEndpoint(server, map[string]string{"requestTimeout": "1000", endpoint.ChannelPoolSizeKey: "1"})
+	defer ep.Destroy()
+	time.Sleep(50 * time.Millisecond)
+
+	sent, err := server.Push(motan.ControlWeight, []byte("5"))
+	if err != nil || sent != 1 {
+		t.Fatalf("push control message fail. sent:%d, err:%v", sent, err)
+	}
+	select {
+	case msg := <-received:
+		if msg.Kind != motan.ControlWeight || string(msg.Payload) != "5" || msg.Address != "127.0.0.1:"+strconv.Itoa(server.URL.Port) {
+			t.Errorf("wrong control message: %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("control message not received")
+	}
+	if _, err = server.Push(motan.ControlDrain, make([]byte, mpro.MaxControlPayloadSize+1)); err == nil {
+		t.Error("control message with too large payload should be rejected")
+	}
+	// the endpoint still works after the control message
+	if _, err = ep.Ping(time.Second); err != nil {
+		t.Errorf("endpoint should work after the control message. err:%v", err)
+	}
+}
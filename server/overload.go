@@ -0,0 +1,122 @@
+package server
+
+import (
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+const (
+	overloadCheckInterval = time.Second
+	// the reject ratio changes by the step each interval, and never reaches 1 so the recovery can be observed
+	overloadRatioStep = 100
+	overloadRatioMax  = 900
+	overloadRatioBase = 1000
+)
+
+// overloadProtector rejects a fraction of the requests when the process is overloaded, the fraction increases
+// while any of the cpu usage, goroutines and queue latency exceeds its threshold, and decreases to 0 after recovery.
+// the rejected requests get a ServiceException, which is retried on other servers by the failover strategy.
+type overloadProtector struct {
+	maxCPU          float64 // cpu usage of the process in percent of all cores, not supported on windows
+	maxGoroutines   int
+	maxQueueLatency time.Duration
+
+	rejectRatio  int32 // per mille
+	latencySum   int64 // nanoseconds in the current interval
+	latencyCount int64
+	lastCPU      time.Duration
+	lastCheck    time.Time
+	stop         chan struct{}
+}
+
+// newOverloadProtector creates the protector with the params of the server url, nil if no threshold is set
+func newOverloadProtector(url *motan.URL) *overloadProtector {
+	o := &overloadProtector{
+		maxCPU:          float64(url.GetPositiveIntValue(motan.OverloadMaxCPUKey, 0)),
+		maxGoroutines:   int(url.GetPositiveIntValue(motan.OverloadMaxGoroutinesKey, 0)),
+		maxQueueLatency: url.GetTimeDuration(motan.OverloadMaxQueueLatencyKey, time.Millisecond, 0),
+	}
+	if o.maxCPU <= 0 && o.maxGoroutines <= 0 && o.maxQueueLatency <= 0 {
+		return nil
+	}
+	return o
+}
+
+func (o *overloadProtector) start() {
+	o.stop = make(chan struct{})
+	o.lastCPU = processCPUTime()
+	o.lastCheck = time.Now()
+	go func() {
+		ticker := time.NewTicker(overloadCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-o.stop:
+				return
+			case <-ticker.C:
+				o.check()
+			}
+		}
+	}()
+}
+
+func (o *overloadProtector) destroy() {
+	close(o.stop)
+}
+
+// check samples the metrics of the last interval
+func (o *overloadProtector) check() {
+	now := time.Now()
+	cpuTime := processCPUTime()
+	var cpu float64
+	if elapsed := now.Sub(o.lastCheck); elapsed > 0 {
+		cpu = float64(cpuTime-o.lastCPU) / float64(elapsed) / float64(runtime.NumCPU()) * 100
+	}
+	o.lastCPU = cpuTime
+	o.lastCheck = now
+	var latency time.Duration
+	sum := atomic.SwapInt64(&o.latencySum, 0)
+	if count := atomic.SwapInt64(&o.latencyCount, 0); count > 0 {
+		latency = time.Duration(sum / count)
+	}
+	o.adjust(cpu, runtime.NumGoroutine(), latency)
+}
+
+// adjust changes the reject ratio by the metrics
+func (o *overloadProtector) adjust(cpu float64, goroutines int, latency time.Duration) {
+	overloaded := (o.maxCPU > 0 && cpu > o.maxCPU) ||
+		(o.maxGoroutines > 0 && goroutines > o.maxGoroutines) ||
+		(o.maxQueueLatency > 0 && latency > o.maxQueueLatency)
+	old := atomic.LoadInt32(&o.rejectRatio)
+	ratio := old
+	if overloaded {
+		if ratio += overloadRatioStep; ratio > overloadRatioMax {
+			ratio = overloadRatioMax
+		}
+	} else if ratio -= overloadRatioStep; ratio < 0 {
+		ratio = 0
+	}
+	atomic.StoreInt32(&o.rejectRatio, ratio)
+	if old == 0 && ratio > 0 {
+		vlog.Warningf("motan server overloaded, start rejecting requests. cpu:%.1f%%, goroutines:%d, queue latency:%v\n", cpu, goroutines, latency)
+	} else if old > 0 && ratio == 0 {
+		vlog.Infof("motan server recovered from overload. cpu:%.1f%%, goroutines:%d, queue latency:%v\n", cpu, goroutines, latency)
+	}
+}
+
+// recordLatency records the time a request waited before processing
+func (o *overloadProtector) recordLatency(latency time.Duration) {
+	atomic.AddInt64(&o.latencySum, int64(latency))
+	atomic.AddInt64(&o.latencyCount, 1)
+}
+
+// reject returns true if the request should be rejected
+func (o *overloadProtector) reject() bool {
+	ratio := atomic.LoadInt32(&o.rejectRatio)
+	return ratio > 0 && rand.Int31n(overloadRatioBase) < ratio
+}
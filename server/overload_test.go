@@ -0,0 +1,63 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func TestOverloadProtector(t *testing.T) {
+	if newOverloadProtector(&motan.URL{}) != nil {
+		t.Errorf("overload protector should not be created without thresholds")
+	}
+	o := newOverloadProtector(&motan.URL{Parameters: map[string]string{motan.OverloadMaxCPUKey: "80", motan.OverloadMaxGoroutinesKey: "1000", motan.OverloadMaxQueueLatencyKey: "100"}})
+	if o.maxCPU != 80 || o.maxGoroutines != 1000 || o.maxQueueLatency != 100*time.Millisecond {
+		t.Fatalf("wrong overload thresholds. protector:%+v", o)
+	}
+	for i := 0; i < 100; i++ {
+		if o.reject() {
+			t.Fatalf("requests should not be rejected without overload")
+		}
+	}
+	o.adjust(90, 10, 0)
+	if o.rejectRatio != overloadRatioStep {
+		t.Errorf("reject ratio should increase when overloaded. ratio:%d", o.rejectRatio)
+	}
+	o.adjust(10, 2000, 0)
+	o.adjust(10, 10, time.Second)
+	if o.rejectRatio != 3*overloadRatioStep {
+		t.Errorf("reject ratio should increase when overloaded. ratio:%d", o.rejectRatio)
+	}
+	for i := 0; i < 20; i++ {
+		o.adjust(100, 10, 0)
+	}
+	if o.rejectRatio != overloadRatioMax {
+		t.Errorf("reject ratio should not be larger than the max. ratio:%d", o.rejectRatio)
+	}
+	rejected := 0
+	for i := 0; i < 1000; i++ {
+		if o.reject() {
+			rejected++
+		}
+	}
+	if rejected < 800 || rejected == 1000 {
+		t.Errorf("wrong rejected requests. rejected:%d", rejected)
+	}
+	for i := 0; i < 20; i++ {
+		o.adjust(10, 10, 0)
+	}
+	if o.rejectRatio != 0 || o.reject() {
+		t.Errorf("requests should not be rejected after recovery. ratio:%d", o.rejectRatio)
+	}
+
+	o.recordLatency(100 * time.Millisecond)
+	o.recordLatency(300 * time.Millisecond)
+	o.maxCPU = 0
+	o.lastCPU = processCPUTime()
+	o.lastCheck = time.Now().Add(-time.Second)
+	o.check()
+	if o.rejectRatio != overloadRatioStep || o.latencyCount != 0 {
+		t.Errorf("average queue latency should be checked. ratio:%d", o.rejectRatio)
+	}
+}
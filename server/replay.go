@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+// the record file is a sequence of records, each is the receive time in unix nanoseconds as a big endian uint64
+// followed by the motan2 request frame as received, including the serialized body and the metadata.
+const (
+	defaultRecordMaxSize = 100 // MB
+	recordQueueSize      = 1024
+	recordFlushInterval  = time.Second
+	defaultReplayTimeout = time.Second
+)
+
+// requestRecorder samples the requests of a server into a local file for replaying, the requests are dropped
+// if the file writing falls behind, and the recording stops when the file reaches the max size
+type requestRecorder struct {
+	path    string
+	rate    int // percent
+	maxSize int64
+	size    int64
+	file    *os.File
+	queue   chan []byte
+	stop    chan struct{}
+	stopped chan struct{}
+	full    int32
+}
+
+// newRequestRecorder creates the recorder writing into the record dir of the server url, nil if the dir is not set
+func newRequestRecorder(url *motan.URL) (*requestRecorder, error) {
+	dir := url.GetParam(motan.RecordDirKey, "")
+	if dir == "" {
+		return nil, nil
+	}
+	rate := int(url.GetIntValue(motan.RecordRateKey, 100))
+	if rate <= 0 || rate > 100 {
+		rate = 100
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "motan-record-"+strconv.Itoa(url.Port)+"-"+time.Now().Format("20060102150405")+".rec")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r := &requestRecorder{
+		path:    path,
+		rate:    rate,
+		maxSize: url.GetPositiveIntValue(motan.RecordMaxSizeKey, defaultRecordMaxSize) * 1024 * 1024,
+		file:    file,
+		queue:   make(chan []byte, recordQueueSize),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go r.run()
+	vlog.Infof("motan server records requests into %s, rate:%d%%\n", path, rate)
+	return r, nil
+}
+
+// sample returns true if the request should be recorded
+func (r *requestRecorder) sample() bool {
+	return atomic.LoadInt32(&r.full) == 0 && (r.rate >= 100 || rand.Intn(100) < r.rate)
+}
+
+// record encodes the request in the caller goroutine, since the message may be changed during processing
+func (r *requestRecorder) record(request *mpro.Message, received time.Time) {
+	buf := request.Encode()
+	data := make([]byte, 8+buf.Len())
+	binary.BigEndian.PutUint64(data, uint64(received.UnixNano()))
+	copy(data[8:], buf.Bytes())
+	motan.ReleaseBytesBuffer(buf)
+	select {
+	case r.queue <- data:
+	default:
+	}
+}
+
+func (r *requestRecorder) run() {
+	defer close(r.stopped)
+	w := bufio.NewWriter(r.file)
+	ticker := time.NewTicker(recordFlushInterval)
+	defer ticker.Stop()
+	write := func(data []byte) {
+		if atomic.LoadInt32(&r.full) == 1 {
+			return
+		}
+		if r.size+int64(len(data)) > r.maxSize {
+			atomic.StoreInt32(&r.full, 1)
+			vlog.Warningf("record file %s reaches the max size %d, recording stopped\n", r.path, r.maxSize)
+			return
+		}
+		if _, err := w.Write(data); err != nil {
+			atomic.StoreInt32(&r.full, 1)
+			vlog.Errorf("write record file %s fail, recording stopped. err:%v\n", r.path, err)
+			return
+		}
+		r.size += int64(len(data))
+	}
+	for {
+		select {
+		case data := <-r.queue:
+			write(data)
+		case <-ticker.C:
+			w.Flush()
+		case <-r.stop:
+			for {
+				select {
+				case data := <-r.queue:
+					write(data)
+				default:
+					w.Flush()
+					r.file.Close()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *requestRecorder) destroy() {
+	close(r.stop)
+	<-r.stopped
+}
+
+// ReplayResult is the result of replaying a record file
+type ReplayResult struct {
+	Total   int // requests sent
+	Success int
+	Failed  int // the responses are exceptions or not received in time
+}
+
+// Replay sends the requests in the record file to the motan server at the address one by one, the request ids
+// are renewed. the one-way requests are counted as success once sent. timeout is the max time waiting for a
+// response, 1s if not positive. the result so far is returned with the error if the connection fails
+func Replay(file string, address string, timeout time.Duration) (*ReplayResult, error) {
+	if timeout <= 0 {
+		timeout = defaultReplayTimeout
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { conn.Close() }()
+	records := bufio.NewReader(f)
+	responses := bufio.NewReader(conn)
+	result := &ReplayResult{}
+	var requestID uint64
+	head := make([]byte, 8)
+	for {
+		if _, err = io.ReadFull(records, head); err != nil {
+			if err == io.EOF {
+				return result, nil
+			}
+			return result, fmt.Errorf("read record file fail. err:%v", err)
+		}
+		request, err := mpro.Decode(records)
+		if err != nil {
+			return result, fmt.Errorf("decode record fail. err:%v", err)
+		}
+		requestID++
+		request.Header.RequestID = requestID
+		request.Metadata.Delete(mpro.MRequestID)
+		buf := request.Encode()
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		_, err = conn.Write(buf.Bytes())
+		motan.ReleaseBytesBuffer(buf)
+		if err != nil {
+			return result, err
+		}
+		result.Total++
+		if request.Header.IsOneWay() {
+			result.Success++
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		res, err := readReplayResponse(responses, requestID)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				result.Failed++
+				vlog.Warningf("replay request %s.%s timeout\n", request.Metadata.LoadOrEmpty(mpro.MPath), request.Metadata.LoadOrEmpty(mpro.MMethod))
+				// a response may be partly read, so the connection is renewed
+				conn.Close()
+				c, err := net.DialTimeout("tcp", address, timeout)
+				if err != nil {
+					return result, err
+				}
+				conn = c
+				responses = bufio.NewReader(conn)
+				continue
+			}
+			return result, err
+		}
+		if res.Header.GetStatus() == mpro.Exception {
+			result.Failed++
+		} else {
+			result.Success++
+		}
+	}
+}
+
+func readReplayResponse(buf *bufio.Reader, requestID uint64) (*mpro.Message, error) {
+	for {
+		res, err := mpro.Decode(buf)
+		if err != nil {
+			return nil, err
+		}
+		if res.Header.RequestID == requestID {
+			return res, nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+type replayService struct {
+	calls int32
+}
+
+func (e *replayService) Echo(s string) (string, error) {
+	atomic.AddInt32(&e.calls, 1)
+	if s == "" {
+		return "", errors.New("empty")
+	}
+	return s, nil
+}
+
+func openReplayServer(t *testing.T, params map[string]string) (*MotanServer, *replayService) {
+	service := &replayService{}
+	return openTestServer(t, nil, newTestHandler("replayService", service, nil), params), service
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "motan-record")
+	if err != nil {
+		t.Fatalf("create temp dir fail. err:%v", err)
+	}
+	defer os.RemoveAll(dir)
+	recordServer, _ := openReplayServer(t, map[string]string{motan.RecordDirKey: dir})
+	ep := newTestEndpoint(recordServer, map[string]string{"requestTimeout": "1000"})
+	for _, arg := range []string{"a", "b", ""} {
+		request := &motan.MotanRequest{ServiceName: "replayService", Method: "Echo", Arguments: []interface{}{arg}, Attachment: motan.NewStringMap(0)}
+		ep.Call(request)
+	}
+	ep.Destroy()
+	recordServer.Destroy()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "motan-record-"+strconv.Itoa(recordServer.URL.Port)+"-*.rec"))
+	if len(files) != 1 {
+		t.Fatalf("record file should be created. files:%v", files)
+	}
+	target, service := openReplayServer(t, nil)
+	defer target.Destroy()
+	addr := "127.0.0.1:" + strconv.Itoa(target.URL.Port)
+	result, err := Replay(files[0], addr, time.Second)
+	if err != nil {
+		t.Fatalf("replay fail. err:%v", err)
+	}
+	if result.Total != 3 || result.Success != 2 || result.Failed != 1 || atomic.LoadInt32(&service.calls) != 3 {
+		t.Errorf("wrong replay result. result:%+v, calls:%d", result, service.calls)
+	}
+	if _, err = Replay(filepath.Join(dir, "unknown.rec"), addr, time.Second); err == nil {
+		t.Errorf("replay unknown file should fail")
+	}
+}
+
+func TestRecorderMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "motan-record")
+	if err != nil {
+		t.Fatalf("create temp dir fail. err:%v", err)
+	}
+	defer os.RemoveAll(dir)
+	recorder, err := newRequestRecorder(&motan.URL{Port: 64547, Parameters: map[string]string{motan.RecordDirKey: dir, motan.RecordRateKey: "0"}})
+	if err != nil || recorder.rate != 100 {
+		t.Fatalf("wrong recorder. recorder:%+v, err:%v", recorder, err)
+	}
+	recorder.maxSize = 100
+	for i := 0; i < 3; i++ {
+		recorder.queue <- make([]byte, 40)
+	}
+	recorder.destroy()
+	if info, err := os.Stat(recorder.path); err != nil || info.Size() != 80 || recorder.sample() {
+		t.Errorf("recording should stop at the max size. info:%v, err:%v", info, err)
+	}
+}
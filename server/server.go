@@ -3,7 +3,9 @@ package server
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	motan "github.com/weibocom/motan-go/core"
@@ -11,14 +13,20 @@ import (
 )
 
 const (
-	Motan2 = "motan2"
-	CGI    = "cgi"
+	Motan2    = "motan2"
+	CGI       = "cgi"
+	WebSocket = "websocket"
 )
 
 const (
 	Default = "default"
 )
 
+var (
+	warmUpRetryInterval = 3 * time.Second
+	exporters           sync.Map // all exported *DefaultExporter -> struct{}
+)
+
 func RegistDefaultServers(extFactory motan.ExtensionFactory) {
 	extFactory.RegistExtServer(Motan2, func(url *motan.URL) motan.Server {
 		return &MotanServer{URL: url}
@@ -26,6 +34,9 @@ func RegistDefaultServers(extFactory motan.ExtensionFactory) {
 	extFactory.RegistExtServer(CGI, func(url *motan.URL) motan.Server {
 		return &MotanServer{URL: url}
 	})
+	extFactory.RegistExtServer(WebSocket, func(url *motan.URL) motan.Server {
+		return &WebSocketServer{URL: url}
+	})
 }
 
 func RegistDefaultMessageHandlers(extFactory motan.ExtensionFactory) {
@@ -45,6 +56,11 @@ type DefaultExporter struct {
 	tmpUnavailable bool
 	exported       bool
 	stopChan       chan struct{}
+	warmUp         func() error
+	warmedUp       bool
+	aclListener    *aclCommandListener
+	ready          bool
+	registered     bool
 
 	// 服务管理单位，负责服务注册、心跳、导出和销毁，内部包含provider，与provider是一对一关系
 }
@@ -77,7 +93,6 @@ func (d *DefaultExporter) Export(server motan.Server, extFactory motan.Extension
 		if registryURL, ok := context.RegistryURLs[r]; ok {
 			registry := d.extFactory.GetRegistry(registryURL)
 			if registry != nil {
-				registry.Register(d.url)
 				registries = append(registries, registry)
 			}
 		} else {
@@ -86,12 +101,18 @@ func (d *DefaultExporter) Export(server motan.Server, extFactory motan.Extension
 		}
 	}
 	d.Registries = registries
+	d.subscribeACL()
 
 	d.exported = true
 
 	d.stopChan = make(chan struct{})
 	d.available = false
 	d.tmpUnavailable = true
+	if d.url.GetParam(motan.LazyExportKey, "") != "true" {
+		d.ready = true
+	}
+	d.warmedUp = d.warmUp == nil
+	d.register()
 
 	go func() {
 		ticker := time.NewTicker(1 * time.Second)
@@ -105,7 +126,11 @@ func (d *DefaultExporter) Export(server motan.Server, extFactory motan.Extension
 			}
 		}
 	}()
+	exporters.Store(d, struct{}{})
 	vlog.Infof("export url %s success.\n", d.url.GetIdentity())
+	if d.warmUp != nil {
+		go d.doWarmUp(d.stopChan)
+	}
 	return nil
 }
 
@@ -115,7 +140,7 @@ func (d *DefaultExporter) checkProvider() {
 	defer d.lock.Unlock()
 
 	// 503 status
-	if !d.available {
+	if !d.available || !d.registered {
 		return
 	}
 
@@ -141,21 +166,144 @@ func (d *DefaultExporter) Unexport() error {
 		return nil
 	}
 
-	d.stopChan <- struct{}{}
+	close(d.stopChan)
 
-	d.doUnavailable()
 	d.available = false
-
-	for _, r := range d.Registries {
-		r.UnRegister(d.url)
+	if d.registered {
+		d.doUnavailable()
+		for _, r := range d.Registries {
+			r.UnRegister(d.url)
+		}
+		d.registered = false
 	}
 
+	d.unsubscribeACL()
 	d.server.GetMessageHandler().RmProvider(d.provider)
 	d.exported = false
+	exporters.Delete(d)
 	// TODO: gracefully destroy provider
 	return nil
 }
 
+// subscribeACL updates the acl of the service by the service commands of the registries
+func (d *DefaultExporter) subscribeACL() {
+	d.aclListener = &aclCommandListener{url: d.url, acl: DefaultACL}
+	for _, r := range d.Registries {
+		if dc, ok := r.(motan.DiscoverCommand); ok {
+			dc.SubscribeCommand(d.url, d.aclListener)
+			if command := dc.DiscoverCommand(d.url); command != "" {
+				d.aclListener.NotifyCommand(r.GetURL(), 0, command)
+			}
+		}
+	}
+}
+
+func (d *DefaultExporter) unsubscribeACL() {
+	if d.aclListener == nil {
+		return
+	}
+	for _, r := range d.Registries {
+		if dc, ok := r.(motan.DiscoverCommand); ok {
+			dc.UnSubscribeCommand(d.url, d.aclListener)
+		}
+	}
+	DefaultACL.SetCommandRules(d.url.Path, nil)
+	d.aclListener = nil
+}
+
+// register registers the service to the registries once it is warmed up and ready
+func (d *DefaultExporter) register() {
+	if d.registered || !d.ready || !d.warmedUp {
+		return
+	}
+	for _, r := range d.Registries {
+		r.Register(d.url)
+	}
+	d.registered = true
+}
+
+// doWarmUp calls the warm-up function until it succeeds or the exporter is unexported
+func (d *DefaultExporter) doWarmUp(stop chan struct{}) {
+	for {
+		err := callWarmUp(d.warmUp)
+		if err == nil {
+			break
+		}
+		vlog.Errorf("warm up service fail, retry after %v. url:%s, err:%v\n", warmUpRetryInterval, d.url.GetIdentity(), err)
+		select {
+		case <-time.After(warmUpRetryInterval):
+		case <-stop:
+			return
+		}
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.exported || d.stopChan != stop {
+		return
+	}
+	vlog.Infof("warm up service success. url:%s\n", d.url.GetIdentity())
+	d.warmedUp = true
+	d.register()
+}
+
+func callWarmUp(warmUp func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("warm up panic: %v", r)
+		}
+	}()
+	return warmUp()
+}
+
+// SetWarmUp sets the function called before the service being registered and available, such as
+// filling the connection pools and the caches. it is retried until it succeeds
+func (d *DefaultExporter) SetWarmUp(warmUp func() error) {
+	d.warmUp = warmUp
+}
+
+// Ready registers the lazy exported service to the registries, the service is registered at once in Export
+// if motan.LazyExportKey is not set
+func (d *DefaultExporter) Ready() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.ready = true
+	if d.exported {
+		d.register()
+	}
+}
+
+// UpdateParams updates the params of the exported url at runtime, such as weight, tags or serialization options.
+// an empty value removes the param. the provider gets a new url with the filters rebuilt, so the requests use either
+// the previous url and filters or the new ones, and the new url is re-published to the registries if registered
+func (d *DefaultExporter) UpdateParams(params map[string]string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.exported {
+		return errors.New("exporter not exported")
+	}
+	for k := range params {
+		if k == motan.RegistryKey || k == motan.NodeTypeKey || k == motan.ExportKey {
+			return errors.New("param can not be updated at runtime: " + k)
+		}
+	}
+	old := d.url
+	url := old.CopyWithParams(params)
+	d.provider.SetURL(url)
+	d.server.GetMessageHandler().AddProvider(d.provider)
+	d.url = url
+	if d.registered {
+		for _, r := range d.Registries {
+			r.UnRegister(old)
+			r.Register(url)
+		}
+		if !d.tmpUnavailable {
+			d.doAvailable()
+		}
+	}
+	vlog.Infof("update exported url params success. url:%s, params:%v\n", url.GetIdentity(), params)
+	return nil
+}
+
 func (d *DefaultExporter) SetProvider(provider motan.Provider) {
 	d.provider = provider
 }
@@ -208,27 +356,38 @@ func (d *DefaultExporter) SetURL(url *motan.URL) {
 }
 
 type DefaultMessageHandler struct {
-	providers map[string]motan.Provider
+	providers *motan.CopyOnWriteMap
+	exposures *motan.CopyOnWriteMap // only the services with exposure config
 }
 
 func (d *DefaultMessageHandler) Initialize() {
-	d.providers = make(map[string]motan.Provider)
+	d.providers = motan.NewCopyOnWriteMap()
+	d.exposures = motan.NewCopyOnWriteMap()
 }
 
+// AddProvider adds or replaces the provider of the path, it is also used to refresh the provider after its url updated
 func (d *DefaultMessageHandler) AddProvider(p motan.Provider) error {
-	d.providers[p.GetPath()] = p
+	d.providers.Store(p.GetPath(), p)
+	if e := NewMethodExposure(p.GetURL()); e != nil {
+		d.exposures.Store(p.GetPath(), e)
+	} else {
+		d.exposures.Delete(p.GetPath())
+	}
 	return nil
 }
 
 func (d *DefaultMessageHandler) RmProvider(p motan.Provider) {
-	dp := d.providers[p.GetPath()]
-	if dp != nil && p == dp {
-		delete(d.providers, p.GetPath())
+	if dp := d.GetProvider(p.GetPath()); dp != nil && p == dp {
+		d.providers.Delete(p.GetPath())
+		d.exposures.Delete(p.GetPath())
 	}
 }
 
 func (d *DefaultMessageHandler) GetProvider(serviceName string) motan.Provider {
-	return d.providers[serviceName]
+	if p := d.providers.LoadOrNil(serviceName); p != nil {
+		return p.(motan.Provider)
+	}
+	return nil
 }
 
 func (d *DefaultMessageHandler) Call(request motan.Request) (res motan.Response) {
@@ -236,19 +395,30 @@ func (d *DefaultMessageHandler) Call(request motan.Request) (res motan.Response)
 		res = motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "provider call panic", ErrType: motan.ServiceException})
 		vlog.Errorf("provider call panic. req:%s\n", motan.GetReqInfo(request))
 	})
-	p := d.providers[request.GetServiceName()]
+	p := d.GetProvider(request.GetServiceName())
 	if p != nil {
+		if e := d.exposures.LoadOrNil(request.GetServiceName()); e != nil && !e.(*MethodExposure).IsExposed(request.GetMethod()) {
+			return MethodNotExported(request)
+		}
 		res = p.Call(request)
-		res.GetRPCContext(true).GzipSize = int(p.GetURL().GetIntValue(motan.GzipSizeKey, 0))
+		SetResponseCompression(p.GetURL(), request, res)
 		return res
 	}
 	vlog.Errorf("not found provider for %s\n", motan.GetReqInfo(request))
-	return motan.BuildExceptionResponse(request.GetRequestID(), &motan.Exception{ErrCode: 500, ErrMsg: "not found provider for " + request.GetServiceName(), ErrType: motan.ServiceException})
+	return motan.BuildExceptionResponse(request.GetRequestID(), motan.NewException(motan.ErrCodeNotFound, "not found provider for "+request.GetServiceName()))
 }
 
 type FilterProviderWrapper struct {
-	provider motan.Provider
-	filter   motan.EndPointFilter
+	provider   motan.Provider
+	chain      atomic.Value // *filterChain
+	extFactory motan.ExtensionFactory
+	context    *motan.Context
+}
+
+// filterChain is the filters built with the url, they are replaced together when the url updated
+type filterChain struct {
+	url    *motan.URL
+	filter motan.EndPointFilter
 }
 
 func (f *FilterProviderWrapper) SetService(s interface{}) {
@@ -256,11 +426,14 @@ func (f *FilterProviderWrapper) SetService(s interface{}) {
 }
 
 func (f *FilterProviderWrapper) GetURL() *motan.URL {
-	return f.provider.GetURL()
+	return f.chain.Load().(*filterChain).url
 }
 
+// SetURL sets the url of the provider and rebuilds the filters with it, the requests being processed keep
+// using the previous filters
 func (f *FilterProviderWrapper) SetURL(url *motan.URL) {
 	f.provider.SetURL(url)
+	f.chain.Store(newFilterChain(url, f.extFactory, f.context))
 }
 
 func (f *FilterProviderWrapper) GetPath() string {
@@ -276,21 +449,31 @@ func (f *FilterProviderWrapper) Destroy() {
 }
 
 func (f *FilterProviderWrapper) Call(request motan.Request) (res motan.Response) {
-	return f.filter.Filter(f.provider, request)
+	return f.chain.Load().(*filterChain).filter.Filter(f.provider, request)
 }
 
-func WrapWithFilter(provider motan.Provider, extFactory motan.ExtensionFactory, context *motan.Context) motan.Provider {
-	var lastf motan.EndPointFilter
-	lastf = motan.GetLastEndPointFilter()
-	_, filters := motan.GetURLFilters(provider.GetURL(), extFactory)
-	for _, f := range filters {
-		if filter := f.NewFilter(provider.GetURL()); filter != nil {
-			if ef, ok := filter.(motan.EndPointFilter); ok {
-				motan.CanSetContext(ef, context)
-				ef.SetNext(lastf)
-				lastf = ef
-			}
-		}
+// DescribeMethods describes the methods of the wrapped provider if it implements motan.MethodDescriber
+func (f *FilterProviderWrapper) DescribeMethods() []*motan.MethodDescriptor {
+	if md, ok := f.provider.(motan.MethodDescriber); ok {
+		return md.DescribeMethods()
 	}
-	return &FilterProviderWrapper{provider: provider, filter: lastf}
+	return nil
+}
+
+func WrapWithFilter(provider motan.Provider, extFactory motan.ExtensionFactory, context *motan.Context) motan.Provider {
+	f := &FilterProviderWrapper{provider: provider, extFactory: extFactory, context: context}
+	f.chain.Store(newFilterChain(provider.GetURL(), extFactory, context))
+	return f
+}
+
+func newFilterChain(url *motan.URL, extFactory motan.ExtensionFactory, context *motan.Context) *filterChain {
+	_, filters := motan.GetURLFilters(url, extFactory)
+	DefaultACL.SetStaticRules(url.Path, ParseACL(url.Path, url.GetParam(motan.ACLKey, "")))
+	// the acl filter checks the rules of the service at each call, as the rules can be updated by the commands
+	filters = append(filters, &aclFilter{acl: DefaultACL})
+	sort.SliceStable(filters, func(i, j int) bool {
+		return filters[i].GetIndex() > filters[j].GetIndex()
+	})
+	filter, _ := motan.BuildEndPointFilterChain(url, filters, context)
+	return &filterChain{url: url, filter: filter}
 }
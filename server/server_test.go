@@ -0,0 +1,188 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/provider"
+)
+
+type recordRegistry struct {
+	motan.TestRegistry
+	lock   sync.Mutex
+	events []string
+}
+
+func (r *recordRegistry) record(event string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recordRegistry) Register(serverURL *motan.URL)    { r.record("register") }
+func (r *recordRegistry) UnRegister(serverURL *motan.URL)  { r.record("unregister") }
+func (r *recordRegistry) Available(serverURL *motan.URL)   { r.record("available") }
+func (r *recordRegistry) Unavailable(serverURL *motan.URL) { r.record("unavailable") }
+
+func (r *recordRegistry) getEvents() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+type warmUpService struct{}
+
+func (w *warmUpService) Hello(name string) string {
+	return "hello " + name
+}
+
+func (w *warmUpService) WarmUp() error {
+	return nil
+}
+
+// newTestExporter returns the exporter, the registry and the function to export
+func newTestExporter(params map[string]string) (*DefaultExporter, *recordRegistry, func() error) {
+	registry := &recordRegistry{}
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	ext.RegistExtRegistry("record", func(url *motan.URL) motan.Registry {
+		return registry
+	})
+	ctx := &motan.Context{RegistryURLs: map[string]*motan.URL{"reg": {Protocol: "record", Host: "127.0.0.1", Port: 1}}}
+	params[motan.RegistryKey] = "reg"
+	url := &motan.URL{Protocol: "motan2", Host: "127.0.0.1", Port: 64544, Path: "test.warmup", Parameters: params}
+	p := &provider.DefaultProvider{}
+	p.SetService(&warmUpService{})
+	p.SetURL(url)
+	p.Initialize()
+	handler := &DefaultMessageHandler{}
+	handler.Initialize()
+	handler.AddProvider(p)
+	server := &MotanServer{URL: url}
+	server.SetMessageHandler(handler)
+	exporter := &DefaultExporter{}
+	exporter.SetProvider(p)
+	return exporter, registry, func() error { return exporter.Export(server, ext, ctx) }
+}
+
+func checkEvents(t *testing.T, registry *recordRegistry, expect ...string) {
+	events := registry.getEvents()
+	if len(events) != len(expect) {
+		t.Fatalf("wrong registry events. expect:%v, real:%v", expect, events)
+	}
+	for i, e := range expect {
+		if events[i] != e {
+			t.Fatalf("wrong registry events. expect:%v, real:%v", expect, events)
+		}
+	}
+}
+
+func TestExporterWarmUp(t *testing.T) {
+	oldInterval := warmUpRetryInterval
+	warmUpRetryInterval = 10 * time.Millisecond
+	defer func() { warmUpRetryInterval = oldInterval }()
+
+	exporter, registry, export := newTestExporter(map[string]string{})
+	var lock sync.Mutex
+	calls := 0
+	warmed := make(chan struct{})
+	exporter.SetWarmUp(func() error {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		if calls == 1 {
+			return errors.New("pool not ready")
+		}
+		if calls == 2 {
+			panic("cache not ready")
+		}
+		close(warmed)
+		return nil
+	})
+	if err := export(); err != nil {
+		t.Fatalf("export fail. err:%v", err)
+	}
+	exporter.Available()
+	exporter.checkProvider()
+	select {
+	case <-warmed:
+	case <-time.After(time.Second):
+		t.Fatalf("warm up not retried")
+	}
+	time.Sleep(20 * time.Millisecond)
+	exporter.checkProvider()
+	checkEvents(t, registry, "register", "available")
+	if !exporter.IsAvailable() {
+		t.Errorf("exporter should be available after warming up")
+	}
+	exporter.Unexport()
+	checkEvents(t, registry, "register", "available", "unavailable", "unregister")
+}
+
+func TestExporterLazyExport(t *testing.T) {
+	exporter, registry, export := newTestExporter(map[string]string{motan.LazyExportKey: "true"})
+	if err := export(); err != nil {
+		t.Fatalf("export fail. err:%v", err)
+	}
+	exporter.Available()
+	exporter.checkProvider()
+	checkEvents(t, registry)
+	exporter.Ready()
+	checkEvents(t, registry, "register")
+	exporter.checkProvider()
+	checkEvents(t, registry, "register", "available")
+	exporter.Ready()
+	checkEvents(t, registry, "register", "available")
+	exporter.Unexport()
+}
+
+func TestProviderSkipWarmUp(t *testing.T) {
+	exporter, _, _ := newTestExporter(map[string]string{})
+	request := &motan.MotanRequest{ServiceName: "test.warmup", Method: "WarmUp"}
+	request.RPCContext = &motan.RPCContext{}
+	if res := exporter.GetProvider().Call(request); res.GetException() == nil {
+		t.Errorf("WarmUp should not be exposed as a remote method")
+	}
+}
+
+func TestExporterUpdateParams(t *testing.T) {
+	exporter, registry, export := newTestExporter(map[string]string{"weight": "10"})
+	if err := exporter.UpdateParams(map[string]string{"weight": "20"}); err == nil {
+		t.Errorf("update params should fail before export")
+	}
+	if err := export(); err != nil {
+		t.Fatalf("export fail. err:%v", err)
+	}
+	defer exporter.Unexport()
+	p := WrapWithFilter(exporter.GetProvider(), &motan.DefaultExtensionFactory{}, nil)
+	exporter.SetProvider(p)
+	handler := exporter.server.GetMessageHandler()
+	handler.AddProvider(p)
+	exporter.Available()
+	exporter.checkProvider()
+	old := exporter.GetURL()
+
+	if err := exporter.UpdateParams(map[string]string{motan.RegistryKey: "other"}); err == nil {
+		t.Errorf("registry should not be updated at runtime")
+	}
+	if err := exporter.UpdateParams(map[string]string{"weight": "", motan.ExportMethodsKey: "Hello"}); err != nil {
+		t.Fatalf("update params fail. err:%v", err)
+	}
+	url := exporter.GetURL()
+	if url == old || url.GetParam("weight", "") != "" || old.GetParam("weight", "") != "10" || p.GetURL() != url {
+		t.Errorf("params should be updated by a new url. url:%v", url)
+	}
+	checkEvents(t, registry, "register", "available", "unregister", "register", "available")
+	request := &motan.MotanRequest{ServiceName: "test.warmup", Method: "Hello", Arguments: []interface{}{"motan"}}
+	request.RPCContext = &motan.RPCContext{}
+	if res := handler.Call(request); res.GetException() != nil {
+		t.Errorf("wrong response. res:%+v", res)
+	}
+	exporter.UpdateParams(map[string]string{motan.ExportMethodsKey: "Other"})
+	if res := handler.Call(request); res.GetException() == nil || res.GetException().ErrCode != 403 {
+		t.Errorf("method exposure should be updated. res:%+v", res)
+	}
+}
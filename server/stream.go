@@ -0,0 +1,222 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mpro "github.com/weibocom/motan-go/protocol"
+)
+
+var (
+	defaultStreamRecvSize    = 64
+	defaultStreamRecvTimeout = 60 * time.Second
+
+	errStreamClosed = errors.New("stream has been closed")
+)
+
+// serverStreams holds the streaming calls of one connection
+type serverStreams struct {
+	lock    sync.Mutex
+	streams map[uint64]*serverStream
+}
+
+func newServerStreams() *serverStreams {
+	return &serverStreams{streams: make(map[uint64]*serverStream, 16)}
+}
+
+func (s *serverStreams) open(request *mpro.Message, conn net.Conn, writeTimeout time.Duration, extFactory motan.ExtensionFactory) *serverStream {
+	stream := &serverStream{
+		requestID:     request.Header.RequestID,
+		conn:          conn,
+		writeTimeout:  writeTimeout,
+		serialization: extFactory.GetSerialization("", request.Header.GetSerialize()),
+		recvCh:        make(chan *mpro.Message, defaultStreamRecvSize),
+		done:          make(chan struct{}),
+	}
+	s.lock.Lock()
+	s.streams[stream.requestID] = stream
+	s.lock.Unlock()
+	return stream
+}
+
+func (s *serverStreams) dispatch(msg *mpro.Message) {
+	s.lock.Lock()
+	stream := s.streams[msg.Header.RequestID]
+	s.lock.Unlock()
+	if stream == nil {
+		vlog.Warningf("stream frame of unknown stream: %d, remote:%s\n", msg.Header.RequestID, msg.Metadata.LoadOrEmpty(motan.HostKey))
+		return
+	}
+	// the stream fails instead of blocking the connection if the frames are not consumed in time
+	select {
+	case stream.recvCh <- msg:
+	case <-stream.done:
+	default:
+		vlog.Warningf("stream recv buffer is full, the stream is canceled. rid:%d, remote:%s\n", msg.Header.RequestID, msg.Metadata.LoadOrEmpty(motan.HostKey))
+		s.lock.Lock()
+		delete(s.streams, stream.requestID)
+		s.lock.Unlock()
+		go stream.fail(&motan.Exception{ErrCode: 503, ErrMsg: "stream recv buffer is full", ErrType: motan.ServiceException})
+	}
+}
+
+// cancel closes the stream with the request id, returns false if there is no such stream
+func (s *serverStreams) cancel(requestID uint64) bool {
+	s.lock.Lock()
+	stream := s.streams[requestID]
+	delete(s.streams, requestID)
+	s.lock.Unlock()
+	if stream == nil {
+		return false
+	}
+	stream.close()
+	return true
+}
+
+func (s *serverStreams) remove(stream *serverStream) {
+	s.lock.Lock()
+	delete(s.streams, stream.requestID)
+	s.lock.Unlock()
+	stream.close()
+}
+
+func (s *serverStreams) closeAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, stream := range s.streams {
+		stream.close()
+	}
+}
+
+// serverStream is the provider side of a streaming call
+type serverStream struct {
+	requestID     uint64
+	conn          net.Conn
+	writeTimeout  time.Duration
+	serialization motan.Serialization
+	recvCh        chan *mpro.Message
+	done          chan struct{}
+	closeOnce     sync.Once
+	endOnce       sync.Once
+}
+
+func (s *serverStream) Send(v interface{}) error {
+	if s.serialization == nil {
+		return mpro.ErrSerializeNil
+	}
+	body, err := s.serialization.Serialize(v)
+	if err != nil {
+		return err
+	}
+	return s.write(mpro.BuildStreamFrame(mpro.Res, s.serialization.GetSerialNum(), s.requestID, mpro.StreamData, body))
+}
+
+func (s *serverStream) Recv(v interface{}) error {
+	timer := time.NewTimer(defaultStreamRecvTimeout)
+	defer timer.Stop()
+	select {
+	case msg := <-s.recvCh:
+		if msg.GetStreamFrame() == mpro.StreamEnd {
+			return io.EOF
+		}
+		if msg.Header.IsGzip() {
+			msg.Body = mpro.DecodeGzipBody(msg.Body)
+		}
+		if s.serialization == nil {
+			return mpro.ErrSerializeNil
+		}
+		_, err := s.serialization.DeSerialize(msg.Body, v)
+		return err
+	case <-timer.C:
+		return errors.New("stream recv timeout")
+	case <-s.done:
+		return errStreamClosed
+	}
+}
+
+// CloseSend sends the end frame, the call is finished for the client after that
+func (s *serverStream) CloseSend() error {
+	return s.end(nil)
+}
+
+// Close ends the call with an exception if it has not been ended, the frames from the client are dropped after that
+func (s *serverStream) Close() error {
+	return s.fail(&motan.Exception{ErrCode: 500, ErrMsg: "stream closed by the provider", ErrType: motan.ServiceException})
+}
+
+// fail tells the client the call failed and closes the stream
+func (s *serverStream) fail(e *motan.Exception) error {
+	defer s.close()
+	return s.end(e)
+}
+
+func (s *serverStream) end(e *motan.Exception) (err error) {
+	s.endOnce.Do(func() {
+		var msg *mpro.Message
+		if e != nil {
+			msg = mpro.BuildExceptionResponse(s.requestID, mpro.ExceptionToJSON(e))
+			msg.Metadata.Store(mpro.MStream, mpro.StreamEnd)
+		} else {
+			serialNum := 0
+			if s.serialization != nil {
+				serialNum = s.serialization.GetSerialNum()
+			}
+			msg = mpro.BuildStreamFrame(mpro.Res, serialNum, s.requestID, mpro.StreamEnd, nil)
+		}
+		err = s.write(msg)
+	})
+	return err
+}
+
+func (s *serverStream) write(msg *mpro.Message) error {
+	select {
+	case <-s.done:
+		return errStreamClosed
+	default:
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	buf := msg.Encode()
+	_, err := s.conn.Write(buf.Bytes())
+	motan.ReleaseBytesBuffer(buf)
+	if err != nil {
+		vlog.Errorf("connection will close. conn: %s, err:%s\n", s.conn.RemoteAddr().String(), err.Error())
+		s.conn.Close()
+	}
+	return err
+}
+
+func (s *serverStream) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func (m *MotanServer) processStream(request *mpro.Message, stream *serverStream, streams *serverStreams) {
+	defer motan.HandlePanic(nil)
+	defer streams.remove(stream)
+	if m.proxy {
+		stream.end(&motan.Exception{ErrCode: 500, ErrMsg: "streaming call is not supported in proxy mode", ErrType: motan.ServiceException})
+		return
+	}
+	req, err := mpro.ConvertToRequest(request, stream.serialization)
+	if err != nil {
+		vlog.Errorf("motan server convert to motan stream request fail. rid :%d, service: %s, method:%s,err:%s\n", request.Header.RequestID, request.Metadata.LoadOrEmpty(mpro.MPath), request.Metadata.LoadOrEmpty(mpro.MMethod), err.Error())
+		stream.end(motan.NewSerializationException("deserialize fail. err:" + err.Error() + " method:" + request.Metadata.LoadOrEmpty(mpro.MMethod)))
+		return
+	}
+	rc := req.GetRPCContext(true)
+	rc.ExtFactory = m.extFactory
+	rc.StreamCall = true
+	rc.Stream = stream
+	mres := m.handler.Call(req)
+	if mres == nil {
+		stream.end(&motan.Exception{ErrCode: 500, ErrMsg: "handler call return nil", ErrType: motan.ServiceException})
+		return
+	}
+	stream.end(mres.GetException())
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/endpoint"
+	mpro "github.com/weibocom/motan-go/protocol"
+	"github.com/weibocom/motan-go/serialize"
+)
+
+type streamService struct{}
+
+func (s *streamService) Echo(prefix string, stream motan.Stream) error {
+	for {
+		var msg string
+		err := stream.Recv(&msg)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(prefix + msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Flood sends more frames than the client buffers
+func (s *streamService) Flood(prefix string, stream motan.Stream) error {
+	for i := 0; i < defaultStreamRecvSize*2; i++ {
+		if err := stream.Send(prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestStreamCall(t *testing.T) {
+	server, ep := startTestServer(t, "streamService", &streamService{}, nil, nil)
+	defer server.Destroy()
+	defer ep.Destroy()
+
+	request := &motan.MotanRequest{ServiceName: "streamService", Method: "echo", Arguments: []interface{}{"hi-"}, Attachment: motan.NewStringMap(0)}
+	request.GetRPCContext(true).StreamCall = true
+	res := ep.Call(request)
+	if res.GetException() != nil {
+		t.Fatalf("open stream fail. err:%v", res.GetException())
+	}
+	stream, ok := res.GetValue().(motan.Stream)
+	if !ok {
+		t.Fatalf("response value should be a stream. value:%v", res.GetValue())
+	}
+	for _, msg := range []string{"a", "b", "c"} {
+		if err := stream.Send(msg); err != nil {
+			t.Fatalf("send fail. err:%v", err)
+		}
+		var reply string
+		if err := stream.Recv(&reply); err != nil || reply != "hi-"+msg {
+			t.Fatalf("recv fail. reply:%s, err:%v", reply, err)
+		}
+	}
+	stream.CloseSend()
+	var reply string
+	if err := stream.Recv(&reply); err != io.EOF {
+		t.Errorf("stream should be finished. reply:%s, err:%v", reply, err)
+	}
+
+	// the slow stream fails without blocking the other calls of the connection
+	request = &motan.MotanRequest{ServiceName: "streamService", Method: "flood", Arguments: []interface{}{"x"}, Attachment: motan.NewStringMap(0)}
+	request.GetRPCContext(true).StreamCall = true
+	flood := ep.Call(request).GetValue().(motan.Stream)
+	time.Sleep(100 * time.Millisecond)
+	var err error
+	for i := 0; i < defaultStreamRecvSize*2 && err == nil; i++ {
+		err = flood.Recv(&reply)
+	}
+	if err != endpoint.ErrStreamBufferFull {
+		t.Errorf("slow stream should fail. err:%v", err)
+	}
+	request = &motan.MotanRequest{ServiceName: "streamService", Method: "echo", Arguments: []interface{}{"hi-"}, Attachment: motan.NewStringMap(0)}
+	request.GetRPCContext(true).StreamCall = true
+	stream = ep.Call(request).GetValue().(motan.Stream)
+	if err = stream.Send("d"); err != nil {
+		t.Fatalf("send fail. err:%v", err)
+	}
+	if err = stream.Recv(&reply); err != nil || reply != "hi-d" {
+		t.Errorf("recv fail. reply:%s, err:%v", reply, err)
+	}
+	stream.Close()
+	if err = stream.Send("e"); err != endpoint.ErrStreamClosed {
+		t.Errorf("closed stream should not send. err:%v", err)
+	}
+}
+
+func TestStreamDispatchFull(t *testing.T) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	serialize.RegistDefaultSerializations(ext)
+	local, remote := net.Pipe()
+	defer local.Close()
+	go io.Copy(ioutil.Discard, remote)
+
+	streams := newServerStreams()
+	stream := streams.open(mpro.BuildStreamFrame(mpro.Req, 6, 1, mpro.StreamOpen, nil), local, time.Second, ext)
+	dispatched := make(chan struct{})
+	go func() {
+		for i := 0; i <= defaultStreamRecvSize; i++ {
+			streams.dispatch(mpro.BuildStreamFrame(mpro.Req, 6, 1, mpro.StreamData, nil))
+		}
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch should not block on a slow stream")
+	}
+	select {
+	case <-stream.done:
+	case <-time.After(time.Second):
+		t.Fatal("slow stream should be closed")
+	}
+	if streams.cancel(1) {
+		t.Error("slow stream should be removed")
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mpro "github.com/weibocom/motan-go/protocol"
+	"github.com/weibocom/motan-go/transport"
+	"golang.org/x/net/websocket"
+)
+
+// websocket paths
+const (
+	WebSocketMotan2Path = "/motan2"
+	WebSocketJSONPath   = "/json"
+	// comma separated origins(e.g. https://example.com) of the web pages can connect, * allows all.
+	// only the same origin and the clients without Origin header(not a browser) are allowed by default
+	WebSocketOriginsKey = "wsAllowedOrigins"
+)
+
+// WebSocketServer lets web clients call motan services through websocket.
+// every binary message on WebSocketMotan2Path is a motan2 frame which is handled the same as the motan server,
+// every text message on WebSocketJSONPath is a JSONCall and answered by a JSONResult.
+type WebSocketServer struct {
+	URL        *motan.URL
+	handler    motan.MessageHandler
+	listener   net.Listener
+	extFactory motan.ExtensionFactory
+	proxy      bool
+	motan      *MotanServer
+	requestID  uint64
+	origins    map[string]bool
+}
+
+// JSONCall is the call of a json websocket message. the service, method and group are stored in the attachments.
+type JSONCall struct {
+	RequestID   uint64            `json:"requestId"`
+	Service     string            `json:"service"`
+	Method      string            `json:"method"`
+	MethodDesc  string            `json:"methodDesc,omitempty"`
+	Group       string            `json:"group,omitempty"`
+	Attachments map[string]string `json:"attachments,omitempty"`
+	Arguments   []interface{}     `json:"arguments,omitempty"`
+}
+
+// JSONResult is the response of a JSONCall with the same request id
+type JSONResult struct {
+	RequestID   uint64            `json:"requestId"`
+	Value       interface{}       `json:"value,omitempty"`
+	Exception   *motan.Exception  `json:"exception,omitempty"`
+	Attachments map[string]string `json:"attachments,omitempty"`
+}
+
+func (w *WebSocketServer) Open(block bool, proxy bool, handler motan.MessageHandler, extFactory motan.ExtensionFactory) error {
+	lis, err := transport.ListenExt(w.URL, extFactory)
+	if err != nil {
+		vlog.Errorf("listen websocket port:%d fail. err: %v\n", w.URL.Port, err)
+		return err
+	}
+	w.listener = lis
+	w.handler = handler
+	w.extFactory = extFactory
+	w.proxy = proxy
+	w.motan = &MotanServer{URL: w.URL, handler: handler, extFactory: extFactory, proxy: proxy}
+	w.motan.initConnOptions()
+	w.origins = make(map[string]bool)
+	for _, origin := range motan.TrimSplit(w.URL.GetParam(WebSocketOriginsKey, ""), ",") {
+		if origin != "" {
+			w.origins[strings.TrimSuffix(origin, "/")] = true
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle(WebSocketMotan2Path, websocket.Server{Handler: w.serveMotan2, Handshake: w.checkOrigin})
+	mux.Handle(WebSocketJSONPath, websocket.Server{Handler: w.serveJSON, Handshake: w.checkOrigin})
+	vlog.Infof("websocket server is started. port:%d\n", w.URL.Port)
+	if block {
+		return http.Serve(lis, mux)
+	}
+	go http.Serve(lis, mux)
+	return nil
+}
+
+// checkOrigin denies the cross-origin requests of the web pages not in the allowed origins
+func (w *WebSocketServer) checkOrigin(config *websocket.Config, req *http.Request) (err error) {
+	if config.Origin, err = websocket.Origin(config, req); err != nil {
+		return err
+	}
+	if config.Origin == nil || config.Origin.Host == req.Host || w.origins["*"] || w.origins[config.Origin.Scheme+"://"+config.Origin.Host] {
+		return nil
+	}
+	vlog.Warningf("websocket origin not allowed. origin:%s, remote:%s\n", config.Origin.String(), req.RemoteAddr)
+	return errors.New("origin not allowed: " + config.Origin.String())
+}
+
+func (w *WebSocketServer) GetMessageHandler() motan.MessageHandler {
+	return w.handler
+}
+
+func (w *WebSocketServer) SetMessageHandler(mh motan.MessageHandler) {
+	w.handler = mh
+}
+
+func (w *WebSocketServer) GetURL() *motan.URL {
+	return w.URL
+}
+
+func (w *WebSocketServer) SetURL(url *motan.URL) {
+	w.URL = url
+}
+
+func (w *WebSocketServer) GetName() string {
+	return WebSocket
+}
+
+func (w *WebSocketServer) Destroy() {
+	err := w.listener.Close()
+	if err != nil {
+		vlog.Errorf("websocket server destroy fail.url %v, err :%s\n", w.URL, err.Error())
+	} else {
+		vlog.Infof("websocket server destroy sucess.url %v\n", w.URL)
+	}
+}
+
+// wsConn is the websocket connection with the address of the web client
+type wsConn struct {
+	*websocket.Conn
+	remote net.Addr
+}
+
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func remoteAddr(ws *websocket.Conn) net.Addr {
+	if addr, err := net.ResolveTCPAddr("tcp", ws.Request().RemoteAddr); err == nil {
+		return addr
+	}
+	return ws.RemoteAddr()
+}
+
+func (w *WebSocketServer) serveMotan2(ws *websocket.Conn) {
+	// the messages are read as a stream of motan2 frames, and every response frame is sent in one binary message
+	ws.PayloadType = websocket.BinaryFrame
+	w.motan.handleConn(&wsConn{Conn: ws, remote: remoteAddr(ws)})
+}
+
+func (w *WebSocketServer) serveJSON(ws *websocket.Conn) {
+	defer ws.Close()
+	defer motan.HandlePanic(nil)
+	ip := getRemoteIP(ws.Request().RemoteAddr)
+	for {
+		var call JSONCall
+		if err := websocket.JSON.Receive(ws, &call); err != nil {
+			if err.Error() != "EOF" {
+				vlog.Warningf("receive websocket json call fail! remote:%s, err:%s\n", ip, err.Error())
+			}
+			return
+		}
+		go w.processJSON(ws, &call, ip)
+	}
+}
+
+func (w *WebSocketServer) processJSON(ws *websocket.Conn, call *JSONCall, ip string) {
+	defer motan.HandlePanic(nil)
+	// request ids of web clients are only unique in the connection
+	result := callJSON(w.handler, call, atomic.AddUint64(&w.requestID, 1), ip)
+	if err := websocket.JSON.Send(ws, result); err != nil {
+		vlog.Warningf("send websocket json result fail! rid:%d, remote:%s, err:%s\n", call.RequestID, ip, err.Error())
+		if result.Value != nil {
+			// the value can not be encoded in json
+			result.Value = nil
+			result.Exception = &motan.Exception{ErrCode: 500, ErrMsg: "encode json result fail. err:" + err.Error(), ErrType: motan.ServiceException}
+			websocket.JSON.Send(ws, result)
+		}
+	}
+}
+
+// callJSON calls the handler by the JSONCall from the web client at ip, the result has the request id of the call
+func callJSON(handler motan.MessageHandler, call *JSONCall, requestID uint64, ip string) *JSONResult {
+	request := &motan.MotanRequest{
+		RequestID:   requestID,
+		ServiceName: call.Service,
+		Method:      call.Method,
+		MethodDesc:  call.MethodDesc,
+		Arguments:   call.Arguments,
+		Attachment:  motan.NewStringMap(motan.DefaultAttachmentSize),
+	}
+	for k, v := range call.Attachments {
+		request.SetAttachment(k, v)
+	}
+	request.SetAttachment(mpro.MPath, call.Service)
+	request.SetAttachment(mpro.MMethod, call.Method)
+	if call.MethodDesc != "" {
+		request.SetAttachment(mpro.MMethodDesc, call.MethodDesc)
+	}
+	if call.Group != "" {
+		request.SetAttachment(mpro.MGroup, call.Group)
+	}
+	if request.GetAttachment(mpro.MProxyProtocol) == "" {
+		request.SetAttachment(mpro.MProxyProtocol, Motan2)
+	}
+	request.SetAttachment(motan.HostKey, ip)
+
+	result := &JSONResult{RequestID: call.RequestID}
+	res := callHandler(handler, request)
+	if res == nil {
+		result.Exception = &motan.Exception{ErrCode: 500, ErrMsg: "handler call return nil", ErrType: motan.ServiceException}
+	} else if res.GetException() != nil {
+		result.Exception = res.GetException()
+	} else if err := res.ProcessDeserializable(nil); err != nil {
+		result.Exception = &motan.Exception{ErrCode: 500, ErrMsg: "deserialize response fail. err:" + err.Error(), ErrType: motan.ServiceException}
+	} else if rv, ok := res.GetValue().(reflect.Value); ok {
+		// the value returned by DefaultProvider
+		result.Value = rv.Interface()
+	} else {
+		result.Value = res.GetValue()
+	}
+	if res != nil && res.GetAttachments() != nil {
+		result.Attachments = res.GetAttachments().RawMap()
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bufio"
+	"strconv"
+	"testing"
+
+	motan "github.com/weibocom/motan-go/core"
+	mpro "github.com/weibocom/motan-go/protocol"
+	"github.com/weibocom/motan-go/serialize"
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketServer(t *testing.T) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	serialize.RegistDefaultSerializations(ext)
+	server := &WebSocketServer{URL: &motan.URL{Port: freePort(t), Parameters: map[string]string{WebSocketOriginsKey: "http://127.0.0.1, https://example.com"}}}
+	if err := server.Open(false, false, newTestHandler("echoService", &echoService{}, nil), ext); err != nil {
+		t.Fatalf("open websocket server fail. err:%v", err)
+	}
+	defer server.Destroy()
+	addr := "127.0.0.1:" + strconv.Itoa(server.URL.Port)
+
+	// cross-origin pages not allowed
+	if ws, err := websocket.Dial("ws://"+addr+WebSocketJSONPath, "", "http://evil.com/"); err == nil {
+		ws.Close()
+		t.Error("cross-origin websocket should be denied")
+	}
+	if ws, err := websocket.Dial("ws://"+addr+WebSocketJSONPath, "", "http://"+addr+"/"); err != nil {
+		t.Errorf("same origin websocket should be allowed. err:%v", err)
+	} else {
+		ws.Close()
+	}
+
+	// json
+	ws, err := websocket.Dial("ws://"+addr+WebSocketJSONPath, "", "http://127.0.0.1/")
+	if err != nil {
+		t.Fatalf("dial websocket fail. err:%v", err)
+	}
+	defer ws.Close()
+	if err = websocket.JSON.Send(ws, &JSONCall{RequestID: 7, Service: "echoService", Method: "echo", Arguments: []interface{}{"hello"}}); err != nil {
+		t.Fatalf("send json call fail. err:%v", err)
+	}
+	var result JSONResult
+	if err = websocket.JSON.Receive(ws, &result); err != nil {
+		t.Fatalf("receive json result fail. err:%v", err)
+	}
+	if result.RequestID != 7 || result.Exception != nil || result.Value != "hello" {
+		t.Errorf("json result not correct. result:%+v", result)
+	}
+	websocket.JSON.Send(ws, &JSONCall{RequestID: 8, Service: "unknown", Method: "echo"})
+	if err = websocket.JSON.Receive(ws, &result); err != nil || result.RequestID != 8 || result.Exception == nil {
+		t.Errorf("json call of unknown service should fail. result:%+v, err:%v", result, err)
+	}
+
+	// motan2 frames
+	bws, err := websocket.Dial("ws://"+addr+WebSocketMotan2Path, "", "http://127.0.0.1/")
+	if err != nil {
+		t.Fatalf("dial websocket fail. err:%v", err)
+	}
+	defer bws.Close()
+	bws.PayloadType = websocket.BinaryFrame
+	s := &serialize.SimpleSerialization{}
+	request := &motan.MotanRequest{RequestID: 9, ServiceName: "echoService", Method: "echo", Arguments: []interface{}{"motan2"}, Attachment: motan.NewStringMap(0)}
+	msg, err := mpro.ConvertToReqMessage(request, s)
+	if err != nil {
+		t.Fatalf("convert request fail. err:%v", err)
+	}
+	if _, err = bws.Write(msg.Encode().Bytes()); err != nil {
+		t.Fatalf("write motan2 frame fail. err:%v", err)
+	}
+	resMsg, err := mpro.Decode(bufio.NewReader(bws))
+	if err != nil {
+		t.Fatalf("decode motan2 frame fail. err:%v", err)
+	}
+	res, err := mpro.ConvertToResponse(resMsg, s)
+	if err != nil || res.GetRequestID() != 9 {
+		t.Fatalf("convert response fail. err:%v", err)
+	}
+	var reply string
+	if err = res.ProcessDeserializable(&reply); err != nil || reply != "motan2" {
+		t.Errorf("motan2 response not correct. reply:%s, err:%v", reply, err)
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+const (
+	defaultWorkerQueueSize = 1024
+	defaultWorkerKeepAlive = 60 * time.Second
+	// the retry-after hint of the requests rejected by the full pools
+	busyRetryAfter = 100 * time.Millisecond
+)
+
+type workerTask struct {
+	run func()
+	// called instead of run if the task waited in the queue longer than the queue timeout
+	reject func()
+	queued time.Time
+}
+
+// workerPool processes the requests of a service with bounded goroutines and a bounded queue,
+// so a slow service can not exhaust the goroutines and the memory of the whole server.
+// the workers more than min exit after keeping idle for keepAlive.
+type workerPool struct {
+	min          int32
+	max          int32
+	queue        chan *workerTask
+	queueTimeout time.Duration
+	keepAlive    time.Duration
+	workers      int32
+	idle         int32
+}
+
+// newWorkerPool creates the worker pool with the params of the service url, nil if maxWorkers is not set
+func newWorkerPool(url *motan.URL) *workerPool {
+	max := url.GetPositiveIntValue(motan.MaxWorkersKey, 0)
+	if max <= 0 {
+		return nil
+	}
+	min := url.GetIntValue(motan.MinWorkersKey, 0)
+	if min < 0 {
+		min = 0
+	} else if min > max {
+		min = max
+	}
+	queueSize := url.GetIntValue(motan.WorkerQueueSizeKey, defaultWorkerQueueSize)
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	return &workerPool{
+		min:          int32(min),
+		max:          int32(max),
+		queue:        make(chan *workerTask, queueSize),
+		queueTimeout: url.GetTimeDuration(motan.WorkerQueueTimeoutKey, time.Millisecond, 0),
+		keepAlive:    defaultWorkerKeepAlive,
+	}
+}
+
+// submit runs the task by an idle worker or a new worker, or queues it if the pool is full.
+// false is returned if the queue is full too, reject is never called in that case.
+func (p *workerPool) submit(run func(), reject func()) bool {
+	task := &workerTask{run: run, reject: reject}
+	if atomic.LoadInt32(&p.idle) == 0 && p.startWorker(task) {
+		return true
+	}
+	if p.queueTimeout > 0 {
+		task.queued = time.Now()
+	}
+	select {
+	case p.queue <- task:
+		return true
+	default:
+	}
+	// all workers may be busy after the idle check
+	return p.startWorker(task)
+}
+
+func (p *workerPool) startWorker(first *workerTask) bool {
+	for {
+		n := atomic.LoadInt32(&p.workers)
+		if n >= p.max {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&p.workers, n, n+1) {
+			break
+		}
+	}
+	go p.work(first)
+	return true
+}
+
+func (p *workerPool) work(task *workerTask) {
+	timer := time.NewTimer(p.keepAlive)
+	defer timer.Stop()
+	for {
+		if task != nil {
+			p.runTask(task)
+		}
+		atomic.AddInt32(&p.idle, 1)
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(p.keepAlive)
+		select {
+		case task = <-p.queue:
+			atomic.AddInt32(&p.idle, -1)
+		case <-timer.C:
+			atomic.AddInt32(&p.idle, -1)
+			if p.exitIdle() {
+				// a task may be queued just before exiting
+				if len(p.queue) > 0 {
+					p.startWorker(nil)
+				}
+				return
+			}
+			task = nil
+		}
+	}
+}
+
+// exitIdle reduces the workers if there are more than min
+func (p *workerPool) exitIdle() bool {
+	for {
+		n := atomic.LoadInt32(&p.workers)
+		if n <= p.min {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&p.workers, n, n-1) {
+			return true
+		}
+	}
+}
+
+func (p *workerPool) runTask(task *workerTask) {
+	defer motan.HandlePanic(nil)
+	if p.queueTimeout > 0 && !task.queued.IsZero() && time.Since(task.queued) > p.queueTimeout {
+		task.reject()
+		return
+	}
+	task.run()
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func TestNewWorkerPool(t *testing.T) {
+	if newWorkerPool(&motan.URL{}) != nil {
+		t.Errorf("worker pool should not be created without maxWorkers")
+	}
+	pool := newWorkerPool(&motan.URL{Parameters: map[string]string{motan.MaxWorkersKey: "4", motan.MinWorkersKey: "8", motan.WorkerQueueTimeoutKey: "50"}})
+	if pool.max != 4 || pool.min != 4 || cap(pool.queue) != defaultWorkerQueueSize || pool.queueTimeout != 50*time.Millisecond {
+		t.Errorf("wrong worker pool params. pool:%+v", pool)
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	pool := newWorkerPool(&motan.URL{Parameters: map[string]string{motan.MaxWorkersKey: "2", motan.WorkerQueueSizeKey: "2"}})
+	block := make(chan struct{})
+	var ran, rejected int32
+	var wg sync.WaitGroup
+	run := func() {
+		<-block
+		atomic.AddInt32(&ran, 1)
+		wg.Done()
+	}
+	reject := func() {
+		atomic.AddInt32(&rejected, 1)
+		wg.Done()
+	}
+	// 2 workers and 2 queued
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		if !pool.submit(run, reject) {
+			t.Fatalf("task %d should be accepted", i)
+		}
+	}
+	if pool.submit(run, reject) {
+		t.Errorf("task should be rejected if the queue is full")
+	}
+	if atomic.LoadInt32(&pool.workers) != 2 {
+		t.Errorf("workers should not be more than max. workers:%d", pool.workers)
+	}
+	close(block)
+	wg.Wait()
+	if ran != 4 || rejected != 0 {
+		t.Errorf("wrong tasks processed. ran:%d, rejected:%d", ran, rejected)
+	}
+
+	// idle workers are reused
+	wg.Add(1)
+	pool.submit(run, reject)
+	wg.Wait()
+	if atomic.LoadInt32(&pool.workers) != 2 {
+		t.Errorf("idle workers should be reused. workers:%d", pool.workers)
+	}
+}
+
+func TestWorkerPoolQueueTimeout(t *testing.T) {
+	pool := newWorkerPool(&motan.URL{Parameters: map[string]string{motan.MaxWorkersKey: "1", motan.WorkerQueueTimeoutKey: "20"}})
+	var ran, rejected int32
+	var wg sync.WaitGroup
+	wg.Add(2)
+	pool.submit(func() {
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&ran, 1)
+		wg.Done()
+	}, nil)
+	pool.submit(func() {
+		atomic.AddInt32(&ran, 1)
+		wg.Done()
+	}, func() {
+		atomic.AddInt32(&rejected, 1)
+		wg.Done()
+	})
+	wg.Wait()
+	if ran != 1 || rejected != 1 {
+		t.Errorf("task queued longer than the queue timeout should be rejected. ran:%d, rejected:%d", ran, rejected)
+	}
+}
+
+func TestWorkerPoolKeepAlive(t *testing.T) {
+	pool := newWorkerPool(&motan.URL{Parameters: map[string]string{motan.MaxWorkersKey: "3", motan.MinWorkersKey: "1"}})
+	pool.keepAlive = 20 * time.Millisecond
+	block := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		pool.submit(func() { <-block }, nil)
+	}
+	close(block)
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&pool.workers); n != 1 {
+		t.Errorf("idle workers more than min should exit. workers:%d", n)
+	}
+	done := make(chan struct{})
+	pool.submit(func() { close(done) }, nil)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("task not processed after idle workers exit")
+	}
+}
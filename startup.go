@@ -0,0 +1,120 @@
+package motan
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	mserver "github.com/weibocom/motan-go/server"
+)
+
+// the keys of motan-agent section for the startup staging
+const (
+	startupMinEndpointsKey  = "startup_min_endpoints"  // the min available endpoints of every cluster to finish warming, 0 means not waiting
+	startupWarmupTimeoutKey = "startup_warmup_timeout" // seconds, the listeners are opened after the timeout even if the clusters are not warmed
+
+	defaultStartupWarmupTimeout = 30 * time.Second
+	startupCheckInterval        = 100 * time.Millisecond
+	startupHealthCheck          = "agent_startup"
+)
+
+// the stages of the agent startup, in order
+const (
+	startupStageRegistries = "registries" // connecting the registries
+	startupStageWarming    = "warming"    // waiting for the endpoints of the clusters
+	startupStageListening  = "listening"  // opening the listeners
+	startupStageReady      = "ready"
+)
+
+// initStartupGate registers the health check which fails until the startup is finished,
+// so /health/ready responds 503 while the clusters may be empty
+func (a *Agent) initStartupGate() {
+	a.setStartupStage(startupStageRegistries)
+	mserver.RegisterHealthCheck(startupHealthCheck, func() error {
+		if stage := a.getStartupStage(); stage != startupStageReady {
+			return fmt.Errorf("agent is starting, stage: %s", stage)
+		}
+		return nil
+	})
+}
+
+func (a *Agent) setStartupStage(stage string) {
+	a.startupStage.Store(stage)
+	vlog.Infof("agent startup stage: %s\n", stage)
+}
+
+func (a *Agent) getStartupStage() string {
+	stage, _ := a.startupStage.Load().(string)
+	return stage
+}
+
+// initRegistries connects the registries used by the refers, the services and the agent before the clusters are created
+func (a *Agent) initRegistries() {
+	ids := make(map[string]struct{})
+	for _, urls := range []map[string]*motan.URL{a.GetContext().RefersURLs, a.GetContext().ServiceURLs, {"agent": a.agentURL}} {
+		for _, url := range urls {
+			for _, id := range motan.TrimSplit(url.GetParam(motan.RegistryKey, ""), ",") {
+				ids[id] = struct{}{}
+			}
+		}
+	}
+	for id := range ids {
+		registryURL, ok := a.GetContext().RegistryURLs[id]
+		if !ok {
+			continue
+		}
+		start := time.Now()
+		if a.extFactory.GetRegistry(registryURL) == nil {
+			vlog.Errorf("connect registry fail. registry:%s\n", id)
+			continue
+		}
+		vlog.Infof("registry connected. registry:%s, cost:%v\n", id, time.Since(start))
+	}
+}
+
+// warmupClusters waits until every cluster has startup_min_endpoints available endpoints, or the warmup timeout
+func (a *Agent) warmupClusters() {
+	section, _ := a.GetContext().Config.GetSection("motan-agent")
+	minEndpoints, _ := section[startupMinEndpointsKey].(int)
+	if minEndpoints <= 0 {
+		return
+	}
+	timeout := defaultStartupWarmupTimeout
+	if n, ok := section[startupWarmupTimeoutKey].(int); ok && n > 0 {
+		timeout = time.Duration(n) * time.Second
+	}
+	a.setStartupStage(startupStageWarming)
+	deadline := time.Now().Add(timeout)
+	for {
+		cold := a.coldClusters(minEndpoints)
+		if len(cold) == 0 {
+			vlog.Infof("clusters warmed. min endpoints:%d\n", minEndpoints)
+			return
+		}
+		if time.Now().After(deadline) {
+			vlog.Warningf("clusters warmup timeout, the listeners are opened anyway. timeout:%v, cold clusters:%v\n", timeout, cold)
+			return
+		}
+		time.Sleep(startupCheckInterval)
+	}
+}
+
+// coldClusters returns the keys of the clusters with less than min available endpoints
+func (a *Agent) coldClusters(min int) []string {
+	cold := make([]string, 0)
+	a.clustermap.Range(func(k, v interface{}) bool {
+		available := 0
+		for _, ep := range v.(*cluster.MotanCluster).GetRefers() {
+			if ep.IsAvailable() {
+				available++
+			}
+		}
+		if available < min {
+			cold = append(cold, k.(string))
+		}
+		return true
+	})
+	return cold
+}
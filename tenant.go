@@ -0,0 +1,118 @@
+package motan
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/juju/ratelimit"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+	"github.com/weibocom/motan-go/metrics"
+	mserver "github.com/weibocom/motan-go/server"
+)
+
+const (
+	tenantSection     = "motan-tenant"
+	tenantKeySplitter = "@"
+	tenantMetricGroup = "motan-agent"
+	tenantMetricKey   = "motan-agent-tenant"
+)
+
+var (
+	errTenantQPS         = errors.New("tenant qps quota exceeded")
+	errTenantConcurrency = errors.New("tenant concurrency quota exceeded")
+)
+
+// tenant is an application sharing the agent, identified by the source application of the requests or the
+// listening port of the tenant. the refers with the tenant param are in the namespace of the tenant, the requests
+// of the tenant find the clusters in its namespace first
+type tenant struct {
+	name          string
+	port          int
+	filter        string // the filters of the refers in the namespace without filters
+	maxConns      int    // connections of the tenant port
+	bucket        *ratelimit.Bucket
+	maxConcurrent int64
+	concurrent    int64
+}
+
+// initTenants parses the tenants of the motan-tenant section, see main/agentdemo.yaml
+func (a *Agent) initTenants() {
+	a.tenants = make(map[string]*tenant)
+	section, err := a.GetContext().Config.GetSection(tenantSection)
+	if err != nil {
+		return
+	}
+	for k, v := range section {
+		conf, _ := v.(map[interface{}]interface{})
+		t := &tenant{name: motan.InterfaceToString(k)}
+		t.port, _ = conf["port"].(int)
+		t.filter, _ = conf["filter"].(string)
+		t.maxConns, _ = conf["maxConnections"].(int)
+		if qps, err := strconv.ParseFloat(motan.InterfaceToString(conf["maxQPS"]), 64); err == nil && qps > 0 {
+			// bursts up to the requests of a second
+			t.bucket = ratelimit.NewBucketWithRate(qps, int64(math.Ceil(qps)))
+		}
+		if n, ok := conf["maxConcurrent"].(int); ok {
+			t.maxConcurrent = int64(n)
+		}
+		a.tenants[t.name] = t
+		vlog.Infof("agent tenant inited. tenant:%+v\n", t)
+	}
+}
+
+// startTenantAgents listens the ports of the tenants, the requests received are of the tenants
+func (a *Agent) startTenantAgents() {
+	for _, t := range a.tenants {
+		if t.port == 0 {
+			continue
+		}
+		url := &motan.URL{Port: t.port, Parameters: map[string]string{}}
+		if t.maxConns > 0 {
+			url.PutParam(motan.MaxConnectionsKey, strconv.Itoa(t.maxConns))
+		}
+		handler := &agentMessageHandler{agent: a, tenant: t}
+		server := &mserver.MotanServer{URL: url}
+		if err := server.Open(false, true, handler, a.extFactory); err != nil {
+			vlog.Errorf("start tenant agent fail. tenant:%s, port:%d, err:%v\n", t.name, t.port, err)
+			continue
+		}
+		a.tenantServers = append(a.tenantServers, server)
+		vlog.Infof("Motan tenant agent is started. tenant:%s, port:%d\n", t.name, t.port)
+	}
+}
+
+// clusterKey returns the cluster key of the refer, in the namespace of the tenant if set
+func clusterKey(url *motan.URL) string {
+	ck := getClusterKey(url.Group, url.GetStringParamsWithDefault(motan.VersionKey, "0.1"), url.Protocol, url.Path)
+	if t := url.GetParam(motan.TenantKey, ""); t != "" {
+		return t + tenantKeySplitter + ck
+	}
+	return ck
+}
+
+// acquire checks the quotas of the tenant, release must be called if it succeeds
+func (t *tenant) acquire() error {
+	if t.bucket != nil && t.bucket.TakeAvailable(1) == 0 {
+		return errTenantQPS
+	}
+	if t.maxConcurrent > 0 {
+		if atomic.AddInt64(&t.concurrent, 1) > t.maxConcurrent {
+			atomic.AddInt64(&t.concurrent, -1)
+			return errTenantConcurrency
+		}
+	}
+	return nil
+}
+
+func (t *tenant) release() {
+	if t.maxConcurrent > 0 {
+		atomic.AddInt64(&t.concurrent, -1)
+	}
+}
+
+func (t *tenant) metric(name string) {
+	metrics.AddCounter(tenantMetricGroup, t.name, tenantMetricKey+"."+name, 1)
+}
@@ -0,0 +1,274 @@
+package tracing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// the exporters of the tracing section
+const (
+	Zipkin = "zipkin"
+	Jaeger = "jaeger"
+)
+
+const (
+	defaultBatchSize   = 100
+	defaultInterval    = time.Second
+	defaultQueueSize   = 1000
+	sendRequestTimeout = 5 * time.Second
+
+	spanKindServer = "server"
+)
+
+var (
+	start sync.Once
+)
+
+// Config is the tracing section of the config
+type Config struct {
+	Exporter    string            // zipkin or jaeger
+	Endpoint    string            // e.g. http://localhost:9411/api/v2/spans of zipkin, http://localhost:14268/api/traces of jaeger
+	SampleRate  float64           // the rate in [0, 1] of the requests traced, the trace policy is not changed if not set
+	ServiceName string            // the application if not set
+	BatchSize   int               // max spans sent in a request
+	Interval    int               // milliseconds, max time the spans wait to be sent
+	QueueSize   int               // max traces waiting to be sent, the traces are dropped if the queue is full
+	Headers     map[string]string // e.g. the auth headers of the collector
+}
+
+// Start exports the finished trace contexts to the tracing system configured by the tracing section,
+// and traces the requests by the sample rate
+func Start(ctx *motan.Context) {
+	start.Do(func() {
+		if ctx.Config == nil {
+			return
+		}
+		if _, err := ctx.Config.GetSection("tracing"); err != nil {
+			return
+		}
+		var conf Config
+		if err := ctx.Config.GetStruct("tracing", &conf); err != nil {
+			vlog.Warningf("get tracing config fail. %s\n", err.Error())
+			return
+		}
+		if conf.ServiceName == "" {
+			for _, url := range []*motan.URL{ctx.AgentURL, ctx.ServerURL, ctx.ClientURL} {
+				if url != nil && url.GetParam(motan.ApplicationKey, "") != "" {
+					conf.ServiceName = url.GetParam(motan.ApplicationKey, "")
+					break
+				}
+			}
+		}
+		exporter, err := NewExporter(&conf)
+		if err != nil {
+			vlog.Warningf("create tracing exporter fail. %s\n", err.Error())
+			return
+		}
+		motan.SetTraceExporter(exporter)
+		if conf.SampleRate > 0 {
+			motan.TracePolicy = motan.SampleTrace(conf.SampleRate)
+		}
+		vlog.Infof("export traces to %s %s, sample rate:%v\n", conf.Exporter, conf.Endpoint, conf.SampleRate)
+	})
+}
+
+// NewExporter returns the exporter which converts the trace contexts to the spans of zipkin or jaeger, and sends
+// them in batches in background
+func NewExporter(conf *Config) (motan.TraceExporter, error) {
+	if conf.Endpoint == "" {
+		return nil, errors.New("tracing endpoint is empty")
+	}
+	var s sender
+	switch conf.Exporter {
+	case Zipkin:
+		s = &zipkinSender{serviceName: conf.ServiceName}
+	case Jaeger:
+		s = &jaegerSender{serviceName: conf.ServiceName}
+	default:
+		return nil, errors.New("unknown tracing exporter: " + conf.Exporter)
+	}
+	e := &exporter{
+		sender:    s,
+		endpoint:  conf.Endpoint,
+		headers:   conf.Headers,
+		client:    &http.Client{Timeout: sendRequestTimeout},
+		batchSize: defaultBatchSize,
+		interval:  defaultInterval,
+		queue:     make(chan []*span, defaultQueueSize),
+	}
+	if conf.BatchSize > 0 {
+		e.batchSize = conf.BatchSize
+	}
+	if conf.Interval > 0 {
+		e.interval = time.Duration(conf.Interval) * time.Millisecond
+	}
+	if conf.QueueSize > 0 {
+		e.queue = make(chan []*span, conf.QueueSize)
+	}
+	go e.run()
+	return e, nil
+}
+
+// span is a span converted from a trace context, the times are microseconds
+type span struct {
+	traceIDHigh uint64
+	traceIDLow  uint64
+	id          uint64
+	parentID    uint64
+	name        string
+	kind        string
+	start       int64
+	duration    int64
+	tags        map[string]string
+}
+
+type sender interface {
+	contentType() string
+	encode(spans []*span) ([]byte, error)
+}
+
+type exporter struct {
+	sender    sender
+	endpoint  string
+	headers   map[string]string
+	client    *http.Client
+	batchSize int
+	interval  time.Duration
+	queue     chan []*span
+	dropped   int64
+}
+
+// Export converts the trace context at once as the spans may be changed after the request finished
+func (e *exporter) Export(tc *motan.TraceContext) {
+	spans := convert(tc)
+	if len(spans) == 0 {
+		return
+	}
+	select {
+	case e.queue <- spans:
+	default:
+		if atomic.AddInt64(&e.dropped, 1)%1000 == 1 {
+			vlog.Warningf("tracing queue is full, traces dropped:%d\n", atomic.LoadInt64(&e.dropped))
+		}
+	}
+}
+
+func (e *exporter) run() {
+	defer motan.HandlePanic(nil)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	batch := make([]*span, 0, e.batchSize)
+	for {
+		select {
+		case spans := <-e.queue:
+			batch = append(batch, spans...)
+			if len(batch) < e.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := e.send(batch); err != nil {
+			vlog.Warningf("send spans to %s fail. spans:%d, err:%v\n", e.endpoint, len(batch), err)
+		}
+		batch = make([]*span, 0, e.batchSize)
+	}
+}
+
+func (e *exporter) send(spans []*span) error {
+	body, err := e.sender.encode(spans)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", e.sender.contentType())
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("tracing endpoint responds %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
+
+// convert returns the server span of the request, and a child span for each span point of the trace context,
+// which lasts from the previous point, e.g. the span decode is the time decoding the request after received
+func convert(tc *motan.TraceContext) []*span {
+	reqSpans, resSpans := tc.Spans()
+	points := append(reqSpans, resSpans...)
+	if len(points) == 0 {
+		return nil
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	first, last := points[0].Time, points[len(points)-1].Time
+
+	service, _ := tc.Values[motan.TraceServiceKey].(string)
+	method, _ := tc.Values[motan.TraceMethodKey].(string)
+	group, _ := tc.Values[motan.TraceGroupKey].(string)
+	name := "motan.request"
+	if service != "" {
+		name = service + "." + method
+	}
+	root := &span{
+		traceIDHigh: newID(),
+		traceIDLow:  newID(),
+		id:          newID(),
+		name:        name,
+		kind:        spanKindServer,
+		start:       first.UnixNano() / 1e3,
+		duration:    last.Sub(first).Nanoseconds() / 1e3,
+		tags: map[string]string{
+			"motan.request_id": fmt.Sprint(tc.Rid),
+			"motan.group":      group,
+			"peer.address":     tc.Addr,
+		},
+	}
+	spans := make([]*span, 0, len(points))
+	spans = append(spans, root)
+	for i := 1; i < len(points); i++ {
+		child := &span{
+			traceIDHigh: root.traceIDHigh,
+			traceIDLow:  root.traceIDLow,
+			id:          newID(),
+			parentID:    root.id,
+			name:        points[i].Name,
+			start:       points[i-1].Time.UnixNano() / 1e3,
+			duration:    points[i].Time.Sub(points[i-1].Time).Nanoseconds() / 1e3,
+		}
+		if points[i].Addr != "" {
+			child.tags = map[string]string{"peer.address": points[i].Addr}
+		}
+		spans = append(spans, child)
+	}
+	return spans
+}
+
+func newID() uint64 {
+	for {
+		if id := rand.Uint64(); id != 0 {
+			return id
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	"github.com/weibocom/motan-go/config"
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func newTestTraceContext() *motan.TraceContext {
+	now := time.Now()
+	tc := &motan.TraceContext{Rid: 123, Addr: "127.0.0.1:1234", Values: map[string]interface{}{
+		motan.TraceServiceKey: "com.weibo.Test", motan.TraceMethodKey: "hello", motan.TraceGroupKey: "g",
+	}}
+	tc.PutReqSpan(&motan.Span{Name: motan.Receive, Time: now})
+	tc.PutReqSpan(&motan.Span{Name: motan.Decode, Time: now.Add(time.Millisecond)})
+	tc.PutReqSpan(&motan.Span{Name: motan.Send, Addr: "10.0.0.1:8002", Time: now.Add(2 * time.Millisecond)})
+	tc.PutResSpan(&motan.Span{Name: motan.Receive, Addr: "10.0.0.1:8002", Time: now.Add(5 * time.Millisecond)})
+	tc.PutResSpan(&motan.Span{Name: motan.Send, Time: now.Add(6 * time.Millisecond)})
+	return tc
+}
+
+func TestConvert(t *testing.T) {
+	spans := convert(newTestTraceContext())
+	assert.Equal(t, 5, len(spans))
+	root := spans[0]
+	assert.Equal(t, "com.weibo.Test.hello", root.name)
+	assert.Equal(t, spanKindServer, root.kind)
+	assert.Equal(t, int64(6000), root.duration)
+	assert.Equal(t, "123", root.tags["motan.request_id"])
+	for _, s := range spans[1:] {
+		assert.Equal(t, root.id, s.parentID)
+		assert.Equal(t, root.traceIDLow, s.traceIDLow)
+	}
+	assert.Equal(t, motan.Decode, spans[1].name)
+	assert.Equal(t, int64(1000), spans[1].duration)
+	assert.Equal(t, motan.Receive, spans[3].name)
+	assert.Equal(t, int64(3000), spans[3].duration)
+	assert.Equal(t, "10.0.0.1:8002", spans[3].tags["peer.address"])
+
+	assert.Nil(t, convert(&motan.TraceContext{}))
+}
+
+func TestZipkinExporter(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer test", r.Header.Get("Authorization"))
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	e, err := NewExporter(&Config{Exporter: Zipkin, Endpoint: server.URL, ServiceName: "test", BatchSize: 5, Interval: 10000,
+		Headers: map[string]string{"Authorization": "Bearer test"}})
+	assert.Nil(t, err)
+	e.Export(newTestTraceContext())
+
+	select {
+	case body := <-bodies:
+		var spans []*zipkinSpan
+		assert.Nil(t, json.Unmarshal(body, &spans))
+		assert.Equal(t, 5, len(spans))
+		assert.Equal(t, "SERVER", spans[0].Kind)
+		assert.Equal(t, 32, len(spans[0].TraceID))
+		assert.Equal(t, "", spans[0].ParentID)
+		assert.Equal(t, spans[0].ID, spans[1].ParentID)
+		assert.Equal(t, "test", spans[1].LocalEndpoint.ServiceName)
+	case <-time.After(3 * time.Second):
+		t.Fatal("spans should be sent when the batch is full")
+	}
+
+	_, err = NewExporter(&Config{Exporter: "unknown", Endpoint: server.URL})
+	assert.NotNil(t, err)
+	_, err = NewExporter(&Config{Exporter: Zipkin})
+	assert.NotNil(t, err)
+}
+
+func TestJaegerEncode(t *testing.T) {
+	spans := convert(newTestTraceContext())
+	data, err := (&jaegerSender{serviceName: "test"}).encode(spans)
+	assert.Nil(t, err)
+
+	buf := thrift.NewTMemoryBuffer()
+	buf.Write(data)
+	p := thrift.NewTBinaryProtocolTransport(buf)
+	var serviceName string
+	var size, traced int
+	p.ReadStructBegin()
+	for {
+		_, typeID, id, _ := p.ReadFieldBegin()
+		if typeID == thrift.STOP {
+			break
+		}
+		switch id {
+		case jaegerBatchProcess:
+			p.ReadStructBegin()
+			p.ReadFieldBegin()
+			serviceName, _ = p.ReadString()
+			p.ReadFieldBegin()
+		case jaegerBatchSpans:
+			_, size, _ = p.ReadListBegin()
+			for i := 0; i < size; i++ {
+				p.ReadStructBegin()
+				_, _, fid, _ := p.ReadFieldBegin()
+				low, _ := p.ReadI64()
+				if fid == jaegerSpanTraceIDLow && uint64(low) == spans[0].traceIDLow {
+					traced++
+				}
+				p.ReadFieldEnd()
+				for {
+					_, ft, _, _ := p.ReadFieldBegin()
+					if ft == thrift.STOP {
+						break
+					}
+					assert.Nil(t, p.Skip(ft))
+				}
+			}
+		}
+	}
+	assert.Equal(t, "test", serviceName)
+	assert.Equal(t, 5, size)
+	assert.Equal(t, 5, traced)
+}
+
+func TestStart(t *testing.T) {
+	c, _ := config.NewConfigFromBytes([]byte("tracing:\n  exporter: jaeger\n  endpoint: http://localhost:14268/api/traces\n  sampleRate: 1\n"))
+	policy := motan.TracePolicy
+	defer func() {
+		motan.TracePolicy = policy
+		motan.SetTraceExporter(nil)
+	}()
+	Start(&motan.Context{Config: c, ServerURL: &motan.URL{Parameters: map[string]string{motan.ApplicationKey: "app"}}})
+	tc := motan.TracePolicy(1, nil)
+	assert.NotNil(t, tc, "all the requests should be traced")
+}
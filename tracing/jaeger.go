@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// the field ids of jaeger.thrift
+const (
+	jaegerBatchProcess = 1
+	jaegerBatchSpans   = 2
+
+	jaegerProcessServiceName = 1
+
+	jaegerSpanTraceIDLow    = 1
+	jaegerSpanTraceIDHigh   = 2
+	jaegerSpanSpanID        = 3
+	jaegerSpanParentSpanID  = 4
+	jaegerSpanOperationName = 5
+	jaegerSpanFlags         = 7
+	jaegerSpanStartTime     = 8
+	jaegerSpanDuration      = 9
+	jaegerSpanTags          = 10
+
+	jaegerTagKey   = 1
+	jaegerTagVType = 2
+	jaegerTagVStr  = 3
+
+	jaegerTagTypeString = 0
+	jaegerFlagSampled   = 1
+)
+
+// jaegerSender encodes the spans in the thrift Batch of the jaeger collector api /api/traces
+type jaegerSender struct {
+	serviceName string
+}
+
+func (j *jaegerSender) contentType() string {
+	return "application/x-thrift"
+}
+
+func (j *jaegerSender) encode(spans []*span) ([]byte, error) {
+	buf := thrift.NewTMemoryBufferLen(1024)
+	w := &thriftWriter{p: thrift.NewTBinaryProtocolTransport(buf)}
+	w.structBegin("Batch")
+	w.fieldBegin(thrift.STRUCT, jaegerBatchProcess)
+	w.structBegin("Process")
+	w.fieldBegin(thrift.STRING, jaegerProcessServiceName)
+	w.str(j.serviceName)
+	w.structEnd()
+	w.fieldBegin(thrift.LIST, jaegerBatchSpans)
+	w.listBegin(thrift.STRUCT, len(spans))
+	for _, s := range spans {
+		j.writeSpan(w, s)
+	}
+	w.structEnd()
+	if w.err == nil {
+		w.err = w.p.Flush(context.Background())
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return buf.Bytes(), nil
+}
+
+func (j *jaegerSender) writeSpan(w *thriftWriter, s *span) {
+	w.structBegin("Span")
+	w.fieldBegin(thrift.I64, jaegerSpanTraceIDLow)
+	w.i64(int64(s.traceIDLow))
+	w.fieldBegin(thrift.I64, jaegerSpanTraceIDHigh)
+	w.i64(int64(s.traceIDHigh))
+	w.fieldBegin(thrift.I64, jaegerSpanSpanID)
+	w.i64(int64(s.id))
+	w.fieldBegin(thrift.I64, jaegerSpanParentSpanID)
+	w.i64(int64(s.parentID))
+	w.fieldBegin(thrift.STRING, jaegerSpanOperationName)
+	w.str(s.name)
+	w.fieldBegin(thrift.I32, jaegerSpanFlags)
+	w.i32(jaegerFlagSampled)
+	w.fieldBegin(thrift.I64, jaegerSpanStartTime)
+	w.i64(s.start)
+	w.fieldBegin(thrift.I64, jaegerSpanDuration)
+	w.i64(s.duration)
+	tags := make(map[string]string, len(s.tags)+1)
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	if s.kind != "" {
+		tags["span.kind"] = s.kind
+	}
+	if len(tags) > 0 {
+		w.fieldBegin(thrift.LIST, jaegerSpanTags)
+		w.listBegin(thrift.STRUCT, len(tags))
+		for k, v := range tags {
+			w.structBegin("Tag")
+			w.fieldBegin(thrift.STRING, jaegerTagKey)
+			w.str(k)
+			w.fieldBegin(thrift.I32, jaegerTagVType)
+			w.i32(jaegerTagTypeString)
+			w.fieldBegin(thrift.STRING, jaegerTagVStr)
+			w.str(v)
+			w.structEnd()
+		}
+	}
+	w.structEnd()
+}
+
+// thriftWriter keeps the first error of the writes. the field and the list ends are no-op in the binary protocol
+type thriftWriter struct {
+	p   *thrift.TBinaryProtocol
+	err error
+}
+
+func (w *thriftWriter) do(f func() error) {
+	if w.err == nil {
+		w.err = f()
+	}
+}
+
+func (w *thriftWriter) structBegin(name string) {
+	w.do(func() error { return w.p.WriteStructBegin(name) })
+}
+
+// structEnd writes the field stop of the struct
+func (w *thriftWriter) structEnd() {
+	w.do(w.p.WriteFieldStop)
+	w.do(w.p.WriteStructEnd)
+}
+
+func (w *thriftWriter) fieldBegin(t thrift.TType, id int16) {
+	w.do(func() error { return w.p.WriteFieldBegin("", t, id) })
+}
+
+func (w *thriftWriter) listBegin(t thrift.TType, size int) {
+	w.do(func() error { return w.p.WriteListBegin(t, size) })
+}
+
+func (w *thriftWriter) str(s string) {
+	w.do(func() error { return w.p.WriteString(s) })
+}
+
+func (w *thriftWriter) i32(v int32) {
+	w.do(func() error { return w.p.WriteI32(v) })
+}
+
+func (w *thriftWriter) i64(v int64) {
+	w.do(func() error { return w.p.WriteI64(v) })
+}
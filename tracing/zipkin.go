@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// zipkinSender encodes the spans in the json of the zipkin v2 api
+type zipkinSender struct {
+	serviceName string
+}
+
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind,omitempty"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration,omitempty"`
+	LocalEndpoint *zipkinEndpoint   `json:"localEndpoint,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+func (z *zipkinSender) contentType() string {
+	return "application/json"
+}
+
+func (z *zipkinSender) encode(spans []*span) ([]byte, error) {
+	endpoint := &zipkinEndpoint{ServiceName: z.serviceName}
+	zs := make([]*zipkinSpan, len(spans))
+	for i, s := range spans {
+		zs[i] = &zipkinSpan{
+			TraceID:       fmt.Sprintf("%016x%016x", s.traceIDHigh, s.traceIDLow),
+			ID:            fmt.Sprintf("%016x", s.id),
+			Name:          s.name,
+			Kind:          strings.ToUpper(s.kind),
+			Timestamp:     s.start,
+			Duration:      s.duration,
+			LocalEndpoint: endpoint,
+			Tags:          s.tags,
+		}
+		if s.parentID != 0 {
+			zs[i].ParentID = fmt.Sprintf("%016x", s.parentID)
+		}
+	}
+	return json.Marshal(zs)
+}
@@ -0,0 +1,129 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// InheritListenersEnv lists the addresses of the listeners passed to the new process by StartInheritProcess,
+// the listener of the i-th address is the file descriptor 3+i
+const InheritListenersEnv = "MOTAN_INHERIT_LISTENERS"
+
+type fileListener interface {
+	net.Listener
+	File() (*os.File, error)
+}
+
+var (
+	inheritOnce sync.Once
+	inheritLock sync.Mutex
+	// listeners passed from the parent process and not used yet
+	inheritedFiles map[string]*os.File
+	// listeners can be passed to the new process
+	activeListeners sync.Map // key -> fileListener
+)
+
+func listenerKey(network string, addr string) string {
+	return network + ":" + addr
+}
+
+func loadInheritedFiles() {
+	inheritedFiles = make(map[string]*os.File)
+	keys := os.Getenv(InheritListenersEnv)
+	if keys == "" {
+		return
+	}
+	// the sub processes started later by this process should not inherit them again
+	os.Unsetenv(InheritListenersEnv)
+	for i, key := range strings.Split(keys, ",") {
+		inheritedFiles[key] = os.NewFile(uintptr(3+i), key)
+	}
+}
+
+// ListenInherited listens on the address, or uses the listener passed from the parent process if there is one.
+// the listener is passed to the new process started by StartInheritProcess until it is closed
+func ListenInherited(network string, addr string) (net.Listener, error) {
+	inheritOnce.Do(loadInheritedFiles)
+	key := listenerKey(network, addr)
+	inheritLock.Lock()
+	f := inheritedFiles[key]
+	delete(inheritedFiles, key)
+	inheritLock.Unlock()
+	var lis net.Listener
+	var err error
+	if f != nil {
+		lis, err = net.FileListener(f)
+		f.Close()
+	} else if network == "unix" {
+		lis, err = listenUnix(addr)
+	} else {
+		lis, err = net.Listen(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fl, ok := lis.(fileListener); ok {
+		activeListeners.Store(key, fl)
+		return &inheritableListener{fileListener: fl, key: key}, nil
+	}
+	return lis, nil
+}
+
+// inheritableListener is removed from the listeners to pass when it is closed
+type inheritableListener struct {
+	fileListener
+	key string
+}
+
+func (l *inheritableListener) Close() error {
+	activeListeners.Delete(l.key)
+	return l.fileListener.Close()
+}
+
+// StartInheritProcess starts a new process of the same command and arguments, and passes the listeners of this
+// process to it, so the connections are accepted by both processes until the listeners of this process are closed
+func StartInheritProcess() (*os.Process, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, 8)
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	defer func() {
+		for _, f := range files[3:] {
+			f.Close()
+		}
+	}()
+	activeListeners.Range(func(k, v interface{}) bool {
+		if ul, ok := v.(*net.UnixListener); ok {
+			// the socket file is used by the new process
+			ul.SetUnlinkOnClose(false)
+		}
+		f, e := v.(fileListener).File()
+		if e != nil {
+			err = e
+			return false
+		}
+		keys = append(keys, k.(string))
+		files = append(files, f)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no listener to pass to the new process")
+	}
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, InheritListenersEnv+"=") {
+			env = append(env, e)
+		}
+	}
+	env = append(env, InheritListenersEnv+"="+strings.Join(keys, ","))
+	wd, _ := os.Getwd()
+	return os.StartProcess(path, os.Args, &os.ProcAttr{Dir: wd, Env: env, Files: files})
+}
@@ -0,0 +1,46 @@
+package transport
+
+import (
+	"net"
+	"testing"
+)
+
+func TestListenInherited(t *testing.T) {
+	origin, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fail. err:%v", err)
+	}
+	defer origin.Close()
+	addr := origin.Addr().String()
+	f, err := origin.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("get listener file fail. err:%v", err)
+	}
+	inheritOnce.Do(loadInheritedFiles)
+	inheritLock.Lock()
+	inheritedFiles[listenerKey("tcp", addr)] = f
+	inheritLock.Unlock()
+
+	// the address is in use, so it only succeeds with the inherited listener
+	lis, err := ListenInherited("tcp", addr)
+	if err != nil {
+		t.Fatalf("listen with inherited listener fail. err:%v", err)
+	}
+	if _, ok := activeListeners.Load(listenerKey("tcp", addr)); !ok {
+		t.Errorf("inherited listener should be passed to the new process")
+	}
+	go func() {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := lis.Accept()
+	if err != nil {
+		t.Fatalf("accept fail. err:%v", err)
+	}
+	conn.Close()
+	lis.Close()
+	if _, ok := activeListeners.Load(listenerKey("tcp", addr)); ok {
+		t.Errorf("closed listener should not be passed to the new process")
+	}
+}
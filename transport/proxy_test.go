@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func listenEcho(t *testing.T) net.Listener {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return lis
+}
+
+// serveProxy accepts connections and tunnels them to the address read by handshake
+func serveProxy(t *testing.T, handshake func(conn net.Conn, r *bufio.Reader) (string, error)) net.Listener {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				addr, err := handshake(conn, r)
+				if err != nil {
+					return
+				}
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go io.Copy(target, r)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return lis
+}
+
+func httpConnectHandshake(conn net.Conn, r *bufio.Reader) (string, error) {
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return "", err
+	}
+	if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+		conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+		return "", io.EOF
+	}
+	_, err = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	return req.Host, err
+}
+
+// socks5Handshake supports no auth and CONNECT to ipv4 address only
+func socks5Handshake(conn net.Conn, r *bufio.Reader) (string, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(r, make([]byte, head[1])); err != nil {
+		return "", err
+	}
+	conn.Write([]byte{5, 0})
+	req := make([]byte, 10)
+	if _, err := io.ReadFull(r, req); err != nil {
+		return "", err
+	}
+	addr := net.IP(req[4:8]).String() + ":" + strconv.Itoa(int(binary.BigEndian.Uint16(req[8:])))
+	_, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return addr, err
+}
+
+func TestDialProxy(t *testing.T) {
+	echo := listenEcho(t)
+	defer echo.Close()
+	httpProxy := serveProxy(t, httpConnectHandshake)
+	defer httpProxy.Close()
+	socksProxy := serveProxy(t, socks5Handshake)
+	defer socksProxy.Close()
+
+	host, port, _ := net.SplitHostPort(echo.Addr().String())
+	p, _ := strconv.Atoi(port)
+	for _, proxyAddr := range []string{"http://user:pass@" + httpProxy.Addr().String(), "socks5://" + socksProxy.Addr().String()} {
+		url := &motan.URL{Host: host, Port: p, Parameters: map[string]string{motan.DialProxyKey: proxyAddr}}
+		conn, err := Dial(url, time.Second)
+		if err != nil {
+			t.Fatalf("dial through proxy fail. proxy:%s, err:%v", proxyAddr, err)
+		}
+		conn.Write([]byte("ping"))
+		buf := make([]byte, 4)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err = io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+			t.Errorf("echo through proxy fail. proxy:%s, read:%s, err:%v", proxyAddr, buf, err)
+		}
+		conn.Close()
+	}
+
+	url := &motan.URL{Host: host, Port: p, Parameters: map[string]string{motan.DialProxyKey: "http://" + httpProxy.Addr().String()}}
+	if _, err := Dial(url, time.Second); err == nil {
+		t.Errorf("dial should fail if proxy authentication fail")
+	}
+	url.PutParam(motan.DialProxyKey, "ftp://"+httpProxy.Addr().String())
+	if _, err := Dial(url, time.Second); err != ErrProxyScheme {
+		t.Errorf("dial should fail with unsupported proxy scheme. err:%v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	// QUIC transport name, used as the value of the url param "transport"
+	QUIC = "quic"
+	// ALPN protocol negotiated by motan2 over QUIC
+	QUICNextProto = "motan2"
+
+	defaultQUICIdleTimeout = 60 * time.Second
+	defaultQUICKeepalive   = 15 * time.Second
+)
+
+// quicConn adapts a single bidirectional QUIC stream to net.Conn, so the motan2 framing can run on it unchanged.
+// each motan connection owns one QUIC connection with exactly one stream.
+type quicConn struct {
+	*quic.Stream
+	conn *quic.Conn
+}
+
+func (q *quicConn) LocalAddr() net.Addr {
+	return q.conn.LocalAddr()
+}
+
+func (q *quicConn) RemoteAddr() net.Addr {
+	return q.conn.RemoteAddr()
+}
+
+func (q *quicConn) Close() error {
+	q.Stream.CancelRead(0)
+	q.Stream.Close()
+	return q.conn.CloseWithError(0, "")
+}
+
+// quicListener accepts the QUIC connections in background, the first stream of each connection is awaited
+// in its own goroutine, so a client that never opens a stream does not block the others.
+type quicListener struct {
+	listener *quic.Listener
+	ctx      context.Context
+	cancel   context.CancelFunc
+	conns    chan net.Conn
+	done     chan struct{}
+	// the accept error, set before done is closed
+	err error
+}
+
+func newQUICListener(lis *quic.Listener) *quicListener {
+	l := &quicListener{listener: lis, conns: make(chan net.Conn), done: make(chan struct{})}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	go l.acceptLoop()
+	return l
+}
+
+func (l *quicListener) acceptLoop() {
+	for {
+		conn, err := l.listener.Accept(l.ctx)
+		if err != nil {
+			l.err = err
+			close(l.done)
+			return
+		}
+		go l.acceptStream(conn)
+	}
+}
+
+func (l *quicListener) acceptStream(conn *quic.Conn) {
+	ctx, cancel := context.WithTimeout(l.ctx, defaultQUICIdleTimeout)
+	stream, err := conn.AcceptStream(ctx)
+	cancel()
+	if err != nil {
+		conn.CloseWithError(0, err.Error())
+		return
+	}
+	qc := &quicConn{Stream: stream, conn: conn}
+	select {
+	case l.conns <- qc:
+	case <-l.done:
+		qc.Close()
+	}
+}
+
+func (l *quicListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *quicListener) Close() error {
+	l.cancel()
+	return l.listener.Close()
+}
+
+func (l *quicListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+func quicConfig() *quic.Config {
+	return &quic.Config{MaxIdleTimeout: defaultQUICIdleTimeout, KeepAlivePeriod: defaultQUICKeepalive}
+}
+
+// DialQUIC opens a QUIC connection to addr and returns its first stream as a net.Conn.
+// if tlsConf is nil the server certificate is verified by the system roots, InsecureSkipVerify must be set explicitly to skip it.
+func DialQUIC(addr string, timeout time.Duration, tlsConf *tls.Config) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if tlsConf == nil {
+		tlsConf = &tls.Config{}
+	}
+	tlsConf.NextProtos = []string{QUICNextProto}
+	conn, err := quic.DialAddr(ctx, addr, tlsConf, quicConfig())
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		conn.CloseWithError(0, err.Error())
+		return nil, err
+	}
+	return &quicConn{Stream: stream, conn: conn}, nil
+}
+
+// ListenQUIC listens on the udp addr and accepts QUIC connections as net.Conn.
+// if tlsConf is nil a self-signed certificate is generated for the listener.
+func ListenQUIC(addr string, tlsConf *tls.Config) (net.Listener, error) {
+	if tlsConf == nil {
+		cert, err := selfSignedCert()
+		if err != nil {
+			return nil, err
+		}
+		tlsConf = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	tlsConf.NextProtos = []string{QUICNextProto}
+	lis, err := quic.ListenAddr(addr, tlsConf, quicConfig())
+	if err != nil {
+		return nil, err
+	}
+	return newQUICListener(lis), nil
+}
+
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{Organization: []string{"motan"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,56 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+func TestQUICRoundTrip(t *testing.T) {
+	lis, err := ListenQUIC("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatalf("listen quic fail. err:%v", err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	// the self-signed certificate is not trusted by default
+	if conn, err := DialQUIC(lis.Addr().String(), time.Second, nil); err == nil {
+		conn.Close()
+		t.Fatal("self-signed certificate should not be verified")
+	}
+	// a connection without stream does not block the following connections
+	idle, err := quic.DialAddr(context.Background(), lis.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{QUICNextProto}}, quicConfig())
+	if err != nil {
+		t.Fatalf("dial quic fail. err:%v", err)
+	}
+	defer idle.CloseWithError(0, "")
+
+	conn, err := DialQUIC(lis.Addr().String(), time.Second, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("dial quic fail. err:%v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err = conn.Write([]byte("motan")); err != nil {
+		t.Fatalf("write fail. err:%v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read fail. err:%v", err)
+	}
+	if string(buf) != "motan" {
+		t.Errorf("echo not match. got:%s", buf)
+	}
+}
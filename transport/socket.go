@@ -0,0 +1,110 @@
+package transport
+
+import (
+	"net"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// tcp socket option url params, applied to dialed and accepted tcp connections. the go defaults are kept if not set
+const (
+	TCPNoDelayKey     = "tcpNoDelay"     // true or false
+	TCPReadBufferKey  = "tcpReadBuffer"  // SO_RCVBUF in bytes
+	TCPWriteBufferKey = "tcpWriteBuffer" // SO_SNDBUF in bytes
+	TCPKeepAliveKey   = "tcpKeepAlive"   // keepalive period in milliseconds, keepalive is disabled if it is 0
+	TCPLingerKey      = "tcpLinger"      // SO_LINGER in seconds, the unsent data is discarded on close if it is 0
+)
+
+type socketOptions struct {
+	noDelay     *bool
+	readBuffer  int
+	writeBuffer int
+	keepAlive   *time.Duration
+	linger      *int
+}
+
+// getSocketOptions returns nil if no socket option is set in url params
+func getSocketOptions(url *motan.URL) *socketOptions {
+	o := &socketOptions{}
+	set := false
+	if v := url.GetParam(TCPNoDelayKey, ""); v != "" {
+		noDelay := v == "true"
+		o.noDelay = &noDelay
+		set = true
+	}
+	if v, ok := url.GetInt(TCPReadBufferKey); ok && v > 0 {
+		o.readBuffer = int(v)
+		set = true
+	}
+	if v, ok := url.GetInt(TCPWriteBufferKey); ok && v > 0 {
+		o.writeBuffer = int(v)
+		set = true
+	}
+	if v, ok := url.GetInt(TCPKeepAliveKey); ok && v >= 0 {
+		keepAlive := time.Duration(v) * time.Millisecond
+		o.keepAlive = &keepAlive
+		set = true
+	}
+	if v, ok := url.GetInt(TCPLingerKey); ok {
+		linger := int(v)
+		o.linger = &linger
+		set = true
+	}
+	if !set {
+		return nil
+	}
+	return o
+}
+
+// apply sets the options if conn is a tcp connection. failures are only logged, the connection is still usable
+func (o *socketOptions) apply(conn net.Conn) {
+	if o == nil {
+		return
+	}
+	if b, ok := conn.(*bufferedConn); ok {
+		conn = b.Conn
+	}
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	var err error
+	if o.noDelay != nil {
+		err = tc.SetNoDelay(*o.noDelay)
+	}
+	if o.readBuffer > 0 && err == nil {
+		err = tc.SetReadBuffer(o.readBuffer)
+	}
+	if o.writeBuffer > 0 && err == nil {
+		err = tc.SetWriteBuffer(o.writeBuffer)
+	}
+	if o.keepAlive != nil && err == nil {
+		if *o.keepAlive == 0 {
+			err = tc.SetKeepAlive(false)
+		} else if err = tc.SetKeepAlive(true); err == nil {
+			err = tc.SetKeepAlivePeriod(*o.keepAlive)
+		}
+	}
+	if o.linger != nil && err == nil {
+		err = tc.SetLinger(*o.linger)
+	}
+	if err != nil {
+		vlog.Warningf("set tcp socket options fail. remote:%s, err:%v\n", tc.RemoteAddr(), err)
+	}
+}
+
+// socketListener applies the socket options to accepted connections
+type socketListener struct {
+	net.Listener
+	options *socketOptions
+}
+
+func (l *socketListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.options.apply(conn)
+	}
+	return conn, err
+}
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func TestGetSocketOptions(t *testing.T) {
+	if getSocketOptions(&motan.URL{Parameters: map[string]string{}}) != nil {
+		t.Errorf("socket options should be nil if not set")
+	}
+	url := &motan.URL{Parameters: map[string]string{TCPNoDelayKey: "false", TCPReadBufferKey: "65536", TCPWriteBufferKey: "131072",
+		TCPKeepAliveKey: "0", TCPLingerKey: "1"}}
+	o := getSocketOptions(url)
+	if o == nil || o.noDelay == nil || *o.noDelay || o.readBuffer != 65536 || o.writeBuffer != 131072 ||
+		o.keepAlive == nil || *o.keepAlive != 0 || o.linger == nil || *o.linger != 1 {
+		t.Errorf("parse socket options fail. options:%+v", o)
+	}
+	url = &motan.URL{Parameters: map[string]string{TCPReadBufferKey: "-1", TCPKeepAliveKey: "abc"}}
+	if o = getSocketOptions(url); o != nil {
+		t.Errorf("invalid socket options should be ignored. options:%+v", o)
+	}
+}
+
+func TestSocketOptions(t *testing.T) {
+	params := map[string]string{TCPNoDelayKey: "true", TCPReadBufferKey: "65536", TCPWriteBufferKey: "65536",
+		TCPKeepAliveKey: "30000", TCPLingerKey: "0"}
+	lis, err := Listen(&motan.URL{Port: 0, Parameters: params})
+	if err != nil {
+		t.Fatalf("listen with socket options fail. err:%v", err)
+	}
+	defer lis.Close()
+	if _, ok := lis.(*socketListener); !ok {
+		t.Fatalf("listener should apply socket options. listener:%T", lis)
+	}
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, ok := conn.(*net.TCPConn); !ok {
+			return
+		}
+		io.Copy(conn, conn)
+	}()
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	p, _ := strconv.Atoi(port)
+	conn, err := Dial(&motan.URL{Host: "127.0.0.1", Port: p, Parameters: params}, time.Second)
+	if err != nil {
+		t.Fatalf("dial with socket options fail. err:%v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("echo with socket options fail. read:%s, err:%v", buf, err)
+	}
+}
@@ -0,0 +1,191 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/log"
+)
+
+// tls url params
+const (
+	TLSKey           = "tls"
+	TLSCertFileKey   = "tlsCertFile"
+	TLSKeyFileKey    = "tlsKeyFile"
+	TLSCAFileKey     = "tlsCAFile"
+	TLSClientAuthKey = "tlsClientAuth"
+	TLSServerNameKey = "tlsServerName"
+	// the certificate of the server is not verified if set to true, only for tests or trusted networks
+	TLSInsecureKey = "tlsInsecure"
+)
+
+var (
+	// certificate files are checked for changes at most once per interval
+	tlsReloadInterval = 10 * time.Second
+
+	reloaders     = make(map[string]*certReloader, 16)
+	reloadersLock sync.Mutex
+)
+
+// IsTLSEnabled returns true if the url param tls is set to true
+func IsTLSEnabled(url *motan.URL) bool {
+	return url.GetParam(TLSKey, "") == "true"
+}
+
+// NewServerTLSConfig builds the tls config of a server from url params.
+// the certificate and the client CA are reloaded when the files changed, so renewed certificates take effect without restart.
+func NewServerTLSConfig(url *motan.URL) (*tls.Config, error) {
+	certFile := url.GetParam(TLSCertFileKey, "")
+	keyFile := url.GetParam(TLSKeyFileKey, "")
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("tls server needs " + TLSCertFileKey + " and " + TLSKeyFileKey)
+	}
+	cert, err := getReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := parseClientAuth(url.GetParam(TLSClientAuthKey, ""))
+	if err != nil {
+		return nil, err
+	}
+	var ca *certReloader
+	if caFile := url.GetParam(TLSCAFileKey, ""); caFile != "" {
+		if ca, err = getReloader(caFile, ""); err != nil {
+			return nil, err
+		}
+	} else if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+		return nil, errors.New("tls client auth needs " + TLSCAFileKey)
+	}
+	config := &tls.Config{}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := &tls.Config{ClientAuth: clientAuth, NextProtos: config.NextProtos}
+		c.Certificates = []tls.Certificate{*cert.load().cert}
+		if ca != nil {
+			c.ClientCAs = ca.load().pool
+		}
+		return c, nil
+	}
+	return config, nil
+}
+
+// NewClientTLSConfig builds the tls config of an endpoint from url params.
+// a client certificate is only sent when both cert and key files are configured(mutual tls).
+func NewClientTLSConfig(url *motan.URL) (*tls.Config, error) {
+	config := &tls.Config{ServerName: url.GetParam(TLSServerNameKey, url.Host), InsecureSkipVerify: url.GetParam(TLSInsecureKey, "") == "true"}
+	if caFile := url.GetParam(TLSCAFileKey, ""); caFile != "" {
+		ca, err := getReloader(caFile, "")
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = ca.load().pool
+	}
+	certFile := url.GetParam(TLSCertFileKey, "")
+	keyFile := url.GetParam(TLSKeyFileKey, "")
+	if certFile != "" && keyFile != "" {
+		cert, err := getReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.load().cert, nil
+		}
+	}
+	return config, nil
+}
+
+func parseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verifyIfGiven":
+		return tls.VerifyClientCertIfGiven, nil
+	case "requireAndVerify":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, errors.New("unknown tls client auth mode: " + mode)
+}
+
+type certMaterial struct {
+	cert *tls.Certificate
+	pool *x509.CertPool
+}
+
+// certReloader holds a certificate(certFile and keyFile) or a CA pool(only certFile), and reloads it when the files are modified
+type certReloader struct {
+	certFile  string
+	keyFile   string
+	lock      sync.Mutex
+	material  *certMaterial
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func getReloader(certFile, keyFile string) (*certReloader, error) {
+	reloadersLock.Lock()
+	defer reloadersLock.Unlock()
+	key := certFile + "|" + keyFile
+	if r, ok := reloaders[key]; ok {
+		return r, nil
+	}
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	reloaders[key] = r
+	return r, nil
+}
+
+func (c *certReloader) load() *certMaterial {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if time.Since(c.lastCheck) >= tlsReloadInterval {
+		c.lastCheck = time.Now()
+		if info, err := os.Stat(c.certFile); err == nil && !info.ModTime().Equal(c.modTime) {
+			if err = c.reload(); err != nil {
+				// keep the old certificate
+				vlog.Errorf("reload tls certificate %s fail. err:%v\n", c.certFile, err)
+			} else {
+				vlog.Infof("tls certificate %s reloaded\n", c.certFile)
+			}
+		}
+	}
+	return c.material
+}
+
+func (c *certReloader) reload() error {
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		return err
+	}
+	material := &certMaterial{}
+	if c.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+		if err != nil {
+			return err
+		}
+		material.cert = &cert
+	} else {
+		pem, err := ioutil.ReadFile(c.certFile)
+		if err != nil {
+			return err
+		}
+		material.pool = x509.NewCertPool()
+		if !material.pool.AppendCertsFromPEM(pem) {
+			return errors.New("no valid certificate in " + c.certFile)
+		}
+	}
+	c.material = material
+	c.modTime = info.ModTime()
+	c.lastCheck = time.Now()
+	return nil
+}
@@ -0,0 +1,128 @@
+package transport
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func writeCert(t *testing.T, dir string, name string, isCA bool, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certOut := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyOut := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	ioutil.WriteFile(filepath.Join(dir, name+".crt"), certOut, 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+".key"), keyOut, 0600)
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "motan-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca, caKey := writeCert(t, dir, "ca", true, nil, nil)
+	writeCert(t, dir, "server", false, ca, caKey)
+	writeCert(t, dir, "client", false, ca, caKey)
+
+	serverURL := &motan.URL{Host: "127.0.0.1", Port: 0, Parameters: map[string]string{
+		TLSKey:           "true",
+		TLSCertFileKey:   filepath.Join(dir, "server.crt"),
+		TLSKeyFileKey:    filepath.Join(dir, "server.key"),
+		TLSCAFileKey:     filepath.Join(dir, "ca.crt"),
+		TLSClientAuthKey: "requireAndVerify",
+	}}
+	lis, err := Listen(serverURL)
+	if err != nil {
+		t.Fatalf("listen tls fail. err:%v", err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	port := lis.Addr().(*net.TCPAddr).Port
+
+	clientURL := &motan.URL{Host: "127.0.0.1", Port: port, Parameters: map[string]string{
+		TLSKey:         "true",
+		TLSCertFileKey: filepath.Join(dir, "client.crt"),
+		TLSKeyFileKey:  filepath.Join(dir, "client.key"),
+		TLSCAFileKey:   filepath.Join(dir, "ca.crt"),
+	}}
+	conn, err := Dial(clientURL, time.Second)
+	if err != nil {
+		t.Fatalf("dial tls fail. err:%v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte("motan"))
+	buf := make([]byte, 5)
+	if _, err = io.ReadFull(conn, buf); err != nil || string(buf) != "motan" {
+		t.Errorf("tls echo fail. buf:%s, err:%v", buf, err)
+	}
+
+	// without client certificate the handshake must fail
+	delete(clientURL.Parameters, TLSCertFileKey)
+	conn2, err := Dial(clientURL, time.Second)
+	if err == nil {
+		conn2.SetDeadline(time.Now().Add(time.Second))
+		conn2.Write([]byte("motan"))
+		_, err = io.ReadFull(conn2, buf)
+		conn2.Close()
+	}
+	if err == nil {
+		t.Errorf("connection without client certificate should fail")
+	}
+}
+
+func TestParseClientAuth(t *testing.T) {
+	if _, err := parseClientAuth("unknown"); err == nil {
+		t.Errorf("unknown client auth mode should fail")
+	}
+	if _, err := NewServerTLSConfig(&motan.URL{Parameters: map[string]string{}}); err == nil {
+		t.Errorf("server tls config without certificate should fail")
+	}
+}
@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+// Dial connects to the address of url using the transport, tls, dial proxy and socket options in url params
+func Dial(url *motan.URL, timeout time.Duration) (net.Conn, error) {
+	var tlsConf *tls.Config
+	var err error
+	if IsTLSEnabled(url) {
+		if tlsConf, err = NewClientTLSConfig(url); err != nil {
+			return nil, err
+		}
+	}
+	if url.GetParam(motan.TransportKey, "") == QUIC {
+		// quic is always encrypted, the server is verified even if the url param tls is not set
+		if tlsConf == nil {
+			if tlsConf, err = NewClientTLSConfig(url); err != nil {
+				return nil, err
+			}
+		}
+		return DialQUIC(url.GetAddressStr(), timeout, tlsConf)
+	}
+	var conn net.Conn
+	if url.IsUnixSocket() {
+		conn, err = net.DialTimeout("unix", url.GetUnixSocketPath(), timeout)
+	} else if proxyAddr := url.GetParam(motan.DialProxyKey, ""); proxyAddr != "" {
+		conn, err = dialProxy(proxyAddr, url.GetAddressStr(), timeout)
+	} else {
+		conn, err = net.DialTimeout("tcp", url.GetAddressStr(), timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	getSocketOptions(url).apply(conn)
+	if tlsConf == nil {
+		return conn, nil
+	}
+	// the socket options are set on the raw connection, and tls is end to end through the proxy
+	tlsConn := tls.Client(conn, tlsConf)
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err = tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// Listen listens on the port of url using the transport, tls and socket options in url params
+func Listen(url *motan.URL) (net.Listener, error) {
+	var tlsConf *tls.Config
+	var err error
+	if IsTLSEnabled(url) {
+		if tlsConf, err = NewServerTLSConfig(url); err != nil {
+			return nil, err
+		}
+	}
+	addr := ":" + strconv.Itoa(int(url.Port))
+	if url.GetParam(motan.TransportKey, "") == QUIC {
+		return ListenQUIC(addr, tlsConf)
+	}
+	var lis net.Listener
+	if url.IsUnixSocket() {
+		lis, err = ListenInherited("unix", url.GetUnixSocketPath())
+	} else {
+		lis, err = ListenInherited("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if options := getSocketOptions(url); options != nil && !url.IsUnixSocket() {
+		lis = &socketListener{Listener: lis, options: options}
+	}
+	if tlsConf != nil {
+		return tls.NewListener(lis, tlsConf), nil
+	}
+	return lis, nil
+}
+
+// getExtTransport returns the transport registered in the extension factory by the url param motan.TransportKey
+func getExtTransport(url *motan.URL, extFactory motan.ExtensionFactory) motan.Transport {
+	name := url.GetParam(motan.TransportKey, "")
+	if name == "" || name == QUIC || extFactory == nil {
+		return nil
+	}
+	return extFactory.GetTransport(name)
+}
+
+// DialExt is the same as Dial, but dials with the extension transport if the transport of url is registered in extFactory
+func DialExt(url *motan.URL, timeout time.Duration, extFactory motan.ExtensionFactory) (net.Conn, error) {
+	if t := getExtTransport(url, extFactory); t != nil {
+		return t.Dial(url, timeout)
+	}
+	return Dial(url, timeout)
+}
+
+// ListenExt is the same as Listen, but listens with the extension transport if the transport of url is registered in extFactory
+func ListenExt(url *motan.URL, extFactory motan.ExtensionFactory) (net.Listener, error) {
+	if t := getExtTransport(url, extFactory); t != nil {
+		return t.Listen(url)
+	}
+	return Listen(url)
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	// remove the socket file left by a previous process, but never remove a regular file
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, errors.New("unix socket path exists and is not a socket: " + path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
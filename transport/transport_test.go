@@ -0,0 +1,140 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	motan "github.com/weibocom/motan-go/core"
+)
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "motan-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	url := &motan.URL{Host: motan.UnixSocketPrefix + filepath.Join(dir, "motan.sock")}
+
+	// a regular file must not be removed
+	ioutil.WriteFile(url.GetUnixSocketPath(), []byte("x"), 0600)
+	if _, err = Listen(url); err == nil {
+		t.Fatalf("listen on a regular file should fail")
+	}
+	os.Remove(url.GetUnixSocketPath())
+
+	lis, err := Listen(url)
+	if err != nil {
+		t.Fatalf("listen unix socket fail. err:%v", err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	conn, err := Dial(url, time.Second)
+	if err != nil {
+		t.Fatalf("dial unix socket fail. err:%v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte("motan"))
+	buf := make([]byte, 5)
+	if _, err = io.ReadFull(conn, buf); err != nil || string(buf) != "motan" {
+		t.Errorf("unix socket echo fail. buf:%s, err:%v", buf, err)
+	}
+}
+
+// pipeTransport connects the dialer and the listener by net.Pipe
+type pipeTransport struct {
+	conns chan net.Conn
+}
+
+func (p *pipeTransport) GetName() string {
+	return "pipe"
+}
+
+func (p *pipeTransport) Dial(url *motan.URL, timeout time.Duration) (net.Conn, error) {
+	client, server := net.Pipe()
+	p.conns <- server
+	return client, nil
+}
+
+func (p *pipeTransport) Listen(url *motan.URL) (net.Listener, error) {
+	return &pipeListener{conns: p.conns, closed: make(chan struct{})}, nil
+}
+
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errors.New("listener closed")
+	}
+}
+
+func (l *pipeListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: "pipe", Net: "pipe"}
+}
+
+func TestExtTransport(t *testing.T) {
+	ext := &motan.DefaultExtensionFactory{}
+	ext.Initialize()
+	pipe := &pipeTransport{conns: make(chan net.Conn, 1)}
+	ext.RegistExtTransport("pipe", func() motan.Transport {
+		return pipe
+	})
+	url := &motan.URL{Host: "127.0.0.1", Port: 1, Parameters: map[string]string{motan.TransportKey: "pipe"}}
+	lis, err := ListenExt(url, ext)
+	if err != nil {
+		t.Fatalf("listen with extension transport fail. err:%v", err)
+	}
+	defer lis.Close()
+	if _, ok := lis.(*pipeListener); !ok {
+		t.Fatalf("listener should be created by the extension transport. listener:%T", lis)
+	}
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+	conn, err := DialExt(url, time.Second, ext)
+	if err != nil {
+		t.Fatalf("dial with extension transport fail. err:%v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err = io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("echo through extension transport fail. read:%s, err:%v", buf, err)
+	}
+
+	// unregistered transport falls back to tcp
+	url.PutParam(motan.TransportKey, "unknown")
+	if _, err = DialExt(url, 100*time.Millisecond, ext); err == nil {
+		t.Errorf("dial tcp should fail without listener")
+	}
+}
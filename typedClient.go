@@ -0,0 +1,98 @@
+//go:build go1.18
+// +build go1.18
+
+package motan
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Call calls the method with the request as the only argument, and returns the reply of the type Resp, e.g.
+//
+//	reply, err := motan.Call[*HelloRequest, *HelloReply](ctx, client, "hello", req)
+func Call[Req any, Resp any](ctx context.Context, client *Client, method string, req Req) (Resp, error) {
+	var resp Resp
+	var reply interface{} = &resp
+	// a pointer Resp is allocated and deserialized into directly, the serializations such as pb can not handle **T
+	if t := reflect.TypeOf(resp); t != nil && t.Kind() == reflect.Ptr {
+		reflect.ValueOf(&resp).Elem().Set(reflect.New(t.Elem()))
+		reply = resp
+	}
+	if err := client.CallContext(ctx, method, []interface{}{req}, reply); err != nil {
+		var zero Resp
+		return zero, err
+	}
+	return resp, nil
+}
+
+// Method is a typed method of a service bound to a client by NewMethod or Bind
+type Method[Req any, Resp any] struct {
+	client *Client
+	name   string
+}
+
+func NewMethod[Req any, Resp any](client *Client, name string) Method[Req, Resp] {
+	return Method[Req, Resp]{client: client, name: name}
+}
+
+func (m Method[Req, Resp]) Name() string {
+	return m.name
+}
+
+func (m Method[Req, Resp]) Call(ctx context.Context, req Req) (Resp, error) {
+	if m.client == nil {
+		var resp Resp
+		return resp, errors.New("motan method " + m.name + " is not bound to a client")
+	}
+	return Call[Req, Resp](ctx, m.client, m.name, req)
+}
+
+func (m *Method[Req, Resp]) bind(client *Client, name string) {
+	m.client, m.name = client, name
+}
+
+type methodBinder interface {
+	bind(client *Client, name string)
+}
+
+// Bind binds the Method fields of the service struct to the client, the method name is the tag `motan:"name"`
+// or the field name with the first letter lower cased, e.g.
+//
+//	type HelloService struct {
+//		Hello motan.Method[string, string]
+//		Greet motan.Method[*GreetRequest, *GreetReply] `motan:"greet_v2"`
+//	}
+//	svc, err := motan.Bind[HelloService](client)
+//	reply, err := svc.Hello.Call(ctx, "ray")
+func Bind[S any](client *Client) (*S, error) {
+	svc := new(S)
+	v := reflect.ValueOf(svc).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("motan service binding must be a struct, but is " + v.Type().String())
+	}
+	t := v.Type()
+	bound := 0
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		b, ok := v.Field(i).Addr().Interface().(methodBinder)
+		if !ok {
+			continue
+		}
+		name := f.Tag.Get("motan")
+		if name == "" {
+			name = strings.ToLower(f.Name[:1]) + f.Name[1:]
+		}
+		b.bind(client, name)
+		bound++
+	}
+	if bound == 0 {
+		return nil, errors.New("no motan method found in " + t.String())
+	}
+	return svc, nil
+}
@@ -0,0 +1,142 @@
+//go:build go1.18
+// +build go1.18
+
+package motan
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/weibocom/motan-go/cluster"
+	motan "github.com/weibocom/motan-go/core"
+	"github.com/weibocom/motan-go/provider"
+	"github.com/weibocom/motan-go/server"
+)
+
+const typedTestPort = 64590
+
+type typedTestRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *typedTestRequest) Reset()         { *m = typedTestRequest{} }
+func (m *typedTestRequest) String() string { return proto.CompactTextString(m) }
+func (*typedTestRequest) ProtoMessage()    {}
+
+type typedTestReply struct {
+	Message string `protobuf:"bytes,1,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *typedTestReply) Reset()         { *m = typedTestReply{} }
+func (m *typedTestReply) String() string { return proto.CompactTextString(m) }
+func (*typedTestReply) ProtoMessage()    {}
+
+type typedTestService struct{}
+
+func (s *typedTestService) Hello(name string) string {
+	return "hello " + name
+}
+
+func (s *typedTestService) Fail(name string) (string, error) {
+	return "", errors.New("fail " + name)
+}
+
+func (s *typedTestService) Greet(req *typedTestRequest) *typedTestReply {
+	return &typedTestReply{Message: "greet " + req.Name}
+}
+
+type typedTestBinding struct {
+	Hello   Method[string, string]
+	Greet   Method[*typedTestRequest, *typedTestReply] `motan:"greet"`
+	Missing Method[string, string]                     `motan:"missing"`
+	other   string
+}
+
+func startTypedTestServer(t *testing.T) *server.MotanServer {
+	p := &provider.DefaultProvider{}
+	p.SetURL(&motan.URL{Path: "typedService"})
+	p.SetService(&typedTestService{})
+	p.Initialize()
+	handler := &server.DefaultMessageHandler{}
+	handler.Initialize()
+	handler.AddProvider(p)
+	s := &server.MotanServer{URL: &motan.URL{Port: typedTestPort}}
+	if err := s.Open(false, false, handler, GetDefaultExtFactory()); err != nil {
+		t.Fatalf("open server fail. err:%v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	return s
+}
+
+func newTypedTestClient(serialization string) *Client {
+	ext := GetDefaultExtFactory()
+	ctx := &motan.Context{ClientURL: &motan.URL{}, RegistryURLs: map[string]*motan.URL{"direct": {Protocol: "direct", Host: "127.0.0.1", Port: typedTestPort}}}
+	url := &motan.URL{Protocol: "motan2", Path: "typedService", Parameters: map[string]string{
+		motan.RegistryKey: "direct", motan.SerializationKey: serialization, motan.TimeOutKey: "1000"}}
+	return &Client{url: url, cluster: cluster.NewCluster(ctx, ext, url, false), extFactory: ext}
+}
+
+func TestTypedCall(t *testing.T) {
+	s := startTypedTestServer(t)
+	defer s.Destroy()
+	client := newTypedTestClient("simple")
+	defer client.cluster.Destroy()
+	ctx := context.Background()
+
+	reply, err := Call[string, string](ctx, client, "hello", "ray")
+	if err != nil || reply != "hello ray" {
+		t.Errorf("value reply not correct. reply:%s, err:%v", reply, err)
+	}
+	ptr, err := Call[string, *string](ctx, client, "hello", "ray")
+	if err != nil || ptr == nil || *ptr != "hello ray" {
+		t.Errorf("pointer reply not correct. reply:%v, err:%v", ptr, err)
+	}
+	reply, err = Call[string, string](ctx, client, "fail", "ray")
+	if err == nil || !strings.Contains(err.Error(), "fail ray") || reply != "" {
+		t.Errorf("error of the provider should be returned. reply:%s, err:%v", reply, err)
+	}
+	if ptr, err = Call[string, *string](ctx, client, "fail", "ray"); err == nil || ptr != nil {
+		t.Errorf("pointer reply of a failed call should be nil. reply:%v, err:%v", ptr, err)
+	}
+	if _, err = NewMethod[string, string](nil, "hello").Call(ctx, "ray"); err == nil {
+		t.Error("method not bound should fail")
+	}
+}
+
+func TestTypedBind(t *testing.T) {
+	s := startTypedTestServer(t)
+	defer s.Destroy()
+	client := newTypedTestClient("protobuf")
+	defer client.cluster.Destroy()
+	ctx := context.Background()
+
+	svc, err := Bind[typedTestBinding](client)
+	if err != nil {
+		t.Fatalf("bind fail. err:%v", err)
+	}
+	if svc.Hello.Name() != "hello" || svc.Greet.Name() != "greet" || svc.Missing.Name() != "missing" {
+		t.Errorf("method names not correct. hello:%s, greet:%s, missing:%s", svc.Hello.Name(), svc.Greet.Name(), svc.Missing.Name())
+	}
+	reply, err := svc.Hello.Call(ctx, "ray")
+	if err != nil || reply != "hello ray" {
+		t.Errorf("value reply not correct. reply:%s, err:%v", reply, err)
+	}
+	greet, err := svc.Greet.Call(ctx, &typedTestRequest{Name: "ray"})
+	if err != nil || greet == nil || greet.Message != "greet ray" {
+		t.Errorf("pb reply not correct. reply:%v, err:%v", greet, err)
+	}
+	if _, err = svc.Missing.Call(ctx, "ray"); err == nil {
+		t.Error("call of an unknown method should fail")
+	}
+
+	if _, err = Bind[struct{ Name string }](client); err == nil {
+		t.Error("binding without methods should fail")
+	}
+	if _, err = Bind[string](client); err == nil {
+		t.Error("binding of a non-struct should fail")
+	}
+}